@@ -51,9 +51,10 @@ func NewHandler() (*Handler, error) {
 	}
 
 	var appStoreCredentials struct {
-		KeyID      string `json:"keyId"`
-		IssuerID   string `json:"issuerId"`
-		PrivateKey string `json:"privateKey"`
+		KeyID        string `json:"keyId"`
+		IssuerID     string `json:"issuerId"`
+		PrivateKey   string `json:"privateKey"`
+		VendorNumber string `json:"vendorNumber"`
 	}
 
 	if err := json.Unmarshal([]byte(*secretResult.SecretString), &appStoreCredentials); err != nil {
@@ -69,6 +70,7 @@ func NewHandler() (*Handler, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize App Store Connect client: %w", err)
 	}
+	appStoreClient.SetVendorNumber(appStoreCredentials.VendorNumber)
 
 	// Initialize JWT manager for auth validation
 	jwtSecret := os.Getenv("JWT_SECRET")
@@ -229,4 +231,4 @@ func main() {
 	}
 
 	lambda.Start(handler.HandleRequest)
-}
\ No newline at end of file
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"time"
 
@@ -12,29 +13,49 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	awslib "github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
 	"github.com/jamesvolpe/central-analytics/backend/pkg/response"
 )
 
 type AuthRequest struct {
 	IDToken string `json:"idToken"`
+	Nonce   string `json:"nonce"` // Raw nonce whose SHA256 the client sent to Apple
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 type AuthResponse struct {
-	AccessToken string `json:"accessToken"`
-	User        struct {
-		ID      string `json:"id"`
-		Email   string `json:"email"`
-		IsAdmin bool   `json:"isAdmin"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	User         struct {
+		ID             string   `json:"id"` // Apple sub; the stable user key
+		Email          string   `json:"email"`
+		IsPrivateRelay bool     `json:"isPrivateRelay"`
+		IsAdmin        bool     `json:"isAdmin"`
+		Scopes         []string `json:"scopes,omitempty"`
 	} `json:"user"`
-	ExpiresIn int64 `json:"expiresIn"`
+	ExpiresIn        int64 `json:"expiresIn"`
+	RefreshExpiresIn int64 `json:"refreshExpiresIn"`
 }
 
 type Handler struct {
 	appleVerifier *auth.AppleAuthVerifier
 	jwtManager    *auth.JWTManager
+	authMetrics   *auth.AuthMetrics
+	userRoles     auth.RoleSet
+	logger        *slog.Logger
+
+	// Accept sign-ins without a nonce, from clients that predate nonces. Such tokens can be
+	// replayed until they expire, so this is off unless ALLOW_NONCELESS_SIGN_IN=true.
+	allowNoncelessSignIn bool
 }
 
 func NewHandler() (*Handler, error) {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
 	// Load AWS config
 	cfg, err := config.LoadDefaultConfig(context.Background())
 	if err != nil {
@@ -57,29 +78,78 @@ func NewHandler() (*Handler, error) {
 		return nil, fmt.Errorf("failed to get JWT secret: %w", err)
 	}
 
-	// Get admin Apple ID sub
-	adminSub := os.Getenv("ADMIN_APPLE_SUB")
-	if adminSub == "" {
-		return nil, fmt.Errorf("ADMIN_APPLE_SUB environment variable not set")
+	// Get admin Apple ID subs
+	adminSubs := appconfig.AdminAppleSubs()
+	if len(adminSubs) == 0 {
+		return nil, fmt.Errorf("ADMIN_APPLE_SUBS or ADMIN_APPLE_SUB environment variable not set")
+	}
+
+	// Scopes granted to non-admin users
+	userRoles, err := auth.ParseRoleSet(os.Getenv("USER_ROLES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid USER_ROLES: %w", err)
 	}
 
-	// Initialize Apple verifier
-	appleVerifier, err := auth.NewAppleAuthVerifier(adminSub)
+	// Initialize Apple verifier; APPLE_CLIENT_ID pins tokens to our app's client ID
+	appleClientID := os.Getenv("APPLE_CLIENT_ID")
+	if appleClientID == "" {
+		logger.Warn("APPLE_CLIENT_ID not set; Apple tokens for any app will be accepted")
+	}
+	appleVerifier, err := auth.NewAppleAuthVerifier(adminSubs, appleClientID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize Apple verifier: %w", err)
 	}
+	if interval := os.Getenv("APPLE_KEY_REFRESH_INTERVAL"); interval != "" {
+		refreshInterval, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid APPLE_KEY_REFRESH_INTERVAL: %w", err)
+		}
+		appleVerifier.SetKeyRefreshInterval(refreshInterval)
+	}
+
+	// Make sign-in nonces single-use; the table shares them across Lambda instances. An
+	// in-memory store lets a nonce be replayed on another instance, so it is only used
+	// when ALLOW_MEMORY_NONCE_STORE=true.
+	if table := os.Getenv("NONCE_TABLE"); table != "" {
+		appleVerifier.SetNonceStore(awslib.NewNonceStore(cfg, table))
+	} else if os.Getenv("ALLOW_MEMORY_NONCE_STORE") == "true" {
+		logger.Warn("NONCE_TABLE not set; sign-in nonces are only single-use within one Lambda instance")
+		appleVerifier.SetNonceStore(auth.NewMemoryNonceStore())
+	} else {
+		return nil, fmt.Errorf("NONCE_TABLE environment variable not set")
+	}
 
-	// Initialize JWT manager
-	jwtTTL := 24 * time.Hour // Default 24 hours
+	// Initialize JWT manager; access tokens are short-lived and renewed with refresh tokens
+	jwtTTL := 15 * time.Minute
 	jwtManager := auth.NewJWTManager(
 		[]byte(*secretResult.SecretString),
 		"central-analytics",
 		jwtTTL,
 	)
 
+	// Clock skew tolerated when validating Apple and session token times
+	if value := os.Getenv("JWT_CLOCK_SKEW_LEEWAY"); value != "" {
+		leeway, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid JWT_CLOCK_SKEW_LEEWAY: %w", err)
+		}
+		appleVerifier.SetLeeway(leeway)
+		jwtManager.SetLeeway(leeway)
+	}
+
+	// Enable logout by blacklisting token IDs
+	if table := os.Getenv("TOKEN_REVOCATION_TABLE"); table != "" {
+		jwtManager.SetRevocationStore(awslib.NewTokenRevocationStore(cfg, table))
+	}
+
 	return &Handler{
 		appleVerifier: appleVerifier,
 		jwtManager:    jwtManager,
+		authMetrics:   auth.NewAuthMetrics(),
+		userRoles:     userRoles,
+		logger:        logger,
+
+		allowNoncelessSignIn: os.Getenv("ALLOW_NONCELESS_SIGN_IN") == "true",
 	}, nil
 }
 
@@ -96,6 +166,8 @@ func (h *Handler) HandleRequest(ctx context.Context, request events.APIGatewayPr
 		return h.handleRefresh(ctx, request)
 	case "/api/auth/logout":
 		return h.handleLogout(ctx, request)
+	case "/api/auth/me":
+		return h.handleMe(ctx, request)
 	default:
 		return response.Error(404, "Not found"), nil
 	}
@@ -111,41 +183,101 @@ func (h *Handler) handleVerify(ctx context.Context, request events.APIGatewayPro
 		return response.Error(400, "ID token is required"), nil
 	}
 
-	// Verify Apple ID token
-	claims, err := h.appleVerifier.VerifyToken(authReq.IDToken)
-	if err != nil {
-		// Try refreshing keys once and retry
-		if refreshErr := h.appleVerifier.RefreshKeys(); refreshErr == nil {
-			claims, err = h.appleVerifier.VerifyToken(authReq.IDToken)
-		}
-		if err != nil {
-			return response.Error(401, "Invalid Apple ID token"), nil
+	// Without a nonce a captured token could be replayed, so one is required unless legacy
+	// clients are explicitly allowed
+	if authReq.Nonce == "" && !h.allowNoncelessSignIn {
+		h.authMetrics.Record(auth.OutcomeInvalid)
+		h.logAuthOutcome(request.Path, auth.OutcomeInvalid)
+		return response.Error(400, "Nonce is required"), nil
+	}
+
+	// Verify Apple ID token; the verifier refreshes rotated keys itself
+	var claims *auth.AppleTokenClaims
+	var err error
+	if authReq.Nonce != "" {
+		claims, err = h.appleVerifier.VerifyTokenWithNonce(authReq.IDToken, authReq.Nonce)
+	} else {
+		claims, err = h.appleVerifier.VerifyToken(authReq.IDToken)
+		// A token bound to a nonce is only accepted with that nonce, or it could be replayed
+		if err == nil && claims.Nonce != "" {
+			err = auth.ErrNonceMismatch
 		}
 	}
+	if err != nil {
+		h.logAuthOutcome(request.Path, h.authMetrics.RecordValidation(err))
+		return response.Error(401, "Invalid Apple ID token"), nil
+	}
+	h.logAuthOutcome(request.Path, h.authMetrics.RecordValidation(nil))
 
 	// Get user info
 	userInfo := h.appleVerifier.GetUserInfo(claims)
+	userInfo.Scopes = h.userRoles.Scopes(userInfo.Sub)
 
-	// Generate JWT session token
-	accessToken, err := h.jwtManager.GenerateToken(userInfo)
+	// Generate JWT access and refresh tokens
+	tokens, err := h.jwtManager.GenerateTokenPair(userInfo)
 	if err != nil {
 		return response.Error(500, "Failed to generate session token"), nil
 	}
 
 	// Build response
 	authResp := AuthResponse{
-		AccessToken: accessToken,
-		ExpiresIn:   86400, // 24 hours in seconds
+		AccessToken:      tokens.AccessToken,
+		RefreshToken:     tokens.RefreshToken,
+		ExpiresIn:        int64(tokens.ExpiresIn.Seconds()),
+		RefreshExpiresIn: int64(tokens.RefreshExpiresIn.Seconds()),
 	}
 	authResp.User.ID = userInfo.Sub
 	authResp.User.Email = userInfo.Email
+	authResp.User.IsPrivateRelay = userInfo.IsPrivateRelay
 	authResp.User.IsAdmin = userInfo.IsAdmin
+	authResp.User.Scopes = userInfo.Scopes
 
 	return response.Success(200, authResp), nil
 }
 
 func (h *Handler) handleRefresh(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// Extract token from Authorization header
+	// Refresh token from the body, falling back to the Authorization header
+	var refreshReq RefreshRequest
+	if request.Body != "" {
+		if err := json.Unmarshal([]byte(request.Body), &refreshReq); err != nil {
+			return response.Error(400, "Invalid request body"), nil
+		}
+	}
+
+	tokenString := refreshReq.RefreshToken
+	if tokenString == "" {
+		authHeader := request.Headers["Authorization"]
+		if authHeader == "" {
+			authHeader = request.Headers["authorization"]
+		}
+		tokenString = authHeader
+		if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+			tokenString = authHeader[7:]
+		}
+	}
+
+	if tokenString == "" {
+		h.authMetrics.Record(auth.OutcomeMissingToken)
+		h.logAuthOutcome(request.Path, auth.OutcomeMissingToken)
+		return response.Error(401, "Refresh token required"), nil
+	}
+
+	// Validate and rotate the refresh token
+	tokens, err := h.jwtManager.RefreshToken(tokenString)
+	h.logAuthOutcome(request.Path, h.authMetrics.RecordValidation(err))
+	if err != nil {
+		return response.Error(401, "Invalid or expired refresh token"), nil
+	}
+
+	return response.Success(200, map[string]interface{}{
+		"accessToken":      tokens.AccessToken,
+		"refreshToken":     tokens.RefreshToken,
+		"expiresIn":        int64(tokens.ExpiresIn.Seconds()),
+		"refreshExpiresIn": int64(tokens.RefreshExpiresIn.Seconds()),
+	}), nil
+}
+
+func (h *Handler) handleLogout(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	authHeader := request.Headers["Authorization"]
 	if authHeader == "" {
 		authHeader = request.Headers["authorization"]
@@ -155,36 +287,71 @@ func (h *Handler) handleRefresh(ctx context.Context, request events.APIGatewayPr
 		return response.Error(401, "Authorization header required"), nil
 	}
 
-	// Remove "Bearer " prefix
 	tokenString := authHeader
 	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
 		tokenString = authHeader[7:]
 	}
 
-	// Validate current token
+	// Revoke the token so it can't be used again before it expires
 	claims, err := h.jwtManager.ValidateToken(tokenString)
 	if err != nil {
 		return response.Error(401, "Invalid or expired token"), nil
 	}
 
-	// Generate new token
-	newToken, err := h.jwtManager.RefreshToken(claims)
+	if err := h.jwtManager.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		h.logger.Error("Failed to revoke token", "error", err)
+		return response.Error(500, "Failed to log out"), nil
+	}
+
+	// Also revoke the refresh token when the client sends it, ending the session entirely
+	var refreshReq RefreshRequest
+	if request.Body != "" && json.Unmarshal([]byte(request.Body), &refreshReq) == nil && refreshReq.RefreshToken != "" {
+		if err := h.jwtManager.RevokeRefreshToken(refreshReq.RefreshToken); err != nil {
+			h.logger.Warn("Failed to revoke refresh token", "error", err)
+		}
+	}
+
+	return response.Success(200, map[string]string{
+		"message": "Logged out successfully",
+	}), nil
+}
+
+func (h *Handler) handleMe(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	authHeader := request.Headers["Authorization"]
+	if authHeader == "" {
+		authHeader = request.Headers["authorization"]
+	}
+
+	if authHeader == "" {
+		return response.Error(401, "Authorization header required"), nil
+	}
+
+	tokenString := authHeader
+	if len(authHeader) > 7 && authHeader[:7] == "Bearer " {
+		tokenString = authHeader[7:]
+	}
+
+	claims, err := h.jwtManager.ValidateToken(tokenString)
 	if err != nil {
-		return response.Error(500, "Failed to refresh token"), nil
+		return response.Error(401, "Invalid or expired token"), nil
 	}
 
 	return response.Success(200, map[string]interface{}{
-		"accessToken": newToken,
-		"expiresIn":   86400,
+		"user": map[string]interface{}{
+			"id":             claims.UserID,
+			"email":          claims.Email,
+			"isPrivateRelay": claims.IsPrivateRelay || auth.IsPrivateRelayEmail(claims.Email),
+			"isAdmin":        claims.IsAdmin,
+			"scopes":         claims.Scopes,
+		},
+		"expiresAt": claims.ExpiresAt.Unix(),
 	}), nil
 }
 
-func (h *Handler) handleLogout(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	// In a stateless JWT implementation, logout is handled client-side
-	// We can optionally add token to a blacklist in DynamoDB if needed
-	return response.Success(200, map[string]string{
-		"message": "Logged out successfully",
-	}), nil
+// logAuthOutcome logs each authentication attempt so CloudWatch metric filters
+// can count outcomes across Lambda instances
+func (h *Handler) logAuthOutcome(path string, outcome auth.AuthOutcome) {
+	h.logger.Info("auth outcome", "outcome", outcome, "path", path)
 }
 
 func main() {
@@ -194,4 +361,4 @@ func main() {
 	}
 
 	lambda.Start(handler.HandleRequest)
-}
\ No newline at end of file
+}
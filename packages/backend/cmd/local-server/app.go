@@ -17,8 +17,12 @@ import (
 	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
 	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
 	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/clientip"
 	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
 	"github.com/jamesvolpe/central-analytics/backend/internal/handlers"
+	"github.com/jamesvolpe/central-analytics/backend/internal/version"
+	"github.com/jamesvolpe/central-analytics/backend/internal/workers"
+	apiresponse "github.com/jamesvolpe/central-analytics/backend/pkg/response"
 	"github.com/rs/cors"
 )
 
@@ -29,9 +33,12 @@ type App struct {
 	router            *mux.Router
 	appHandler        *handlers.AppHandler
 	metricsAggregator *handlers.MetricsAggregator
+	reportHandler     *handlers.ReportHandler
 	timeSeriesHandler *handlers.TimeSeriesHandler
 	echartsHandler    *handlers.EChartsHandler
 	corsHandler       *cors.Cors
+	workers           *workers.Registry
+	stopWorkers       context.CancelFunc
 }
 
 // NewApp creates a new application instance with all dependencies
@@ -46,11 +53,13 @@ func NewApp(cfg *Config) (*App, error) {
 		AddSource: true,
 	}))
 	slog.SetDefault(logger)
+	apiresponse.SetEnvelope(cfg.ResponseEnvelope)
 
 	app := &App{
-		config: cfg,
-		logger: logger,
-		router: mux.NewRouter(),
+		config:  cfg,
+		logger:  logger,
+		router:  mux.NewRouter(),
+		workers: workers.NewRegistry(),
 	}
 
 	// Initialize AWS configuration
@@ -61,52 +70,150 @@ func NewApp(cfg *Config) (*App, error) {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
+	// Give every AWS call its own deadline within the request
+	awsCfg.APIOptions = append(awsCfg.APIOptions, aws.WithQueryTimeout(cfg.AWSQueryTimeout))
+
+	// Limit in-flight AWS calls per app; waiting for a slot doesn't count against the timeout
+	concurrencyLimiter := aws.NewConcurrencyLimiter(cfg.AWSPerAppConcurrency)
+	awsCfg.APIOptions = append(awsCfg.APIOptions, concurrencyLimiter.Middleware())
+
 	// Initialize authentication
 	jwtManager := auth.NewJWTManager([]byte(cfg.JWTSecret), cfg.JWTIssuer, cfg.JWTTTL)
+	jwtManager.SetLeeway(cfg.JWTLeeway)
+	if cfg.TokenRevocationTable != "" {
+		jwtManager.SetRevocationStore(aws.NewTokenRevocationStore(awsCfg, cfg.TokenRevocationTable))
+	}
+	var apiKeys *auth.APIKeyAuthenticator
+	if cfg.APIKeysTable != "" {
+		apiKeys = auth.NewAPIKeyAuthenticator(aws.NewAPIKeyStore(awsCfg, cfg.APIKeysTable))
+		logger.Info("API key authentication enabled")
+	}
+	var alertNotifier handlers.Notifier
+	switch {
+	case cfg.AlertSNSTopicARN != "":
+		alertNotifier = handlers.NewSNSNotifier(aws.NewSNSPublisher(awsCfg, cfg.AlertSNSTopicARN))
+		logger.Info("Alert notifications enabled", "topic", cfg.AlertSNSTopicARN)
+	case cfg.AlertWebhookURL != "":
+		alertNotifier = handlers.NewWebhookNotifier(cfg.AlertWebhookURL)
+		logger.Info("Alert notifications enabled")
+	}
 	if cfg.AppleAuthEnabled {
 		logger.Info("Apple authentication enabled")
 	} else {
 		logger.Info("Apple authentication disabled (development mode)")
 	}
 
-	// Initialize AWS clients
-	cloudWatchClient := aws.NewCloudWatchClient(awsCfg)
-	costExplorerClient := aws.NewCostExplorerClient(awsCfg)
-	dynamoDBClient := aws.NewDynamoDBClient(awsCfg)
+	// Initialize AWS clients for enabled subsystems
+	cloudWatchClient := aws.NewCloudWatchClient(awsCfg, cfg.CloudWatchMaxAttempts)
+
+	var costExplorerClient *aws.CostExplorerClient
+	if cfg.Features.Cost {
+		costExplorerClient = aws.NewCostExplorerClient(awsCfg)
+	}
+
+	var dynamoDBClient *aws.DynamoDBClient
+	if cfg.Features.DynamoDB {
+		dynamoDBClient = aws.NewDynamoDBClient(awsCfg, cfg.CloudWatchMaxAttempts)
+	}
+
+	var taggingClient *aws.ResourceTaggingClient
+	var lambdaConfigClient *aws.LambdaConfigClient
+	if cfg.Features.Lambda {
+		taggingClient = aws.NewResourceTaggingClient(awsCfg)
+		lambdaConfigClient = aws.NewLambdaConfigClient(awsCfg)
+	}
+
+	var idempotencyStore handlers.IdempotencyStore
+	if cfg.IdempotencyTable != "" {
+		idempotencyStore = aws.NewIdempotencyStore(awsCfg, cfg.IdempotencyTable, cfg.IdempotencyTTL)
+	}
+
+	var ratingsHistoryStore handlers.RatingsHistoryStore
+	if cfg.Features.AppStore && cfg.RatingsHistoryTable != "" {
+		ratingsHistoryStore = aws.NewRatingsHistoryStore(awsCfg, cfg.RatingsHistoryTable)
+	}
+
+	// Retention windows for stored snapshots
+	retention := handlers.NewRetention(map[string]time.Duration{
+		handlers.RetentionStoreRatingsHistory: cfg.RatingsHistoryRetention,
+	})
 
 	// App Store Connect client initialization handled below
 
+	// Lambda rates for cost estimates in the configured region
+	lambdaPricing, knownRegion := aws.LambdaPricingForRegion(cfg.AWSRegion)
+	if !knownRegion {
+		logger.Info("No Lambda pricing for region, using us-east-1 rates", "region", cfg.AWSRegion)
+	}
+
+	// Resolve real client IPs behind the HTTPS proxy
+	clientIPResolver, err := clientip.NewResolver(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trusted proxies: %w", err)
+	}
+
 	// Initialize apps configuration
 	appsConfig := appconfig.NewAppsConfiguration()
 
 	// Initialize App Store Connect client if credentials provided
-	var appStoreConnectClient *appstore.AppStoreConnectClient
-	if cfg.AppStoreKeyID != "" && cfg.AppStoreIssuerID != "" && cfg.AppStorePrivateKey != "" {
-		appStoreConnectClient, err = appstore.NewAppStoreConnectClient(
+	var appStoreClient appstore.Client
+	if cfg.Features.AppStore && cfg.AppStoreKeyID != "" && cfg.AppStoreIssuerID != "" && cfg.AppStorePrivateKey != "" {
+		appStoreConnectClient, err := appstore.NewAppStoreConnectClient(
 			cfg.AppStoreKeyID,
 			cfg.AppStoreIssuerID,
 			[]byte(cfg.AppStorePrivateKey),
 		)
 		if err != nil {
 			logger.Warn("Failed to initialize App Store Connect client", "error", err)
+		} else {
+			appStoreConnectClient.SetMaxConcurrentRequests(cfg.AppStoreMaxConcurrency)
+			appStoreConnectClient.SetMaxPages(cfg.AppStoreMaxPages)
+			appStoreConnectClient.SetRequestTimeout(cfg.AppStoreRequestTimeout)
+			appStoreConnectClient.SetVendorNumber(cfg.AppStoreVendorNumber)
+			appStoreConnectClient.SetPayoutCurrency(cfg.AppStorePayoutCurrency, cfg.AppStoreExchangeRates)
+			appStoreClient = appStoreConnectClient
 		}
 	}
 
+	// Real credentials take precedence; otherwise serve synthetic App Store data in development
+	if appStoreClient == nil && cfg.Features.AppStore && cfg.MockAppStore {
+		logger.Warn("Using mock App Store data (MOCK_APPSTORE=true)")
+		appStoreClient = appstore.NewMockClient()
+	}
+
 	// Create an AppHandler with real dependencies (no mocking)
 	app.appHandler = &handlers.AppHandler{
-		CloudWatch:   cloudWatchClient,
-		CostExplorer: costExplorerClient,
-		DynamoDB:     dynamoDBClient,
-		AppStore:     appStoreConnectClient,
-		JWTManager:   jwtManager,
-		AppsConfig:   appsConfig,
-		Logger:       logger,
+		CloudWatch:     cloudWatchClient,
+		CostExplorer:   costExplorerClient,
+		DynamoDB:       dynamoDBClient,
+		AppStore:       appStoreClient,
+		Tagging:        taggingClient,
+		LambdaConfig:   lambdaConfigClient,
+		Idempotency:    idempotencyStore,
+		RatingsHistory: ratingsHistoryStore,
+		Retention:      retention,
+		Workers:        app.workers,
+		LambdaPricing:  lambdaPricing,
+		JWTManager:     jwtManager,
+		APIKeys:        apiKeys,
+		AuthMetrics:    auth.NewAuthMetrics(),
+		ClientIP:       clientIPResolver,
+		Concurrency:    concurrencyLimiter,
+		Polling:        handlers.NewPollingDetector(cfg.PollingThreshold, cfg.PollingWindow, logger),
+		AppsConfig:     appsConfig,
+		Features:       cfg.Features,
+		Freshness:      cfg.Freshness,
+		SLO:            cfg.SLO,
+		AlertRules:     cfg.AlertRules,
+		AlertTracker:   handlers.NewAlertTracker(alertNotifier, cfg.AlertRenotifyInterval, logger),
+		Logger:         logger,
 	}
 
 	// Initialize derived handlers
-	app.metricsAggregator = handlers.NewMetricsAggregator(app.appHandler, logger)
-	app.timeSeriesHandler = handlers.NewTimeSeriesHandler(app.appHandler, logger)
-	app.echartsHandler = handlers.NewEChartsHandler(app.appHandler, logger)
+	app.metricsAggregator = handlers.NewMetricsAggregator(app.appHandler, cfg.AggregatedDefaultDepth, cfg.MetricsCacheTTL, logger)
+	app.reportHandler = handlers.NewReportHandler(app.appHandler, app.metricsAggregator, logger)
+	app.timeSeriesHandler = handlers.NewTimeSeriesHandler(app.appHandler, cfg.MaxTimeSeriesBuckets, logger)
+	app.echartsHandler = handlers.NewEChartsHandler(app.appHandler, cfg.MetricsCacheTTL, logger)
 
 	// Setup CORS
 	app.corsHandler = cors.New(cors.Options{
@@ -119,11 +226,17 @@ func NewApp(cfg *Config) (*App, error) {
 	// Setup routes
 	app.setupRoutes()
 
+	// Start background workers
+	app.startWorkers()
+
 	logger.Info("Application initialized successfully",
 		"environment", cfg.Environment,
 		"port", cfg.Port,
 		"apple_auth_enabled", cfg.AppleAuthEnabled,
-		"app_store_enabled", appStoreConnectClient != nil)
+		"app_store_enabled", appStoreClient != nil,
+		"idempotency_enabled", idempotencyStore != nil,
+		"ratings_history_enabled", ratingsHistoryStore != nil,
+		"features", cfg.Features)
 
 	return app, nil
 }
@@ -132,75 +245,173 @@ func NewApp(cfg *Config) (*App, error) {
 func (app *App) setupRoutes() {
 	r := app.router
 
+	// Routes need an admin session unless wrapped in RequireScope, which also admits users whose
+	// roles grant the scope, or SessionMiddleware, which admits any signed-in user
+
 	// Health check
 	r.HandleFunc("/health", app.handleHealth).Methods("GET")
 
+	// Prometheus scrape endpoint
+	r.HandleFunc("/metrics", app.appHandler.GetPrometheusMetrics).Methods("GET")
+
 	// Apple auth endpoint (development fallback)
 	r.HandleFunc("/api/auth/apple", app.handleAppleAuth).Methods("POST")
+	r.HandleFunc("/api/auth/refresh", app.handleRefresh).Methods("POST")
+	r.HandleFunc("/api/auth/logout", app.appHandler.SessionMiddleware(app.handleLogout)).Methods("POST")
+	r.HandleFunc("/api/auth/me", app.appHandler.SessionMiddleware(app.handleMe)).Methods("GET")
+
+	features := app.config.Features
 
 	// Protected AWS Infrastructure Dashboard endpoints
-	r.HandleFunc("/api/apps/{appId}/aws/lambda", app.appHandler.AuthMiddleware(app.appHandler.GetLambdaMetrics)).Methods("GET")
-	r.HandleFunc("/api/apps/{appId}/aws/apigateway", app.appHandler.AuthMiddleware(app.appHandler.GetAPIGatewayMetrics)).Methods("GET")
-	r.HandleFunc("/api/apps/{appId}/aws/dynamodb", app.appHandler.AuthMiddleware(app.appHandler.GetDynamoDBMetrics)).Methods("GET")
-	r.HandleFunc("/api/apps/{appId}/aws/costs", app.appHandler.AuthMiddleware(app.appHandler.GetCostAnalytics)).Methods("GET")
+	if features.Lambda {
+		r.HandleFunc("/api/apps/{appId}/aws/lambda", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetLambdaMetrics)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/aws/lambda/statistics", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetLambdaStatistics)).Methods("GET")
+	}
+	r.HandleFunc("/api/apps/{appId}/aws/apigateway", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetAPIGatewayMetrics)).Methods("GET")
+	r.HandleFunc("/api/apps/{appId}/slo/deploy-recommendation", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetDeployRecommendation)).Methods("GET")
+	r.HandleFunc("/api/apps/{appId}/aws/apigateway/endpoints/slowest", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetSlowestEndpoints)).Methods("GET")
+	if features.DynamoDB {
+		r.HandleFunc("/api/apps/{appId}/aws/dynamodb", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetDynamoDBMetrics)).Methods("GET")
+	}
+	if features.Cost {
+		r.HandleFunc("/api/apps/{appId}/aws/costs", app.appHandler.RequireScope(auth.ScopeBilling, app.appHandler.GetCostAnalytics)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/aws/costs/categories", app.appHandler.RequireScope(auth.ScopeBilling, app.appHandler.GetCostByCategory)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/aws/costs/grouped", app.appHandler.RequireScope(auth.ScopeBilling, app.appHandler.GetCostGrouped)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/aws/cost/anomalies", app.appHandler.RequireScope(auth.ScopeBilling, app.appHandler.GetCostAnomalies)).Methods("GET")
+	}
 
 	// App Store Analytics endpoints
-	r.HandleFunc("/api/apps/{appId}/appstore/downloads", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreDownloads)).Methods("GET")
-	r.HandleFunc("/api/apps/{appId}/appstore/revenue", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreRevenue)).Methods("GET")
+	if features.AppStore {
+		r.HandleFunc("/api/apps/{appId}/appstore/downloads", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreDownloads)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/appstore/revenue", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreRevenue)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/appstore/subscriptions", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreSubscriptions)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/appstore/ratings/history", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreRatingsHistory)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/appstore/reviews", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreReviews)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/appstore/reports/refresh", app.appHandler.AuthMiddleware(app.appHandler.IdempotencyMiddleware(app.appHandler.RefreshAppStoreReport))).Methods("POST")
+		r.HandleFunc("/api/apps/{appId}/appstore/reports/{jobId}", app.appHandler.AuthMiddleware(app.appHandler.GetAppStoreReportJob)).Methods("GET")
+	}
 
 	// Health status endpoint
-	r.HandleFunc("/api/apps/{appId}/health", app.appHandler.AuthMiddleware(app.appHandler.GetHealthStatus)).Methods("GET")
+	r.HandleFunc("/api/apps/{appId}/health", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetHealthStatus)).Methods("GET")
+	r.HandleFunc("/api/apps/{appId}/alerts", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetAlerts)).Methods("GET")
+
+	// Ranked plain-language insights comparing against the previous period
+	r.HandleFunc("/api/apps/{appId}/insights", app.appHandler.RequireScope(auth.ScopeViewer, app.appHandler.GetInsights)).Methods("GET")
+
+	// Diagnostics endpoints
+	if features.AppStore {
+		r.HandleFunc("/api/diagnostics/appstore", app.appHandler.RequireScope(auth.ScopeOperator, app.appHandler.GetAppStoreDiagnostics)).Methods("GET")
+	}
+	r.HandleFunc("/api/diagnostics/workers", app.appHandler.RequireScope(auth.ScopeOperator, app.appHandler.GetWorkerDiagnostics)).Methods("GET")
+	r.HandleFunc("/api/diagnostics/retention", app.appHandler.RequireScope(auth.ScopeOperator, app.appHandler.GetRetentionDiagnostics)).Methods("GET")
+	r.HandleFunc("/api/diagnostics/auth", app.appHandler.RequireScope(auth.ScopeOperator, app.appHandler.GetAuthDiagnostics)).Methods("GET")
+	r.HandleFunc("/api/diagnostics/concurrency", app.appHandler.RequireScope(auth.ScopeOperator, app.appHandler.GetConcurrencyDiagnostics)).Methods("GET")
+	r.HandleFunc("/api/diagnostics/polling", app.appHandler.RequireScope(auth.ScopeOperator, app.appHandler.GetPollingDiagnostics)).Methods("GET")
+	r.HandleFunc("/api/diagnostics/cache", app.appHandler.RequireScope(auth.ScopeOperator, app.appHandler.GetCacheDiagnostics)).Methods("GET")
+
+	// Build version and enabled features without auth
+	r.HandleFunc("/api/version", app.appHandler.GetVersion).Methods("GET")
 
 	// Health endpoint without auth
 	r.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		response := map[string]interface{}{
 			"status":    "healthy",
 			"timestamp": time.Now().Unix(),
-			"version":   "1.0.0",
+			"version":   version.Version,
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		apiresponse.WriteJSON(w, http.StatusOK, response)
 	}).Methods("GET")
 
 	// Aggregated metrics endpoint
 	if app.metricsAggregator != nil {
-		r.HandleFunc("/api/apps/{appId}/metrics/aggregated", app.appHandler.AuthMiddleware(app.metricsAggregator.GetAggregatedMetrics)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/metrics/aggregated", app.appHandler.AuthMiddleware(app.metricsAggregator.Cached("aggregated", app.metricsAggregator.GetAggregatedMetrics))).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/metrics/stream", app.appHandler.AuthMiddleware(app.metricsAggregator.StreamAggregatedMetrics)).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/metrics/prometheus", app.appHandler.AuthMiddleware(app.metricsAggregator.Cached("prometheus", app.metricsAggregator.GetPrometheusMetrics))).Methods("GET")
+		r.HandleFunc("/api/apps/{appId}/card", app.appHandler.AuthMiddleware(app.metricsAggregator.GetAppCard)).Methods("GET")
+	}
+
+	// Side-by-side comparison of two explicit time windows
+	r.HandleFunc("/api/apps/{appId}/metrics/compare-windows", app.appHandler.AuthMiddleware(app.appHandler.CompareWindows)).Methods("POST")
+
+	// Downloadable HTML report
+	if app.reportHandler != nil {
+		r.HandleFunc("/api/apps/{appId}/reports/metrics", app.appHandler.AuthMiddleware(app.reportHandler.GetMetricsReport)).Methods("GET")
 	}
 
 	// Time series endpoints
 	if app.timeSeriesHandler != nil {
-		r.HandleFunc("/api/apps/{appId}/timeseries/lambda", app.appHandler.AuthMiddleware(app.timeSeriesHandler.GetLambdaTimeSeries)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/timeseries/apigateway", app.appHandler.AuthMiddleware(app.timeSeriesHandler.GetAPIGatewayTimeSeries)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/timeseries/dynamodb", app.appHandler.AuthMiddleware(app.timeSeriesHandler.GetDynamoDBTimeSeries)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/timeseries/cost", app.appHandler.AuthMiddleware(app.timeSeriesHandler.GetCostTimeSeries)).Methods("GET")
+		if features.Lambda {
+			r.HandleFunc("/api/apps/{appId}/timeseries/lambda", app.appHandler.RequireScope(auth.ScopeViewer, app.timeSeriesHandler.GetLambdaTimeSeries)).Methods("GET")
+		}
+		r.HandleFunc("/api/apps/{appId}/timeseries/apigateway", app.appHandler.RequireScope(auth.ScopeViewer, app.timeSeriesHandler.GetAPIGatewayTimeSeries)).Methods("GET")
+		if features.DynamoDB {
+			r.HandleFunc("/api/apps/{appId}/timeseries/dynamodb", app.appHandler.RequireScope(auth.ScopeViewer, app.timeSeriesHandler.GetDynamoDBTimeSeries)).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/timeseries/dynamodb/capacity", app.appHandler.RequireScope(auth.ScopeViewer, app.timeSeriesHandler.GetDynamoDBCapacityTimeSeries)).Methods("GET")
+		}
+		if features.Cost {
+			r.HandleFunc("/api/apps/{appId}/timeseries/cost", app.appHandler.RequireScope(auth.ScopeBilling, app.timeSeriesHandler.GetCostTimeSeries)).Methods("GET")
+		}
+		r.HandleFunc("/api/apps/{appId}/timeseries/export", app.appHandler.AuthMiddleware(app.timeSeriesHandler.ExportTimeSeries)).Methods("GET")
 	}
 
 	// ECharts formatted endpoints
 	if app.echartsHandler != nil {
-		r.HandleFunc("/api/apps/{appId}/metrics/lambda", app.appHandler.AuthMiddleware(app.echartsHandler.GetLambdaMetricsECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/apigateway", app.appHandler.AuthMiddleware(app.echartsHandler.GetAPIGatewayMetricsECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/dynamodb", app.appHandler.AuthMiddleware(app.echartsHandler.GetDynamoDBMetricsECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/costs", app.appHandler.AuthMiddleware(app.echartsHandler.GetCostMetricsECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/appstore/downloads", app.appHandler.AuthMiddleware(app.echartsHandler.GetAppStoreMetricsECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/appstore/revenue", app.appHandler.AuthMiddleware(app.echartsHandler.GetAppStoreMetricsECharts)).Methods("GET")
-
-		// Additional frontend-expected endpoints
-		r.HandleFunc("/api/apps/{appId}/metrics/aws/lambda/timeseries", app.appHandler.AuthMiddleware(app.echartsHandler.GetLambdaTimeSeriesECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/aws/lambda/functions", app.appHandler.AuthMiddleware(app.echartsHandler.GetLambdaFunctionsECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/aws/cost/breakdown", app.appHandler.AuthMiddleware(app.echartsHandler.GetCostBreakdownECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/aws/cost/daily", app.appHandler.AuthMiddleware(app.echartsHandler.GetCostDailyECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/aws/cost/projection", app.appHandler.AuthMiddleware(app.echartsHandler.GetCostProjectionECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/appstore/credit-packs", app.appHandler.AuthMiddleware(app.echartsHandler.GetCreditPacksECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/appstore/geographic", app.appHandler.AuthMiddleware(app.echartsHandler.GetGeographicECharts)).Methods("GET")
-		r.HandleFunc("/api/apps/{appId}/metrics/appstore/engagement", app.appHandler.AuthMiddleware(app.echartsHandler.GetEngagementECharts)).Methods("GET")
+		if features.Lambda {
+			r.HandleFunc("/api/apps/{appId}/metrics/lambda", app.appHandler.RequireScope(auth.ScopeViewer, app.echartsHandler.Cached("lambda", app.echartsHandler.GetLambdaMetricsECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/aws/lambda/timeseries", app.appHandler.RequireScope(auth.ScopeViewer, app.echartsHandler.Cached("aws:lambda:timeseries", app.echartsHandler.GetLambdaTimeSeriesECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/aws/lambda/functions", app.appHandler.RequireScope(auth.ScopeViewer, app.echartsHandler.Cached("aws:lambda:functions", app.echartsHandler.GetLambdaFunctionsECharts))).Methods("GET")
+		}
+		r.HandleFunc("/api/apps/{appId}/metrics/apigateway", app.appHandler.RequireScope(auth.ScopeViewer, app.echartsHandler.Cached("apigateway", app.echartsHandler.GetAPIGatewayMetricsECharts))).Methods("GET")
+		if features.DynamoDB {
+			r.HandleFunc("/api/apps/{appId}/metrics/dynamodb", app.appHandler.RequireScope(auth.ScopeViewer, app.echartsHandler.Cached("dynamodb", app.echartsHandler.GetDynamoDBMetricsECharts))).Methods("GET")
+		}
+		if features.Cost {
+			r.HandleFunc("/api/apps/{appId}/metrics/costs", app.appHandler.RequireScope(auth.ScopeBilling, app.echartsHandler.Cached("costs", app.echartsHandler.GetCostMetricsECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/aws/cost/breakdown", app.appHandler.RequireScope(auth.ScopeBilling, app.echartsHandler.Cached("aws:cost:breakdown", app.echartsHandler.GetCostBreakdownECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/aws/cost/daily", app.appHandler.RequireScope(auth.ScopeBilling, app.echartsHandler.Cached("aws:cost:daily", app.echartsHandler.GetCostDailyECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/aws/cost/projection", app.appHandler.RequireScope(auth.ScopeBilling, app.echartsHandler.Cached("aws:cost:projection", app.echartsHandler.GetCostProjectionECharts))).Methods("GET")
+		}
+		if features.AppStore {
+			r.HandleFunc("/api/apps/{appId}/metrics/appstore/downloads", app.appHandler.AuthMiddleware(app.echartsHandler.Cached("appstore:downloads", app.echartsHandler.GetAppStoreMetricsECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/appstore/revenue", app.appHandler.AuthMiddleware(app.echartsHandler.Cached("appstore:revenue", app.echartsHandler.GetAppStoreMetricsECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/appstore/credit-packs", app.appHandler.AuthMiddleware(app.echartsHandler.Cached("appstore:credit-packs", app.echartsHandler.GetCreditPacksECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/appstore/geographic", app.appHandler.AuthMiddleware(app.echartsHandler.Cached("appstore:geographic", app.echartsHandler.GetGeographicECharts))).Methods("GET")
+			r.HandleFunc("/api/apps/{appId}/metrics/appstore/engagement", app.appHandler.AuthMiddleware(app.echartsHandler.Cached("appstore:engagement", app.echartsHandler.GetEngagementECharts))).Methods("GET")
+		}
+	}
+}
+
+// startWorkers launches background workers; they stop when the app shuts down
+func (app *App) startWorkers() {
+	ctx, cancel := context.WithCancel(context.Background())
+	app.stopWorkers = cancel
+
+	// Synthetic ratings are never snapshotted into the history table
+	_, mockAppStore := app.appHandler.AppStore.(*appstore.MockClient)
+	if app.appHandler.AppStore != nil && !mockAppStore && app.appHandler.RatingsHistory != nil {
+		worker := app.workers.Register("appstore-ratings-snapshot", app.config.RatingsSnapshotInterval)
+		go worker.RunEvery(ctx, app.appHandler.SnapshotRatings)
+	}
+
+	if app.appHandler.RatingsHistory != nil {
+		worker := app.workers.Register("retention-prune", app.config.RetentionPruneInterval)
+		go worker.RunEvery(ctx, app.appHandler.PruneExpiredData)
+	}
+
+	// Without a webhook or SNS topic there's nowhere to send alerts, so health isn't polled for them
+	if app.config.AlertWebhookURL != "" || app.config.AlertSNSTopicARN != "" {
+		worker := app.workers.Register("alert-check", app.config.AlertCheckInterval)
+		go worker.RunEvery(ctx, app.appHandler.CheckAlerts)
 	}
 }
 
 // handleHealth handles health check requests
 func (app *App) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"healthy","timestamp":%d,"environment":"%s"}`, time.Now().Unix(), app.config.Environment)
+	apiresponse.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"status":      "healthy",
+		"timestamp":   time.Now().Unix(),
+		"environment": app.config.Environment,
+	})
 }
 
 // handleAppleAuth handles Apple authentication (development fallback)
@@ -210,7 +421,7 @@ func (app *App) handleAppleAuth(w http.ResponseWriter, r *http.Request) {
 	var req AppleAuthRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		app.logger.Error("Error decoding auth request", "error", err)
-		http.Error(w, "Invalid request", http.StatusBadRequest)
+		apiresponse.WriteError(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
@@ -240,22 +451,23 @@ func (app *App) handleAppleAuth(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	app.logger.Info("Auth request", "user", userSub, "email", req.Email)
+	app.logger.Info("Auth request", "user", userSub, "email", req.Email, "client_ip", app.appHandler.ClientIP.ClientIP(r))
 
-	adminSub := app.config.AdminAppleSub
-	if adminSub == "" {
-		adminSub = "dev-admin-sub"
-	}
+	isAdmin := auth.NewAdminSet(app.config.AdminAppleSubs).Contains(userSub)
+	scopes := app.config.UserRoles.Scopes(userSub)
 
-	// Generate JWT token
-	accessToken, err := app.appHandler.JWTManager.GenerateToken(&auth.AppleUserInfo{
-		Sub:     userSub,
-		Email:   req.Email,
-		IsAdmin: userSub == adminSub,
+	// Generate JWT access and refresh tokens
+	isPrivateRelay := auth.IsPrivateRelayEmail(req.Email)
+	tokens, err := app.appHandler.JWTManager.GenerateTokenPair(&auth.AppleUserInfo{
+		Sub:            userSub,
+		Email:          req.Email,
+		IsPrivateRelay: isPrivateRelay,
+		IsAdmin:        isAdmin,
+		Scopes:         scopes,
 	})
 	if err != nil {
 		app.logger.Error("Failed to generate token", "error", err)
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		apiresponse.WriteError(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
@@ -266,21 +478,100 @@ func (app *App) handleAppleAuth(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := AuthResponse{
-		AccessToken: accessToken,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
 		User: User{
-			ID:      userSub,
-			Email:   req.Email,
-			Name:    fullName,
-			IsAdmin: userSub == adminSub,
+			ID:             userSub,
+			Email:          req.Email,
+			IsPrivateRelay: isPrivateRelay,
+			Name:           fullName,
+			IsAdmin:        isAdmin,
+			Scopes:         scopes,
 		},
-		ExpiresIn: 86400,
+		ExpiresIn:        int64(tokens.ExpiresIn.Seconds()),
+		RefreshExpiresIn: int64(tokens.RefreshExpiresIn.Seconds()),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	apiresponse.WriteJSON(w, http.StatusOK, response)
 	app.logger.Info("Auth response sent")
 }
 
+// handleRefresh exchanges a refresh token for a new access token and a rotated refresh token
+func (app *App) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		app.appHandler.AuthMetrics.Record(auth.OutcomeMissingToken)
+		apiresponse.WriteError(w, "Refresh token required", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := app.appHandler.JWTManager.RefreshToken(req.RefreshToken)
+	app.appHandler.AuthMetrics.RecordValidation(err)
+	if err != nil {
+		app.logger.Warn("Token refresh rejected", "error", err, "client_ip", app.appHandler.ClientIP.ClientIP(r))
+		apiresponse.WriteError(w, "Invalid or expired refresh token", http.StatusUnauthorized)
+		return
+	}
+
+	response := map[string]interface{}{
+		"accessToken":      tokens.AccessToken,
+		"refreshToken":     tokens.RefreshToken,
+		"expiresIn":        int64(tokens.ExpiresIn.Seconds()),
+		"refreshExpiresIn": int64(tokens.RefreshExpiresIn.Seconds()),
+	}
+
+	apiresponse.WriteJSON(w, http.StatusOK, response)
+}
+
+// handleLogout revokes the caller's session token, and its refresh token when one is sent,
+// so neither can be reused before expiry
+func (app *App) handleLogout(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value("claims").(*auth.SessionClaims)
+	if !ok {
+		apiresponse.WriteError(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	if err := app.appHandler.JWTManager.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+		app.logger.Error("Failed to revoke token", "userID", claims.UserID, "error", err)
+		apiresponse.WriteError(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	var req RefreshRequest
+	if json.NewDecoder(r.Body).Decode(&req) == nil && req.RefreshToken != "" {
+		if err := app.appHandler.JWTManager.RevokeRefreshToken(req.RefreshToken); err != nil {
+			app.logger.Warn("Failed to revoke refresh token", "userID", claims.UserID, "error", err)
+		}
+	}
+
+	app.logger.Info("Token revoked", "userID", claims.UserID, "client_ip", app.appHandler.ClientIP.ClientIP(r))
+	apiresponse.WriteJSON(w, http.StatusOK, map[string]string{"message": "Logged out successfully"})
+}
+
+// handleMe returns the signed-in user from the session token
+func (app *App) handleMe(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value("claims").(*auth.SessionClaims)
+	if !ok {
+		apiresponse.WriteError(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user": User{
+			ID:             claims.UserID,
+			Email:          claims.Email,
+			IsPrivateRelay: claims.IsPrivateRelay || auth.IsPrivateRelayEmail(claims.Email),
+			IsAdmin:        claims.IsAdmin,
+			Scopes:         claims.Scopes,
+		},
+		"expiresAt": claims.ExpiresAt.Unix(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	apiresponse.WriteJSON(w, http.StatusOK, response)
+}
+
 // Router returns the configured router with CORS
 func (app *App) Router() http.Handler {
 	return app.corsHandler.Handler(app.router)
@@ -289,7 +580,9 @@ func (app *App) Router() http.Handler {
 // Shutdown gracefully shuts down the application
 func (app *App) Shutdown(ctx context.Context) error {
 	app.logger.Info("Application shutdown initiated")
-	// Add cleanup logic here if needed
+	if app.stopWorkers != nil {
+		app.stopWorkers()
+	}
 	return nil
 }
 
@@ -306,14 +599,25 @@ type AppleAuthRequest struct {
 }
 
 type AuthResponse struct {
-	AccessToken string `json:"accessToken"`
-	User        User   `json:"user"`
-	ExpiresIn   int64  `json:"expiresIn"`
+	AccessToken      string `json:"accessToken"`
+	RefreshToken     string `json:"refreshToken"`
+	User             User   `json:"user"`
+	ExpiresIn        int64  `json:"expiresIn"`
+	RefreshExpiresIn int64  `json:"refreshExpiresIn"`
+}
+
+// RefreshRequest carries the refresh token exchanged for a new token pair
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
+// User identifies a signed-in user. ID is the Apple sub, the only stable key; Email may
+// be a private relay address that changes per app or stops forwarding.
 type User struct {
-	ID      string `json:"id"`
-	Email   string `json:"email"`
-	Name    string `json:"name"`
-	IsAdmin bool   `json:"isAdmin"`
+	ID             string   `json:"id"`
+	Email          string   `json:"email"`
+	IsPrivateRelay bool     `json:"isPrivateRelay"`
+	Name           string   `json:"name"`
+	IsAdmin        bool     `json:"isAdmin"`
+	Scopes         []string `json:"scopes,omitempty"`
 }
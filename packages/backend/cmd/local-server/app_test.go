@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/version"
+)
+
+// featureRoutes maps each ENABLE_* flag to one route that exists only while it's set
+var featureRoutes = map[string]string{
+	"ENABLE_LAMBDA":   "/api/apps/test-app/aws/lambda",
+	"ENABLE_DYNAMODB": "/api/apps/test-app/aws/dynamodb",
+	"ENABLE_COST":     "/api/apps/test-app/aws/costs",
+	"ENABLE_APPSTORE": "/api/apps/test-app/appstore/downloads",
+}
+
+// newTestApp builds an App from the environment with only the enabled flag set
+func newTestApp(t *testing.T, enabled string) *App {
+	t.Helper()
+
+	t.Setenv("ENV", "development")
+	t.Setenv("ADMIN_APPLE_SUBS", "test-admin")
+	t.Setenv("MOCK_APPSTORE", "true")
+	for flag := range featureRoutes {
+		t.Setenv(flag, strconv.FormatBool(flag == enabled))
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatalf("NewApp: %v", err)
+	}
+	t.Cleanup(func() { app.Shutdown(context.Background()) })
+	return app
+}
+
+// featureSets enables no flags, then each flag on its own
+var featureSets = []string{"", "ENABLE_LAMBDA", "ENABLE_DYNAMODB", "ENABLE_COST", "ENABLE_APPSTORE"}
+
+func featureSetName(enabled string) string {
+	if enabled == "" {
+		return "none enabled"
+	}
+	return "only " + enabled
+}
+
+func TestDisabledFeaturesRegisterNoRoutes(t *testing.T) {
+	for _, enabled := range featureSets {
+		t.Run(featureSetName(enabled), func(t *testing.T) {
+			app := newTestApp(t, enabled)
+
+			for flag, path := range featureRoutes {
+				rec := httptest.NewRecorder()
+				app.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+				// An enabled route still needs a session, so it answers 401 rather than 404
+				if flag == enabled && rec.Code == http.StatusNotFound {
+					t.Errorf("%s is enabled but GET %s = 404", flag, path)
+				}
+				if flag != enabled && rec.Code != http.StatusNotFound {
+					t.Errorf("%s is disabled but GET %s = %d, want 404", flag, path, rec.Code)
+				}
+			}
+
+			// Routes outside the feature flags are always registered
+			rec := httptest.NewRecorder()
+			app.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("GET /api/health = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
+
+func TestDisabledFeaturesConstructNoClients(t *testing.T) {
+	for _, enabled := range featureSets {
+		t.Run(featureSetName(enabled), func(t *testing.T) {
+			handler := newTestApp(t, enabled).appHandler
+
+			clients := []struct {
+				name  string
+				flag  string
+				built bool
+			}{
+				{name: "Resource Groups Tagging", flag: "ENABLE_LAMBDA", built: handler.Tagging != nil},
+				{name: "Lambda config", flag: "ENABLE_LAMBDA", built: handler.LambdaConfig != nil},
+				{name: "DynamoDB", flag: "ENABLE_DYNAMODB", built: handler.DynamoDB != nil},
+				{name: "Cost Explorer", flag: "ENABLE_COST", built: handler.CostExplorer != nil},
+				{name: "App Store", flag: "ENABLE_APPSTORE", built: handler.AppStore != nil},
+			}
+			for _, client := range clients {
+				if want := client.flag == enabled; client.built != want {
+					t.Errorf("%s client constructed = %v, want %v", client.name, client.built, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVersionReportsBuildAndEnabledFeatures(t *testing.T) {
+	for _, enabled := range featureSets {
+		t.Run(featureSetName(enabled), func(t *testing.T) {
+			app := newTestApp(t, enabled)
+
+			rec := httptest.NewRecorder()
+			app.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET /api/version = %d, want 200", rec.Code)
+			}
+
+			var body struct {
+				Build      map[string]*string     `json:"build"`
+				Features   appconfig.FeatureFlags `json:"features"`
+				Subsystems map[string]bool        `json:"subsystems"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			for _, field := range []string{"version", "commit", "buildTime", "goVersion"} {
+				if body.Build[field] == nil {
+					t.Errorf("build is missing %s", field)
+				}
+			}
+			if v := body.Build["version"]; v == nil || *v != version.Version {
+				t.Errorf("build version = %v, want %q", v, version.Version)
+			}
+
+			want := appconfig.FeatureFlags{
+				Lambda:   enabled == "ENABLE_LAMBDA",
+				DynamoDB: enabled == "ENABLE_DYNAMODB",
+				Cost:     enabled == "ENABLE_COST",
+				AppStore: enabled == "ENABLE_APPSTORE",
+			}
+			if body.Features != want {
+				t.Errorf("features = %+v, want %+v", body.Features, want)
+			}
+			if body.Subsystems["appStoreConnect"] != want.AppStore || body.Subsystems["lambdaDiscovery"] != want.Lambda {
+				t.Errorf("subsystems = %v, want App Store %v and Lambda discovery %v", body.Subsystems, want.AppStore, want.Lambda)
+			}
+		})
+	}
+}
+
+func TestAuthMeFlagsPrivateRelay(t *testing.T) {
+	app := newTestApp(t, "")
+
+	tests := []struct {
+		name string
+		user auth.AppleUserInfo
+		want bool
+	}{
+		{name: "flagged at sign-in", user: auth.AppleUserInfo{Sub: "001.relay", Email: "abc123@privaterelay.appleid.com", IsPrivateRelay: true}, want: true},
+		{name: "relay address", user: auth.AppleUserInfo{Sub: "001.relay", Email: "abc123@privaterelay.appleid.com"}, want: true},
+		{name: "normal email", user: auth.AppleUserInfo{Sub: "001.normal", Email: "user@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := app.appHandler.JWTManager.GenerateToken(&tt.user)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			app.router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET /api/auth/me = %d, want 200: %s", rec.Code, rec.Body)
+			}
+
+			var body struct {
+				User User `json:"user"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			// Users are keyed by sub, never by a relay address
+			if body.User.ID != tt.user.Sub || body.User.Email != tt.user.Email || body.User.IsPrivateRelay != tt.want {
+				t.Errorf("user = %+v, want id %s with private relay %v", body.User, tt.user.Sub, tt.want)
+			}
+		})
+	}
+}
+
+// testAppStoreKey is a PEM-encoded P-256 key in the form App Store Connect issues
+func testAppStoreKey(t *testing.T) string {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestMockAppStoreOnlyWithoutCredentials(t *testing.T) {
+	t.Run("no credentials", func(t *testing.T) {
+		app := newTestApp(t, "ENABLE_APPSTORE")
+		if _, ok := app.appHandler.AppStore.(*appstore.MockClient); !ok {
+			t.Errorf("App Store client = %T, want the mock", app.appHandler.AppStore)
+		}
+	})
+
+	t.Run("real credentials", func(t *testing.T) {
+		t.Setenv("APP_STORE_KEY_ID", "KEY123")
+		t.Setenv("APP_STORE_ISSUER_ID", "issuer")
+		t.Setenv("APP_STORE_PRIVATE_KEY", testAppStoreKey(t))
+
+		app := newTestApp(t, "ENABLE_APPSTORE")
+		if _, ok := app.appHandler.AppStore.(*appstore.AppStoreConnectClient); !ok {
+			t.Errorf("App Store client = %T, want App Store Connect", app.appHandler.AppStore)
+		}
+	})
+
+	t.Run("production", func(t *testing.T) {
+		t.Setenv("ENV", "production")
+		t.Setenv("MOCK_APPSTORE", "true")
+
+		cfg, err := LoadConfig()
+		if err != nil {
+			t.Fatalf("LoadConfig: %v", err)
+		}
+		if cfg.MockAppStore {
+			t.Error("MOCK_APPSTORE is honoured in production")
+		}
+	})
+}
+
+func TestBillingScopeReachesOnlyCostRoutes(t *testing.T) {
+	t.Setenv("USER_ROLES", "001.billing=billing,002.viewer=viewer")
+	app := newTestApp(t, "ENABLE_COST")
+
+	// Routes reached with bad parameters answer 400 without calling AWS
+	routes := []struct {
+		path  string
+		scope string
+	}{
+		{path: "/api/apps/test-app/aws/costs/grouped?groupBy=account", scope: auth.ScopeBilling},
+		{path: "/api/apps/test-app/aws/costs/categories", scope: auth.ScopeBilling},
+		{path: "/api/apps/test-app/aws/apigateway?rate=hour", scope: auth.ScopeViewer},
+		{path: "/api/apps/test-app/aws/apigateway/endpoints/slowest?limit=0", scope: auth.ScopeViewer},
+		{path: "/api/diagnostics/cache", scope: auth.ScopeOperator},
+	}
+
+	tests := []struct {
+		name    string
+		user    auth.AppleUserInfo
+		allowed []string
+	}{
+		{name: "billing", user: auth.AppleUserInfo{Sub: "001.billing"}, allowed: []string{auth.ScopeBilling}},
+		{name: "viewer", user: auth.AppleUserInfo{Sub: "002.viewer"}, allowed: []string{auth.ScopeViewer}},
+		{name: "no role", user: auth.AppleUserInfo{Sub: "003.nobody"}},
+		{name: "admin", user: auth.AppleUserInfo{Sub: "test-admin", IsAdmin: true}, allowed: []string{auth.ScopeBilling, auth.ScopeViewer, auth.ScopeOperator}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.user.Scopes = app.config.UserRoles.Scopes(tt.user.Sub)
+			token, err := app.appHandler.JWTManager.GenerateToken(&tt.user)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+
+			for _, route := range routes {
+				req := httptest.NewRequest(http.MethodGet, route.path, nil)
+				req.Header.Set("Authorization", "Bearer "+token)
+				rec := httptest.NewRecorder()
+				app.router.ServeHTTP(rec, req)
+
+				if rec.Code == http.StatusUnauthorized || rec.Code == http.StatusNotFound {
+					t.Fatalf("GET %s = %d: %s", route.path, rec.Code, rec.Body)
+				}
+				want := slices.Contains(tt.allowed, route.scope)
+				if allowed := rec.Code != http.StatusForbidden; allowed != want {
+					t.Errorf("GET %s = %d, want allowed %v", route.path, rec.Code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLambdaFunctionsRouteRequiresViewerScope(t *testing.T) {
+	t.Setenv("USER_ROLES", "001.billing=billing,002.viewer=viewer")
+	app := newTestApp(t, "ENABLE_LAMBDA")
+
+	// An unknown function answers 400 without calling AWS
+	const path = "/api/apps/test-app/metrics/aws/lambda/functions?functions=not-a-function"
+
+	tests := []struct {
+		name string
+		user auth.AppleUserInfo
+		want int
+	}{
+		{name: "viewer", user: auth.AppleUserInfo{Sub: "002.viewer"}, want: http.StatusBadRequest},
+		{name: "billing", user: auth.AppleUserInfo{Sub: "001.billing"}, want: http.StatusForbidden},
+		{name: "no role", user: auth.AppleUserInfo{Sub: "003.nobody"}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.user.Scopes = app.config.UserRoles.Scopes(tt.user.Sub)
+			token, err := app.appHandler.JWTManager.GenerateToken(&tt.user)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			app.router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d: %s", path, rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
@@ -5,7 +5,15 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/clientip"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/handlers"
 )
 
 // Config holds all configuration for the local server
@@ -16,6 +24,9 @@ type Config struct {
 	WriteTimeout time.Duration
 	IdleTimeout  time.Duration
 
+	// Proxies trusted to set X-Forwarded-For (CIDRs or IPs)
+	TrustedProxies []string
+
 	// CORS configuration
 	CORSAllowedOrigins   []string
 	CORSAllowedMethods   []string
@@ -23,21 +34,98 @@ type Config struct {
 	CORSAllowCredentials bool
 
 	// Authentication configuration
-	JWTSecret     string
-	JWTIssuer     string
-	JWTTTL        time.Duration
-	AdminAppleSub string
+	JWTSecret      string
+	JWTIssuer      string
+	JWTTTL         time.Duration
+	JWTLeeway      time.Duration
+	AdminAppleSubs []string
+	UserRoles      auth.RoleSet // Scopes granted to non-admin users, from USER_ROLES
 
 	// Apple Sign In configuration
-	AppleAuthEnabled   bool
-	AppStoreKeyID      string
-	AppStoreIssuerID   string
-	AppStorePrivateKey string
+	AppleAuthEnabled       bool
+	AppStoreKeyID          string
+	AppStoreIssuerID       string
+	AppStorePrivateKey     string
+	AppStoreMaxConcurrency int
+	AppStoreMaxPages       int           // Pages followed per paginated App Store Connect request
+	AppStoreRequestTimeout time.Duration // Timeout for App Store Connect requests without a caller deadline
+	MockAppStore           bool
+	AppStorePayoutCurrency string                       // Currency sales report proceeds are converted to; empty disables conversion
+	AppStoreExchangeRates  appstore.StaticExchangeRates // Rates into AppStorePayoutCurrency
+	AppStoreVendorNumber   string                       // Vendor number for sales reports; downloads and revenue need it
 
 	// AWS configuration
 	AWSRegion    string
 	DefaultAppID string
 
+	// Timeout for each individual AWS query within a request
+	AWSQueryTimeout time.Duration
+
+	// Concurrent AWS calls allowed per app
+	AWSPerAppConcurrency int
+
+	// Attempts allowed for a throttled CloudWatch call before giving up
+	CloudWatchMaxAttempts int
+
+	// TLS settings for the local HTTPS proxy
+	TLS TLSSettings
+
+	// Serve HTTP/2 without TLS (h2c) on the backend port alongside HTTP/1.1
+	H2C bool
+
+	// Wrap HTTP responses in the {success, data} envelope the Lambda handlers use
+	ResponseEnvelope bool
+
+	// Enabled subsystems
+	Features appconfig.FeatureFlags
+
+	// Per-metric freshness windows for stale data detection
+	Freshness appconfig.FreshnessWindows
+
+	// Availability SLO for deploy recommendations
+	SLO appconfig.SLO
+
+	// Health check and alert thresholds per service
+	AlertRules appconfig.AlertRules
+
+	// Alert notifications (empty webhook URL and SNS topic disable them)
+	AlertWebhookURL       string
+	AlertSNSTopicARN      string        // Published to instead of the webhook when set
+	AlertRenotifyInterval time.Duration // How often a service that stays unhealthy is notified again; 0 never
+	AlertCheckInterval    time.Duration // How often every app's health is evaluated for notifications
+
+	// Idempotency configuration (empty table disables Idempotency-Key support)
+	IdempotencyTable string
+	IdempotencyTTL   time.Duration
+
+	// Maximum buckets a time series request may produce
+	MaxTimeSeriesBuckets int
+
+	// Aggregated endpoint depth used when a request doesn't set one
+	AggregatedDefaultDepth string
+
+	// How long aggregated and ECharts metrics responses are cached; zero disables caching
+	MetricsCacheTTL time.Duration
+
+	// Polling storm detection: identical queries per window before warning
+	PollingThreshold int
+	PollingWindow    time.Duration
+
+	// Ratings history configuration (empty table disables snapshots)
+	RatingsHistoryTable     string
+	RatingsSnapshotInterval time.Duration
+
+	// Data retention: how long stored snapshots are kept (0 keeps forever) and how often
+	// expired items are pruned
+	RatingsHistoryRetention time.Duration
+	RetentionPruneInterval  time.Duration
+
+	// Token revocation table (empty disables logout revocation)
+	TokenRevocationTable string
+
+	// API key table (empty disables X-API-Key authentication)
+	APIKeysTable string
+
 	// Environment
 	Environment string
 }
@@ -52,14 +140,14 @@ func LoadConfig() (*Config, error) {
 		IdleTimeout:  getDurationEnvOrDefault("IDLE_TIMEOUT", 120*time.Second),
 
 		// CORS defaults - dynamically configured based on domain
-		CORSAllowedOrigins: getCORSOrigins(),
+		CORSAllowedOrigins:   getCORSOrigins(),
 		CORSAllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		CORSAllowedHeaders:   []string{"*"},
 		CORSAllowCredentials: true,
 
 		// Auth defaults
 		JWTIssuer:   "central-analytics",
-		JWTTTL:      24 * time.Hour,
+		JWTTTL:      15 * time.Minute,
 		Environment: getEnvOrDefault("ENV", "development"),
 
 		// AWS defaults
@@ -68,18 +156,115 @@ func LoadConfig() (*Config, error) {
 
 	// Load secrets from env (in prod, these would come from AWS Secrets Manager)
 	cfg.JWTSecret = getEnvOrDefault("JWT_SECRET", "development-secret-change-in-production")
-	// Backend uses ADMIN_APPLE_SUB (frontend uses PUBLIC_ADMIN_APPLE_SUB)
-	cfg.AdminAppleSub = getEnvOrDefault("ADMIN_APPLE_SUB", "dev-admin-sub")
+	cfg.JWTLeeway = getDurationEnvOrDefault("JWT_CLOCK_SKEW_LEEWAY", auth.DefaultClockSkewLeeway)
+	// Backend uses ADMIN_APPLE_SUBS, or ADMIN_APPLE_SUB for a single admin (frontend uses PUBLIC_ADMIN_APPLE_SUB)
+	cfg.AdminAppleSubs = appconfig.AdminAppleSubs()
+	if len(cfg.AdminAppleSubs) == 0 {
+		cfg.AdminAppleSubs = []string{"dev-admin-sub"}
+	}
+
+	userRoles, err := auth.ParseRoleSet(os.Getenv("USER_ROLES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid USER_ROLES: %w", err)
+	}
+	cfg.UserRoles = userRoles
 
 	// Apple auth configuration
 	cfg.AppStoreKeyID = os.Getenv("APP_STORE_KEY_ID")
 	cfg.AppStoreIssuerID = os.Getenv("APP_STORE_ISSUER_ID")
 	cfg.AppStorePrivateKey = os.Getenv("APP_STORE_PRIVATE_KEY")
 	cfg.AppleAuthEnabled = cfg.AppStoreKeyID != "" && cfg.AppStoreIssuerID != "" && cfg.AppStorePrivateKey != ""
+	cfg.AppStoreMaxConcurrency = getIntEnvOrDefault("APP_STORE_MAX_CONCURRENCY", appstore.DefaultMaxConcurrentRequests)
+	cfg.AppStoreMaxPages = getIntEnvOrDefault("APP_STORE_MAX_PAGES", appstore.DefaultMaxPages)
+	cfg.AppStoreRequestTimeout = getDurationEnvOrDefault("APP_STORE_REQUEST_TIMEOUT", appstore.DefaultRequestTimeout)
+	cfg.AppStoreVendorNumber = os.Getenv("APP_STORE_VENDOR_NUMBER")
+	cfg.AppStorePayoutCurrency = os.Getenv("APP_STORE_PAYOUT_CURRENCY")
+	exchangeRates, err := appstore.ParseExchangeRates(os.Getenv("APP_STORE_EXCHANGE_RATES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APP_STORE_EXCHANGE_RATES: %w", err)
+	}
+	cfg.AppStoreExchangeRates = exchangeRates
+	// Synthetic App Store data when credentials are absent; never served in production
+	cfg.MockAppStore = os.Getenv("MOCK_APPSTORE") == "true" && !cfg.IsProduction()
 
 	// Default app ID
 	cfg.DefaultAppID = getEnvOrDefault("DEFAULT_APP_ID", "ilikeyacut")
 
+	// Subsystem feature flags (ENABLE_LAMBDA, ENABLE_DYNAMODB, ENABLE_COST, ENABLE_APPSTORE)
+	cfg.Features = appconfig.LoadFeatureFlags()
+
+	// Freshness windows (FRESHNESS_WINDOW_LAMBDA, FRESHNESS_WINDOW_APIGATEWAY, ...)
+	cfg.Freshness = appconfig.LoadFreshnessWindows()
+
+	// Availability SLO (SLO_TARGET, SLO_WINDOW, SLO_BURN_RATE_WINDOW)
+	cfg.SLO = appconfig.LoadSLO()
+
+	// Alert thresholds (ALERT_LAMBDA_ERROR_RATE, ALERT_API_LATENCY_P99, ...)
+	cfg.AlertRules = appconfig.LoadAlertRules()
+	cfg.AlertWebhookURL = os.Getenv("ALERT_WEBHOOK_URL")
+	cfg.AlertSNSTopicARN = os.Getenv("ALERT_SNS_TOPIC_ARN")
+	cfg.AlertRenotifyInterval = getDurationEnvOrDefault("ALERT_RENOTIFY_INTERVAL", time.Hour)
+	cfg.AlertCheckInterval = getDurationEnvOrDefault("ALERT_CHECK_INTERVAL", 5*time.Minute)
+
+	// Idempotency keys for mutating endpoints
+	cfg.IdempotencyTable = os.Getenv("IDEMPOTENCY_TABLE")
+	cfg.IdempotencyTTL = getDurationEnvOrDefault("IDEMPOTENCY_TTL", 24*time.Hour)
+
+	// Per-query AWS timeout so one stalled call can't starve a fan-out
+	cfg.AWSQueryTimeout = getDurationEnvOrDefault("AWS_QUERY_TIMEOUT", aws.DefaultQueryTimeout)
+
+	// Per-app AWS concurrency so one app's load can't starve the others
+	cfg.AWSPerAppConcurrency = getIntEnvOrDefault("AWS_PER_APP_CONCURRENCY", aws.DefaultPerAppConcurrency)
+
+	// CloudWatch retries so throttling during large fan-outs doesn't leave gaps
+	cfg.CloudWatchMaxAttempts = getIntEnvOrDefault("CLOUDWATCH_MAX_ATTEMPTS", aws.DefaultCloudWatchMaxAttempts)
+
+	// Time series bucket cap
+	cfg.MaxTimeSeriesBuckets = getIntEnvOrDefault("MAX_TIMESERIES_BUCKETS", handlers.DefaultMaxTimeSeriesBuckets)
+
+	// Aggregated endpoint default depth (summary or detailed)
+	cfg.AggregatedDefaultDepth = getEnvOrDefault("AGGREGATED_DEFAULT_DEPTH", handlers.DepthSummary)
+
+	// Metrics response cache
+	cfg.MetricsCacheTTL = getDurationEnvOrDefault("METRICS_CACHE_TTL", handlers.DefaultMetricsCacheTTL)
+
+	// Polling storm detection
+	cfg.PollingThreshold = getIntEnvOrDefault("POLLING_STORM_THRESHOLD", handlers.DefaultPollingThreshold)
+	cfg.PollingWindow = getDurationEnvOrDefault("POLLING_STORM_WINDOW", handlers.DefaultPollingWindow)
+
+	// App Store ratings history snapshots
+	cfg.RatingsHistoryTable = os.Getenv("RATINGS_HISTORY_TABLE")
+	cfg.RatingsSnapshotInterval = getDurationEnvOrDefault("RATINGS_SNAPSHOT_INTERVAL", 6*time.Hour)
+
+	// Retention pruning for stored snapshots
+	cfg.RatingsHistoryRetention = getDurationEnvOrDefault("RATINGS_HISTORY_RETENTION", handlers.DefaultRatingsHistoryRetention)
+	cfg.RetentionPruneInterval = getDurationEnvOrDefault("RETENTION_PRUNE_INTERVAL", 24*time.Hour)
+
+	// Session token blacklist
+	cfg.TokenRevocationTable = os.Getenv("TOKEN_REVOCATION_TABLE")
+
+	// Hashed API keys for automated consumers
+	cfg.APIKeysTable = os.Getenv("API_KEYS_TABLE")
+
+	// Trusted proxies for client IP resolution
+	cfg.TrustedProxies = clientip.DefaultTrustedProxies
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		cfg.TrustedProxies = strings.Split(proxies, ",")
+	}
+
+	// HTTPS proxy TLS versions and cipher suites
+	var cipherSuites []string
+	if suites := os.Getenv("TLS_CIPHER_SUITES"); suites != "" {
+		cipherSuites = strings.Split(suites, ",")
+	}
+	tlsSettings, err := ParseTLSSettings(os.Getenv("TLS_MIN_VERSION"), cipherSuites, os.Getenv("TLS13_ONLY") == "true")
+	if err != nil {
+		return nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+	cfg.TLS = tlsSettings
+	cfg.H2C = os.Getenv("H2C_ENABLED") == "true"
+	cfg.ResponseEnvelope = os.Getenv("RESPONSE_ENVELOPE") == "true"
+
 	// Override CORS origins if specified
 	if origins := os.Getenv("CORS_ALLOWED_ORIGINS"); origins != "" {
 		cfg.CORSAllowedOrigins = []string{origins}
@@ -98,8 +283,11 @@ func (c *Config) validate() error {
 	if c.JWTSecret == "" {
 		return fmt.Errorf("JWT_SECRET is required")
 	}
-	if c.AdminAppleSub == "" {
-		return fmt.Errorf("ADMIN_APPLE_SUB is required")
+	if len(c.AdminAppleSubs) == 0 {
+		return fmt.Errorf("ADMIN_APPLE_SUBS or ADMIN_APPLE_SUB is required")
+	}
+	if !handlers.IsAggregatedDepth(c.AggregatedDefaultDepth) {
+		return fmt.Errorf("AGGREGATED_DEFAULT_DEPTH must be %q or %q", handlers.DepthSummary, handlers.DepthDetailed)
 	}
 	return nil
 }
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// serverHandler returns the backend's handler, also accepting cleartext HTTP/2 (prior
+// knowledge or Upgrade: h2c) when enableH2C is set. HTTP/1.1 clients are served either way.
+func serverHandler(handler http.Handler, enableH2C bool) http.Handler {
+	if !enableH2C {
+		return handler
+	}
+	return h2c.NewHandler(handler, &http2.Server{})
+}
@@ -9,19 +9,101 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/http2"
 )
 
+// TLSSettings controls the protocol versions and cipher suites the proxy accepts
+type TLSSettings struct {
+	MinVersion   uint16
+	CipherSuites []uint16 // TLS 1.2 suites; TLS 1.3 suites are not configurable in Go
+}
+
+// defaultCipherSuites are the TLS 1.2 suites accepted unless TLS_CIPHER_SUITES overrides them
+var defaultCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+}
+
+// DefaultTLSSettings returns TLS 1.2 minimum with the default cipher suites
+func DefaultTLSSettings() TLSSettings {
+	return TLSSettings{
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: defaultCipherSuites,
+	}
+}
+
+// ParseTLSSettings builds settings from a minimum version ("1.2" or "1.3"), a list of cipher
+// suite names as reported by crypto/tls, and the TLS13_ONLY shorthand. Empty values keep the
+// defaults. Only suites Go considers secure are accepted.
+func ParseTLSSettings(minVersion string, cipherNames []string, tls13Only bool) (TLSSettings, error) {
+	settings := DefaultTLSSettings()
+
+	switch strings.TrimSpace(minVersion) {
+	case "", "1.2":
+	case "1.3":
+		settings.MinVersion = tls.VersionTLS13
+	default:
+		return settings, fmt.Errorf("unsupported TLS minimum version %q (use 1.2 or 1.3)", minVersion)
+	}
+	if tls13Only {
+		settings.MinVersion = tls.VersionTLS13
+	}
+
+	if len(cipherNames) > 0 {
+		known := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			known[suite.Name] = suite.ID
+		}
+
+		settings.CipherSuites = nil
+		for _, name := range cipherNames {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			id, ok := known[name]
+			if !ok {
+				return settings, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+			}
+			settings.CipherSuites = append(settings.CipherSuites, id)
+		}
+	}
+
+	return settings, nil
+}
+
+// tlsConfig returns a server TLS config serving cert with these settings. HTTP/2 is offered
+// first so browsers multiplex dashboard requests over one connection.
+func (s TLSSettings) tlsConfig(cert tls.Certificate) *tls.Config {
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   s.MinVersion,
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if s.MinVersion < tls.VersionTLS13 {
+		config.CipherSuites = s.CipherSuites
+	}
+	return config
+}
+
 // HTTPSProxy wraps an HTTP server with HTTPS using local certificates
 type HTTPSProxy struct {
-	targetPort  string
-	httpsPort   string
-	certFile    string
-	keyFile     string
-	proxy       *httputil.ReverseProxy
+	targetPort string
+	httpsPort  string
+	certFile   string
+	keyFile    string
+	proxy      *httputil.ReverseProxy
+	tls        TLSSettings
 }
 
 // NewHTTPSProxy creates a new HTTPS proxy server
-func NewHTTPSProxy(targetPort, httpsPort string) (*HTTPSProxy, error) {
+func NewHTTPSProxy(targetPort, httpsPort string, settings TLSSettings) (*HTTPSProxy, error) {
 	// Certificate files are always in root certs directory
 	certFile := filepath.Join("certs", "cert.pem")
 	keyFile := filepath.Join("certs", "key.pem")
@@ -40,17 +122,16 @@ func NewHTTPSProxy(targetPort, httpsPort string) (*HTTPSProxy, error) {
 		return nil, fmt.Errorf("failed to parse target URL: %w", err)
 	}
 
-	proxy := httputil.NewSingleHostReverseProxy(targetURL)
-
-	// Custom director to preserve headers
-	originalDirector := proxy.Director
-	proxy.Director = func(req *http.Request) {
-		originalDirector(req)
-		// Preserve the original host header for the backend
-		req.Host = targetURL.Host
-		// Add forwarded headers
-		req.Header.Set("X-Forwarded-Proto", "https")
-		req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(targetURL)
+			// Preserve the original host header for the backend
+			pr.Out.Host = targetURL.Host
+			// Keep any incoming X-Forwarded-For chain so SetXForwarded appends the
+			// real client address to it, along with X-Forwarded-Host and -Proto
+			pr.Out.Header["X-Forwarded-For"] = pr.In.Header["X-Forwarded-For"]
+			pr.SetXForwarded()
+		},
 	}
 
 	// Custom error handler
@@ -65,42 +146,41 @@ func NewHTTPSProxy(targetPort, httpsPort string) (*HTTPSProxy, error) {
 		certFile:   certFile,
 		keyFile:    keyFile,
 		proxy:      proxy,
+		tls:        settings,
 	}, nil
 }
 
 // Start starts the HTTPS proxy server
 func (p *HTTPSProxy) Start() error {
-	// Load certificates
-	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	server, err := p.newServer()
 	if err != nil {
-		return fmt.Errorf("failed to load certificates: %w", err)
+		return err
 	}
 
-	// Configure TLS
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		MinVersion:   tls.VersionTLS12,
-		CipherSuites: []uint16{
-			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
-			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-		},
+	log.Printf("Starting HTTPS proxy on https://local-dev.jcvolpe.me:%s", p.httpsPort)
+	log.Printf("Proxying to HTTP backend on port %s", p.targetPort)
+
+	return server.ListenAndServeTLS("", "")
+}
+
+// newServer loads the certificates and returns the HTTPS server, with HTTP/2 enabled
+func (p *HTTPSProxy) newServer() (*http.Server, error) {
+	// Load certificates
+	cert, err := tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificates: %w", err)
 	}
 
 	// Create HTTPS server
 	server := &http.Server{
 		Addr:      fmt.Sprintf(":%s", p.httpsPort),
 		Handler:   p,
-		TLSConfig: tlsConfig,
+		TLSConfig: p.tls.tlsConfig(cert),
 	}
-
-	log.Printf("Starting HTTPS proxy on https://local-dev.jcvolpe.me:%s", p.httpsPort)
-	log.Printf("Proxying to HTTP backend on port %s", p.targetPort)
-
-	return server.ListenAndServeTLS("", "")
+	if err := http2.ConfigureServer(server, nil); err != nil {
+		return nil, fmt.Errorf("failed to enable HTTP/2: %w", err)
+	}
+	return server, nil
 }
 
 // ServeHTTP handles incoming HTTPS requests and forwards them to the HTTP backend
@@ -113,10 +193,10 @@ func (p *HTTPSProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // StartHTTPSProxy is a convenience function to start the HTTPS proxy
-func StartHTTPSProxy(httpPort, httpsPort string) error {
-	proxy, err := NewHTTPSProxy(httpPort, httpsPort)
+func StartHTTPSProxy(httpPort, httpsPort string, settings TLSSettings) error {
+	proxy, err := NewHTTPSProxy(httpPort, httpsPort, settings)
 	if err != nil {
 		return err
 	}
 	return proxy.Start()
-}
\ No newline at end of file
+}
@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// writeTestCerts writes a self-signed localhost certificate where NewHTTPSProxy looks for
+// one, under a temporary working directory, and returns it
+func writeTestCerts(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("encode key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "certs"), 0o755); err != nil {
+		t.Fatalf("create certs directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "certs", "cert.pem"), certPEM, 0o600); err != nil {
+		t.Fatalf("write certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "certs", "key.pem"), keyPEM, 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory: %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("change working directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("load certificate: %v", err)
+	}
+	return cert
+}
+
+func TestHTTPSProxyAppendsForwardedFor(t *testing.T) {
+	writeTestCerts(t)
+
+	forwarded := make(chan http.Header, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header.Clone()
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+
+	proxy, err := NewHTTPSProxy(backendURL.Port(), "0", DefaultTLSSettings())
+	if err != nil {
+		t.Fatalf("NewHTTPSProxy: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		forwardedFor string
+		want         string
+	}{
+		{name: "direct client", want: "203.0.113.7"},
+		{name: "client behind another proxy", forwardedFor: "198.51.100.1", want: "198.51.100.1, 203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://local-dev.jcvolpe.me/api/health", nil)
+			req.RemoteAddr = "203.0.113.7:51000"
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			proxy.ServeHTTP(httptest.NewRecorder(), req)
+
+			header := <-forwarded
+			if got := header.Get("X-Forwarded-For"); got != tt.want {
+				t.Errorf("X-Forwarded-For = %q, want %q", got, tt.want)
+			}
+			if got := header.Get("X-Forwarded-Host"); got != "local-dev.jcvolpe.me" {
+				t.Errorf("X-Forwarded-Host = %q, want local-dev.jcvolpe.me", got)
+			}
+		})
+	}
+}
+
+// handshake serves one TLS handshake with settings and completes it with client, returning
+// the negotiated version
+func handshake(t *testing.T, cert tls.Certificate, settings TLSSettings, client *tls.Config) (uint16, error) {
+	t.Helper()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", settings.tlsConfig(cert))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	client.RootCAs = roots
+	client.ServerName = "localhost"
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: 5 * time.Second}, Config: client}
+	conn, err := dialer.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	return conn.(*tls.Conn).ConnectionState().Version, nil
+}
+
+func TestTLSSettingsHandshake(t *testing.T) {
+	cert := writeTestCerts(t)
+	tls13Only, err := ParseTLSSettings("", nil, true)
+	if err != nil {
+		t.Fatalf("ParseTLSSettings: %v", err)
+	}
+	oneSuite, err := ParseTLSSettings("", []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"}, false)
+	if err != nil {
+		t.Fatalf("ParseTLSSettings: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		settings    TLSSettings
+		client      *tls.Config
+		wantVersion uint16
+	}{
+		{name: "default accepts TLS 1.2", settings: DefaultTLSSettings(), client: &tls.Config{MaxVersion: tls.VersionTLS12}, wantVersion: tls.VersionTLS12},
+		{name: "default negotiates TLS 1.3", settings: DefaultTLSSettings(), client: &tls.Config{}, wantVersion: tls.VersionTLS13},
+		{name: "TLS 1.3 only rejects TLS 1.2", settings: tls13Only, client: &tls.Config{MaxVersion: tls.VersionTLS12}},
+		{name: "TLS 1.3 only accepts TLS 1.3", settings: tls13Only, client: &tls.Config{}, wantVersion: tls.VersionTLS13},
+		{name: "configured suite accepted", settings: oneSuite, client: &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}}, wantVersion: tls.VersionTLS12},
+		{name: "unconfigured suite rejected", settings: oneSuite, client: &tls.Config{MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := handshake(t, cert, tt.settings, tt.client)
+			if tt.wantVersion == 0 {
+				if err == nil {
+					t.Fatalf("handshake succeeded with %s, want it rejected", tls.VersionName(version))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("handshake: %v", err)
+			}
+			if version != tt.wantVersion {
+				t.Errorf("negotiated %s, want %s", tls.VersionName(version), tls.VersionName(tt.wantVersion))
+			}
+		})
+	}
+}
+
+func TestParseTLSSettings(t *testing.T) {
+	tests := []struct {
+		name        string
+		minVersion  string
+		ciphers     []string
+		tls13Only   bool
+		wantVersion uint16
+		wantSuites  []uint16
+		wantErr     bool
+	}{
+		{name: "defaults", wantVersion: tls.VersionTLS12, wantSuites: defaultCipherSuites},
+		{name: "minimum 1.2", minVersion: "1.2", wantVersion: tls.VersionTLS12, wantSuites: defaultCipherSuites},
+		{name: "minimum 1.3", minVersion: " 1.3 ", wantVersion: tls.VersionTLS13, wantSuites: defaultCipherSuites},
+		{name: "TLS13_ONLY overrides minimum", minVersion: "1.2", tls13Only: true, wantVersion: tls.VersionTLS13, wantSuites: defaultCipherSuites},
+		{
+			name:        "cipher suites",
+			ciphers:     []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", " ", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+			wantVersion: tls.VersionTLS12,
+			wantSuites:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305},
+		},
+		{name: "unsupported version", minVersion: "1.1", wantErr: true},
+		{name: "unknown suite", ciphers: []string{"TLS_FAKE_SUITE"}, wantErr: true},
+		{name: "insecure suite", ciphers: []string{"TLS_RSA_WITH_RC4_128_SHA"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := ParseTLSSettings(tt.minVersion, tt.ciphers, tt.tls13Only)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTLSSettings = %+v, want an error", settings)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTLSSettings: %v", err)
+			}
+			if settings.MinVersion != tt.wantVersion {
+				t.Errorf("MinVersion = %s, want %s", tls.VersionName(settings.MinVersion), tls.VersionName(tt.wantVersion))
+			}
+			if !reflect.DeepEqual(settings.CipherSuites, tt.wantSuites) {
+				t.Errorf("CipherSuites = %v, want %v", settings.CipherSuites, tt.wantSuites)
+			}
+		})
+	}
+}
+
+func TestHTTPSProxyNegotiatesHTTP2(t *testing.T) {
+	cert := writeTestCerts(t)
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+
+	proxy, err := NewHTTPSProxy(backendURL.Port(), "0", DefaultTLSSettings())
+	if err != nil {
+		t.Fatalf("NewHTTPSProxy: %v", err)
+	}
+	server, err := proxy.newServer()
+	if err != nil {
+		t.Fatalf("newServer: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go server.ServeTLS(listener, "", "")
+	defer server.Close()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, ServerName: "localhost"},
+			ForceAttemptHTTP2: true,
+		},
+	}
+
+	resp, err := client.Get("https://" + listener.Addr().String() + "/api/health")
+	if err != nil {
+		t.Fatalf("GET through proxy: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if resp.TLS == nil || resp.TLS.NegotiatedProtocol != "h2" {
+		t.Fatalf("negotiated protocol = %+v, want h2", resp.TLS)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("response protocol = %s, want HTTP/2.0", resp.Proto)
+	}
+}
+
+func TestServerHandlerH2C(t *testing.T) {
+	protos := make(chan string, 1)
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protos <- r.Proto
+	})
+
+	// A prior-knowledge h2c client speaks HTTP/2 over plain TCP
+	h2cClient := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	t.Run("enabled", func(t *testing.T) {
+		backend := httptest.NewServer(serverHandler(router, true))
+		defer backend.Close()
+
+		resp, err := h2cClient.Get(backend.URL)
+		if err != nil {
+			t.Fatalf("h2c GET: %v", err)
+		}
+		resp.Body.Close()
+		if got := <-protos; got != "HTTP/2.0" {
+			t.Errorf("backend saw %s, want HTTP/2.0", got)
+		}
+
+		// HTTP/1.1 clients are still served
+		resp, err = backend.Client().Get(backend.URL)
+		if err != nil {
+			t.Fatalf("HTTP/1.1 GET: %v", err)
+		}
+		resp.Body.Close()
+		if got := <-protos; got != "HTTP/1.1" {
+			t.Errorf("backend saw %s, want HTTP/1.1", got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		backend := httptest.NewServer(serverHandler(router, false))
+		defer backend.Close()
+
+		if resp, err := h2cClient.Get(backend.URL); err == nil {
+			resp.Body.Close()
+			t.Errorf("h2c GET succeeded with %s, want it refused", <-protos)
+		}
+	})
+}
@@ -35,7 +35,7 @@ func main() {
 
 		srv := &http.Server{
 			Addr:         ":" + cfg.Port,
-			Handler:      app.Router(),
+			Handler:      serverHandler(app.Router(), cfg.H2C),
 			ReadTimeout:  cfg.ReadTimeout,
 			WriteTimeout: cfg.WriteTimeout,
 			IdleTimeout:  cfg.IdleTimeout,
@@ -59,7 +59,7 @@ func main() {
 			fmt.Printf("   HTTPS: https://local-dev.jcvolpe.me:%s\n", *httpsPort)
 			fmt.Printf("   Proxying to HTTP backend on port %s\n\n", cfg.Port)
 
-			if err := StartHTTPSProxy(cfg.Port, *httpsPort); err != nil {
+			if err := StartHTTPSProxy(cfg.Port, *httpsPort, cfg.TLS); err != nil {
 				log.Fatal("Failed to start HTTPS proxy:", err)
 			}
 		}()
@@ -90,7 +90,7 @@ func main() {
 
 		srv := &http.Server{
 			Addr:         ":" + cfg.Port,
-			Handler:      app.Router(),
+			Handler:      serverHandler(app.Router(), cfg.H2C),
 			ReadTimeout:  cfg.ReadTimeout,
 			WriteTimeout: cfg.WriteTimeout,
 			IdleTimeout:  cfg.IdleTimeout,
@@ -11,8 +11,9 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/config"
-	awslib "github.com/jamesvolpe/central-analytics/backend/internal/aws"
 	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	awslib "github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 	"github.com/jamesvolpe/central-analytics/backend/pkg/response"
 )
 
@@ -22,11 +23,64 @@ type Handler struct {
 	jwtManager       *auth.JWTManager
 }
 
+// MetricsService is a metrics source served under /api/metrics/{service}
+type MetricsService string
+
+const (
+	ServiceLambda     MetricsService = "lambda"
+	ServiceAPIGateway MetricsService = "apigateway"
+	ServiceDynamoDB   MetricsService = "dynamodb"
+	ServiceAll        MetricsService = "all"
+)
+
+// validServices lists every routable service, in the order reported to callers
+var validServices = []MetricsService{ServiceLambda, ServiceAPIGateway, ServiceDynamoDB, ServiceAll}
+
+// parseService extracts the service from a request, preferring the {service} path parameter
+// and otherwise taking the segment after "metrics" so stage prefixes and trailing slashes work
+func parseService(request events.APIGatewayProxyRequest) (MetricsService, bool) {
+	name := request.PathParameters["service"]
+	if name == "" {
+		var segments []string
+		for _, segment := range strings.Split(request.Path, "/") {
+			if segment != "" {
+				segments = append(segments, segment)
+			}
+		}
+		for i := len(segments) - 2; i >= 0; i-- {
+			if segments[i] == "metrics" {
+				name = segments[i+1]
+				break
+			}
+		}
+	}
+
+	service := MetricsService(strings.ToLower(name))
+	for _, valid := range validServices {
+		if service == valid {
+			return service, true
+		}
+	}
+	return service, false
+}
+
+// unknownServiceMessage explains a 404 and lists the valid services
+func unknownServiceMessage(service MetricsService) string {
+	names := make([]string, len(validServices))
+	for i, valid := range validServices {
+		names[i] = string(valid)
+	}
+	if service == "" {
+		return fmt.Sprintf("Service required (valid: %s)", strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("Unknown service %q (valid: %s)", service, strings.Join(names, ", "))
+}
+
 type MetricsRequest struct {
-	Service   string    `json:"service"`   // lambda, apigateway, dynamodb
-	Resources []string  `json:"resources"` // Function names, API names, or table names
-	StartTime time.Time `json:"startTime"`
-	EndTime   time.Time `json:"endTime"`
+	Service   MetricsService `json:"service"`   // lambda, apigateway, dynamodb
+	Resources []string       `json:"resources"` // Function names, API names, or table names
+	StartTime time.Time      `json:"startTime"`
+	EndTime   time.Time      `json:"endTime"`
 }
 
 func NewHandler() (*Handler, error) {
@@ -48,9 +102,14 @@ func NewHandler() (*Handler, error) {
 		24*time.Hour,
 	)
 
+	// Reject tokens revoked at logout
+	if table := os.Getenv("TOKEN_REVOCATION_TABLE"); table != "" {
+		jwtManager.SetRevocationStore(awslib.NewTokenRevocationStore(cfg, table))
+	}
+
 	return &Handler{
-		cloudWatchClient: awslib.NewCloudWatchClient(cfg),
-		dynamoDBClient:   awslib.NewDynamoDBClient(cfg),
+		cloudWatchClient: awslib.NewCloudWatchClient(cfg, awslib.DefaultCloudWatchMaxAttempts),
+		dynamoDBClient:   awslib.NewDynamoDBClient(cfg, awslib.DefaultCloudWatchMaxAttempts),
 		jwtManager:       jwtManager,
 	}, nil
 }
@@ -81,25 +140,21 @@ func (h *Handler) HandleRequest(ctx context.Context, request events.APIGatewayPr
 		return response.Error(401, "Invalid or expired token"), nil
 	}
 
-	// Route based on path
-	pathParts := strings.Split(request.Path, "/")
-	if len(pathParts) < 4 {
-		return response.Error(404, "Not found"), nil
+	// Route based on path: /api/metrics/{service}
+	service, ok := parseService(request)
+	if !ok {
+		return response.Error(404, unknownServiceMessage(service)), nil
 	}
 
-	service := pathParts[3] // /api/metrics/{service}
-
 	switch service {
-	case "lambda":
+	case ServiceLambda:
 		return h.handleLambdaMetrics(ctx, request)
-	case "apigateway":
+	case ServiceAPIGateway:
 		return h.handleAPIGatewayMetrics(ctx, request)
-	case "dynamodb":
+	case ServiceDynamoDB:
 		return h.handleDynamoDBMetrics(ctx, request)
-	case "all":
-		return h.handleAllMetrics(ctx, request)
 	default:
-		return response.Error(404, "Unknown service"), nil
+		return h.handleAllMetrics(ctx, request)
 	}
 }
 
@@ -141,12 +196,9 @@ func (h *Handler) handleLambdaMetrics(ctx context.Context, request events.APIGat
 	}
 
 	return response.Success(200, map[string]interface{}{
-		"service": "lambda",
+		"service": ServiceLambda,
 		"metrics": allMetrics,
-		"period": map[string]string{
-			"start": req.StartTime.Format(time.RFC3339),
-			"end":   req.EndTime.Format(time.RFC3339),
-		},
+		"period":  timerange.NewPeriod(req.StartTime, req.EndTime),
 	}), nil
 }
 
@@ -180,12 +232,9 @@ func (h *Handler) handleAPIGatewayMetrics(ctx context.Context, request events.AP
 	}
 
 	return response.Success(200, map[string]interface{}{
-		"service": "apigateway",
+		"service": ServiceAPIGateway,
 		"metrics": allMetrics,
-		"period": map[string]string{
-			"start": req.StartTime.Format(time.RFC3339),
-			"end":   req.EndTime.Format(time.RFC3339),
-		},
+		"period":  timerange.NewPeriod(req.StartTime, req.EndTime),
 	}), nil
 }
 
@@ -219,12 +268,9 @@ func (h *Handler) handleDynamoDBMetrics(ctx context.Context, request events.APIG
 	}
 
 	return response.Success(200, map[string]interface{}{
-		"service": "dynamodb",
+		"service": ServiceDynamoDB,
 		"metrics": metrics,
-		"period": map[string]string{
-			"start": req.StartTime.Format(time.RFC3339),
-			"end":   req.EndTime.Format(time.RFC3339),
-		},
+		"period":  timerange.NewPeriod(req.StartTime, req.EndTime),
 	}), nil
 }
 
@@ -271,10 +317,7 @@ func (h *Handler) handleAllMetrics(ctx context.Context, request events.APIGatewa
 
 	return response.Success(200, map[string]interface{}{
 		"metrics": allMetrics,
-		"period": map[string]string{
-			"start": req.StartTime.Format(time.RFC3339),
-			"end":   req.EndTime.Format(time.RFC3339),
-		},
+		"period":  timerange.NewPeriod(req.StartTime, req.EndTime),
 	}), nil
 }
 
@@ -307,4 +350,4 @@ func main() {
 	}
 
 	lambda.Start(handler.HandleRequest)
-}
\ No newline at end of file
+}
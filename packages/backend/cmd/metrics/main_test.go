@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+)
+
+func TestParseService(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    events.APIGatewayProxyRequest
+		want       MetricsService
+		wantRouted bool
+	}{
+		{name: "lambda", request: events.APIGatewayProxyRequest{Path: "/api/metrics/lambda"}, want: ServiceLambda, wantRouted: true},
+		{name: "apigateway", request: events.APIGatewayProxyRequest{Path: "/api/metrics/apigateway"}, want: ServiceAPIGateway, wantRouted: true},
+		{name: "dynamodb", request: events.APIGatewayProxyRequest{Path: "/api/metrics/dynamodb"}, want: ServiceDynamoDB, wantRouted: true},
+		{name: "all", request: events.APIGatewayProxyRequest{Path: "/api/metrics/all"}, want: ServiceAll, wantRouted: true},
+		{name: "trailing slash", request: events.APIGatewayProxyRequest{Path: "/api/metrics/lambda/"}, want: ServiceLambda, wantRouted: true},
+		{name: "stage prefix", request: events.APIGatewayProxyRequest{Path: "/prod/api/metrics/DynamoDB"}, want: ServiceDynamoDB, wantRouted: true},
+		{name: "path parameter", request: events.APIGatewayProxyRequest{Path: "/ignored", PathParameters: map[string]string{"service": "apigateway"}}, want: ServiceAPIGateway, wantRouted: true},
+		{name: "unknown service", request: events.APIGatewayProxyRequest{Path: "/api/metrics/s3"}, want: "s3"},
+		{name: "no service", request: events.APIGatewayProxyRequest{Path: "/api/metrics/"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, ok := parseService(tt.request)
+			if service != tt.want || ok != tt.wantRouted {
+				t.Errorf("parseService(%q) = %q, %v, want %q, %v", tt.request.Path, service, ok, tt.want, tt.wantRouted)
+			}
+		})
+	}
+}
+
+func TestHandleRequestUnknownService(t *testing.T) {
+	manager := auth.NewJWTManager([]byte("test-secret-key-of-at-least-32-bytes"), "central-analytics", time.Hour)
+	token, err := manager.GenerateToken(&auth.AppleUserInfo{Sub: "user"})
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	h := &Handler{jwtManager: manager}
+
+	for _, path := range []string{"/api/metrics/s3", "/api/metrics"} {
+		resp, err := h.HandleRequest(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: "GET",
+			Path:       path,
+			Headers:    map[string]string{"Authorization": "Bearer " + token},
+		})
+		if err != nil {
+			t.Fatalf("HandleRequest(%s): %v", path, err)
+		}
+		if resp.StatusCode != 404 {
+			t.Errorf("HandleRequest(%s) status = %d, want 404", path, resp.StatusCode)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(resp.Body), &body); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if !strings.Contains(body.Error, "valid: lambda, apigateway, dynamodb, all") {
+			t.Errorf("404 error %q doesn't list the valid services", body.Error)
+		}
+	}
+}
@@ -38,6 +38,26 @@ variable "admin_apple_sub" {
   sensitive   = true
 }
 
+variable "admin_apple_subs" {
+  description = "Comma-separated Apple ID sub identifiers for admin users; overrides admin_apple_sub when set"
+  type        = string
+  sensitive   = true
+  default     = ""
+}
+
+variable "user_roles" {
+  description = "Comma-separated sub=role grants (viewer, billing, operator) for non-admin users"
+  type        = string
+  sensitive   = true
+  default     = ""
+}
+
+variable "apple_client_id" {
+  description = "Apple Sign In client ID (Services ID) that ID tokens must be issued for; empty accepts any"
+  type        = string
+  default     = ""
+}
+
 variable "default_app_id" {
   description = "Default App Store app ID"
   type        = string
@@ -110,7 +130,9 @@ resource "aws_iam_role_policy" "lambda_policy" {
         Effect = "Allow"
         Action = [
           "ce:GetCostAndUsage",
-          "ce:GetCostForecast"
+          "ce:GetCostForecast",
+          "ce:GetAnomalies",
+          "ce:GetAnomalyMonitors"
         ]
         Resource = "*"
       },
@@ -118,9 +140,57 @@ resource "aws_iam_role_policy" "lambda_policy" {
         Effect = "Allow"
         Action = [
           "dynamodb:DescribeTable",
-          "dynamodb:ListTables"
+          "dynamodb:ListTables",
+          "dynamodb:Scan"
+        ]
+        Resource = "*"
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "tag:GetResources"
+        ]
+        Resource = "*"
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "lambda:GetFunctionConfiguration"
         ]
         Resource = "*"
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "dynamodb:GetItem",
+          "dynamodb:PutItem",
+          "dynamodb:DeleteItem"
+        ]
+        Resource = aws_dynamodb_table.idempotency.arn
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "dynamodb:PutItem",
+          "dynamodb:Query",
+          "dynamodb:BatchWriteItem"
+        ]
+        Resource = aws_dynamodb_table.ratings_history.arn
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "dynamodb:GetItem",
+          "dynamodb:PutItem"
+        ]
+        Resource = aws_dynamodb_table.token_revocations.arn
+      },
+      {
+        Effect = "Allow"
+        Action = [
+          "dynamodb:PutItem"
+        ]
+        Resource = aws_dynamodb_table.nonces.arn
       }
     ]
   })
@@ -142,6 +212,11 @@ resource "aws_lambda_function" "auth" {
       STAGE              = var.environment
       JWT_SECRET_NAME    = aws_secretsmanager_secret.jwt_secret.name
       ADMIN_APPLE_SUB    = var.admin_apple_sub
+      ADMIN_APPLE_SUBS   = var.admin_apple_subs
+      USER_ROLES         = var.user_roles
+      APPLE_CLIENT_ID    = var.apple_client_id
+      TOKEN_REVOCATION_TABLE = aws_dynamodb_table.token_revocations.name
+      NONCE_TABLE        = aws_dynamodb_table.nonces.name
     }
   }
 
@@ -163,6 +238,7 @@ resource "aws_lambda_function" "metrics" {
     variables = {
       STAGE           = var.environment
       JWT_SECRET_NAME = aws_secretsmanager_secret.jwt_secret.name
+      TOKEN_REVOCATION_TABLE = aws_dynamodb_table.token_revocations.name
     }
   }
 
@@ -401,6 +477,83 @@ resource "aws_dynamodb_table" "sessions" {
   tags = local.tags
 }
 
+# DynamoDB table for Idempotency-Key results
+resource "aws_dynamodb_table" "idempotency" {
+  name         = "${local.prefix}-idempotency"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "idempotencyKey"
+
+  attribute {
+    name = "idempotencyKey"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB table for App Store ratings history snapshots
+resource "aws_dynamodb_table" "ratings_history" {
+  name         = "${local.prefix}-ratings-history"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "appId"
+  range_key    = "timestamp"
+
+  attribute {
+    name = "appId"
+    type = "S"
+  }
+
+  attribute {
+    name = "timestamp"
+    type = "N"
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB table of session tokens revoked at logout
+resource "aws_dynamodb_table" "token_revocations" {
+  name         = "${local.prefix}-token-revocations"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "tokenId"
+
+  attribute {
+    name = "tokenId"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  tags = local.tags
+}
+
+# DynamoDB table of consumed Apple sign-in nonces, kept until their ID tokens expire
+resource "aws_dynamodb_table" "nonces" {
+  name         = "${local.prefix}-nonces"
+  billing_mode = "PAY_PER_REQUEST"
+  hash_key     = "nonce"
+
+  attribute {
+    name = "nonce"
+    type = "S"
+  }
+
+  ttl {
+    attribute_name = "expiresAt"
+    enabled        = true
+  }
+
+  tags = local.tags
+}
+
 # S3 bucket for frontend
 resource "aws_s3_bucket" "frontend" {
   bucket = "${local.prefix}-frontend"
@@ -9,11 +9,16 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/cloudwatch v1.38.6
 	github.com/aws/aws-sdk-go-v2/service/costexplorer v1.38.5
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.32.8
+	github.com/aws/aws-sdk-go-v2/service/lambda v1.54.5
+	github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi v1.21.9
 	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.28.6
+	github.com/aws/aws-sdk-go-v2/service/sns v1.29.10
+	github.com/aws/smithy-go v1.20.2
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/gorilla/mux v1.8.1
 	github.com/lestrrat-go/jwx/v2 v2.0.21
 	github.com/rs/cors v1.11.1
+	golang.org/x/net v0.26.0
 )
 
 require (
@@ -28,7 +33,6 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/sso v1.20.11 // indirect
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.24.5 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sts v1.28.12 // indirect
-	github.com/aws/smithy-go v1.20.2 // indirect
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.3.0 // indirect
 	github.com/goccy/go-json v0.10.3 // indirect
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
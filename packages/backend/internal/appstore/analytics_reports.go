@@ -0,0 +1,329 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+// Analytics report request access types
+const (
+	// AccessTypeOneTimeSnapshot requests a single historical snapshot
+	AccessTypeOneTimeSnapshot = "ONE_TIME_SNAPSHOT"
+
+	// AccessTypeOngoing requests reports that Apple keeps generating daily
+	AccessTypeOngoing = "ONGOING"
+)
+
+// Report job statuses
+const (
+	ReportJobPending = "pending"
+	ReportJobReady   = "ready"
+)
+
+// ErrReportRequestNotFound is returned when a report request doesn't belong to the app
+var ErrReportRequestNotFound = errors.New("report request not found")
+
+// ReportSegment is a downloadable, gzipped part of a report instance. URLs are short-lived.
+type ReportSegment struct {
+	URL         string `json:"url"`
+	Checksum    string `json:"checksum"`
+	SizeInBytes int64  `json:"sizeInBytes"`
+}
+
+// ReportInstance is one generated run of a report
+type ReportInstance struct {
+	ID             string          `json:"id"`
+	Granularity    string          `json:"granularity"`
+	ProcessingDate string          `json:"processingDate"`
+	Segments       []ReportSegment `json:"segments"`
+}
+
+// AnalyticsReport is a report available under a report request, with its latest instance
+type AnalyticsReport struct {
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Category string          `json:"category"`
+	Latest   *ReportInstance `json:"latest"`
+}
+
+// ReportJob tracks an analytics report request. Apple generates reports asynchronously,
+// so a job stays pending until at least one report has an instance.
+type ReportJob struct {
+	ID         string            `json:"id"`
+	AccessType string            `json:"accessType"`
+	Status     string            `json:"status"`
+	Reports    []AnalyticsReport `json:"reports"`
+}
+
+// ReportFilter narrows which reports a job status check fetches instances for. Each report
+// costs further API calls, so callers should filter where they can.
+type ReportFilter struct {
+	Category    string // e.g. APP_STORE_ENGAGEMENT, COMMERCE, APP_USAGE
+	Name        string
+	Granularity string // DAILY, WEEKLY or MONTHLY
+}
+
+// reportRequestResource is a JSON:API analyticsReportRequests resource
+type reportRequestResource struct {
+	ID         string `json:"id"`
+	Attributes struct {
+		AccessType             string `json:"accessType"`
+		StoppedDueToInactivity bool   `json:"stoppedDueToInactivity"`
+	} `json:"attributes"`
+}
+
+// EnsureReportRequest returns an active analytics report request of accessType for the app,
+// creating one if none exists
+func (c *AppStoreConnectClient) EnsureReportRequest(ctx context.Context, appID, accessType string) (*ReportJob, error) {
+	existing, err := c.findReportRequest(ctx, appID, func(request *reportRequestResource) bool {
+		return request.Attributes.AccessType == accessType && !request.Attributes.StoppedDueToInactivity
+	})
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return &ReportJob{ID: existing.ID, AccessType: existing.Attributes.AccessType, Status: ReportJobPending}, nil
+	}
+
+	body := map[string]interface{}{
+		"data": map[string]interface{}{
+			"type": "analyticsReportRequests",
+			"attributes": map[string]interface{}{
+				"accessType": accessType,
+			},
+			"relationships": map[string]interface{}{
+				"app": map[string]interface{}{
+					"data": map[string]interface{}{
+						"type": "apps",
+						"id":   appID,
+					},
+				},
+			},
+		},
+	}
+
+	data, err := c.makeRequest(ctx, "POST", "/analyticsReportRequests", body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report request: %w", err)
+	}
+
+	var created struct {
+		Data reportRequestResource `json:"data"`
+	}
+	if err := json.Unmarshal(data, &created); err != nil {
+		return nil, fmt.Errorf("failed to parse report request: %w", err)
+	}
+
+	return &ReportJob{ID: created.Data.ID, AccessType: created.Data.Attributes.AccessType, Status: ReportJobPending}, nil
+}
+
+// findReportRequest returns the app's first report request matching match, or nil
+func (c *AppStoreConnectClient) findReportRequest(ctx context.Context, appID string, match func(*reportRequestResource) bool) (*reportRequestResource, error) {
+	endpoint := fmt.Sprintf("/apps/%s/analyticsReportRequests?limit=%d", appID, pageLimit)
+
+	var found *reportRequestResource
+	err := c.paginate(ctx, endpoint, func(data []byte) (bool, error) {
+		var page struct {
+			Data []reportRequestResource `json:"data"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return false, fmt.Errorf("failed to parse report requests: %w", err)
+		}
+		for i := range page.Data {
+			if match(&page.Data[i]) {
+				found = &page.Data[i]
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report requests: %w", err)
+	}
+	return found, nil
+}
+
+// GetReportJob returns the status of one of the app's report requests and, once ready, the
+// latest instance of each matching report with its download segments
+func (c *AppStoreConnectClient) GetReportJob(ctx context.Context, appID, requestID string, filter ReportFilter) (*ReportJob, error) {
+	request, err := c.findReportRequest(ctx, appID, func(request *reportRequestResource) bool {
+		return request.ID == requestID
+	})
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrReportRequestNotFound
+	}
+
+	reports, err := c.listReports(ctx, requestID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch each report's latest instance concurrently; the client's slot limit paces requests
+	var wg sync.WaitGroup
+	errs := make([]error, len(reports))
+	for i := range reports {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			reports[i].Latest, errs[i] = c.latestReportInstance(ctx, reports[i].ID, filter.Granularity)
+		}(i)
+	}
+	wg.Wait()
+
+	job := &ReportJob{
+		ID:         request.ID,
+		AccessType: request.Attributes.AccessType,
+		Status:     ReportJobPending,
+		Reports:    reports,
+	}
+	for i, report := range reports {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if report.Latest != nil {
+			job.Status = ReportJobReady
+		}
+	}
+
+	return job, nil
+}
+
+// listReports returns the reports under a request matching the filter's category and name
+func (c *AppStoreConnectClient) listReports(ctx context.Context, requestID string, filter ReportFilter) ([]AnalyticsReport, error) {
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(pageLimit))
+	if filter.Category != "" {
+		query.Set("filter[category]", filter.Category)
+	}
+	if filter.Name != "" {
+		query.Set("filter[name]", filter.Name)
+	}
+	endpoint := fmt.Sprintf("/analyticsReportRequests/%s/reports?%s", url.PathEscape(requestID), query.Encode())
+
+	reports := []AnalyticsReport{}
+	err := c.paginate(ctx, endpoint, func(data []byte) (bool, error) {
+		var page struct {
+			Data []struct {
+				ID         string `json:"id"`
+				Attributes struct {
+					Name     string `json:"name"`
+					Category string `json:"category"`
+				} `json:"attributes"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return false, fmt.Errorf("failed to parse reports: %w", err)
+		}
+		for _, report := range page.Data {
+			reports = append(reports, AnalyticsReport{
+				ID:       report.ID,
+				Name:     report.Attributes.Name,
+				Category: report.Attributes.Category,
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports, nil
+}
+
+// latestReportInstance returns a report's most recently processed instance with its
+// segments, or nil if Apple hasn't generated one yet
+func (c *AppStoreConnectClient) latestReportInstance(ctx context.Context, reportID, granularity string) (*ReportInstance, error) {
+	instances, err := c.reportInstances(ctx, reportID, granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	// Processing dates are YYYY-MM-DD, so they compare correctly as strings
+	var latest *ReportInstance
+	for i := range instances {
+		if latest == nil || instances[i].ProcessingDate > latest.ProcessingDate {
+			latest = &instances[i]
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	latest.Segments, err = c.reportSegments(ctx, latest.ID)
+	if err != nil {
+		return nil, err
+	}
+	return latest, nil
+}
+
+// reportInstances returns every generated instance of a report, without segments
+func (c *AppStoreConnectClient) reportInstances(ctx context.Context, reportID, granularity string) ([]ReportInstance, error) {
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(pageLimit))
+	if granularity != "" {
+		query.Set("filter[granularity]", granularity)
+	}
+	endpoint := fmt.Sprintf("/analyticsReports/%s/instances?%s", url.PathEscape(reportID), query.Encode())
+
+	var instances []ReportInstance
+	err := c.paginate(ctx, endpoint, func(data []byte) (bool, error) {
+		var page struct {
+			Data []struct {
+				ID         string `json:"id"`
+				Attributes struct {
+					Granularity    string `json:"granularity"`
+					ProcessingDate string `json:"processingDate"`
+				} `json:"attributes"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return false, fmt.Errorf("failed to parse report instances: %w", err)
+		}
+		for _, instance := range page.Data {
+			instances = append(instances, ReportInstance{
+				ID:             instance.ID,
+				Granularity:    instance.Attributes.Granularity,
+				ProcessingDate: instance.Attributes.ProcessingDate,
+			})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report instances: %w", err)
+	}
+	return instances, nil
+}
+
+// reportSegments returns the download segments of a report instance
+func (c *AppStoreConnectClient) reportSegments(ctx context.Context, instanceID string) ([]ReportSegment, error) {
+	endpoint := fmt.Sprintf("/analyticsReportInstances/%s/segments?limit=%d", url.PathEscape(instanceID), pageLimit)
+
+	segments := []ReportSegment{}
+	err := c.paginate(ctx, endpoint, func(data []byte) (bool, error) {
+		var page struct {
+			Data []struct {
+				Attributes ReportSegment `json:"attributes"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return false, fmt.Errorf("failed to parse report segments: %w", err)
+		}
+		for _, segment := range page.Data {
+			segments = append(segments, segment.Attributes)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list report segments: %w", err)
+	}
+	return segments, nil
+}
@@ -0,0 +1,160 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrVendorNumberNotSet is returned when sales data is requested before SetVendorNumber
+var ErrVendorNumberNotSet = errors.New("App Store Connect vendor number not configured")
+
+// Product Type Identifiers counted as first-time downloads and as updates. Redownloads,
+// in-app purchases and subscriptions contribute proceeds but not downloads.
+var (
+	downloadProductTypes = map[string]bool{
+		"1": true, "1F": true, "1T": true, "F1": true,
+		"1E": true, "1EP": true, "1EU": true,
+		"1-B": true, "F1-B": true,
+	}
+	updateProductTypes = map[string]bool{
+		"7": true, "7F": true, "7T": true, "F7": true,
+	}
+)
+
+// SetVendorNumber sets the vendor number sales reports are requested for. Call before the
+// client is shared.
+func (c *AppStoreConnectClient) SetVendorNumber(vendorNumber string) {
+	c.vendorNumber = vendorNumber
+}
+
+// GetAppSalesReports aggregates the daily summary sales reports from startDate through endDate
+// for one app, identified by its Apple ID and SKU so in-app purchases are included. Days
+// without a published report, such as days with no sales or the current day, are skipped.
+// Apple keeps daily reports for a year. Proceeds are converted to the payout currency when
+// one is set.
+func (c *AppStoreConnectClient) GetAppSalesReports(ctx context.Context, vendorNumber, appID, sku string, startDate, endDate time.Time) (*SalesReportSummary, error) {
+	if vendorNumber == "" {
+		return nil, ErrVendorNumberNotSet
+	}
+
+	filter := &salesRowFilter{appleID: appID, sku: sku}
+	summary := newSalesReportSummary()
+	var mu sync.Mutex
+	_, err := fetchReportDays(startDate, endDate, func(day time.Time) error {
+		report, err := c.fetchSalesReport(ctx, vendorNumber, day, filter)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		summary.merge(report)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.convertPayout(ctx, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// fetchReportDays calls fetch concurrently for each UTC day from startDate through endDate,
+// relying on the client's request cap to bound the fan-out. Days whose report isn't published
+// are skipped; it returns how many days had a report and the earliest day's other error.
+func fetchReportDays(startDate, endDate time.Time, fetch func(day time.Time) error) (int, error) {
+	var days []time.Time
+	day := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
+	for ; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	errs := make([]error, len(days))
+	var wg sync.WaitGroup
+	for i, day := range days {
+		wg.Add(1)
+		go func(i int, day time.Time) {
+			defer wg.Done()
+			errs[i] = fetch(day)
+		}(i, day)
+	}
+	wg.Wait()
+
+	found := 0
+	for i, err := range errs {
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return found, fmt.Errorf("report for %s: %w", days[i].Format("2006-01-02"), err)
+		}
+		found++
+	}
+	return found, nil
+}
+
+// applySales fills downloads, updates and revenue from an app's sales summary. Revenue is the
+// payout total when proceeds were converted, or the proceeds when they are all in one
+// currency; proceeds in several currencies without a payout currency leave it unset, with
+// the local amounts still reported in Proceeds.
+func (a *AppAnalytics) applySales(sales *SalesReportSummary) {
+	for productType, bucket := range sales.ByProductType {
+		switch {
+		case downloadProductTypes[productType]:
+			a.Downloads += bucket.Units
+		case updateProductTypes[productType]:
+			a.Updates += bucket.Units
+		}
+	}
+
+	a.Proceeds = sales.Total.Proceeds
+	if sales.Payout != nil {
+		a.Revenue = sales.Payout.Total
+		a.RevenueCurrency = sales.Payout.Currency
+		return
+	}
+	if len(sales.Total.Proceeds) == 1 {
+		for currency, proceeds := range sales.Total.Proceeds {
+			a.Revenue = proceeds
+			a.RevenueCurrency = currency
+		}
+	}
+}
+
+// newSalesReportSummary creates an empty summary
+func newSalesReportSummary() *SalesReportSummary {
+	return &SalesReportSummary{
+		Total:         newSalesBucket(),
+		ByCountry:     make(map[string]*SalesBucket),
+		BySKU:         make(map[string]*SalesBucket),
+		ByProductType: make(map[string]*SalesBucket),
+	}
+}
+
+// merge adds other's rows and buckets into the summary
+func (s *SalesReportSummary) merge(other *SalesReportSummary) {
+	s.Rows += other.Rows
+	s.Total.merge(other.Total)
+	mergeBuckets(s.ByCountry, other.ByCountry)
+	mergeBuckets(s.BySKU, other.BySKU)
+	mergeBuckets(s.ByProductType, other.ByProductType)
+}
+
+// merge adds other's units and proceeds into the bucket
+func (b *SalesBucket) merge(other *SalesBucket) {
+	b.Units += other.Units
+	for currency, proceeds := range other.Proceeds {
+		b.Proceeds[currency] += proceeds
+	}
+}
+
+// mergeBuckets adds each bucket in from to the matching bucket in into
+func mergeBuckets(into, from map[string]*SalesBucket) {
+	for key, bucket := range from {
+		bucketFor(into, key).merge(bucket)
+	}
+}
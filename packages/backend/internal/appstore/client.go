@@ -0,0 +1,19 @@
+package appstore
+
+import (
+	"context"
+	"time"
+)
+
+// Client is the App Store data source used by the analytics handlers. AppStoreConnectClient
+// implements it against Apple's API; MockClient serves synthetic data for local development.
+type Client interface {
+	GetAppAnalytics(ctx context.Context, appID string, startDate, endDate time.Time) (*AppAnalytics, error)
+	GetAppRatings(ctx context.Context, appID string) (*RatingsData, error)
+	GetCrashMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*CrashMetrics, error)
+	GetSubscriptionMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*SubscriptionMetrics, error)
+	GetCustomerReviews(ctx context.Context, appID string, filter ReviewFilter) (*ReviewPage, error)
+	EnsureReportRequest(ctx context.Context, appID, accessType string) (*ReportJob, error)
+	GetReportJob(ctx context.Context, appID, requestID string, filter ReportFilter) (*ReportJob, error)
+	RateLimitStatus() RateLimitStatus
+}
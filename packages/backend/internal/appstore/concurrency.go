@@ -0,0 +1,49 @@
+package appstore
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// DefaultMaxConcurrentRequests is the default cap on in-flight App Store Connect requests
+const DefaultMaxConcurrentRequests = 4
+
+// SetMaxConcurrentRequests caps how many requests the client has in flight at once.
+// Values below 1 use DefaultMaxConcurrentRequests. Call before the client is shared.
+func (c *AppStoreConnectClient) SetMaxConcurrentRequests(n int) {
+	if n < 1 {
+		n = DefaultMaxConcurrentRequests
+	}
+	c.inflight = make(chan struct{}, n)
+}
+
+// acquireSlot blocks until an in-flight slot is free or ctx is done
+func (c *AppStoreConnectClient) acquireSlot(ctx context.Context) error {
+	select {
+	case c.inflight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// releaseSlot frees an in-flight slot
+func (c *AppStoreConnectClient) releaseSlot() {
+	<-c.inflight
+}
+
+// slotBody releases the request's in-flight slot once the response body is closed,
+// so streamed downloads count against the cap until they finish
+type slotBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+// Close closes the body and releases the slot
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
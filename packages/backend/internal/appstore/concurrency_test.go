@@ -0,0 +1,134 @@
+package appstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testKeyPEM generates a P-256 private key in the PKCS #8 PEM form Apple issues
+func testKeyPEM(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("encode key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// newTestClient creates a client signing with a fresh key whose requests go to transport
+func newTestClient(t *testing.T, transport http.RoundTripper) *AppStoreConnectClient {
+	t.Helper()
+	return newTestClientWithKey(t, transport, testKeyPEM(t))
+}
+
+// newTestClientWithKey creates a client signing with keyPEM whose requests go to transport
+func newTestClientWithKey(t *testing.T, transport http.RoundTripper, keyPEM []byte) *AppStoreConnectClient {
+	t.Helper()
+
+	client, err := NewAppStoreConnectClient("KEY123", "issuer", keyPEM)
+	if err != nil {
+		t.Fatalf("NewAppStoreConnectClient: %v", err)
+	}
+	client.httpClient = &http.Client{Transport: transport}
+	return client
+}
+
+// concurrencyTransport answers every request after a delay, tracking how many are in flight
+type concurrencyTransport struct {
+	delay    time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (tr *concurrencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	current := tr.inFlight.Add(1)
+	defer tr.inFlight.Add(-1)
+	for {
+		peak := tr.peak.Load()
+		if current <= peak || tr.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+
+	select {
+	case <-time.After(tr.delay):
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"data":[]}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	const limit = 3
+	transport := &concurrencyTransport{delay: 20 * time.Millisecond}
+	client := newTestClient(t, transport)
+	client.SetMaxConcurrentRequests(limit)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5*limit)
+	for i := 0; i < 5*limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.makeRequest(context.Background(), http.MethodGet, "/apps", nil); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("makeRequest: %v", err)
+	}
+	if peak := transport.peak.Load(); peak > limit {
+		t.Errorf("%d requests were in flight at once, want at most %d", peak, limit)
+	} else if peak < limit {
+		t.Errorf("at most %d requests were in flight at once; the cap of %d was never reached", peak, limit)
+	}
+}
+
+func TestStreamedBodyHoldsSlotUntilClosed(t *testing.T) {
+	transport := &concurrencyTransport{}
+	client := newTestClient(t, transport)
+	client.SetMaxConcurrentRequests(1)
+
+	resp, err := client.openRequest(context.Background(), http.MethodGet, "/salesReports", nil, "application/a-gzip")
+	if err != nil {
+		t.Fatalf("openRequest: %v", err)
+	}
+
+	// The only slot is held by the unread body, so another request waits until it's closed
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.makeRequest(ctx, http.MethodGet, "/apps", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("request while the slot was held: err = %v, want context.DeadlineExceeded", err)
+	}
+
+	resp.Body.Close()
+	if _, err := client.makeRequest(context.Background(), http.MethodGet, "/apps", nil); err != nil {
+		t.Errorf("request after the body was closed: %v", err)
+	}
+}
@@ -3,30 +3,64 @@ package appstore
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 )
 
 const (
 	appStoreConnectBaseURL = "https://api.appstoreconnect.apple.com/v1"
-	tokenTTL               = 20 * time.Minute // Apple recommends 20 minutes max
+	tokenTTL               = 20 * time.Minute // Apple rejects tokens valid for longer
+	tokenRefreshMargin     = time.Minute      // Tokens this close to expiry are replaced
 )
 
+// ErrClientNotInitialized is returned by requests on a client that wasn't built by
+// NewAppStoreConnectClient with a usable signing key
+var ErrClientNotInitialized = errors.New("App Store client not properly initialized")
+
+// ErrNotFound is returned when App Store Connect responds 404, e.g. for a sales report date
+// with no sales or that hasn't been published yet
+var ErrNotFound = errors.New("App Store Connect resource not found")
+
 // AppStoreConnectClient handles App Store Connect API interactions
 type AppStoreConnectClient struct {
 	keyID      string
 	issuerID   string
 	privateKey interface{}
 	httpClient *http.Client
-	token      string
-	tokenExp   time.Time
+
+	// Timeout for requests whose context has no deadline; zero uses DefaultRequestTimeout
+	requestTimeout time.Duration
+
+	// Key the client's signed tokens are shared under; see bearerToken
+	tokenKey string
+
+	rateMu    sync.Mutex
+	rateLimit RateLimitStatus
+
+	// Semaphore limiting concurrent in-flight requests
+	inflight chan struct{}
+
+	// Currency sales report proceeds are converted to, and the rates used; unset disables it
+	payoutCurrency string
+	exchangeRates  ExchangeRateSource
+
+	// Vendor number sales reports are requested for; unset leaves sales unavailable
+	vendorNumber string
+
+	// Cap on pages followed per paginated request; zero uses DefaultMaxPages
+	maxPages int
 }
 
 // NewAppStoreConnectClient creates a new App Store Connect API client
@@ -41,24 +75,59 @@ func NewAppStoreConnectClient(keyID, issuerID string, privateKeyPEM []byte) (*Ap
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
+	// Apple issues P-256 keys and only accepts ES256 tokens
+	ecdsaKey, ok := privateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is %T, not an ECDSA key", privateKey)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&ecdsaKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	fingerprint := sha256.Sum256(publicKey)
 
 	return &AppStoreConnectClient{
 		keyID:      keyID,
 		issuerID:   issuerID,
 		privateKey: privateKey,
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		tokenKey:   fmt.Sprintf("%s/%s/%x", issuerID, keyID, fingerprint),
+		// No client timeout: the request context, bounded by requestContext, governs each request
+		httpClient: &http.Client{},
+		inflight:   make(chan struct{}, DefaultMaxConcurrentRequests),
 	}, nil
 }
 
-// generateToken creates a new JWT token for App Store Connect API
-func (c *AppStoreConnectClient) generateToken() error {
-	now := time.Now()
+// ready reports ErrClientNotInitialized for a nil or zero-value client, which would otherwise
+// panic or fail signing with a confusing error
+func (c *AppStoreConnectClient) ready() error {
+	if c == nil || c.privateKey == nil || c.httpClient == nil || c.inflight == nil {
+		return ErrClientNotInitialized
+	}
+	return nil
+}
+
+// signedToken is a signed App Store Connect JWT and when it expires
+type signedToken struct {
+	value   string
+	expires time.Time
+}
+
+// tokenCache shares signed tokens between clients in the process that use the same issuer and
+// key, so clients created per handler or per invocation don't each sign their own
+var tokenCache = struct {
+	mu     sync.Mutex
+	tokens map[string]signedToken
+}{tokens: make(map[string]signedToken)}
 
-	// Check if existing token is still valid
-	if c.token != "" && c.tokenExp.After(now.Add(1*time.Minute)) {
-		return nil
+// bearerToken returns a cached token with more than tokenRefreshMargin left, signing a new one
+// otherwise. Concurrent requests wait for a single signing rather than racing to replace it.
+func (c *AppStoreConnectClient) bearerToken() (string, error) {
+	tokenCache.mu.Lock()
+	defer tokenCache.mu.Unlock()
+
+	now := time.Now()
+	if cached, ok := tokenCache.tokens[c.tokenKey]; ok && cached.expires.After(now.Add(tokenRefreshMargin)) {
+		return cached.value, nil
 	}
 
 	claims := jwt.MapClaims{
@@ -73,23 +142,15 @@ func (c *AppStoreConnectClient) generateToken() error {
 
 	tokenString, err := token.SignedString(c.privateKey)
 	if err != nil {
-		return fmt.Errorf("failed to sign token: %w", err)
+		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	c.token = tokenString
-	c.tokenExp = now.Add(tokenTTL)
-	return nil
+	tokenCache.tokens[c.tokenKey] = signedToken{value: tokenString, expires: now.Add(tokenTTL)}
+	return tokenString, nil
 }
 
 // makeRequest performs an authenticated request to the App Store Connect API
 func (c *AppStoreConnectClient) makeRequest(ctx context.Context, method, endpoint string, body interface{}) ([]byte, error) {
-	// Ensure we have a valid token
-	if err := c.generateToken(); err != nil {
-		return nil, fmt.Errorf("failed to generate token: %w", err)
-	}
-
-	url := appStoreConnectBaseURL + endpoint
-
 	var reqBody io.Reader
 	if body != nil {
 		jsonBody, err := json.Marshal(body)
@@ -99,53 +160,115 @@ func (c *AppStoreConnectClient) makeRequest(ctx context.Context, method, endpoin
 		reqBody = bytes.NewBuffer(jsonBody)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	resp, err := c.openRequest(ctx, method, endpoint, reqBody, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return respBody, nil
+}
+
+// openRequest performs an authenticated request and returns the successful response for the
+// caller to read, so large downloads can be streamed. The caller must close the body.
+func (c *AppStoreConnectClient) openRequest(ctx context.Context, method, endpoint string, reqBody io.Reader, accept string) (*http.Response, error) {
+	if err := c.ready(); err != nil {
+		return nil, err
+	}
+
+	// Ensure we have a valid token
+	token, err := c.bearerToken()
 	if err != nil {
+		return nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	// Back off before hitting Apple's hourly quota
+	if err := c.waitForQuota(ctx); err != nil {
+		return nil, fmt.Errorf("request cancelled while waiting for rate limit: %w", err)
+	}
+
+	// Smooth bursts from fan-out and pagination by capping in-flight requests
+	if err := c.acquireSlot(ctx); err != nil {
+		return nil, fmt.Errorf("request cancelled while waiting for a connection slot: %w", err)
+	}
+
+	url := appStoreConnectBaseURL + endpoint
+
+	// The timeout outlives this call, covering streamed reads until the body is closed
+	reqCtx, cancel := c.requestContext(ctx)
+	release := func() {
+		c.releaseSlot()
+		cancel()
+	}
+
+	req, err := http.NewRequestWithContext(reqCtx, method, url, reqBody)
+	if err != nil {
+		release()
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Authorization", "Bearer "+token)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		release()
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
-	defer resp.Body.Close()
+	resp.Body = &slotBody{ReadCloser: resp.Body, release: release}
 
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
+	c.recordRateLimit(resp.Header.Get(rateLimitHeader), resp.StatusCode)
 
 	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &RateLimitError{
+				RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+				Body:       string(respBody),
+			}
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, string(respBody))
+		}
 		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(respBody))
 	}
 
-	return respBody, nil
+	return resp, nil
 }
 
 // AppAnalytics represents app analytics data
 type AppAnalytics struct {
-	AppID          string                 `json:"appId"`
-	AppName        string                 `json:"appName"`
-	Downloads      int64                  `json:"downloads"`
-	Updates        int64                  `json:"updates"`
-	Revenue        float64                `json:"revenue"`
-	ActiveDevices  int64                  `json:"activeDevices"`
-	Crashes        int64                  `json:"crashes"`
-	Ratings        RatingsData            `json:"ratings"`
-	Period         string                 `json:"period"`
+	AppID           string             `json:"appId"`
+	AppName         string             `json:"appName"`
+	Downloads       int64              `json:"downloads"`
+	Updates         int64              `json:"updates"`
+	Revenue         float64            `json:"revenue"`
+	RevenueCurrency string             `json:"revenueCurrency,omitempty"`
+	Proceeds        map[string]float64 `json:"proceeds,omitempty"` // Local-currency proceeds by currency
+	ActiveDevices   int64              `json:"activeDevices"`
+	Crashes         int64              `json:"crashes"`
+	Ratings         RatingsData        `json:"ratings"`
+	Period          timerange.Period   `json:"period"`
 }
 
 // RatingsData represents app ratings information
 type RatingsData struct {
-	AverageRating float64 `json:"averageRating"`
-	TotalRatings  int64   `json:"totalRatings"`
+	AverageRating float64       `json:"averageRating"`
+	TotalRatings  int64         `json:"totalRatings"`
 	Distribution  map[int]int64 `json:"distribution"` // 1-5 star distribution
 }
 
-// GetAppAnalytics retrieves analytics for an app
+// GetAppAnalytics retrieves analytics for an app. Downloads, updates and revenue come from the
+// daily sales reports for the vendor number set with SetVendorNumber, and crashes from the App
+// Crashes analytics report.
 func (c *AppStoreConnectClient) GetAppAnalytics(ctx context.Context, appID string, startDate, endDate time.Time) (*AppAnalytics, error) {
 	// Get app information
 	appData, err := c.makeRequest(ctx, "GET", fmt.Sprintf("/apps/%s", appID), nil)
@@ -157,6 +280,7 @@ func (c *AppStoreConnectClient) GetAppAnalytics(ctx context.Context, appID strin
 		Data struct {
 			Attributes struct {
 				Name string `json:"name"`
+				SKU  string `json:"sku"`
 			} `json:"attributes"`
 		} `json:"data"`
 	}
@@ -167,34 +291,22 @@ func (c *AppStoreConnectClient) GetAppAnalytics(ctx context.Context, appID strin
 	analytics := &AppAnalytics{
 		AppID:   appID,
 		AppName: appInfo.Data.Attributes.Name,
-		Period:  fmt.Sprintf("%s to %s", startDate.Format("2006-01-02"), endDate.Format("2006-01-02")),
-	}
-
-	// Get analytics reports
-	// Note: This is a simplified example. The actual App Store Connect API
-	// requires more complex query parameters and response parsing
-	metricsEndpoint := fmt.Sprintf("/analyticsReportRequests")
-	reportRequest := map[string]interface{}{
-		"data": map[string]interface{}{
-			"type": "analyticsReportRequests",
-			"attributes": map[string]interface{}{
-				"accessType": "ONGOING",
-			},
-			"relationships": map[string]interface{}{
-				"app": map[string]interface{}{
-					"data": map[string]interface{}{
-						"type": "apps",
-						"id":   appID,
-					},
-				},
-			},
-		},
-	}
-
-	_, err = c.makeRequest(ctx, "POST", metricsEndpoint, reportRequest)
+		Period:  timerange.NewDatePeriod(startDate, endDate),
+	}
+
+	sales, err := c.GetAppSalesReports(ctx, c.vendorNumber, appID, appInfo.Data.Attributes.SKU, startDate, endDate)
 	if err != nil {
-		// Log error but continue with available data
-		fmt.Printf("Failed to get analytics report: %v\n", err)
+		return nil, err
+	}
+	analytics.applySales(sales)
+
+	// Crashes stay zero until the app's ongoing analytics reports include App Crashes
+	crashes, err := c.GetCrashMetrics(ctx, appID, startDate, endDate)
+	if err != nil && !errors.Is(err, ErrCrashReportUnavailable) {
+		return nil, err
+	}
+	if crashes != nil {
+		analytics.Crashes = crashes.Total
 	}
 
 	// Get customer reviews for ratings
@@ -206,46 +318,51 @@ func (c *AppStoreConnectClient) GetAppAnalytics(ctx context.Context, appID strin
 	return analytics, nil
 }
 
-// GetAppRatings retrieves ratings data for an app
+// GetAppRatings retrieves ratings data for an app across every page of customer reviews
 func (c *AppStoreConnectClient) GetAppRatings(ctx context.Context, appID string) (*RatingsData, error) {
-	endpoint := fmt.Sprintf("/apps/%s/customerReviews", appID)
-	data, err := c.makeRequest(ctx, "GET", endpoint, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get ratings: %w", err)
-	}
-
-	var reviewsResponse struct {
-		Data []struct {
-			Attributes struct {
-				Rating int `json:"rating"`
-			} `json:"attributes"`
-		} `json:"data"`
-		Meta struct {
-			Paging struct {
-				Total int64 `json:"total"`
-			} `json:"paging"`
-		} `json:"meta"`
-	}
-
-	if err := json.Unmarshal(data, &reviewsResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse reviews: %w", err)
-	}
+	endpoint := fmt.Sprintf("/apps/%s/customerReviews?limit=%d", appID, pageLimit)
 
 	ratings := &RatingsData{
-		TotalRatings: reviewsResponse.Meta.Paging.Total,
 		Distribution: make(map[int]int64),
 	}
 
 	// Calculate distribution and average
-	var totalScore int64
-	for _, review := range reviewsResponse.Data {
-		rating := review.Attributes.Rating
-		ratings.Distribution[rating]++
-		totalScore += int64(rating)
+	var totalScore, reviewCount int64
+	err := c.paginate(ctx, endpoint, func(data []byte) (bool, error) {
+		var reviewsResponse struct {
+			Data []struct {
+				Attributes struct {
+					Rating int `json:"rating"`
+				} `json:"attributes"`
+			} `json:"data"`
+			Meta struct {
+				Paging struct {
+					Total int64 `json:"total"`
+				} `json:"paging"`
+			} `json:"meta"`
+		}
+
+		if err := json.Unmarshal(data, &reviewsResponse); err != nil {
+			return false, fmt.Errorf("failed to parse reviews: %w", err)
+		}
+
+		if reviewsResponse.Meta.Paging.Total > ratings.TotalRatings {
+			ratings.TotalRatings = reviewsResponse.Meta.Paging.Total
+		}
+		for _, review := range reviewsResponse.Data {
+			rating := review.Attributes.Rating
+			ratings.Distribution[rating]++
+			totalScore += int64(rating)
+			reviewCount++
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ratings: %w", err)
 	}
 
-	if len(reviewsResponse.Data) > 0 {
-		ratings.AverageRating = float64(totalScore) / float64(len(reviewsResponse.Data))
+	if reviewCount > 0 {
+		ratings.AverageRating = float64(totalScore) / float64(reviewCount)
 	}
 
 	return ratings, nil
@@ -253,11 +370,11 @@ func (c *AppStoreConnectClient) GetAppRatings(ctx context.Context, appID string)
 
 // BuildInfo represents information about an app build
 type BuildInfo struct {
-	Version       string    `json:"version"`
-	BuildNumber   string    `json:"buildNumber"`
-	UploadedDate  time.Time `json:"uploadedDate"`
-	ProcessingState string  `json:"processingState"`
-	Platform      string    `json:"platform"`
+	Version         string    `json:"version"`
+	BuildNumber     string    `json:"buildNumber"`
+	UploadedDate    time.Time `json:"uploadedDate"`
+	ProcessingState string    `json:"processingState"`
+	Platform        string    `json:"platform"`
 }
 
 // GetLatestBuild retrieves information about the latest build
@@ -300,57 +417,30 @@ func (c *AppStoreConnectClient) GetLatestBuild(ctx context.Context, appID string
 
 // TestFlightInfo represents TestFlight beta testing information
 type TestFlightInfo struct {
-	BetaTesters     int64     `json:"betaTesters"`
-	BetaGroups      int64     `json:"betaGroups"`
-	InstallCount    int64     `json:"installCount"`
-	CrashCount      int64     `json:"crashCount"`
-	FeedbackCount   int64     `json:"feedbackCount"`
-	LastUpdated     time.Time `json:"lastUpdated"`
+	BetaTesters   int64     `json:"betaTesters"`
+	BetaGroups    int64     `json:"betaGroups"`
+	InstallCount  int64     `json:"installCount"`
+	CrashCount    int64     `json:"crashCount"`
+	FeedbackCount int64     `json:"feedbackCount"`
+	LastUpdated   time.Time `json:"lastUpdated"`
 }
 
 // GetTestFlightInfo retrieves TestFlight beta testing information
 func (c *AppStoreConnectClient) GetTestFlightInfo(ctx context.Context, appID string) (*TestFlightInfo, error) {
-	// Get beta testers count
-	testersEndpoint := fmt.Sprintf("/apps/%s/betaTesters", appID)
-	testersData, err := c.makeRequest(ctx, "GET", testersEndpoint, nil)
+	// Count testers and groups across every page rather than trusting a single page
+	testers, err := c.makeRequestPaged(ctx, "GET", fmt.Sprintf("/apps/%s/betaTesters?limit=%d", appID, pageLimit))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get beta testers: %w", err)
 	}
 
-	var testersResponse struct {
-		Meta struct {
-			Paging struct {
-				Total int64 `json:"total"`
-			} `json:"paging"`
-		} `json:"meta"`
-	}
-
-	if err := json.Unmarshal(testersData, &testersResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse testers response: %w", err)
-	}
-
-	// Get beta groups count
-	groupsEndpoint := fmt.Sprintf("/apps/%s/betaGroups", appID)
-	groupsData, err := c.makeRequest(ctx, "GET", groupsEndpoint, nil)
+	groups, err := c.makeRequestPaged(ctx, "GET", fmt.Sprintf("/apps/%s/betaGroups?limit=%d", appID, pageLimit))
 	if err != nil {
 		return nil, fmt.Errorf("failed to get beta groups: %w", err)
 	}
 
-	var groupsResponse struct {
-		Meta struct {
-			Paging struct {
-				Total int64 `json:"total"`
-			} `json:"paging"`
-		} `json:"meta"`
-	}
-
-	if err := json.Unmarshal(groupsData, &groupsResponse); err != nil {
-		return nil, fmt.Errorf("failed to parse groups response: %w", err)
-	}
-
 	return &TestFlightInfo{
-		BetaTesters: testersResponse.Meta.Paging.Total,
-		BetaGroups:  groupsResponse.Meta.Paging.Total,
+		BetaTesters: int64(len(testers)),
+		BetaGroups:  int64(len(groups)),
 		LastUpdated: time.Now(),
 	}, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,161 @@
+package appstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenTransport records the bearer token of every request it answers
+type tokenTransport struct {
+	mu     sync.Mutex
+	tokens []string
+}
+
+func (tr *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.mu.Lock()
+	tr.tokens = append(tr.tokens, strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "))
+	tr.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(`{"data":[]}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestBearerTokenSharedAcrossConcurrentRequests(t *testing.T) {
+	transport := &tokenTransport{}
+	client := newTestClient(t, transport)
+	client.SetMaxConcurrentRequests(50)
+	publicKey := &client.privateKey.(*ecdsa.PrivateKey).PublicKey
+
+	const requests = 50
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.makeRequest(context.Background(), http.MethodGet, "/apps", nil); err != nil {
+				t.Errorf("makeRequest: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(transport.tokens) != requests {
+		t.Fatalf("transport saw %d requests, want %d", len(transport.tokens), requests)
+	}
+	for _, token := range transport.tokens {
+		if token != transport.tokens[0] {
+			t.Fatal("concurrent requests signed different tokens, want one shared token")
+		}
+	}
+
+	claims := jwt.MapClaims{}
+	parsed, err := jwt.ParseWithClaims(transport.tokens[0], claims, func(token *jwt.Token) (interface{}, error) {
+		return publicKey, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}), jwt.WithAudience("appstoreconnect-v1"), jwt.WithIssuer("issuer"))
+	if err != nil {
+		t.Fatalf("token doesn't verify: %v", err)
+	}
+	if kid := parsed.Header["kid"]; kid != "KEY123" {
+		t.Errorf("kid = %v, want KEY123", kid)
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		t.Fatalf("token has no expiry: %v", err)
+	}
+	if lifetime := time.Until(expiresAt.Time); lifetime > tokenTTL {
+		t.Errorf("token is valid for %s, longer than Apple's %s cap", lifetime, tokenTTL)
+	}
+}
+
+func TestBearerTokenSharedAcrossClients(t *testing.T) {
+	transport := &tokenTransport{}
+	keyPEM := testKeyPEM(t)
+	first := newTestClientWithKey(t, transport, keyPEM)
+
+	// A second client for the same key, as a new Lambda invocation would create
+	second := newTestClientWithKey(t, transport, keyPEM)
+	if _, err := first.makeRequest(context.Background(), http.MethodGet, "/apps", nil); err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	if _, err := second.makeRequest(context.Background(), http.MethodGet, "/apps", nil); err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+
+	if transport.tokens[0] != transport.tokens[1] {
+		t.Error("clients sharing a key signed separate tokens")
+	}
+}
+
+func TestNewAppStoreConnectClientRejectsInvalidKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate RSA key: %v", err)
+	}
+	rsaDER, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatalf("encode RSA key: %v", err)
+	}
+
+	keys := map[string][]byte{
+		"empty":       nil,
+		"not PEM":     []byte("not a key"),
+		"corrupt DER": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("garbage")}),
+		"RSA key":     pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: rsaDER}),
+	}
+	for name, key := range keys {
+		if client, err := NewAppStoreConnectClient("KEY123", "issuer", key); err == nil || client != nil {
+			t.Errorf("%s: NewAppStoreConnectClient = %v, %v, want an error and no client", name, client, err)
+		}
+	}
+}
+
+func TestUninitializedClientReturnsClearError(t *testing.T) {
+	// A constructor that logged a key error and carried on leaves a nil or zero-value client
+	_, err := NewAppStoreConnectClient("KEY123", "issuer", []byte("not a key"))
+	if err == nil {
+		t.Fatal("NewAppStoreConnectClient accepted an invalid key")
+	}
+
+	ctx := context.Background()
+	start, end := time.Now().AddDate(0, 0, -7), time.Now()
+	clients := map[string]*AppStoreConnectClient{"nil": nil, "zero value": {}}
+	for name, client := range clients {
+		calls := map[string]func() error{
+			"GetAppAnalytics": func() error { _, err := client.GetAppAnalytics(ctx, "123", start, end); return err },
+			"GetAppRatings":   func() error { _, err := client.GetAppRatings(ctx, "123"); return err },
+			"GetCustomerReviews": func() error {
+				_, err := client.GetCustomerReviews(ctx, "123", ReviewFilter{})
+				return err
+			},
+			"GetLatestBuild":      func() error { _, err := client.GetLatestBuild(ctx, "123"); return err },
+			"GetTestFlightInfo":   func() error { _, err := client.GetTestFlightInfo(ctx, "123"); return err },
+			"EnsureReportRequest": func() error { _, err := client.EnsureReportRequest(ctx, "123", "ONGOING"); return err },
+		}
+		for method, call := range calls {
+			if err := call(); !errors.Is(err, ErrClientNotInitialized) {
+				t.Errorf("%s client %s: err = %v, want ErrClientNotInitialized", name, method, err)
+			}
+		}
+		if status := client.RateLimitStatus(); status != (RateLimitStatus{}) {
+			t.Errorf("%s client RateLimitStatus = %+v, want the zero status", name, status)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// ErrCrashReportUnavailable is returned when the app has no ongoing analytics report request
+// producing the App Crashes report. Create one with EnsureReportRequest; Apple takes a day or
+// two to generate the first instances.
+var ErrCrashReportUnavailable = errors.New("App Crashes analytics report not available; request ongoing analytics reports first")
+
+// crashReportName and crashReportCategory identify Apple's daily crash count report
+const (
+	crashReportName     = "App Crashes"
+	crashReportCategory = "APP_USAGE"
+)
+
+// crashReportProcessingLag is how long after a day its report instance may be processed, so
+// instances processed just after the requested range are still read
+const crashReportProcessingLag = 3 * 24 * time.Hour
+
+// App Crashes report column headers used for aggregation
+const (
+	crashColumnDate    = "Date"
+	crashColumnAppleID = "App Apple Identifier"
+	crashColumnCrashes = "Crashes"
+)
+
+// DailyCrashes is the crash count Apple reported for one day
+type DailyCrashes struct {
+	Date    string `json:"date"`
+	Crashes int64  `json:"crashes"`
+}
+
+// CrashMetrics holds an app's crash counts from the App Crashes analytics report
+type CrashMetrics struct {
+	AppID  string           `json:"appId"`
+	Total  int64            `json:"total"`
+	Daily  []DailyCrashes   `json:"daily"` // Days with reported crashes, oldest first
+	Period timerange.Period `json:"period"`
+}
+
+// GetCrashMetrics sums the crashes in the daily App Crashes reports of the app's ongoing
+// analytics report request for each day from startDate through endDate
+func (c *AppStoreConnectClient) GetCrashMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*CrashMetrics, error) {
+	request, err := c.findReportRequest(ctx, appID, func(request *reportRequestResource) bool {
+		return request.Attributes.AccessType == AccessTypeOngoing && !request.Attributes.StoppedDueToInactivity
+	})
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, ErrCrashReportUnavailable
+	}
+
+	reports, err := c.listReports(ctx, request.ID, ReportFilter{Category: crashReportCategory, Name: crashReportName})
+	if err != nil {
+		return nil, err
+	}
+	if len(reports) == 0 {
+		return nil, ErrCrashReportUnavailable
+	}
+
+	instances, err := c.reportInstances(ctx, reports[0].ID, "DAILY")
+	if err != nil {
+		return nil, err
+	}
+
+	first := startDate.UTC().Format("2006-01-02")
+	last := endDate.UTC().Format("2006-01-02")
+	lastProcessed := endDate.UTC().Add(crashReportProcessingLag).Format("2006-01-02")
+
+	// Read the instances that can hold rows for the range concurrently
+	var mu sync.Mutex
+	byDate := make(map[string]int64)
+	var wg sync.WaitGroup
+	var errs []error
+	for _, instance := range instances {
+		if instance.ProcessingDate < first || instance.ProcessingDate > lastProcessed {
+			continue
+		}
+		wg.Add(1)
+		go func(instanceID string) {
+			defer wg.Done()
+			counts, err := c.instanceCrashes(ctx, instanceID, appID, first, last)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for date, crashes := range counts {
+				byDate[date] += crashes
+			}
+		}(instance.ID)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	metrics := &CrashMetrics{
+		AppID:  appID,
+		Daily:  []DailyCrashes{},
+		Period: timerange.NewDatePeriod(startDate, endDate),
+	}
+	for date, crashes := range byDate {
+		metrics.Total += crashes
+		metrics.Daily = append(metrics.Daily, DailyCrashes{Date: date, Crashes: crashes})
+	}
+	sort.Slice(metrics.Daily, func(i, j int) bool { return metrics.Daily[i].Date < metrics.Daily[j].Date })
+	return metrics, nil
+}
+
+// instanceCrashes reads every segment of a report instance and sums the app's crashes per day
+// for days from first through last
+func (c *AppStoreConnectClient) instanceCrashes(ctx context.Context, instanceID, appID, first, last string) (map[string]int64, error) {
+	segments, err := c.reportSegments(ctx, instanceID)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64)
+	for _, segment := range segments {
+		if err := c.readCrashSegment(ctx, segment.URL, appID, first, last, counts); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+// readCrashSegment downloads one gzipped report segment and adds the app's crashes per day
+// to counts
+func (c *AppStoreConnectClient) readCrashSegment(ctx context.Context, segmentURL, appID, first, last string, counts map[string]int64) error {
+	body, err := c.downloadSegment(ctx, segmentURL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	report, err := openTSVReport(body, "crash")
+	if err != nil {
+		return err
+	}
+	defer report.Close()
+
+	crashesIdx, ok := report.columns[crashColumnCrashes]
+	if !ok {
+		return fmt.Errorf("crash report is missing the %q column", crashColumnCrashes)
+	}
+	dateIdx := columnIndex(report.columns, crashColumnDate)
+	appleIDIdx := columnIndex(report.columns, crashColumnAppleID)
+
+	for {
+		record, err := report.next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if appleID := field(record, appleIDIdx); appleID != "" && appleID != appID {
+			continue
+		}
+		date := field(record, dateIdx)
+		if date < first || date > last {
+			continue
+		}
+		counts[date] += countField(record, crashesIdx)
+	}
+}
+
+// downloadSegment fetches a report segment from its pre-signed URL. The URL carries its own
+// authorization, so the API bearer token isn't sent.
+func (c *AppStoreConnectClient) downloadSegment(ctx context.Context, segmentURL string) (io.ReadCloser, error) {
+	parsed, err := url.Parse(segmentURL)
+	if err != nil || parsed.Scheme != "https" {
+		return nil, fmt.Errorf("unexpected report segment URL %q", segmentURL)
+	}
+
+	if err := c.acquireSlot(ctx); err != nil {
+		return nil, fmt.Errorf("request cancelled while waiting for a connection slot: %w", err)
+	}
+
+	reqCtx, cancel := c.requestContext(ctx)
+	release := func() {
+		c.releaseSlot()
+		cancel()
+	}
+
+	req, err := http.NewRequestWithContext(reqCtx, "GET", segmentURL, nil)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to create segment request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("failed to download report segment: %w", err)
+	}
+	resp.Body = &slotBody{ReadCloser: resp.Body, release: release}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("failed to download report segment (status %d)", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,244 @@
+package appstore
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// mockReviewCount is how many synthetic reviews MockClient serves per app
+const mockReviewCount = 40
+
+// mockRatings is the cycle synthetic review ratings are drawn from, skewed positive
+var mockRatings = []int{5, 5, 4, 5, 3, 5, 4, 1, 5, 2}
+
+// mockTerritories are the territories synthetic reviews rotate through
+var mockTerritories = []string{"USA", "GBR", "CAN", "AUS", "DEU"}
+
+// MockClient serves deterministic synthetic App Store data so App Store endpoints work in local
+// development without App Store Connect credentials. Daily figures depend only on the app ID
+// and the day, so overlapping ranges agree and repeated requests return identical data.
+type MockClient struct{}
+
+// NewMockClient creates a synthetic App Store data source
+func NewMockClient() *MockClient {
+	return &MockClient{}
+}
+
+// mockSeed derives a stable per-app seed so different apps get different figures
+func mockSeed(appID string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(appID))
+	return h.Sum32()
+}
+
+// mockDailyDownloads returns the synthetic download count for the UTC day containing day
+func mockDailyDownloads(seed uint32, day time.Time) int64 {
+	dayNumber := uint32(day.UTC().Unix() / 86400)
+	return 40 + int64((seed+dayNumber*7919)%60)
+}
+
+// GetAppAnalytics returns synthetic downloads, revenue and ratings summed over each day in range
+func (m *MockClient) GetAppAnalytics(ctx context.Context, appID string, startDate, endDate time.Time) (*AppAnalytics, error) {
+	seed := mockSeed(appID)
+
+	var downloads int64
+	day := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
+	for ; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		downloads += mockDailyDownloads(seed, day)
+	}
+
+	ratings, err := m.GetAppRatings(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	crashes, err := m.GetCrashMetrics(ctx, appID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	// Roughly 3% of downloads convert at $4.99, before Apple's commission
+	revenue := math.Round(float64(downloads)*0.03*4.99*0.7*100) / 100
+
+	return &AppAnalytics{
+		AppID:         appID,
+		AppName:       fmt.Sprintf("%s (mock)", appID),
+		Downloads:     downloads,
+		Updates:       downloads / 3,
+		Revenue:       revenue,
+		ActiveDevices: downloads * 4,
+		Crashes:       crashes.Total,
+		Ratings:       *ratings,
+		Period:        timerange.NewDatePeriod(startDate, endDate),
+	}, nil
+}
+
+// GetAppRatings returns a fixed, app-specific ratings distribution
+func (m *MockClient) GetAppRatings(ctx context.Context, appID string) (*RatingsData, error) {
+	seed := mockSeed(appID)
+	ratings := &RatingsData{
+		Distribution: map[int]int64{
+			5: 120 + int64(seed%50),
+			4: 40 + int64(seed%20),
+			3: 15 + int64(seed%5),
+			2: 6,
+			1: 9,
+		},
+	}
+
+	var totalScore int64
+	for stars, count := range ratings.Distribution {
+		ratings.TotalRatings += count
+		totalScore += int64(stars) * count
+	}
+	ratings.AverageRating = float64(totalScore) / float64(ratings.TotalRatings)
+	return ratings, nil
+}
+
+// GetCrashMetrics returns roughly one synthetic crash per 200 of each day's downloads
+func (m *MockClient) GetCrashMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*CrashMetrics, error) {
+	seed := mockSeed(appID)
+
+	metrics := &CrashMetrics{
+		AppID:  appID,
+		Daily:  []DailyCrashes{},
+		Period: timerange.NewDatePeriod(startDate, endDate),
+	}
+	day := time.Date(startDate.Year(), startDate.Month(), startDate.Day(), 0, 0, 0, 0, time.UTC)
+	for ; !day.After(endDate); day = day.AddDate(0, 0, 1) {
+		crashes := (mockDailyDownloads(seed, day) + int64(seed%200)) / 200
+		if crashes == 0 {
+			continue
+		}
+		metrics.Total += crashes
+		metrics.Daily = append(metrics.Daily, DailyCrashes{Date: day.Format("2006-01-02"), Crashes: crashes})
+	}
+	return metrics, nil
+}
+
+// GetSubscriptionMetrics reports no subscriptions; synthetic apps sell one-off purchases only,
+// which exercises the unavailable path of subscription views
+func (m *MockClient) GetSubscriptionMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*SubscriptionMetrics, error) {
+	return nil, ErrNoSubscriptions
+}
+
+// GetCustomerReviews returns synthetic reviews spaced over the weeks before the current UTC
+// day, filtered and paginated the same way as real reviews
+func (m *MockClient) GetCustomerReviews(ctx context.Context, appID string, filter ReviewFilter) (*ReviewPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultReviewsPageSize
+	}
+	if limit > MaxReviewsPageSize {
+		limit = MaxReviewsPageSize
+	}
+
+	var after *reviewPosition
+	if filter.Cursor != "" {
+		position, err := decodeReviewCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &position
+	}
+
+	seed := mockSeed(appID)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	page := &ReviewPage{Reviews: []CustomerReview{}}
+	for i := 0; i < mockReviewCount; i++ {
+		rating := mockRatings[(seed+uint32(i))%uint32(len(mockRatings))]
+		review := CustomerReview{
+			ID:               fmt.Sprintf("mock-%s-%03d", appID, i),
+			Rating:           rating,
+			Title:            fmt.Sprintf("%d-star review", rating),
+			Body:             "Synthetic review generated for local development.",
+			ReviewerNickname: fmt.Sprintf("reviewer%d", i),
+			Territory:        mockTerritories[i%len(mockTerritories)],
+			CreatedDate:      today.Add(-time.Duration(i) * 18 * time.Hour),
+		}
+
+		if review.Rating < filter.MinRating {
+			continue
+		}
+		if filter.Territory != "" && !strings.EqualFold(review.Territory, filter.Territory) {
+			continue
+		}
+		if !filter.Start.IsZero() && review.CreatedDate.Before(filter.Start) {
+			break
+		}
+		if !filter.End.IsZero() && review.CreatedDate.After(filter.End) {
+			continue
+		}
+		position := reviewPosition{CreatedDate: review.CreatedDate, ID: review.ID}
+		if after != nil && !after.before(position) {
+			continue
+		}
+
+		if len(page.Reviews) == limit {
+			last := page.Reviews[limit-1]
+			page.NextCursor = encodeReviewCursor(reviewPosition{CreatedDate: last.CreatedDate, ID: last.ID})
+			break
+		}
+		page.Reviews = append(page.Reviews, review)
+	}
+
+	return page, nil
+}
+
+// EnsureReportRequest returns a pending synthetic report job
+func (m *MockClient) EnsureReportRequest(ctx context.Context, appID, accessType string) (*ReportJob, error) {
+	return &ReportJob{
+		ID:         fmt.Sprintf("mock-%s-%s", appID, strings.ToLower(accessType)),
+		AccessType: accessType,
+		Status:     ReportJobPending,
+	}, nil
+}
+
+// GetReportJob reports synthetic jobs as ready with one report and no downloadable segments
+func (m *MockClient) GetReportJob(ctx context.Context, appID, requestID string, filter ReportFilter) (*ReportJob, error) {
+	prefix := fmt.Sprintf("mock-%s-", appID)
+	accessType, ok := strings.CutPrefix(requestID, prefix)
+	if !ok {
+		return nil, ErrReportRequestNotFound
+	}
+
+	category := filter.Category
+	if category == "" {
+		category = "APP_STORE_ENGAGEMENT"
+	}
+	name := filter.Name
+	if name == "" {
+		name = "App Store Discovery and Engagement Standard"
+	}
+
+	return &ReportJob{
+		ID:         requestID,
+		AccessType: strings.ToUpper(accessType),
+		Status:     ReportJobReady,
+		Reports: []AnalyticsReport{
+			{
+				ID:       requestID + "-report",
+				Name:     name,
+				Category: category,
+				Latest: &ReportInstance{
+					ID:             requestID + "-instance",
+					Granularity:    filter.Granularity,
+					ProcessingDate: time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02"),
+					Segments:       []ReportSegment{},
+				},
+			},
+		},
+	}, nil
+}
+
+// RateLimitStatus reports an unthrottled quota; the mock never calls Apple
+func (m *MockClient) RateLimitStatus() RateLimitStatus {
+	return RateLimitStatus{}
+}
@@ -0,0 +1,145 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	mockStart = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	mockEnd   = time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC)
+)
+
+func TestMockClientIsStable(t *testing.T) {
+	mock := NewMockClient()
+	ctx := context.Background()
+
+	first, err := mock.GetAppAnalytics(ctx, "app", mockStart, mockEnd)
+	if err != nil {
+		t.Fatalf("GetAppAnalytics: %v", err)
+	}
+	second, err := NewMockClient().GetAppAnalytics(ctx, "app", mockStart, mockEnd)
+	if err != nil {
+		t.Fatalf("second GetAppAnalytics: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("repeated analytics differ:\n%+v\n%+v", first, second)
+	}
+
+	// Figures depend only on the day, so a range split in two adds up to the whole
+	head, _ := mock.GetAppAnalytics(ctx, "app", mockStart, mockStart.AddDate(0, 0, 2))
+	tail, _ := mock.GetAppAnalytics(ctx, "app", mockStart.AddDate(0, 0, 3), mockEnd)
+	if head.Downloads+tail.Downloads != first.Downloads {
+		t.Errorf("split downloads = %d + %d, want %d", head.Downloads, tail.Downloads, first.Downloads)
+	}
+	if head.Crashes+tail.Crashes != first.Crashes {
+		t.Errorf("split crashes = %d + %d, want %d", head.Crashes, tail.Crashes, first.Crashes)
+	}
+
+	other, err := mock.GetAppAnalytics(ctx, "other-app", mockStart, mockEnd)
+	if err != nil {
+		t.Fatalf("GetAppAnalytics for another app: %v", err)
+	}
+	if other.Downloads == first.Downloads && other.Ratings.TotalRatings == first.Ratings.TotalRatings {
+		t.Error("different apps got identical figures")
+	}
+}
+
+func TestMockClientDataIsShaped(t *testing.T) {
+	mock := NewMockClient()
+	ctx := context.Background()
+
+	analytics, err := mock.GetAppAnalytics(ctx, "app", mockStart, mockEnd)
+	if err != nil {
+		t.Fatalf("GetAppAnalytics: %v", err)
+	}
+	// Seven days of 40 to 99 downloads each
+	if analytics.Downloads < 7*40 || analytics.Downloads >= 7*100 {
+		t.Errorf("Downloads = %d, want between %d and %d", analytics.Downloads, 7*40, 7*100)
+	}
+	if analytics.Revenue <= 0 || analytics.ActiveDevices != 4*analytics.Downloads || analytics.Updates != analytics.Downloads/3 {
+		t.Errorf("analytics = %+v, want revenue and device and update counts derived from downloads", analytics)
+	}
+	if analytics.Period.Start == "" || analytics.Period.End == "" {
+		t.Errorf("Period = %+v, want the requested range", analytics.Period)
+	}
+
+	ratings := analytics.Ratings
+	var total int64
+	for stars, count := range ratings.Distribution {
+		if stars < 1 || stars > 5 || count <= 0 {
+			t.Errorf("distribution has %d stars x %d", stars, count)
+		}
+		total += count
+	}
+	if total != ratings.TotalRatings || ratings.AverageRating < 1 || ratings.AverageRating > 5 {
+		t.Errorf("ratings = %+v, want a total matching the distribution and an average in 1-5", ratings)
+	}
+
+	crashes, err := mock.GetCrashMetrics(ctx, "app", mockStart, mockEnd)
+	if err != nil {
+		t.Fatalf("GetCrashMetrics: %v", err)
+	}
+	var daily int64
+	for _, day := range crashes.Daily {
+		daily += day.Crashes
+	}
+	if daily != crashes.Total || crashes.Total != analytics.Crashes {
+		t.Errorf("crashes = %d daily, %d total, %d in analytics, want all equal", daily, crashes.Total, analytics.Crashes)
+	}
+
+	if _, err := mock.GetSubscriptionMetrics(ctx, "app", mockStart, mockEnd); !errors.Is(err, ErrNoSubscriptions) {
+		t.Errorf("GetSubscriptionMetrics: err = %v, want ErrNoSubscriptions", err)
+	}
+}
+
+func TestMockClientReviewsPaginate(t *testing.T) {
+	mock := NewMockClient()
+	ctx := context.Background()
+
+	seen := make(map[string]bool)
+	var last time.Time
+	filter := ReviewFilter{Limit: 15}
+	for pages := 1; ; pages++ {
+		page, err := mock.GetCustomerReviews(ctx, "app", filter)
+		if err != nil {
+			t.Fatalf("GetCustomerReviews page %d: %v", pages, err)
+		}
+		for _, review := range page.Reviews {
+			if seen[review.ID] {
+				t.Errorf("review %s served twice", review.ID)
+			}
+			if !last.IsZero() && review.CreatedDate.After(last) {
+				t.Errorf("review %s is newer than the one before it", review.ID)
+			}
+			seen[review.ID] = true
+			last = review.CreatedDate
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		if pages > mockReviewCount {
+			t.Fatal("pagination never ended")
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if len(seen) != mockReviewCount {
+		t.Errorf("paginated through %d reviews, want %d", len(seen), mockReviewCount)
+	}
+
+	page, err := mock.GetCustomerReviews(ctx, "app", ReviewFilter{MinRating: 4, Territory: "gbr", Limit: MaxReviewsPageSize})
+	if err != nil {
+		t.Fatalf("GetCustomerReviews filtered: %v", err)
+	}
+	if len(page.Reviews) == 0 {
+		t.Fatal("filtered reviews are empty")
+	}
+	for _, review := range page.Reviews {
+		if review.Rating < 4 || review.Territory != "GBR" {
+			t.Errorf("review %s is %d stars from %s, want 4+ from GBR", review.ID, review.Rating, review.Territory)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// pageLimit is the page size requested from list endpoints (Apple's maximum is 200)
+	pageLimit = 200
+
+	// DefaultMaxPages bounds how many pages a single paginated request fetches
+	DefaultMaxPages = 50
+)
+
+// SetMaxPages caps how many pages a paginated request follows before truncating results.
+// Values below 1 use DefaultMaxPages. Call before the client is shared.
+func (c *AppStoreConnectClient) SetMaxPages(n int) {
+	c.maxPages = n
+}
+
+// pageCap returns the configured page cap
+func (c *AppStoreConnectClient) pageCap() int {
+	if c.maxPages < 1 {
+		return DefaultMaxPages
+	}
+	return c.maxPages
+}
+
+// makeRequestPaged performs method on endpoint and every links.next page after it, returning
+// the accumulated data arrays. Results are truncated once the page cap is reached.
+func (c *AppStoreConnectClient) makeRequestPaged(ctx context.Context, method, endpoint string) ([]json.RawMessage, error) {
+	var items []json.RawMessage
+	err := c.paginateRequest(ctx, method, endpoint, func(data []byte) (bool, error) {
+		var page struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return false, fmt.Errorf("failed to parse page data: %w", err)
+		}
+		items = append(items, page.Data...)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// paginate fetches endpoint and follows links.next, calling handlePage with each page's body.
+// It stops when there are no more pages, handlePage returns false or an error, or the page
+// cap has been reached, in which case results are truncated.
+func (c *AppStoreConnectClient) paginate(ctx context.Context, endpoint string, handlePage func([]byte) (bool, error)) error {
+	return c.paginateRequest(ctx, "GET", endpoint, handlePage)
+}
+
+// paginateRequest implements paginate for any request method
+func (c *AppStoreConnectClient) paginateRequest(ctx context.Context, method, endpoint string, handlePage func([]byte) (bool, error)) error {
+	if err := c.ready(); err != nil {
+		return err
+	}
+
+	maxPages := c.pageCap()
+	for page := 0; endpoint != "" && page < maxPages; page++ {
+		data, err := c.makeRequest(ctx, method, endpoint, nil)
+		if err != nil {
+			return err
+		}
+
+		more, err := handlePage(data)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+
+		var links struct {
+			Links struct {
+				Next string `json:"next"`
+			} `json:"links"`
+		}
+		if err := json.Unmarshal(data, &links); err != nil {
+			return fmt.Errorf("failed to parse pagination links: %w", err)
+		}
+
+		endpoint, err = nextEndpoint(links.Links.Next)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nextEndpoint converts an absolute links.next URL into an endpoint relative to the API base.
+// Links pointing anywhere else are rejected so the bearer token is never sent off-host.
+func nextEndpoint(next string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(next, appStoreConnectBaseURL+"/") {
+		return "", fmt.Errorf("unexpected pagination link %q", next)
+	}
+	return strings.TrimPrefix(next, appStoreConnectBaseURL), nil
+}
@@ -0,0 +1,215 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pagedTransport serves pages numbered from 1, each linking to the next until pages runs out
+type pagedTransport struct {
+	pages     int
+	nextLink  func(page int) string
+	mu        sync.Mutex
+	requested []string
+}
+
+func (tr *pagedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.mu.Lock()
+	tr.requested = append(tr.requested, req.URL.String())
+	tr.mu.Unlock()
+
+	page := 1
+	if value := req.URL.Query().Get("cursor"); value != "" {
+		page, _ = strconv.Atoi(value)
+	}
+
+	next := ""
+	if page < tr.pages {
+		next = appStoreConnectBaseURL + "/apps?cursor=" + strconv.Itoa(page+1)
+		if tr.nextLink != nil {
+			next = tr.nextLink(page + 1)
+		}
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"data":  []map[string]string{{"id": fmt.Sprintf("item-%d", page)}},
+		"links": map[string]string{"next": next},
+	})
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(string(body))),
+		Request:    req,
+	}, nil
+}
+
+func (tr *pagedTransport) requests() []string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return append([]string(nil), tr.requested...)
+}
+
+func TestPaginateVisitsEveryPage(t *testing.T) {
+	transport := &pagedTransport{pages: 4}
+	client := newTestClient(t, transport)
+
+	var pages int
+	err := client.paginate(context.Background(), "/apps", func(data []byte) (bool, error) {
+		pages++
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+	if pages != 4 {
+		t.Errorf("visited %d pages, want 4", pages)
+	}
+
+	requested := transport.requests()
+	if len(requested) != 4 || requested[0] != appStoreConnectBaseURL+"/apps" || requested[3] != appStoreConnectBaseURL+"/apps?cursor=4" {
+		t.Errorf("requested %v, want /apps then cursors 2 to 4", requested)
+	}
+}
+
+func TestPaginateStopsEarly(t *testing.T) {
+	transport := &pagedTransport{pages: 10}
+	client := newTestClient(t, transport)
+
+	var pages int
+	err := client.paginate(context.Background(), "/apps", func(data []byte) (bool, error) {
+		pages++
+		return pages < 2, nil
+	})
+	if err != nil {
+		t.Fatalf("paginate: %v", err)
+	}
+	if pages != 2 || len(transport.requests()) != 2 {
+		t.Errorf("visited %d pages with %d requests, want 2 of each", pages, len(transport.requests()))
+	}
+
+	// An error from the callback stops paging and is returned
+	stop := errors.New("bad page")
+	transport = &pagedTransport{pages: 10}
+	client = newTestClient(t, transport)
+	if err := client.paginate(context.Background(), "/apps", func([]byte) (bool, error) { return true, stop }); !errors.Is(err, stop) {
+		t.Errorf("err = %v, want the callback's error", err)
+	}
+	if got := len(transport.requests()); got != 1 {
+		t.Errorf("made %d requests after the callback failed, want 1", got)
+	}
+}
+
+func TestPaginateTruncatesAtPageCap(t *testing.T) {
+	transport := &pagedTransport{pages: 10}
+	client := newTestClient(t, transport)
+	client.SetMaxPages(3)
+
+	items, err := client.makeRequestPaged(context.Background(), http.MethodGet, "/apps")
+	if err != nil {
+		t.Fatalf("makeRequestPaged: %v", err)
+	}
+	if len(items) != 3 || len(transport.requests()) != 3 {
+		t.Errorf("got %d items from %d requests, want 3 of each", len(items), len(transport.requests()))
+	}
+	if !strings.Contains(string(items[2]), "item-3") {
+		t.Errorf("last item = %s, want item-3", items[2])
+	}
+}
+
+func TestPaginateRejectsOffHostLinks(t *testing.T) {
+	transport := &pagedTransport{pages: 2, nextLink: func(page int) string {
+		return "https://evil.example.com/v1/apps?cursor=" + strconv.Itoa(page)
+	}}
+	client := newTestClient(t, transport)
+
+	err := client.paginate(context.Background(), "/apps", func([]byte) (bool, error) { return true, nil })
+	if err == nil || !strings.Contains(err.Error(), "unexpected pagination link") {
+		t.Errorf("err = %v, want an unexpected pagination link error", err)
+	}
+	if got := len(transport.requests()); got != 1 {
+		t.Errorf("made %d requests, want only the first page", got)
+	}
+}
+
+// cannedPagesTransport answers each request with the body stored under its path and query,
+// relative to the API base
+type cannedPagesTransport struct {
+	pages map[string]string
+}
+
+func (tr *cannedPagesTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := strings.TrimPrefix(req.URL.Path, "/v1")
+	if req.URL.RawQuery != "" {
+		key += "?" + req.URL.RawQuery
+	}
+	body, ok := tr.pages[key]
+	status := http.StatusOK
+	if !ok {
+		status, body = http.StatusNotFound, `{"errors":[{"detail":"no canned page for `+key+`"}]}`
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestGetAppRatingsAggregatesEveryPage(t *testing.T) {
+	transport := &cannedPagesTransport{pages: map[string]string{
+		"/apps/123/customerReviews?limit=200": `{
+			"data": [{"attributes": {"rating": 5}}, {"attributes": {"rating": 5}}, {"attributes": {"rating": 4}}],
+			"links": {"next": "` + appStoreConnectBaseURL + `/apps/123/customerReviews?cursor=page-2&limit=200"},
+			"meta": {"paging": {"total": 5}}
+		}`,
+		"/apps/123/customerReviews?cursor=page-2&limit=200": `{
+			"data": [{"attributes": {"rating": 1}}, {"attributes": {"rating": 5}}],
+			"links": {},
+			"meta": {"paging": {"total": 5}}
+		}`,
+	}}
+	client := newTestClient(t, transport)
+
+	ratings, err := client.GetAppRatings(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("GetAppRatings: %v", err)
+	}
+
+	// The second page's reviews count toward the average, not just the first page's
+	if ratings.AverageRating != 4 {
+		t.Errorf("AverageRating = %v, want 4 from both pages", ratings.AverageRating)
+	}
+	if ratings.Distribution[5] != 3 || ratings.Distribution[4] != 1 || ratings.Distribution[1] != 1 {
+		t.Errorf("Distribution = %v, want 3 fives, a four and a one", ratings.Distribution)
+	}
+	if ratings.TotalRatings != 5 {
+		t.Errorf("TotalRatings = %d, want 5", ratings.TotalRatings)
+	}
+}
+
+func TestGetTestFlightInfoCountsEveryPage(t *testing.T) {
+	transport := &cannedPagesTransport{pages: map[string]string{
+		"/apps/123/betaTesters?limit=200": `{
+			"data": [{"id": "t1"}, {"id": "t2"}],
+			"links": {"next": "` + appStoreConnectBaseURL + `/apps/123/betaTesters?cursor=2&limit=200"}
+		}`,
+		"/apps/123/betaTesters?cursor=2&limit=200": `{"data": [{"id": "t3"}], "links": {}}`,
+		"/apps/123/betaGroups?limit=200":           `{"data": [{"id": "g1"}], "links": {}}`,
+	}}
+	client := newTestClient(t, transport)
+
+	info, err := client.GetTestFlightInfo(context.Background(), "123")
+	if err != nil {
+		t.Fatalf("GetTestFlightInfo: %v", err)
+	}
+	if info.BetaTesters != 3 || info.BetaGroups != 1 {
+		t.Errorf("info = %d testers, %d groups, want 3 testers across both pages and 1 group", info.BetaTesters, info.BetaGroups)
+	}
+}
@@ -0,0 +1,123 @@
+package appstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExchangeRateSource supplies the rates used to convert proceeds to the payout currency
+type ExchangeRateSource interface {
+	// Rate returns how much one unit of from is worth in to
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// StaticExchangeRates is a fixed set of rates into one payout currency, keyed by source
+// currency, e.g. {"EUR": 1.08} when paid in USD
+type StaticExchangeRates map[string]float64
+
+// ParseExchangeRates parses comma-separated CURRENCY=rate pairs, e.g. "EUR=1.08,GBP=1.27"
+func ParseExchangeRates(value string) (StaticExchangeRates, error) {
+	rates := make(StaticExchangeRates)
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		currency, rateValue, ok := strings.Cut(pair, "=")
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateValue), 64)
+		if !ok || currency == "" || err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate %q, want CURRENCY=rate", pair)
+		}
+		rates[currency] = rate
+	}
+	return rates, nil
+}
+
+// Rate returns the configured rate for from. The rates are all into the payout currency they
+// were configured for, so to isn't consulted.
+func (r StaticExchangeRates) Rate(ctx context.Context, from, to string) (float64, error) {
+	rate, ok := r[strings.ToUpper(from)]
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate from %s to %s", from, to)
+	}
+	return rate, nil
+}
+
+// PayoutProceeds holds proceeds converted to the developer's payout currency, alongside the
+// rates used so the conversion can be audited
+type PayoutProceeds struct {
+	Currency  string             `json:"currency"`
+	Total     float64            `json:"total"`
+	ByCountry map[string]float64 `json:"byCountry"`
+	Rates     map[string]float64 `json:"rates"`
+}
+
+// ConvertProceeds converts every bucket's proceeds to currency and stores the result in
+// Payout. Proceeds already in currency keep a rate of 1 and need no rate from rates. The
+// local-currency proceeds are left as they are.
+func (s *SalesReportSummary) ConvertProceeds(ctx context.Context, currency string, rates ExchangeRateSource) error {
+	currency = strings.ToUpper(currency)
+
+	used, err := payoutRates(ctx, s.Total.Proceeds, currency, rates)
+	if err != nil {
+		return err
+	}
+
+	payout := &PayoutProceeds{
+		Currency:  currency,
+		Total:     convertAmounts(s.Total.Proceeds, used),
+		ByCountry: make(map[string]float64, len(s.ByCountry)),
+		Rates:     used,
+	}
+	for country, bucket := range s.ByCountry {
+		payout.ByCountry[country] = convertAmounts(bucket.Proceeds, used)
+	}
+	s.Payout = payout
+	return nil
+}
+
+// payoutRates looks up the rate into currency for every currency in amounts, keeping a rate
+// of 1 for amounts already in currency
+func payoutRates(ctx context.Context, amounts map[string]float64, currency string, rates ExchangeRateSource) (map[string]float64, error) {
+	used := make(map[string]float64, len(amounts))
+	currencies := make([]string, 0, len(amounts))
+	for local := range amounts {
+		currencies = append(currencies, local)
+	}
+	sort.Strings(currencies)
+
+	for _, local := range currencies {
+		if strings.EqualFold(local, currency) {
+			used[local] = 1
+			continue
+		}
+		if rates == nil {
+			return nil, fmt.Errorf("no exchange rate source to convert %s proceeds to %s", local, currency)
+		}
+		rate, err := rates.Rate(ctx, local, currency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert proceeds to %s: %w", currency, err)
+		}
+		used[local] = rate
+	}
+	return used, nil
+}
+
+// convertAmounts sums amounts keyed by currency after converting each with rates
+func convertAmounts(amounts map[string]float64, rates map[string]float64) float64 {
+	var total float64
+	for currency, amount := range amounts {
+		total += amount * rates[currency]
+	}
+	return total
+}
+
+// SetPayoutCurrency makes GetSalesReport also return proceeds converted to currency using
+// rates. An empty currency turns conversion off. Call before the client is shared.
+func (c *AppStoreConnectClient) SetPayoutCurrency(currency string, rates ExchangeRateSource) {
+	c.payoutCurrency = strings.ToUpper(strings.TrimSpace(currency))
+	c.exchangeRates = rates
+}
@@ -0,0 +1,152 @@
+package appstore
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// multiCurrencyReport sells in three currencies: 1.40 USD, 2.80 EUR across two countries and
+// 0.50 GBP
+const multiCurrencyReport = salesReportHeader +
+	"APPLE\tSKU-1\t2\t0.70\tUS\tUSD\t1F\t1000\t\n" +
+	"APPLE\tSKU-1\t3\t0.60\tDE\tEUR\t1F\t1000\t\n" +
+	"APPLE\tSKU-1\t1\t1.00\tFR\tEUR\t1F\t1000\t\n" +
+	"APPLE\tSKU-1\t1\t0.50\tGB\tGBP\t1F\t1000\t\n"
+
+var usdRates = StaticExchangeRates{"EUR": 1.10, "GBP": 1.25}
+
+func TestParseExchangeRates(t *testing.T) {
+	rates, err := ParseExchangeRates(" eur=1.10, GBP = 1.25 ,")
+	if err != nil {
+		t.Fatalf("ParseExchangeRates: %v", err)
+	}
+	if !reflect.DeepEqual(rates, usdRates) {
+		t.Errorf("rates = %v, want %v", rates, usdRates)
+	}
+
+	for _, value := range []string{"EUR", "EUR=", "EUR=abc", "EUR=0", "EUR=-1", "=1.1"} {
+		if _, err := ParseExchangeRates(value); err == nil {
+			t.Errorf("ParseExchangeRates(%q) succeeded", value)
+		}
+	}
+}
+
+func TestConvertProceeds(t *testing.T) {
+	summary, err := ParseSalesReport(bytes.NewReader(gzipReport(t, multiCurrencyReport)))
+	if err != nil {
+		t.Fatalf("ParseSalesReport: %v", err)
+	}
+	if err := summary.ConvertProceeds(context.Background(), "usd", usdRates); err != nil {
+		t.Fatalf("ConvertProceeds: %v", err)
+	}
+
+	payout := summary.Payout
+	if payout == nil || payout.Currency != "USD" {
+		t.Fatalf("payout = %+v, want USD", payout)
+	}
+	checkAmount(t, "total", payout.Total, 1.40+2.80*1.10+0.50*1.25)
+	for country, want := range map[string]float64{"US": 1.40, "DE": 1.80 * 1.10, "FR": 1.10, "GB": 0.625} {
+		checkAmount(t, country, payout.ByCountry[country], want)
+	}
+	if want := map[string]float64{"USD": 1, "EUR": 1.10, "GBP": 1.25}; !reflect.DeepEqual(payout.Rates, want) {
+		t.Errorf("rates used = %v, want %v", payout.Rates, want)
+	}
+
+	// Local-currency proceeds are returned alongside the converted ones
+	checkAmount(t, "local EUR", summary.Total.Proceeds["EUR"], 2.80)
+	checkAmount(t, "local DE EUR", summary.ByCountry["DE"].Proceeds["EUR"], 1.80)
+}
+
+func TestConvertProceedsSingleCurrencyPassesThrough(t *testing.T) {
+	report := salesReportHeader +
+		"APPLE\tSKU-1\t2\t0.70\tUS\tUSD\t1F\t1000\t\n" +
+		"APPLE\tSKU-1\t1\t0.70\tCA\tUSD\t1F\t1000\t\n"
+	summary, err := ParseSalesReport(bytes.NewReader(gzipReport(t, report)))
+	if err != nil {
+		t.Fatalf("ParseSalesReport: %v", err)
+	}
+
+	// Nothing needs converting, so no rate source is needed
+	if err := summary.ConvertProceeds(context.Background(), "USD", nil); err != nil {
+		t.Fatalf("ConvertProceeds: %v", err)
+	}
+	checkAmount(t, "total", summary.Payout.Total, summary.Total.Proceeds["USD"])
+	checkAmount(t, "US", summary.Payout.ByCountry["US"], 1.40)
+	checkAmount(t, "CA", summary.Payout.ByCountry["CA"], 0.70)
+	if want := map[string]float64{"USD": 1}; !reflect.DeepEqual(summary.Payout.Rates, want) {
+		t.Errorf("rates used = %v, want %v", summary.Payout.Rates, want)
+	}
+}
+
+func TestConvertProceedsMissingRate(t *testing.T) {
+	summary, err := ParseSalesReport(bytes.NewReader(gzipReport(t, multiCurrencyReport)))
+	if err != nil {
+		t.Fatalf("ParseSalesReport: %v", err)
+	}
+
+	for name, rates := range map[string]ExchangeRateSource{"no source": nil, "no GBP rate": StaticExchangeRates{"EUR": 1.10}} {
+		if err := summary.ConvertProceeds(context.Background(), "USD", rates); err == nil {
+			t.Errorf("%s: ConvertProceeds succeeded", name)
+		}
+		if summary.Payout != nil {
+			t.Errorf("%s: a failed conversion set payout %+v", name, summary.Payout)
+		}
+	}
+}
+
+// salesReportTransport answers every request with report as the gzipped report body
+type salesReportTransport struct {
+	report []byte
+}
+
+func (tr *salesReportTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewReader(tr.report)),
+		Request:    req,
+	}, nil
+}
+
+func TestGetSalesReportConvertsWhenConfigured(t *testing.T) {
+	transport := &salesReportTransport{report: gzipReport(t, multiCurrencyReport)}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	client := newTestClient(t, transport)
+	summary, err := client.GetSalesReport(context.Background(), "vendor", day)
+	if err != nil {
+		t.Fatalf("GetSalesReport: %v", err)
+	}
+	if summary.Payout != nil {
+		t.Errorf("payout = %+v without a payout currency", summary.Payout)
+	}
+
+	client.SetPayoutCurrency(" usd ", usdRates)
+	summary, err = client.GetSalesReport(context.Background(), "vendor", day)
+	if err != nil {
+		t.Fatalf("GetSalesReport: %v", err)
+	}
+	if summary.Payout == nil || summary.Payout.Currency != "USD" {
+		t.Fatalf("payout = %+v, want USD", summary.Payout)
+	}
+	checkAmount(t, "total", summary.Payout.Total, 5.105)
+
+	client.SetPayoutCurrency("USD", nil)
+	if _, err := client.GetSalesReport(context.Background(), "vendor", day); err == nil || !strings.Contains(err.Error(), "EUR") {
+		t.Errorf("GetSalesReport without rates: err = %v, want a missing EUR rate", err)
+	}
+}
+
+func checkAmount(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
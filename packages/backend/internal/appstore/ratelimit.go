@@ -0,0 +1,174 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// rateLimitHeader carries Apple's quota, e.g. "user-hour-lim:3500;user-hour-rem:500;"
+	rateLimitHeader = "X-Rate-Limit"
+
+	// lowQuotaFraction is the remaining-quota fraction below which requests are delayed
+	lowQuotaFraction = 0.05
+
+	// maxProactiveDelay caps the delay applied when the quota is nearly exhausted
+	maxProactiveDelay = 5 * time.Second
+)
+
+// ErrRateLimited is returned when App Store Connect rejects a request with 429
+var ErrRateLimited = errors.New("App Store Connect rate limit exceeded")
+
+// RateLimitError is the error returned for a 429 response. It matches ErrRateLimited with
+// errors.Is and carries how long Apple asked clients to wait, zero when it didn't say.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Body       string
+}
+
+// Error describes the rejection and the requested wait
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v (retry after %s): %s", ErrRateLimited, e.RetryAfter, e.Body)
+	}
+	return fmt.Sprintf("%v: %s", ErrRateLimited, e.Body)
+}
+
+// Unwrap lets errors.Is match ErrRateLimited
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// parseRetryAfter reads a Retry-After header given either as seconds or as an HTTP date,
+// returning zero when it is missing, invalid or already past
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(header); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// RateLimitStatus represents the App Store Connect quota last reported by Apple
+type RateLimitStatus struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Throttled bool      `json:"throttled"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// RateLimitStatus returns the most recently observed quota
+func (c *AppStoreConnectClient) RateLimitStatus() RateLimitStatus {
+	if c == nil {
+		return RateLimitStatus{}
+	}
+	c.rateMu.Lock()
+	defer c.rateMu.Unlock()
+	return c.rateLimit
+}
+
+// recordRateLimit updates the observed quota from a response
+func (c *AppStoreConnectClient) recordRateLimit(header string, statusCode int) {
+	limit, remaining, ok := parseRateLimitHeader(header)
+
+	c.rateMu.Lock()
+	defer c.rateMu.Unlock()
+
+	if ok {
+		c.rateLimit.Limit = limit
+		c.rateLimit.Remaining = remaining
+		c.rateLimit.UpdatedAt = time.Now()
+	}
+	c.rateLimit.Throttled = statusCode == 429
+	if c.rateLimit.Throttled {
+		c.rateLimit.Remaining = 0
+		c.rateLimit.UpdatedAt = time.Now()
+	}
+}
+
+// proactiveDelay returns how long to wait before the next request based on the remaining quota.
+// The delay grows linearly from zero at the low-quota threshold to maxProactiveDelay when exhausted.
+func (c *AppStoreConnectClient) proactiveDelay() time.Duration {
+	c.rateMu.Lock()
+	defer c.rateMu.Unlock()
+
+	if c.rateLimit.UpdatedAt.IsZero() {
+		return 0
+	}
+	if c.rateLimit.Throttled {
+		return maxProactiveDelay
+	}
+	if c.rateLimit.Limit <= 0 {
+		return 0
+	}
+
+	threshold := float64(c.rateLimit.Limit) * lowQuotaFraction
+	remaining := float64(c.rateLimit.Remaining)
+	if remaining >= threshold {
+		return 0
+	}
+
+	return time.Duration(float64(maxProactiveDelay) * (threshold - remaining) / threshold)
+}
+
+// waitForQuota blocks for the proactive delay, returning early if the context is cancelled
+func (c *AppStoreConnectClient) waitForQuota(ctx context.Context) error {
+	delay := c.proactiveDelay()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// parseRateLimitHeader extracts the hourly limit and remaining quota from Apple's header
+func parseRateLimitHeader(header string) (int, int, bool) {
+	if header == "" {
+		return 0, 0, false
+	}
+
+	limit, remaining := -1, -1
+	for _, part := range strings.Split(header, ";") {
+		key, value, found := strings.Cut(strings.TrimSpace(part), ":")
+		if !found {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "user-hour-lim":
+			limit = n
+		case "user-hour-rem":
+			remaining = n
+		}
+	}
+
+	if limit < 0 || remaining < 0 {
+		return 0, 0, false
+	}
+	return limit, remaining, true
+}
@@ -0,0 +1,182 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// quotaTransport answers every request with status and Apple's rate-limit header set to quota
+type quotaTransport struct {
+	status     int
+	quota      string
+	retryAfter string
+	calls      atomic.Int32
+}
+
+func (tr *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.calls.Add(1)
+	header := make(http.Header)
+	if tr.quota != "" {
+		header.Set(rateLimitHeader, tr.quota)
+	}
+	if tr.retryAfter != "" {
+		header.Set("Retry-After", tr.retryAfter)
+	}
+	status := tr.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(`{"data":[]}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestParseRateLimitHeader(t *testing.T) {
+	tests := []struct {
+		header        string
+		wantLimit     int
+		wantRemaining int
+		wantOK        bool
+	}{
+		{header: "user-hour-lim:3500;user-hour-rem:500;", wantLimit: 3500, wantRemaining: 500, wantOK: true},
+		{header: " user-hour-rem: 12 ; user-hour-lim: 3600 ", wantLimit: 3600, wantRemaining: 12, wantOK: true},
+		{header: "user-hour-lim:3500;user-hour-rem:0;", wantLimit: 3500, wantRemaining: 0, wantOK: true},
+		{header: "user-hour-lim:3500;", wantOK: false},
+		{header: "user-hour-lim:many;user-hour-rem:5;", wantOK: false},
+		{header: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		limit, remaining, ok := parseRateLimitHeader(tt.header)
+		if ok != tt.wantOK || limit != tt.wantLimit || remaining != tt.wantRemaining {
+			t.Errorf("parseRateLimitHeader(%q) = %d, %d, %v, want %d, %d, %v", tt.header, limit, remaining, ok, tt.wantLimit, tt.wantRemaining, tt.wantOK)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{header: "30", want: 30 * time.Second},
+		{header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second},
+		{header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0},
+		{header: "0", want: 0},
+		{header: "soon", want: 0},
+		{header: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitHeadersSetQuotaAndDelay(t *testing.T) {
+	client := newTestClient(t, &quotaTransport{})
+
+	if status := client.RateLimitStatus(); !status.UpdatedAt.IsZero() || client.proactiveDelay() != 0 {
+		t.Fatalf("quota before any response = %+v", status)
+	}
+
+	tests := []struct {
+		name          string
+		quota         string
+		status        int
+		wantLimit     int
+		wantRemaining int
+		wantThrottled bool
+		wantDelay     time.Duration
+	}{
+		{name: "plenty left", quota: "user-hour-lim:3500;user-hour-rem:500;", wantLimit: 3500, wantRemaining: 500},
+		{name: "at the low-quota threshold", quota: "user-hour-lim:3500;user-hour-rem:175;", wantLimit: 3500, wantRemaining: 175},
+		{name: "below the threshold", quota: "user-hour-lim:3500;user-hour-rem:35;", wantLimit: 3500, wantRemaining: 35, wantDelay: 4 * time.Second},
+		{name: "exhausted", quota: "user-hour-lim:3500;user-hour-rem:0;", wantLimit: 3500, wantRemaining: 0, wantDelay: maxProactiveDelay},
+		{name: "header missing keeps the last quota", wantLimit: 3500, wantRemaining: 0, wantDelay: maxProactiveDelay},
+		{name: "rejected", quota: "user-hour-lim:3500;user-hour-rem:3;", status: http.StatusTooManyRequests, wantLimit: 3500, wantRemaining: 0, wantThrottled: true, wantDelay: maxProactiveDelay},
+		{name: "recovered", quota: "user-hour-lim:3500;user-hour-rem:3400;", wantLimit: 3500, wantRemaining: 3400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.recordRateLimit(tt.quota, max(tt.status, http.StatusOK))
+
+			status := client.RateLimitStatus()
+			if status.Limit != tt.wantLimit || status.Remaining != tt.wantRemaining || status.Throttled != tt.wantThrottled {
+				t.Errorf("status = %+v, want limit %d, remaining %d, throttled %v", status, tt.wantLimit, tt.wantRemaining, tt.wantThrottled)
+			}
+			if got := client.proactiveDelay(); got != tt.wantDelay {
+				t.Errorf("proactiveDelay = %s, want %s", got, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestMakeRequestRecordsQuota(t *testing.T) {
+	transport := &quotaTransport{quota: "user-hour-lim:3600;user-hour-rem:1200;"}
+	client := newTestClient(t, transport)
+
+	if _, err := client.makeRequest(context.Background(), http.MethodGet, "/apps", nil); err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	if status := client.RateLimitStatus(); status.Limit != 3600 || status.Remaining != 1200 || status.Throttled || status.UpdatedAt.IsZero() {
+		t.Errorf("status after a response = %+v", status)
+	}
+
+	transport.status = http.StatusTooManyRequests
+	transport.retryAfter = "7"
+	_, err := client.makeRequest(context.Background(), http.MethodGet, "/apps", nil)
+	var rateLimitErr *RateLimitError
+	if !errors.As(err, &rateLimitErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("makeRequest on 429: err = %v, want a RateLimitError", err)
+	}
+	if rateLimitErr.RetryAfter != 7*time.Second {
+		t.Errorf("RetryAfter = %s, want 7s", rateLimitErr.RetryAfter)
+	}
+	if status := client.RateLimitStatus(); !status.Throttled || status.Remaining != 0 {
+		t.Errorf("status after a 429 = %+v, want throttled with nothing remaining", status)
+	}
+}
+
+func TestLowQuotaDelaysNextRequest(t *testing.T) {
+	transport := &quotaTransport{}
+	client := newTestClient(t, transport)
+
+	// 10 below a threshold of 5000 delays by maxProactiveDelay * 10/5000 = 10ms
+	client.recordRateLimit("user-hour-lim:100000;user-hour-rem:4990;", http.StatusOK)
+	started := time.Now()
+	if _, err := client.makeRequest(context.Background(), http.MethodGet, "/apps", nil); err != nil {
+		t.Fatalf("makeRequest: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed < 10*time.Millisecond {
+		t.Errorf("request with low quota took %s, want at least 10ms", elapsed)
+	}
+
+	// A caller that gives up while waiting never reaches Apple
+	client.recordRateLimit("user-hour-lim:3500;user-hour-rem:0;", http.StatusOK)
+	calls := transport.calls.Load()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	started = time.Now()
+	if _, err := client.makeRequest(ctx, http.MethodGet, "/apps", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("makeRequest with exhausted quota: err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(started); elapsed >= maxProactiveDelay {
+		t.Errorf("cancelled wait took %s", elapsed)
+	}
+	if got := transport.calls.Load(); got != calls {
+		t.Errorf("exhausted quota still sent %d requests", got-calls)
+	}
+}
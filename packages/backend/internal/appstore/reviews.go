@@ -0,0 +1,214 @@
+package appstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultReviewsPageSize is the number of reviews returned when no limit is requested
+	DefaultReviewsPageSize = 50
+
+	// MaxReviewsPageSize caps the limit a caller may request
+	MaxReviewsPageSize = 200
+)
+
+// ErrInvalidCursor is returned when a reviews cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid reviews cursor")
+
+// CustomerReview is a single App Store customer review
+type CustomerReview struct {
+	ID               string    `json:"id"`
+	Rating           int       `json:"rating"`
+	Title            string    `json:"title"`
+	Body             string    `json:"body"`
+	ReviewerNickname string    `json:"reviewerNickname"`
+	Territory        string    `json:"territory"`
+	CreatedDate      time.Time `json:"createdDate"`
+}
+
+// ReviewFilter selects which customer reviews are returned. Zero values mean no filter.
+type ReviewFilter struct {
+	MinRating int       // 1-5; reviews rated below this are excluded
+	Territory string    // ISO 3166-1 alpha-3 code such as USA
+	Start     time.Time // reviews created before Start are excluded
+	End       time.Time // reviews created after End are excluded
+	Cursor    string    // NextCursor from a previous page
+	Limit     int       // page size, defaults to DefaultReviewsPageSize
+}
+
+// ReviewPage is one page of filtered customer reviews, newest first
+type ReviewPage struct {
+	Reviews    []CustomerReview `json:"reviews"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// reviewPosition identifies a review in newest-first order. Cursors encode the position of
+// the last review returned, so pages stay stable as new reviews arrive.
+type reviewPosition struct {
+	CreatedDate time.Time
+	ID          string
+}
+
+// before reports whether p sorts ahead of other (newer first, then by ID)
+func (p reviewPosition) before(other reviewPosition) bool {
+	if !p.CreatedDate.Equal(other.CreatedDate) {
+		return p.CreatedDate.After(other.CreatedDate)
+	}
+	return p.ID < other.ID
+}
+
+// encodeReviewCursor encodes a review position as an opaque cursor
+func encodeReviewCursor(p reviewPosition) string {
+	raw := p.CreatedDate.UTC().Format(time.RFC3339Nano) + "|" + p.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeReviewCursor decodes a cursor produced by encodeReviewCursor
+func decodeReviewCursor(cursor string) (reviewPosition, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return reviewPosition{}, ErrInvalidCursor
+	}
+	created, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return reviewPosition{}, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return reviewPosition{}, ErrInvalidCursor
+	}
+	return reviewPosition{CreatedDate: t, ID: id}, nil
+}
+
+// reviewsEndpoint builds the customer reviews request, pushing rating and territory
+// filters down to Apple and asking for newest-first order
+func reviewsEndpoint(appID string, filter ReviewFilter) string {
+	query := url.Values{}
+	query.Set("limit", strconv.Itoa(pageLimit))
+	query.Set("sort", "-createdDate")
+	if filter.MinRating > 1 {
+		ratings := make([]string, 0, 5)
+		for rating := filter.MinRating; rating <= 5; rating++ {
+			ratings = append(ratings, strconv.Itoa(rating))
+		}
+		query.Set("filter[rating]", strings.Join(ratings, ","))
+	}
+	if filter.Territory != "" {
+		query.Set("filter[territory]", filter.Territory)
+	}
+	return fmt.Sprintf("/apps/%s/customerReviews?%s", appID, query.Encode())
+}
+
+// GetCustomerReviews returns one page of customer reviews matching filter, newest first.
+// Rating and territory are filtered by Apple; the date range and cursor are applied here.
+func (c *AppStoreConnectClient) GetCustomerReviews(ctx context.Context, appID string, filter ReviewFilter) (*ReviewPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultReviewsPageSize
+	}
+	if limit > MaxReviewsPageSize {
+		limit = MaxReviewsPageSize
+	}
+
+	var after *reviewPosition
+	if filter.Cursor != "" {
+		position, err := decodeReviewCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &position
+	}
+
+	var matches []CustomerReview
+	var boundary time.Time
+	hasMore := false
+
+	err := c.paginate(ctx, reviewsEndpoint(appID, filter), func(data []byte) (bool, error) {
+		var reviewsResponse struct {
+			Data []struct {
+				ID         string `json:"id"`
+				Attributes struct {
+					Rating           int       `json:"rating"`
+					Title            string    `json:"title"`
+					Body             string    `json:"body"`
+					ReviewerNickname string    `json:"reviewerNickname"`
+					Territory        string    `json:"territory"`
+					CreatedDate      time.Time `json:"createdDate"`
+				} `json:"attributes"`
+			} `json:"data"`
+		}
+
+		if err := json.Unmarshal(data, &reviewsResponse); err != nil {
+			return false, fmt.Errorf("failed to parse reviews: %w", err)
+		}
+
+		for _, item := range reviewsResponse.Data {
+			review := CustomerReview{
+				ID:               item.ID,
+				Rating:           item.Attributes.Rating,
+				Title:            item.Attributes.Title,
+				Body:             item.Attributes.Body,
+				ReviewerNickname: item.Attributes.ReviewerNickname,
+				Territory:        item.Attributes.Territory,
+				CreatedDate:      item.Attributes.CreatedDate,
+			}
+
+			// Reviews arrive newest first, so anything older than Start ends the scan
+			if !filter.Start.IsZero() && review.CreatedDate.Before(filter.Start) {
+				return false, nil
+			}
+			if !filter.End.IsZero() && review.CreatedDate.After(filter.End) {
+				continue
+			}
+			position := reviewPosition{CreatedDate: review.CreatedDate, ID: review.ID}
+			if after != nil && !after.before(position) {
+				continue
+			}
+
+			// Once the page is full, keep reading reviews that share the last timestamp so
+			// ties are ordered by ID rather than by Apple's arbitrary order
+			if len(matches) >= limit && review.CreatedDate.Before(boundary) {
+				hasMore = true
+				return false, nil
+			}
+
+			matches = append(matches, review)
+			if len(matches) == limit {
+				boundary = review.CreatedDate
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return reviewPosition{CreatedDate: matches[i].CreatedDate, ID: matches[i].ID}.
+			before(reviewPosition{CreatedDate: matches[j].CreatedDate, ID: matches[j].ID})
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+		hasMore = true
+	}
+
+	page := &ReviewPage{Reviews: matches}
+	if page.Reviews == nil {
+		page.Reviews = []CustomerReview{}
+	}
+	if hasMore {
+		last := matches[len(matches)-1]
+		page.NextCursor = encodeReviewCursor(reviewPosition{CreatedDate: last.CreatedDate, ID: last.ID})
+	}
+
+	return page, nil
+}
@@ -0,0 +1,194 @@
+package appstore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reviewsTransport serves fixture reviews newest first, three to a page, applying Apple's
+// rating and territory filters
+type reviewsTransport struct {
+	reviews []CustomerReview
+	calls   atomic.Int32
+}
+
+const reviewsFixturePageSize = 3
+
+func (tr *reviewsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tr.calls.Add(1)
+	query := req.URL.Query()
+
+	ratings := map[string]bool{}
+	for _, rating := range strings.Split(query.Get("filter[rating]"), ",") {
+		if rating != "" {
+			ratings[rating] = true
+		}
+	}
+	var matching []CustomerReview
+	for _, review := range tr.reviews {
+		if len(ratings) > 0 && !ratings[strconv.Itoa(review.Rating)] {
+			continue
+		}
+		if territory := query.Get("filter[territory]"); territory != "" && review.Territory != territory {
+			continue
+		}
+		matching = append(matching, review)
+	}
+
+	offset, _ := strconv.Atoi(query.Get("cursor"))
+	end := min(offset+reviewsFixturePageSize, len(matching))
+	type item struct {
+		ID         string         `json:"id"`
+		Attributes CustomerReview `json:"attributes"`
+	}
+	data := []item{}
+	for _, review := range matching[offset:end] {
+		data = append(data, item{ID: review.ID, Attributes: review})
+	}
+	next := ""
+	if end < len(matching) {
+		q := req.URL.Query()
+		q.Set("cursor", strconv.Itoa(end))
+		next = appStoreConnectBaseURL + strings.TrimPrefix(req.URL.Path, "/v1") + "?" + q.Encode()
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"data": data, "links": map[string]string{"next": next}})
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader(string(body))),
+		Request:    req,
+	}, nil
+}
+
+// reviewFixtures returns ten reviews a day apart, newest first, with two sharing a timestamp
+func reviewFixtures() []CustomerReview {
+	newest := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	territories := []string{"USA", "GBR", "USA", "DEU", "USA"}
+	reviews := make([]CustomerReview, 10)
+	for i := range reviews {
+		reviews[i] = CustomerReview{
+			ID:          "review-" + strconv.Itoa(i),
+			Rating:      i%5 + 1,
+			Territory:   territories[i%5],
+			CreatedDate: newest.AddDate(0, 0, -i),
+		}
+	}
+	reviews[5].CreatedDate = reviews[4].CreatedDate
+	return reviews
+}
+
+func reviewIDs(reviews []CustomerReview) string {
+	ids := make([]string, len(reviews))
+	for i, review := range reviews {
+		ids[i] = strings.TrimPrefix(review.ID, "review-")
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestGetCustomerReviewsFilters(t *testing.T) {
+	fixtures := reviewFixtures()
+	tests := []struct {
+		name   string
+		filter ReviewFilter
+		want   string
+	}{
+		{name: "no filter", filter: ReviewFilter{}, want: "0,1,2,3,4,5,6,7,8,9"},
+		{name: "rating threshold", filter: ReviewFilter{MinRating: 4}, want: "3,4,8,9"},
+		{name: "territory", filter: ReviewFilter{Territory: "USA"}, want: "0,2,4,5,7,9"},
+		{name: "rating and territory", filter: ReviewFilter{MinRating: 3, Territory: "USA"}, want: "2,4,7,9"},
+		{name: "date range", filter: ReviewFilter{Start: fixtures[7].CreatedDate, End: fixtures[2].CreatedDate}, want: "2,3,4,5,6,7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, &reviewsTransport{reviews: fixtures})
+			page, err := client.GetCustomerReviews(context.Background(), "123", tt.filter)
+			if err != nil {
+				t.Fatalf("GetCustomerReviews: %v", err)
+			}
+			if got := reviewIDs(page.Reviews); got != tt.want {
+				t.Errorf("reviews = %s, want %s", got, tt.want)
+			}
+			if page.NextCursor != "" {
+				t.Errorf("NextCursor = %q on the last page", page.NextCursor)
+			}
+		})
+	}
+}
+
+func TestGetCustomerReviewsStopsAtStart(t *testing.T) {
+	fixtures := reviewFixtures()
+	transport := &reviewsTransport{reviews: fixtures}
+	client := newTestClient(t, transport)
+
+	page, err := client.GetCustomerReviews(context.Background(), "123", ReviewFilter{Start: fixtures[2].CreatedDate})
+	if err != nil {
+		t.Fatalf("GetCustomerReviews: %v", err)
+	}
+	if got := reviewIDs(page.Reviews); got != "0,1,2" {
+		t.Errorf("reviews = %s, want 0,1,2", got)
+	}
+	if calls := transport.calls.Load(); calls != 2 {
+		t.Errorf("fetched %d pages, want to stop at the first review older than start", calls)
+	}
+}
+
+func TestGetCustomerReviewsCursorPagination(t *testing.T) {
+	client := newTestClient(t, &reviewsTransport{reviews: reviewFixtures()})
+
+	for _, filter := range []ReviewFilter{{Limit: 2}, {Limit: 4, MinRating: 2}} {
+		var pages []string
+		var all []CustomerReview
+		for cursor := ""; ; {
+			filter.Cursor = cursor
+			page, err := client.GetCustomerReviews(context.Background(), "123", filter)
+			if err != nil {
+				t.Fatalf("GetCustomerReviews: %v", err)
+			}
+			if len(page.Reviews) > filter.Limit {
+				t.Errorf("page has %d reviews, want at most %d", len(page.Reviews), filter.Limit)
+			}
+			pages = append(pages, reviewIDs(page.Reviews))
+			all = append(all, page.Reviews...)
+			if page.NextCursor == "" {
+				break
+			}
+			if len(pages) > 10 {
+				t.Fatalf("cursor never ran out: %v", pages)
+			}
+			cursor = page.NextCursor
+		}
+
+		want := "0,1,2,3,4,5,6,7,8,9"
+		if filter.MinRating == 2 {
+			want = "1,2,3,4,6,7,8,9"
+		}
+		if got := reviewIDs(all); got != want {
+			t.Errorf("limit %d pages %v, want every review once in order: %s", filter.Limit, pages, want)
+		}
+	}
+}
+
+func TestGetCustomerReviewsInvalidCursor(t *testing.T) {
+	transport := &reviewsTransport{reviews: reviewFixtures()}
+	client := newTestClient(t, transport)
+
+	for _, cursor := range []string{"not base64!", base64.RawURLEncoding.EncodeToString([]byte("no-separator")), base64.RawURLEncoding.EncodeToString([]byte("yesterday|review-1"))} {
+		if _, err := client.GetCustomerReviews(context.Background(), "123", ReviewFilter{Cursor: cursor}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("cursor %q: err = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+	if calls := transport.calls.Load(); calls != 0 {
+		t.Errorf("made %d requests with an invalid cursor", calls)
+	}
+}
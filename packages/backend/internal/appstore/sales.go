@@ -0,0 +1,297 @@
+package appstore
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sales report column headers used for aggregation
+const (
+	salesColumnSKU              = "SKU"
+	salesColumnAppleID          = "Apple Identifier"
+	salesColumnParentID         = "Parent Identifier"
+	salesColumnProductType      = "Product Type Identifier"
+	salesColumnUnits            = "Units"
+	salesColumnProceeds         = "Developer Proceeds"
+	salesColumnCountry          = "Country Code"
+	salesColumnProceedsCurrency = "Currency of Proceeds"
+)
+
+// SalesBucket holds aggregated units and proceeds for one group of sales report rows.
+// Proceeds are keyed by currency because Apple reports them in the storefront's proceeds currency.
+type SalesBucket struct {
+	Units    int64              `json:"units"`
+	Proceeds map[string]float64 `json:"proceeds"`
+}
+
+// SalesReportSummary holds sales report totals aggregated by country, SKU and product type
+type SalesReportSummary struct {
+	Rows          int64                   `json:"rows"`
+	Total         *SalesBucket            `json:"total"`
+	ByCountry     map[string]*SalesBucket `json:"byCountry"`
+	BySKU         map[string]*SalesBucket `json:"bySku"`
+	ByProductType map[string]*SalesBucket `json:"byProductType"`
+
+	// Proceeds converted to the payout currency; nil unless conversion is configured
+	Payout *PayoutProceeds `json:"payout,omitempty"`
+}
+
+// GetSalesReport downloads a daily summary sales report and aggregates it while streaming.
+// Proceeds are also converted to the payout currency when one is set.
+func (c *AppStoreConnectClient) GetSalesReport(ctx context.Context, vendorNumber string, reportDate time.Time) (*SalesReportSummary, error) {
+	summary, err := c.fetchSalesReport(ctx, vendorNumber, reportDate, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.convertPayout(ctx, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// fetchSalesReport downloads and aggregates one day's summary sales report, keeping only the
+// rows accepted by filter when it is non-nil
+func (c *AppStoreConnectClient) fetchSalesReport(ctx context.Context, vendorNumber string, reportDate time.Time, filter *salesRowFilter) (*SalesReportSummary, error) {
+	body, err := c.openReport(ctx, vendorNumber, "SALES", "", reportDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sales report: %w", err)
+	}
+	defer body.Close()
+
+	return parseSalesReport(body, filter)
+}
+
+// openReport requests one day's gzipped summary report of reportType from the salesReports
+// endpoint. version is only sent when set. The caller must close the body.
+func (c *AppStoreConnectClient) openReport(ctx context.Context, vendorNumber, reportType, version string, reportDate time.Time) (io.ReadCloser, error) {
+	query := url.Values{}
+	query.Set("filter[frequency]", "DAILY")
+	query.Set("filter[reportDate]", reportDate.Format("2006-01-02"))
+	query.Set("filter[reportSubType]", "SUMMARY")
+	query.Set("filter[reportType]", reportType)
+	query.Set("filter[vendorNumber]", vendorNumber)
+	if version != "" {
+		query.Set("filter[version]", version)
+	}
+
+	resp, err := c.openRequest(ctx, "GET", "/salesReports?"+query.Encode(), nil, "application/a-gzip")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// convertPayout converts a summary's proceeds to the payout currency when one is set
+func (c *AppStoreConnectClient) convertPayout(ctx context.Context, summary *SalesReportSummary) error {
+	if c.payoutCurrency == "" {
+		return nil
+	}
+	return summary.ConvertProceeds(ctx, c.payoutCurrency, c.exchangeRates)
+}
+
+// ParseSalesReport aggregates a gzipped tab-separated sales report row by row.
+// Only the aggregate buckets are kept in memory, so report size doesn't bound memory use.
+func ParseSalesReport(r io.Reader) (*SalesReportSummary, error) {
+	return parseSalesReport(r, nil)
+}
+
+// salesRowFilter keeps the sales report rows belonging to one app: the app's own rows, matched
+// by Apple ID, and its in-app purchases, whose Parent Identifier is the app's SKU
+type salesRowFilter struct {
+	appleID string
+	sku     string
+}
+
+// matches reports whether a row with the given identifiers belongs to the app
+func (f *salesRowFilter) matches(appleID, parentID string) bool {
+	return appleID == f.appleID || (f.sku != "" && parentID == f.sku)
+}
+
+// parseSalesReport implements ParseSalesReport, skipping rows filter rejects when it is non-nil
+func parseSalesReport(r io.Reader, filter *salesRowFilter) (*SalesReportSummary, error) {
+	report, err := openTSVReport(r, "sales")
+	if err != nil {
+		return nil, err
+	}
+	defer report.Close()
+
+	columns := report.columns
+	unitsIdx, ok := columns[salesColumnUnits]
+	if !ok {
+		return nil, fmt.Errorf("sales report is missing the %q column", salesColumnUnits)
+	}
+	proceedsIdx := columnIndex(columns, salesColumnProceeds)
+	currencyIdx := columnIndex(columns, salesColumnProceedsCurrency)
+	countryIdx := columnIndex(columns, salesColumnCountry)
+	skuIdx := columnIndex(columns, salesColumnSKU)
+	productTypeIdx := columnIndex(columns, salesColumnProductType)
+	appleIDIdx := columnIndex(columns, salesColumnAppleID)
+	parentIDIdx := columnIndex(columns, salesColumnParentID)
+	if filter != nil && appleIDIdx < 0 {
+		return nil, fmt.Errorf("sales report is missing the %q column", salesColumnAppleID)
+	}
+
+	summary := newSalesReportSummary()
+
+	for {
+		record, err := report.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		units, err := strconv.ParseInt(field(record, unitsIdx), 10, 64)
+		if err != nil {
+			// Apple appends a trailing "Total" style line on some report types; skip non-data rows
+			continue
+		}
+		if filter != nil && !filter.matches(field(record, appleIDIdx), field(record, parentIDIdx)) {
+			continue
+		}
+
+		// Developer Proceeds is reported per unit
+		proceedsPerUnit, _ := strconv.ParseFloat(field(record, proceedsIdx), 64)
+		proceeds := proceedsPerUnit * float64(units)
+		currency := field(record, currencyIdx)
+
+		summary.Rows++
+		summary.Total.add(units, currency, proceeds)
+		bucketFor(summary.ByCountry, field(record, countryIdx)).add(units, currency, proceeds)
+		bucketFor(summary.BySKU, field(record, skuIdx)).add(units, currency, proceeds)
+		bucketFor(summary.ByProductType, field(record, productTypeIdx)).add(units, currency, proceeds)
+	}
+
+	return summary, nil
+}
+
+// tsvReport reads a gzipped tab-separated report whose first row names its columns
+type tsvReport struct {
+	name    string
+	gz      *gzip.Reader
+	reader  *csv.Reader
+	header  []string
+	columns map[string]int
+}
+
+// openTSVReport opens a gzipped report and reads its header. name identifies the report in
+// errors. Reports may be several gzip members concatenated, each starting with its own copy of
+// the header; the members are read as one stream and the repeated headers are skipped.
+func openTSVReport(r io.Reader, name string) (*tsvReport, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzipped %s report: %w", name, err)
+	}
+
+	reader := csv.NewReader(gz)
+	reader.Comma = '\t'
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		gz.Close()
+		return nil, fmt.Errorf("failed to read %s report header: %w", name, err)
+	}
+
+	// The reader reuses its record slice, so keep a copy of the header to compare rows against
+	header = append([]string(nil), header...)
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		columns[header[i]] = i
+	}
+	return &tsvReport{name: name, gz: gz, reader: reader, header: header, columns: columns}, nil
+}
+
+// next returns the next row, or io.EOF after the last one. The slice is reused between calls.
+// Header rows repeated by later gzip members are skipped.
+func (t *tsvReport) next() ([]string, error) {
+	for {
+		record, err := t.reader.Read()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("failed to read %s report: %w", t.name, err)
+			}
+			return nil, err
+		}
+		if !t.isHeader(record) {
+			return record, nil
+		}
+	}
+}
+
+// isHeader reports whether record repeats the report's header row
+func (t *tsvReport) isHeader(record []string) bool {
+	if len(record) != len(t.header) {
+		return false
+	}
+	for i, column := range t.header {
+		if strings.TrimSpace(record[i]) != column {
+			return false
+		}
+	}
+	return true
+}
+
+// Close releases the report's decompressor
+func (t *tsvReport) Close() error {
+	return t.gz.Close()
+}
+
+// newSalesBucket creates an empty sales bucket
+func newSalesBucket() *SalesBucket {
+	return &SalesBucket{Proceeds: make(map[string]float64)}
+}
+
+// add accumulates units and proceeds into the bucket
+func (b *SalesBucket) add(units int64, currency string, proceeds float64) {
+	b.Units += units
+	if currency == "" || proceeds == 0 {
+		return
+	}
+	if _, ok := b.Proceeds[currency]; !ok {
+		currency = strings.Clone(currency)
+	}
+	b.Proceeds[currency] += proceeds
+}
+
+// bucketFor returns the bucket for key, creating it on first use.
+// Keys are cloned so the map doesn't retain each row's backing string.
+func bucketFor(buckets map[string]*SalesBucket, key string) *SalesBucket {
+	if key == "" {
+		key = "unknown"
+	}
+	if bucket, ok := buckets[key]; ok {
+		return bucket
+	}
+	bucket := newSalesBucket()
+	buckets[strings.Clone(key)] = bucket
+	return bucket
+}
+
+// columnIndex returns a column's index, or -1 if the report doesn't include it
+func columnIndex(columns map[string]int, name string) int {
+	if idx, ok := columns[name]; ok {
+		return idx
+	}
+	return -1
+}
+
+// field returns a trimmed record field, or "" for missing columns
+func field(record []string, idx int) string {
+	if idx < 0 || idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
@@ -0,0 +1,212 @@
+package appstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+const salesReportHeader = "Provider\tSKU\tUnits\tDeveloper Proceeds\tCountry Code\tCurrency of Proceeds\tProduct Type Identifier\tApple Identifier\tParent Identifier\n"
+
+// gzipReport gzips each part as its own member, as Apple does for large reports
+func gzipReport(t *testing.T, parts ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for _, part := range parts {
+		gz := gzip.NewWriter(&buf)
+		if _, err := io.WriteString(gz, part); err != nil {
+			t.Fatalf("gzip report: %v", err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatalf("gzip report: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// syntheticSales streams a gzipped sales report of rows rows through a pipe, so the report
+// itself is never held in memory, and totals what it wrote into want. peakHeap samples the
+// heap while the report is being read.
+type syntheticSales struct {
+	rows     int
+	bytes    int64
+	peakHeap uint64
+	want     *SalesReportSummary
+}
+
+func (s *syntheticSales) reader() io.Reader {
+	countries := []string{"US", "GB", "DE", "JP", "BR"}
+	productTypes := []string{"1F", "IA1", "IAY"}
+	currencies := []string{"USD", "EUR"}
+
+	s.want = newSalesReportSummary()
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		n, _ := io.WriteString(gz, salesReportHeader)
+		s.bytes += int64(n)
+		for i := 0; i < s.rows; i++ {
+			country, sku, productType, currency := countries[i%5], fmt.Sprintf("SKU-%d", i%7), productTypes[i%3], currencies[i%2]
+			units := int64(i%4 + 1)
+			n, _ := fmt.Fprintf(gz, "APPLE\t%s\t%d\t0.70\t%s\t%s\t%s\t%d\t\n", sku, units, country, currency, productType, 1000+i%7)
+			s.bytes += int64(n)
+
+			proceeds := 0.70 * float64(units)
+			s.want.Rows++
+			s.want.Total.add(units, currency, proceeds)
+			bucketFor(s.want.ByCountry, country).add(units, currency, proceeds)
+			bucketFor(s.want.BySKU, sku).add(units, currency, proceeds)
+			bucketFor(s.want.ByProductType, productType).add(units, currency, proceeds)
+
+			if i%10000 == 0 {
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				s.peakHeap = max(s.peakHeap, stats.HeapAlloc)
+			}
+		}
+		pw.CloseWithError(gz.Close())
+	}()
+	return pr
+}
+
+// checkBuckets fails t unless got and want hold the same units and proceeds per key
+func checkBuckets(t *testing.T, name string, got, want map[string]*SalesBucket) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s has %d buckets, want %d", name, len(got), len(want))
+	}
+	for key, w := range want {
+		checkBucket(t, name+"["+key+"]", got[key], w)
+	}
+}
+
+func checkBucket(t *testing.T, name string, got, want *SalesBucket) {
+	t.Helper()
+
+	if got == nil {
+		t.Errorf("%s is missing", name)
+		return
+	}
+	if got.Units != want.Units {
+		t.Errorf("%s units = %d, want %d", name, got.Units, want.Units)
+	}
+	for currency, proceeds := range want.Proceeds {
+		if math.Abs(got.Proceeds[currency]-proceeds) > 1e-6*proceeds {
+			t.Errorf("%s %s proceeds = %v, want %v", name, currency, got.Proceeds[currency], proceeds)
+		}
+	}
+}
+
+func TestParseSalesReportStreamsLargeReport(t *testing.T) {
+	sales := &syntheticSales{rows: 500000}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	summary, err := ParseSalesReport(sales.reader())
+	if err != nil {
+		t.Fatalf("ParseSalesReport: %v", err)
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	runtime.KeepAlive(summary)
+
+	if summary.Rows != sales.want.Rows {
+		t.Fatalf("Rows = %d, want %d", summary.Rows, sales.want.Rows)
+	}
+	checkBucket(t, "Total", summary.Total, sales.want.Total)
+	checkBuckets(t, "ByCountry", summary.ByCountry, sales.want.ByCountry)
+	checkBuckets(t, "BySKU", summary.BySKU, sales.want.BySKU)
+	checkBuckets(t, "ByProductType", summary.ByProductType, sales.want.ByProductType)
+
+	// Rows are garbage as soon as they're aggregated, so the heap never holds the report
+	if peak, limit := int64(sales.peakHeap)-int64(before.HeapAlloc), sales.bytes/3; peak > limit {
+		t.Errorf("parsing %d bytes of rows grew the heap by %d bytes, want under %d", sales.bytes, peak, limit)
+	}
+
+	// Only the buckets outlive the parse: a few kilobytes against megabytes of rows
+	if retained, limit := int64(after.HeapAlloc)-int64(before.HeapAlloc), sales.bytes/100; retained > limit {
+		t.Errorf("parsing %d bytes of rows retained %d bytes, want under %d", sales.bytes, retained, limit)
+	}
+}
+
+func TestParseSalesReportSkipsRepeatedHeadersAndTrailers(t *testing.T) {
+	report := gzipReport(t,
+		salesReportHeader+"APPLE\tSKU-1\t3\t0.70\tUS\tUSD\t1F\t1000\t\n",
+		salesReportHeader+"APPLE\tIAP-1\t2\t1.40\tUS\tUSD\tIA1\t2000\tSKU-1\n\t\tTotal\t\t\t\t\t\t\n",
+	)
+
+	summary, err := ParseSalesReport(bytes.NewReader(report))
+	if err != nil {
+		t.Fatalf("ParseSalesReport: %v", err)
+	}
+	if summary.Rows != 2 || summary.Total.Units != 5 {
+		t.Errorf("summary = %d rows, %d units, want 2 rows, 5 units", summary.Rows, summary.Total.Units)
+	}
+	if got := summary.Total.Proceeds["USD"]; math.Abs(got-4.9) > 1e-9 {
+		t.Errorf("USD proceeds = %v, want 4.9", got)
+	}
+}
+
+func TestParseSalesReportFiltersToApp(t *testing.T) {
+	report := gzipReport(t, salesReportHeader+strings.Join([]string{
+		"APPLE\tSKU-1\t3\t0.70\tUS\tUSD\t1F\t1000\t",
+		"APPLE\tIAP-1\t2\t1.40\tUS\tUSD\tIA1\t2000\tSKU-1",
+		"APPLE\tSKU-2\t9\t0.70\tUS\tUSD\t1F\t3000\t",
+		"APPLE\tIAP-2\t4\t1.40\tUS\tUSD\tIA1\t4000\tSKU-2",
+	}, "\n")+"\n")
+
+	summary, err := parseSalesReport(bytes.NewReader(report), &salesRowFilter{appleID: "1000", sku: "SKU-1"})
+	if err != nil {
+		t.Fatalf("parseSalesReport: %v", err)
+	}
+	if summary.Rows != 2 || summary.Total.Units != 5 {
+		t.Errorf("summary = %d rows, %d units, want the app's 2 rows, 5 units", summary.Rows, summary.Total.Units)
+	}
+	if _, ok := summary.BySKU["SKU-2"]; ok {
+		t.Error("another app's rows were aggregated")
+	}
+}
+
+func TestOpenTSVReportMergesGzipMembers(t *testing.T) {
+	// Two report instances, each with its own header; the second pads its header's columns
+	report := gzipReport(t,
+		"Date\tApp Name\tCrashes\n2024-05-01\tMy App\t4\n2024-05-02\tMy App\t7\n",
+		"Date \tApp Name\t Crashes\n2024-05-03\tMy App\t2\n",
+	)
+
+	tsv, err := openTSVReport(bytes.NewReader(report), "crash")
+	if err != nil {
+		t.Fatalf("openTSVReport: %v", err)
+	}
+	defer tsv.Close()
+
+	var rows []string
+	for {
+		row, err := tsv.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		rows = append(rows, strings.Join(row, ","))
+	}
+
+	want := []string{"2024-05-01,My App,4", "2024-05-02,My App,7", "2024-05-03,My App,2"}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+	if got, ok := tsv.columns["Crashes"]; !ok || got != 2 {
+		t.Errorf("Crashes column = %d, %v, want 2", got, ok)
+	}
+}
@@ -0,0 +1,318 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// ErrNoSubscriptions is returned when App Store Connect has no subscription reports for an app
+var ErrNoSubscriptions = errors.New("no auto-renewable subscriptions reported for this app")
+
+// subscriptionReportVersion is the SUBSCRIPTION and SUBSCRIPTION_EVENT report format parsed here
+const subscriptionReportVersion = "1_3"
+
+// snapshotLookback is how many days before a requested day are tried when its subscription
+// report isn't published yet
+const snapshotLookback = 3
+
+// Subscription and subscription event report column headers used for aggregation
+const (
+	subscriptionColumnAppleID      = "App Apple ID"
+	subscriptionColumnDuration     = "Standard Subscription Duration"
+	subscriptionColumnProceeds     = "Developer Proceeds"
+	subscriptionColumnCurrency     = "Proceeds Currency"
+	subscriptionColumnBillingRetry = "Billing Retry"
+	subscriptionColumnGracePeriod  = "Grace Period"
+	subscriptionEventColumnEvent   = "Event"
+	subscriptionEventColumnCount   = "Quantity"
+)
+
+// Subscription report columns counting active subscriptions, and whether subscribers in that
+// column currently pay
+var subscriptionActiveColumns = map[string]bool{
+	"Active Standard Price Subscriptions":                   true,
+	"Active Free Trial Introductory Offer Subscriptions":    false,
+	"Active Pay Up Front Introductory Offer Subscriptions":  true,
+	"Active Pay As You Go Introductory Offer Subscriptions": true,
+	"Free Trial Promotional Offer Subscriptions":            false,
+	"Pay Up Front Promotional Offer Subscriptions":          true,
+	"Pay As You Go Promotional Offer Subscriptions":         true,
+}
+
+// Subscription events counted as new subscriptions and as cancellations
+var (
+	newSubscriptionEvents      = map[string]bool{"Subscribe": true, "Start Introductory Price": true}
+	canceledSubscriptionEvents = map[string]bool{"Cancel": true}
+)
+
+// monthlyFactors converts one billing period's proceeds to a monthly amount, keyed by the
+// report's Standard Subscription Duration
+var monthlyFactors = map[string]float64{
+	"7 Days":   52.0 / 12,
+	"1 Month":  1,
+	"2 Months": 1.0 / 2,
+	"3 Months": 1.0 / 3,
+	"6 Months": 1.0 / 6,
+	"1 Year":   1.0 / 12,
+}
+
+// SubscriptionMetrics summarizes auto-renewable subscription health for an app
+type SubscriptionMetrics struct {
+	AppID               string   `json:"appId"`
+	ActiveSubscriptions int64    `json:"activeSubscriptions"`
+	PaidSubscriptions   int64    `json:"paidSubscriptions"`
+	FreeTrials          int64    `json:"freeTrials"`
+	BillingRetry        int64    `json:"billingRetry"`
+	GracePeriod         int64    `json:"gracePeriod"`
+	NewSubscriptions    int64    `json:"newSubscriptions"`
+	Cancellations       int64    `json:"cancellations"`
+	Retention           *float64 `json:"retention"` // Share of subscribers active before the period still active at its end; nil without a starting snapshot
+
+	// Estimated monthly recurring revenue from paying subscribers, by proceeds currency
+	EstimatedMRR map[string]float64 `json:"estimatedMrr"`
+	// EstimatedMRR converted to the payout currency; nil unless conversion is configured
+	PayoutMRR *PayoutAmount `json:"payoutMrr,omitempty"`
+
+	SnapshotDate string           `json:"snapshotDate"` // Day the active counts were reported for
+	Period       timerange.Period `json:"period"`
+}
+
+// PayoutAmount is an amount converted to the payout currency
+type PayoutAmount struct {
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+// subscriptionSnapshot holds one day's active subscription counts for an app
+type subscriptionSnapshot struct {
+	date         time.Time
+	active       int64
+	paid         int64
+	freeTrials   int64
+	billingRetry int64
+	gracePeriod  int64
+	mrr          map[string]float64
+}
+
+// GetSubscriptionMetrics reports an app's subscription health from the daily subscription and
+// subscription event reports for the vendor number set with SetVendorNumber. Active counts and
+// MRR come from the latest published snapshot up to endDate; new subscriptions and
+// cancellations are summed over the period. It returns ErrNoSubscriptions when Apple has no
+// subscription data for the app.
+func (c *AppStoreConnectClient) GetSubscriptionMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*SubscriptionMetrics, error) {
+	if c.vendorNumber == "" {
+		return nil, ErrVendorNumberNotSet
+	}
+
+	end, err := c.latestSubscriptionSnapshot(ctx, appID, endDate)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrNoSubscriptions
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	newSubs, canceled, err := c.subscriptionEvents(ctx, appID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := &SubscriptionMetrics{
+		AppID:               appID,
+		ActiveSubscriptions: end.active,
+		PaidSubscriptions:   end.paid,
+		FreeTrials:          end.freeTrials,
+		BillingRetry:        end.billingRetry,
+		GracePeriod:         end.gracePeriod,
+		NewSubscriptions:    newSubs,
+		Cancellations:       canceled,
+		EstimatedMRR:        end.mrr,
+		SnapshotDate:        end.date.Format("2006-01-02"),
+		Period:              timerange.NewDatePeriod(startDate, endDate),
+	}
+	if metrics.ActiveSubscriptions == 0 && newSubs == 0 && canceled == 0 {
+		return nil, ErrNoSubscriptions
+	}
+
+	// Subscribers active the day before the period began and still active at its end
+	start, err := c.latestSubscriptionSnapshot(ctx, appID, startDate.AddDate(0, 0, -1))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	if err == nil && start.active > 0 {
+		retained := float64(end.active-newSubs) / float64(start.active)
+		retained = min(max(retained, 0), 1)
+		metrics.Retention = &retained
+	}
+
+	if c.payoutCurrency != "" {
+		rates, err := payoutRates(ctx, end.mrr, c.payoutCurrency, c.exchangeRates)
+		if err != nil {
+			return nil, err
+		}
+		metrics.PayoutMRR = &PayoutAmount{
+			Currency: c.payoutCurrency,
+			Amount:   convertAmounts(end.mrr, rates),
+		}
+	}
+
+	return metrics, nil
+}
+
+// latestSubscriptionSnapshot returns the app's subscription snapshot for day, falling back to
+// earlier days while the report isn't published yet
+func (c *AppStoreConnectClient) latestSubscriptionSnapshot(ctx context.Context, appID string, day time.Time) (*subscriptionSnapshot, error) {
+	var err error
+	for i := 0; i <= snapshotLookback; i++ {
+		var snapshot *subscriptionSnapshot
+		snapshot, err = c.fetchSubscriptionSnapshot(ctx, appID, day.AddDate(0, 0, -i))
+		if !errors.Is(err, ErrNotFound) {
+			return snapshot, err
+		}
+	}
+	return nil, err
+}
+
+// fetchSubscriptionSnapshot downloads one day's subscription report and totals the app's rows
+func (c *AppStoreConnectClient) fetchSubscriptionSnapshot(ctx context.Context, appID string, day time.Time) (*subscriptionSnapshot, error) {
+	body, err := c.openReport(ctx, c.vendorNumber, "SUBSCRIPTION", subscriptionReportVersion, day)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription report: %w", err)
+	}
+	defer body.Close()
+
+	report, err := openTSVReport(body, "subscription")
+	if err != nil {
+		return nil, err
+	}
+	defer report.Close()
+
+	appleIDIdx, ok := report.columns[subscriptionColumnAppleID]
+	if !ok {
+		return nil, fmt.Errorf("subscription report is missing the %q column", subscriptionColumnAppleID)
+	}
+	durationIdx := columnIndex(report.columns, subscriptionColumnDuration)
+	proceedsIdx := columnIndex(report.columns, subscriptionColumnProceeds)
+	currencyIdx := columnIndex(report.columns, subscriptionColumnCurrency)
+	billingRetryIdx := columnIndex(report.columns, subscriptionColumnBillingRetry)
+	gracePeriodIdx := columnIndex(report.columns, subscriptionColumnGracePeriod)
+
+	snapshot := &subscriptionSnapshot{
+		date: time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC),
+		mrr:  make(map[string]float64),
+	}
+	for {
+		record, err := report.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if field(record, appleIDIdx) != appID {
+			continue
+		}
+
+		var paid int64
+		for column, paying := range subscriptionActiveColumns {
+			count := countField(record, columnIndex(report.columns, column))
+			snapshot.active += count
+			if paying {
+				paid += count
+			} else {
+				snapshot.freeTrials += count
+			}
+		}
+		snapshot.paid += paid
+		snapshot.billingRetry += countField(record, billingRetryIdx)
+		snapshot.gracePeriod += countField(record, gracePeriodIdx)
+
+		// Developer Proceeds is per billing period for the row's price
+		proceeds, _ := strconv.ParseFloat(field(record, proceedsIdx), 64)
+		currency := field(record, currencyIdx)
+		if factor, ok := monthlyFactors[field(record, durationIdx)]; ok && currency != "" && paid > 0 {
+			snapshot.mrr[currency] += proceeds * float64(paid) * factor
+		}
+	}
+
+	return snapshot, nil
+}
+
+// subscriptionEvents sums the app's new subscriptions and cancellations over each day's
+// subscription event report
+func (c *AppStoreConnectClient) subscriptionEvents(ctx context.Context, appID string, startDate, endDate time.Time) (int64, int64, error) {
+	var mu sync.Mutex
+	var newSubs, canceled int64
+	_, err := fetchReportDays(startDate, endDate, func(day time.Time) error {
+		dayNew, dayCanceled, err := c.fetchSubscriptionEvents(ctx, appID, day)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		newSubs += dayNew
+		canceled += dayCanceled
+		return nil
+	})
+	return newSubs, canceled, err
+}
+
+// fetchSubscriptionEvents downloads one day's subscription event report and counts the app's
+// new subscriptions and cancellations
+func (c *AppStoreConnectClient) fetchSubscriptionEvents(ctx context.Context, appID string, day time.Time) (int64, int64, error) {
+	body, err := c.openReport(ctx, c.vendorNumber, "SUBSCRIPTION_EVENT", subscriptionReportVersion, day)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to get subscription event report: %w", err)
+	}
+	defer body.Close()
+
+	report, err := openTSVReport(body, "subscription event")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer report.Close()
+
+	appleIDIdx, ok := report.columns[subscriptionColumnAppleID]
+	if !ok {
+		return 0, 0, fmt.Errorf("subscription event report is missing the %q column", subscriptionColumnAppleID)
+	}
+	eventIdx := columnIndex(report.columns, subscriptionEventColumnEvent)
+	countIdx := columnIndex(report.columns, subscriptionEventColumnCount)
+
+	var newSubs, canceled int64
+	for {
+		record, err := report.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		if field(record, appleIDIdx) != appID {
+			continue
+		}
+
+		event := field(record, eventIdx)
+		switch {
+		case newSubscriptionEvents[event]:
+			newSubs += countField(record, countIdx)
+		case canceledSubscriptionEvents[event]:
+			canceled += countField(record, countIdx)
+		}
+	}
+
+	return newSubs, canceled, nil
+}
+
+// countField parses a count column, treating missing or non-numeric values as zero
+func countField(record []string, idx int) int64 {
+	count, _ := strconv.ParseInt(field(record, idx), 10, 64)
+	return count
+}
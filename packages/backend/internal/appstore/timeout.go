@@ -0,0 +1,29 @@
+package appstore
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRequestTimeout bounds a request whose context has no deadline of its own
+const DefaultRequestTimeout = 30 * time.Second
+
+// SetRequestTimeout sets the timeout applied to requests whose context has no deadline.
+// Values below or equal to zero use DefaultRequestTimeout. Call before the client is shared.
+func (c *AppStoreConnectClient) SetRequestTimeout(timeout time.Duration) {
+	c.requestTimeout = timeout
+}
+
+// requestContext returns ctx unchanged when the caller set a deadline, so it governs the
+// request, and otherwise bounds it by the default request timeout. The cancel function must
+// be called once the response body is done with.
+func (c *AppStoreConnectClient) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	timeout := c.requestTimeout
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,26 @@
+package auth
+
+import "strings"
+
+// AdminSet is the set of Apple ID subs granted admin access
+type AdminSet map[string]struct{}
+
+// NewAdminSet builds an admin set from subs, ignoring blanks and surrounding whitespace
+func NewAdminSet(subs []string) AdminSet {
+	admins := make(AdminSet, len(subs))
+	for _, sub := range subs {
+		if sub = strings.TrimSpace(sub); sub != "" {
+			admins[sub] = struct{}{}
+		}
+	}
+	return admins
+}
+
+// Contains reports whether sub is an admin
+func (a AdminSet) Contains(sub string) bool {
+	if sub == "" {
+		return false
+	}
+	_, ok := a[sub]
+	return ok
+}
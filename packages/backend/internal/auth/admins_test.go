@@ -0,0 +1,29 @@
+package auth
+
+import "testing"
+
+func TestIsAdminChecksEverySub(t *testing.T) {
+	verifier, _ := newTestAppleVerifier(t, "com.example.app")
+	verifier.admins = NewAdminSet([]string{"001.first", " 002.second ", ""})
+
+	tests := []struct {
+		sub  string
+		want bool
+	}{
+		{sub: "001.first", want: true},
+		{sub: "002.second", want: true},
+		{sub: " 002.second ", want: false},
+		{sub: "003.other", want: false},
+		{sub: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := verifier.IsAdmin(tt.sub); got != tt.want {
+			t.Errorf("IsAdmin(%q) = %v, want %v", tt.sub, got, tt.want)
+		}
+	}
+
+	// The user info built at sign-in carries the same decision
+	if info := verifier.GetUserInfo(&AppleTokenClaims{Sub: "002.second"}); !info.IsAdmin {
+		t.Error("second admin's user info is not flagged admin")
+	}
+}
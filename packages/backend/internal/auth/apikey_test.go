@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// fakeAPIKeyStore holds keys by the hash of their secret
+type fakeAPIKeyStore struct {
+	keys map[string]*APIKey
+	err  error
+}
+
+func (s *fakeAPIKeyStore) GetAPIKey(ctx context.Context, keyHash string) (*APIKey, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.keys[keyHash], nil
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	store := &fakeAPIKeyStore{keys: map[string]*APIKey{
+		HashAPIKey("ci-secret"):      {ID: "ci", Scopes: []string{ScopeViewer}, ExpiresAt: expiresAt},
+		HashAPIKey("forever-secret"): {ID: "forever", Scopes: []string{ScopeBilling}},
+		HashAPIKey("admin-secret"):   {ID: "ops", Admin: true},
+		HashAPIKey("expired-secret"): {ID: "old", Scopes: []string{ScopeViewer}, ExpiresAt: time.Now().Add(-time.Minute)},
+		HashAPIKey("revoked-secret"): {ID: "leaked", Scopes: []string{ScopeViewer}, Revoked: true},
+	}}
+	authenticator := NewAPIKeyAuthenticator(store)
+
+	claims, err := authenticator.Authenticate("ci-secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if claims.UserID != "apikey:ci" || claims.Subject != "ci" || claims.IsAdmin || claims.TokenType != TokenTypeAccess {
+		t.Errorf("claims = %+v, want a non-admin access identity for key ci", claims)
+	}
+	if !slices.Equal(claims.Scopes, []string{ScopeViewer}) || !claims.HasScope(ScopeViewer) || claims.HasScope(ScopeBilling) {
+		t.Errorf("scopes = %v, want only viewer", claims.Scopes)
+	}
+	if claims.ExpiresAt == nil || !claims.ExpiresAt.Time.Equal(expiresAt) {
+		t.Errorf("ExpiresAt = %v, want %v", claims.ExpiresAt, expiresAt)
+	}
+
+	if claims, err := authenticator.Authenticate("forever-secret"); err != nil || claims.ExpiresAt != nil {
+		t.Errorf("key without expiry = %+v, %v, want claims that don't expire", claims, err)
+	}
+	if claims, err := authenticator.Authenticate("admin-secret"); err != nil || !claims.IsAdmin || !claims.HasScope(ScopeOperator) {
+		t.Errorf("admin key = %+v, %v, want an admin holding every scope", claims, err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want error
+	}{
+		{name: "expired", key: "expired-secret", want: ErrAPIKeyExpired},
+		{name: "revoked", key: "revoked-secret", want: ErrAPIKeyRevoked},
+		{name: "unknown", key: "guessed-secret", want: ErrAPIKeyInvalid},
+		{name: "empty", key: "", want: ErrAPIKeyInvalid},
+		// The stored hash is not itself a key
+		{name: "hash", key: HashAPIKey("ci-secret"), want: ErrAPIKeyInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := authenticator.Authenticate(tt.key); !errors.Is(err, tt.want) {
+				t.Errorf("Authenticate = %+v, %v, want %v", claims, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthenticateStoreUnavailable(t *testing.T) {
+	unavailable := errors.New("table unavailable")
+	authenticator := NewAPIKeyAuthenticator(&fakeAPIKeyStore{err: unavailable})
+
+	if claims, err := authenticator.Authenticate("ci-secret"); !errors.Is(err, unavailable) || claims != nil {
+		t.Errorf("Authenticate = %+v, %v, want the store's error", claims, err)
+	}
+}
+
+func TestAPIKeyErrorsClassify(t *testing.T) {
+	if got := ClassifyValidationError(ErrAPIKeyExpired); got != OutcomeExpired {
+		t.Errorf("expired key outcome = %s, want %s", got, OutcomeExpired)
+	}
+	if got := ClassifyValidationError(ErrAPIKeyRevoked); got != OutcomeRevoked {
+		t.Errorf("revoked key outcome = %s, want %s", got, OutcomeRevoked)
+	}
+}
@@ -2,18 +2,34 @@ package auth
 
 import (
 	"context"
-
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 )
 
 const (
 	appleKeysURL = "https://appleid.apple.com/auth/keys"
 	appleIssuer  = "https://appleid.apple.com"
+
+	// DefaultAppleKeyRefreshInterval is how old the cached Apple keys may get before refetching
+	DefaultAppleKeyRefreshInterval = 6 * time.Hour
+
+	// minKeyRefreshInterval stops tokens with made-up key IDs, or an Apple outage, from
+	// forcing a fetch per request
+	minKeyRefreshInterval = time.Minute
+
+	appleKeysFetchTimeout = 10 * time.Second
 )
 
 // AppleTokenClaims represents the claims in an Apple ID token
@@ -22,38 +38,183 @@ type AppleTokenClaims struct {
 	Email          string `json:"email"`
 	EmailVerified  string `json:"email_verified"`
 	IsPrivateEmail string `json:"is_private_email"`
+	RealUserStatus int    `json:"real_user_status"`
 	AuthTime       int64  `json:"auth_time"`
+	ExpiresAt      int64  `json:"exp"`
+	Nonce          string `json:"nonce"`
 	NonceSupported bool   `json:"nonce_supported"`
 }
 
+// ErrNonceMismatch is returned when an Apple ID token's nonce doesn't match the one the
+// client says it sent, meaning the token was minted for a different sign-in
+var ErrNonceMismatch = errors.New("token nonce does not match")
+
+// RealUserStatus is Apple's indication of whether the user appears to be a real person
+type RealUserStatus int
+
+// Values of Apple's real_user_status claim
+const (
+	RealUserStatusUnsupported RealUserStatus = 0
+	RealUserStatusUnknown     RealUserStatus = 1
+	RealUserStatusLikelyReal  RealUserStatus = 2
+)
+
+// String returns a readable name for the status
+func (s RealUserStatus) String() string {
+	switch s {
+	case RealUserStatusUnsupported:
+		return "unsupported"
+	case RealUserStatusUnknown:
+		return "unknown"
+	case RealUserStatusLikelyReal:
+		return "likely_real"
+	}
+	return fmt.Sprintf("RealUserStatus(%d)", int(s))
+}
+
+// IsLikelyReal reports whether Apple is confident the user is a real person
+func (s RealUserStatus) IsLikelyReal() bool {
+	return s == RealUserStatusLikelyReal
+}
+
 // AppleAuthVerifier handles Apple Sign In token verification
 type AppleAuthVerifier struct {
-	keySet   jwk.Set
-	adminSub string
+	admins          AdminSet
+	audience        string // Expected aud (client ID); empty skips the check
+	refreshInterval time.Duration
+	leeway          time.Duration
+
+	// Cached Apple keys and when they were fetched
+	mu        sync.RWMutex
+	keySet    jwk.Set
+	fetchedAt time.Time
+
+	// Serializes fetches so concurrent verifications don't stampede Apple's endpoint
+	refreshMu   sync.Mutex
+	lastAttempt time.Time
+
+	// Consumed nonces; nil when nonces aren't single-use
+	nonces NonceStore
 }
 
-// NewAppleAuthVerifier creates a new Apple auth verifier
-func NewAppleAuthVerifier(adminSub string) (*AppleAuthVerifier, error) {
-	keySet, err := jwk.Fetch(context.Background(), appleKeysURL)
-	if err != nil {
+// NewAppleAuthVerifier creates a new Apple auth verifier. adminSubs are the Apple ID subs
+// granted admin access. audience is the app's client ID (bundle or Services ID) that tokens
+// must be issued for; if empty, tokens for any app are accepted, as before audience checking
+// was added.
+func NewAppleAuthVerifier(adminSubs []string, audience string) (*AppleAuthVerifier, error) {
+	v := &AppleAuthVerifier{
+		admins:          NewAdminSet(adminSubs),
+		audience:        audience,
+		refreshInterval: DefaultAppleKeyRefreshInterval,
+		leeway:          DefaultClockSkewLeeway,
+	}
+	if err := v.RefreshKeys(); err != nil {
 		return nil, fmt.Errorf("failed to fetch Apple public keys: %w", err)
 	}
+	return v, nil
+}
+
+// SetKeyRefreshInterval sets how old the cached keys may get before they are refetched
+func (v *AppleAuthVerifier) SetKeyRefreshInterval(interval time.Duration) {
+	v.refreshInterval = interval
+}
+
+// SetLeeway sets the clock skew tolerated when validating exp, nbf and iat
+func (v *AppleAuthVerifier) SetLeeway(leeway time.Duration) {
+	v.leeway = leeway
+}
+
+// keys returns the cached key set and when it was fetched
+func (v *AppleAuthVerifier) keys() (jwk.Set, time.Time) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.keySet, v.fetchedAt
+}
+
+// currentKeys returns keys that can verify a token signed with kid, refetching them when
+// they are older than the refresh interval or don't contain kid. A failed refetch falls back
+// to the cached keys, so an Apple outage doesn't fail tokens the old keys can still verify.
+func (v *AppleAuthVerifier) currentKeys(kid string) jwk.Set {
+	keySet, fetchedAt := v.keys()
+	age := time.Since(fetchedAt)
+
+	stale := age > v.refreshInterval
+	unknownKey := kid != "" && age > minKeyRefreshInterval
+	if unknownKey {
+		_, found := keySet.LookupKeyID(kid)
+		unknownKey = !found
+	}
+	if !stale && !unknownKey {
+		return keySet
+	}
+
+	if err := v.refreshIfFetchedAt(fetchedAt, true); err != nil {
+		fmt.Printf("Failed to refresh Apple public keys, using cached keys: %v\n", err)
+	}
+	keySet, _ = v.keys()
+	return keySet
+}
+
+// RefreshKeys refreshes the Apple public keys from the JWKS endpoint
+func (v *AppleAuthVerifier) RefreshKeys() error {
+	_, fetchedAt := v.keys()
+	return v.refreshIfFetchedAt(fetchedAt, false)
+}
+
+// refreshIfFetchedAt fetches the keys unless another caller already replaced the set that was
+// fetched at fetchedAt while this one waited for the lock. Throttled refreshes are skipped
+// within minKeyRefreshInterval of the previous attempt.
+func (v *AppleAuthVerifier) refreshIfFetchedAt(fetchedAt time.Time, throttle bool) error {
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+
+	if _, current := v.keys(); !current.Equal(fetchedAt) {
+		return nil
+	}
+	if throttle && time.Since(v.lastAttempt) < minKeyRefreshInterval {
+		return nil
+	}
+	v.lastAttempt = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), appleKeysFetchTimeout)
+	defer cancel()
+	keySet, err := jwk.Fetch(ctx, appleKeysURL)
+	if err != nil {
+		return fmt.Errorf("failed to refresh Apple public keys: %w", err)
+	}
+
+	v.mu.Lock()
+	v.keySet = keySet
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+	return nil
+}
 
-	return &AppleAuthVerifier{
-		keySet:   keySet,
-		adminSub: adminSub,
-	}, nil
+// tokenKeyID returns the kid header of a compact JWS, or "" if it has none
+func tokenKeyID(tokenString string) string {
+	message, err := jws.Parse([]byte(tokenString))
+	if err != nil || len(message.Signatures()) == 0 {
+		return ""
+	}
+	return message.Signatures()[0].ProtectedHeaders().KeyID()
 }
 
-// VerifyToken verifies an Apple ID token and returns the claims
+// VerifyToken verifies an Apple ID token and returns the claims. Stale keys, or keys missing
+// the token's kid after a rotation, are refreshed before verifying.
 func (v *AppleAuthVerifier) VerifyToken(tokenString string) (*AppleTokenClaims, error) {
+	keySet := v.currentKeys(tokenKeyID(tokenString))
+
 	// Parse and verify the token
-	token, err := jwt.Parse(
-		[]byte(tokenString),
-		jwt.WithKeySet(v.keySet),
+	options := []jwt.ParseOption{
+		jwt.WithKeySet(keySet),
 		jwt.WithValidate(true),
 		jwt.WithIssuer(appleIssuer),
-	)
+		jwt.WithAcceptableSkew(v.leeway),
+	}
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+	token, err := jwt.Parse([]byte(tokenString), options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to verify token: %w", err)
 	}
@@ -73,16 +234,17 @@ func (v *AppleAuthVerifier) VerifyToken(tokenString string) (*AppleTokenClaims,
 		}
 	}
 
+	// Apple sends these as either "true"/"false" strings or JSON booleans
 	if val, ok := token.Get("email_verified"); ok {
-		if emailVerified, ok2 := val.(string); ok2 {
-			claims.EmailVerified = emailVerified
-		}
+		claims.EmailVerified = boolClaimString(val)
 	}
 
 	if val, ok := token.Get("is_private_email"); ok {
-		if isPrivateEmail, ok2 := val.(string); ok2 {
-			claims.IsPrivateEmail = isPrivateEmail
-		}
+		claims.IsPrivateEmail = boolClaimString(val)
+	}
+
+	if val, ok := token.Get("real_user_status"); ok {
+		claims.RealUserStatus = intClaim(val)
 	}
 
 	if val, ok := token.Get("auth_time"); ok {
@@ -91,6 +253,16 @@ func (v *AppleAuthVerifier) VerifyToken(tokenString string) (*AppleTokenClaims,
 		}
 	}
 
+	if exp := token.Expiration(); !exp.IsZero() {
+		claims.ExpiresAt = exp.Unix()
+	}
+
+	if val, ok := token.Get("nonce"); ok {
+		if nonce, ok2 := val.(string); ok2 {
+			claims.Nonce = nonce
+		}
+	}
+
 	if val, ok := token.Get("nonce_supported"); ok {
 		if nonceSupported, ok2 := val.(bool); ok2 {
 			claims.NonceSupported = nonceSupported
@@ -100,37 +272,92 @@ func (v *AppleAuthVerifier) VerifyToken(tokenString string) (*AppleTokenClaims,
 	return claims, nil
 }
 
-// IsAdmin checks if the user is an admin based on their Apple ID sub
-func (v *AppleAuthVerifier) IsAdmin(sub string) bool {
-	return sub == v.adminSub
-}
-
-// RefreshKeys refreshes the Apple public keys from the JWKS endpoint
-func (v *AppleAuthVerifier) RefreshKeys() error {
-	keySet, err := jwk.Fetch(context.Background(), appleKeysURL)
+// VerifyTokenWithNonce verifies an Apple ID token and checks it was issued for this sign-in.
+// nonce is the raw value the client generated; the client passes its SHA256 hex digest to
+// Apple, which echoes it in the token, so a captured token can't be replayed without it.
+// With a nonce store each nonce is accepted once, so the token can't be replayed even with it.
+func (v *AppleAuthVerifier) VerifyTokenWithNonce(tokenString, nonce string) (*AppleTokenClaims, error) {
+	claims, err := v.VerifyToken(tokenString)
 	if err != nil {
-		return fmt.Errorf("failed to refresh Apple public keys: %w", err)
+		return nil, err
 	}
-	v.keySet = keySet
-	return nil
+
+	digest := sha256.Sum256([]byte(nonce))
+	expected := hex.EncodeToString(digest[:])
+	if nonce == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(claims.Nonce)) != 1 {
+		return nil, ErrNonceMismatch
+	}
+	if err := v.consumeNonce(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// IsAdmin checks if the user is an admin based on their Apple ID sub
+func (v *AppleAuthVerifier) IsAdmin(sub string) bool {
+	return v.admins.Contains(sub)
 }
 
 // AppleUserInfo represents user information from Apple
 type AppleUserInfo struct {
-	Sub           string    `json:"sub"`
-	Email         string    `json:"email"`
-	EmailVerified bool      `json:"email_verified"`
-	IsAdmin       bool      `json:"is_admin"`
-	AuthTime      time.Time `json:"auth_time"`
+	Sub            string         `json:"sub"`
+	Email          string         `json:"email"`
+	EmailVerified  bool           `json:"email_verified"`
+	IsPrivateEmail bool           `json:"is_private_email"`
+	IsPrivateRelay bool           `json:"is_private_relay"` // Email is a Hide My Email relay, not a contact address
+	RealUserStatus RealUserStatus `json:"real_user_status"`
+	IsAdmin        bool           `json:"is_admin"`
+	Scopes         []string       `json:"scopes,omitempty"` // From the user's configured roles
+	AuthTime       time.Time      `json:"auth_time"`
 }
 
 // GetUserInfo extracts user information from Apple token claims
 func (v *AppleAuthVerifier) GetUserInfo(claims *AppleTokenClaims) *AppleUserInfo {
 	return &AppleUserInfo{
-		Sub:           claims.Sub,
-		Email:         claims.Email,
-		EmailVerified: claims.EmailVerified == "true",
-		IsAdmin:       v.IsAdmin(claims.Sub),
-		AuthTime:      time.Unix(claims.AuthTime, 0),
+		Sub:            claims.Sub,
+		Email:          claims.Email,
+		EmailVerified:  claims.EmailVerified == "true",
+		IsPrivateEmail: claims.IsPrivateEmail == "true",
+		IsPrivateRelay: claims.IsPrivateEmail == "true" || IsPrivateRelayEmail(claims.Email),
+		RealUserStatus: RealUserStatus(claims.RealUserStatus),
+		IsAdmin:        v.IsAdmin(claims.Sub),
+		AuthTime:       time.Unix(claims.AuthTime, 0),
+	}
+}
+
+// privateRelayDomain is the domain of Apple's Hide My Email relay addresses
+const privateRelayDomain = "@privaterelay.appleid.com"
+
+// IsPrivateRelayEmail reports whether email is an Apple private relay address. Relay
+// addresses are per-app and can be disabled by the user, so identify users by sub instead.
+func IsPrivateRelayEmail(email string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSpace(email)), privateRelayDomain)
+}
+
+// boolClaimString normalizes a boolean claim sent as a string or JSON boolean to "true"/"false"
+func boolClaimString(val interface{}) string {
+	switch v := val.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return strings.ToLower(v)
+	}
+	return ""
+}
+
+// intClaim converts a numeric claim, which JSON decoding yields as float64, to an int
+func intClaim(val interface{}) int {
+	switch v := val.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
 	}
+	return 0
 }
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const testAppleClientID = "com.example.analytics"
+
+// testAppleSigner signs ID tokens as Apple would, with a key a test verifier trusts
+type testAppleSigner struct {
+	key jwk.Key
+}
+
+// newTestAppleVerifier creates a verifier expecting audience whose cached key set holds only
+// the returned signer's key, so it never fetches Apple's keys
+func newTestAppleVerifier(t *testing.T, audience string) (*AppleAuthVerifier, *testAppleSigner) {
+	t.Helper()
+
+	rawKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	key, err := jwk.FromRaw(rawKey)
+	if err != nil {
+		t.Fatalf("wrap key: %v", err)
+	}
+	key.Set(jwk.KeyIDKey, "test-key")
+	key.Set(jwk.AlgorithmKey, jwa.RS256)
+
+	publicKey, err := jwk.PublicKeyOf(key)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+	keySet := jwk.NewSet()
+	keySet.AddKey(publicKey)
+
+	verifier := &AppleAuthVerifier{
+		admins:          NewAdminSet(nil),
+		audience:        audience,
+		refreshInterval: DefaultAppleKeyRefreshInterval,
+		leeway:          DefaultClockSkewLeeway,
+		keySet:          keySet,
+		fetchedAt:       time.Now(),
+	}
+	return verifier, &testAppleSigner{key: key}
+}
+
+// sign mints an ID token for audience, issued at issuedAt and valid for ten minutes
+func (s *testAppleSigner) sign(t *testing.T, audience string, issuedAt time.Time) string {
+	t.Helper()
+	return s.signWithClaims(t, audience, issuedAt, nil)
+}
+
+// signWithClaims mints an ID token like sign with extra claims added
+func (s *testAppleSigner) signWithClaims(t *testing.T, audience string, issuedAt time.Time, extra map[string]interface{}) string {
+	t.Helper()
+
+	token := jwt.New()
+	token.Set(jwt.IssuerKey, appleIssuer)
+	token.Set(jwt.SubjectKey, "001234.abcdef")
+	token.Set(jwt.AudienceKey, audience)
+	token.Set(jwt.IssuedAtKey, issuedAt)
+	token.Set(jwt.ExpirationKey, issuedAt.Add(10*time.Minute))
+	token.Set("email", "user@example.com")
+	for name, value := range extra {
+		token.Set(name, value)
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, s.key))
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return string(signed)
+}
+
+func TestAppleVerifyTokenAudience(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string // Configured client ID
+		audience string // Token's aud
+		wantErr  bool
+	}{
+		{name: "matching audience", expected: testAppleClientID, audience: testAppleClientID},
+		{name: "other app's audience", expected: testAppleClientID, audience: "com.example.other", wantErr: true},
+		{name: "unset client ID accepts any audience", expected: "", audience: "com.example.other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, signer := newTestAppleVerifier(t, tt.expected)
+			claims, err := verifier.VerifyToken(signer.sign(t, tt.audience, time.Now()))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyToken err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.Sub != "001234.abcdef" || claims.Email != "user@example.com") {
+				t.Errorf("claims = %+v, want the token's subject and email", claims)
+			}
+		})
+	}
+}
+
+func TestAppleVerifyTokenRejectsOtherSigners(t *testing.T) {
+	verifier, _ := newTestAppleVerifier(t, testAppleClientID)
+	_, impostor := newTestAppleVerifier(t, testAppleClientID)
+
+	if _, err := verifier.VerifyToken(impostor.sign(t, testAppleClientID, time.Now())); err == nil {
+		t.Error("VerifyToken accepted a token signed with an untrusted key")
+	}
+}
+
+func TestAppleVerifyTokenClockSkewLeeway(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		leeway   time.Duration
+		issuedAt time.Time // Tokens expire ten minutes after they're issued
+		wantErr  bool
+	}{
+		{name: "issued slightly in the future", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(30 * time.Second)},
+		{name: "issued well in the future", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(5 * time.Minute), wantErr: true},
+		{name: "expired within leeway", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(-10*time.Minute - 30*time.Second)},
+		{name: "expired beyond leeway", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(-15 * time.Minute), wantErr: true},
+		{name: "no leeway", leeway: 0, issuedAt: now.Add(-10*time.Minute - 30*time.Second), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, signer := newTestAppleVerifier(t, testAppleClientID)
+			verifier.SetLeeway(tt.leeway)
+
+			_, err := verifier.VerifyToken(signer.sign(t, testAppleClientID, tt.issuedAt))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("VerifyToken err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppleUserInfoPrivateEmailAndRealUserStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		claims      map[string]interface{}
+		wantPrivate bool
+		wantStatus  RealUserStatus
+	}{
+		{name: "claims absent", wantStatus: RealUserStatusUnsupported},
+		{name: "private relay as string", claims: map[string]interface{}{"is_private_email": "true", "real_user_status": 2}, wantPrivate: true, wantStatus: RealUserStatusLikelyReal},
+		{name: "private relay as boolean", claims: map[string]interface{}{"is_private_email": true, "real_user_status": 1}, wantPrivate: true, wantStatus: RealUserStatusUnknown},
+		{name: "shared email as string", claims: map[string]interface{}{"is_private_email": "false", "real_user_status": 0}, wantStatus: RealUserStatusUnsupported},
+		{name: "shared email as boolean", claims: map[string]interface{}{"is_private_email": false, "real_user_status": 2}, wantStatus: RealUserStatusLikelyReal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, signer := newTestAppleVerifier(t, testAppleClientID)
+			claims, err := verifier.VerifyToken(signer.signWithClaims(t, testAppleClientID, time.Now(), tt.claims))
+			if err != nil {
+				t.Fatalf("VerifyToken: %v", err)
+			}
+
+			info := verifier.GetUserInfo(claims)
+			if info.IsPrivateEmail != tt.wantPrivate {
+				t.Errorf("IsPrivateEmail = %v, want %v", info.IsPrivateEmail, tt.wantPrivate)
+			}
+			if info.RealUserStatus != tt.wantStatus {
+				t.Errorf("RealUserStatus = %s, want %s", info.RealUserStatus, tt.wantStatus)
+			}
+			if info.RealUserStatus.IsLikelyReal() != (tt.wantStatus == RealUserStatusLikelyReal) {
+				t.Errorf("IsLikelyReal = %v for %s", info.RealUserStatus.IsLikelyReal(), info.RealUserStatus)
+			}
+		})
+	}
+}
+
+func TestRealUserStatusString(t *testing.T) {
+	tests := map[RealUserStatus]string{
+		RealUserStatusUnsupported: "unsupported",
+		RealUserStatusUnknown:     "unknown",
+		RealUserStatusLikelyReal:  "likely_real",
+		RealUserStatus(7):         "RealUserStatus(7)",
+	}
+	for status, want := range tests {
+		if got := status.String(); got != want {
+			t.Errorf("RealUserStatus(%d).String() = %q, want %q", int(status), got, want)
+		}
+	}
+}
+
+func TestAppleUserInfoFlagsPrivateRelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims AppleTokenClaims
+		want   bool
+	}{
+		{name: "claimed private", claims: AppleTokenClaims{Sub: "001", Email: "abc123@privaterelay.appleid.com", IsPrivateEmail: "true"}, want: true},
+		{name: "relay domain without the claim", claims: AppleTokenClaims{Sub: "001", Email: "ABC123@PrivateRelay.AppleID.com"}, want: true},
+		{name: "normal email", claims: AppleTokenClaims{Sub: "001", Email: "user@example.com", IsPrivateEmail: "false"}},
+		{name: "relay domain as a subdomain", claims: AppleTokenClaims{Sub: "001", Email: "user@privaterelay.appleid.com.example.com"}},
+	}
+
+	verifier, _ := newTestAppleVerifier(t, testAppleClientID)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := verifier.GetUserInfo(&tt.claims)
+			if info.IsPrivateRelay != tt.want {
+				t.Errorf("IsPrivateRelay = %v, want %v", info.IsPrivateRelay, tt.want)
+			}
+			if info.Sub != tt.claims.Sub || info.Email != tt.claims.Email {
+				t.Errorf("user info = %+v, want the token's sub and email", info)
+			}
+		})
+	}
+}
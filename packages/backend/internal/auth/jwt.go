@@ -1,6 +1,12 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
 	"fmt"
 	"time"
 
@@ -10,46 +16,150 @@ import (
 // SessionClaims represents the JWT claims for user sessions
 type SessionClaims struct {
 	jwt.RegisteredClaims
-	UserID  string `json:"user_id"`
-	Email   string `json:"email"`
-	IsAdmin bool   `json:"is_admin"`
+	UserID         string `json:"user_id"`
+	Email          string `json:"email"`
+	IsPrivateRelay bool   `json:"is_private_relay,omitempty"`
+	IsAdmin        bool   `json:"is_admin"`
+
+	// Scopes granted by the user's configured roles; IsAdmin implies every scope
+	Scopes []string `json:"scopes,omitempty"`
+
+	// TokenType distinguishes access from refresh tokens; empty on tokens issued before
+	// refresh tokens existed, which are treated as access tokens
+	TokenType string `json:"typ,omitempty"`
+}
+
+// Session token types
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
+
+// DefaultRefreshTTL is how long a refresh token stays valid
+const DefaultRefreshTTL = 30 * 24 * time.Hour
+
+// DefaultClockSkewLeeway is how far exp, nbf and iat may be off before a token is rejected,
+// allowing for clock drift between the instance that signed a token and the one validating it
+const DefaultClockSkewLeeway = 60 * time.Second
+
+// ErrWrongTokenType is returned when a refresh token is used as an access token or vice versa
+var ErrWrongTokenType = errors.New("wrong token type")
+
+// TokenPair is a short-lived access token with the refresh token used to replace it
+type TokenPair struct {
+	AccessToken      string
+	RefreshToken     string
+	ExpiresIn        time.Duration
+	RefreshExpiresIn time.Duration
 }
 
 // JWTManager handles JWT creation and validation
 type JWTManager struct {
-	secretKey []byte
-	issuer    string
-	ttl       time.Duration
+	method     jwt.SigningMethod
+	signKey    interface{}
+	verifyKey  interface{}
+	issuer     string
+	ttl        time.Duration
+	refreshTTL time.Duration
+	leeway     time.Duration
+
+	// Revoked token IDs; nil when revocation is not configured
+	revocations *revocationList
 }
 
-// NewJWTManager creates a new JWT manager
+// NewJWTManager creates a new JWT manager that signs and validates with an HS256 shared secret
 func NewJWTManager(secretKey []byte, issuer string, ttl time.Duration) *JWTManager {
 	return &JWTManager{
-		secretKey: secretKey,
-		issuer:    issuer,
-		ttl:       ttl,
+		method:     jwt.SigningMethodHS256,
+		signKey:    secretKey,
+		verifyKey:  secretKey,
+		issuer:     issuer,
+		ttl:        ttl,
+		refreshTTL: DefaultRefreshTTL,
+		leeway:     DefaultClockSkewLeeway,
+	}
+}
+
+// NewAsymmetricJWTManager creates a JWT manager that signs with privateKey and validates with
+// publicKey, so services that only verify tokens never hold signing material. ECDSA P-256 keys
+// use ES256 and RSA keys use RS256. privateKey may be nil for a verify-only manager.
+func NewAsymmetricJWTManager(privateKey crypto.PrivateKey, publicKey crypto.PublicKey, issuer string, ttl time.Duration) (*JWTManager, error) {
+	var method jwt.SigningMethod
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key, got %s", key.Curve.Params().Name)
+		}
+		method = jwt.SigningMethodES256
+		if privateKey != nil {
+			if _, ok := privateKey.(*ecdsa.PrivateKey); !ok {
+				return nil, fmt.Errorf("private key type %T does not match ECDSA public key", privateKey)
+			}
+		}
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+		if privateKey != nil {
+			if _, ok := privateKey.(*rsa.PrivateKey); !ok {
+				return nil, fmt.Errorf("private key type %T does not match RSA public key", privateKey)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+
+	return &JWTManager{
+		method:     method,
+		signKey:    privateKey,
+		verifyKey:  publicKey,
+		issuer:     issuer,
+		ttl:        ttl,
+		refreshTTL: DefaultRefreshTTL,
+		leeway:     DefaultClockSkewLeeway,
+	}, nil
+}
+
+// SetRefreshTTL sets how long refresh tokens stay valid
+func (m *JWTManager) SetRefreshTTL(ttl time.Duration) {
+	m.refreshTTL = ttl
+}
+
+// SetLeeway sets the clock skew tolerated when validating exp, nbf and iat
+func (m *JWTManager) SetLeeway(leeway time.Duration) {
+	m.leeway = leeway
+}
+
+// TTL returns the access token lifetime
+func (m *JWTManager) TTL() time.Duration {
+	return m.ttl
+}
+
+// errNoSigningKey is returned when a verify-only manager is asked to issue a token
+var errNoSigningKey = errors.New("JWT manager has no signing key")
+
+// sign signs claims with the manager's algorithm and key
+func (m *JWTManager) sign(claims jwt.Claims) (string, error) {
+	if m.signKey == nil {
+		return "", errNoSigningKey
 	}
+	return jwt.NewWithClaims(m.method, claims).SignedString(m.signKey)
+}
+
+// Algorithm returns the JWS algorithm the manager signs and accepts, e.g. "HS256"
+func (m *JWTManager) Algorithm() string {
+	return m.method.Alg()
 }
 
 // GenerateToken creates a new JWT token for a user session
 func (m *JWTManager) GenerateToken(userInfo *AppleUserInfo) (string, error) {
-	now := time.Now()
 	claims := SessionClaims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    m.issuer,
-			Subject:   userInfo.Sub,
-			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
-			IssuedAt:  jwt.NewNumericDate(now),
-			NotBefore: jwt.NewNumericDate(now),
-			ID:        GenerateSessionID(),
-		},
-		UserID:  userInfo.Sub,
-		Email:   userInfo.Email,
-		IsAdmin: userInfo.IsAdmin,
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(m.secretKey)
+		UserID:         userInfo.Sub,
+		Email:          userInfo.Email,
+		IsPrivateRelay: userInfo.IsPrivateRelay,
+		IsAdmin:        userInfo.IsAdmin,
+		Scopes:         userInfo.Scopes,
+	}
+
+	tokenString, err := m.issue(claims, TokenTypeAccess, m.ttl)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
@@ -57,14 +167,75 @@ func (m *JWTManager) GenerateToken(userInfo *AppleUserInfo) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// GenerateTokenPair creates an access token and a long-lived refresh token for a user session
+func (m *JWTManager) GenerateTokenPair(userInfo *AppleUserInfo) (*TokenPair, error) {
+	claims := SessionClaims{
+		UserID:         userInfo.Sub,
+		Email:          userInfo.Email,
+		IsPrivateRelay: userInfo.IsPrivateRelay,
+		IsAdmin:        userInfo.IsAdmin,
+		Scopes:         userInfo.Scopes,
+	}
+	return m.issuePair(claims)
+}
+
+// issuePair signs an access and refresh token carrying the same user claims
+func (m *JWTManager) issuePair(claims SessionClaims) (*TokenPair, error) {
+	accessToken, err := m.issue(claims, TokenTypeAccess, m.ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign token: %w", err)
+	}
+
+	refreshToken, err := m.issue(claims, TokenTypeRefresh, m.refreshTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign refresh token: %w", err)
+	}
+
+	return &TokenPair{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		ExpiresIn:        m.ttl,
+		RefreshExpiresIn: m.refreshTTL,
+	}, nil
+}
+
+// issue signs the user claims as a new token of the given type with its own ID and lifetime
+func (m *JWTManager) issue(claims SessionClaims, tokenType string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		Issuer:    m.issuer,
+		Subject:   claims.UserID,
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ID:        GenerateSessionID(),
+	}
+	claims.TokenType = tokenType
+	return m.sign(claims)
+}
+
+// ValidateToken validates an access token and returns the claims. Refresh tokens are rejected.
 func (m *JWTManager) ValidateToken(tokenString string) (*SessionClaims, error) {
+	claims, err := m.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeAccess && claims.TokenType != "" {
+		return nil, ErrWrongTokenType
+	}
+	return claims, nil
+}
+
+// parse validates a token of any type and returns the claims. Only tokens signed with the
+// manager's own algorithm are accepted, so an HS256 token can't be verified using an
+// asymmetric manager's public key as the HMAC secret (algorithm confusion).
+func (m *JWTManager) parse(tokenString string) (*SessionClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &SessionClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		if token.Method.Alg() != m.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return m.secretKey, nil
-	})
+		return m.verifyKey, nil
+	}, jwt.WithValidMethods([]string{m.method.Alg()}), jwt.WithLeeway(m.leeway), jwt.WithIssuedAt())
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse token: %w", err)
@@ -80,23 +251,45 @@ func (m *JWTManager) ValidateToken(tokenString string) (*SessionClaims, error) {
 		return nil, fmt.Errorf("invalid token issuer")
 	}
 
+	revoked, err := m.isRevoked(claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
 	return claims, nil
 }
 
-// RefreshToken creates a new token with extended expiration
-func (m *JWTManager) RefreshToken(claims *SessionClaims) (string, error) {
-	now := time.Now()
-	claims.ExpiresAt = jwt.NewNumericDate(now.Add(m.ttl))
-	claims.IssuedAt = jwt.NewNumericDate(now)
-	claims.ID = GenerateSessionID()
+// RefreshToken exchanges a refresh token for a new access token and a rotated refresh
+// token. The presented refresh token is revoked with a conditional write, so each one can be
+// used only once even by concurrent requests, the losers getting ErrTokenRevoked; without a
+// revocation store it stays valid until it expires.
+func (m *JWTManager) RefreshToken(refreshToken string) (*TokenPair, error) {
+	claims, err := m.parse(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != TokenTypeRefresh {
+		return nil, ErrWrongTokenType
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(m.secretKey)
+	if m.revocations != nil {
+		if err := m.revokeOnce(claims.ID, claims.ExpiresAt.Time); err != nil {
+			if errors.Is(err, ErrTokenRevoked) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+	}
+
+	pair, err := m.issuePair(*claims)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign refreshed token: %w", err)
+		return nil, fmt.Errorf("failed to sign refreshed token: %w", err)
 	}
 
-	return tokenString, nil
+	return pair, nil
 }
 
 // GenerateSessionID creates a unique session identifier
@@ -104,12 +297,25 @@ func GenerateSessionID() string {
 	return fmt.Sprintf("%d-%s", time.Now().Unix(), generateRandomString(16))
 }
 
-// generateRandomString creates a random string of specified length
+// generateRandomString creates a random string of specified length. Token IDs are revoked
+// individually, so they must not collide even when minted in the same instant.
 func generateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[time.Now().UnixNano()%int64(len(charset))]
+	// Bytes at or above the largest multiple of len(charset) are discarded so every
+	// character is equally likely
+	const maxByte = 256 - 256%len(charset)
+
+	b := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(b) < length {
+		if _, err := rand.Read(buf); err != nil {
+			panic(fmt.Sprintf("crypto/rand failed: %v", err))
+		}
+		for _, r := range buf {
+			if int(r) < maxByte && len(b) < length {
+				b = append(b, charset[int(r)%len(charset)])
+			}
+		}
 	}
 	return string(b)
-}
\ No newline at end of file
+}
@@ -0,0 +1,239 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// memoryRevocationStore is a RevocationStore held in a map
+type memoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func newMemoryRevocationStore() *memoryRevocationStore {
+	return &memoryRevocationStore{revoked: make(map[string]time.Time)}
+}
+
+func (s *memoryRevocationStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[tokenID] = expiresAt
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[tokenID]
+	return ok, nil
+}
+
+func (s *memoryRevocationStore) RevokeOnce(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.revoked[tokenID]; ok {
+		return false, nil
+	}
+	s.revoked[tokenID] = expiresAt
+	return true, nil
+}
+
+func newTestJWTManager(t *testing.T) *JWTManager {
+	t.Helper()
+	manager := NewJWTManager([]byte("test-secret-key-of-at-least-32-bytes"), "central-analytics", time.Hour)
+	manager.SetRevocationStore(newMemoryRevocationStore())
+	return manager
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	manager := newTestJWTManager(t)
+	pair, err := manager.GenerateTokenPair(&AppleUserInfo{Sub: "user", Email: "user@example.com"})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	refreshed, err := manager.RefreshToken(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if _, err := manager.ValidateToken(refreshed.AccessToken); err != nil {
+		t.Errorf("refreshed access token is invalid: %v", err)
+	}
+
+	if _, err := manager.RefreshToken(pair.RefreshToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("reusing a refresh token: err = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := manager.RefreshToken(refreshed.RefreshToken); err != nil {
+		t.Errorf("rotated refresh token: %v", err)
+	}
+}
+
+func TestRefreshTokenSingleUseUnderConcurrency(t *testing.T) {
+	manager := newTestJWTManager(t)
+	pair, err := manager.GenerateTokenPair(&AppleUserInfo{Sub: "user"})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	const requests = 20
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = manager.RefreshToken(pair.RefreshToken)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, ErrTokenRevoked):
+			t.Errorf("concurrent refresh: err = %v, want ErrTokenRevoked", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("%d concurrent refreshes succeeded, want 1", succeeded)
+	}
+}
+
+func TestRefreshTokenRejectsAccessToken(t *testing.T) {
+	manager := newTestJWTManager(t)
+	pair, err := manager.GenerateTokenPair(&AppleUserInfo{Sub: "user"})
+	if err != nil {
+		t.Fatalf("GenerateTokenPair: %v", err)
+	}
+
+	if _, err := manager.RefreshToken(pair.AccessToken); !errors.Is(err, ErrWrongTokenType) {
+		t.Errorf("err = %v, want ErrWrongTokenType", err)
+	}
+}
+
+// signSessionToken signs an access token issued at issuedAt that expires at expiresAt
+func signSessionToken(t *testing.T, manager *JWTManager, issuedAt, expiresAt time.Time) string {
+	t.Helper()
+	token, err := manager.sign(SessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "central-analytics",
+			Subject:   "user",
+			IssuedAt:  jwt.NewNumericDate(issuedAt),
+			NotBefore: jwt.NewNumericDate(issuedAt),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        GenerateSessionID(),
+		},
+		UserID:    "user",
+		TokenType: TokenTypeAccess,
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func TestValidateTokenClockSkewLeeway(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		leeway    time.Duration
+		issuedAt  time.Time
+		expiresAt time.Time
+		wantErr   bool
+	}{
+		{name: "current", leeway: DefaultClockSkewLeeway, issuedAt: now, expiresAt: now.Add(time.Hour)},
+		{name: "issued slightly in the future", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(30 * time.Second), expiresAt: now.Add(time.Hour)},
+		{name: "issued well in the future", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(5 * time.Minute), expiresAt: now.Add(time.Hour), wantErr: true},
+		{name: "expired within leeway", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(-time.Hour), expiresAt: now.Add(-30 * time.Second)},
+		{name: "expired beyond leeway", leeway: DefaultClockSkewLeeway, issuedAt: now.Add(-time.Hour), expiresAt: now.Add(-5 * time.Minute), wantErr: true},
+		{name: "no leeway", leeway: 0, issuedAt: now.Add(30 * time.Second), expiresAt: now.Add(time.Hour), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewJWTManager([]byte("test-secret-key-of-at-least-32-bytes"), "central-analytics", time.Hour)
+			manager.SetLeeway(tt.leeway)
+
+			_, err := manager.ValidateToken(signSessionToken(t, manager, tt.issuedAt, tt.expiresAt))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateSessionIDUniqueAndUniform(t *testing.T) {
+	const (
+		count   = 10_000
+		charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+		length  = 16
+	)
+
+	seen := make(map[string]bool, count)
+	counts := make(map[rune]int, len(charset))
+	var positions [length]map[rune]bool
+	for i := range positions {
+		positions[i] = make(map[rune]bool, len(charset))
+	}
+
+	for i := 0; i < count; i++ {
+		id := GenerateSessionID()
+		if seen[id] {
+			t.Fatalf("duplicate session ID %q after %d IDs", id, i)
+		}
+		seen[id] = true
+
+		unix, random, ok := strings.Cut(id, "-")
+		if _, err := strconv.ParseInt(unix, 10, 64); !ok || err != nil || len(random) != length {
+			t.Fatalf("session ID %q is not <unix seconds>-<%d characters>", id, length)
+		}
+		for j, c := range random {
+			if !strings.ContainsRune(charset, c) {
+				t.Fatalf("session ID %q contains %q outside the charset", id, c)
+			}
+			counts[c]++
+			positions[j][c] = true
+		}
+	}
+
+	// Each character is expected count*length/62 ≈ 2581 times with a standard deviation
+	// of about 50, so 15% either way only fails for a skewed generator
+	expected := float64(count*length) / float64(len(charset))
+	for _, c := range charset {
+		if got := float64(counts[c]); got < expected*0.85 || got > expected*1.15 {
+			t.Errorf("character %q appeared %v times, want about %.0f", c, got, expected)
+		}
+	}
+	for j, chars := range positions {
+		if len(chars) != len(charset) {
+			t.Errorf("position %d used %d of %d characters", j, len(chars), len(charset))
+		}
+	}
+}
+
+func TestSessionTokenCarriesPrivateRelay(t *testing.T) {
+	manager := newTestJWTManager(t)
+	for _, relay := range []bool{true, false} {
+		token, err := manager.GenerateToken(&AppleUserInfo{Sub: "user", Email: "abc@privaterelay.appleid.com", IsPrivateRelay: relay})
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		claims, err := manager.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if claims.IsPrivateRelay != relay || claims.UserID != "user" {
+			t.Errorf("claims = %+v, want user with IsPrivateRelay %v", claims, relay)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AuthOutcome classifies the result of an authentication attempt
+type AuthOutcome string
+
+// Authentication outcomes tracked by AuthMetrics
+const (
+	OutcomeSuccess          AuthOutcome = "success"
+	OutcomeMissingToken     AuthOutcome = "missing_token"
+	OutcomeMalformed        AuthOutcome = "malformed"
+	OutcomeInvalidSignature AuthOutcome = "invalid_signature"
+	OutcomeExpired          AuthOutcome = "expired"
+	OutcomeInvalid          AuthOutcome = "invalid"
+	OutcomeForbidden        AuthOutcome = "forbidden"
+	OutcomeRevoked          AuthOutcome = "revoked"
+)
+
+var authOutcomes = []AuthOutcome{
+	OutcomeSuccess,
+	OutcomeMissingToken,
+	OutcomeMalformed,
+	OutcomeInvalidSignature,
+	OutcomeExpired,
+	OutcomeInvalid,
+	OutcomeForbidden,
+	OutcomeRevoked,
+}
+
+// AuthMetrics counts authentication attempts by outcome. A nil *AuthMetrics discards records.
+type AuthMetrics struct {
+	counters map[AuthOutcome]*atomic.Int64
+}
+
+// NewAuthMetrics creates zeroed counters for every outcome
+func NewAuthMetrics() *AuthMetrics {
+	counters := make(map[AuthOutcome]*atomic.Int64, len(authOutcomes))
+	for _, outcome := range authOutcomes {
+		counters[outcome] = &atomic.Int64{}
+	}
+	return &AuthMetrics{counters: counters}
+}
+
+// Record increments the counter for an outcome
+func (m *AuthMetrics) Record(outcome AuthOutcome) {
+	if m == nil {
+		return
+	}
+	if counter, ok := m.counters[outcome]; ok {
+		counter.Add(1)
+	}
+}
+
+// RecordValidation records the outcome of a token validation and returns it
+func (m *AuthMetrics) RecordValidation(err error) AuthOutcome {
+	outcome := ClassifyValidationError(err)
+	m.Record(outcome)
+	return outcome
+}
+
+// Snapshot returns the current count for every outcome
+func (m *AuthMetrics) Snapshot() map[AuthOutcome]int64 {
+	snapshot := make(map[AuthOutcome]int64, len(authOutcomes))
+	for _, outcome := range authOutcomes {
+		if m != nil {
+			snapshot[outcome] = m.counters[outcome].Load()
+		} else {
+			snapshot[outcome] = 0
+		}
+	}
+	return snapshot
+}
+
+// WritePrometheus writes the counters in the Prometheus text exposition format
+func (m *AuthMetrics) WritePrometheus(w io.Writer) error {
+	snapshot := m.Snapshot()
+
+	if _, err := fmt.Fprint(w,
+		"# HELP central_analytics_auth_attempts_total Authentication attempts by outcome.\n",
+		"# TYPE central_analytics_auth_attempts_total counter\n",
+	); err != nil {
+		return err
+	}
+	for _, outcome := range authOutcomes {
+		if _, err := fmt.Fprintf(w, "central_analytics_auth_attempts_total{outcome=%q} %d\n", outcome, snapshot[outcome]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClassifyValidationError maps a token or API key validation error to an outcome
+func ClassifyValidationError(err error) AuthOutcome {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, ErrTokenRevoked), errors.Is(err, ErrAPIKeyRevoked):
+		return OutcomeRevoked
+	case errors.Is(err, jwt.ErrTokenExpired), errors.Is(err, ErrAPIKeyExpired):
+		return OutcomeExpired
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return OutcomeInvalidSignature
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return OutcomeMalformed
+	default:
+		return OutcomeInvalid
+	}
+}
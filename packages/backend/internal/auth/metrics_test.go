@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClassifyValidationError(t *testing.T) {
+	manager := newTestJWTManager(t)
+	now := time.Now()
+	valid := signSessionToken(t, manager, now, now.Add(time.Hour))
+	expired := signSessionToken(t, manager, now.Add(-2*time.Hour), now.Add(-time.Hour))
+	forged := signSessionToken(t, newTestJWTManagerWithKey(t, "another-secret-key-of-at-least-32-bytes"), now, now.Add(time.Hour))
+
+	tests := []struct {
+		name  string
+		token string
+		want  AuthOutcome
+	}{
+		{name: "valid", token: valid, want: OutcomeSuccess},
+		{name: "expired", token: expired, want: OutcomeExpired},
+		{name: "signed with another key", token: forged, want: OutcomeInvalidSignature},
+		{name: "malformed", token: "not-a-jwt", want: OutcomeMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := manager.ValidateToken(tt.token)
+			if got := ClassifyValidationError(err); got != tt.want {
+				t.Errorf("ClassifyValidationError(%v) = %s, want %s", err, got, tt.want)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		err  error
+		want AuthOutcome
+	}{
+		{err: fmt.Errorf("validate: %w", ErrTokenRevoked), want: OutcomeRevoked},
+		{err: ErrAPIKeyRevoked, want: OutcomeRevoked},
+		{err: ErrAPIKeyExpired, want: OutcomeExpired},
+		{err: ErrWrongTokenType, want: OutcomeInvalid},
+		{err: errors.New("unknown"), want: OutcomeInvalid},
+	}
+	for _, tt := range errorTests {
+		if got := ClassifyValidationError(tt.err); got != tt.want {
+			t.Errorf("ClassifyValidationError(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func newTestJWTManagerWithKey(t *testing.T, key string) *JWTManager {
+	t.Helper()
+	return NewJWTManager([]byte(key), "central-analytics", time.Hour)
+}
+
+func TestAuthMetricsCounts(t *testing.T) {
+	metrics := NewAuthMetrics()
+	metrics.Record(OutcomeSuccess)
+	metrics.Record(OutcomeSuccess)
+	metrics.Record(OutcomeForbidden)
+	if outcome := metrics.RecordValidation(jwt.ErrTokenExpired); outcome != OutcomeExpired {
+		t.Errorf("RecordValidation = %s, want %s", outcome, OutcomeExpired)
+	}
+	metrics.Record(AuthOutcome("unknown"))
+
+	snapshot := metrics.Snapshot()
+	want := map[AuthOutcome]int64{OutcomeSuccess: 2, OutcomeForbidden: 1, OutcomeExpired: 1}
+	for _, outcome := range authOutcomes {
+		if snapshot[outcome] != want[outcome] {
+			t.Errorf("%s = %d, want %d", outcome, snapshot[outcome], want[outcome])
+		}
+	}
+	if len(snapshot) != len(authOutcomes) {
+		t.Errorf("snapshot has %d outcomes, want %d", len(snapshot), len(authOutcomes))
+	}
+
+	var out strings.Builder
+	if err := metrics.WritePrometheus(&out); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	for _, line := range []string{
+		"# TYPE central_analytics_auth_attempts_total counter",
+		`central_analytics_auth_attempts_total{outcome="success"} 2`,
+		`central_analytics_auth_attempts_total{outcome="revoked"} 0`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Prometheus output is missing %q:\n%s", line, out.String())
+		}
+	}
+
+	// A nil *AuthMetrics discards records and reports zeros
+	var discarded *AuthMetrics
+	discarded.Record(OutcomeSuccess)
+	if got := discarded.Snapshot()[OutcomeSuccess]; got != 0 {
+		t.Errorf("nil metrics counted %d successes", got)
+	}
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNonceReused is returned when an Apple ID token's nonce has already been used to sign in
+var ErrNonceReused = errors.New("token nonce has already been used")
+
+// DefaultNonceTTL is how long a consumed nonce is remembered when the token carries no expiry.
+// Apple ID tokens are valid for ten minutes.
+const DefaultNonceTTL = 10 * time.Minute
+
+// nonceCheckTimeout bounds the nonce store call made on every sign-in
+const nonceCheckTimeout = 2 * time.Second
+
+// NonceStore records consumed sign-in nonces until the tokens carrying them expire
+type NonceStore interface {
+	// Consume marks nonce as used until expiresAt. It reports false if the nonce was already
+	// used, and must check and record atomically so concurrent replays can't both succeed.
+	Consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error)
+}
+
+// MemoryNonceStore is a NonceStore held in process memory. It only stops replays against the
+// same instance; use a shared store when several instances verify sign-ins.
+type MemoryNonceStore struct {
+	mu   sync.Mutex
+	used map[string]time.Time
+}
+
+// NewMemoryNonceStore creates an empty in-memory nonce store
+func NewMemoryNonceStore() *MemoryNonceStore {
+	return &MemoryNonceStore{used: make(map[string]time.Time)}
+}
+
+// Consume records nonce, dropping entries whose tokens have since expired
+func (s *MemoryNonceStore) Consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for used, expiry := range s.used {
+		if now.After(expiry) {
+			delete(s.used, used)
+		}
+	}
+	if _, ok := s.used[nonce]; ok {
+		return false, nil
+	}
+	s.used[nonce] = expiresAt
+	return true, nil
+}
+
+// SetNonceStore makes nonces single-use: VerifyTokenWithNonce records each nonce in store and
+// rejects tokens whose nonce was already used. Without a store, a token can be verified with
+// its nonce any number of times until it expires.
+func (v *AppleAuthVerifier) SetNonceStore(store NonceStore) {
+	v.nonces = store
+}
+
+// consumeNonce records a token's nonce as used. The store only ever sees the hashed nonce
+// from the token, never the raw value. If the store can't be reached the sign-in is refused,
+// so an outage never lets a replay through.
+func (v *AppleAuthVerifier) consumeNonce(claims *AppleTokenClaims) error {
+	if v.nonces == nil {
+		return nil
+	}
+
+	// Remember the nonce for as long as the token could still pass verification
+	expiresAt := time.Now().Add(DefaultNonceTTL)
+	if claims.ExpiresAt > 0 {
+		expiresAt = time.Unix(claims.ExpiresAt, 0)
+	}
+	expiresAt = expiresAt.Add(v.leeway)
+
+	ctx, cancel := context.WithTimeout(context.Background(), nonceCheckTimeout)
+	defer cancel()
+	fresh, err := v.nonces.Consume(ctx, claims.Nonce, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to record nonce: %w", err)
+	}
+	if !fresh {
+		return ErrNonceReused
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// hashedNonce is the digest of nonce the client passes to Apple, which Apple echoes in the token
+func hashedNonce(nonce string) string {
+	digest := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(digest[:])
+}
+
+// recordingNonceStore wraps a MemoryNonceStore, recording each expiry it is given, or fails
+// every call with err when set
+type recordingNonceStore struct {
+	*MemoryNonceStore
+	err     error
+	expires []time.Time
+}
+
+func (s *recordingNonceStore) Consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	if s.err != nil {
+		return false, s.err
+	}
+	s.expires = append(s.expires, expiresAt)
+	return s.MemoryNonceStore.Consume(ctx, nonce, expiresAt)
+}
+
+func TestVerifyTokenWithNonceIsSingleUse(t *testing.T) {
+	verifier, signer := newTestAppleVerifier(t, testAppleClientID)
+	store := &recordingNonceStore{MemoryNonceStore: NewMemoryNonceStore()}
+	verifier.SetNonceStore(store)
+
+	issuedAt := time.Now().Add(-time.Minute).Truncate(time.Second)
+	token := signer.signWithClaims(t, testAppleClientID, issuedAt, map[string]interface{}{"nonce": hashedNonce("raw-nonce")})
+
+	// The wrong nonce is rejected without using up the token's nonce
+	if _, err := verifier.VerifyTokenWithNonce(token, "other-nonce"); !errors.Is(err, ErrNonceMismatch) {
+		t.Fatalf("wrong nonce: err = %v, want ErrNonceMismatch", err)
+	}
+
+	if _, err := verifier.VerifyTokenWithNonce(token, "raw-nonce"); err != nil {
+		t.Fatalf("first sign-in: %v", err)
+	}
+	if _, err := verifier.VerifyTokenWithNonce(token, "raw-nonce"); !errors.Is(err, ErrNonceReused) {
+		t.Errorf("replay: err = %v, want ErrNonceReused", err)
+	}
+
+	// The nonce is remembered until the token stops verifying, leeway included
+	if want := issuedAt.Add(10 * time.Minute).Add(DefaultClockSkewLeeway); len(store.expires) == 0 || !store.expires[0].Equal(want) {
+		t.Errorf("nonce remembered until %v, want %v", store.expires, want)
+	}
+
+	// A token for another sign-in has its own nonce
+	other := signer.signWithClaims(t, testAppleClientID, issuedAt, map[string]interface{}{"nonce": hashedNonce("next-nonce")})
+	if _, err := verifier.VerifyTokenWithNonce(other, "next-nonce"); err != nil {
+		t.Errorf("another sign-in: %v", err)
+	}
+}
+
+func TestVerifyTokenWithNonceWithoutStore(t *testing.T) {
+	verifier, signer := newTestAppleVerifier(t, testAppleClientID)
+	token := signer.signWithClaims(t, testAppleClientID, time.Now(), map[string]interface{}{"nonce": hashedNonce("raw-nonce")})
+
+	for i := 0; i < 2; i++ {
+		if _, err := verifier.VerifyTokenWithNonce(token, "raw-nonce"); err != nil {
+			t.Errorf("sign-in %d without a nonce store: %v", i+1, err)
+		}
+	}
+}
+
+func TestVerifyTokenWithNonceStoreUnavailable(t *testing.T) {
+	verifier, signer := newTestAppleVerifier(t, testAppleClientID)
+	verifier.SetNonceStore(&recordingNonceStore{err: errors.New("table unavailable")})
+	token := signer.signWithClaims(t, testAppleClientID, time.Now(), map[string]interface{}{"nonce": hashedNonce("raw-nonce")})
+
+	// An outage refuses the sign-in rather than letting a possible replay through
+	if _, err := verifier.VerifyTokenWithNonce(token, "raw-nonce"); err == nil || errors.Is(err, ErrNonceReused) {
+		t.Errorf("err = %v, want the store failure", err)
+	}
+}
+
+func TestMemoryNonceStore(t *testing.T) {
+	store := NewMemoryNonceStore()
+	ctx := context.Background()
+
+	// Concurrent replays of one nonce: exactly one is accepted
+	var accepted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if fresh, err := store.Consume(ctx, "nonce", time.Now().Add(time.Minute)); err == nil && fresh {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := accepted.Load(); got != 1 {
+		t.Errorf("%d concurrent uses of one nonce accepted, want 1", got)
+	}
+
+	// Expired records are dropped, so the store doesn't grow without bound
+	if fresh, _ := store.Consume(ctx, "expired", time.Now().Add(-time.Second)); !fresh {
+		t.Fatal("new nonce was rejected")
+	}
+	if fresh, _ := store.Consume(ctx, "expired", time.Now().Add(time.Minute)); !fresh {
+		t.Error("nonce whose token had expired is still rejected")
+	}
+	if len(store.used) != 2 {
+		t.Errorf("store holds %d nonces, want 2", len(store.used))
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrTokenRevoked is returned by ValidateToken for tokens that have been revoked
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// revocationCheckTimeout bounds the revocation lookup made on every validation
+const revocationCheckTimeout = 2 * time.Second
+
+// RevocationStore persists revoked token IDs until the tokens would have expired anyway
+type RevocationStore interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+
+	// RevokeOnce revokes tokenID until expiresAt, reporting false if it was already revoked.
+	// It must check and record atomically so concurrent uses of a token can't both succeed.
+	RevokeOnce(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error)
+}
+
+// revocationList pairs a store with a local cache of IDs known to be revoked. Only positive
+// results are cached, since another instance may revoke a token at any time.
+type revocationList struct {
+	store RevocationStore
+
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// SetRevocationStore enables token revocation backed by store. Without a store, Revoke
+// fails and every token is treated as not revoked.
+func (m *JWTManager) SetRevocationStore(store RevocationStore) {
+	m.revocations = &revocationList{store: store, revoked: make(map[string]time.Time)}
+}
+
+// Revoke blacklists a token ID until expiresAt, after which the token is invalid regardless
+func (m *JWTManager) Revoke(tokenID string, expiresAt time.Time) error {
+	if m.revocations == nil {
+		return fmt.Errorf("token revocation is not configured")
+	}
+	if tokenID == "" {
+		return fmt.Errorf("token has no ID to revoke")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationCheckTimeout)
+	defer cancel()
+	if err := m.revocations.store.Revoke(ctx, tokenID, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	m.revocations.remember(tokenID, expiresAt)
+	return nil
+}
+
+// revokeOnce revokes a token ID unless it was already revoked, returning ErrTokenRevoked if
+// it was, so only one of several concurrent uses of a single-use token succeeds
+func (m *JWTManager) revokeOnce(tokenID string, expiresAt time.Time) error {
+	if m.revocations == nil {
+		return fmt.Errorf("token revocation is not configured")
+	}
+	if tokenID == "" {
+		return fmt.Errorf("token has no ID to revoke")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationCheckTimeout)
+	defer cancel()
+	fresh, err := m.revocations.store.RevokeOnce(ctx, tokenID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	m.revocations.remember(tokenID, expiresAt)
+	if !fresh {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// IsRevoked reports whether a token ID has been revoked. If the store cannot be reached the
+// token is reported as revoked, so an outage never lets a revoked token through.
+func (m *JWTManager) IsRevoked(tokenID string) bool {
+	revoked, err := m.isRevoked(tokenID)
+	return revoked || err != nil
+}
+
+// isRevoked checks the local cache and then the store
+func (m *JWTManager) isRevoked(tokenID string) (bool, error) {
+	if m.revocations == nil || tokenID == "" {
+		return false, nil
+	}
+	if m.revocations.cached(tokenID) {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), revocationCheckTimeout)
+	defer cancel()
+	revoked, err := m.revocations.store.IsRevoked(ctx, tokenID)
+	if err != nil {
+		return false, err
+	}
+	if revoked {
+		// The exact expiry isn't known here; tokens never outlive the longer of the two TTLs
+		ttl := m.ttl
+		if m.refreshTTL > ttl {
+			ttl = m.refreshTTL
+		}
+		m.revocations.remember(tokenID, time.Now().Add(ttl))
+	}
+	return revoked, nil
+}
+
+// remember caches a revoked ID, dropping entries for tokens that have since expired
+func (l *revocationList) remember(tokenID string, expiresAt time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for id, expiry := range l.revoked {
+		if now.After(expiry) {
+			delete(l.revoked, id)
+		}
+	}
+	l.revoked[tokenID] = expiresAt
+}
+
+// cached reports whether tokenID is in the local revoked cache
+func (l *revocationList) cached(tokenID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.revoked[tokenID]
+	return ok
+}
+
+// RevokeRefreshToken revokes a refresh token, e.g. one presented at logout. Tokens that are
+// already revoked or expired can't be used anyway and are not an error.
+func (m *JWTManager) RevokeRefreshToken(refreshToken string) error {
+	claims, err := m.parse(refreshToken)
+	if errors.Is(err, ErrTokenRevoked) || errors.Is(err, jwt.ErrTokenExpired) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if claims.TokenType != TokenTypeRefresh {
+		return ErrWrongTokenType
+	}
+	return m.Revoke(claims.ID, claims.ExpiresAt.Time)
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Scopes a session token can carry. Admins hold every scope implicitly.
+const (
+	ScopeViewer   = "viewer"   // read operational metrics
+	ScopeBilling  = "billing"  // read cost analytics
+	ScopeOperator = "operator" // operate the service: diagnostics and report refreshes
+)
+
+// roleScopes maps each assignable role to the scopes it grants
+var roleScopes = map[string][]string{
+	"viewer":   {ScopeViewer},
+	"billing":  {ScopeBilling},
+	"operator": {ScopeViewer, ScopeOperator},
+}
+
+// RoleSet maps Apple ID subs to the scopes granted by their configured roles
+type RoleSet map[string][]string
+
+// ParseRoleSet parses comma-separated sub=role assignments, e.g. "sub1=billing,sub2=viewer".
+// A sub may be assigned several roles; their scopes are combined.
+func ParseRoleSet(value string) (RoleSet, error) {
+	roles := make(RoleSet)
+	for _, assignment := range strings.Split(value, ",") {
+		if assignment = strings.TrimSpace(assignment); assignment == "" {
+			continue
+		}
+		sub, role, ok := strings.Cut(assignment, "=")
+		sub, role = strings.TrimSpace(sub), strings.TrimSpace(role)
+		if !ok || sub == "" {
+			return nil, fmt.Errorf("invalid role assignment %q, want sub=role", assignment)
+		}
+		scopes, ok := roleScopes[role]
+		if !ok {
+			return nil, fmt.Errorf("unknown role %q for %s", role, sub)
+		}
+		roles[sub] = mergeScopes(roles[sub], scopes)
+	}
+	return roles, nil
+}
+
+// Scopes returns the scopes granted to sub, or nil if it has no role
+func (r RoleSet) Scopes(sub string) []string {
+	if sub == "" {
+		return nil
+	}
+	return r[sub]
+}
+
+// mergeScopes returns the sorted union of two scope lists
+func mergeScopes(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var merged []string
+	for _, scope := range append(append([]string{}, a...), b...) {
+		if !seen[scope] {
+			seen[scope] = true
+			merged = append(merged, scope)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// HasScope reports whether the session holds scope. Admins hold every scope.
+func (c *SessionClaims) HasScope(scope string) bool {
+	if c.IsAdmin {
+		return true
+	}
+	for _, granted := range c.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRoleSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    RoleSet
+		wantErr bool
+	}{
+		{name: "empty", value: "", want: RoleSet{}},
+		{name: "one role each", value: "001.billing=billing, 002.viewer=viewer", want: RoleSet{"001.billing": {ScopeBilling}, "002.viewer": {ScopeViewer}}},
+		{name: "operator includes viewer", value: "003.ops=operator", want: RoleSet{"003.ops": {ScopeOperator, ScopeViewer}}},
+		{name: "roles combine", value: "004.both=billing,004.both=operator,004.both=viewer", want: RoleSet{"004.both": {ScopeBilling, ScopeOperator, ScopeViewer}}},
+		{name: "trailing comma", value: "001.billing=billing,", want: RoleSet{"001.billing": {ScopeBilling}}},
+		{name: "unknown role", value: "001.billing=finance", wantErr: true},
+		{name: "missing role", value: "001.billing", wantErr: true},
+		{name: "missing sub", value: "=billing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRoleSet(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRoleSet(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRoleSet(%q): %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRoleSet(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	billing := &SessionClaims{Scopes: []string{ScopeBilling}}
+	admin := &SessionClaims{IsAdmin: true}
+	none := &SessionClaims{}
+
+	for _, scope := range []string{ScopeViewer, ScopeBilling, ScopeOperator} {
+		if got := billing.HasScope(scope); got != (scope == ScopeBilling) {
+			t.Errorf("billing user HasScope(%s) = %v", scope, got)
+		}
+		if !admin.HasScope(scope) {
+			t.Errorf("admin HasScope(%s) = false, want every scope", scope)
+		}
+		if none.HasScope(scope) {
+			t.Errorf("user without roles HasScope(%s) = true", scope)
+		}
+	}
+}
+
+func TestSessionTokenCarriesScopes(t *testing.T) {
+	manager := newTestJWTManager(t)
+	roles, err := ParseRoleSet("001.billing=billing")
+	if err != nil {
+		t.Fatalf("ParseRoleSet: %v", err)
+	}
+
+	for _, sub := range []string{"001.billing", "002.nobody"} {
+		token, err := manager.GenerateToken(&AppleUserInfo{Sub: sub, Scopes: roles.Scopes(sub)})
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		claims, err := manager.ValidateToken(token)
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if !reflect.DeepEqual(claims.Scopes, roles.Scopes(sub)) {
+			t.Errorf("%s token scopes = %v, want %v", sub, claims.Scopes, roles.Scopes(sub))
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// DefaultAnomalyBandWidth is how many standard deviations the expected band spans by default
+const DefaultAnomalyBandWidth = 2.0
+
+// Query IDs used by anomaly band requests
+const (
+	anomalyActualID = "actual"
+	anomalyBandID   = "band"
+)
+
+// lambdaBandMetrics maps the Lambda metrics an expected band can be drawn for to CloudWatch names
+var lambdaBandMetrics = map[string]string{
+	"invocations": "Invocations",
+	"errors":      "Errors",
+	"throttles":   "Throttles",
+}
+
+// apiGatewayBandMetrics maps the API Gateway metrics an expected band can be drawn for to
+// CloudWatch names
+var apiGatewayBandMetrics = map[string]string{
+	"count": "Count",
+	"4xx":   "4XXError",
+	"5xx":   "5XXError",
+}
+
+// IsLambdaBandMetric reports whether an expected band can be drawn for a Lambda metric
+func IsLambdaBandMetric(metric string) bool {
+	_, ok := lambdaBandMetrics[metric]
+	return ok || metric == "errorRate"
+}
+
+// IsAPIGatewayBandMetric reports whether an expected band can be drawn for an API Gateway metric
+func IsAPIGatewayBandMetric(metric string) bool {
+	_, ok := apiGatewayBandMetrics[metric]
+	return ok || metric == "errorRate"
+}
+
+// AnomalyBand is a metric's actual series alongside the upper and lower bounds CloudWatch's
+// anomaly detection model expects it to stay within
+type AnomalyBand struct {
+	Metric string            `json:"metric"`
+	Width  float64           `json:"width"`
+	Actual []MetricDatapoint `json:"actual"`
+	Upper  []MetricDatapoint `json:"upper"`
+	Lower  []MetricDatapoint `json:"lower"`
+}
+
+// GetLambdaAnomalyBand returns the expected band for a Lambda metric summed across functions,
+// or for their combined error rate (errorRate). Width is the band's width in standard
+// deviations; interval sets the period of every series.
+func (c *CloudWatchClient) GetLambdaAnomalyBand(ctx context.Context, functionNames []string, metric string, width float64, startTime, endTime time.Time, interval time.Duration) (*AnomalyBand, error) {
+	if len(functionNames) == 0 {
+		return nil, fmt.Errorf("no Lambda functions to draw an expected band for")
+	}
+	period := MetricPeriod(WithPeriod(ctx, int32(interval/time.Second)), startTime, endTime)
+
+	query := func(prefix, metricName string) func(i int) types.MetricDataQuery {
+		return func(i int) types.MetricDataQuery {
+			return lambdaMetricQuery(fmt.Sprintf("%s%d", prefix, i), metricName, functionNames[i], "Sum", period)
+		}
+	}
+
+	var queries []types.MetricDataQuery
+	var unit string
+	if metric == "errorRate" {
+		invocations, invocationsSum := summedQueries(len(functionNames), query("i", "Invocations"))
+		errors, errorsSum := summedQueries(len(functionNames), query("e", "Errors"))
+		queries = append(invocations, errors...)
+		queries = append(queries, expressionQuery(anomalyActualID, fmt.Sprintf("100*%s/%s", errorsSum, invocationsSum)))
+		unit = "Percent"
+	} else {
+		metricName, ok := lambdaBandMetrics[metric]
+		if !ok {
+			return nil, fmt.Errorf("no expected band for Lambda metric %q", metric)
+		}
+		queries = actualQueries(len(functionNames), query("m", metricName))
+		unit = MetricUnit("AWS/Lambda", metricName)
+	}
+
+	band, err := c.getAnomalyBand(ctx, queries, width, unit, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Lambda %s expected band: %w", metric, err)
+	}
+	band.Metric = metric
+	return band, nil
+}
+
+// GetAPIGatewayAnomalyBand returns the expected band for an API Gateway metric, or for its
+// server error rate (errorRate). Width is the band's width in standard deviations; interval
+// sets the period of every series.
+func (c *CloudWatchClient) GetAPIGatewayAnomalyBand(ctx context.Context, apiName, metric string, width float64, startTime, endTime time.Time, interval time.Duration) (*AnomalyBand, error) {
+	period := MetricPeriod(WithPeriod(ctx, int32(interval/time.Second)), startTime, endTime)
+
+	var queries []types.MetricDataQuery
+	var unit string
+	if metric == "errorRate" {
+		count := apiGatewayMetricQuery("count", "Count", apiName, "Sum", period)
+		errors := apiGatewayMetricQuery("error5xx", "5XXError", apiName, "Sum", period)
+		count.ReturnData = aws.Bool(false)
+		errors.ReturnData = aws.Bool(false)
+		queries = []types.MetricDataQuery{count, errors, expressionQuery(anomalyActualID, "100*error5xx/count")}
+		unit = "Percent"
+	} else {
+		metricName, ok := apiGatewayBandMetrics[metric]
+		if !ok {
+			return nil, fmt.Errorf("no expected band for API Gateway metric %q", metric)
+		}
+		queries = []types.MetricDataQuery{apiGatewayMetricQuery(anomalyActualID, metricName, apiName, "Sum", period)}
+		unit = MetricUnit("AWS/ApiGateway", metricName)
+	}
+
+	band, err := c.getAnomalyBand(ctx, queries, width, unit, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API Gateway %s expected band: %w", metric, err)
+	}
+	band.Metric = metric
+	return band, nil
+}
+
+// getAnomalyBand adds an ANOMALY_DETECTION_BAND query over the query with ID "actual" and
+// returns both series
+func (c *CloudWatchClient) getAnomalyBand(ctx context.Context, queries []types.MetricDataQuery, width float64, unit string, startTime, endTime time.Time) (*AnomalyBand, error) {
+	if width <= 0 {
+		width = DefaultAnomalyBandWidth
+	}
+	queries = append(queries, anomalyBandQuery(anomalyActualID, width))
+
+	results, err := getAllMetricData(ctx, c.client, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         &startTime,
+		EndTime:           &endTime,
+		ScanBy:            types.ScanByTimestampAscending,
+	}, c.maxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	band := parseAnomalyBand(results, unit)
+	band.Width = width
+	return band, nil
+}
+
+// anomalyBandQuery builds the metric math query for the expected band around the query with
+// ID actualID, width standard deviations wide
+func anomalyBandQuery(actualID string, width float64) types.MetricDataQuery {
+	return expressionQuery(anomalyBandID, fmt.Sprintf("ANOMALY_DETECTION_BAND(%s, %g)", actualID, width))
+}
+
+// expressionQuery builds a returned metric math query
+func expressionQuery(id, expression string) types.MetricDataQuery {
+	return types.MetricDataQuery{
+		Id:         aws.String(id),
+		Expression: aws.String(expression),
+		ReturnData: aws.Bool(true),
+	}
+}
+
+// actualQueries returns the queries producing the "actual" series for n resources: the single
+// resource's metric itself, or a SUM over every resource's metric
+func actualQueries(n int, query func(i int) types.MetricDataQuery) []types.MetricDataQuery {
+	if n == 1 {
+		single := query(0)
+		single.Id = aws.String(anomalyActualID)
+		return []types.MetricDataQuery{single}
+	}
+	queries, sum := summedQueries(n, query)
+	return append(queries, expressionQuery(anomalyActualID, sum))
+}
+
+// summedQueries returns n unreturned per-resource queries and a metric math expression summing
+// them
+func summedQueries(n int, query func(i int) types.MetricDataQuery) ([]types.MetricDataQuery, string) {
+	queries := make([]types.MetricDataQuery, 0, n)
+	ids := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		q := query(i)
+		q.ReturnData = aws.Bool(false)
+		queries = append(queries, q)
+		ids = append(ids, aws.ToString(q.Id))
+	}
+	return queries, "SUM([" + strings.Join(ids, ",") + "])"
+}
+
+// parseAnomalyBand splits GetMetricData results into the actual series and the band's bounds.
+// ANOMALY_DETECTION_BAND returns its two bounds as separate series under one ID, so at each
+// timestamp the higher value is the upper bound and the lower value the lower bound.
+func parseAnomalyBand(results []types.MetricDataResult, unit string) *AnomalyBand {
+	band := &AnomalyBand{
+		Actual: []MetricDatapoint{},
+		Upper:  []MetricDatapoint{},
+		Lower:  []MetricDatapoint{},
+	}
+
+	upper := make(map[time.Time]float64)
+	lower := make(map[time.Time]float64)
+	for _, result := range results {
+		switch aws.ToString(result.Id) {
+		case anomalyActualID:
+			band.Actual = append(band.Actual, resultDatapoints(result, unit)...)
+		case anomalyBandID:
+			for _, datapoint := range resultDatapoints(result, unit) {
+				if high, ok := upper[datapoint.Timestamp]; !ok || datapoint.Value > high {
+					upper[datapoint.Timestamp] = datapoint.Value
+				}
+				if low, ok := lower[datapoint.Timestamp]; !ok || datapoint.Value < low {
+					lower[datapoint.Timestamp] = datapoint.Value
+				}
+			}
+		}
+	}
+
+	for timestamp, value := range upper {
+		band.Upper = append(band.Upper, MetricDatapoint{Timestamp: timestamp, Value: value, Unit: unit})
+		band.Lower = append(band.Lower, MetricDatapoint{Timestamp: timestamp, Value: lower[timestamp], Unit: unit})
+	}
+	byTime := func(series []MetricDatapoint) {
+		sort.Slice(series, func(i, j int) bool { return series[i].Timestamp.Before(series[j].Timestamp) })
+	}
+	byTime(band.Actual)
+	byTime(band.Upper)
+	byTime(band.Lower)
+
+	return band
+}
@@ -0,0 +1,154 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// queryByID indexes an input's queries by ID
+func queryByID(input cloudwatch.GetMetricDataInput) map[string]types.MetricDataQuery {
+	queries := make(map[string]types.MetricDataQuery, len(input.MetricDataQueries))
+	for _, query := range input.MetricDataQueries {
+		queries[aws.ToString(query.Id)] = query
+	}
+	return queries
+}
+
+func TestAnomalyBandQueries(t *testing.T) {
+	tests := []struct {
+		name           string
+		get            func(client *CloudWatchClient) error
+		wantActual     string
+		wantBand       string
+		wantUnreturned []string
+	}{
+		{
+			name: "Lambda metric across functions",
+			get: func(client *CloudWatchClient) error {
+				_, err := client.GetLambdaAnomalyBand(context.Background(), []string{"checkout", "worker"}, "invocations", 3, pageStart, pageEnd, time.Hour)
+				return err
+			},
+			wantActual:     "SUM([m0,m1])",
+			wantBand:       "ANOMALY_DETECTION_BAND(actual, 3)",
+			wantUnreturned: []string{"m0", "m1"},
+		},
+		{
+			name: "Lambda error rate",
+			get: func(client *CloudWatchClient) error {
+				_, err := client.GetLambdaAnomalyBand(context.Background(), []string{"checkout", "worker"}, "errorRate", 2.5, pageStart, pageEnd, time.Hour)
+				return err
+			},
+			wantActual:     "100*SUM([e0,e1])/SUM([i0,i1])",
+			wantBand:       "ANOMALY_DETECTION_BAND(actual, 2.5)",
+			wantUnreturned: []string{"i0", "i1", "e0", "e1"},
+		},
+		{
+			name: "API Gateway error rate with the default width",
+			get: func(client *CloudWatchClient) error {
+				_, err := client.GetAPIGatewayAnomalyBand(context.Background(), "orders-api", "errorRate", 0, pageStart, pageEnd, time.Hour)
+				return err
+			},
+			wantActual:     "100*error5xx/count",
+			wantBand:       "ANOMALY_DETECTION_BAND(actual, 2)",
+			wantUnreturned: []string{"count", "error5xx"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{}}}}
+			if err := tt.get(&CloudWatchClient{client: fake, maxAttempts: 1}); err != nil {
+				t.Fatalf("get band: %v", err)
+			}
+
+			queries := queryByID(fake.inputs[0])
+			if got := aws.ToString(queries[anomalyActualID].Expression); got != tt.wantActual {
+				t.Errorf("actual = %q, want %q", got, tt.wantActual)
+			}
+			band := queries[anomalyBandID]
+			if got := aws.ToString(band.Expression); got != tt.wantBand {
+				t.Errorf("band = %q, want %q", got, tt.wantBand)
+			}
+			if !aws.ToBool(band.ReturnData) || !aws.ToBool(queries[anomalyActualID].ReturnData) {
+				t.Error("actual and band series are not both returned")
+			}
+			for _, id := range tt.wantUnreturned {
+				query, ok := queries[id]
+				if !ok || aws.ToBool(query.ReturnData) {
+					t.Errorf("query %s = %+v, want an unreturned input", id, query)
+				}
+			}
+			if len(queries) != len(tt.wantUnreturned)+2 {
+				t.Errorf("got %d queries, want %d", len(queries), len(tt.wantUnreturned)+2)
+			}
+		})
+	}
+}
+
+func TestAnomalyBandSingleFunctionIsActualMetric(t *testing.T) {
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	if _, err := client.GetLambdaAnomalyBand(context.Background(), []string{"checkout"}, "errors", 2, pageStart, pageEnd, time.Hour); err != nil {
+		t.Fatalf("GetLambdaAnomalyBand: %v", err)
+	}
+	actual := queryByID(fake.inputs[0])[anomalyActualID]
+	if actual.MetricStat == nil || aws.ToString(actual.MetricStat.Metric.MetricName) != "Errors" || aws.ToInt32(actual.MetricStat.Period) != 3600 {
+		t.Errorf("actual = %+v, want the function's hourly Errors metric", actual)
+	}
+}
+
+func TestAnomalyBandParsesSeries(t *testing.T) {
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []types.MetricDataResult{
+			{Id: aws.String(anomalyActualID), Label: aws.String("Count"), Values: []float64{120, 90}, Timestamps: []time.Time{hour(1), hour(0)}},
+			// The band's bounds come back as two series under one ID, in no promised order
+			{Id: aws.String(anomalyBandID), Label: aws.String("Count Upper"), Values: []float64{150, 130}, Timestamps: []time.Time{hour(0), hour(1)}},
+			{Id: aws.String(anomalyBandID), Label: aws.String("Count Lower"), Values: []float64{70, 60}, Timestamps: []time.Time{hour(0), hour(1)}},
+		},
+	}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	band, err := client.GetAPIGatewayAnomalyBand(context.Background(), "orders-api", "count", 2, pageStart, pageEnd, time.Hour)
+	if err != nil {
+		t.Fatalf("GetAPIGatewayAnomalyBand: %v", err)
+	}
+
+	if band.Metric != "count" || band.Width != 2 {
+		t.Errorf("band = %s at width %v, want count at 2", band.Metric, band.Width)
+	}
+	check := func(name string, got []MetricDatapoint, want ...float64) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("%s = %+v, want %v", name, got, want)
+		}
+		for i, w := range want {
+			if !got[i].Timestamp.Equal(hour(i)) || got[i].Value != w {
+				t.Errorf("%s[%d] = %v at %s, want %v at %s", name, i, got[i].Value, got[i].Timestamp, w, hour(i))
+			}
+		}
+	}
+	check("actual", band.Actual, 90, 120)
+	check("upper", band.Upper, 150, 130)
+	check("lower", band.Lower, 70, 60)
+}
+
+func TestAnomalyBandRejectsUnknownMetric(t *testing.T) {
+	fake := &fakeMetricData{}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	if _, err := client.GetLambdaAnomalyBand(context.Background(), []string{"checkout"}, "duration", 2, pageStart, pageEnd, time.Hour); err == nil {
+		t.Error("Lambda duration band succeeded, want an error")
+	}
+	if _, err := client.GetAPIGatewayAnomalyBand(context.Background(), "orders-api", "latency", 2, pageStart, pageEnd, time.Hour); err == nil {
+		t.Error("API Gateway latency band succeeded, want an error")
+	}
+	if len(fake.inputs) != 0 {
+		t.Errorf("unknown metrics still made %d calls", len(fake.inputs))
+	}
+}
@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+)
+
+// APIKeyStore reads API keys from a DynamoDB table keyed by "keyHash", the hex SHA-256 of the
+// key. Items carry "keyId", optional "name", "scopes" (string set), "admin" and "revoked"
+// booleans, and "expiresAt" epoch seconds for keys that expire.
+type APIKeyStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewAPIKeyStore creates a new DynamoDB-backed API key store
+func NewAPIKeyStore(cfg aws.Config, tableName string) *APIKeyStore {
+	return &APIKeyStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+// GetAPIKey returns the key stored under keyHash, or nil if there is none
+func (s *APIKeyStore) GetAPIKey(ctx context.Context, keyHash string) (*auth.APIKey, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]ddbtypes.AttributeValue{
+			"keyHash": &ddbtypes.AttributeValueMemberS{Value: keyHash},
+		},
+		// Revocations must take effect immediately
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	if output.Item == nil {
+		return nil, nil
+	}
+
+	key := &auth.APIKey{}
+	if v, ok := output.Item["keyId"].(*ddbtypes.AttributeValueMemberS); ok {
+		key.ID = v.Value
+	}
+	if key.ID == "" {
+		return nil, fmt.Errorf("API key item has no keyId")
+	}
+	if v, ok := output.Item["name"].(*ddbtypes.AttributeValueMemberS); ok {
+		key.Name = v.Value
+	}
+	if v, ok := output.Item["scopes"].(*ddbtypes.AttributeValueMemberSS); ok {
+		key.Scopes = v.Value
+	}
+	if v, ok := output.Item["admin"].(*ddbtypes.AttributeValueMemberBOOL); ok {
+		key.Admin = v.Value
+	}
+	if v, ok := output.Item["revoked"].(*ddbtypes.AttributeValueMemberBOOL); ok {
+		key.Revoked = v.Value
+	}
+	if v, ok := output.Item["expiresAt"].(*ddbtypes.AttributeValueMemberN); ok {
+		seconds, err := strconv.ParseInt(v.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API key expiresAt %q: %w", v.Value, err)
+		}
+		key.ExpiresAt = time.Unix(seconds, 0)
+	}
+	return key, nil
+}
@@ -0,0 +1,182 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// maxMetricDataQueries is CloudWatch's limit on queries per GetMetricData call
+const maxMetricDataQueries = 500
+
+// APIEndpoint identifies one API Gateway method on a resource path in a stage
+type APIEndpoint struct {
+	Method   string `json:"method"`
+	Resource string `json:"resource"`
+	Stage    string `json:"stage"`
+}
+
+// EndpointLatency holds an endpoint's request count and latency over a window
+type EndpointLatency struct {
+	APIEndpoint
+	Count          float64 `json:"count"`
+	LatencyAverage float64 `json:"latencyAverage"`
+	LatencyP99     float64 `json:"latencyP99"`
+}
+
+// ListAPIEndpoints returns the endpoints of an API that publish per-method metrics. API Gateway
+// only emits these when detailed CloudWatch metrics are enabled on the stage.
+func (c *CloudWatchClient) ListAPIEndpoints(ctx context.Context, apiName string) ([]APIEndpoint, error) {
+	seen := make(map[APIEndpoint]bool)
+	var endpoints []APIEndpoint
+	var nextToken *string
+
+	for {
+		var output *cloudwatch.ListMetricsOutput
+		err := withRetry(ctx, c.maxAttempts, func() error {
+			var err error
+			output, err = c.client.ListMetrics(ctx, &cloudwatch.ListMetricsInput{
+				Namespace:  aws.String("AWS/ApiGateway"),
+				MetricName: aws.String("Latency"),
+				Dimensions: []types.DimensionFilter{
+					{Name: aws.String("ApiName"), Value: aws.String(apiName)},
+					{Name: aws.String("Method")},
+					{Name: aws.String("Resource")},
+				},
+				NextToken: nextToken,
+			})
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list API Gateway endpoint metrics: %w", err)
+		}
+
+		for _, metric := range output.Metrics {
+			var endpoint APIEndpoint
+			for _, dimension := range metric.Dimensions {
+				switch aws.ToString(dimension.Name) {
+				case "Method":
+					endpoint.Method = aws.ToString(dimension.Value)
+				case "Resource":
+					endpoint.Resource = aws.ToString(dimension.Value)
+				case "Stage":
+					endpoint.Stage = aws.ToString(dimension.Value)
+				}
+			}
+			if endpoint.Method == "" || endpoint.Resource == "" || seen[endpoint] {
+				continue
+			}
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+
+		if output.NextToken == nil {
+			break
+		}
+		nextToken = output.NextToken
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Resource != endpoints[j].Resource {
+			return endpoints[i].Resource < endpoints[j].Resource
+		}
+		if endpoints[i].Method != endpoints[j].Method {
+			return endpoints[i].Method < endpoints[j].Method
+		}
+		return endpoints[i].Stage < endpoints[j].Stage
+	})
+	return endpoints, nil
+}
+
+// GetEndpointLatencies retrieves request count and average and p99 latency for each endpoint.
+// The query period spans the whole range so CloudWatch computes each statistic over it exactly.
+func (c *CloudWatchClient) GetEndpointLatencies(ctx context.Context, apiName string, endpoints []APIEndpoint, startTime, endTime time.Time) ([]EndpointLatency, error) {
+	const queriesPerEndpoint = 3
+	period := rangePeriodSeconds(startTime, endTime)
+	latencies := make([]EndpointLatency, len(endpoints))
+
+	batchSize := maxMetricDataQueries / queriesPerEndpoint
+	for start := 0; start < len(endpoints); start += batchSize {
+		end := start + batchSize
+		if end > len(endpoints) {
+			end = len(endpoints)
+		}
+
+		queries := make([]types.MetricDataQuery, 0, (end-start)*queriesPerEndpoint)
+		for i := start; i < end; i++ {
+			latencies[i].APIEndpoint = endpoints[i]
+			queries = append(queries,
+				endpointMetricQuery(fmt.Sprintf("count_%d", i), "Count", apiName, endpoints[i], "Sum", period),
+				endpointMetricQuery(fmt.Sprintf("avg_%d", i), "Latency", apiName, endpoints[i], "Average", period),
+				endpointMetricQuery(fmt.Sprintf("p99_%d", i), "Latency", apiName, endpoints[i], "p99", period),
+			)
+		}
+
+		results, err := getAllMetricData(ctx, c.client, &cloudwatch.GetMetricDataInput{
+			MetricDataQueries: queries,
+			StartTime:         &startTime,
+			EndTime:           &endTime,
+		}, c.maxAttempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get API Gateway endpoint metrics: %w", err)
+		}
+
+		for _, metricResult := range results {
+			if metricResult.Id == nil || len(metricResult.Values) == 0 {
+				continue
+			}
+
+			// Query IDs are <stat>_<endpoint index>
+			stat, index, _ := strings.Cut(*metricResult.Id, "_")
+			i, err := strconv.Atoi(index)
+			if err != nil || i < start || i >= end {
+				continue
+			}
+
+			// A range-wide period yields a single value; combine defensively if CloudWatch splits it
+			switch stat {
+			case "count":
+				latencies[i].Count = sumValues(metricResult.Values)
+			case "avg":
+				latencies[i].LatencyAverage = sumValues(metricResult.Values) / float64(len(metricResult.Values))
+			case "p99":
+				latencies[i].LatencyP99 = maxValue(metricResult.Values)
+			}
+		}
+	}
+
+	return latencies, nil
+}
+
+// endpointMetricQuery builds a metric query for one API Gateway endpoint
+func endpointMetricQuery(id, metricName, apiName string, endpoint APIEndpoint, stat string, period int32) types.MetricDataQuery {
+	dimensions := []types.Dimension{
+		{Name: aws.String("ApiName"), Value: aws.String(apiName)},
+		{Name: aws.String("Method"), Value: aws.String(endpoint.Method)},
+		{Name: aws.String("Resource"), Value: aws.String(endpoint.Resource)},
+	}
+	if endpoint.Stage != "" {
+		dimensions = append(dimensions, types.Dimension{Name: aws.String("Stage"), Value: aws.String(endpoint.Stage)})
+	}
+
+	return types.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &types.MetricStat{
+			Metric: &types.Metric{
+				Namespace:  aws.String("AWS/ApiGateway"),
+				MetricName: aws.String(metricName),
+				Dimensions: dimensions,
+			},
+			Period: aws.Int32(period),
+			Stat:   aws.String(stat),
+		},
+		ReturnData: aws.Bool(true),
+	}
+}
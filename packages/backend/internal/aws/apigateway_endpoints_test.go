@@ -0,0 +1,69 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+func TestGetEndpointLatencies(t *testing.T) {
+	endpoints := []APIEndpoint{
+		{Method: "GET", Resource: "/items", Stage: "prod"},
+		{Method: "POST", Resource: "/orders", Stage: "prod"},
+		{Method: "GET", Resource: "/health"},
+	}
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []types.MetricDataResult{
+			{Id: aws.String("count_0"), Values: []float64{1200}},
+			{Id: aws.String("avg_0"), Values: []float64{85}},
+			{Id: aws.String("p99_0"), Values: []float64{420}},
+			{Id: aws.String("count_1"), Values: []float64{40}},
+			{Id: aws.String("avg_1"), Values: []float64{300}},
+			{Id: aws.String("p99_1"), Values: []float64{900}},
+			// The health check got no traffic, so CloudWatch returns no values for it
+			{Id: aws.String("count_2")},
+			{Id: aws.String("avg_2")},
+			{Id: aws.String("p99_2")},
+		},
+	}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	latencies, err := client.GetEndpointLatencies(context.Background(), "orders-api", endpoints, pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetEndpointLatencies: %v", err)
+	}
+
+	want := []EndpointLatency{
+		{APIEndpoint: endpoints[0], Count: 1200, LatencyAverage: 85, LatencyP99: 420},
+		{APIEndpoint: endpoints[1], Count: 40, LatencyAverage: 300, LatencyP99: 900},
+		{APIEndpoint: endpoints[2]},
+	}
+	if len(latencies) != len(want) {
+		t.Fatalf("latencies = %+v, want %d", latencies, len(want))
+	}
+	for i, w := range want {
+		if latencies[i] != w {
+			t.Errorf("latency %d = %+v, want %+v", i, latencies[i], w)
+		}
+	}
+
+	// Three range-wide queries per endpoint, with the stage dimension only when there is one
+	queries := fake.inputs[0].MetricDataQueries
+	if len(queries) != 3*len(endpoints) {
+		t.Fatalf("got %d queries, want %d", len(queries), 3*len(endpoints))
+	}
+	for _, query := range queries {
+		if got := aws.ToInt32(query.MetricStat.Period); got != 4*3600 {
+			t.Errorf("%s period = %d, want %d", aws.ToString(query.Id), got, 4*3600)
+		}
+	}
+	if got := len(queries[0].MetricStat.Metric.Dimensions); got != 4 {
+		t.Errorf("staged endpoint has %d dimensions, want 4", got)
+	}
+	if got := len(queries[6].MetricStat.Metric.Dimensions); got != 3 {
+		t.Errorf("unstaged endpoint has %d dimensions, want 3", got)
+	}
+}
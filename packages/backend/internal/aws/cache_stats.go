@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// CacheStats counts lookups in one cache. An expired entry found on lookup counts as both an
+// eviction and a miss. A nil *CacheStats discards records.
+type CacheStats struct {
+	name      string
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+// newCacheStats creates zeroed counters for the named cache
+func newCacheStats(name string) *CacheStats {
+	return &CacheStats{name: name}
+}
+
+// recordLookup records a lookup that found a fresh entry (hit), an expired one (expired)
+// or nothing
+func (s *CacheStats) recordLookup(hit, expired bool) {
+	if s == nil {
+		return
+	}
+	switch {
+	case hit:
+		s.hits.Add(1)
+	case expired:
+		s.evictions.Add(1)
+		s.misses.Add(1)
+	default:
+		s.misses.Add(1)
+	}
+}
+
+// CacheSnapshot is a point-in-time copy of one cache's counters
+type CacheSnapshot struct {
+	Name      string  `json:"name"`
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Evictions int64   `json:"evictions"`
+	HitRatio  float64 `json:"hitRatio"`
+}
+
+// Snapshot returns the current counters
+func (s *CacheStats) Snapshot() CacheSnapshot {
+	if s == nil {
+		return CacheSnapshot{}
+	}
+	snapshot := CacheSnapshot{
+		Name:      s.name,
+		Hits:      s.hits.Load(),
+		Misses:    s.misses.Load(),
+		Evictions: s.evictions.Load(),
+	}
+	if lookups := snapshot.Hits + snapshot.Misses; lookups > 0 {
+		snapshot.HitRatio = float64(snapshot.Hits) / float64(lookups)
+	}
+	return snapshot
+}
+
+// WriteCachePrometheus writes cache counters in the Prometheus text exposition format
+func WriteCachePrometheus(w io.Writer, caches []CacheSnapshot) error {
+	counters := []struct {
+		name  string
+		help  string
+		value func(CacheSnapshot) int64
+	}{
+		{"central_analytics_cache_hits_total", "Cache lookups served from the cache.", func(c CacheSnapshot) int64 { return c.Hits }},
+		{"central_analytics_cache_misses_total", "Cache lookups that had to fetch.", func(c CacheSnapshot) int64 { return c.Misses }},
+		{"central_analytics_cache_evictions_total", "Expired cache entries replaced on lookup.", func(c CacheSnapshot) int64 { return c.Evictions }},
+	}
+
+	for _, counter := range counters {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", counter.name, counter.help, counter.name); err != nil {
+			return err
+		}
+		for _, cache := range caches {
+			if _, err := fmt.Fprintf(w, "%s{cache=%q} %d\n", counter.name, cache.Name, counter.value(cache)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// fakeLambdaConfig reports 512 MB for every function, counting calls
+type fakeLambdaConfig struct {
+	calls int
+}
+
+func (f *fakeLambdaConfig) GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error) {
+	f.calls++
+	return &lambda.GetFunctionConfigurationOutput{MemorySize: aws.Int32(512)}, nil
+}
+
+func TestCacheStatsCountLookups(t *testing.T) {
+	ctx := context.Background()
+
+	tagging, fakeTags := newTestTaggingClient()
+	lookupTag := func(value string) {
+		t.Helper()
+		if _, err := tagging.GetLambdaFunctionsByTag(ctx, "Application", value); err != nil {
+			t.Fatalf("GetLambdaFunctionsByTag: %v", err)
+		}
+	}
+
+	fakeConfig := &fakeLambdaConfig{}
+	lambdaConfig := &LambdaConfigClient{
+		client: fakeConfig,
+		ttl:    functionMemoryCacheTTL,
+		memory: make(map[string]functionMemoryEntry),
+		stats:  newCacheStats("lambda_memory"),
+	}
+	lookupMemory := func(function string) {
+		t.Helper()
+		if _, err := lambdaConfig.GetMemorySize(ctx, function); err != nil {
+			t.Fatalf("GetMemorySize: %v", err)
+		}
+	}
+
+	// First lookups miss, repeats hit
+	lookupTag("ilikeyacut")
+	lookupTag("ilikeyacut")
+	lookupTag("ilikeyacut")
+	lookupTag("other")
+	lookupMemory("checkout")
+	lookupMemory("checkout")
+	lookupMemory("orders")
+
+	checkCacheSnapshot(t, tagging.CacheStats(), CacheSnapshot{Name: "tagged_functions", Hits: 2, Misses: 2, HitRatio: 0.5})
+	checkCacheSnapshot(t, lambdaConfig.CacheStats(), CacheSnapshot{Name: "lambda_memory", Hits: 1, Misses: 2, HitRatio: 1.0 / 3})
+
+	// An expired entry is evicted and fetched again: a miss as well as an eviction
+	tagging.ttl = 0
+	lambdaConfig.ttl = 0
+	lookupTag("ilikeyacut")
+	lookupMemory("checkout")
+
+	checkCacheSnapshot(t, tagging.CacheStats(), CacheSnapshot{Name: "tagged_functions", Hits: 2, Misses: 3, Evictions: 1, HitRatio: 0.4})
+	checkCacheSnapshot(t, lambdaConfig.CacheStats(), CacheSnapshot{Name: "lambda_memory", Hits: 1, Misses: 3, Evictions: 1, HitRatio: 0.25})
+
+	// Every miss fetched; no hit did
+	if got := len(fakeTags.inputs); got != 6 {
+		t.Errorf("GetResources called %d times, want 6 for 3 misses over 2 pages", got)
+	}
+	if fakeConfig.calls != 3 {
+		t.Errorf("GetFunctionConfiguration called %d times, want 3", fakeConfig.calls)
+	}
+}
+
+func checkCacheSnapshot(t *testing.T, got, want CacheSnapshot) {
+	t.Helper()
+
+	if got.Name != want.Name || got.Hits != want.Hits || got.Misses != want.Misses || got.Evictions != want.Evictions {
+		t.Errorf("snapshot = %+v, want %+v", got, want)
+	}
+	if diff := got.HitRatio - want.HitRatio; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("%s hit ratio = %v, want %v", got.Name, got.HitRatio, want.HitRatio)
+	}
+}
+
+func TestNilCacheStats(t *testing.T) {
+	var stats *CacheStats
+	stats.recordLookup(true, false)
+	if got := stats.Snapshot(); got != (CacheSnapshot{}) {
+		t.Errorf("nil stats snapshot = %+v, want zero", got)
+	}
+}
+
+func TestWriteCachePrometheus(t *testing.T) {
+	stats := newCacheStats("tagged_functions")
+	stats.recordLookup(true, false)
+	stats.recordLookup(true, false)
+	stats.recordLookup(false, true)
+	stats.recordLookup(false, false)
+
+	var out strings.Builder
+	if err := WriteCachePrometheus(&out, []CacheSnapshot{stats.Snapshot(), newCacheStats("lambda_memory").Snapshot()}); err != nil {
+		t.Fatalf("WriteCachePrometheus: %v", err)
+	}
+
+	for _, line := range []string{
+		"# TYPE central_analytics_cache_hits_total counter",
+		`central_analytics_cache_hits_total{cache="tagged_functions"} 2`,
+		`central_analytics_cache_misses_total{cache="tagged_functions"} 2`,
+		`central_analytics_cache_evictions_total{cache="tagged_functions"} 1`,
+		`central_analytics_cache_hits_total{cache="lambda_memory"} 0`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("output is missing %q:\n%s", line, out.String())
+		}
+	}
+}
@@ -3,35 +3,58 @@ package aws
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 )
 
+// cloudWatchAPI is the part of the CloudWatch client CloudWatchClient calls, implemented by
+// *cloudwatch.Client
+type cloudWatchAPI interface {
+	metricDataAPI
+	ListMetrics(ctx context.Context, params *cloudwatch.ListMetricsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.ListMetricsOutput, error)
+}
+
 // CloudWatchClient wraps the CloudWatch client
 type CloudWatchClient struct {
-	client *cloudwatch.Client
+	client      cloudWatchAPI
+	maxAttempts int
 }
 
-// NewCloudWatchClient creates a new CloudWatch client
-func NewCloudWatchClient(cfg aws.Config) *CloudWatchClient {
+// NewCloudWatchClient creates a new CloudWatch client that retries throttled and 5xx calls with
+// exponential backoff, making at most maxAttempts calls (DefaultCloudWatchMaxAttempts if <= 0)
+func NewCloudWatchClient(cfg aws.Config, maxAttempts int) *CloudWatchClient {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultCloudWatchMaxAttempts
+	}
 	return &CloudWatchClient{
-		client: cloudwatch.NewFromConfig(cfg),
+		client:      newCloudWatchAPI(cfg),
+		maxAttempts: maxAttempts,
 	}
 }
 
 // LambdaMetrics represents Lambda function metrics
 type LambdaMetrics struct {
-	FunctionName string                 `json:"functionName"`
-	Invocations  float64                `json:"invocations"`
-	Errors       float64                `json:"errors"`
-	Duration     float64                `json:"duration"`
-	Throttles    float64                `json:"throttles"`
-	ConcurrentExecutions float64        `json:"concurrentExecutions"`
-	Period       string                 `json:"period"`
-	Datapoints   []MetricDatapoint      `json:"datapoints"`
+	FunctionName         string                       `json:"functionName"`
+	Invocations          float64                      `json:"invocations"`
+	Errors               float64                      `json:"errors"`
+	Duration             float64                      `json:"duration"`
+	Throttles            float64                      `json:"throttles"`
+	ConcurrentExecutions float64                      `json:"concurrentExecutions"`
+	AsyncEventsReceived  float64                      `json:"asyncEventsReceived"`
+	ErrorRate            float64                      `json:"errorRate"`         // Errors / Invocations, retries included
+	AdjustedErrorRate    float64                      `json:"adjustedErrorRate"` // Final failures / unique events, async retries removed
+	SuccessRate          *float64                     `json:"successRate"`       // 100 - ErrorRate; null without invocations
+	Period               timerange.Period             `json:"period"`
+	Datapoints           []MetricDatapoint            `json:"datapoints"`
+	Series               map[string][]MetricDatapoint `json:"series,omitempty"`
+	Rates                map[string]CounterRate       `json:"rates,omitempty"` // Only when a rate unit is requested
+	DataAsOf             *time.Time                   `json:"dataAsOf,omitempty"`
 }
 
 // MetricDatapoint represents a single metric data point
@@ -43,119 +66,113 @@ type MetricDatapoint struct {
 
 // GetLambdaMetrics retrieves metrics for a Lambda function
 func (c *CloudWatchClient) GetLambdaMetrics(ctx context.Context, functionName string, startTime, endTime time.Time) (*LambdaMetrics, error) {
-	metrics := &LambdaMetrics{
-		FunctionName: functionName,
-		Period:       fmt.Sprintf("%s to %s", startTime.Format(time.RFC3339), endTime.Format(time.RFC3339)),
-	}
+	// Period scales with the range unless the request asked for one
+	period := MetricPeriod(ctx, startTime, endTime)
 
 	// Define metric queries
-	queries := []types.MetricDataQuery{
-		{
-			Id: aws.String("invocations"),
-			MetricStat: &types.MetricStat{
-				Metric: &types.Metric{
-					Namespace:  aws.String("AWS/Lambda"),
-					MetricName: aws.String("Invocations"),
-					Dimensions: []types.Dimension{
-						{
-							Name:  aws.String("FunctionName"),
-							Value: aws.String(functionName),
-						},
-					},
-				},
-				Period: aws.Int32(300), // 5 minutes
-				Stat:   aws.String("Sum"),
-			},
-			ReturnData: aws.Bool(true),
-		},
-		{
-			Id: aws.String("errors"),
-			MetricStat: &types.MetricStat{
-				Metric: &types.Metric{
-					Namespace:  aws.String("AWS/Lambda"),
-					MetricName: aws.String("Errors"),
-					Dimensions: []types.Dimension{
-						{
-							Name:  aws.String("FunctionName"),
-							Value: aws.String(functionName),
-						},
-					},
-				},
-				Period: aws.Int32(300),
-				Stat:   aws.String("Sum"),
-			},
-			ReturnData: aws.Bool(true),
-		},
-		{
-			Id: aws.String("duration"),
-			MetricStat: &types.MetricStat{
-				Metric: &types.Metric{
-					Namespace:  aws.String("AWS/Lambda"),
-					MetricName: aws.String("Duration"),
-					Dimensions: []types.Dimension{
-						{
-							Name:  aws.String("FunctionName"),
-							Value: aws.String(functionName),
-						},
-					},
-				},
-				Period: aws.Int32(300),
-				Stat:   aws.String("Average"),
-			},
-			ReturnData: aws.Bool(true),
-		},
-		{
-			Id: aws.String("throttles"),
-			MetricStat: &types.MetricStat{
-				Metric: &types.Metric{
-					Namespace:  aws.String("AWS/Lambda"),
-					MetricName: aws.String("Throttles"),
-					Dimensions: []types.Dimension{
-						{
-							Name:  aws.String("FunctionName"),
-							Value: aws.String(functionName),
-						},
-					},
-				},
-				Period: aws.Int32(300),
-				Stat:   aws.String("Sum"),
-			},
-			ReturnData: aws.Bool(true),
-		},
-		{
-			Id: aws.String("concurrent"),
-			MetricStat: &types.MetricStat{
-				Metric: &types.Metric{
-					Namespace:  aws.String("AWS/Lambda"),
-					MetricName: aws.String("ConcurrentExecutions"),
-					Dimensions: []types.Dimension{
-						{
-							Name:  aws.String("FunctionName"),
-							Value: aws.String(functionName),
-						},
-					},
-				},
-				Period: aws.Int32(300),
-				Stat:   aws.String("Maximum"),
-			},
-			ReturnData: aws.Bool(true),
-		},
-	}
+	queries := lambdaMetricsQueries(functionName, "", period)
 
 	// Get metric data
 	input := &cloudwatch.GetMetricDataInput{
 		MetricDataQueries: queries,
-		StartTime:        &startTime,
-		EndTime:          &endTime,
+		StartTime:         &startTime,
+		EndTime:           &endTime,
 	}
 
-	result, err := c.client.GetMetricData(ctx, input)
+	results, err := getAllMetricData(ctx, c.client, input, c.maxAttempts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get metric data: %w", err)
 	}
 
-	// Process results
-	for _, metricResult := range result.MetricDataResults {
+	return newLambdaMetrics(functionName, startTime, endTime, results, queryUnits(queries)), nil
+}
+
+// lambdaBatchSize is how many functions share one GetMetricData call; each function needs
+// six of the 500 queries a call allows
+const lambdaBatchSize = 80
+
+// GetLambdaMetricsBatch retrieves the same metrics as GetLambdaMetrics for several functions
+// with one GetMetricData call per lambdaBatchSize functions instead of one per function.
+// Query IDs carry the function's index (invocations_0, errors_0, ...) so results can be
+// demultiplexed; the returned map is keyed by function name.
+func (c *CloudWatchClient) GetLambdaMetricsBatch(ctx context.Context, functionNames []string, startTime, endTime time.Time) (map[string]*LambdaMetrics, error) {
+	period := MetricPeriod(ctx, startTime, endTime)
+	units := queryUnits(lambdaMetricsQueries("", "", period))
+	batch := make(map[string]*LambdaMetrics, len(functionNames))
+
+	for offset := 0; offset < len(functionNames); offset += lambdaBatchSize {
+		chunk := functionNames[offset:min(offset+lambdaBatchSize, len(functionNames))]
+
+		var queries []types.MetricDataQuery
+		for i, functionName := range chunk {
+			queries = append(queries, lambdaMetricsQueries(functionName, fmt.Sprintf("_%d", i), period)...)
+		}
+
+		results, err := getAllMetricData(ctx, c.client, &cloudwatch.GetMetricDataInput{
+			MetricDataQueries: queries,
+			StartTime:         &startTime,
+			EndTime:           &endTime,
+		}, c.maxAttempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get batched Lambda metric data: %w", err)
+		}
+
+		// Route each result to its function, restoring the unsuffixed query ID
+		perFunction := make([][]types.MetricDataResult, len(chunk))
+		for _, result := range results {
+			id, index, ok := splitBatchID(aws.ToString(result.Id))
+			if !ok || index >= len(chunk) {
+				continue
+			}
+			result.Id = aws.String(id)
+			perFunction[index] = append(perFunction[index], result)
+		}
+
+		for i, functionName := range chunk {
+			batch[functionName] = newLambdaMetrics(functionName, startTime, endTime, perFunction[i], units)
+		}
+	}
+
+	return batch, nil
+}
+
+// splitBatchID splits a batched query ID such as "errors_3" into "errors" and 3
+func splitBatchID(id string) (string, int, bool) {
+	separator := strings.LastIndex(id, "_")
+	if separator < 0 {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(id[separator+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return id[:separator], index, true
+}
+
+// lambdaMetricsQueries returns the queries behind LambdaMetrics for one function, with suffix
+// appended to every query ID
+func lambdaMetricsQueries(functionName, suffix string, period int32) []types.MetricDataQuery {
+	return []types.MetricDataQuery{
+		lambdaMetricQuery("invocations"+suffix, "Invocations", functionName, "Sum", period),
+		lambdaMetricQuery("errors"+suffix, "Errors", functionName, "Sum", period),
+		lambdaMetricQuery("duration"+suffix, "Duration", functionName, "Average", period),
+		lambdaMetricQuery("throttles"+suffix, "Throttles", functionName, "Sum", period),
+		lambdaMetricQuery("concurrent"+suffix, "ConcurrentExecutions", functionName, "Maximum", period),
+		lambdaMetricQuery("asyncReceived"+suffix, "AsyncEventsReceived", functionName, "Sum", period),
+	}
+}
+
+// newLambdaMetrics aggregates one function's GetMetricData results, keyed by unsuffixed
+// query ID, into LambdaMetrics
+func newLambdaMetrics(functionName string, startTime, endTime time.Time, results []types.MetricDataResult, units map[string]string) *LambdaMetrics {
+	metrics := &LambdaMetrics{
+		FunctionName: functionName,
+		Period:       timerange.NewPeriod(startTime, endTime),
+		Series:       make(map[string][]MetricDatapoint, len(units)),
+		DataAsOf:     latestTimestamp(results),
+	}
+
+	for _, metricResult := range results {
 		if metricResult.Id == nil || len(metricResult.Values) == 0 {
 			continue
 		}
@@ -189,43 +206,231 @@ func (c *CloudWatchClient) GetLambdaMetrics(ctx context.Context, functionName st
 				}
 			}
 			metrics.ConcurrentExecutions = maxConcurrent
+		case "asyncReceived":
+			metrics.AsyncEventsReceived = total
 		}
 
-		// Add datapoints for time series (only for invocations to avoid duplication)
+		// Keep every metric's datapoints in its own series, labelled with its real unit
+		datapoints := resultDatapoints(metricResult, units[*metricResult.Id])
+		metrics.Series[*metricResult.Id] = datapoints
+
+		// Datapoints holds the invocations series for existing consumers
 		if *metricResult.Id == "invocations" {
-			for i, timestamp := range metricResult.Timestamps {
-				if i < len(metricResult.Values) {
-					metrics.Datapoints = append(metrics.Datapoints, MetricDatapoint{
-						Timestamp: timestamp,
-						Value:     metricResult.Values[i],
-						Unit:      "Count",
-					})
-				}
+			metrics.Datapoints = datapoints
+		}
+	}
+
+	metrics.ErrorRate = ErrorRate(metrics.Invocations, metrics.Errors)
+	metrics.AdjustedErrorRate = AdjustedErrorRate(metrics.Invocations, metrics.Errors, metrics.AsyncEventsReceived)
+	metrics.SuccessRate = SuccessRate(metrics.Invocations, metrics.Errors)
+
+	return metrics
+}
+
+// LambdaStatistics represents a Lambda function's metrics at mixed statistics
+type LambdaStatistics struct {
+	FunctionName    string           `json:"functionName"`
+	InvocationsSum  float64          `json:"invocationsSum"`
+	ErrorsSum       float64          `json:"errorsSum"`
+	DurationAverage float64          `json:"durationAverage"`
+	DurationP99     float64          `json:"durationP99"`
+	ConcurrencyMax  float64          `json:"concurrencyMax"`
+	EstimatedCost   float64          `json:"estimatedCost,omitempty"`
+	Period          timerange.Period `json:"period"`
+}
+
+// GetLambdaStatistics retrieves sums, average and p99 duration, and max concurrency in a single request.
+// The query period spans the whole range so CloudWatch computes each statistic over it exactly.
+func (c *CloudWatchClient) GetLambdaStatistics(ctx context.Context, functionName string, startTime, endTime time.Time) (*LambdaStatistics, error) {
+	stats := &LambdaStatistics{
+		FunctionName: functionName,
+		Period:       timerange.NewPeriod(startTime, endTime),
+	}
+
+	period := rangePeriodSeconds(startTime, endTime)
+	queries := []types.MetricDataQuery{
+		lambdaMetricQuery("invocations", "Invocations", functionName, "Sum", period),
+		lambdaMetricQuery("errors", "Errors", functionName, "Sum", period),
+		lambdaMetricQuery("durationAvg", "Duration", functionName, "Average", period),
+		lambdaMetricQuery("durationP99", "Duration", functionName, "p99", period),
+		lambdaMetricQuery("concurrencyMax", "ConcurrentExecutions", functionName, "Maximum", period),
+	}
+
+	results, err := getAllMetricData(ctx, c.client, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         &startTime,
+		EndTime:           &endTime,
+	}, c.maxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Lambda statistics: %w", err)
+	}
+
+	for _, metricResult := range results {
+		if metricResult.Id == nil || len(metricResult.Values) == 0 {
+			continue
+		}
+
+		// A range-wide period yields a single value; combine defensively if CloudWatch splits it
+		switch *metricResult.Id {
+		case "invocations":
+			stats.InvocationsSum = sumValues(metricResult.Values)
+		case "errors":
+			stats.ErrorsSum = sumValues(metricResult.Values)
+		case "durationAvg":
+			stats.DurationAverage = sumValues(metricResult.Values) / float64(len(metricResult.Values))
+		case "durationP99":
+			stats.DurationP99 = maxValue(metricResult.Values)
+		case "concurrencyMax":
+			stats.ConcurrencyMax = maxValue(metricResult.Values)
+		}
+	}
+
+	return stats, nil
+}
+
+// ErrorRate returns the raw Lambda error rate as a percentage of invocations
+func ErrorRate(invocations, errors float64) float64 {
+	if invocations <= 0 {
+		return 0
+	}
+	return (errors / invocations) * 100
+}
+
+// SuccessRate returns the percentage of invocations that succeeded, or nil when there were no
+// invocations, since a function that never ran neither succeeded nor failed
+func SuccessRate(invocations, errors float64) *float64 {
+	if invocations <= 0 {
+		return nil
+	}
+	rate := 100 - ErrorRate(invocations, errors)
+	return &rate
+}
+
+// AdjustedErrorRate returns the error rate over unique events, removing Lambda's automatic
+// async retries. Synchronous functions report no async events and get the raw rate.
+func AdjustedErrorRate(invocations, errors, asyncEvents float64) float64 {
+	events, failures := UniqueEventCounts(invocations, errors, asyncEvents)
+	return ErrorRate(events, failures)
+}
+
+// UniqueEventCounts returns the number of unique events and final failures for a function.
+// Each async retry is an extra invocation triggered by a failed attempt, so retries are
+// Invocations - AsyncEventsReceived and final failures are Errors minus those retries.
+func UniqueEventCounts(invocations, errors, asyncEvents float64) (float64, float64) {
+	if asyncEvents <= 0 {
+		return invocations, errors
+	}
+
+	retries := invocations - asyncEvents
+	if retries < 0 {
+		retries = 0
+	}
+	failures := errors - retries
+	if failures < 0 {
+		failures = 0
+	}
+	return asyncEvents, failures
+}
+
+// lambdaMetricQuery builds a metric query for a Lambda function
+func lambdaMetricQuery(id, metricName, functionName, stat string, period int32) types.MetricDataQuery {
+	return types.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &types.MetricStat{
+			Metric: &types.Metric{
+				Namespace:  aws.String("AWS/Lambda"),
+				MetricName: aws.String(metricName),
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("FunctionName"),
+						Value: aws.String(functionName),
+					},
+				},
+			},
+			Period: aws.Int32(period),
+			Stat:   aws.String(stat),
+		},
+		ReturnData: aws.Bool(true),
+	}
+}
+
+// rangePeriodSeconds returns a CloudWatch period covering the whole range.
+// Periods must be multiples of 60 seconds, and of an hour for data older than 15 days.
+func rangePeriodSeconds(startTime, endTime time.Time) int32 {
+	seconds := int64(endTime.Sub(startTime).Seconds())
+	granularity := int64(60)
+	if endTime.Sub(startTime) > 15*24*time.Hour {
+		granularity = 3600
+	}
+	if seconds < granularity {
+		return int32(granularity)
+	}
+	return int32((seconds + granularity - 1) / granularity * granularity)
+}
+
+// latestTimestamp returns the newest datapoint timestamp across results, or nil if there is no data
+func latestTimestamp(results []types.MetricDataResult) *time.Time {
+	var latest *time.Time
+	for _, result := range results {
+		for i := range result.Timestamps {
+			if latest == nil || result.Timestamps[i].After(*latest) {
+				latest = &result.Timestamps[i]
 			}
 		}
 	}
+	return latest
+}
 
-	return metrics, nil
+// sumValues returns the sum of metric values
+func sumValues(values []float64) float64 {
+	var total float64
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
+
+// maxValue returns the largest metric value
+func maxValue(values []float64) float64 {
+	var largest float64
+	for _, value := range values {
+		if value > largest {
+			largest = value
+		}
+	}
+	return largest
 }
 
 // APIGatewayMetrics represents API Gateway metrics
 type APIGatewayMetrics struct {
-	APIName      string              `json:"apiName"`
-	Count        float64             `json:"count"`
-	Latency      float64             `json:"latency"`
-	Error4XX     float64             `json:"error4xx"`
-	Error5XX     float64             `json:"error5xx"`
-	Period       string              `json:"period"`
-	Datapoints   []MetricDatapoint   `json:"datapoints"`
+	APIName               string                       `json:"apiName"`
+	Count                 float64                      `json:"count"`
+	Latency               float64                      `json:"latency"`
+	Error4XX              float64                      `json:"error4xx"`
+	Error5XX              float64                      `json:"error5xx"`
+	LatencyP50            float64                      `json:"latencyP50"`
+	LatencyP95            float64                      `json:"latencyP95"`
+	LatencyP99            float64                      `json:"latencyP99"`
+	IntegrationLatencyP50 float64                      `json:"integrationLatencyP50"`
+	IntegrationLatencyP95 float64                      `json:"integrationLatencyP95"`
+	IntegrationLatencyP99 float64                      `json:"integrationLatencyP99"`
+	Period                timerange.Period             `json:"period"`
+	Datapoints            []MetricDatapoint            `json:"datapoints"`
+	Series                map[string][]MetricDatapoint `json:"series,omitempty"`
+	Rates                 map[string]CounterRate       `json:"rates,omitempty"` // Only when a rate unit is requested
+	DataAsOf              *time.Time                   `json:"dataAsOf,omitempty"`
 }
 
 // GetAPIGatewayMetrics retrieves metrics for an API Gateway
 func (c *CloudWatchClient) GetAPIGatewayMetrics(ctx context.Context, apiName string, startTime, endTime time.Time) (*APIGatewayMetrics, error) {
 	metrics := &APIGatewayMetrics{
 		APIName: apiName,
-		Period:  fmt.Sprintf("%s to %s", startTime.Format(time.RFC3339), endTime.Format(time.RFC3339)),
+		Period:  timerange.NewPeriod(startTime, endTime),
 	}
 
+	// Period scales with the range unless the request asked for one
+	period := MetricPeriod(ctx, startTime, endTime)
+
 	// Define metric queries
 	queries := []types.MetricDataQuery{
 		{
@@ -241,7 +446,7 @@ func (c *CloudWatchClient) GetAPIGatewayMetrics(ctx context.Context, apiName str
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
@@ -259,7 +464,7 @@ func (c *CloudWatchClient) GetAPIGatewayMetrics(ctx context.Context, apiName str
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Average"),
 			},
 			ReturnData: aws.Bool(true),
@@ -277,7 +482,7 @@ func (c *CloudWatchClient) GetAPIGatewayMetrics(ctx context.Context, apiName str
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
@@ -295,31 +500,60 @@ func (c *CloudWatchClient) GetAPIGatewayMetrics(ctx context.Context, apiName str
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
 		},
 	}
 
+	// Percentiles can't be combined across periods, so they use a single range-wide period
+	// and are reported as values rather than series
+	rangePeriod := rangePeriodSeconds(startTime, endTime)
+	percentileQueries := []struct {
+		id, metricName, stat string
+		value                *float64
+	}{
+		{"latencyP50", "Latency", "p50", &metrics.LatencyP50},
+		{"latencyP95", "Latency", "p95", &metrics.LatencyP95},
+		{"latencyP99", "Latency", "p99", &metrics.LatencyP99},
+		{"integrationLatencyP50", "IntegrationLatency", "p50", &metrics.IntegrationLatencyP50},
+		{"integrationLatencyP95", "IntegrationLatency", "p95", &metrics.IntegrationLatencyP95},
+		{"integrationLatencyP99", "IntegrationLatency", "p99", &metrics.IntegrationLatencyP99},
+	}
+	percentiles := make(map[string]*float64, len(percentileQueries))
+	for _, percentile := range percentileQueries {
+		queries = append(queries, apiGatewayMetricQuery(percentile.id, percentile.metricName, apiName, percentile.stat, rangePeriod))
+		percentiles[percentile.id] = percentile.value
+	}
+
 	// Get metric data
 	input := &cloudwatch.GetMetricDataInput{
 		MetricDataQueries: queries,
-		StartTime:        &startTime,
-		EndTime:          &endTime,
+		StartTime:         &startTime,
+		EndTime:           &endTime,
 	}
 
-	result, err := c.client.GetMetricData(ctx, input)
+	results, err := getAllMetricData(ctx, c.client, input, c.maxAttempts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get API Gateway metrics: %w", err)
 	}
 
 	// Process results
-	for _, metricResult := range result.MetricDataResults {
+	units := queryUnits(queries)
+	metrics.Series = make(map[string][]MetricDatapoint, len(queries))
+	metrics.DataAsOf = latestTimestamp(results)
+	for _, metricResult := range results {
 		if metricResult.Id == nil || len(metricResult.Values) == 0 {
 			continue
 		}
 
+		// A range-wide period yields a single value; take the worst if CloudWatch splits it
+		if percentile, ok := percentiles[*metricResult.Id]; ok {
+			*percentile = maxValue(metricResult.Values)
+			continue
+		}
+
 		// Calculate sum of all values for count metrics
 		var total float64
 		for _, value := range metricResult.Values {
@@ -342,19 +576,15 @@ func (c *CloudWatchClient) GetAPIGatewayMetrics(ctx context.Context, apiName str
 			metrics.Error5XX = total
 		}
 
-		// Add datapoints for time series (only for count to avoid duplication)
+		// Keep every metric's datapoints in its own series, labelled with its real unit
+		datapoints := resultDatapoints(metricResult, units[*metricResult.Id])
+		metrics.Series[*metricResult.Id] = datapoints
+
+		// Datapoints holds the count series for existing consumers
 		if *metricResult.Id == "count" {
-			for i, timestamp := range metricResult.Timestamps {
-				if i < len(metricResult.Values) {
-					metrics.Datapoints = append(metrics.Datapoints, MetricDatapoint{
-						Timestamp: timestamp,
-						Value:     metricResult.Values[i],
-						Unit:      "Count",
-					})
-				}
-			}
+			metrics.Datapoints = datapoints
 		}
 	}
 
 	return metrics, nil
-}
\ No newline at end of file
+}
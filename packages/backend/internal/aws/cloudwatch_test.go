@@ -0,0 +1,295 @@
+package aws
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+func TestGetLambdaStatisticsQueriesMixedStatistics(t *testing.T) {
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	if _, err := client.GetLambdaStatistics(context.Background(), "checkout", pageStart, pageEnd); err != nil {
+		t.Fatalf("GetLambdaStatistics: %v", err)
+	}
+	if len(fake.inputs) != 1 {
+		t.Fatalf("GetMetricData called %d times, want once", len(fake.inputs))
+	}
+
+	want := map[string]struct{ metricName, stat string }{
+		"invocations":    {"Invocations", "Sum"},
+		"errors":         {"Errors", "Sum"},
+		"durationAvg":    {"Duration", "Average"},
+		"durationP99":    {"Duration", "p99"},
+		"concurrencyMax": {"ConcurrentExecutions", "Maximum"},
+	}
+	queries := fake.inputs[0].MetricDataQueries
+	if len(queries) != len(want) {
+		t.Fatalf("got %d queries, want %d", len(queries), len(want))
+	}
+	for _, query := range queries {
+		id := aws.ToString(query.Id)
+		w, ok := want[id]
+		if !ok {
+			t.Errorf("unexpected query %q", id)
+			continue
+		}
+		stat := query.MetricStat
+		if got := aws.ToString(stat.Metric.MetricName); got != w.metricName {
+			t.Errorf("%s metric = %q, want %q", id, got, w.metricName)
+		}
+		if got := aws.ToString(stat.Stat); got != w.stat {
+			t.Errorf("%s stat = %q, want %q", id, got, w.stat)
+		}
+		if got := aws.ToString(stat.Metric.Dimensions[0].Value); got != "checkout" {
+			t.Errorf("%s function = %q, want checkout", id, got)
+		}
+		// One period spanning the 4-hour range, so each statistic covers all of it
+		if got := aws.ToInt32(stat.Period); got != 4*3600 {
+			t.Errorf("%s period = %d, want %d", id, got, 4*3600)
+		}
+	}
+}
+
+func TestGetLambdaStatisticsCombinesResults(t *testing.T) {
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []types.MetricDataResult{
+			{Id: aws.String("invocations"), Values: []float64{1200}},
+			{Id: aws.String("errors"), Values: []float64{12}},
+			{Id: aws.String("durationAvg"), Values: []float64{85.5}},
+			{Id: aws.String("durationP99"), Values: []float64{640}},
+			{Id: aws.String("concurrencyMax"), Values: []float64{14}},
+		},
+	}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	stats, err := client.GetLambdaStatistics(context.Background(), "checkout", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetLambdaStatistics: %v", err)
+	}
+
+	want := LambdaStatistics{
+		FunctionName:    "checkout",
+		InvocationsSum:  1200,
+		ErrorsSum:       12,
+		DurationAverage: 85.5,
+		DurationP99:     640,
+		ConcurrencyMax:  14,
+		Period:          timerange.NewPeriod(pageStart, pageEnd),
+	}
+	if *stats != want {
+		t.Errorf("statistics = %+v, want %+v", *stats, want)
+	}
+}
+
+func TestLatestTimestamp(t *testing.T) {
+	results := []types.MetricDataResult{
+		{Id: aws.String("invocations"), Values: []float64{1, 2}, Timestamps: []time.Time{hour(1), hour(0)}},
+		{Id: aws.String("errors"), Values: []float64{1}, Timestamps: []time.Time{hour(3)}},
+		{Id: aws.String("throttles")},
+	}
+
+	if got := latestTimestamp(results); got == nil || !got.Equal(hour(3)) {
+		t.Errorf("latestTimestamp = %v, want %v", got, hour(3))
+	}
+	if got := latestTimestamp(results[2:]); got != nil {
+		t.Errorf("latestTimestamp without datapoints = %v, want nil", got)
+	}
+}
+
+func TestAdjustedErrorRate(t *testing.T) {
+	tests := []struct {
+		name                             string
+		invocations, errors, asyncEvents float64
+		wantRaw, wantAdjusted            float64
+	}{
+		// Synchronous functions report no async events, so both rates match
+		{name: "sync", invocations: 200, errors: 10, wantRaw: 5, wantAdjusted: 5},
+		// 100 events, 20 retries: 10 events failed every attempt, 10 recovered on a retry
+		{name: "async with retries", invocations: 120, errors: 30, asyncEvents: 100, wantRaw: 25, wantAdjusted: 10},
+		{name: "async without failures", invocations: 50, asyncEvents: 50},
+		{name: "more retries than errors", invocations: 130, errors: 20, asyncEvents: 100, wantRaw: 20 / 1.3, wantAdjusted: 0},
+		{name: "no invocations"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := ErrorRate(tt.invocations, tt.errors)
+			adjusted := AdjustedErrorRate(tt.invocations, tt.errors, tt.asyncEvents)
+			if math.Abs(raw-tt.wantRaw) > 1e-9 || math.Abs(adjusted-tt.wantAdjusted) > 1e-9 {
+				t.Errorf("rates = %v raw, %v adjusted, want %v, %v", raw, adjusted, tt.wantRaw, tt.wantAdjusted)
+			}
+			if adjusted > raw {
+				t.Errorf("adjusted rate %v exceeds raw rate %v", adjusted, raw)
+			}
+		})
+	}
+}
+
+func TestGetLambdaMetricsAdjustsAsyncErrorRate(t *testing.T) {
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []types.MetricDataResult{
+			{Id: aws.String("invocations"), Values: []float64{120}, Timestamps: []time.Time{hour(0)}},
+			{Id: aws.String("errors"), Values: []float64{30}, Timestamps: []time.Time{hour(0)}},
+			{Id: aws.String("asyncReceived"), Values: []float64{100}, Timestamps: []time.Time{hour(0)}},
+		},
+	}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	metrics, err := client.GetLambdaMetrics(context.Background(), "worker", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetLambdaMetrics: %v", err)
+	}
+	if metrics.AsyncEventsReceived != 100 || metrics.ErrorRate != 25 || metrics.AdjustedErrorRate != 10 {
+		t.Errorf("async events %v, error rate %v, adjusted %v, want 100, 25, 10", metrics.AsyncEventsReceived, metrics.ErrorRate, metrics.AdjustedErrorRate)
+	}
+}
+
+func TestGetAPIGatewayMetricsParsesPercentiles(t *testing.T) {
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []types.MetricDataResult{
+			{Id: aws.String("count"), Values: []float64{600, 400}, Timestamps: []time.Time{hour(0), hour(1)}},
+			{Id: aws.String("latency"), Values: []float64{90, 110}, Timestamps: []time.Time{hour(0), hour(1)}},
+			{Id: aws.String("latencyP50"), Values: []float64{72}, Timestamps: []time.Time{hour(0)}},
+			{Id: aws.String("latencyP95"), Values: []float64{480}, Timestamps: []time.Time{hour(0)}},
+			{Id: aws.String("latencyP99"), Values: []float64{2400, 3100}, Timestamps: []time.Time{hour(0), hour(2)}},
+			{Id: aws.String("integrationLatencyP50"), Values: []float64{60}, Timestamps: []time.Time{hour(0)}},
+			{Id: aws.String("integrationLatencyP95"), Values: []float64{410}, Timestamps: []time.Time{hour(0)}},
+			{Id: aws.String("integrationLatencyP99"), Values: []float64{2900}, Timestamps: []time.Time{hour(0)}},
+		},
+	}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	metrics, err := client.GetAPIGatewayMetrics(context.Background(), "orders-api", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetAPIGatewayMetrics: %v", err)
+	}
+
+	// A percentile split across periods reports its worst value
+	want := map[string][2]float64{
+		"LatencyP50":            {metrics.LatencyP50, 72},
+		"LatencyP95":            {metrics.LatencyP95, 480},
+		"LatencyP99":            {metrics.LatencyP99, 3100},
+		"IntegrationLatencyP50": {metrics.IntegrationLatencyP50, 60},
+		"IntegrationLatencyP95": {metrics.IntegrationLatencyP95, 410},
+		"IntegrationLatencyP99": {metrics.IntegrationLatencyP99, 2900},
+	}
+	for name, values := range want {
+		if values[0] != values[1] {
+			t.Errorf("%s = %v, want %v", name, values[0], values[1])
+		}
+	}
+	if metrics.Count != 1000 || metrics.Latency != 100 {
+		t.Errorf("count = %v, latency = %v, want 1000 and an average of 100", metrics.Count, metrics.Latency)
+	}
+	if _, ok := metrics.Series["latencyP99"]; ok {
+		t.Error("percentiles are reported as series")
+	}
+
+	// Each percentile is its own extended-statistic query over one range-wide period
+	wantQueries := map[string]struct{ metricName, stat string }{
+		"latencyP50":            {"Latency", "p50"},
+		"latencyP95":            {"Latency", "p95"},
+		"latencyP99":            {"Latency", "p99"},
+		"integrationLatencyP50": {"IntegrationLatency", "p50"},
+		"integrationLatencyP95": {"IntegrationLatency", "p95"},
+		"integrationLatencyP99": {"IntegrationLatency", "p99"},
+	}
+	found := 0
+	for _, query := range fake.inputs[0].MetricDataQueries {
+		id := aws.ToString(query.Id)
+		w, ok := wantQueries[id]
+		if !ok {
+			continue
+		}
+		found++
+		stat := query.MetricStat
+		if aws.ToString(stat.Metric.MetricName) != w.metricName || aws.ToString(stat.Stat) != w.stat {
+			t.Errorf("%s queries %s %s, want %s %s", id, aws.ToString(stat.Metric.MetricName), aws.ToString(stat.Stat), w.metricName, w.stat)
+		}
+		if got := aws.ToInt32(stat.Period); got != 4*3600 {
+			t.Errorf("%s period = %d, want %d", id, got, 4*3600)
+		}
+	}
+	if found != len(wantQueries) {
+		t.Errorf("found %d percentile queries, want %d", found, len(wantQueries))
+	}
+}
+
+func TestSuccessRate(t *testing.T) {
+	tests := []struct {
+		name                string
+		invocations, errors float64
+		want                *float64
+	}{
+		{name: "with errors", invocations: 200, errors: 10, want: aws.Float64(95)},
+		{name: "no errors", invocations: 50, want: aws.Float64(100)},
+		{name: "every invocation failed", invocations: 8, errors: 8, want: aws.Float64(0)},
+		// A function that never ran neither succeeded nor failed
+		{name: "zero invocations", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SuccessRate(tt.invocations, tt.errors)
+			if (got == nil) != (tt.want == nil) || got != nil && math.Abs(*got-*tt.want) > 1e-9 {
+				t.Errorf("SuccessRate(%v, %v) = %v, want %v", tt.invocations, tt.errors, formatRate(got), formatRate(tt.want))
+			}
+		})
+	}
+}
+
+func formatRate(rate *float64) string {
+	if rate == nil {
+		return "null"
+	}
+	return strconv.FormatFloat(*rate, 'g', -1, 64)
+}
+
+func TestGetLambdaMetricsSuccessRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []types.MetricDataResult
+		want    string
+	}{
+		{
+			name: "with errors",
+			results: []types.MetricDataResult{
+				{Id: aws.String("invocations"), Values: []float64{400}, Timestamps: []time.Time{hour(0)}},
+				{Id: aws.String("errors"), Values: []float64{4}, Timestamps: []time.Time{hour(0)}},
+			},
+			want: "99",
+		},
+		{
+			name: "no errors",
+			results: []types.MetricDataResult{
+				{Id: aws.String("invocations"), Values: []float64{400}, Timestamps: []time.Time{hour(0)}},
+			},
+			want: "100",
+		},
+		{name: "zero invocations", want: "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{MetricDataResults: tt.results}}}}
+			client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+			metrics, err := client.GetLambdaMetrics(context.Background(), "worker", pageStart, pageEnd)
+			if err != nil {
+				t.Fatalf("GetLambdaMetrics: %v", err)
+			}
+			if got := formatRate(metrics.SuccessRate); got != tt.want {
+				t.Errorf("success rate = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// DefaultPerAppConcurrency is how many AWS calls one app may have in flight at once
+const DefaultPerAppConcurrency = 8
+
+// appIDContextKey carries the app an AWS call is made on behalf of
+type appIDContextKey struct{}
+
+// WithAppID tags ctx so AWS calls made with it count against appID's concurrency limit
+func WithAppID(ctx context.Context, appID string) context.Context {
+	return context.WithValue(ctx, appIDContextKey{}, appID)
+}
+
+// appIDFromContext returns the app set by WithAppID, or "" if none
+func appIDFromContext(ctx context.Context) string {
+	appID, _ := ctx.Value(appIDContextKey{}).(string)
+	return appID
+}
+
+// AppConcurrency reports one app's in-flight AWS calls
+type AppConcurrency struct {
+	AppID    string `json:"appId"`
+	InFlight int    `json:"inFlight"`
+	Limit    int    `json:"limit"`
+}
+
+// ConcurrencyLimiter gives each app its own semaphore for outbound AWS calls, so a heavy
+// query for one app waits on its own slots instead of starving every other app's dashboard
+type ConcurrencyLimiter struct {
+	perApp int
+
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// NewConcurrencyLimiter creates a limiter allowing perApp concurrent calls per app
+func NewConcurrencyLimiter(perApp int) *ConcurrencyLimiter {
+	if perApp <= 0 {
+		perApp = DefaultPerAppConcurrency
+	}
+	return &ConcurrencyLimiter{
+		perApp: perApp,
+		slots:  make(map[string]chan struct{}),
+	}
+}
+
+// semaphore returns appID's semaphore, creating it on first use
+func (l *ConcurrencyLimiter) semaphore(appID string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem, ok := l.slots[appID]
+	if !ok {
+		sem = make(chan struct{}, l.perApp)
+		l.slots[appID] = sem
+	}
+	return sem
+}
+
+// Acquire waits for one of appID's slots, returning a function that releases it.
+// It fails with the context's error if ctx is done first.
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context, appID string) (func(), error) {
+	sem := l.semaphore(appID)
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InFlight reports the current in-flight calls for every app seen so far
+func (l *ConcurrencyLimiter) InFlight() []AppConcurrency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	apps := make([]AppConcurrency, 0, len(l.slots))
+	for appID, sem := range l.slots {
+		apps = append(apps, AppConcurrency{AppID: appID, InFlight: len(sem), Limit: l.perApp})
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].AppID < apps[j].AppID })
+	return apps
+}
+
+// Middleware returns an API option that holds one of the calling app's slots for the duration
+// of each AWS call, including retries. Calls without an app ID (background workers) are not
+// limited. Add it to aws.Config.APIOptions after WithQueryTimeout so time spent waiting for a
+// slot does not count against the query timeout.
+func (l *ConcurrencyLimiter) Middleware() func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("AppConcurrencyLimit",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				appID := appIDFromContext(ctx)
+				if appID == "" {
+					return next.HandleInitialize(ctx, in)
+				}
+
+				release, err := l.Acquire(ctx, appID)
+				if err != nil {
+					return middleware.InitializeOutput{}, middleware.Metadata{}, err
+				}
+				defer release()
+				return next.HandleInitialize(ctx, in)
+			}), middleware.Before)
+	}
+}
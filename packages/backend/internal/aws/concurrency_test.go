@@ -0,0 +1,94 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// blockingCalls runs calls through a stack with the limiter's middleware applied. Each call
+// signals started once it holds a slot, then waits for release.
+func blockingCalls(t *testing.T, limiter *ConcurrencyLimiter, started chan<- struct{}, release <-chan struct{}) func(ctx context.Context) error {
+	t.Helper()
+
+	stack := middleware.NewStack("GetMetricData", func() interface{} { return struct{}{} })
+	if err := limiter.Middleware()(stack); err != nil {
+		t.Fatalf("Middleware: %v", err)
+	}
+	handler := middleware.DecorateHandler(middleware.HandlerFunc(func(ctx context.Context, input interface{}) (interface{}, middleware.Metadata, error) {
+		started <- struct{}{}
+		<-release
+		return struct{}{}, middleware.Metadata{}, nil
+	}), stack)
+
+	return func(ctx context.Context) error {
+		_, _, err := handler.Handle(ctx, nil)
+		return err
+	}
+}
+
+func TestConcurrencyLimiterIsolatesApps(t *testing.T) {
+	limiter := NewConcurrencyLimiter(2)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	call := blockingCalls(t, limiter, started, release)
+
+	// Saturate app A
+	done := make(chan error, 10)
+	for i := 0; i < 2; i++ {
+		go func() { done <- call(WithAppID(context.Background(), "app-a")) }()
+	}
+	for i := 0; i < 2; i++ {
+		<-started
+	}
+
+	// A third call for app A waits for a slot until its context gives up
+	ctx, cancel := context.WithTimeout(WithAppID(context.Background(), "app-a"), 20*time.Millisecond)
+	defer cancel()
+	if err := call(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("call over app A's limit: err = %v, want context.DeadlineExceeded", err)
+	}
+
+	// App B and unattributed calls still proceed
+	go func() { done <- call(WithAppID(context.Background(), "app-b")) }()
+	go func() { done <- call(context.Background()) }()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("app B was starved by app A's load")
+		}
+	}
+
+	want := []AppConcurrency{{AppID: "app-a", InFlight: 2, Limit: 2}, {AppID: "app-b", InFlight: 1, Limit: 2}}
+	if got := limiter.InFlight(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("InFlight = %+v, want %+v", got, want)
+	}
+
+	close(release)
+	for i := 0; i < 4; i++ {
+		if err := <-done; err != nil {
+			t.Errorf("call: %v", err)
+		}
+	}
+	for _, app := range limiter.InFlight() {
+		if app.InFlight != 0 {
+			t.Errorf("%s has %d calls in flight after all returned", app.AppID, app.InFlight)
+		}
+	}
+}
+
+func TestNewConcurrencyLimiterDefault(t *testing.T) {
+	limiter := NewConcurrencyLimiter(0)
+	release, err := limiter.Acquire(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer release()
+	if got := limiter.InFlight(); len(got) != 1 || got[0].Limit != DefaultPerAppConcurrency {
+		t.Errorf("InFlight = %+v, want the default limit", got)
+	}
+}
@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// ErrNoAnomalyMonitor is returned when the account has no Cost Anomaly Detection monitor, so
+// there are no anomalies to report
+var ErrNoAnomalyMonitor = errors.New("no cost anomaly monitor configured")
+
+// CostAnomaly is a span of spend Cost Anomaly Detection flagged as abnormally high
+type CostAnomaly struct {
+	ID               string  `json:"id"`
+	StartDate        string  `json:"startDate"`
+	EndDate          string  `json:"endDate,omitempty"` // Empty while the anomaly is ongoing
+	Service          string  `json:"service"`
+	Region           string  `json:"region,omitempty"`
+	UsageType        string  `json:"usageType,omitempty"`
+	ExpectedSpend    float64 `json:"expectedSpend"`
+	ActualSpend      float64 `json:"actualSpend"`
+	Impact           float64 `json:"impact"`
+	ImpactPercentage float64 `json:"impactPercentage"`
+	Score            float64 `json:"score"`
+	MaxScore         float64 `json:"maxScore"`
+}
+
+// GetCostAnomalies returns the anomalies detected between startDate and endDate, oldest first.
+// It returns ErrNoAnomalyMonitor when no monitor exists to detect them.
+func (c *CostExplorerClient) GetCostAnomalies(ctx context.Context, startDate, endDate time.Time) ([]CostAnomaly, error) {
+	monitors, err := c.client.GetAnomalyMonitors(ctx, &costexplorer.GetAnomalyMonitorsInput{
+		MaxResults: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cost anomaly monitors: %w", err)
+	}
+	if len(monitors.AnomalyMonitors) == 0 {
+		return nil, ErrNoAnomalyMonitor
+	}
+
+	input := &costexplorer.GetAnomaliesInput{
+		DateInterval: &types.AnomalyDateInterval{
+			StartDate: aws.String(startDate.Format("2006-01-02")),
+			EndDate:   aws.String(endDate.Format("2006-01-02")),
+		},
+	}
+
+	anomalies := []CostAnomaly{}
+	for {
+		result, err := c.client.GetAnomalies(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cost anomalies: %w", err)
+		}
+		for _, anomaly := range result.Anomalies {
+			anomalies = append(anomalies, newCostAnomaly(anomaly))
+		}
+		if result.NextPageToken == nil {
+			break
+		}
+		input.NextPageToken = result.NextPageToken
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].StartDate < anomalies[j].StartDate
+	})
+	return anomalies, nil
+}
+
+// newCostAnomaly flattens a Cost Explorer anomaly. The impacted service comes from the first
+// root cause naming one, falling back to the monitor's dimension value.
+func newCostAnomaly(anomaly types.Anomaly) CostAnomaly {
+	result := CostAnomaly{
+		ID:        aws.ToString(anomaly.AnomalyId),
+		StartDate: aws.ToString(anomaly.AnomalyStartDate),
+		EndDate:   aws.ToString(anomaly.AnomalyEndDate),
+		Service:   aws.ToString(anomaly.DimensionValue),
+	}
+
+	for _, cause := range anomaly.RootCauses {
+		if cause.Service != nil {
+			result.Service = aws.ToString(cause.Service)
+			result.Region = aws.ToString(cause.Region)
+			result.UsageType = aws.ToString(cause.UsageType)
+			break
+		}
+	}
+
+	if impact := anomaly.Impact; impact != nil {
+		result.ExpectedSpend = aws.ToFloat64(impact.TotalExpectedSpend)
+		result.ActualSpend = aws.ToFloat64(impact.TotalActualSpend)
+		result.Impact = impact.TotalImpact
+		result.ImpactPercentage = aws.ToFloat64(impact.TotalImpactPercentage)
+	}
+	if score := anomaly.AnomalyScore; score != nil {
+		result.Score = score.CurrentScore
+		result.MaxScore = score.MaxScore
+	}
+
+	return result
+}
@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// CostTagFilter restricts cost queries to resources carrying one cost allocation tag value
+type CostTagFilter struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// GetCostAndUsageFiltered retrieves cost and usage data limited by filter; a nil filter
+// returns account-wide spend
+func (c *CostExplorerClient) GetCostAndUsageFiltered(ctx context.Context, startDate, endDate time.Time, filter *types.Expression) (*CostData, error) {
+	return c.getCostAndUsage(ctx, startDate, endDate, filter)
+}
+
+// CostTagExpression builds a filter matching a single cost allocation tag value
+func CostTagExpression(tag CostTagFilter) *types.Expression {
+	return &types.Expression{
+		Tags: &types.TagValues{
+			Key:          aws.String(tag.Key),
+			Values:       []string{tag.Value},
+			MatchOptions: []types.MatchOption{types.MatchOptionEquals},
+		},
+	}
+}
+
+// AppCostFilter builds the filter isolating an app's spend from its Cost Category and cost
+// allocation tag, either of which may be nil. It returns nil, meaning account-wide spend,
+// when neither is configured.
+func AppCostFilter(category *CostCategoryFilter, tag *CostTagFilter) *types.Expression {
+	var filters []*types.Expression
+	if category != nil {
+		filters = append(filters, costCategoryExpression(*category))
+	}
+	if tag != nil {
+		filters = append(filters, CostTagExpression(*tag))
+	}
+	return andCostFilters(filters...)
+}
+
+// andCostFilters combines filters, skipping nil ones. Cost Explorer rejects an And with a
+// single operand, so one filter is returned as is.
+func andCostFilters(filters ...*types.Expression) *types.Expression {
+	var operands []types.Expression
+	for _, filter := range filters {
+		if filter != nil {
+			operands = append(operands, *filter)
+		}
+	}
+
+	switch len(operands) {
+	case 0:
+		return nil
+	case 1:
+		return &operands[0]
+	default:
+		return &types.Expression{And: operands}
+	}
+}
@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestCostTagExpression(t *testing.T) {
+	expression := CostTagExpression(CostTagFilter{Key: "app", Value: "ilikeyacut"})
+
+	want := &types.Expression{Tags: &types.TagValues{
+		Key:          aws.String("app"),
+		Values:       []string{"ilikeyacut"},
+		MatchOptions: []types.MatchOption{types.MatchOptionEquals},
+	}}
+	if !reflect.DeepEqual(expression, want) {
+		t.Errorf("expression = %+v, want %+v", expression, want)
+	}
+	if expression.And != nil || expression.CostCategories != nil || expression.Dimensions != nil {
+		t.Errorf("tag expression also filters on %+v", expression)
+	}
+}
+
+func TestAppCostFilter(t *testing.T) {
+	category := &CostCategoryFilter{Name: "App", Value: "ilikeyacut"}
+	tag := &CostTagFilter{Key: "app", Value: "ilikeyacut"}
+
+	if filter := AppCostFilter(nil, nil); filter != nil {
+		t.Errorf("filter without a category or tag = %+v, want nil for account-wide spend", filter)
+	}
+
+	// A single filter is sent as is, since Cost Explorer rejects a one-operand And
+	if filter := AppCostFilter(nil, tag); !reflect.DeepEqual(filter, CostTagExpression(*tag)) {
+		t.Errorf("tag-only filter = %+v, want the tag expression", filter)
+	}
+	if filter := AppCostFilter(category, nil); !reflect.DeepEqual(filter, costCategoryExpression(*category)) {
+		t.Errorf("category-only filter = %+v, want the category expression", filter)
+	}
+
+	both := AppCostFilter(category, tag)
+	want := []types.Expression{*costCategoryExpression(*category), *CostTagExpression(*tag)}
+	if both == nil || !reflect.DeepEqual(both.And, want) {
+		t.Errorf("filter with both = %+v, want an And of the category and tag", both)
+	}
+}
+
+func TestGetCostAndUsageFilteredSendsFilter(t *testing.T) {
+	fake := cannedCostExplorer()
+	client := &CostExplorerClient{client: fake}
+	filter := AppCostFilter(nil, &CostTagFilter{Key: "app", Value: "ilikeyacut"})
+
+	if _, err := client.GetCostAndUsageFiltered(context.Background(), pageStart, pageEnd, filter); err != nil {
+		t.Fatalf("GetCostAndUsageFiltered: %v", err)
+	}
+	if len(fake.inputs) != 2 {
+		t.Fatalf("GetCostAndUsage called %d times, want daily and by service", len(fake.inputs))
+	}
+	for i, input := range fake.inputs {
+		if input.Filter != filter {
+			t.Errorf("call %d Filter = %+v, want the app's tag filter", i, input.Filter)
+		}
+	}
+
+	// Without a filter the whole account is queried
+	fake.inputs = nil
+	if _, err := client.GetCostAndUsageFiltered(context.Background(), pageStart, pageEnd, nil); err != nil {
+		t.Fatalf("GetCostAndUsageFiltered: %v", err)
+	}
+	for i, input := range fake.inputs {
+		if input.Filter != nil {
+			t.Errorf("unfiltered call %d Filter = %+v, want nil", i, input.Filter)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// Cost Explorer dimensions spend can be grouped by
+const (
+	CostGroupByService = "SERVICE"
+	CostGroupByRegion  = "REGION"
+)
+
+// noRegionCostValue labels spend Cost Explorer doesn't attribute to a region
+const noRegionCostValue = "NoRegion"
+
+// IsCostGroupBy reports whether dimension is a supported cost grouping
+func IsCostGroupBy(dimension string) bool {
+	return dimension == CostGroupByService || dimension == CostGroupByRegion
+}
+
+// CostGroup represents spend for one value of a grouping dimension
+type CostGroup struct {
+	Key        string  `json:"key"`
+	Cost       float64 `json:"cost"`
+	Percentage float64 `json:"percentage"`
+}
+
+// GroupedCost is spend broken down by a dimension, with the combined total across groups.
+// Regions lists the regions spend was restricted to, if any.
+type GroupedCost struct {
+	GroupBy string      `json:"groupBy"`
+	Regions []string    `json:"regions,omitempty"`
+	Total   float64     `json:"total"`
+	Groups  []CostGroup `json:"groups"`
+}
+
+// GetCostGroupedBy groups spend by a Cost Explorer dimension, optionally restricted by filter
+// (see AppCostFilter) and to a set of regions. When grouping by region, every requested region
+// is reported even if it had no spend, so the breakdown always covers the configured set.
+func (c *CostExplorerClient) GetCostGroupedBy(ctx context.Context, dimension string, filter *types.Expression, regions []string, startDate, endDate time.Time) (*GroupedCost, error) {
+	if !IsCostGroupBy(dimension) {
+		return nil, fmt.Errorf("unsupported cost grouping %q", dimension)
+	}
+
+	start := startDate.Format("2006-01-02")
+	end := endDate.Format("2006-01-02")
+
+	input := &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &types.DateInterval{
+			Start: &start,
+			End:   &end,
+		},
+		Granularity: types.GranularityMonthly,
+		Metrics:     []string{"UnblendedCost"},
+		Filter:      groupedCostFilter(filter, regions),
+		GroupBy: []types.GroupDefinition{
+			{
+				Type: types.GroupDefinitionTypeDimension,
+				Key:  aws.String(dimension),
+			},
+		},
+	}
+
+	result, err := c.client.GetCostAndUsage(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get costs by %s: %w", strings.ToLower(dimension), err)
+	}
+
+	totals, total := sumGroupCosts(result.ResultsByTime, func(key string) string {
+		if key == "" && dimension == CostGroupByRegion {
+			return noRegionCostValue
+		}
+		return key
+	})
+	if dimension == CostGroupByRegion {
+		for _, region := range regions {
+			if _, ok := totals[region]; !ok {
+				totals[region] = 0
+			}
+		}
+	}
+
+	grouped := &GroupedCost{
+		GroupBy: dimension,
+		Regions: regions,
+		Total:   total,
+		Groups:  make([]CostGroup, 0, len(totals)),
+	}
+	for key, cost := range totals {
+		group := CostGroup{Key: key, Cost: cost}
+		if total > 0 {
+			group.Percentage = (cost / total) * 100
+		}
+		grouped.Groups = append(grouped.Groups, group)
+	}
+	sort.Slice(grouped.Groups, func(i, j int) bool {
+		if grouped.Groups[i].Cost != grouped.Groups[j].Cost {
+			return grouped.Groups[i].Cost > grouped.Groups[j].Cost
+		}
+		return grouped.Groups[i].Key < grouped.Groups[j].Key
+	})
+
+	return grouped, nil
+}
+
+// groupedCostFilter combines an optional app filter with an optional region filter
+func groupedCostFilter(filter *types.Expression, regions []string) *types.Expression {
+	var regionFilter *types.Expression
+	if len(regions) > 0 {
+		regionFilter = &types.Expression{
+			Dimensions: &types.DimensionValues{
+				Key:    types.DimensionRegion,
+				Values: regions,
+			},
+		}
+	}
+	return andCostFilters(filter, regionFilter)
+}
@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+func TestGetCostGroupedByRegion(t *testing.T) {
+	fake := &fakeCostExplorer{byService: []types.ResultByTime{
+		serviceCosts("2024-04-01", "us-east-1", "20.00", "us-west-2", "5.00", "", "1.00"),
+		serviceCosts("2024-05-01", "us-east-1", "10.00", "us-west-2", "4.00"),
+	}}
+	client := &CostExplorerClient{client: fake}
+	category := AppCostFilter(&CostCategoryFilter{Name: "App", Value: "ilikeyacut"}, nil)
+	regions := []string{"us-east-1", "us-west-2", "eu-west-1"}
+
+	grouped, err := client.GetCostGroupedBy(context.Background(), CostGroupByRegion, category, regions, pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetCostGroupedBy: %v", err)
+	}
+
+	// The combined total spans every region, and each configured region is reported even
+	// without spend
+	if grouped.GroupBy != CostGroupByRegion || !reflect.DeepEqual(grouped.Regions, regions) {
+		t.Errorf("grouped = %s over %v, want REGION over %v", grouped.GroupBy, grouped.Regions, regions)
+	}
+	if math.Abs(grouped.Total-40) > 1e-9 {
+		t.Errorf("Total = %v, want 40", grouped.Total)
+	}
+	want := []CostGroup{
+		{Key: "us-east-1", Cost: 30, Percentage: 75},
+		{Key: "us-west-2", Cost: 9, Percentage: 22.5},
+		{Key: noRegionCostValue, Cost: 1, Percentage: 2.5},
+		{Key: "eu-west-1", Cost: 0, Percentage: 0},
+	}
+	if len(grouped.Groups) != len(want) {
+		t.Fatalf("groups = %+v, want %+v", grouped.Groups, want)
+	}
+	var sum float64
+	for i, w := range want {
+		got := grouped.Groups[i]
+		if got.Key != w.Key || math.Abs(got.Cost-w.Cost) > 1e-9 || math.Abs(got.Percentage-w.Percentage) > 1e-9 {
+			t.Errorf("group %d = %+v, want %+v", i, got, w)
+		}
+		sum += got.Cost
+	}
+	if math.Abs(sum-grouped.Total) > 1e-9 {
+		t.Errorf("groups sum to %v, want the total %v", sum, grouped.Total)
+	}
+
+	// The request groups by region and keeps the app's filter alongside the region filter
+	input := fake.inputs[0]
+	if len(input.GroupBy) != 1 || aws.ToString(input.GroupBy[0].Key) != CostGroupByRegion {
+		t.Errorf("GroupBy = %+v, want REGION", input.GroupBy)
+	}
+	if input.Filter == nil || len(input.Filter.And) != 2 {
+		t.Fatalf("filter = %+v, want the app filter and a region filter", input.Filter)
+	}
+	if !reflect.DeepEqual(input.Filter.And[0], *category) {
+		t.Errorf("first filter = %+v, want the app filter", input.Filter.And[0])
+	}
+	regionFilter := input.Filter.And[1].Dimensions
+	if regionFilter == nil || regionFilter.Key != types.DimensionRegion || !reflect.DeepEqual(regionFilter.Values, regions) {
+		t.Errorf("second filter = %+v, want a region filter on %v", input.Filter.And[1], regions)
+	}
+}
+
+func TestGetCostGroupedByAllRegions(t *testing.T) {
+	fake := &fakeCostExplorer{byService: []types.ResultByTime{
+		serviceCosts("2024-05-01", "us-east-1", "6.00", "ap-southeast-2", "2.00"),
+	}}
+	client := &CostExplorerClient{client: fake}
+
+	grouped, err := client.GetCostGroupedBy(context.Background(), CostGroupByRegion, nil, nil, pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetCostGroupedBy: %v", err)
+	}
+	if fake.inputs[0].Filter != nil {
+		t.Errorf("filter = %+v, want none for account-wide spend in every region", fake.inputs[0].Filter)
+	}
+	if grouped.Total != 8 || len(grouped.Groups) != 2 || grouped.Groups[0].Key != "us-east-1" {
+		t.Errorf("grouped = %+v, want 8 across us-east-1 then ap-southeast-2", grouped)
+	}
+}
+
+func TestGetCostGroupedByRejectsUnsupportedDimension(t *testing.T) {
+	fake := &fakeCostExplorer{}
+	client := &CostExplorerClient{client: fake}
+
+	if _, err := client.GetCostGroupedBy(context.Background(), "USAGE_TYPE", nil, nil, pageStart, pageEnd); err == nil {
+		t.Error("GetCostGroupedBy(USAGE_TYPE) succeeded, want an error")
+	}
+	if len(fake.inputs) != 0 {
+		t.Errorf("unsupported grouping still made %d calls", len(fake.inputs))
+	}
+}
@@ -3,16 +3,28 @@ package aws
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 )
 
+// costExplorerAPI is the part of the Cost Explorer client CostExplorerClient calls,
+// implemented by *costexplorer.Client
+type costExplorerAPI interface {
+	GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error)
+	GetCostForecast(ctx context.Context, params *costexplorer.GetCostForecastInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostForecastOutput, error)
+	GetAnomalyMonitors(ctx context.Context, params *costexplorer.GetAnomalyMonitorsInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomalyMonitorsOutput, error)
+	GetAnomalies(ctx context.Context, params *costexplorer.GetAnomaliesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomaliesOutput, error)
+}
+
 // CostExplorerClient wraps the Cost Explorer client
 type CostExplorerClient struct {
-	client *costexplorer.Client
+	client costExplorerAPI
 }
 
 // NewCostExplorerClient creates a new Cost Explorer client
@@ -24,11 +36,18 @@ func NewCostExplorerClient(cfg aws.Config) *CostExplorerClient {
 
 // CostData represents AWS cost information
 type CostData struct {
-	TotalCost      float64                `json:"totalCost"`
-	Currency       string                 `json:"currency"`
-	Services       []ServiceCost          `json:"services"`
-	DailyCosts     []DailyCost            `json:"dailyCosts"`
-	Period         string                 `json:"period"`
+	TotalCost  float64          `json:"totalCost"`
+	Currency   string           `json:"currency"`
+	Services   []ServiceCost    `json:"services"`
+	DailyCosts []DailyCost      `json:"dailyCosts"`
+	Period     timerange.Period `json:"period"`
+	Raw        *CostExplorerRaw `json:"-"`
+}
+
+// CostExplorerRaw holds the unprocessed Cost Explorer results behind a CostData, for auditing
+type CostExplorerRaw struct {
+	Daily     []types.ResultByTime `json:"daily"`
+	ByService []types.ResultByTime `json:"byService"`
 }
 
 // ServiceCost represents cost breakdown by service
@@ -44,15 +63,42 @@ type DailyCost struct {
 	Cost float64 `json:"cost"`
 }
 
+// CostCategoryFilter restricts cost queries to a single AWS Cost Category value
+type CostCategoryFilter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CategoryCost represents spend for one value of a Cost Category
+type CategoryCost struct {
+	Value      string  `json:"value"`
+	Cost       float64 `json:"cost"`
+	Percentage float64 `json:"percentage"`
+}
+
+// uncategorizedCostValue labels spend that no Cost Category rule matched
+const uncategorizedCostValue = "Uncategorized"
+
 // GetCostAndUsage retrieves cost and usage data
 func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, startDate, endDate time.Time) (*CostData, error) {
+	return c.getCostAndUsage(ctx, startDate, endDate, nil)
+}
+
+// GetCostAndUsageForCategory retrieves cost and usage data limited to one Cost Category value
+func (c *CostExplorerClient) GetCostAndUsageForCategory(ctx context.Context, category CostCategoryFilter, startDate, endDate time.Time) (*CostData, error) {
+	return c.getCostAndUsage(ctx, startDate, endDate, costCategoryExpression(category))
+}
+
+// getCostAndUsage retrieves the daily and per-service breakdown, optionally filtered
+func (c *CostExplorerClient) getCostAndUsage(ctx context.Context, startDate, endDate time.Time, filter *types.Expression) (*CostData, error) {
 	// Format dates for AWS API
 	start := startDate.Format("2006-01-02")
 	end := endDate.Format("2006-01-02")
 
 	costData := &CostData{
 		Currency: "USD",
-		Period:   fmt.Sprintf("%s to %s", start, end),
+		Period:   timerange.NewDatePeriod(startDate, endDate),
+		Raw:      &CostExplorerRaw{},
 	}
 
 	// Get total cost and daily breakdown
@@ -63,12 +109,14 @@ func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, startDate, end
 		},
 		Granularity: types.GranularityDaily,
 		Metrics:     []string{"UnblendedCost"},
+		Filter:      filter,
 	}
 
 	dailyResult, err := c.client.GetCostAndUsage(ctx, dailyInput)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get daily costs: %w", err)
 	}
+	costData.Raw.Daily = dailyResult.ResultsByTime
 
 	// Process daily costs
 	var totalCost float64
@@ -97,10 +145,11 @@ func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, startDate, end
 		},
 		Granularity: types.GranularityMonthly,
 		Metrics:     []string{"UnblendedCost"},
+		Filter:      filter,
 		GroupBy: []types.GroupDefinition{
 			{
-				Type: types.GroupDefinitionTypeTag,
-				Key:  aws.String("SERVICE"),
+				Type: types.GroupDefinitionTypeDimension,
+				Key:  aws.String(string(types.DimensionService)),
 			},
 		},
 	}
@@ -110,31 +159,83 @@ func (c *CostExplorerClient) GetCostAndUsage(ctx context.Context, startDate, end
 		// Log error but continue with available data
 		fmt.Printf("Failed to get service breakdown: %v\n", err)
 	} else {
-		// Process service costs
-		for _, result := range serviceResult.ResultsByTime {
-			for _, group := range result.Groups {
-				if group.Metrics != nil {
-					if costAmount, ok := group.Metrics["UnblendedCost"]; ok {
-						if costAmount.Amount != nil && len(group.Keys) > 0 {
-							cost := parseFloat(*costAmount.Amount)
-							serviceCost := ServiceCost{
-								ServiceName: group.Keys[0],
-								Cost:        cost,
-								Percentage:  (cost / totalCost) * 100,
-							}
-							costData.Services = append(costData.Services, serviceCost)
-						}
-					}
-				}
-			}
-		}
+		costData.Raw.ByService = serviceResult.ResultsByTime
+		costData.Services = groupServiceCosts(serviceResult.ResultsByTime)
 	}
 
 	return costData, nil
 }
 
-// GetForecast retrieves cost forecast data
-func (c *CostExplorerClient) GetForecast(ctx context.Context, days int) (*CostData, error) {
+// groupServiceCosts sums grouped results per service across time periods, ordered by
+// descending cost. Percentages are shares of the grouped total rather than of the daily
+// total, which Cost Explorer rounds separately, so they add up to 100.
+func groupServiceCosts(results []types.ResultByTime) []ServiceCost {
+	totals, total := sumGroupCosts(results, func(key string) string { return key })
+
+	services := make([]ServiceCost, 0, len(totals))
+	for name, cost := range totals {
+		service := ServiceCost{ServiceName: name, Cost: cost}
+		if total > 0 {
+			service.Percentage = (cost / total) * 100
+		}
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Cost > services[j].Cost
+	})
+
+	return services
+}
+
+// OtherServicesName labels the combined cost of untracked services
+const OtherServicesName = "Other"
+
+// GroupUntrackedServices keeps tracked services as individual entries (merged by name) and
+// collapses everything else into a single "Other" entry. Percentages are recomputed
+// against the combined total, and entries are ordered by cost with "Other" last.
+func GroupUntrackedServices(services []ServiceCost, tracked []string) []ServiceCost {
+	trackedSet := make(map[string]bool, len(tracked))
+	for _, name := range tracked {
+		trackedSet[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+
+	var total, other float64
+	hasOther := false
+	indexByName := make(map[string]int)
+	var grouped []ServiceCost
+
+	for _, service := range services {
+		total += service.Cost
+		if !trackedSet[strings.ToLower(service.ServiceName)] {
+			other += service.Cost
+			hasOther = true
+			continue
+		}
+		if i, ok := indexByName[service.ServiceName]; ok {
+			grouped[i].Cost += service.Cost
+			continue
+		}
+		indexByName[service.ServiceName] = len(grouped)
+		grouped = append(grouped, ServiceCost{ServiceName: service.ServiceName, Cost: service.Cost})
+	}
+
+	sort.Slice(grouped, func(i, j int) bool {
+		return grouped[i].Cost > grouped[j].Cost
+	})
+	if hasOther {
+		grouped = append(grouped, ServiceCost{ServiceName: OtherServicesName, Cost: other})
+	}
+
+	for i := range grouped {
+		if total > 0 {
+			grouped[i].Percentage = (grouped[i].Cost / total) * 100
+		}
+	}
+	return grouped
+}
+
+// GetForecast retrieves cost forecast data, limited by filter when it isn't nil
+func (c *CostExplorerClient) GetForecast(ctx context.Context, days int, filter *types.Expression) (*CostData, error) {
 	// Calculate date range
 	startDate := time.Now().AddDate(0, 0, 1) // Start from tomorrow
 	endDate := startDate.AddDate(0, 0, days-1)
@@ -149,6 +250,7 @@ func (c *CostExplorerClient) GetForecast(ctx context.Context, days int) (*CostDa
 		},
 		Metric:      types.MetricUnblendedCost,
 		Granularity: types.GranularityDaily,
+		Filter:      filter,
 	}
 
 	result, err := c.client.GetCostForecast(ctx, input)
@@ -158,8 +260,9 @@ func (c *CostExplorerClient) GetForecast(ctx context.Context, days int) (*CostDa
 
 	costData := &CostData{
 		Currency: "USD",
-		Period:   fmt.Sprintf("%s to %s (forecast)", start, end),
+		Period:   timerange.NewDatePeriod(startDate, endDate),
 	}
+	costData.Period.Display += " (forecast)"
 
 	// Process forecast data
 	if result.Total != nil && result.Total.Amount != nil {
@@ -239,9 +342,110 @@ func (c *CostExplorerClient) GetServiceCosts(ctx context.Context, services []str
 	return serviceCosts, nil
 }
 
+// GetCostByCategory groups spend by the values of a Cost Category
+func (c *CostExplorerClient) GetCostByCategory(ctx context.Context, categoryName string, startDate, endDate time.Time) ([]CategoryCost, error) {
+	start := startDate.Format("2006-01-02")
+	end := endDate.Format("2006-01-02")
+
+	input := &costexplorer.GetCostAndUsageInput{
+		TimePeriod: &types.DateInterval{
+			Start: &start,
+			End:   &end,
+		},
+		Granularity: types.GranularityMonthly,
+		Metrics:     []string{"UnblendedCost"},
+		GroupBy: []types.GroupDefinition{
+			{
+				Type: types.GroupDefinitionTypeCostCategory,
+				Key:  aws.String(categoryName),
+			},
+		},
+	}
+
+	result, err := c.client.GetCostAndUsage(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get costs by category: %w", err)
+	}
+
+	return groupCategoryCosts(result.ResultsByTime, categoryName), nil
+}
+
+// costCategoryExpression builds a filter matching a single Cost Category value
+func costCategoryExpression(category CostCategoryFilter) *types.Expression {
+	return &types.Expression{
+		CostCategories: &types.CostCategoryValues{
+			Key:          aws.String(category.Name),
+			Values:       []string{category.Value},
+			MatchOptions: []types.MatchOption{types.MatchOptionEquals},
+		},
+	}
+}
+
+// groupCategoryCosts sums grouped results per Cost Category value across time periods,
+// ordered by descending cost
+func groupCategoryCosts(results []types.ResultByTime, categoryName string) []CategoryCost {
+	totals, total := sumGroupCosts(results, func(key string) string {
+		return costCategoryGroupValue(key, categoryName)
+	})
+
+	categoryCosts := make([]CategoryCost, 0, len(totals))
+	for value, cost := range totals {
+		categoryCost := CategoryCost{Value: value, Cost: cost}
+		if total > 0 {
+			categoryCost.Percentage = (cost / total) * 100
+		}
+		categoryCosts = append(categoryCosts, categoryCost)
+	}
+	sort.Slice(categoryCosts, func(i, j int) bool {
+		return categoryCosts[i].Cost > categoryCosts[j].Cost
+	})
+
+	return categoryCosts
+}
+
+// sumGroupCosts sums the unblended cost of grouped results per group across time periods,
+// keyed by the group's first key after mapping it with value
+func sumGroupCosts(results []types.ResultByTime, value func(key string) string) (map[string]float64, float64) {
+	totals := make(map[string]float64)
+	var total float64
+
+	for _, result := range results {
+		for _, group := range result.Groups {
+			if len(group.Keys) == 0 {
+				continue
+			}
+			costAmount, ok := group.Metrics["UnblendedCost"]
+			if !ok || costAmount.Amount == nil {
+				continue
+			}
+			cost := parseFloat(*costAmount.Amount)
+			totals[value(group.Keys[0])] += cost
+			total += cost
+		}
+	}
+
+	return totals, total
+}
+
+// costCategoryGroupValue extracts the value from a Cost Category group key.
+// Cost Explorer returns keys as "<category>$<value>", with an empty value for unmatched spend.
+func costCategoryGroupValue(key, categoryName string) string {
+	value := strings.TrimPrefix(key, categoryName+"$")
+	if value == key {
+		// Fall back to splitting on the separator if the category name differs in the key
+		if _, after, found := strings.Cut(key, "$"); found {
+			value = after
+		}
+	}
+	if value == "" {
+		return uncategorizedCostValue
+	}
+	return value
+}
+
 // parseFloat converts string to float64
 func parseFloat(s string) float64 {
 	var f float64
 	fmt.Sscanf(s, "%f", &f)
 	return f
-}
\ No newline at end of file
+}
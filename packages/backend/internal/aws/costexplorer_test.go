@@ -0,0 +1,313 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+)
+
+// fakeCostExplorer answers ungrouped GetCostAndUsage calls with daily and grouped calls with
+// byService (or byServiceErr), recording each input
+type fakeCostExplorer struct {
+	daily        []types.ResultByTime
+	byService    []types.ResultByTime
+	byServiceErr error
+	inputs       []costexplorer.GetCostAndUsageInput
+}
+
+func (f *fakeCostExplorer) GetCostAndUsage(ctx context.Context, params *costexplorer.GetCostAndUsageInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
+	f.inputs = append(f.inputs, *params)
+	if len(params.GroupBy) == 0 {
+		return &costexplorer.GetCostAndUsageOutput{ResultsByTime: f.daily}, nil
+	}
+	if f.byServiceErr != nil {
+		return nil, f.byServiceErr
+	}
+	return &costexplorer.GetCostAndUsageOutput{ResultsByTime: f.byService}, nil
+}
+
+func (f *fakeCostExplorer) GetCostForecast(ctx context.Context, params *costexplorer.GetCostForecastInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetCostForecastOutput, error) {
+	return nil, errors.New("unexpected GetCostForecast call")
+}
+
+func (f *fakeCostExplorer) GetAnomalyMonitors(ctx context.Context, params *costexplorer.GetAnomalyMonitorsInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomalyMonitorsOutput, error) {
+	return nil, errors.New("unexpected GetAnomalyMonitors call")
+}
+
+func (f *fakeCostExplorer) GetAnomalies(ctx context.Context, params *costexplorer.GetAnomaliesInput, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomaliesOutput, error) {
+	return nil, errors.New("unexpected GetAnomalies call")
+}
+
+// unblendedCost is a result's metrics with the given unblended cost
+func unblendedCost(amount string) map[string]types.MetricValue {
+	return map[string]types.MetricValue{"UnblendedCost": {Amount: aws.String(amount), Unit: aws.String("USD")}}
+}
+
+// dailyCost is a one-day ungrouped result
+func dailyCost(date, amount string) types.ResultByTime {
+	return types.ResultByTime{
+		TimePeriod: &types.DateInterval{Start: aws.String(date)},
+		Total:      unblendedCost(amount),
+	}
+}
+
+// serviceCosts is a monthly result grouped by service, with costs given as name, amount pairs
+func serviceCosts(month string, costs ...string) types.ResultByTime {
+	result := types.ResultByTime{TimePeriod: &types.DateInterval{Start: aws.String(month)}}
+	for i := 0; i+1 < len(costs); i += 2 {
+		result.Groups = append(result.Groups, types.Group{Keys: []string{costs[i]}, Metrics: unblendedCost(costs[i+1])})
+	}
+	return result
+}
+
+// cannedCostExplorer is a three-day range spanning a month boundary, whose grouped services
+// add up to a cent less than the daily totals
+func cannedCostExplorer() *fakeCostExplorer {
+	return &fakeCostExplorer{
+		daily: []types.ResultByTime{
+			dailyCost("2024-04-30", "10.0040"),
+			dailyCost("2024-05-01", "12.5000"),
+			dailyCost("2024-05-02", "7.4960"),
+		},
+		byService: []types.ResultByTime{
+			serviceCosts("2024-04-01", "AWS Lambda", "5.00", "Amazon DynamoDB", "5.00"),
+			serviceCosts("2024-05-01", "AWS Lambda", "3.00", "Amazon DynamoDB", "7.00", "AmazonCloudWatch", "9.99"),
+		},
+	}
+}
+
+func TestGetCostAndUsageServiceBreakdown(t *testing.T) {
+	fake := cannedCostExplorer()
+	client := &CostExplorerClient{client: fake}
+	start := time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+
+	costData, err := client.GetCostAndUsage(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("GetCostAndUsage: %v", err)
+	}
+
+	if len(fake.inputs) != 2 {
+		t.Fatalf("GetCostAndUsage called %d times, want 2", len(fake.inputs))
+	}
+	grouped := fake.inputs[1]
+	if len(grouped.GroupBy) != 1 || grouped.GroupBy[0].Type != types.GroupDefinitionTypeDimension || aws.ToString(grouped.GroupBy[0].Key) != "SERVICE" {
+		t.Errorf("service breakdown GroupBy = %+v, want the SERVICE dimension", grouped.GroupBy)
+	}
+	for i, input := range fake.inputs {
+		if aws.ToString(input.TimePeriod.Start) != "2024-04-30" || aws.ToString(input.TimePeriod.End) != "2024-05-03" {
+			t.Errorf("call %d period = %s..%s", i, aws.ToString(input.TimePeriod.Start), aws.ToString(input.TimePeriod.End))
+		}
+	}
+
+	if math.Abs(costData.TotalCost-30) > 1e-9 {
+		t.Errorf("TotalCost = %v, want 30", costData.TotalCost)
+	}
+	if len(costData.DailyCosts) != 3 || costData.DailyCosts[0].Date != "2024-04-30" || costData.DailyCosts[1].Cost != 12.5 {
+		t.Errorf("DailyCosts = %+v", costData.DailyCosts)
+	}
+
+	want := []ServiceCost{
+		{ServiceName: "Amazon DynamoDB", Cost: 12},
+		{ServiceName: "AmazonCloudWatch", Cost: 9.99},
+		{ServiceName: "AWS Lambda", Cost: 8},
+	}
+	if len(costData.Services) != len(want) {
+		t.Fatalf("Services = %+v, want %d services", costData.Services, len(want))
+	}
+	var percentages float64
+	for i, w := range want {
+		got := costData.Services[i]
+		if got.ServiceName != w.ServiceName || math.Abs(got.Cost-w.Cost) > 1e-9 {
+			t.Errorf("service %d = %s %v, want %s %v", i, got.ServiceName, got.Cost, w.ServiceName, w.Cost)
+		}
+		if wantPercentage := w.Cost / 29.99 * 100; math.Abs(got.Percentage-wantPercentage) > 1e-9 {
+			t.Errorf("%s percentage = %v, want %v", got.ServiceName, got.Percentage, wantPercentage)
+		}
+		percentages += got.Percentage
+	}
+	if math.Abs(percentages-100) > 1e-9 {
+		t.Errorf("percentages sum to %v, want 100", percentages)
+	}
+}
+
+func TestGetCostAndUsageWithoutServiceBreakdown(t *testing.T) {
+	fake := cannedCostExplorer()
+	fake.byServiceErr = errors.New("access denied")
+	client := &CostExplorerClient{client: fake}
+
+	costData, err := client.GetCostAndUsage(context.Background(), time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetCostAndUsage: %v", err)
+	}
+	if len(costData.DailyCosts) != 3 || math.Abs(costData.TotalCost-30) > 1e-9 {
+		t.Errorf("daily data = %v over %+v, want 30 over three days", costData.TotalCost, costData.DailyCosts)
+	}
+	if len(costData.Services) != 0 {
+		t.Errorf("Services = %+v, want none", costData.Services)
+	}
+}
+
+func TestGetCostAndUsageForCategoryFiltersEveryCall(t *testing.T) {
+	fake := cannedCostExplorer()
+	client := &CostExplorerClient{client: fake}
+
+	category := CostCategoryFilter{Name: "Product", Value: "checkout"}
+	if _, err := client.GetCostAndUsageForCategory(context.Background(), category, time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("GetCostAndUsageForCategory: %v", err)
+	}
+
+	if len(fake.inputs) != 2 {
+		t.Fatalf("GetCostAndUsage called %d times, want 2", len(fake.inputs))
+	}
+	for i, input := range fake.inputs {
+		if input.Filter == nil || input.Filter.CostCategories == nil {
+			t.Errorf("call %d has no Cost Category filter: %+v", i, input.Filter)
+			continue
+		}
+		filter := input.Filter.CostCategories
+		if aws.ToString(filter.Key) != "Product" || len(filter.Values) != 1 || filter.Values[0] != "checkout" {
+			t.Errorf("call %d filter = %s %v, want Product [checkout]", i, aws.ToString(filter.Key), filter.Values)
+		}
+		if len(filter.MatchOptions) != 1 || filter.MatchOptions[0] != types.MatchOptionEquals {
+			t.Errorf("call %d match options = %v, want EQUALS", i, filter.MatchOptions)
+		}
+	}
+}
+
+func TestGetCostByCategory(t *testing.T) {
+	fake := &fakeCostExplorer{byService: []types.ResultByTime{
+		serviceCosts("2024-04-01", "Product$checkout", "6.00", "Product$search", "2.00", "Product$", "1.00"),
+		serviceCosts("2024-05-01", "Product$checkout", "4.00", "Product$", "3.00", "Product$search", "4.00"),
+	}}
+	client := &CostExplorerClient{client: fake}
+
+	costs, err := client.GetCostByCategory(context.Background(), "Product", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("GetCostByCategory: %v", err)
+	}
+
+	groupBy := fake.inputs[0].GroupBy
+	if len(groupBy) != 1 || groupBy[0].Type != types.GroupDefinitionTypeCostCategory || aws.ToString(groupBy[0].Key) != "Product" {
+		t.Errorf("GroupBy = %+v, want the Product Cost Category", groupBy)
+	}
+
+	want := []CategoryCost{
+		{Value: "checkout", Cost: 10, Percentage: 50},
+		{Value: "search", Cost: 6, Percentage: 30},
+		{Value: uncategorizedCostValue, Cost: 4, Percentage: 20},
+	}
+	if len(costs) != len(want) {
+		t.Fatalf("costs = %+v, want %d values", costs, len(want))
+	}
+	for i, w := range want {
+		got := costs[i]
+		if got.Value != w.Value || math.Abs(got.Cost-w.Cost) > 1e-9 || math.Abs(got.Percentage-w.Percentage) > 1e-9 {
+			t.Errorf("value %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestCostCategoryGroupValue(t *testing.T) {
+	tests := []struct {
+		key, categoryName, want string
+	}{
+		{key: "Product$checkout", categoryName: "Product", want: "checkout"},
+		{key: "Product$", categoryName: "Product", want: uncategorizedCostValue},
+		{key: "product$search", categoryName: "Product", want: "search"},
+		{key: "Product$a$b", categoryName: "Product", want: "a$b"},
+		{key: "checkout", categoryName: "Product", want: "checkout"},
+	}
+
+	for _, tt := range tests {
+		if got := costCategoryGroupValue(tt.key, tt.categoryName); got != tt.want {
+			t.Errorf("costCategoryGroupValue(%q, %q) = %q, want %q", tt.key, tt.categoryName, got, tt.want)
+		}
+	}
+}
+
+func TestGroupUntrackedServices(t *testing.T) {
+	services := []ServiceCost{
+		{ServiceName: "AWS Lambda", Cost: 12},
+		{ServiceName: "Amazon S3", Cost: 2},
+		{ServiceName: "Amazon DynamoDB", Cost: 20},
+		{ServiceName: "AWS Key Management Service", Cost: 1.5},
+		{ServiceName: "AWS Lambda", Cost: 4},
+		{ServiceName: "Amazon Route 53", Cost: 0.5},
+	}
+
+	grouped := GroupUntrackedServices(services, []string{" aws lambda", "Amazon DynamoDB ", "Amazon CloudWatch"})
+
+	want := []ServiceCost{
+		{ServiceName: "Amazon DynamoDB", Cost: 20, Percentage: 50},
+		{ServiceName: "AWS Lambda", Cost: 16, Percentage: 40},
+		{ServiceName: OtherServicesName, Cost: 4, Percentage: 10},
+	}
+	if len(grouped) != len(want) {
+		t.Fatalf("grouped = %+v, want %+v", grouped, want)
+	}
+	for i := range want {
+		if grouped[i].ServiceName != want[i].ServiceName || math.Abs(grouped[i].Cost-want[i].Cost) > 1e-9 || math.Abs(grouped[i].Percentage-want[i].Percentage) > 1e-9 {
+			t.Errorf("grouped[%d] = %+v, want %+v", i, grouped[i], want[i])
+		}
+	}
+
+	// Every service tracked leaves no Other entry
+	if grouped := GroupUntrackedServices(services[:1], []string{"AWS Lambda"}); len(grouped) != 1 || grouped[0].ServiceName != "AWS Lambda" {
+		t.Errorf("grouped = %+v, want only AWS Lambda", grouped)
+	}
+}
+
+func TestGetCostAndUsageKeepsRawResults(t *testing.T) {
+	fake := cannedCostExplorer()
+	client := &CostExplorerClient{client: fake}
+	start := time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+
+	costData, err := client.GetCostAndUsage(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("GetCostAndUsage: %v", err)
+	}
+	if costData.Raw == nil {
+		t.Fatal("Raw is nil")
+	}
+	if !reflect.DeepEqual(costData.Raw.Daily, fake.daily) || !reflect.DeepEqual(costData.Raw.ByService, fake.byService) {
+		t.Errorf("Raw = %+v, want the upstream results unchanged", costData.Raw)
+	}
+
+	// The raw section keeps Cost Explorer's exact amounts and stays out of the processed data
+	raw, err := json.Marshal(costData.Raw)
+	if err != nil {
+		t.Fatalf("marshal raw: %v", err)
+	}
+	if !strings.Contains(string(raw), `"Amount":"10.0040"`) {
+		t.Errorf("raw JSON %s doesn't carry the upstream amounts", raw)
+	}
+	processed, err := json.Marshal(costData)
+	if err != nil {
+		t.Fatalf("marshal cost data: %v", err)
+	}
+	if strings.Contains(string(processed), "10.0040") || strings.Contains(string(processed), "ResultsByTime") {
+		t.Errorf("processed JSON %s includes raw results", processed)
+	}
+
+	// Without the service breakdown only the daily results are available
+	fake = cannedCostExplorer()
+	fake.byServiceErr = errors.New("access denied")
+	costData, err = (&CostExplorerClient{client: fake}).GetCostAndUsage(context.Background(), start, end)
+	if err != nil {
+		t.Fatalf("GetCostAndUsage: %v", err)
+	}
+	if len(costData.Raw.Daily) != 3 || costData.Raw.ByService != nil {
+		t.Errorf("Raw = %+v, want daily results only", costData.Raw)
+	}
+}
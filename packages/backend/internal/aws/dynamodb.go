@@ -3,49 +3,77 @@ package aws
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 )
 
+// dynamoDBAPI is the part of the DynamoDB client DynamoDBClient calls, implemented by
+// *dynamodb.Client
+type dynamoDBAPI interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
 // DynamoDBClient wraps DynamoDB and CloudWatch clients for metrics
 type DynamoDBClient struct {
-	dynamoClient *dynamodb.Client
-	cwClient     *cloudwatch.Client
+	dynamoClient dynamoDBAPI
+	cwClient     metricDataAPI
+	maxAttempts  int // Calls made for each throttled CloudWatch query
+
+	// Last exact count per table, used to rate-limit full-table scans
+	exactCountMu   sync.Mutex
+	lastExactCount map[string]time.Time
 }
 
-// NewDynamoDBClient creates a new DynamoDB metrics client
-func NewDynamoDBClient(cfg aws.Config) *DynamoDBClient {
+// NewDynamoDBClient creates a new DynamoDB metrics client whose CloudWatch queries are retried
+// like the CloudWatch client's, making at most maxAttempts calls (DefaultCloudWatchMaxAttempts
+// if <= 0)
+func NewDynamoDBClient(cfg aws.Config, maxAttempts int) *DynamoDBClient {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultCloudWatchMaxAttempts
+	}
 	return &DynamoDBClient{
-		dynamoClient: dynamodb.NewFromConfig(cfg),
-		cwClient:     cloudwatch.NewFromConfig(cfg),
+		dynamoClient:   dynamodb.NewFromConfig(cfg),
+		cwClient:       newCloudWatchAPI(cfg),
+		maxAttempts:    maxAttempts,
+		lastExactCount: make(map[string]time.Time),
 	}
 }
 
 // DynamoDBMetrics represents DynamoDB table metrics
 type DynamoDBMetrics struct {
-	TableName              string                 `json:"tableName"`
-	ConsumedReadCapacity   float64                `json:"consumedReadCapacity"`
-	ConsumedWriteCapacity  float64                `json:"consumedWriteCapacity"`
-	ProvisionedReadCapacity  float64              `json:"provisionedReadCapacity"`
-	ProvisionedWriteCapacity float64              `json:"provisionedWriteCapacity"`
-	ThrottledRequests      float64                `json:"throttledRequests"`
-	UserErrors             float64                `json:"userErrors"`
-	SystemErrors           float64                `json:"systemErrors"`
-	ItemCount              int64                  `json:"itemCount"`
-	TableSizeBytes         int64                  `json:"tableSizeBytes"`
-	Period                 string                 `json:"period"`
-	Datapoints            []MetricDatapoint       `json:"datapoints"`
+	TableName                string                       `json:"tableName"`
+	ConsumedReadCapacity     float64                      `json:"consumedReadCapacity"`
+	ConsumedWriteCapacity    float64                      `json:"consumedWriteCapacity"`
+	ProvisionedReadCapacity  float64                      `json:"provisionedReadCapacity"`
+	ProvisionedWriteCapacity float64                      `json:"provisionedWriteCapacity"`
+	ThrottledRequests        float64                      `json:"throttledRequests"` // read + write throttle events
+	ThrottledReadRequests    float64                      `json:"throttledReadRequests"`
+	ThrottledWriteRequests   float64                      `json:"throttledWriteRequests"`
+	UserErrors               float64                      `json:"userErrors"`
+	SystemErrors             float64                      `json:"systemErrors"`
+	ItemCount                int64                        `json:"itemCount"`
+	ItemCountApproximate     bool                         `json:"itemCountApproximate"`
+	ItemCountAsOf            *time.Time                   `json:"itemCountAsOf,omitempty"`
+	TableSizeBytes           int64                        `json:"tableSizeBytes"`
+	Period                   timerange.Period             `json:"period"`
+	Datapoints               []MetricDatapoint            `json:"datapoints"`
+	Series                   map[string][]MetricDatapoint `json:"series,omitempty"`
+	DataAsOf                 *time.Time                   `json:"dataAsOf,omitempty"`
 }
 
 // GetTableMetrics retrieves metrics for a DynamoDB table
 func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string, startTime, endTime time.Time) (*DynamoDBMetrics, error) {
 	metrics := &DynamoDBMetrics{
 		TableName: tableName,
-		Period:    fmt.Sprintf("%s to %s", startTime.Format(time.RFC3339), endTime.Format(time.RFC3339)),
+		Period:    timerange.NewPeriod(startTime, endTime),
 	}
 
 	// Get table description for size and item count
@@ -59,6 +87,9 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 	if describeOutput.Table != nil {
 		if describeOutput.Table.ItemCount != nil {
 			metrics.ItemCount = *describeOutput.Table.ItemCount
+			metrics.ItemCountApproximate = true
+			asOf := approximateItemCountAsOf(describeOutput.Table.CreationDateTime, time.Now().UTC())
+			metrics.ItemCountAsOf = &asOf
 		}
 		if describeOutput.Table.TableSizeBytes != nil {
 			metrics.TableSizeBytes = *describeOutput.Table.TableSizeBytes
@@ -73,6 +104,9 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 		}
 	}
 
+	// Period scales with the range unless the request asked for one
+	period := MetricPeriod(ctx, startTime, endTime)
+
 	// Define CloudWatch metric queries
 	queries := []types.MetricDataQuery{
 		{
@@ -88,7 +122,7 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
@@ -106,17 +140,17 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
 		},
 		{
-			Id: aws.String("throttled"),
+			Id: aws.String("readThrottles"),
 			MetricStat: &types.MetricStat{
 				Metric: &types.Metric{
 					Namespace:  aws.String("AWS/DynamoDB"),
-					MetricName: aws.String("ThrottledRequests"),
+					MetricName: aws.String("ReadThrottleEvents"),
 					Dimensions: []types.Dimension{
 						{
 							Name:  aws.String("TableName"),
@@ -124,7 +158,25 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
+				Stat:   aws.String("Sum"),
+			},
+			ReturnData: aws.Bool(true),
+		},
+		{
+			Id: aws.String("writeThrottles"),
+			MetricStat: &types.MetricStat{
+				Metric: &types.Metric{
+					Namespace:  aws.String("AWS/DynamoDB"),
+					MetricName: aws.String("WriteThrottleEvents"),
+					Dimensions: []types.Dimension{
+						{
+							Name:  aws.String("TableName"),
+							Value: aws.String(tableName),
+						},
+					},
+				},
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
@@ -142,7 +194,7 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
@@ -160,7 +212,7 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 						},
 					},
 				},
-				Period: aws.Int32(300),
+				Period: aws.Int32(period),
 				Stat:   aws.String("Sum"),
 			},
 			ReturnData: aws.Bool(true),
@@ -170,17 +222,20 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 	// Get metric data from CloudWatch
 	input := &cloudwatch.GetMetricDataInput{
 		MetricDataQueries: queries,
-		StartTime:        &startTime,
-		EndTime:          &endTime,
+		StartTime:         &startTime,
+		EndTime:           &endTime,
 	}
 
-	result, err := c.cwClient.GetMetricData(ctx, input)
+	results, err := getAllMetricData(ctx, c.cwClient, input, c.maxAttempts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get CloudWatch metrics: %w", err)
 	}
 
 	// Process results
-	for _, metricResult := range result.MetricDataResults {
+	units := queryUnits(queries)
+	metrics.Series = make(map[string][]MetricDatapoint, len(queries))
+	metrics.DataAsOf = latestTimestamp(results)
+	for _, metricResult := range results {
 		if metricResult.Id == nil || len(metricResult.Values) == 0 {
 			continue
 		}
@@ -196,27 +251,26 @@ func (c *DynamoDBClient) GetTableMetrics(ctx context.Context, tableName string,
 			metrics.ConsumedReadCapacity = total
 		case "consumedWrite":
 			metrics.ConsumedWriteCapacity = total
-		case "throttled":
-			metrics.ThrottledRequests = total
+		case "readThrottles":
+			metrics.ThrottledReadRequests = total
+		case "writeThrottles":
+			metrics.ThrottledWriteRequests = total
 		case "userErrors":
 			metrics.UserErrors = total
 		case "systemErrors":
 			metrics.SystemErrors = total
 		}
 
-		// Add datapoints for the first metric only to avoid duplication
+		// Keep every metric's datapoints in its own series, labelled with its real unit
+		datapoints := resultDatapoints(metricResult, units[*metricResult.Id])
+		metrics.Series[*metricResult.Id] = datapoints
+
+		// Datapoints holds the consumed read series for existing consumers
 		if *metricResult.Id == "consumedRead" {
-			for i, timestamp := range metricResult.Timestamps {
-				if i < len(metricResult.Values) {
-					metrics.Datapoints = append(metrics.Datapoints, MetricDatapoint{
-						Timestamp: timestamp,
-						Value:     metricResult.Values[i],
-						Unit:      "ConsumedCapacityUnits",
-					})
-				}
-			}
+			metrics.Datapoints = datapoints
 		}
 	}
+	metrics.ThrottledRequests = metrics.ThrottledReadRequests + metrics.ThrottledWriteRequests
 
 	return metrics, nil
 }
@@ -236,4 +290,164 @@ func (c *DynamoDBClient) GetMultipleTableMetrics(ctx context.Context, tableNames
 	}
 
 	return results, nil
-}
\ No newline at end of file
+}
+
+// CapacityDatapoint represents consumed versus provisioned capacity for a single time bucket.
+// Consumed values are normalized to units per second so they compare directly with provisioned capacity.
+type CapacityDatapoint struct {
+	Timestamp        time.Time `json:"timestamp"`
+	ConsumedRead     float64   `json:"consumedRead"`
+	ConsumedWrite    float64   `json:"consumedWrite"`
+	ProvisionedRead  float64   `json:"provisionedRead"`
+	ProvisionedWrite float64   `json:"provisionedWrite"`
+	ReadHeadroom     float64   `json:"readHeadroom"`
+	WriteHeadroom    float64   `json:"writeHeadroom"`
+	ThrottleRisk     bool      `json:"throttleRisk"`
+}
+
+// TableCapacitySeries represents the capacity headroom of a table over time
+type TableCapacitySeries struct {
+	TableName         string              `json:"tableName"`
+	BillingMode       string              `json:"billingMode"`
+	Datapoints        []CapacityDatapoint `json:"datapoints"`
+	ThrottleRiskCount int                 `json:"throttleRiskCount"`
+}
+
+// GetTableCapacitySeries retrieves consumed and provisioned capacity for a table bucketed by period
+func (c *DynamoDBClient) GetTableCapacitySeries(ctx context.Context, tableName string, startTime, endTime time.Time, period time.Duration) (*TableCapacitySeries, error) {
+	series := &TableCapacitySeries{
+		TableName:   tableName,
+		BillingMode: "PROVISIONED",
+		Datapoints:  []CapacityDatapoint{},
+	}
+
+	describeOutput, err := c.dynamoClient.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(tableName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe table: %w", err)
+	}
+
+	// Current provisioned capacity is used for buckets where CloudWatch has no provisioned datapoint
+	var currentRead, currentWrite float64
+	if describeOutput.Table != nil {
+		if describeOutput.Table.BillingModeSummary != nil && describeOutput.Table.BillingModeSummary.BillingMode != "" {
+			series.BillingMode = string(describeOutput.Table.BillingModeSummary.BillingMode)
+		}
+		if throughput := describeOutput.Table.ProvisionedThroughput; throughput != nil {
+			if throughput.ReadCapacityUnits != nil {
+				currentRead = float64(*throughput.ReadCapacityUnits)
+			}
+			if throughput.WriteCapacityUnits != nil {
+				currentWrite = float64(*throughput.WriteCapacityUnits)
+			}
+		}
+	}
+
+	// CloudWatch periods must be multiples of 60 seconds
+	periodSeconds := int32((int64(period/time.Second) + 59) / 60 * 60)
+	if periodSeconds < 60 {
+		periodSeconds = 60
+	}
+
+	queries := []types.MetricDataQuery{
+		tableMetricQuery("consumedRead", "ConsumedReadCapacityUnits", tableName, "Sum", periodSeconds),
+		tableMetricQuery("consumedWrite", "ConsumedWriteCapacityUnits", tableName, "Sum", periodSeconds),
+		tableMetricQuery("provisionedRead", "ProvisionedReadCapacityUnits", tableName, "Average", periodSeconds),
+		tableMetricQuery("provisionedWrite", "ProvisionedWriteCapacityUnits", tableName, "Average", periodSeconds),
+	}
+
+	results, err := getAllMetricData(ctx, c.cwClient, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         &startTime,
+		EndTime:           &endTime,
+	}, c.maxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CloudWatch metrics: %w", err)
+	}
+
+	buckets := make(map[time.Time]*CapacityDatapoint)
+	provisionedSeen := make(map[time.Time]map[string]bool)
+	for _, metricResult := range results {
+		if metricResult.Id == nil {
+			continue
+		}
+		for i, timestamp := range metricResult.Timestamps {
+			if i >= len(metricResult.Values) {
+				break
+			}
+			dp, ok := buckets[timestamp]
+			if !ok {
+				dp = &CapacityDatapoint{Timestamp: timestamp}
+				buckets[timestamp] = dp
+				provisionedSeen[timestamp] = make(map[string]bool)
+			}
+			value := metricResult.Values[i]
+			switch *metricResult.Id {
+			case "consumedRead":
+				dp.ConsumedRead = value / float64(periodSeconds)
+			case "consumedWrite":
+				dp.ConsumedWrite = value / float64(periodSeconds)
+			case "provisionedRead":
+				dp.ProvisionedRead = value
+				provisionedSeen[timestamp]["read"] = true
+			case "provisionedWrite":
+				dp.ProvisionedWrite = value
+				provisionedSeen[timestamp]["write"] = true
+			}
+		}
+	}
+
+	for timestamp, dp := range buckets {
+		if !provisionedSeen[timestamp]["read"] {
+			dp.ProvisionedRead = currentRead
+		}
+		if !provisionedSeen[timestamp]["write"] {
+			dp.ProvisionedWrite = currentWrite
+		}
+		series.Datapoints = append(series.Datapoints, computeCapacityHeadroom(*dp))
+	}
+
+	sort.Slice(series.Datapoints, func(i, j int) bool {
+		return series.Datapoints[i].Timestamp.Before(series.Datapoints[j].Timestamp)
+	})
+
+	for _, dp := range series.Datapoints {
+		if dp.ThrottleRisk {
+			series.ThrottleRiskCount++
+		}
+	}
+
+	return series, nil
+}
+
+// computeCapacityHeadroom fills in headroom and flags buckets where consumption exceeded provisioned capacity
+func computeCapacityHeadroom(dp CapacityDatapoint) CapacityDatapoint {
+	dp.ReadHeadroom = dp.ProvisionedRead - dp.ConsumedRead
+	dp.WriteHeadroom = dp.ProvisionedWrite - dp.ConsumedWrite
+	dp.ThrottleRisk = (dp.ProvisionedRead > 0 && dp.ReadHeadroom < 0) ||
+		(dp.ProvisionedWrite > 0 && dp.WriteHeadroom < 0)
+	return dp
+}
+
+// tableMetricQuery builds a CloudWatch query for a DynamoDB table metric
+func tableMetricQuery(id, metricName, tableName, stat string, period int32) types.MetricDataQuery {
+	return types.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &types.MetricStat{
+			Metric: &types.Metric{
+				Namespace:  aws.String("AWS/DynamoDB"),
+				MetricName: aws.String(metricName),
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("TableName"),
+						Value: aws.String(tableName),
+					},
+				},
+			},
+			Period: aws.Int32(period),
+			Stat:   aws.String(stat),
+		},
+		ReturnData: aws.Bool(true),
+	}
+}
@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// ItemCountRefreshInterval is how often DynamoDB refreshes DescribeTable's ItemCount
+	ItemCountRefreshInterval = 6 * time.Hour
+
+	// exactCountMinInterval limits how often a table can be fully scanned for an exact count
+	exactCountMinInterval = 15 * time.Minute
+)
+
+// ErrExactCountRateLimited is returned when a table was exactly counted too recently
+var ErrExactCountRateLimited = errors.New("exact item count was requested too recently")
+
+// approximateItemCountAsOf returns the oldest time DescribeTable's ItemCount can reflect.
+// DynamoDB doesn't report when the count was refreshed, so this is a lower bound.
+func approximateItemCountAsOf(createdAt *time.Time, now time.Time) time.Time {
+	asOf := now.Add(-ItemCountRefreshInterval)
+	if createdAt != nil && createdAt.After(asOf) {
+		return createdAt.UTC()
+	}
+	return asOf
+}
+
+// ExactItemCount counts a table's items with a paginated Scan (Select=COUNT).
+// This reads the entire table and consumes read capacity for every item, so each
+// table can only be counted once per exactCountMinInterval.
+func (c *DynamoDBClient) ExactItemCount(ctx context.Context, tableName string) (int64, error) {
+	c.exactCountMu.Lock()
+	if last, ok := c.lastExactCount[tableName]; ok && time.Since(last) < exactCountMinInterval {
+		c.exactCountMu.Unlock()
+		return 0, ErrExactCountRateLimited
+	}
+	c.lastExactCount[tableName] = time.Now()
+	c.exactCountMu.Unlock()
+
+	var count int64
+	var startKey map[string]ddbtypes.AttributeValue
+
+	for {
+		output, err := c.dynamoClient.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(tableName),
+			Select:            ddbtypes.SelectCount,
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			// Let the caller retry a failed count without waiting out the interval
+			c.exactCountMu.Lock()
+			delete(c.lastExactCount, tableName)
+			c.exactCountMu.Unlock()
+			return 0, fmt.Errorf("failed to scan table %s: %w", tableName, err)
+		}
+
+		count += int64(output.Count)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = output.LastEvaluatedKey
+	}
+
+	return count, nil
+}
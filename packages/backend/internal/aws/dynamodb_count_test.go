@@ -0,0 +1,111 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestApproximateItemCountAsOf(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	old := now.AddDate(-1, 0, 0)
+
+	tests := []struct {
+		name      string
+		createdAt *time.Time
+		want      time.Time
+	}{
+		{name: "creation unknown", want: now.Add(-ItemCountRefreshInterval)},
+		{name: "old table", createdAt: &old, want: now.Add(-ItemCountRefreshInterval)},
+		{name: "created since the last refresh", createdAt: &recent, want: recent},
+	}
+	for _, tt := range tests {
+		if got := approximateItemCountAsOf(tt.createdAt, now); !got.Equal(tt.want) {
+			t.Errorf("%s: approximateItemCountAsOf = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetTableMetricsFlagsApproximateItemCount(t *testing.T) {
+	createdAt := time.Now().AddDate(0, -1, 0)
+	client := &DynamoDBClient{
+		dynamoClient: &fakeDynamoDB{read: 5, write: 5, itemCount: aws.Int64(48213), createdAt: &createdAt},
+		cwClient:     &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{}}}},
+		maxAttempts:  1,
+	}
+
+	before := time.Now()
+	metrics, err := client.GetTableMetrics(context.Background(), "orders", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetTableMetrics: %v", err)
+	}
+
+	if metrics.ItemCount != 48213 || !metrics.ItemCountApproximate {
+		t.Errorf("item count = %d, approximate %v, want 48213, true", metrics.ItemCount, metrics.ItemCountApproximate)
+	}
+	if asOf := metrics.ItemCountAsOf; asOf == nil || asOf.Before(before.Add(-ItemCountRefreshInterval-time.Second)) || asOf.After(time.Now().Add(-ItemCountRefreshInterval)) {
+		t.Errorf("ItemCountAsOf = %v, want %s before now", metrics.ItemCountAsOf, ItemCountRefreshInterval)
+	}
+}
+
+func TestExactItemCountScansEveryPage(t *testing.T) {
+	lastKey := map[string]types.AttributeValue{"id": &types.AttributeValueMemberS{Value: "order-500"}}
+	table := &fakeDynamoDB{scanPages: []*dynamodb.ScanOutput{
+		{Count: 500, LastEvaluatedKey: lastKey},
+		{Count: 500, LastEvaluatedKey: lastKey},
+		{Count: 37},
+	}}
+	client := &DynamoDBClient{dynamoClient: table, lastExactCount: make(map[string]time.Time)}
+
+	count, err := client.ExactItemCount(context.Background(), "orders")
+	if err != nil {
+		t.Fatalf("ExactItemCount: %v", err)
+	}
+	if count != 1037 {
+		t.Errorf("count = %d, want 1037", count)
+	}
+
+	if len(table.scans) != 3 {
+		t.Fatalf("scanned %d pages, want 3", len(table.scans))
+	}
+	for i, scan := range table.scans {
+		if scan.Select != types.SelectCount || aws.ToString(scan.TableName) != "orders" {
+			t.Errorf("scan %d = %s of %s, want COUNT of orders", i, scan.Select, aws.ToString(scan.TableName))
+		}
+		if continues := scan.ExclusiveStartKey != nil; continues != (i > 0) {
+			t.Errorf("scan %d continues from a previous page = %v", i, continues)
+		}
+	}
+
+	// A second count of the same table inside the interval is refused without scanning
+	if _, err := client.ExactItemCount(context.Background(), "orders"); !errors.Is(err, ErrExactCountRateLimited) {
+		t.Errorf("second count err = %v, want ErrExactCountRateLimited", err)
+	}
+	if len(table.scans) != 3 {
+		t.Errorf("rate-limited count scanned %d more pages", len(table.scans)-3)
+	}
+}
+
+func TestExactItemCountFailureCanBeRetried(t *testing.T) {
+	table := &fakeDynamoDB{scanErr: errors.New("throughput exceeded")}
+	client := &DynamoDBClient{dynamoClient: table, lastExactCount: make(map[string]time.Time)}
+
+	if _, err := client.ExactItemCount(context.Background(), "orders"); err == nil || errors.Is(err, ErrExactCountRateLimited) {
+		t.Fatalf("failing scan err = %v, want the scan error", err)
+	}
+
+	table.scanErr = nil
+	table.scans = nil
+	table.scanPages = []*dynamodb.ScanOutput{{Count: 12}}
+	count, err := client.ExactItemCount(context.Background(), "orders")
+	if err != nil || count != 12 {
+		t.Errorf("retry = %d, %v, want 12", count, err)
+	}
+}
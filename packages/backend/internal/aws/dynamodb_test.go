@@ -0,0 +1,179 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeDynamoDB describes a provisioned table with fixed read and write capacity, created at
+// createdAt with itemCount items when those are set. Scans answer with the next of scanPages,
+// or scanErr, recording each input.
+type fakeDynamoDB struct {
+	read, write int64
+	itemCount   *int64
+	createdAt   *time.Time
+
+	scanPages []*dynamodb.ScanOutput
+	scanErr   error
+	scans     []*dynamodb.ScanInput
+}
+
+func (f *fakeDynamoDB) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+		TableName:        params.TableName,
+		ItemCount:        f.itemCount,
+		CreationDateTime: f.createdAt,
+		ProvisionedThroughput: &types.ProvisionedThroughputDescription{
+			ReadCapacityUnits:  aws.Int64(f.read),
+			WriteCapacityUnits: aws.Int64(f.write),
+		},
+	}}, nil
+}
+
+func (f *fakeDynamoDB) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	f.scans = append(f.scans, params)
+	if f.scanErr != nil {
+		return nil, f.scanErr
+	}
+	if len(f.scans) > len(f.scanPages) {
+		return nil, errors.New("unexpected Scan call")
+	}
+	return f.scanPages[len(f.scans)-1], nil
+}
+
+// capacityResult is one capacity metric's datapoints, newest first as CloudWatch returns them
+func capacityResult(id string, values []float64, timestamps ...time.Time) cwtypes.MetricDataResult {
+	return cwtypes.MetricDataResult{Id: aws.String(id), Values: values, Timestamps: timestamps}
+}
+
+func TestGetTableCapacitySeriesCrossesProvisioned(t *testing.T) {
+	t0 := pageStart
+	t1 := t0.Add(2 * time.Minute)
+	t2 := t0.Add(4 * time.Minute)
+
+	// Consumed sums over 120-second buckets: reads run at 5, 12 and 8 units per second
+	// against 10 provisioned, and writes at 2, 3 and 6 against 5 provisioned until CloudWatch
+	// reports an increase to 8 in the last bucket
+	metrics := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []cwtypes.MetricDataResult{
+			capacityResult("consumedRead", []float64{960, 1440, 600}, t2, t1, t0),
+			capacityResult("consumedWrite", []float64{720, 360, 240}, t2, t1, t0),
+			capacityResult("provisionedRead", []float64{10}, t0),
+			capacityResult("provisionedWrite", []float64{8}, t2),
+		},
+	}}}}
+	client := &DynamoDBClient{dynamoClient: &fakeDynamoDB{read: 10, write: 5}, cwClient: metrics, maxAttempts: 1}
+
+	series, err := client.GetTableCapacitySeries(context.Background(), "orders", t0, t0.Add(6*time.Minute), 90*time.Second)
+	if err != nil {
+		t.Fatalf("GetTableCapacitySeries: %v", err)
+	}
+
+	for _, query := range metrics.inputs[0].MetricDataQueries {
+		if got := aws.ToInt32(query.MetricStat.Period); got != 120 {
+			t.Errorf("%s period = %d, want 90s rounded up to 120", aws.ToString(query.Id), got)
+		}
+	}
+
+	want := []CapacityDatapoint{
+		{Timestamp: t0, ConsumedRead: 5, ConsumedWrite: 2, ProvisionedRead: 10, ProvisionedWrite: 5, ReadHeadroom: 5, WriteHeadroom: 3},
+		{Timestamp: t1, ConsumedRead: 12, ConsumedWrite: 3, ProvisionedRead: 10, ProvisionedWrite: 5, ReadHeadroom: -2, WriteHeadroom: 2, ThrottleRisk: true},
+		{Timestamp: t2, ConsumedRead: 8, ConsumedWrite: 6, ProvisionedRead: 10, ProvisionedWrite: 8, ReadHeadroom: 2, WriteHeadroom: 2},
+	}
+	if len(series.Datapoints) != len(want) {
+		t.Fatalf("got %d datapoints, want %d: %+v", len(series.Datapoints), len(want), series.Datapoints)
+	}
+	for i, w := range want {
+		if got := series.Datapoints[i]; got != w {
+			t.Errorf("datapoint %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if series.ThrottleRiskCount != 1 {
+		t.Errorf("ThrottleRiskCount = %d, want 1", series.ThrottleRiskCount)
+	}
+	if series.BillingMode != "PROVISIONED" || series.TableName != "orders" {
+		t.Errorf("series = %s %s, want orders PROVISIONED", series.TableName, series.BillingMode)
+	}
+}
+
+func TestGetTableCapacitySeriesPeriod(t *testing.T) {
+	tests := []struct {
+		period time.Duration
+		want   int32
+	}{
+		{period: 0, want: 60},
+		{period: 59 * time.Second, want: 60},
+		{period: time.Minute, want: 60},
+		{period: 90 * time.Second, want: 120},
+		{period: 5 * time.Minute, want: 300},
+		{period: 301 * time.Second, want: 360},
+		{period: time.Hour + 1500*time.Millisecond, want: 3660},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.period.String(), func(t *testing.T) {
+			metrics := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{}}}}
+			client := &DynamoDBClient{dynamoClient: &fakeDynamoDB{read: 1, write: 1}, cwClient: metrics, maxAttempts: 1}
+
+			if _, err := client.GetTableCapacitySeries(context.Background(), "orders", pageStart, pageEnd, tt.period); err != nil {
+				t.Fatalf("GetTableCapacitySeries: %v", err)
+			}
+			for _, query := range metrics.inputs[0].MetricDataQueries {
+				if got := aws.ToInt32(query.MetricStat.Period); got != tt.want {
+					t.Errorf("%s period = %d, want %d", aws.ToString(query.Id), got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTableMetricsSplitsThrottles(t *testing.T) {
+	metricData := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []cwtypes.MetricDataResult{
+			capacityResult("consumedRead", []float64{120}, hour(0)),
+			capacityResult("readThrottles", []float64{3, 4}, hour(0), hour(1)),
+			capacityResult("writeThrottles", []float64{5}, hour(1)),
+		},
+	}}}}
+	client := &DynamoDBClient{dynamoClient: &fakeDynamoDB{read: 5, write: 5}, cwClient: metricData, maxAttempts: 1}
+
+	metrics, err := client.GetTableMetrics(context.Background(), "orders", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetTableMetrics: %v", err)
+	}
+
+	if metrics.ThrottledReadRequests != 7 || metrics.ThrottledWriteRequests != 5 {
+		t.Errorf("read, write throttles = %v, %v, want 7, 5", metrics.ThrottledReadRequests, metrics.ThrottledWriteRequests)
+	}
+	// The aggregate is kept for existing consumers
+	if metrics.ThrottledRequests != 12 {
+		t.Errorf("ThrottledRequests = %v, want the sum 12", metrics.ThrottledRequests)
+	}
+	for id, want := range map[string]int{"readThrottles": 2, "writeThrottles": 1} {
+		series := metrics.Series[id]
+		if len(series) != want {
+			t.Errorf("%s series has %d datapoints, want %d", id, len(series), want)
+			continue
+		}
+		if series[0].Unit != "Count" {
+			t.Errorf("%s unit = %q, want Count", id, series[0].Unit)
+		}
+	}
+
+	// Each direction is its own CloudWatch metric
+	queried := make(map[string]string)
+	for _, query := range metricData.inputs[0].MetricDataQueries {
+		queried[aws.ToString(query.Id)] = aws.ToString(query.MetricStat.Metric.MetricName)
+	}
+	if queried["readThrottles"] != "ReadThrottleEvents" || queried["writeThrottles"] != "WriteThrottleEvents" {
+		t.Errorf("throttle queries = %q and %q, want ReadThrottleEvents and WriteThrottleEvents", queried["readThrottles"], queried["writeThrottles"])
+	}
+}
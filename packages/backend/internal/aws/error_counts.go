@@ -0,0 +1,73 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// RequestErrorCounts holds how many requests a source served and how many of them failed
+type RequestErrorCounts struct {
+	Requests float64 `json:"requests"`
+	Errors   float64 `json:"errors"`
+}
+
+// GetAPIGatewayErrorCounts returns an API's request count and server errors over the range.
+// Client errors (4XX) are the caller's fault and don't count against availability.
+func (c *CloudWatchClient) GetAPIGatewayErrorCounts(ctx context.Context, apiName string, startTime, endTime time.Time) (*RequestErrorCounts, error) {
+	period := rangePeriodSeconds(startTime, endTime)
+	queries := []types.MetricDataQuery{
+		apiGatewayMetricQuery("count", "Count", apiName, "Sum", period),
+		apiGatewayMetricQuery("error5xx", "5XXError", apiName, "Sum", period),
+	}
+
+	results, err := getAllMetricData(ctx, c.client, &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: queries,
+		StartTime:         &startTime,
+		EndTime:           &endTime,
+	}, c.maxAttempts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API Gateway error counts: %w", err)
+	}
+
+	counts := &RequestErrorCounts{}
+	for _, metricResult := range results {
+		if metricResult.Id == nil {
+			continue
+		}
+		switch *metricResult.Id {
+		case "count":
+			counts.Requests = sumValues(metricResult.Values)
+		case "error5xx":
+			counts.Errors = sumValues(metricResult.Values)
+		}
+	}
+
+	return counts, nil
+}
+
+// apiGatewayMetricQuery builds a metric query for a whole API
+func apiGatewayMetricQuery(id, metricName, apiName, stat string, period int32) types.MetricDataQuery {
+	return types.MetricDataQuery{
+		Id: aws.String(id),
+		MetricStat: &types.MetricStat{
+			Metric: &types.Metric{
+				Namespace:  aws.String("AWS/ApiGateway"),
+				MetricName: aws.String(metricName),
+				Dimensions: []types.Dimension{
+					{
+						Name:  aws.String("ApiName"),
+						Value: aws.String(apiName),
+					},
+				},
+			},
+			Period: aws.Int32(period),
+			Stat:   aws.String(stat),
+		},
+		ReturnData: aws.Bool(true),
+	}
+}
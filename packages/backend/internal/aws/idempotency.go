@@ -0,0 +1,153 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// IdempotencyStore persists the results of mutating requests keyed by Idempotency-Key.
+// Items carry an "expiresAt" epoch attribute that the table's TTL setting uses for cleanup.
+type IdempotencyStore struct {
+	client    *dynamodb.Client
+	tableName string
+	ttl       time.Duration
+}
+
+// IdempotencyRecord represents a stored request result
+type IdempotencyRecord struct {
+	Key         string
+	Completed   bool
+	RequestHash string // Digest of the request body the result answers
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+// NewIdempotencyStore creates a new DynamoDB-backed idempotency store
+func NewIdempotencyStore(cfg aws.Config, tableName string, ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+		ttl:       ttl,
+	}
+}
+
+// Acquire reserves a key for a new request. If the key is already held by an unexpired
+// record, that record is returned instead and acquired is false.
+func (s *IdempotencyStore) Acquire(ctx context.Context, key string) (*IdempotencyRecord, bool, error) {
+	now := time.Now()
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]ddbtypes.AttributeValue{
+			"idempotencyKey": &ddbtypes.AttributeValueMemberS{Value: key},
+			"completed":      &ddbtypes.AttributeValueMemberBOOL{Value: false},
+			"expiresAt":      &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(s.ttl).Unix(), 10)},
+		},
+		// DynamoDB TTL deletion is lazy, so expired records may still be present
+		ConditionExpression: aws.String("attribute_not_exists(idempotencyKey) OR expiresAt < :now"),
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":now": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+	if err == nil {
+		return nil, true, nil
+	}
+
+	var conditionErr *ddbtypes.ConditionalCheckFailedException
+	if !errors.As(err, &conditionErr) {
+		return nil, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	record, err := s.get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	return record, false, nil
+}
+
+// Complete stores the final result for a previously acquired key
+func (s *IdempotencyStore) Complete(ctx context.Context, record *IdempotencyRecord) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]ddbtypes.AttributeValue{
+			"idempotencyKey": &ddbtypes.AttributeValueMemberS{Value: record.Key},
+			"completed":      &ddbtypes.AttributeValueMemberBOOL{Value: true},
+			"requestHash":    &ddbtypes.AttributeValueMemberS{Value: record.RequestHash},
+			"statusCode":     &ddbtypes.AttributeValueMemberN{Value: strconv.Itoa(record.StatusCode)},
+			"contentType":    &ddbtypes.AttributeValueMemberS{Value: record.ContentType},
+			"body":           &ddbtypes.AttributeValueMemberB{Value: record.Body},
+			"expiresAt":      &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store idempotency result: %w", err)
+	}
+	return nil
+}
+
+// Release removes a reservation so the request can be retried
+func (s *IdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]ddbtypes.AttributeValue{
+			"idempotencyKey": &ddbtypes.AttributeValueMemberS{Value: key},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// get loads a record by key
+func (s *IdempotencyStore) get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]ddbtypes.AttributeValue{
+			"idempotencyKey": &ddbtypes.AttributeValueMemberS{Value: key},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+
+	record := &IdempotencyRecord{Key: key}
+	for name, value := range output.Item {
+		switch v := value.(type) {
+		case *ddbtypes.AttributeValueMemberBOOL:
+			if name == "completed" {
+				record.Completed = v.Value
+			}
+		case *ddbtypes.AttributeValueMemberN:
+			n, _ := strconv.ParseInt(v.Value, 10, 64)
+			switch name {
+			case "statusCode":
+				record.StatusCode = int(n)
+			case "expiresAt":
+				record.ExpiresAt = time.Unix(n, 0)
+			}
+		case *ddbtypes.AttributeValueMemberS:
+			switch name {
+			case "requestHash":
+				record.RequestHash = v.Value
+			case "contentType":
+				record.ContentType = v.Value
+			}
+		case *ddbtypes.AttributeValueMemberB:
+			if name == "body" {
+				record.Body = v.Value
+			}
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,80 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+)
+
+// functionMemoryCacheTTL bounds how long a function's configured memory is reused; it only
+// changes on deploy
+const functionMemoryCacheTTL = time.Hour
+
+// lambdaConfigAPI is the Lambda call LambdaConfigClient makes, implemented by *lambda.Client
+type lambdaConfigAPI interface {
+	GetFunctionConfiguration(ctx context.Context, params *lambda.GetFunctionConfigurationInput, optFns ...func(*lambda.Options)) (*lambda.GetFunctionConfigurationOutput, error)
+}
+
+// LambdaConfigClient reads Lambda function configuration
+type LambdaConfigClient struct {
+	client lambdaConfigAPI
+	ttl    time.Duration
+
+	mu     sync.Mutex
+	memory map[string]functionMemoryEntry
+	stats  *CacheStats
+}
+
+// functionMemoryEntry holds a cached memory size
+type functionMemoryEntry struct {
+	memoryMB  int
+	fetchedAt time.Time
+}
+
+// NewLambdaConfigClient creates a new Lambda configuration client
+func NewLambdaConfigClient(cfg aws.Config) *LambdaConfigClient {
+	return &LambdaConfigClient{
+		client: lambda.NewFromConfig(cfg),
+		ttl:    functionMemoryCacheTTL,
+		memory: make(map[string]functionMemoryEntry),
+		stats:  newCacheStats("lambda_memory"),
+	}
+}
+
+// GetMemorySize returns a function's configured memory in MB, cached for functionMemoryCacheTTL
+func (c *LambdaConfigClient) GetMemorySize(ctx context.Context, functionName string) (int, error) {
+	c.mu.Lock()
+	entry, ok := c.memory[functionName]
+	c.mu.Unlock()
+	fresh := ok && time.Since(entry.fetchedAt) < c.ttl
+	c.stats.recordLookup(fresh, ok && !fresh)
+	if fresh {
+		return entry.memoryMB, nil
+	}
+
+	output, err := c.client.GetFunctionConfiguration(ctx, &lambda.GetFunctionConfigurationInput{
+		FunctionName: aws.String(functionName),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get configuration for function %s: %w", functionName, err)
+	}
+	if output.MemorySize == nil {
+		return 0, fmt.Errorf("function %s reported no memory size", functionName)
+	}
+
+	memoryMB := int(*output.MemorySize)
+	c.mu.Lock()
+	c.memory[functionName] = functionMemoryEntry{memoryMB: memoryMB, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return memoryMB, nil
+}
+
+// CacheStats returns hit, miss and eviction counts for the memory size cache
+func (c *LambdaConfigClient) CacheStats() CacheSnapshot {
+	return c.stats.Snapshot()
+}
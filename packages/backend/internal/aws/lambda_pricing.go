@@ -0,0 +1,64 @@
+package aws
+
+// defaultPricingRegion is used when a region has no entry in the pricing table
+const defaultPricingRegion = "us-east-1"
+
+// LambdaPricing holds on-demand Lambda rates for one region and architecture (x86_64)
+type LambdaPricing struct {
+	Region             string  `json:"region"`
+	PerGBSecond        float64 `json:"perGbSecond"`
+	PerMillionRequests float64 `json:"perMillionRequests"`
+}
+
+// lambdaPricingByRegion lists on-demand x86_64 Lambda rates for common regions (USD)
+var lambdaPricingByRegion = map[string]LambdaPricing{
+	"us-east-1":      {Region: "us-east-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"us-east-2":      {Region: "us-east-2", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"us-west-1":      {Region: "us-west-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"us-west-2":      {Region: "us-west-2", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"ca-central-1":   {Region: "ca-central-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"eu-west-1":      {Region: "eu-west-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"eu-west-2":      {Region: "eu-west-2", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"eu-central-1":   {Region: "eu-central-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"eu-north-1":     {Region: "eu-north-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"ap-south-1":     {Region: "ap-south-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"ap-northeast-1": {Region: "ap-northeast-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"ap-northeast-2": {Region: "ap-northeast-2", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"ap-southeast-1": {Region: "ap-southeast-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"ap-southeast-2": {Region: "ap-southeast-2", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"sa-east-1":      {Region: "sa-east-1", PerGBSecond: 0.0000166667, PerMillionRequests: 0.20},
+	"af-south-1":     {Region: "af-south-1", PerGBSecond: 0.0000221, PerMillionRequests: 0.27},
+	"ap-east-1":      {Region: "ap-east-1", PerGBSecond: 0.00002292, PerMillionRequests: 0.25},
+	"me-south-1":     {Region: "me-south-1", PerGBSecond: 0.0000206667, PerMillionRequests: 0.25},
+	"eu-south-1":     {Region: "eu-south-1", PerGBSecond: 0.0000195172, PerMillionRequests: 0.23},
+}
+
+// LambdaPricingForRegion returns the Lambda rates for a region. Unknown regions fall back
+// to us-east-1 rates and report false so callers can note the approximation.
+func LambdaPricingForRegion(region string) (LambdaPricing, bool) {
+	if pricing, ok := lambdaPricingByRegion[region]; ok {
+		return pricing, true
+	}
+	return lambdaPricingByRegion[defaultPricingRegion], false
+}
+
+// DefaultLambdaPricing returns the us-east-1 Lambda rates
+func DefaultLambdaPricing() LambdaPricing {
+	return lambdaPricingByRegion[defaultPricingRegion]
+}
+
+// EstimateCost returns the compute and request cost of invocations at an average duration and memory size
+func (p LambdaPricing) EstimateCost(invocations, averageDurationMs float64, memoryMB int) float64 {
+	return GBSeconds(memoryMB, averageDurationMs, invocations)*p.PerGBSecond + (invocations/1_000_000)*p.PerMillionRequests
+}
+
+// GBSeconds returns the compute Lambda bills for invocations at an average duration and memory size
+func GBSeconds(memoryMB int, averageDurationMs, invocations float64) float64 {
+	return invocations * (averageDurationMs / 1000) * (float64(memoryMB) / 1024)
+}
+
+// CalculateLambdaCost returns the on-demand cost of invocations at us-east-1 rates:
+// $0.0000166667 per GB-second of compute plus $0.20 per million requests
+func CalculateLambdaCost(memoryMB int, averageDurationMs, invocations float64) float64 {
+	return DefaultLambdaPricing().EstimateCost(invocations, averageDurationMs, memoryMB)
+}
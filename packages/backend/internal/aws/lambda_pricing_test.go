@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalculateLambdaCost(t *testing.T) {
+	tests := []struct {
+		name          string
+		memoryMB      int
+		durationMs    float64
+		invocations   float64
+		wantGBSeconds float64
+		wantCost      float64
+	}{
+		{name: "no invocations", memoryMB: 128, durationMs: 100, invocations: 0, wantGBSeconds: 0, wantCost: 0},
+		{name: "one GB-second", memoryMB: 1024, durationMs: 1000, invocations: 1, wantGBSeconds: 1, wantCost: 0.0000166667 + 0.0000002},
+		{name: "million small invocations", memoryMB: 128, durationMs: 100, invocations: 1_000_000, wantGBSeconds: 12_500, wantCost: 0.20833375 + 0.20},
+		{name: "three million half GB", memoryMB: 512, durationMs: 250, invocations: 3_000_000, wantGBSeconds: 375_000, wantCost: 6.2500125 + 0.60},
+		{name: "requests only", memoryMB: 1769, durationMs: 0, invocations: 5_000_000, wantGBSeconds: 0, wantCost: 1.00},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GBSeconds(tt.memoryMB, tt.durationMs, tt.invocations); math.Abs(got-tt.wantGBSeconds) > 1e-9 {
+				t.Errorf("GBSeconds = %v, want %v", got, tt.wantGBSeconds)
+			}
+			if got := CalculateLambdaCost(tt.memoryMB, tt.durationMs, tt.invocations); math.Abs(got-tt.wantCost) > 1e-9 {
+				t.Errorf("CalculateLambdaCost = %v, want %v", got, tt.wantCost)
+			}
+		})
+	}
+}
+
+func TestLambdaPricingForRegion(t *testing.T) {
+	tests := []struct {
+		region          string
+		wantPerGBSecond float64
+		wantPerMillion  float64
+		wantKnown       bool
+	}{
+		{region: "us-east-1", wantPerGBSecond: 0.0000166667, wantPerMillion: 0.20, wantKnown: true},
+		{region: "eu-west-1", wantPerGBSecond: 0.0000166667, wantPerMillion: 0.20, wantKnown: true},
+		{region: "af-south-1", wantPerGBSecond: 0.0000221, wantPerMillion: 0.27, wantKnown: true},
+		{region: "ap-east-1", wantPerGBSecond: 0.00002292, wantPerMillion: 0.25, wantKnown: true},
+		{region: "eu-south-1", wantPerGBSecond: 0.0000195172, wantPerMillion: 0.23, wantKnown: true},
+		{region: "xx-nowhere-1", wantPerGBSecond: 0.0000166667, wantPerMillion: 0.20},
+		{region: "", wantPerGBSecond: 0.0000166667, wantPerMillion: 0.20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.region, func(t *testing.T) {
+			pricing, known := LambdaPricingForRegion(tt.region)
+			if known != tt.wantKnown || pricing.PerGBSecond != tt.wantPerGBSecond || pricing.PerMillionRequests != tt.wantPerMillion {
+				t.Errorf("LambdaPricingForRegion = %+v, %v, want %v/GB-s, %v/M requests, %v", pricing, known, tt.wantPerGBSecond, tt.wantPerMillion, tt.wantKnown)
+			}
+			// Unknown regions are priced, and labelled, as us-east-1
+			if !tt.wantKnown && pricing != DefaultLambdaPricing() {
+				t.Errorf("fallback = %+v, want the us-east-1 rates", pricing)
+			}
+			if got, want := pricing.EstimateCost(1_000_000, 1000, 1024), 1_000_000*tt.wantPerGBSecond+tt.wantPerMillion; math.Abs(got-want) > 1e-9 {
+				t.Errorf("EstimateCost = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLambdaPricingTableRegions(t *testing.T) {
+	for region, pricing := range lambdaPricingByRegion {
+		if pricing.Region != region {
+			t.Errorf("%s is labelled %q", region, pricing.Region)
+		}
+		if pricing.PerGBSecond <= 0 || pricing.PerMillionRequests <= 0 {
+			t.Errorf("%s has no rates: %+v", region, pricing)
+		}
+	}
+}
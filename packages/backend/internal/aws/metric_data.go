@@ -0,0 +1,61 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// metricDataAPI is the CloudWatch GetMetricData call, implemented by *cloudwatch.Client
+type metricDataAPI interface {
+	GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error)
+}
+
+// newCloudWatchAPI creates a CloudWatch client without the SDK's retryer. Its calls are retried
+// by withRetry instead, which would otherwise multiply the SDK's attempts by its own.
+func newCloudWatchAPI(cfg aws.Config) *cloudwatch.Client {
+	return cloudwatch.NewFromConfig(cfg, func(o *cloudwatch.Options) {
+		o.Retryer = aws.NopRetryer{}
+	})
+}
+
+// getAllMetricData runs a GetMetricData query and follows NextToken until every page has been
+// read. CloudWatch splits long series across pages, so each query's values and timestamps are
+// merged back into a single result per query ID and label (expressions such as
+// ANOMALY_DETECTION_BAND return several series under one ID), in the order they first appear.
+// Each page is retried with backoff when throttled, up to maxAttempts calls.
+func getAllMetricData(ctx context.Context, client metricDataAPI, input *cloudwatch.GetMetricDataInput, maxAttempts int) ([]types.MetricDataResult, error) {
+	page := *input
+	var results []types.MetricDataResult
+	index := make(map[string]int)
+
+	for {
+		var output *cloudwatch.GetMetricDataOutput
+		err := withRetry(ctx, maxAttempts, func() error {
+			var err error
+			output, err = client.GetMetricData(ctx, &page)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range output.MetricDataResults {
+			key := aws.ToString(result.Id) + "\x00" + aws.ToString(result.Label)
+			if i, ok := index[key]; ok {
+				results[i].Values = append(results[i].Values, result.Values...)
+				results[i].Timestamps = append(results[i].Timestamps, result.Timestamps...)
+				continue
+			}
+			index[key] = len(results)
+			results = append(results, result)
+		}
+
+		if output.NextToken == nil || *output.NextToken == "" {
+			return results, nil
+		}
+		page.NextToken = output.NextToken
+	}
+}
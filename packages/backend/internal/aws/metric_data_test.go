@@ -0,0 +1,128 @@
+package aws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+var (
+	pageStart = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	pageEnd   = pageStart.Add(4 * time.Hour)
+)
+
+// hour returns the timestamp h hours after pageStart
+func hour(h int) time.Time {
+	return pageStart.Add(time.Duration(h) * time.Hour)
+}
+
+// twoPages is a GetMetricData answer split across two pages. The second page continues the
+// invocations and duration series, adds the upper half of an anomaly band under the band's
+// ID and starts the errors series.
+func twoPages() []fakeMetricDataPage {
+	return []fakeMetricDataPage{
+		{output: &cloudwatch.GetMetricDataOutput{
+			NextToken: aws.String("page-2"),
+			MetricDataResults: []types.MetricDataResult{
+				{Id: aws.String("invocations"), Label: aws.String("Invocations"), Values: []float64{10, 20}, Timestamps: []time.Time{hour(0), hour(1)}},
+				{Id: aws.String("duration"), Label: aws.String("Duration"), Values: []float64{100, 200}, Timestamps: []time.Time{hour(0), hour(1)}},
+				{Id: aws.String("band"), Label: aws.String("Invocations Lower"), Values: []float64{5}, Timestamps: []time.Time{hour(0)}},
+			},
+		}},
+		{output: &cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []types.MetricDataResult{
+				{Id: aws.String("invocations"), Label: aws.String("Invocations"), Values: []float64{30}, Timestamps: []time.Time{hour(2)}},
+				{Id: aws.String("duration"), Label: aws.String("Duration"), Values: []float64{300}, Timestamps: []time.Time{hour(2)}},
+				{Id: aws.String("band"), Label: aws.String("Invocations Upper"), Values: []float64{50}, Timestamps: []time.Time{hour(0)}},
+				{Id: aws.String("errors"), Label: aws.String("Errors"), Values: []float64{3}, Timestamps: []time.Time{hour(2)}},
+			},
+		}},
+	}
+}
+
+func TestGetAllMetricDataMergesPages(t *testing.T) {
+	fake := &fakeMetricData{pages: twoPages()}
+	input := &cloudwatch.GetMetricDataInput{
+		MetricDataQueries: lambdaMetricsQueries("checkout", "", 3600),
+		StartTime:         aws.Time(pageStart),
+		EndTime:           aws.Time(pageEnd),
+	}
+
+	results, err := getAllMetricData(context.Background(), fake, input, 1)
+	if err != nil {
+		t.Fatalf("getAllMetricData: %v", err)
+	}
+
+	if len(fake.inputs) != 2 {
+		t.Fatalf("GetMetricData called %d times, want 2", len(fake.inputs))
+	}
+	if fake.inputs[0].NextToken != nil {
+		t.Errorf("first call NextToken = %q, want none", *fake.inputs[0].NextToken)
+	}
+	second := fake.inputs[1]
+	if got := aws.ToString(second.NextToken); got != "page-2" {
+		t.Errorf("second call NextToken = %q, want %q", got, "page-2")
+	}
+	if !second.StartTime.Equal(pageStart) || !second.EndTime.Equal(pageEnd) || len(second.MetricDataQueries) != len(input.MetricDataQueries) {
+		t.Errorf("second call changed the query: %+v", second)
+	}
+	if input.NextToken != nil {
+		t.Error("caller's input was modified")
+	}
+
+	want := []struct {
+		id, label  string
+		values     []float64
+		timestamps []time.Time
+	}{
+		{"invocations", "Invocations", []float64{10, 20, 30}, []time.Time{hour(0), hour(1), hour(2)}},
+		{"duration", "Duration", []float64{100, 200, 300}, []time.Time{hour(0), hour(1), hour(2)}},
+		{"band", "Invocations Lower", []float64{5}, []time.Time{hour(0)}},
+		{"band", "Invocations Upper", []float64{50}, []time.Time{hour(0)}},
+		{"errors", "Errors", []float64{3}, []time.Time{hour(2)}},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(results), len(want))
+	}
+	for i, w := range want {
+		got := results[i]
+		if aws.ToString(got.Id) != w.id || aws.ToString(got.Label) != w.label {
+			t.Errorf("result %d = %s/%s, want %s/%s", i, aws.ToString(got.Id), aws.ToString(got.Label), w.id, w.label)
+			continue
+		}
+		if !reflect.DeepEqual(got.Values, w.values) {
+			t.Errorf("%s/%s values = %v, want %v", w.id, w.label, got.Values, w.values)
+		}
+		if !reflect.DeepEqual(got.Timestamps, w.timestamps) {
+			t.Errorf("%s/%s timestamps = %v, want %v", w.id, w.label, got.Timestamps, w.timestamps)
+		}
+	}
+}
+
+func TestGetLambdaMetricsTotalsEveryPage(t *testing.T) {
+	fake := &fakeMetricData{pages: twoPages()}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	metrics, err := client.GetLambdaMetrics(context.Background(), "checkout", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetLambdaMetrics: %v", err)
+	}
+
+	if metrics.Invocations != 60 {
+		t.Errorf("Invocations = %v, want 60", metrics.Invocations)
+	}
+	if metrics.Errors != 3 {
+		t.Errorf("Errors = %v, want 3", metrics.Errors)
+	}
+	if metrics.Duration != 200 {
+		t.Errorf("Duration = %v, want the average 200", metrics.Duration)
+	}
+	if metrics.DataAsOf == nil || !metrics.DataAsOf.Equal(hour(2)) {
+		t.Errorf("DataAsOf = %v, want %v", metrics.DataAsOf, hour(2))
+	}
+}
@@ -0,0 +1,58 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// NonceStore records consumed Apple sign-in nonces so each is accepted once across every
+// instance. Items carry an "expiresAt" epoch attribute that the table's TTL setting uses for
+// cleanup.
+type NonceStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewNonceStore creates a new DynamoDB-backed nonce store
+func NewNonceStore(cfg aws.Config, tableName string) *NonceStore {
+	return &NonceStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+// Consume records nonce as used until expiresAt with a conditional write, reporting false if
+// an unexpired record already exists
+func (s *NonceStore) Consume(ctx context.Context, nonce string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]ddbtypes.AttributeValue{
+			"nonce":      &ddbtypes.AttributeValueMemberS{Value: nonce},
+			"consumedAt": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			"expiresAt":  &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+		// DynamoDB TTL deletion is lazy, so expired records may still be present
+		ConditionExpression: aws.String("attribute_not_exists(nonce) OR expiresAt < :now"),
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":now": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+	if err == nil {
+		return true, nil
+	}
+
+	var conditionErr *ddbtypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to record nonce: %w", err)
+}
@@ -0,0 +1,57 @@
+package aws
+
+import (
+	"context"
+	"time"
+)
+
+// periodContextKey carries a requested CloudWatch period
+type periodContextKey struct{}
+
+// WithPeriod overrides the automatically chosen period, in seconds, for CloudWatch series
+// queried with ctx
+func WithPeriod(ctx context.Context, seconds int32) context.Context {
+	return context.WithValue(ctx, periodContextKey{}, seconds)
+}
+
+// ValidPeriod reports whether seconds is a period CloudWatch accepts for standard metrics
+func ValidPeriod(seconds int) bool {
+	return seconds >= 60 && seconds%60 == 0 && seconds <= 7*86400
+}
+
+// AutoPeriod picks a CloudWatch period for a range so series are fine enough to be useful
+// without returning thousands of datapoints: a minute under 3 hours, five minutes under a
+// day, an hour under a week, and a day beyond that.
+func AutoPeriod(startTime, endTime time.Time) int32 {
+	span := endTime.Sub(startTime)
+	switch {
+	case span < 3*time.Hour:
+		return 60
+	case span < 24*time.Hour:
+		return 300
+	case span < 7*24*time.Hour:
+		return 3600
+	default:
+		return 86400
+	}
+}
+
+// MetricPeriod returns the period requested with WithPeriod, or AutoPeriod for the range,
+// rounded up to the coarsest resolution CloudWatch still retains for startTime: one minute
+// for 15 days, five minutes for 63 days and an hour after that.
+func MetricPeriod(ctx context.Context, startTime, endTime time.Time) int32 {
+	period, ok := ctx.Value(periodContextKey{}).(int32)
+	if !ok || period <= 0 {
+		period = AutoPeriod(startTime, endTime)
+	}
+
+	resolution := int32(60)
+	age := time.Since(startTime)
+	switch {
+	case age > 63*24*time.Hour:
+		resolution = 3600
+	case age > 15*24*time.Hour:
+		resolution = 300
+	}
+	return (period + resolution - 1) / resolution * resolution
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+)
+
+func TestAutoPeriod(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		span time.Duration
+		want int32
+	}{
+		{span: time.Hour, want: 60},
+		{span: 3*time.Hour - time.Second, want: 60},
+		{span: 3 * time.Hour, want: 300},
+		{span: 12 * time.Hour, want: 300},
+		{span: 24 * time.Hour, want: 3600},
+		{span: 6 * 24 * time.Hour, want: 3600},
+		{span: 7 * 24 * time.Hour, want: 86400},
+		{span: 30 * 24 * time.Hour, want: 86400},
+	}
+
+	for _, tt := range tests {
+		if got := AutoPeriod(start, start.Add(tt.span)); got != tt.want {
+			t.Errorf("AutoPeriod over %s = %d, want %d", tt.span, got, tt.want)
+		}
+	}
+}
+
+func TestMetricPeriod(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		age, span time.Duration
+		want      int32
+	}{
+		{name: "recent hour", ctx: context.Background(), age: time.Hour, span: time.Hour, want: 60},
+		{name: "recent week", ctx: context.Background(), age: 7 * 24 * time.Hour, span: 7 * 24 * time.Hour, want: 86400},
+		{name: "requested", ctx: WithPeriod(context.Background(), 900), age: time.Hour, span: time.Hour, want: 900},
+		// CloudWatch keeps only five-minute data past 15 days and hourly data past 63
+		{name: "20 days old", ctx: context.Background(), age: 20 * 24 * time.Hour, span: time.Hour, want: 300},
+		{name: "requested past 15 days", ctx: WithPeriod(context.Background(), 420), age: 20 * 24 * time.Hour, span: time.Hour, want: 600},
+		{name: "70 days old", ctx: WithPeriod(context.Background(), 600), age: 70 * 24 * time.Hour, span: time.Hour, want: 3600},
+	}
+
+	for _, tt := range tests {
+		start := now.Add(-tt.age)
+		if got := MetricPeriod(tt.ctx, start, start.Add(tt.span)); got != tt.want {
+			t.Errorf("%s: MetricPeriod = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidPeriod(t *testing.T) {
+	for seconds, want := range map[int]bool{60: true, 300: true, 7 * 86400: true, 0: false, 30: false, 90: false, 8 * 86400: false} {
+		if got := ValidPeriod(seconds); got != want {
+			t.Errorf("ValidPeriod(%d) = %v, want %v", seconds, got, want)
+		}
+	}
+}
+
+func TestMetricQueriesUsePeriodForRange(t *testing.T) {
+	end := time.Now()
+	start := end.Add(-time.Hour)
+	createdAt := end.AddDate(0, -1, 0)
+
+	tests := []struct {
+		name  string
+		query func(ctx context.Context, fake *fakeMetricData) error
+	}{
+		{name: "Lambda", query: func(ctx context.Context, fake *fakeMetricData) error {
+			_, err := (&CloudWatchClient{client: fake, maxAttempts: 1}).GetLambdaMetrics(ctx, "checkout", start, end)
+			return err
+		}},
+		{name: "API Gateway", query: func(ctx context.Context, fake *fakeMetricData) error {
+			_, err := (&CloudWatchClient{client: fake, maxAttempts: 1}).GetAPIGatewayMetrics(ctx, "orders-api", start, end)
+			return err
+		}},
+		{name: "DynamoDB", query: func(ctx context.Context, fake *fakeMetricData) error {
+			client := &DynamoDBClient{
+				dynamoClient: &fakeDynamoDB{read: 5, write: 5, itemCount: aws.Int64(1), createdAt: &createdAt},
+				cwClient:     fake,
+				maxAttempts:  1,
+			}
+			_, err := client.GetTableMetrics(ctx, "orders", start, end)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		for _, c := range []struct {
+			ctx  context.Context
+			want int32
+		}{
+			{ctx: context.Background(), want: 60},
+			{ctx: WithPeriod(context.Background(), 900), want: 900},
+		} {
+			fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{}}}}
+			if err := tt.query(c.ctx, fake); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if len(fake.inputs) == 0 {
+				t.Fatalf("%s made no GetMetricData call", tt.name)
+			}
+			// The first query is always a series; range-wide percentiles come after
+			if got := aws.ToInt32(fake.inputs[0].MetricDataQueries[0].MetricStat.Period); got != c.want {
+				t.Errorf("%s period = %d, want %d", tt.name, got, c.want)
+			}
+		}
+	}
+}
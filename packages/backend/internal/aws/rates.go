@@ -0,0 +1,60 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+)
+
+// Units counter metrics can be normalized to with a rate
+const (
+	RatePerSecond = "second"
+	RatePerMinute = "minute"
+)
+
+// IsRateUnit reports whether unit is a supported rate unit
+func IsRateUnit(unit string) bool {
+	return unit == RatePerSecond || unit == RatePerMinute
+}
+
+// CounterRate is a counter's total over a window alongside its average rate, so totals over
+// different ranges can be compared
+type CounterRate struct {
+	Sum  float64 `json:"sum"`
+	Rate float64 `json:"rate"`
+	Unit string  `json:"unit"`
+}
+
+// NewCounterRate divides sum by the window's length in unit. The unit is labelled, for
+// example "count/minute".
+func NewCounterRate(sum float64, window time.Duration, unit string) CounterRate {
+	per := time.Second
+	if unit == RatePerMinute {
+		per = time.Minute
+	}
+
+	rate := CounterRate{Sum: sum, Unit: fmt.Sprintf("count/%s", unit)}
+	if window > 0 {
+		rate.Rate = sum / (float64(window) / float64(per))
+	}
+	return rate
+}
+
+// CounterRates returns the rates of a Lambda function's counters (invocations, errors and
+// throttles) over window. Duration and concurrency aren't counters and have no rate.
+func (m *LambdaMetrics) CounterRates(window time.Duration, unit string) map[string]CounterRate {
+	return map[string]CounterRate{
+		"invocations": NewCounterRate(m.Invocations, window, unit),
+		"errors":      NewCounterRate(m.Errors, window, unit),
+		"throttles":   NewCounterRate(m.Throttles, window, unit),
+	}
+}
+
+// CounterRates returns the rates of an API's counters (requests and 4XX/5XX errors) over
+// window. Latencies aren't counters and have no rate.
+func (m *APIGatewayMetrics) CounterRates(window time.Duration, unit string) map[string]CounterRate {
+	return map[string]CounterRate{
+		"requests": NewCounterRate(m.Count, window, unit),
+		"4xx":      NewCounterRate(m.Error4XX, window, unit),
+		"5xx":      NewCounterRate(m.Error5XX, window, unit),
+	}
+}
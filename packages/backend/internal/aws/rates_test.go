@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestNewCounterRate(t *testing.T) {
+	week := 7 * 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		sum    float64
+		window time.Duration
+		unit   string
+		want   CounterRate
+	}{
+		{name: "per minute over a week", sum: 20160, window: week, unit: RatePerMinute, want: CounterRate{Sum: 20160, Rate: 2, Unit: "count/minute"}},
+		{name: "per second over a week", sum: 1209600, window: week, unit: RatePerSecond, want: CounterRate{Sum: 1209600, Rate: 2, Unit: "count/second"}},
+		{name: "per minute over an hour", sum: 90, window: time.Hour, unit: RatePerMinute, want: CounterRate{Sum: 90, Rate: 1.5, Unit: "count/minute"}},
+		{name: "per second over a minute", sum: 3, window: time.Minute, unit: RatePerSecond, want: CounterRate{Sum: 3, Rate: 0.05, Unit: "count/second"}},
+		{name: "no count", window: time.Hour, unit: RatePerMinute, want: CounterRate{Unit: "count/minute"}},
+		{name: "empty window", sum: 10, unit: RatePerMinute, want: CounterRate{Sum: 10, Unit: "count/minute"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewCounterRate(tt.sum, tt.window, tt.unit)
+			if got.Sum != tt.want.Sum || got.Unit != tt.want.Unit || math.Abs(got.Rate-tt.want.Rate) > 1e-9 {
+				t.Errorf("NewCounterRate(%v, %s, %q) = %+v, want %+v", tt.sum, tt.window, tt.unit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterRatesCoverOnlyCounters(t *testing.T) {
+	window := 10 * time.Minute
+
+	lambda := &LambdaMetrics{Invocations: 600, Errors: 30, Throttles: 6, Duration: 250, ConcurrentExecutions: 12}
+	lambdaRates := lambda.CounterRates(window, RatePerMinute)
+	checkRates(t, "Lambda", lambdaRates, map[string]float64{"invocations": 60, "errors": 3, "throttles": 0.6})
+	if lambda.Duration != 250 || lambda.ConcurrentExecutions != 12 {
+		t.Errorf("Lambda duration and concurrency = %v, %v, want them unchanged", lambda.Duration, lambda.ConcurrentExecutions)
+	}
+
+	api := &APIGatewayMetrics{Count: 1200, Error4XX: 60, Error5XX: 12, Latency: 85, LatencyP99: 400}
+	apiRates := api.CounterRates(window, RatePerSecond)
+	checkRates(t, "API Gateway", apiRates, map[string]float64{"requests": 2, "4xx": 0.1, "5xx": 0.02})
+	if api.Latency != 85 || api.LatencyP99 != 400 {
+		t.Errorf("API Gateway latencies = %v, %v, want them unchanged", api.Latency, api.LatencyP99)
+	}
+}
+
+// checkRates fails t unless rates holds exactly the counters in want, at those rates
+func checkRates(t *testing.T, name string, rates map[string]CounterRate, want map[string]float64) {
+	t.Helper()
+
+	var got, wantKeys []string
+	for key := range rates {
+		got = append(got, key)
+	}
+	for key := range want {
+		wantKeys = append(wantKeys, key)
+	}
+	sort.Strings(got)
+	sort.Strings(wantKeys)
+	if !reflect.DeepEqual(got, wantKeys) {
+		t.Errorf("%s rates cover %v, want only the counters %v", name, got, wantKeys)
+	}
+	for key, rate := range want {
+		if math.Abs(rates[key].Rate-rate) > 1e-9 {
+			t.Errorf("%s %s rate = %v, want %v", name, key, rates[key].Rate, rate)
+		}
+	}
+}
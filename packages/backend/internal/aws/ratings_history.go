@@ -0,0 +1,196 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ratingsHistoryAPI is the part of the DynamoDB client RatingsHistoryStore calls, implemented
+// by *dynamodb.Client
+type ratingsHistoryAPI interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// RatingsHistoryStore persists periodic App Store ratings snapshots in DynamoDB.
+// Items are keyed by appId (partition) and timestamp in epoch seconds (sort).
+type RatingsHistoryStore struct {
+	client    ratingsHistoryAPI
+	tableName string
+}
+
+// RatingSnapshot represents an app's ratings at a point in time
+type RatingSnapshot struct {
+	AppID         string    `json:"appId"`
+	Timestamp     time.Time `json:"timestamp"`
+	AverageRating float64   `json:"averageRating"`
+	TotalRatings  int64     `json:"totalRatings"`
+}
+
+// NewRatingsHistoryStore creates a new DynamoDB-backed ratings history store
+func NewRatingsHistoryStore(cfg aws.Config, tableName string) *RatingsHistoryStore {
+	return &RatingsHistoryStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+// PutSnapshot stores a ratings snapshot
+func (s *RatingsHistoryStore) PutSnapshot(ctx context.Context, snapshot RatingSnapshot) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]ddbtypes.AttributeValue{
+			"appId":         &ddbtypes.AttributeValueMemberS{Value: snapshot.AppID},
+			"timestamp":     &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(snapshot.Timestamp.Unix(), 10)},
+			"averageRating": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatFloat(snapshot.AverageRating, 'f', -1, 64)},
+			"totalRatings":  &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(snapshot.TotalRatings, 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store ratings snapshot: %w", err)
+	}
+	return nil
+}
+
+// GetSnapshots returns an app's snapshots within a time range, oldest first
+func (s *RatingsHistoryStore) GetSnapshots(ctx context.Context, appID string, startTime, endTime time.Time) ([]RatingSnapshot, error) {
+	snapshots := []RatingSnapshot{}
+	var startKey map[string]ddbtypes.AttributeValue
+
+	for {
+		output, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(s.tableName),
+			KeyConditionExpression: aws.String("appId = :appId AND #ts BETWEEN :start AND :end"),
+			ExpressionAttributeNames: map[string]string{
+				"#ts": "timestamp",
+			},
+			ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+				":appId": &ddbtypes.AttributeValueMemberS{Value: appID},
+				":start": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(startTime.Unix(), 10)},
+				":end":   &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(endTime.Unix(), 10)},
+			},
+			ScanIndexForward:  aws.Bool(true),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query ratings history: %w", err)
+		}
+
+		for _, item := range output.Items {
+			snapshots = append(snapshots, ratingSnapshotFromItem(appID, item))
+		}
+
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = output.LastEvaluatedKey
+	}
+
+	return snapshots, nil
+}
+
+// maxBatchWriteItems is DynamoDB's limit on requests per BatchWriteItem call
+const maxBatchWriteItems = 25
+
+// PruneSnapshots deletes an app's snapshots taken before the cutoff, returning how many
+// were deleted
+func (s *RatingsHistoryStore) PruneSnapshots(ctx context.Context, appID string, before time.Time) (int, error) {
+	var keys []map[string]ddbtypes.AttributeValue
+	var startKey map[string]ddbtypes.AttributeValue
+
+	for {
+		output, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(s.tableName),
+			KeyConditionExpression: aws.String("appId = :appId AND #ts < :before"),
+			ProjectionExpression:   aws.String("appId, #ts"),
+			ExpressionAttributeNames: map[string]string{
+				"#ts": "timestamp",
+			},
+			ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+				":appId":  &ddbtypes.AttributeValueMemberS{Value: appID},
+				":before": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(before.Unix(), 10)},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to query expired ratings snapshots: %w", err)
+		}
+		keys = append(keys, output.Items...)
+
+		if len(output.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = output.LastEvaluatedKey
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		requests := make([]ddbtypes.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			requests = append(requests, ddbtypes.WriteRequest{DeleteRequest: &ddbtypes.DeleteRequest{Key: key}})
+		}
+		if err := s.batchDelete(ctx, requests); err != nil {
+			return deleted, err
+		}
+		deleted += len(requests)
+	}
+
+	return deleted, nil
+}
+
+// maxUnprocessedRetries bounds resubmissions of items a batch write left unprocessed
+const maxUnprocessedRetries = 5
+
+// batchDelete issues a batch of delete requests, resubmitting with backoff any that DynamoDB
+// leaves unprocessed (typically due to throttling)
+func (s *RatingsHistoryStore) batchDelete(ctx context.Context, requests []ddbtypes.WriteRequest) error {
+	for attempt := 0; len(requests) > 0; attempt++ {
+		if attempt > maxUnprocessedRetries {
+			return fmt.Errorf("failed to delete expired ratings snapshots: %d items left unprocessed", len(requests))
+		}
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * 100 * time.Millisecond):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		output, err := s.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]ddbtypes.WriteRequest{s.tableName: requests},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete expired ratings snapshots: %w", err)
+		}
+		requests = output.UnprocessedItems[s.tableName]
+	}
+	return nil
+}
+
+// ratingSnapshotFromItem converts a DynamoDB item into a snapshot
+func ratingSnapshotFromItem(appID string, item map[string]ddbtypes.AttributeValue) RatingSnapshot {
+	snapshot := RatingSnapshot{AppID: appID}
+	if v, ok := item["timestamp"].(*ddbtypes.AttributeValueMemberN); ok {
+		seconds, _ := strconv.ParseInt(v.Value, 10, 64)
+		snapshot.Timestamp = time.Unix(seconds, 0).UTC()
+	}
+	if v, ok := item["averageRating"].(*ddbtypes.AttributeValueMemberN); ok {
+		snapshot.AverageRating, _ = strconv.ParseFloat(v.Value, 64)
+	}
+	if v, ok := item["totalRatings"].(*ddbtypes.AttributeValueMemberN); ok {
+		snapshot.TotalRatings, _ = strconv.ParseInt(v.Value, 10, 64)
+	}
+	return snapshot
+}
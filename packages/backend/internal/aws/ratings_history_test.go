@@ -0,0 +1,180 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// fakeRatingsTable answers each Query with the next of pages, recording every input. The
+// first batch write leaves its last unprocessed requests for the caller to resubmit.
+type fakeRatingsTable struct {
+	pages       []*dynamodb.QueryOutput
+	unprocessed int
+	puts        []*dynamodb.PutItemInput
+	queries     []*dynamodb.QueryInput
+	writes      []*dynamodb.BatchWriteItemInput
+}
+
+func (f *fakeRatingsTable) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	f.puts = append(f.puts, params)
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeRatingsTable) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	f.queries = append(f.queries, params)
+	if len(f.queries) > len(f.pages) {
+		return nil, errors.New("unexpected Query call")
+	}
+	return f.pages[len(f.queries)-1], nil
+}
+
+func (f *fakeRatingsTable) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	f.writes = append(f.writes, params)
+	output := &dynamodb.BatchWriteItemOutput{}
+	if len(f.writes) == 1 && f.unprocessed > 0 {
+		for table, requests := range params.RequestItems {
+			output.UnprocessedItems = map[string][]ddbtypes.WriteRequest{table: requests[len(requests)-f.unprocessed:]}
+		}
+	}
+	return output, nil
+}
+
+// ratingItem is a stored snapshot as DynamoDB returns it
+func ratingItem(timestamp time.Time, average string, total int) map[string]ddbtypes.AttributeValue {
+	return map[string]ddbtypes.AttributeValue{
+		"appId":         &ddbtypes.AttributeValueMemberS{Value: "app"},
+		"timestamp":     &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(timestamp.Unix(), 10)},
+		"averageRating": &ddbtypes.AttributeValueMemberN{Value: average},
+		"totalRatings":  &ddbtypes.AttributeValueMemberN{Value: strconv.Itoa(total)},
+	}
+}
+
+func TestPutSnapshot(t *testing.T) {
+	table := &fakeRatingsTable{}
+	store := &RatingsHistoryStore{client: table, tableName: "ratings"}
+
+	err := store.PutSnapshot(context.Background(), RatingSnapshot{AppID: "app", Timestamp: hour(2), AverageRating: 4.25, TotalRatings: 1310})
+	if err != nil {
+		t.Fatalf("PutSnapshot: %v", err)
+	}
+
+	if len(table.puts) != 1 || aws.ToString(table.puts[0].TableName) != "ratings" {
+		t.Fatalf("puts = %+v, want one to the ratings table", table.puts)
+	}
+	want := map[string]string{
+		"appId":         "app",
+		"timestamp":     strconv.FormatInt(hour(2).Unix(), 10),
+		"averageRating": "4.25",
+		"totalRatings":  "1310",
+	}
+	item := table.puts[0].Item
+	if len(item) != len(want) {
+		t.Errorf("item = %+v, want %d attributes", item, len(want))
+	}
+	for name, w := range want {
+		var got string
+		switch value := item[name].(type) {
+		case *ddbtypes.AttributeValueMemberS:
+			got = value.Value
+		case *ddbtypes.AttributeValueMemberN:
+			got = value.Value
+		}
+		if got != w {
+			t.Errorf("%s = %q, want %q", name, got, w)
+		}
+	}
+}
+
+func TestGetSnapshotsReadsEveryPage(t *testing.T) {
+	lastKey := map[string]ddbtypes.AttributeValue{"appId": &ddbtypes.AttributeValueMemberS{Value: "app"}}
+	table := &fakeRatingsTable{pages: []*dynamodb.QueryOutput{
+		{Items: []map[string]ddbtypes.AttributeValue{ratingItem(hour(0), "4.1", 1000), ratingItem(hour(1), "4.2", 1100)}, LastEvaluatedKey: lastKey},
+		{Items: []map[string]ddbtypes.AttributeValue{ratingItem(hour(2), "4.3", 1200)}},
+	}}
+	store := &RatingsHistoryStore{client: table, tableName: "ratings"}
+
+	snapshots, err := store.GetSnapshots(context.Background(), "app", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetSnapshots: %v", err)
+	}
+
+	if len(table.queries) != 2 || table.queries[0].ExclusiveStartKey != nil || table.queries[1].ExclusiveStartKey == nil {
+		t.Fatalf("queries = %d, want a first page and one continuing from its last key", len(table.queries))
+	}
+	values := table.queries[0].ExpressionAttributeValues
+	if got := values[":start"].(*ddbtypes.AttributeValueMemberN).Value; got != strconv.FormatInt(pageStart.Unix(), 10) {
+		t.Errorf(":start = %s, want %d", got, pageStart.Unix())
+	}
+	if got := values[":end"].(*ddbtypes.AttributeValueMemberN).Value; got != strconv.FormatInt(pageEnd.Unix(), 10) {
+		t.Errorf(":end = %s, want %d", got, pageEnd.Unix())
+	}
+
+	want := []RatingSnapshot{
+		{AppID: "app", Timestamp: hour(0), AverageRating: 4.1, TotalRatings: 1000},
+		{AppID: "app", Timestamp: hour(1), AverageRating: 4.2, TotalRatings: 1100},
+		{AppID: "app", Timestamp: hour(2), AverageRating: 4.3, TotalRatings: 1200},
+	}
+	if len(snapshots) != len(want) {
+		t.Fatalf("snapshots = %+v, want %d", snapshots, len(want))
+	}
+	for i, w := range want {
+		if snapshots[i] != w {
+			t.Errorf("snapshot %d = %+v, want %+v", i, snapshots[i], w)
+		}
+	}
+}
+
+func TestPruneSnapshotsDeletesEveryExpiredItem(t *testing.T) {
+	expired := func(n int) []map[string]ddbtypes.AttributeValue {
+		items := make([]map[string]ddbtypes.AttributeValue, n)
+		for i := range items {
+			items[i] = ratingItem(pageStart.Add(-time.Duration(i+1)*time.Hour), "4.0", 900)
+		}
+		return items
+	}
+	lastKey := map[string]ddbtypes.AttributeValue{"appId": &ddbtypes.AttributeValueMemberS{Value: "app"}}
+	table := &fakeRatingsTable{
+		pages: []*dynamodb.QueryOutput{
+			{Items: expired(26), LastEvaluatedKey: lastKey},
+			{Items: expired(4)},
+		},
+		unprocessed: 2,
+	}
+	store := &RatingsHistoryStore{client: table, tableName: "ratings"}
+
+	deleted, err := store.PruneSnapshots(context.Background(), "app", pageStart)
+	if err != nil {
+		t.Fatalf("PruneSnapshots: %v", err)
+	}
+	if deleted != 30 {
+		t.Errorf("deleted = %d, want 30", deleted)
+	}
+
+	// Only items before the cutoff are queried, so recent snapshots are never touched
+	if len(table.queries) != 2 {
+		t.Fatalf("queries = %d, want both pages", len(table.queries))
+	}
+	if got := aws.ToString(table.queries[0].KeyConditionExpression); got != "appId = :appId AND #ts < :before" {
+		t.Errorf("key condition = %q, want only snapshots before the cutoff", got)
+	}
+	if got := table.queries[0].ExpressionAttributeValues[":before"].(*ddbtypes.AttributeValueMemberN).Value; got != strconv.FormatInt(pageStart.Unix(), 10) {
+		t.Errorf(":before = %s, want %d", got, pageStart.Unix())
+	}
+
+	// 25 per batch, with the 2 left unprocessed resubmitted before the next batch
+	var sizes []int
+	for _, write := range table.writes {
+		sizes = append(sizes, len(write.RequestItems["ratings"]))
+	}
+	if want := []int{25, 2, 5}; !slices.Equal(sizes, want) {
+		t.Errorf("batch sizes = %v, want %v", sizes, want)
+	}
+}
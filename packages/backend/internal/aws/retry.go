@@ -0,0 +1,111 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// DefaultCloudWatchMaxAttempts is how many times a throttled CloudWatch call is tried in total
+const DefaultCloudWatchMaxAttempts = 4
+
+// Backoff bounds between retried calls; each wait is drawn at random up to the exponential step
+const (
+	retryBaseDelay = 200 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+)
+
+// ErrThrottled is matched by errors.Is when AWS kept throttling a call until retries ran out
+var ErrThrottled = errors.New("aws throttled the request")
+
+// throttlingErrorCodes are the error codes AWS services use to signal throttling
+var throttlingErrorCodes = map[string]bool{
+	"Throttling":                             true,
+	"ThrottlingException":                    true,
+	"ThrottledException":                     true,
+	"RequestThrottled":                       true,
+	"RequestThrottledException":              true,
+	"TooManyRequestsException":               true,
+	"RequestLimitExceeded":                   true,
+	"ProvisionedThroughputExceededException": true,
+}
+
+// RetriesExhaustedError reports a call that was still throttled or failing with a server error
+// after every attempt. It matches ErrThrottled when the last attempt was throttled, so callers
+// can tell "try later" from "no data".
+type RetriesExhaustedError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetriesExhaustedError) Unwrap() error {
+	return e.Err
+}
+
+// Is matches ErrThrottled when the last attempt failed because AWS was throttling
+func (e *RetriesExhaustedError) Is(target error) bool {
+	return target == ErrThrottled && isThrottling(e.Err)
+}
+
+// isThrottling reports whether err carries one of AWS's throttling error codes
+func isThrottling(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && throttlingErrorCodes[apiErr.ErrorCode()]
+}
+
+// isRetryable reports whether err is throttling or a 5xx response worth retrying
+func isRetryable(err error) bool {
+	if isThrottling(err) {
+		return true
+	}
+
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) && statusErr.HTTPStatusCode() >= 500 {
+		return true
+	}
+	return false
+}
+
+// retryDelay returns a jittered wait before retry number attempt (starting at 1): a random
+// duration up to the base delay doubled per attempt, capped at retryMaxDelay
+func retryDelay(attempt int) time.Duration {
+	step := retryBaseDelay << (attempt - 1)
+	if step <= 0 || step > retryMaxDelay {
+		step = retryMaxDelay
+	}
+	return time.Duration(rand.Int63n(int64(step))) + 1
+}
+
+// withRetry calls fn until it succeeds, returns an error that isn't retryable, or maxAttempts
+// calls have been made. Retryable errors that outlast every attempt come back as a
+// RetriesExhaustedError. Clients retried this way are built without the SDK's own retryer, see
+// newCloudWatchAPI, so maxAttempts is the total number of calls made.
+func withRetry(ctx context.Context, maxAttempts int, fn func() error) error {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultCloudWatchMaxAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if attempt >= maxAttempts {
+			return &RetriesExhaustedError{Attempts: attempt, Err: err}
+		}
+
+		select {
+		case <-time.After(retryDelay(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/smithy-go"
+)
+
+// fakeMetricData answers GetMetricData calls with the outputs and errors of its pages in turn
+type fakeMetricData struct {
+	pages  []fakeMetricDataPage
+	inputs []cloudwatch.GetMetricDataInput
+}
+
+// fakeMetricDataPage is one GetMetricData call's answer
+type fakeMetricDataPage struct {
+	output *cloudwatch.GetMetricDataOutput
+	err    error
+}
+
+func (f *fakeMetricData) GetMetricData(ctx context.Context, params *cloudwatch.GetMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricDataOutput, error) {
+	call := len(f.inputs)
+	f.inputs = append(f.inputs, *params)
+	if call >= len(f.pages) {
+		return nil, errors.New("unexpected GetMetricData call")
+	}
+	return f.pages[call].output, f.pages[call].err
+}
+
+func (f *fakeMetricData) ListMetrics(ctx context.Context, params *cloudwatch.ListMetricsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.ListMetricsOutput, error) {
+	return nil, errors.New("unexpected ListMetrics call")
+}
+
+// serverError is a 5xx response error
+type serverError struct{}
+
+func (serverError) Error() string       { return "internal server error" }
+func (serverError) HTTPStatusCode() int { return 500 }
+
+var (
+	errThrottling = &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	errInvalid    = &smithy.GenericAPIError{Code: "InvalidParameterValue", Message: "bad period"}
+)
+
+// failingPages returns n pages failing with err followed by one succeeding
+func failingPages(n int, err error) []fakeMetricDataPage {
+	pages := make([]fakeMetricDataPage, 0, n+1)
+	for i := 0; i < n; i++ {
+		pages = append(pages, fakeMetricDataPage{err: err})
+	}
+	return append(pages, fakeMetricDataPage{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []types.MetricDataResult{{Id: aws.String("invocations"), Values: []float64{42}}},
+	}})
+}
+
+func TestGetAllMetricDataRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		pages         []fakeMetricDataPage
+		maxAttempts   int
+		wantCalls     int
+		wantErr       bool
+		wantThrottled bool
+	}{
+		{name: "succeeds first time", pages: failingPages(0, nil), maxAttempts: 3, wantCalls: 1},
+		{name: "recovers from throttling", pages: failingPages(2, errThrottling), maxAttempts: 3, wantCalls: 3},
+		{name: "recovers from server errors", pages: failingPages(1, serverError{}), maxAttempts: 3, wantCalls: 2},
+		{name: "throttled past every attempt", pages: failingPages(3, errThrottling), maxAttempts: 3, wantCalls: 3, wantErr: true, wantThrottled: true},
+		{name: "server errors past every attempt", pages: failingPages(2, serverError{}), maxAttempts: 2, wantCalls: 2, wantErr: true},
+		{name: "not retryable", pages: failingPages(1, errInvalid), maxAttempts: 3, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeMetricData{pages: tt.pages}
+			results, err := getAllMetricData(context.Background(), fake, &cloudwatch.GetMetricDataInput{}, tt.maxAttempts)
+
+			if got := len(fake.inputs); got != tt.wantCalls {
+				t.Errorf("GetMetricData called %d times, want %d", got, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if got := errors.Is(err, ErrThrottled); got != tt.wantThrottled {
+				t.Errorf("errors.Is(err, ErrThrottled) = %v, want %v", got, tt.wantThrottled)
+			}
+			if err == nil && (len(results) != 1 || results[0].Values[0] != 42) {
+				t.Errorf("results = %+v, want the successful page's result", results)
+			}
+		})
+	}
+}
+
+func TestWithRetryStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := withRetry(ctx, 5, func() error {
+		calls++
+		cancel()
+		return errThrottling
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+}
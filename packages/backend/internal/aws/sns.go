@@ -0,0 +1,66 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	snstypes "github.com/aws/aws-sdk-go-v2/service/sns/types"
+)
+
+// snsMaxSubjectLength is the longest subject SNS accepts
+const snsMaxSubjectLength = 100
+
+// snsPublishAPI is the SNS call SNSPublisher makes, implemented by *sns.Client
+type snsPublishAPI interface {
+	Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error)
+}
+
+// SNSPublisher publishes messages to a single SNS topic
+type SNSPublisher struct {
+	client   snsPublishAPI
+	topicARN string
+}
+
+// NewSNSPublisher creates a publisher for the topic with the given ARN
+func NewSNSPublisher(cfg aws.Config, topicARN string) *SNSPublisher {
+	return &SNSPublisher{
+		client:   sns.NewFromConfig(cfg),
+		topicARN: topicARN,
+	}
+}
+
+// Publish sends message to the topic with string attributes subscribers can filter on.
+// Subject is used by email subscriptions and is cut to the length SNS allows.
+func (p *SNSPublisher) Publish(ctx context.Context, subject, message string, attributes map[string]string) error {
+	input := &sns.PublishInput{
+		TopicArn: aws.String(p.topicARN),
+		Message:  aws.String(message),
+	}
+	if subject != "" {
+		if runes := []rune(subject); len(runes) > snsMaxSubjectLength {
+			subject = string(runes[:snsMaxSubjectLength])
+		}
+		input.Subject = aws.String(subject)
+	}
+
+	for name, value := range attributes {
+		// SNS rejects attributes with empty values
+		if value == "" {
+			continue
+		}
+		if input.MessageAttributes == nil {
+			input.MessageAttributes = make(map[string]snstypes.MessageAttributeValue, len(attributes))
+		}
+		input.MessageAttributes[name] = snstypes.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	if _, err := p.client.Publish(ctx, input); err != nil {
+		return fmt.Errorf("failed to publish to SNS topic %s: %w", p.topicARN, err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// fakeSNS captures the input of each publish
+type fakeSNS struct {
+	inputs []*sns.PublishInput
+	err    error
+}
+
+func (f *fakeSNS) Publish(ctx context.Context, params *sns.PublishInput, optFns ...func(*sns.Options)) (*sns.PublishOutput, error) {
+	f.inputs = append(f.inputs, params)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &sns.PublishOutput{MessageId: aws.String("message-1")}, nil
+}
+
+func TestSNSPublisherBuildsInput(t *testing.T) {
+	fake := &fakeSNS{}
+	publisher := &SNSPublisher{client: fake, topicARN: "arn:aws:sns:us-east-1:123456789012:alerts"}
+
+	subject := strings.Repeat("é", snsMaxSubjectLength+10)
+	attributes := map[string]string{"severity": "critical", "appId": "app", "resource": ""}
+	if err := publisher.Publish(context.Background(), subject, "error rate above 5%", attributes); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(fake.inputs) != 1 {
+		t.Fatalf("published %d times, want 1", len(fake.inputs))
+	}
+	input := fake.inputs[0]
+
+	if got := aws.ToString(input.TopicArn); got != publisher.topicARN {
+		t.Errorf("topic = %q, want %q", got, publisher.topicARN)
+	}
+	if got := aws.ToString(input.Message); got != "error rate above 5%" {
+		t.Errorf("message = %q, want the alert text", got)
+	}
+	// The subject is cut by characters, not bytes, so multi-byte runes stay whole
+	if got := aws.ToString(input.Subject); got != strings.Repeat("é", snsMaxSubjectLength) {
+		t.Errorf("subject = %q (%d runes), want %d runes", got, len([]rune(got)), snsMaxSubjectLength)
+	}
+
+	if len(input.MessageAttributes) != 2 {
+		t.Errorf("attributes = %v, want severity and appId only", input.MessageAttributes)
+	}
+	if _, ok := input.MessageAttributes["resource"]; ok {
+		t.Error("empty resource attribute was sent")
+	}
+	for name, want := range map[string]string{"severity": "critical", "appId": "app"} {
+		value, ok := input.MessageAttributes[name]
+		if !ok {
+			t.Errorf("attribute %s missing", name)
+			continue
+		}
+		if aws.ToString(value.DataType) != "String" || aws.ToString(value.StringValue) != want {
+			t.Errorf("attribute %s = %s %q, want String %q", name, aws.ToString(value.DataType), aws.ToString(value.StringValue), want)
+		}
+	}
+}
+
+func TestSNSPublisherOmitsEmptyFields(t *testing.T) {
+	fake := &fakeSNS{}
+	publisher := &SNSPublisher{client: fake, topicARN: "arn:aws:sns:us-east-1:123456789012:alerts"}
+
+	if err := publisher.Publish(context.Background(), "", "message", map[string]string{"appId": ""}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	input := fake.inputs[0]
+	if input.Subject != nil {
+		t.Errorf("subject = %q, want none", aws.ToString(input.Subject))
+	}
+	if input.MessageAttributes != nil {
+		t.Errorf("attributes = %v, want none when every value is empty", input.MessageAttributes)
+	}
+}
+
+func TestSNSPublisherWrapsErrors(t *testing.T) {
+	failure := errors.New("topic not found")
+	publisher := &SNSPublisher{client: &fakeSNS{err: failure}, topicARN: "arn:aws:sns:us-east-1:123456789012:alerts"}
+
+	err := publisher.Publish(context.Background(), "subject", "message", nil)
+	if !errors.Is(err, failure) {
+		t.Fatalf("Publish error = %v, want it to wrap %v", err, failure)
+	}
+	if !strings.Contains(err.Error(), publisher.topicARN) {
+		t.Errorf("Publish error = %q, want it to name the topic", err)
+	}
+}
@@ -0,0 +1,124 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+)
+
+const (
+	lambdaFunctionResourceType = "lambda:function"
+	taggedFunctionsCacheTTL    = 15 * time.Minute
+)
+
+// resourceTaggingAPI is the Resource Groups Tagging API call ResourceTaggingClient makes,
+// implemented by *resourcegroupstaggingapi.Client
+type resourceTaggingAPI interface {
+	GetResources(ctx context.Context, params *resourcegroupstaggingapi.GetResourcesInput, optFns ...func(*resourcegroupstaggingapi.Options)) (*resourcegroupstaggingapi.GetResourcesOutput, error)
+}
+
+// ResourceTaggingClient wraps the Resource Groups Tagging API client
+type ResourceTaggingClient struct {
+	client resourceTaggingAPI
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]taggedFunctionsEntry
+	stats *CacheStats
+}
+
+// taggedFunctionsEntry holds a cached list of discovered functions
+type taggedFunctionsEntry struct {
+	functions []string
+	fetchedAt time.Time
+}
+
+// NewResourceTaggingClient creates a new Resource Groups Tagging API client
+func NewResourceTaggingClient(cfg aws.Config) *ResourceTaggingClient {
+	return &ResourceTaggingClient{
+		client: resourcegroupstaggingapi.NewFromConfig(cfg),
+		ttl:    taggedFunctionsCacheTTL,
+		cache:  make(map[string]taggedFunctionsEntry),
+		stats:  newCacheStats("tagged_functions"),
+	}
+}
+
+// GetLambdaFunctionsByTag returns the names of Lambda functions carrying the given tag.
+// Results are cached per tag so dashboards don't hit the tagging API on every request; each
+// call returns its own copy, so callers may modify it.
+func (c *ResourceTaggingClient) GetLambdaFunctionsByTag(ctx context.Context, tagKey, tagValue string) ([]string, error) {
+	cacheKey := tagKey + "=" + tagValue
+
+	c.mu.Lock()
+	entry, ok := c.cache[cacheKey]
+	fresh := ok && time.Since(entry.fetchedAt) < c.ttl
+	c.mu.Unlock()
+	c.stats.recordLookup(fresh, ok && !fresh)
+	if fresh {
+		return append([]string(nil), entry.functions...), nil
+	}
+
+	var functions []string
+	var paginationToken *string
+
+	for {
+		output, err := c.client.GetResources(ctx, &resourcegroupstaggingapi.GetResourcesInput{
+			ResourceTypeFilters: []string{lambdaFunctionResourceType},
+			TagFilters: []types.TagFilter{
+				{
+					Key:    aws.String(tagKey),
+					Values: []string{tagValue},
+				},
+			},
+			PaginationToken: paginationToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tagged resources: %w", err)
+		}
+
+		for _, mapping := range output.ResourceTagMappingList {
+			if mapping.ResourceARN == nil {
+				continue
+			}
+			if name := lambdaFunctionNameFromARN(*mapping.ResourceARN); name != "" {
+				functions = append(functions, name)
+			}
+		}
+
+		// The tagging API signals the last page with an empty token
+		if output.PaginationToken == nil || *output.PaginationToken == "" {
+			break
+		}
+		paginationToken = output.PaginationToken
+	}
+
+	c.mu.Lock()
+	c.cache[cacheKey] = taggedFunctionsEntry{
+		functions: functions,
+		fetchedAt: time.Now(),
+	}
+	c.mu.Unlock()
+
+	return append([]string(nil), functions...), nil
+}
+
+// lambdaFunctionNameFromARN extracts the function name from a Lambda ARN
+// (arn:aws:lambda:region:account:function:name[:qualifier])
+func lambdaFunctionNameFromARN(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) < 7 || parts[5] != "function" {
+		return ""
+	}
+	return parts[6]
+}
+
+// CacheStats returns hit, miss and eviction counts for the tagged functions cache
+func (c *ResourceTaggingClient) CacheStats() CacheSnapshot {
+	return c.stats.Snapshot()
+}
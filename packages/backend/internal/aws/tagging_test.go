@@ -0,0 +1,181 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cwtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+)
+
+// fakeTagging answers GetResources with the page stored under the request's pagination
+// token ("" for the first page), recording each input
+type fakeTagging struct {
+	pages  map[string]*resourcegroupstaggingapi.GetResourcesOutput
+	inputs []resourcegroupstaggingapi.GetResourcesInput
+}
+
+func (f *fakeTagging) GetResources(ctx context.Context, params *resourcegroupstaggingapi.GetResourcesInput, optFns ...func(*resourcegroupstaggingapi.Options)) (*resourcegroupstaggingapi.GetResourcesOutput, error) {
+	f.inputs = append(f.inputs, *params)
+	page, ok := f.pages[aws.ToString(params.PaginationToken)]
+	if !ok {
+		return nil, errors.New("unexpected pagination token")
+	}
+	return page, nil
+}
+
+// taggedResources is a page of resources with the given ARNs
+func taggedResources(nextToken string, arns ...string) *resourcegroupstaggingapi.GetResourcesOutput {
+	output := &resourcegroupstaggingapi.GetResourcesOutput{PaginationToken: aws.String(nextToken)}
+	for _, arn := range arns {
+		output.ResourceTagMappingList = append(output.ResourceTagMappingList, types.ResourceTagMapping{ResourceARN: aws.String(arn)})
+	}
+	return output
+}
+
+// newTestTaggingClient returns a ResourceTaggingClient over fake two pages of resources
+// tagged Application=ilikeyacut
+func newTestTaggingClient() (*ResourceTaggingClient, *fakeTagging) {
+	fake := &fakeTagging{pages: map[string]*resourcegroupstaggingapi.GetResourcesOutput{
+		"": taggedResources("page-2",
+			"arn:aws:lambda:us-east-1:123456789012:function:ilikeyacut-checkout",
+			"arn:aws:lambda:us-east-1:123456789012:function:ilikeyacut-orders:live",
+			"arn:aws:lambda:us-east-1:123456789012:layer:shared:3",
+		),
+		"page-2": taggedResources("", "arn:aws:lambda:us-east-1:123456789012:function:ilikeyacut-reports"),
+	}}
+	fake.pages[""].ResourceTagMappingList = append(fake.pages[""].ResourceTagMappingList, types.ResourceTagMapping{})
+
+	return &ResourceTaggingClient{
+		client: fake,
+		ttl:    taggedFunctionsCacheTTL,
+		cache:  make(map[string]taggedFunctionsEntry),
+		stats:  newCacheStats("tagged_functions"),
+	}, fake
+}
+
+var taggedFunctionNames = []string{"ilikeyacut-checkout", "ilikeyacut-orders", "ilikeyacut-reports"}
+
+func TestGetLambdaFunctionsByTag(t *testing.T) {
+	client, fake := newTestTaggingClient()
+	ctx := context.Background()
+
+	functions, err := client.GetLambdaFunctionsByTag(ctx, "Application", "ilikeyacut")
+	if err != nil {
+		t.Fatalf("GetLambdaFunctionsByTag: %v", err)
+	}
+	if !reflect.DeepEqual(functions, taggedFunctionNames) {
+		t.Errorf("functions = %v, want %v", functions, taggedFunctionNames)
+	}
+
+	if len(fake.inputs) != 2 {
+		t.Fatalf("GetResources called %d times, want 2", len(fake.inputs))
+	}
+	for i, input := range fake.inputs {
+		if !reflect.DeepEqual(input.ResourceTypeFilters, []string{"lambda:function"}) {
+			t.Errorf("call %d ResourceTypeFilters = %v", i, input.ResourceTypeFilters)
+		}
+		if len(input.TagFilters) != 1 || aws.ToString(input.TagFilters[0].Key) != "Application" || !reflect.DeepEqual(input.TagFilters[0].Values, []string{"ilikeyacut"}) {
+			t.Errorf("call %d TagFilters = %+v, want Application=ilikeyacut", i, input.TagFilters)
+		}
+	}
+	if got := aws.ToString(fake.inputs[1].PaginationToken); got != "page-2" {
+		t.Errorf("second call PaginationToken = %q, want page-2", got)
+	}
+
+	// A caller modifying its result must not change what the cache hands out next
+	functions[0] = "modified"
+	cached, err := client.GetLambdaFunctionsByTag(ctx, "Application", "ilikeyacut")
+	if err != nil {
+		t.Fatalf("cached GetLambdaFunctionsByTag: %v", err)
+	}
+	if len(fake.inputs) != 2 {
+		t.Errorf("cached lookup called GetResources, %d calls in total", len(fake.inputs))
+	}
+	if !reflect.DeepEqual(cached, taggedFunctionNames) {
+		t.Errorf("cached functions = %v, want %v", cached, taggedFunctionNames)
+	}
+	cached[1] = "modified"
+	if again, _ := client.GetLambdaFunctionsByTag(ctx, "Application", "ilikeyacut"); !reflect.DeepEqual(again, taggedFunctionNames) {
+		t.Errorf("functions after modifying a cached result = %v, want %v", again, taggedFunctionNames)
+	}
+
+	stats := client.CacheStats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("cache stats = %+v, want 2 hits and 1 miss", stats)
+	}
+
+	// Another tag is cached separately, and expired entries are fetched again
+	client.GetLambdaFunctionsByTag(ctx, "Application", "other")
+	if len(fake.inputs) != 4 {
+		t.Errorf("lookup of another tag made %d calls in total, want 4", len(fake.inputs))
+	}
+	client.ttl = 0
+	client.GetLambdaFunctionsByTag(ctx, "Application", "ilikeyacut")
+	if len(fake.inputs) != 6 {
+		t.Errorf("lookup after expiry made %d calls in total, want 6", len(fake.inputs))
+	}
+}
+
+func TestGetLambdaFunctionsByTagFailure(t *testing.T) {
+	client, fake := newTestTaggingClient()
+	delete(fake.pages, "page-2")
+
+	if _, err := client.GetLambdaFunctionsByTag(context.Background(), "Application", "ilikeyacut"); err == nil {
+		t.Fatal("GetLambdaFunctionsByTag succeeded with a failing second page")
+	}
+	if len(client.cache) != 0 {
+		t.Errorf("failed lookup was cached: %+v", client.cache)
+	}
+}
+
+func TestTaggedFunctionsDriveMetricQueries(t *testing.T) {
+	tagging, _ := newTestTaggingClient()
+	functions, err := tagging.GetLambdaFunctionsByTag(context.Background(), "Application", "ilikeyacut")
+	if err != nil {
+		t.Fatalf("GetLambdaFunctionsByTag: %v", err)
+	}
+
+	metrics := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []cwtypes.MetricDataResult{
+			{Id: aws.String("invocations_0"), Values: []float64{4}},
+			{Id: aws.String("invocations_1"), Values: []float64{5}},
+			{Id: aws.String("invocations_2"), Values: []float64{6}},
+		},
+	}}}}
+	cloudWatch := &CloudWatchClient{client: metrics, maxAttempts: 1}
+
+	batch, err := cloudWatch.GetLambdaMetricsBatch(context.Background(), functions, pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetLambdaMetricsBatch: %v", err)
+	}
+
+	queried := make(map[string]bool)
+	for _, query := range metrics.inputs[0].MetricDataQueries {
+		for _, dimension := range query.MetricStat.Metric.Dimensions {
+			if aws.ToString(dimension.Name) == "FunctionName" {
+				queried[aws.ToString(dimension.Value)] = true
+			}
+		}
+	}
+	var names []string
+	for name := range queried {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, taggedFunctionNames) {
+		t.Errorf("queried functions = %v, want %v", names, taggedFunctionNames)
+	}
+
+	for i, name := range taggedFunctionNames {
+		if batch[name] == nil || batch[name].Invocations != float64(4+i) {
+			t.Errorf("%s metrics = %+v, want %d invocations", name, batch[name], 4+i)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// DefaultQueryTimeout bounds each individual AWS call
+const DefaultQueryTimeout = 8 * time.Second
+
+// WithQueryTimeout returns an API option that gives every AWS call, including its retries,
+// its own deadline derived from the caller's context. In a fan-out a stalled query then fails
+// fast instead of consuming the whole request budget. Add it to aws.Config.APIOptions.
+func WithQueryTimeout(timeout time.Duration) func(*middleware.Stack) error {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("QueryTimeout",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				ctx, cancel := context.WithTimeout(ctx, timeout)
+				defer cancel()
+				return next.HandleInitialize(ctx, in)
+			}), middleware.Before)
+	}
+}
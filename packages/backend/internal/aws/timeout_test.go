@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// stallingCall runs a call through a stack with WithQueryTimeout applied. A call for a stalled
+// query blocks until its context is done; the rest return at once.
+func stallingCall(t *testing.T, timeout time.Duration) func(ctx context.Context, query string) error {
+	t.Helper()
+
+	stack := middleware.NewStack("GetMetricData", func() interface{} { return struct{}{} })
+	if err := WithQueryTimeout(timeout)(stack); err != nil {
+		t.Fatalf("WithQueryTimeout: %v", err)
+	}
+
+	return func(ctx context.Context, query string) error {
+		handler := middleware.DecorateHandler(middleware.HandlerFunc(func(ctx context.Context, input interface{}) (interface{}, middleware.Metadata, error) {
+			if query == "stalled" {
+				<-ctx.Done()
+				return nil, middleware.Metadata{}, ctx.Err()
+			}
+			return struct{}{}, middleware.Metadata{}, nil
+		}), stack)
+		_, _, err := handler.Handle(ctx, query)
+		return err
+	}
+}
+
+func TestQueryTimeoutFailsStalledQueryOnly(t *testing.T) {
+	call := stallingCall(t, 50*time.Millisecond)
+
+	// The overall deadline is far beyond the per-query timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	queries := []string{"invocations", "stalled", "errors", "duration"}
+	errs := make([]error, len(queries))
+	started := time.Now()
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func(i int, query string) {
+			defer wg.Done()
+			errs[i] = call(ctx, query)
+		}(i, query)
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("fan-out took %s, want the stalled query to fail after its own timeout", elapsed)
+	}
+	for i, query := range queries {
+		if query == "stalled" {
+			if !errors.Is(errs[i], context.DeadlineExceeded) {
+				t.Errorf("stalled query: err = %v, want context.DeadlineExceeded", errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Errorf("%s query: %v", query, errs[i])
+		}
+	}
+	if ctx.Err() != nil {
+		t.Error("the overall deadline was consumed")
+	}
+}
+
+func TestQueryTimeoutDefault(t *testing.T) {
+	stack := middleware.NewStack("GetMetricData", func() interface{} { return struct{}{} })
+	if err := WithQueryTimeout(0)(stack); err != nil {
+		t.Fatalf("WithQueryTimeout: %v", err)
+	}
+
+	handler := middleware.DecorateHandler(middleware.HandlerFunc(func(ctx context.Context, input interface{}) (interface{}, middleware.Metadata, error) {
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > DefaultQueryTimeout || time.Until(deadline) < DefaultQueryTimeout-time.Second {
+			t.Errorf("deadline = %v (set %v), want about %s away", deadline, ok, DefaultQueryTimeout)
+		}
+		return nil, middleware.Metadata{}, nil
+	}), stack)
+	if _, _, err := handler.Handle(context.Background(), nil); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+}
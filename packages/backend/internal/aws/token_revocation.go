@@ -0,0 +1,99 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TokenRevocationStore persists revoked session token IDs. Items carry an "expiresAt" epoch
+// attribute matching the token's own expiry, which the table's TTL setting uses for cleanup.
+type TokenRevocationStore struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewTokenRevocationStore creates a new DynamoDB-backed token revocation store
+func NewTokenRevocationStore(cfg aws.Config, tableName string) *TokenRevocationStore {
+	return &TokenRevocationStore{
+		client:    dynamodb.NewFromConfig(cfg),
+		tableName: tableName,
+	}
+}
+
+// Revoke records a token ID as revoked until expiresAt
+func (s *TokenRevocationStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]ddbtypes.AttributeValue{
+			"tokenId":   &ddbtypes.AttributeValueMemberS{Value: tokenID},
+			"revokedAt": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+			"expiresAt": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store token revocation: %w", err)
+	}
+	return nil
+}
+
+// RevokeOnce records a token ID as revoked until expiresAt with a conditional write, reporting
+// false if an unexpired revocation record already exists
+func (s *TokenRevocationStore) RevokeOnce(ctx context.Context, tokenID string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.tableName),
+		Item: map[string]ddbtypes.AttributeValue{
+			"tokenId":   &ddbtypes.AttributeValueMemberS{Value: tokenID},
+			"revokedAt": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			"expiresAt": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(expiresAt.Unix(), 10)},
+		},
+		// DynamoDB TTL deletion is lazy, so expired records may still be present
+		ConditionExpression: aws.String("attribute_not_exists(tokenId) OR expiresAt < :now"),
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":now": &ddbtypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	})
+	if err == nil {
+		return true, nil
+	}
+
+	var conditionErr *ddbtypes.ConditionalCheckFailedException
+	if errors.As(err, &conditionErr) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to store token revocation: %w", err)
+}
+
+// IsRevoked reports whether a token ID has an unexpired revocation record
+func (s *TokenRevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	output, err := s.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.tableName),
+		Key: map[string]ddbtypes.AttributeValue{
+			"tokenId": &ddbtypes.AttributeValueMemberS{Value: tokenID},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to get token revocation: %w", err)
+	}
+	if output.Item == nil {
+		return false, nil
+	}
+
+	// DynamoDB TTL deletion is lazy, so expired records may still be present; the token
+	// itself has expired by then and fails validation without the blacklist
+	if v, ok := output.Item["expiresAt"].(*ddbtypes.AttributeValueMemberN); ok {
+		if n, err := strconv.ParseInt(v.Value, 10, 64); err == nil && time.Unix(n, 0).Before(time.Now()) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,77 @@
+package aws
+
+import (
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// metricUnits maps CloudWatch namespaces and metric names to the unit CloudWatch reports them in.
+// GetMetricData does not return units, so they are resolved from the query instead.
+var metricUnits = map[string]map[string]types.StandardUnit{
+	"AWS/Lambda": {
+		"Invocations":          types.StandardUnitCount,
+		"Errors":               types.StandardUnitCount,
+		"Duration":             types.StandardUnitMilliseconds,
+		"Throttles":            types.StandardUnitCount,
+		"ConcurrentExecutions": types.StandardUnitCount,
+		"AsyncEventsReceived":  types.StandardUnitCount,
+	},
+	"AWS/ApiGateway": {
+		"Count":              types.StandardUnitCount,
+		"Latency":            types.StandardUnitMilliseconds,
+		"IntegrationLatency": types.StandardUnitMilliseconds,
+		"4XXError":           types.StandardUnitCount,
+		"5XXError":           types.StandardUnitCount,
+	},
+	"AWS/DynamoDB": {
+		"ConsumedReadCapacityUnits":     types.StandardUnitCount,
+		"ConsumedWriteCapacityUnits":    types.StandardUnitCount,
+		"ProvisionedReadCapacityUnits":  types.StandardUnitCount,
+		"ProvisionedWriteCapacityUnits": types.StandardUnitCount,
+		"ThrottledRequests":             types.StandardUnitCount,
+		"ReadThrottleEvents":            types.StandardUnitCount,
+		"WriteThrottleEvents":           types.StandardUnitCount,
+		"UserErrors":                    types.StandardUnitCount,
+		"SystemErrors":                  types.StandardUnitCount,
+		"SuccessfulRequestLatency":      types.StandardUnitMilliseconds,
+	},
+}
+
+// MetricUnit returns the CloudWatch unit for a metric, or "None" if it is not known
+func MetricUnit(namespace, metricName string) string {
+	if unit, ok := metricUnits[namespace][metricName]; ok {
+		return string(unit)
+	}
+	return string(types.StandardUnitNone)
+}
+
+// queryUnits maps each metric query ID to the unit of the metric it requests
+func queryUnits(queries []types.MetricDataQuery) map[string]string {
+	units := make(map[string]string, len(queries))
+	for _, query := range queries {
+		if query.Id == nil || query.MetricStat == nil || query.MetricStat.Metric == nil {
+			continue
+		}
+		metric := query.MetricStat.Metric
+		if metric.Namespace == nil || metric.MetricName == nil {
+			continue
+		}
+		units[*query.Id] = MetricUnit(*metric.Namespace, *metric.MetricName)
+	}
+	return units
+}
+
+// resultDatapoints converts a metric data result into datapoints carrying the given unit
+func resultDatapoints(result types.MetricDataResult, unit string) []MetricDatapoint {
+	datapoints := make([]MetricDatapoint, 0, len(result.Timestamps))
+	for i, timestamp := range result.Timestamps {
+		if i >= len(result.Values) {
+			break
+		}
+		datapoints = append(datapoints, MetricDatapoint{
+			Timestamp: timestamp,
+			Value:     result.Values[i],
+			Unit:      unit,
+		})
+	}
+	return datapoints
+}
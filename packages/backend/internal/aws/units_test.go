@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+func TestMetricUnit(t *testing.T) {
+	tests := []struct {
+		namespace, metricName string
+		want                  string
+	}{
+		{namespace: "AWS/Lambda", metricName: "Invocations", want: "Count"},
+		{namespace: "AWS/Lambda", metricName: "Duration", want: "Milliseconds"},
+		{namespace: "AWS/ApiGateway", metricName: "Latency", want: "Milliseconds"},
+		{namespace: "AWS/ApiGateway", metricName: "Count", want: "Count"},
+		{namespace: "AWS/DynamoDB", metricName: "SuccessfulRequestLatency", want: "Milliseconds"},
+		{namespace: "AWS/DynamoDB", metricName: "ConsumedReadCapacityUnits", want: "Count"},
+		{namespace: "AWS/Lambda", metricName: "Latency", want: "None"},
+		{namespace: "Custom/App", metricName: "Duration", want: "None"},
+	}
+
+	for _, tt := range tests {
+		if got := MetricUnit(tt.namespace, tt.metricName); got != tt.want {
+			t.Errorf("MetricUnit(%q, %q) = %q, want %q", tt.namespace, tt.metricName, got, tt.want)
+		}
+	}
+}
+
+// checkSeriesUnits fails t unless every datapoint of each series carries the wanted unit
+func checkSeriesUnits(t *testing.T, metrics *LambdaMetrics) {
+	t.Helper()
+
+	for id, want := range map[string]string{"invocations": "Count", "errors": "Count", "duration": "Milliseconds"} {
+		series := metrics.Series[id]
+		if len(series) == 0 {
+			t.Errorf("no %s datapoints", id)
+		}
+		for _, datapoint := range series {
+			if datapoint.Unit != want {
+				t.Errorf("%s datapoint at %s has unit %q, want %q", id, datapoint.Timestamp, datapoint.Unit, want)
+			}
+		}
+	}
+	for _, datapoint := range metrics.Datapoints {
+		if datapoint.Unit != "Count" {
+			t.Errorf("invocation datapoint at %s has unit %q, want Count", datapoint.Timestamp, datapoint.Unit)
+		}
+	}
+}
+
+func TestLambdaDatapointsCarryUnits(t *testing.T) {
+	client := &CloudWatchClient{client: &fakeMetricData{pages: twoPages()}, maxAttempts: 1}
+
+	metrics, err := client.GetLambdaMetrics(context.Background(), "checkout", pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetLambdaMetrics: %v", err)
+	}
+	if len(metrics.Datapoints) != 3 {
+		t.Errorf("got %d invocation datapoints, want 3", len(metrics.Datapoints))
+	}
+	checkSeriesUnits(t, metrics)
+}
+
+func TestBatchedLambdaDatapointsCarryUnits(t *testing.T) {
+	timestamps := []time.Time{hour(0), hour(1)}
+	fake := &fakeMetricData{pages: []fakeMetricDataPage{{output: &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []types.MetricDataResult{
+			{Id: aws.String("invocations_0"), Values: []float64{10, 20}, Timestamps: timestamps},
+			{Id: aws.String("errors_0"), Values: []float64{1, 0}, Timestamps: timestamps},
+			{Id: aws.String("duration_0"), Values: []float64{120, 80}, Timestamps: timestamps},
+		},
+	}}}}
+	client := &CloudWatchClient{client: fake, maxAttempts: 1}
+
+	batch, err := client.GetLambdaMetricsBatch(context.Background(), []string{"checkout"}, pageStart, pageEnd)
+	if err != nil {
+		t.Fatalf("GetLambdaMetricsBatch: %v", err)
+	}
+	metrics, ok := batch["checkout"]
+	if !ok {
+		t.Fatalf("no metrics for checkout in %v", batch)
+	}
+	checkSeriesUnits(t, metrics)
+}
@@ -0,0 +1,26 @@
+package aws
+
+// WeightedMean accumulates an average in which each value counts in proportion to its weight.
+// Combining per-function durations weighted by invocations lets a busy slow function dominate
+// the way it does for callers, where an equal-weight mean would let an idle function skew it.
+type WeightedMean struct {
+	sum    float64
+	weight float64
+}
+
+// Add includes value with the given weight. Values with no weight are ignored.
+func (m *WeightedMean) Add(value, weight float64) {
+	if weight <= 0 {
+		return
+	}
+	m.sum += value * weight
+	m.weight += weight
+}
+
+// Value returns the weighted mean, or 0 when nothing with weight was added
+func (m *WeightedMean) Value() float64 {
+	if m.weight == 0 {
+		return 0
+	}
+	return m.sum / m.weight
+}
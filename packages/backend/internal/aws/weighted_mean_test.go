@@ -0,0 +1,55 @@
+package aws
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWeightedMean(t *testing.T) {
+	// A busy slow function and an idle fast one: 1000 calls at 800ms against 10 at 50ms
+	functions := []struct {
+		duration    float64
+		invocations float64
+	}{
+		{duration: 800, invocations: 1000},
+		{duration: 50, invocations: 10},
+	}
+
+	var weighted WeightedMean
+	var unweighted float64
+	for _, fn := range functions {
+		weighted.Add(fn.duration, fn.invocations)
+		unweighted += fn.duration / float64(len(functions))
+	}
+
+	// Callers waited 800ms on all but 10 of 1010 calls, which the weighted mean reflects
+	want := (800*1000 + 50*10) / 1010.0
+	if got := weighted.Value(); math.Abs(got-want) > 1e-9 {
+		t.Errorf("weighted mean = %v, want %v", got, want)
+	}
+	if unweighted != 425 {
+		t.Fatalf("unweighted mean = %v, want 425", unweighted)
+	}
+	if got := weighted.Value(); got < 790 || math.Abs(got-unweighted) < 1 {
+		t.Errorf("weighted mean %v is not dominated by the busy function (unweighted %v)", got, unweighted)
+	}
+}
+
+func TestWeightedMeanIgnoresUnweightedValues(t *testing.T) {
+	var mean WeightedMean
+	if got := mean.Value(); got != 0 {
+		t.Errorf("empty mean = %v, want 0", got)
+	}
+
+	// A function with no invocations reports no duration worth averaging
+	mean.Add(900, 0)
+	mean.Add(700, -1)
+	if got := mean.Value(); got != 0 {
+		t.Errorf("mean of unweighted values = %v, want 0", got)
+	}
+
+	mean.Add(120, 3)
+	if got := mean.Value(); got != 120 {
+		t.Errorf("mean = %v, want 120", got)
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache is a concurrency-safe in-memory cache whose entries expire a fixed time after they
+// are set. Values are copied on the way in and out with the clone function given to New, so
+// callers can't mutate what other readers see.
+type TTLCache[K comparable, V any] struct {
+	ttl   time.Duration
+	clone func(V) V
+
+	mu        sync.Mutex
+	entries   map[K]ttlEntry[V]
+	lastSweep time.Time
+}
+
+// ttlEntry is a cached value and when it expires
+type ttlEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// New creates a cache whose entries live for ttl. clone copies a value; nil stores and
+// returns values as they are, which is only safe for immutable values.
+func New[K comparable, V any](ttl time.Duration, clone func(V) V) *TTLCache[K, V] {
+	if clone == nil {
+		clone = func(value V) V { return value }
+	}
+	return &TTLCache[K, V]{
+		ttl:       ttl,
+		clone:     clone,
+		entries:   make(map[K]ttlEntry[V]),
+		lastSweep: time.Now(),
+	}
+}
+
+// TTL returns how long entries live
+func (c *TTLCache[K, V]) TTL() time.Duration {
+	return c.ttl
+}
+
+// Get returns a copy of the value cached under key, reporting false when there is none or it
+// has expired
+func (c *TTLCache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if !time.Now().Before(entry.expires) {
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	return c.clone(entry.value), true
+}
+
+// Set caches a copy of value under key for the cache's TTL. Expired entries are swept at most
+// once per TTL so keys that are never read again don't accumulate.
+func (c *TTLCache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= c.ttl {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+	c.entries[key] = ttlEntry[V]{value: c.clone(value), expires: now.Add(c.ttl)}
+}
+
+// Delete removes the value cached under key
+func (c *TTLCache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Len returns the number of entries held, including expired ones not yet swept
+func (c *TTLCache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTTLCacheGetSet(t *testing.T) {
+	c := New[string, int](time.Minute, nil)
+
+	if _, ok := c.Get("missing"); ok {
+		t.Fatal("Get of a missing key reported a value")
+	}
+
+	c.Set("a", 1)
+	got, ok := c.Get("a")
+	if !ok || got != 1 {
+		t.Fatalf("Get(a) = %d, %v; want 1, true", got, ok)
+	}
+
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get after Delete reported a value")
+	}
+}
+
+func TestTTLCacheExpiry(t *testing.T) {
+	ttl := 20 * time.Millisecond
+	c := New[string, int](ttl, nil)
+
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("entry expired before its TTL")
+	}
+
+	time.Sleep(2 * ttl)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("entry was served after its TTL")
+	}
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len after reading an expired entry = %d, want 0", n)
+	}
+}
+
+func TestTTLCacheSweepsExpiredEntries(t *testing.T) {
+	ttl := 20 * time.Millisecond
+	c := New[string, int](ttl, nil)
+
+	for i := 0; i < 10; i++ {
+		c.Set(fmt.Sprint(i), i)
+	}
+	time.Sleep(2 * ttl)
+
+	// Setting after a TTL has passed sweeps the entries that expired without being read
+	c.Set("fresh", 1)
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len after sweep = %d, want 1", n)
+	}
+}
+
+func TestTTLCacheClonesValues(t *testing.T) {
+	clone := func(value []byte) []byte { return append([]byte(nil), value...) }
+	c := New[string](time.Minute, clone)
+
+	value := []byte("abc")
+	c.Set("a", value)
+	value[0] = 'x'
+
+	got, _ := c.Get("a")
+	if string(got) != "abc" {
+		t.Fatalf("cached value changed with the caller's slice: %q", got)
+	}
+
+	got[1] = 'y'
+	again, _ := c.Get("a")
+	if string(again) != "abc" {
+		t.Fatalf("cached value changed with a reader's slice: %q", again)
+	}
+}
+
+func TestTTLCacheConcurrentAccess(t *testing.T) {
+	c := New[int, int](time.Minute, nil)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 50; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := (g + i) % 10
+				c.Set(key, i)
+				c.Get(key)
+				if i%10 == 0 {
+					c.Delete(key)
+				}
+				c.Len()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := c.Len(); n > 10 {
+		t.Errorf("Len = %d, want at most 10", n)
+	}
+}
@@ -0,0 +1,100 @@
+// Package clientip resolves the real client address of requests arriving through proxies
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const forwardedForHeader = "X-Forwarded-For"
+
+// DefaultTrustedProxies covers the local HTTPS proxy, which forwards from loopback
+var DefaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// Resolver extracts the client IP from X-Forwarded-For, trusting the header only when
+// it was added by a known proxy
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// NewResolver creates a resolver that trusts the given CIDRs (or bare IPs) as proxies
+func NewResolver(trustedProxies []string) (*Resolver, error) {
+	resolver := &Resolver{}
+	for _, entry := range trustedProxies {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		resolver.trusted = append(resolver.trusted, network)
+	}
+	return resolver, nil
+}
+
+// ClientIP returns the address of the client that sent the request. X-Forwarded-For is walked
+// from the nearest hop outwards, skipping trusted proxies; the first untrusted hop is the client.
+// Requests not arriving from a trusted proxy use RemoteAddr so the header can't be spoofed.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	remote := remoteHost(req.RemoteAddr)
+	if r == nil || !r.isTrusted(remote) {
+		return remote
+	}
+
+	hops := forwardedHops(req.Header.Values(forwardedForHeader))
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !r.isTrusted(hops[i]) {
+			return hops[i]
+		}
+	}
+	if len(hops) > 0 {
+		return hops[0]
+	}
+	return remote
+}
+
+// isTrusted reports whether an address belongs to a trusted proxy
+func (r *Resolver) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range r.trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedHops splits X-Forwarded-For header values into individual addresses, in order
+func forwardedHops(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, hop := range strings.Split(value, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, remoteHost(hop))
+			}
+		}
+	}
+	return hops
+}
+
+// remoteHost strips the port from an address, if present
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.Trim(addr, "[]")
+}
@@ -0,0 +1,66 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := NewResolver(append([]string{"10.0.0.0/8", "192.0.2.10"}, DefaultTrustedProxies...))
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		wantClientIP string
+	}{
+		{name: "direct request", remoteAddr: "203.0.113.7:51000", wantClientIP: "203.0.113.7"},
+		{name: "single forwarded hop", remoteAddr: "127.0.0.1:40000", forwardedFor: []string{"203.0.113.7"}, wantClientIP: "203.0.113.7"},
+		{name: "chained through trusted proxies", remoteAddr: "127.0.0.1:40000", forwardedFor: []string{"203.0.113.7, 10.1.2.3, 192.0.2.10"}, wantClientIP: "203.0.113.7"},
+		{name: "chain across header lines", remoteAddr: "127.0.0.1:40000", forwardedFor: []string{"203.0.113.7", "10.1.2.3"}, wantClientIP: "203.0.113.7"},
+		{name: "spoofed hops before the client are ignored", remoteAddr: "127.0.0.1:40000", forwardedFor: []string{"198.51.100.1, 203.0.113.7, 10.1.2.3"}, wantClientIP: "203.0.113.7"},
+		{name: "untrusted proxy can't forge the header", remoteAddr: "198.51.100.9:40000", forwardedFor: []string{"203.0.113.7"}, wantClientIP: "198.51.100.9"},
+		{name: "every hop trusted", remoteAddr: "127.0.0.1:40000", forwardedFor: []string{"10.1.2.3, 10.4.5.6"}, wantClientIP: "10.1.2.3"},
+		{name: "trusted proxy without the header", remoteAddr: "127.0.0.1:40000", wantClientIP: "127.0.0.1"},
+		{name: "IPv6 client through loopback", remoteAddr: "[::1]:40000", forwardedFor: []string{"2001:db8::1"}, wantClientIP: "2001:db8::1"},
+		{name: "hop with a port", remoteAddr: "127.0.0.1:40000", forwardedFor: []string{"203.0.113.7:51000"}, wantClientIP: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add(forwardedForHeader, value)
+			}
+
+			if got := resolver.ClientIP(req); got != tt.wantClientIP {
+				t.Errorf("ClientIP = %q, want %q", got, tt.wantClientIP)
+			}
+		})
+	}
+}
+
+func TestClientIPWithoutResolver(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "127.0.0.1:40000"
+	req.Header.Set(forwardedForHeader, "203.0.113.7")
+
+	var resolver *Resolver
+	if got := resolver.ClientIP(req); got != "127.0.0.1" {
+		t.Errorf("ClientIP = %q, want RemoteAddr when no proxies are trusted", got)
+	}
+}
+
+func TestNewResolverRejectsInvalidProxies(t *testing.T) {
+	if _, err := NewResolver([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("NewResolver accepted an invalid CIDR")
+	}
+	if _, err := NewResolver([]string{"not-an-ip"}); err == nil {
+		t.Error("NewResolver accepted an invalid address")
+	}
+}
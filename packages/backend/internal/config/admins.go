@@ -0,0 +1,23 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// AdminAppleSubs returns the Apple ID subs granted admin access, read from the comma-separated
+// ADMIN_APPLE_SUBS. ADMIN_APPLE_SUB is still honoured as a single-admin fallback.
+func AdminAppleSubs() []string {
+	var subs []string
+	for _, sub := range strings.Split(os.Getenv("ADMIN_APPLE_SUBS"), ",") {
+		if sub = strings.TrimSpace(sub); sub != "" {
+			subs = append(subs, sub)
+		}
+	}
+	if len(subs) == 0 {
+		if sub := strings.TrimSpace(os.Getenv("ADMIN_APPLE_SUB")); sub != "" {
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
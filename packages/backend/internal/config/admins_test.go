@@ -0,0 +1,32 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAdminAppleSubs(t *testing.T) {
+	tests := []struct {
+		name   string
+		subs   string
+		single string
+		want   []string
+	}{
+		{name: "list", subs: "001.first, 002.second,,", want: []string{"001.first", "002.second"}},
+		{name: "list wins over single", subs: "001.first", single: "009.legacy", want: []string{"001.first"}},
+		{name: "single fallback", single: " 009.legacy ", want: []string{"009.legacy"}},
+		{name: "blank list falls back", subs: " , ", single: "009.legacy", want: []string{"009.legacy"}},
+		{name: "neither set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_APPLE_SUBS", tt.subs)
+			t.Setenv("ADMIN_APPLE_SUB", tt.single)
+
+			if got := AdminAppleSubs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AdminAppleSubs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AlertThreshold is the value a health metric must exceed to degrade its service, and the
+// higher value it must exceed to make it critical
+type AlertThreshold struct {
+	Degraded float64 `json:"degraded"`
+	Critical float64 `json:"critical"`
+}
+
+// AlertRules holds the thresholds health checks and alerts evaluate each service against
+type AlertRules struct {
+	LambdaErrorRate      AlertThreshold `json:"lambdaErrorRate"`      // % of invocations
+	LambdaThrottles      AlertThreshold `json:"lambdaThrottles"`      // throttled invocations
+	APIErrorRate         AlertThreshold `json:"apiErrorRate"`         // % of requests answered 4XX or 5XX
+	APILatencyP99        AlertThreshold `json:"apiLatencyP99"`        // milliseconds
+	DynamoDBThrottles    AlertThreshold `json:"dynamodbThrottles"`    // throttled requests
+	DynamoDBSystemErrors AlertThreshold `json:"dynamodbSystemErrors"` // system errors
+}
+
+// DefaultAlertRules returns the thresholds used unless overridden. Any throttling or DynamoDB
+// system error degrades a service. Tail latency is checked rather than the average, which
+// hides the slow requests users notice.
+func DefaultAlertRules() AlertRules {
+	return AlertRules{
+		LambdaErrorRate:      AlertThreshold{Degraded: 5, Critical: 25},
+		LambdaThrottles:      AlertThreshold{Degraded: 0, Critical: 100},
+		APIErrorRate:         AlertThreshold{Degraded: 5, Critical: 25},
+		APILatencyP99:        AlertThreshold{Degraded: 2500, Critical: 10000},
+		DynamoDBThrottles:    AlertThreshold{Degraded: 0, Critical: 100},
+		DynamoDBSystemErrors: AlertThreshold{Degraded: 0, Critical: 10},
+	}
+}
+
+// LoadAlertRules loads the default rules, overridable with ALERT_<RULE>=<degraded>[,<critical>]
+// (e.g. ALERT_LAMBDA_ERROR_RATE=2,10). Invalid values are ignored, and a critical threshold
+// below the degraded one is raised to it.
+func LoadAlertRules() AlertRules {
+	rules := DefaultAlertRules()
+
+	overrides := map[string]*AlertThreshold{
+		"LAMBDA_ERROR_RATE":      &rules.LambdaErrorRate,
+		"LAMBDA_THROTTLES":       &rules.LambdaThrottles,
+		"API_ERROR_RATE":         &rules.APIErrorRate,
+		"API_LATENCY_P99":        &rules.APILatencyP99,
+		"DYNAMODB_THROTTLES":     &rules.DynamoDBThrottles,
+		"DYNAMODB_SYSTEM_ERRORS": &rules.DynamoDBSystemErrors,
+	}
+	for name, threshold := range overrides {
+		value := os.Getenv("ALERT_" + name)
+		if value == "" {
+			continue
+		}
+		if parsed, ok := parseAlertThreshold(value, *threshold); ok {
+			*threshold = parsed
+		}
+	}
+
+	return rules
+}
+
+// parseAlertThreshold parses "<degraded>[,<critical>]", keeping current's critical threshold
+// when only the degraded one is given
+func parseAlertThreshold(value string, current AlertThreshold) (AlertThreshold, bool) {
+	degradedValue, criticalValue, hasCritical := strings.Cut(value, ",")
+
+	degraded, err := strconv.ParseFloat(strings.TrimSpace(degradedValue), 64)
+	if err != nil || degraded < 0 {
+		return AlertThreshold{}, false
+	}
+	threshold := AlertThreshold{Degraded: degraded, Critical: current.Critical}
+	if hasCritical {
+		critical, err := strconv.ParseFloat(strings.TrimSpace(criticalValue), 64)
+		if err != nil || critical < 0 {
+			return AlertThreshold{}, false
+		}
+		threshold.Critical = critical
+	}
+	threshold.Critical = max(threshold.Critical, threshold.Degraded)
+	return threshold, true
+}
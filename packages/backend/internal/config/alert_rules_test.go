@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestLoadAlertRulesOverrides(t *testing.T) {
+	t.Setenv("ALERT_LAMBDA_ERROR_RATE", "2,10")
+	t.Setenv("ALERT_API_LATENCY_P99", "3000")
+	t.Setenv("ALERT_DYNAMODB_THROTTLES", "20,5")
+	t.Setenv("ALERT_API_ERROR_RATE", "lots")
+
+	rules := LoadAlertRules()
+	defaults := DefaultAlertRules()
+
+	tests := []struct {
+		name string
+		got  AlertThreshold
+		want AlertThreshold
+	}{
+		{"both thresholds", rules.LambdaErrorRate, AlertThreshold{Degraded: 2, Critical: 10}},
+		{"degraded only keeps the default critical", rules.APILatencyP99, AlertThreshold{Degraded: 3000, Critical: defaults.APILatencyP99.Critical}},
+		{"critical below degraded is raised", rules.DynamoDBThrottles, AlertThreshold{Degraded: 20, Critical: 20}},
+		{"invalid value is ignored", rules.APIErrorRate, defaults.APIErrorRate},
+		{"unset keeps the default", rules.LambdaThrottles, defaults.LambdaThrottles},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, tt.got, tt.want)
+		}
+	}
+}
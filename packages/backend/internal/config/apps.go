@@ -7,13 +7,20 @@ import (
 
 // AppConfig represents configuration for a single application
 type AppConfig struct {
-	ID               string   `json:"id"`
-	Name             string   `json:"name"`
-	AppStoreID       string   `json:"appStoreId"`
-	LambdaFunctions  []string `json:"lambdaFunctions"`
-	APIGateway       string   `json:"apiGateway"`
-	DynamoDBTables   []string `json:"dynamodbTables"`
-	Environment      string   `json:"environment"`
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	AppStoreID      string   `json:"appStoreId"`
+	LambdaFunctions []string `json:"lambdaFunctions"`
+	LambdaTag       string   `json:"lambdaTag,omitempty"`
+	CostCategory    string   `json:"costCategory,omitempty"`
+	CostTag         string   `json:"costTag,omitempty"`
+	TrackedServices []string `json:"trackedServices,omitempty"`
+	CostRegions     []string `json:"costRegions,omitempty"`
+	APIGateway      string   `json:"apiGateway"`
+	DynamoDBTables  []string `json:"dynamodbTables"`
+	Environment     string   `json:"environment"`
+	// Signals scored in the app's health summary and their weights; nil scores every signal equally
+	HealthSignals HealthSignalWeights `json:"healthSignals,omitempty"`
 }
 
 // AppsConfiguration manages application configurations
@@ -43,10 +50,18 @@ func (c *AppsConfiguration) loadAppConfigurations() {
 		Environment: getEnvOrDefault("ILIKEYACUT_ENV", "dev"),
 	}
 
+	// Lambda functions can be discovered by tag (e.g. Application=ilikeyacut)
+	// when no explicit list is configured
+	ilikeyacutConfig.LambdaTag = os.Getenv("ILIKEYACUT_LAMBDA_TAG")
+
 	// Parse Lambda functions from environment
-	lambdaFuncs := getEnvOrDefault("ILIKEYACUT_LAMBDA_FUNCTIONS",
-		"ilikeyacut-gemini-proxy-dev,ilikeyacut-auth-dev,ilikeyacut-templates-dev,ilikeyacut-user-data-dev,ilikeyacut-purchase-dev,ilikeyacut-iap-webhook-dev")
-	ilikeyacutConfig.LambdaFunctions = strings.Split(lambdaFuncs, ",")
+	lambdaFuncs := os.Getenv("ILIKEYACUT_LAMBDA_FUNCTIONS")
+	if lambdaFuncs == "" && ilikeyacutConfig.LambdaTag == "" {
+		lambdaFuncs = "ilikeyacut-gemini-proxy-dev,ilikeyacut-auth-dev,ilikeyacut-templates-dev,ilikeyacut-user-data-dev,ilikeyacut-purchase-dev,ilikeyacut-iap-webhook-dev"
+	}
+	if lambdaFuncs != "" {
+		ilikeyacutConfig.LambdaFunctions = strings.Split(lambdaFuncs, ",")
+	}
 
 	// Set API Gateway
 	ilikeyacutConfig.APIGateway = getEnvOrDefault("ILIKEYACUT_API_GATEWAY", "ilikeyacut-api-dev")
@@ -56,6 +71,27 @@ func (c *AppsConfiguration) loadAppConfigurations() {
 		"ilikeyacut-users-dev,ilikeyacut-transactions-dev,ilikeyacut-templates-dev,ilikeyacut-rate-limits-dev")
 	ilikeyacutConfig.DynamoDBTables = strings.Split(dynamoTables, ",")
 
+	// Spend can be isolated with an AWS Cost Category (e.g. Product=ilikeyacut)
+	ilikeyacutConfig.CostCategory = os.Getenv("ILIKEYACUT_COST_CATEGORY")
+
+	// Or with a cost allocation tag on the app's resources (e.g. app=ilikeyacut)
+	ilikeyacutConfig.CostTag = os.Getenv("ILIKEYACUT_COST_TAG")
+
+	// Services shown individually in the cost breakdown; the rest are grouped as "Other"
+	if trackedServices := os.Getenv("ILIKEYACUT_TRACKED_SERVICES"); trackedServices != "" {
+		ilikeyacutConfig.TrackedServices = strings.Split(trackedServices, ",")
+	}
+
+	// Regions aggregated into the app's multi-region cost total (e.g. us-east-1,us-west-2)
+	if costRegions := os.Getenv("ILIKEYACUT_COST_REGIONS"); costRegions != "" {
+		ilikeyacutConfig.CostRegions = strings.Split(costRegions, ",")
+	}
+
+	// Health signals scored for the app, optionally weighted (e.g. lambda_errors=2,api_latency)
+	if healthSignals := os.Getenv("ILIKEYACUT_HEALTH_SIGNALS"); healthSignals != "" {
+		ilikeyacutConfig.HealthSignals = ParseHealthSignals(healthSignals)
+	}
+
 	c.Apps["ilikeyacut"] = ilikeyacutConfig
 
 	// Add more apps as needed
@@ -91,6 +127,61 @@ func (c *AppsConfiguration) GetLambdaFunctions(appID string) []string {
 	return []string{}
 }
 
+// GetLambdaTag returns the tag key and value used to discover an app's Lambda functions
+func (c *AppsConfiguration) GetLambdaTag(appID string) (string, string, bool) {
+	app := c.GetAppConfig(appID)
+	if app == nil || app.LambdaTag == "" {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(app.LambdaTag, "=")
+	if !ok || key == "" || value == "" {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// GetCostCategory returns the Cost Category name and value used to isolate an app's spend
+func (c *AppsConfiguration) GetCostCategory(appID string) (string, string, bool) {
+	app := c.GetAppConfig(appID)
+	if app == nil || app.CostCategory == "" {
+		return "", "", false
+	}
+	name, value, ok := strings.Cut(app.CostCategory, "=")
+	if !ok || name == "" || value == "" {
+		return "", "", false
+	}
+	return name, value, true
+}
+
+// GetCostTag returns the cost allocation tag key and value used to isolate an app's spend
+func (c *AppsConfiguration) GetCostTag(appID string) (string, string, bool) {
+	app := c.GetAppConfig(appID)
+	if app == nil || app.CostTag == "" {
+		return "", "", false
+	}
+	key, value, ok := strings.Cut(app.CostTag, "=")
+	if !ok || key == "" || value == "" {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// GetTrackedServices returns the AWS services broken out individually in an app's cost breakdown
+func (c *AppsConfiguration) GetTrackedServices(appID string) []string {
+	if app := c.GetAppConfig(appID); app != nil {
+		return app.TrackedServices
+	}
+	return []string{}
+}
+
+// GetCostRegions returns the regions an app's spend is aggregated across, or none for all regions
+func (c *AppsConfiguration) GetCostRegions(appID string) []string {
+	if app := c.GetAppConfig(appID); app != nil {
+		return app.CostRegions
+	}
+	return []string{}
+}
+
 // GetAPIGateway returns the API Gateway name for an app
 func (c *AppsConfiguration) GetAPIGateway(appID string) string {
 	if app := c.GetAppConfig(appID); app != nil {
@@ -107,6 +198,14 @@ func (c *AppsConfiguration) GetDynamoDBTables(appID string) []string {
 	return []string{}
 }
 
+// GetHealthSignals returns the health signals scored for an app and their weights
+func (c *AppsConfiguration) GetHealthSignals(appID string) HealthSignalWeights {
+	if app := c.GetAppConfig(appID); app != nil {
+		return app.HealthSignals
+	}
+	return nil
+}
+
 // GetAppStoreID returns the App Store ID for an app
 func (c *AppsConfiguration) GetAppStoreID(appID string) string {
 	if app := c.GetAppConfig(appID); app != nil {
@@ -121,4 +220,4 @@ func getEnvOrDefault(key, defaultValue string) string {
 		return value
 	}
 	return defaultValue
-}
\ No newline at end of file
+}
@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestGetCostTag(t *testing.T) {
+	config := &AppsConfiguration{Apps: map[string]*AppConfig{
+		"tagged":    {ID: "tagged", CostTag: "app=ilikeyacut"},
+		"untagged":  {ID: "untagged"},
+		"no value":  {ID: "no value", CostTag: "app="},
+		"no key":    {ID: "no key", CostTag: "=ilikeyacut"},
+		"malformed": {ID: "malformed", CostTag: "ilikeyacut"},
+	}}
+
+	tests := []struct {
+		appID     string
+		wantKey   string
+		wantValue string
+		wantOK    bool
+	}{
+		{appID: "tagged", wantKey: "app", wantValue: "ilikeyacut", wantOK: true},
+		{appID: "untagged"},
+		{appID: "no value"},
+		{appID: "no key"},
+		{appID: "malformed"},
+		{appID: "unknown"},
+	}
+
+	for _, tt := range tests {
+		key, value, ok := config.GetCostTag(tt.appID)
+		if key != tt.wantKey || value != tt.wantValue || ok != tt.wantOK {
+			t.Errorf("GetCostTag(%q) = %q, %q, %v, want %q, %q, %v", tt.appID, key, value, ok, tt.wantKey, tt.wantValue, tt.wantOK)
+		}
+	}
+}
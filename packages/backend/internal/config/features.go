@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// FeatureFlags controls which data-source subsystems are enabled
+type FeatureFlags struct {
+	Lambda   bool `json:"lambda"`
+	DynamoDB bool `json:"dynamodb"`
+	Cost     bool `json:"cost"`
+	AppStore bool `json:"appStore"`
+}
+
+// LoadFeatureFlags loads subsystem flags from environment variables.
+// Every subsystem is enabled unless its ENABLE_* variable is set to false.
+func LoadFeatureFlags() FeatureFlags {
+	return FeatureFlags{
+		Lambda:   getBoolEnvOrDefault("ENABLE_LAMBDA", true),
+		DynamoDB: getBoolEnvOrDefault("ENABLE_DYNAMODB", true),
+		Cost:     getBoolEnvOrDefault("ENABLE_COST", true),
+		AppStore: getBoolEnvOrDefault("ENABLE_APPSTORE", true),
+	}
+}
+
+// AllFeaturesEnabled returns flags with every subsystem enabled
+func AllFeaturesEnabled() FeatureFlags {
+	return FeatureFlags{
+		Lambda:   true,
+		DynamoDB: true,
+		Cost:     true,
+		AppStore: true,
+	}
+}
+
+// Helper function to get a boolean environment variable with default
+func getBoolEnvOrDefault(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
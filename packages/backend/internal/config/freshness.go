@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultFreshnessWindow applies to metrics without a configured window
+const defaultFreshnessWindow = 15 * time.Minute
+
+// FreshnessWindows holds how far behind each metric source may lag before it is reported as stale
+type FreshnessWindows map[string]time.Duration
+
+// LoadFreshnessWindows loads per-metric freshness windows, overridable with FRESHNESS_WINDOW_<METRIC>
+// (e.g. FRESHNESS_WINDOW_LAMBDA=5m). CloudWatch publishes with a few minutes of delay and
+// Cost Explorer updates roughly daily, so the defaults differ per source.
+func LoadFreshnessWindows() FreshnessWindows {
+	windows := FreshnessWindows{
+		"lambda":     10 * time.Minute,
+		"apigateway": 10 * time.Minute,
+		"dynamodb":   15 * time.Minute,
+		"cost":       48 * time.Hour,
+	}
+
+	for metric := range windows {
+		value := os.Getenv("FRESHNESS_WINDOW_" + strings.ToUpper(metric))
+		if value == "" {
+			continue
+		}
+		if window, err := time.ParseDuration(value); err == nil && window > 0 {
+			windows[metric] = window
+		}
+	}
+
+	return windows
+}
+
+// Window returns the freshness window for a metric source
+func (w FreshnessWindows) Window(metric string) time.Duration {
+	if window, ok := w[metric]; ok {
+		return window
+	}
+	return defaultFreshnessWindow
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadFreshnessWindows(t *testing.T) {
+	t.Setenv("FRESHNESS_WINDOW_LAMBDA", "5m")
+	t.Setenv("FRESHNESS_WINDOW_COST", "soon")
+	t.Setenv("FRESHNESS_WINDOW_DYNAMODB", "-1m")
+
+	windows := LoadFreshnessWindows()
+
+	tests := []struct {
+		metric string
+		want   time.Duration
+	}{
+		{metric: "lambda", want: 5 * time.Minute},
+		{metric: "apigateway", want: 10 * time.Minute},
+		{metric: "dynamodb", want: 15 * time.Minute},
+		{metric: "cost", want: 48 * time.Hour},
+		{metric: "appstore", want: defaultFreshnessWindow},
+	}
+	for _, tt := range tests {
+		if got := windows.Window(tt.metric); got != tt.want {
+			t.Errorf("Window(%q) = %s, want %s", tt.metric, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Health signals evaluated when scoring an app's health
+const (
+	HealthSignalLambdaErrors         = "lambda_errors"
+	HealthSignalLambdaThrottles      = "lambda_throttles"
+	HealthSignalAPIErrors            = "api_errors"
+	HealthSignalAPILatency           = "api_latency"
+	HealthSignalDynamoDBThrottles    = "dynamodb_throttles"
+	HealthSignalDynamoDBSystemErrors = "dynamodb_system_errors"
+	HealthSignalAppCrashes           = "app_crashes"
+)
+
+// healthSignals lists every known health signal
+var healthSignals = []string{
+	HealthSignalLambdaErrors,
+	HealthSignalLambdaThrottles,
+	HealthSignalAPIErrors,
+	HealthSignalAPILatency,
+	HealthSignalDynamoDBThrottles,
+	HealthSignalDynamoDBSystemErrors,
+	HealthSignalAppCrashes,
+}
+
+// HealthSignalWeights maps health signals to the weight they carry in an app's health score.
+// A nil map evaluates every signal with weight 1; otherwise signals missing from the map are
+// not evaluated.
+type HealthSignalWeights map[string]float64
+
+// Weight returns the weight of signal, or 0 when it is disabled
+func (w HealthSignalWeights) Weight(signal string) float64 {
+	if w == nil {
+		return 1
+	}
+	return w[signal]
+}
+
+// ParseHealthSignals parses a comma-separated list of signals, each optionally followed by
+// "=weight" (e.g. "lambda_errors=2,api_latency"). A signal without a weight gets weight 1 and
+// a weight of 0 disables it. Unknown signals and invalid weights are ignored; nil is returned
+// when value lists no valid signal.
+func ParseHealthSignals(value string) HealthSignalWeights {
+	var weights HealthSignalWeights
+	for _, entry := range strings.Split(value, ",") {
+		name, weightValue, hasWeight := strings.Cut(strings.TrimSpace(entry), "=")
+		name = strings.TrimSpace(name)
+		if !isHealthSignal(name) {
+			continue
+		}
+
+		weight := float64(1)
+		if hasWeight {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(weightValue), 64)
+			if err != nil || parsed < 0 {
+				continue
+			}
+			weight = parsed
+		}
+
+		if weights == nil {
+			weights = make(HealthSignalWeights)
+		}
+		weights[name] = weight
+	}
+	return weights
+}
+
+// isHealthSignal reports whether name is a known health signal
+func isHealthSignal(name string) bool {
+	for _, signal := range healthSignals {
+		if signal == name {
+			return true
+		}
+	}
+	return false
+}
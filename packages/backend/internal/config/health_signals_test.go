@@ -0,0 +1,48 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHealthSignals(t *testing.T) {
+	tests := []struct {
+		value string
+		want  HealthSignalWeights
+	}{
+		{value: "lambda_errors,api_latency", want: HealthSignalWeights{"lambda_errors": 1, "api_latency": 1}},
+		{value: " lambda_errors = 2 , dynamodb_throttles=0.5", want: HealthSignalWeights{"lambda_errors": 2, "dynamodb_throttles": 0.5}},
+		{value: "app_crashes=0,api_errors", want: HealthSignalWeights{"app_crashes": 0, "api_errors": 1}},
+		{value: "lambda_errors,cpu_usage,api_errors=-1,api_latency=high", want: HealthSignalWeights{"lambda_errors": 1}},
+		{value: "cpu_usage", want: nil},
+		{value: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		if got := ParseHealthSignals(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseHealthSignals(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestHealthSignalWeight(t *testing.T) {
+	var all HealthSignalWeights
+	if got := all.Weight(HealthSignalAPILatency); got != 1 {
+		t.Errorf("unconfigured weight = %v, want every signal at 1", got)
+	}
+
+	some := ParseHealthSignals("lambda_errors=3,api_latency=0")
+	tests := []struct {
+		signal string
+		want   float64
+	}{
+		{HealthSignalLambdaErrors, 3},
+		{HealthSignalAPILatency, 0},
+		{HealthSignalDynamoDBThrottles, 0},
+	}
+	for _, tt := range tests {
+		if got := some.Weight(tt.signal); got != tt.want {
+			t.Errorf("Weight(%q) = %v, want %v", tt.signal, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// SLO is the availability objective release decisions are measured against. Burn rate is how
+// fast the error budget is being spent relative to spending it evenly over Window; a rate of 1
+// exhausts the budget exactly at the end of the window.
+type SLO struct {
+	Target          float64       // availability percentage, e.g. 99.9
+	Window          time.Duration // period the error budget covers
+	BurnRateWindow  time.Duration // recent period the burn rate is measured over
+	CautionBurnRate float64       // burn rate at which releases need care
+	FreezeBurnRate  float64       // burn rate at which releases should stop
+	CautionBudget   float64       // remaining budget fraction below which releases need care
+}
+
+// LoadSLO loads the SLO from SLO_TARGET, SLO_WINDOW and SLO_BURN_RATE_WINDOW. The freeze burn
+// rate of 14.4 spends 2% of a 30-day budget in an hour, the usual fast-burn paging threshold.
+func LoadSLO() SLO {
+	slo := SLO{
+		Target:          99.9,
+		Window:          30 * 24 * time.Hour,
+		BurnRateWindow:  time.Hour,
+		CautionBurnRate: 1,
+		FreezeBurnRate:  14.4,
+		CautionBudget:   0.25,
+	}
+
+	if value := os.Getenv("SLO_TARGET"); value != "" {
+		if target, err := strconv.ParseFloat(value, 64); err == nil && target > 0 && target < 100 {
+			slo.Target = target
+		}
+	}
+	if value := os.Getenv("SLO_WINDOW"); value != "" {
+		if window, err := time.ParseDuration(value); err == nil && window > 0 {
+			slo.Window = window
+		}
+	}
+	if value := os.Getenv("SLO_BURN_RATE_WINDOW"); value != "" {
+		if window, err := time.ParseDuration(value); err == nil && window > 0 {
+			slo.BurnRateWindow = window
+		}
+	}
+
+	return slo
+}
+
+// ErrorBudget returns the fraction of requests allowed to fail
+func (s SLO) ErrorBudget() float64 {
+	return 1 - s.Target/100
+}
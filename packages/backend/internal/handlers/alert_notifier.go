@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAlertRenotifyInterval is how often a service that stays unhealthy is notified again
+	DefaultAlertRenotifyInterval = time.Hour
+
+	// webhookTimeout bounds each webhook delivery
+	webhookTimeout = 10 * time.Second
+
+	// alertNotifyTimeout bounds the deliveries made for a request's health evaluation
+	alertNotifyTimeout = 30 * time.Second
+)
+
+// AlertNotification reports a change in a service's health, or a reminder that it is still
+// unhealthy. Text makes the payload a valid Slack incoming webhook message.
+type AlertNotification struct {
+	Text           string  `json:"text"`
+	AppID          string  `json:"appId"`
+	Service        string  `json:"service"`
+	Status         string  `json:"status"`
+	PreviousStatus string  `json:"previousStatus"`
+	Alerts         []Alert `json:"alerts"`
+	Timestamp      int64   `json:"timestamp"`
+
+	observedAt         time.Time // When the tracker recorded the status it reports
+	previousNotifiedAt time.Time // When the service was notified before, restored if delivery fails
+}
+
+// Notifier delivers alert notifications
+type Notifier interface {
+	Notify(ctx context.Context, notification AlertNotification) error
+}
+
+// noopNotifier discards notifications, for when no webhook or SNS topic is configured
+type noopNotifier struct{}
+
+func (noopNotifier) Notify(context.Context, AlertNotification) error {
+	return nil
+}
+
+// WebhookNotifier POSTs notifications as JSON to a webhook URL, such as a Slack incoming webhook
+type WebhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookNotifier creates a notifier posting to url
+func NewWebhookNotifier(url string) *WebhookNotifier {
+	return &WebhookNotifier{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Notify posts notification to the webhook, failing on any non-2xx response
+func (n *WebhookNotifier) Notify(ctx context.Context, notification AlertNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post alert notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// snsAlertMessage is the JSON body of an alert published to SNS. Metric, Value and Threshold
+// are those of the notification's most severe alert, and are omitted once a service recovers.
+type snsAlertMessage struct {
+	AppID            string   `json:"appId"`
+	Service          string   `json:"service"`
+	Severity         string   `json:"severity"`
+	PreviousSeverity string   `json:"previousSeverity"`
+	Metric           string   `json:"metric,omitempty"`
+	Value            *float64 `json:"value,omitempty"`
+	Threshold        *float64 `json:"threshold,omitempty"`
+	Message          string   `json:"message"`
+	Alerts           []Alert  `json:"alerts"`
+	Timestamp        int64    `json:"timestamp"`
+}
+
+// Publisher publishes a message with a subject and string attributes, as aws.SNSPublisher
+// does to an SNS topic
+type Publisher interface {
+	Publish(ctx context.Context, subject, message string, attributes map[string]string) error
+}
+
+// SNSNotifier publishes notifications as JSON to an SNS topic, with appId, service and
+// severity message attributes for subscription filter policies
+type SNSNotifier struct {
+	publisher Publisher
+}
+
+// NewSNSNotifier creates a notifier publishing through publisher
+func NewSNSNotifier(publisher Publisher) *SNSNotifier {
+	return &SNSNotifier{publisher: publisher}
+}
+
+// Notify publishes notification to the topic. The subject is the first line of its text.
+func (n *SNSNotifier) Notify(ctx context.Context, notification AlertNotification) error {
+	message := snsAlertMessage{
+		AppID:            notification.AppID,
+		Service:          notification.Service,
+		Severity:         notification.Status,
+		PreviousSeverity: notification.PreviousStatus,
+		Message:          notification.Text,
+		Alerts:           notification.Alerts,
+		Timestamp:        notification.Timestamp,
+	}
+	if worst, ok := mostSevereAlert(notification.Alerts); ok {
+		message.Metric = worst.Metric
+		message.Value = &worst.Value
+		message.Threshold = &worst.Threshold
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert notification: %w", err)
+	}
+
+	subject, _, _ := strings.Cut(notification.Text, "\n")
+	return n.publisher.Publish(ctx, subject, string(body), map[string]string{
+		"appId":    notification.AppID,
+		"service":  notification.Service,
+		"severity": notification.Status,
+	})
+}
+
+// mostSevereAlert returns the first critical alert, or else the first alert
+func mostSevereAlert(alerts []Alert) (Alert, bool) {
+	if len(alerts) == 0 {
+		return Alert{}, false
+	}
+	for _, alert := range alerts {
+		if alert.Severity == AlertSeverityCritical {
+			return alert, true
+		}
+	}
+	return alerts[0], true
+}
+
+// alertStateKey identifies a service of an app whose health is tracked
+type alertStateKey struct {
+	appID   string
+	service string
+}
+
+// alertState is a service's last seen health and when it was last notified
+type alertState struct {
+	status     string
+	notifiedAt time.Time
+}
+
+// AlertTracker remembers each service's last seen health and decides which evaluations are
+// worth notifying: a change of status, or a service still unhealthy a renotify interval after
+// it was last notified. Services first seen healthy aren't notified. A notification that can't
+// be delivered is undone, so the next evaluation notifies it again. A nil tracker notifies
+// nothing.
+type AlertTracker struct {
+	notifier Notifier
+	renotify time.Duration // zero never re-notifies
+	logger   *slog.Logger
+	now      func() time.Time
+
+	mu     sync.Mutex
+	states map[alertStateKey]*alertState
+}
+
+// NewAlertTracker creates a tracker delivering through notifier, re-notifying unhealthy services
+// every renotify interval. A nil notifier discards notifications.
+func NewAlertTracker(notifier Notifier, renotify time.Duration, logger *slog.Logger) *AlertTracker {
+	if notifier == nil {
+		notifier = noopNotifier{}
+	}
+	return &AlertTracker{
+		notifier: notifier,
+		renotify: renotify,
+		logger:   logger,
+		now:      time.Now,
+		states:   make(map[alertStateKey]*alertState),
+	}
+}
+
+// observe records the health of every service metrics covers and returns the notifications
+// due. Services whose metrics couldn't be fetched keep their previous state.
+func (t *AlertTracker) observe(appID string, metrics HealthMetrics, alerts []Alert) []AlertNotification {
+	if t == nil {
+		return nil
+	}
+
+	byResource := alertsByResource(alerts)
+	var services []string
+	for functionName, m := range metrics.Lambda {
+		if m != nil {
+			services = append(services, "lambda:"+functionName)
+		}
+	}
+	if metrics.APIGateway != nil {
+		services = append(services, "apigateway:"+metrics.APIName)
+	}
+	for tableName, m := range metrics.DynamoDB {
+		if m != nil {
+			services = append(services, "dynamodb:"+tableName)
+		}
+	}
+	sort.Strings(services)
+
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var notifications []AlertNotification
+	for _, service := range services {
+		serviceAlerts := byResource[service]
+		status := healthStatus(serviceAlerts)
+
+		key := alertStateKey{appID: appID, service: service}
+		state, seen := t.states[key]
+		if !seen {
+			state = &alertState{status: "healthy"}
+			t.states[key] = state
+		}
+
+		previous := state.status
+		changed := status != previous
+		reminder := !changed && status != "healthy" && t.renotify > 0 && now.Sub(state.notifiedAt) >= t.renotify
+		if !changed && !reminder {
+			continue
+		}
+
+		// Recorded as notified now so concurrent evaluations don't notify it twice; send undoes
+		// it if delivery fails
+		previousNotifiedAt := state.notifiedAt
+		state.status = status
+		state.notifiedAt = now
+		if serviceAlerts == nil {
+			serviceAlerts = []Alert{}
+		}
+		notifications = append(notifications, AlertNotification{
+			Text:               alertNotificationText(appID, service, status, previous, serviceAlerts),
+			AppID:              appID,
+			Service:            service,
+			Status:             status,
+			PreviousStatus:     previous,
+			Alerts:             serviceAlerts,
+			Timestamp:          now.Unix(),
+			observedAt:         now,
+			previousNotifiedAt: previousNotifiedAt,
+		})
+	}
+	return notifications
+}
+
+// send delivers notifications, returning every delivery failure. Failed notifications are
+// undone so the next evaluation sends them again.
+func (t *AlertTracker) send(ctx context.Context, notifications []AlertNotification) error {
+	var errs []error
+	for _, notification := range notifications {
+		if err := t.notifier.Notify(ctx, notification); err != nil {
+			t.undo(notification)
+			errs = append(errs, fmt.Errorf("%s %s: %w", notification.AppID, notification.Service, err))
+			continue
+		}
+		t.logger.Info("Sent alert notification", "appId", notification.AppID, "service", notification.Service, "status", notification.Status)
+	}
+	return errors.Join(errs...)
+}
+
+// undo restores the state notification's service had before it was observed, unless a later
+// evaluation has recorded a newer one
+func (t *AlertTracker) undo(notification AlertNotification) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.states[alertStateKey{appID: notification.AppID, service: notification.Service}]
+	if !ok || state.status != notification.Status || !state.notifiedAt.Equal(notification.observedAt) {
+		return
+	}
+	state.status = notification.PreviousStatus
+	state.notifiedAt = notification.previousNotifiedAt
+}
+
+// alertNotificationText summarizes a notification in one line per alert
+func alertNotificationText(appID, service, status, previous string, alerts []Alert) string {
+	var text strings.Builder
+	switch {
+	case status == "healthy":
+		fmt.Fprintf(&text, "Resolved: %s %s is healthy again (was %s)", appID, service, previous)
+	case status == previous:
+		fmt.Fprintf(&text, "Still %s: %s %s", status, appID, service)
+	default:
+		fmt.Fprintf(&text, "%s: %s %s (was %s)", strings.ToUpper(status), appID, service, previous)
+	}
+	for _, alert := range alerts {
+		fmt.Fprintf(&text, "\n• %s (%s > %g)", alert.Message, alert.Metric, alert.Threshold)
+	}
+	return text.String()
+}
+
+// evaluateHealth evaluates metrics against the alert rules and notifies in the background of
+// any change in the app's service health
+func (h *AppHandler) evaluateHealth(appID string, metrics HealthMetrics) []Alert {
+	alerts := EvaluateHealth(metrics, h.AlertRules)
+	if notifications := h.AlertTracker.observe(appID, metrics, alerts); len(notifications) > 0 {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), alertNotifyTimeout)
+			defer cancel()
+			if err := h.AlertTracker.send(ctx, notifications); err != nil {
+				h.Logger.Warn("Failed to send alert notifications", "appId", appID, "error", err)
+			}
+		}()
+	}
+	return alerts
+}
+
+// CheckAlerts evaluates every configured app's health and sends the notifications due, so
+// alerts fire even when nobody is polling the health endpoints
+func (h *AppHandler) CheckAlerts(ctx context.Context) error {
+	var errs []error
+	for _, app := range h.AppsConfig.GetAllApps() {
+		metrics := h.fetchHealthMetrics(ctx, app.ID, true, true, true, newPartialResult())
+		alerts := EvaluateHealth(metrics, h.AlertRules)
+		if err := h.AlertTracker.send(ctx, h.AlertTracker.observe(app.ID, metrics, alerts)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// fakePublisher records what it's asked to publish
+type fakePublisher struct {
+	subject    string
+	message    string
+	attributes map[string]string
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, subject, message string, attributes map[string]string) error {
+	p.subject = subject
+	p.message = message
+	p.attributes = attributes
+	return nil
+}
+
+func TestSNSNotifierPublishes(t *testing.T) {
+	alerts := []Alert{
+		{Severity: AlertSeverityDegraded, Service: "lambda", Resource: "lambda:api", Metric: "throttles", Threshold: 0, Value: 3, Message: "Lambda api is being throttled: 3 throttles"},
+		{Severity: AlertSeverityCritical, Service: "lambda", Resource: "lambda:api", Metric: "errorRate", Threshold: 25, Value: 40, Message: "Lambda api has high error rate: 40.00%"},
+	}
+	notification := AlertNotification{
+		Text:           alertNotificationText("app", "lambda:api", AlertSeverityCritical, "healthy", alerts),
+		AppID:          "app",
+		Service:        "lambda:api",
+		Status:         AlertSeverityCritical,
+		PreviousStatus: "healthy",
+		Alerts:         alerts,
+		Timestamp:      1700000000,
+	}
+
+	publisher := &fakePublisher{}
+	if err := NewSNSNotifier(publisher).Notify(context.Background(), notification); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	if want := "CRITICAL: app lambda:api (was healthy)"; publisher.subject != want {
+		t.Errorf("subject = %q, want %q", publisher.subject, want)
+	}
+	wantAttributes := map[string]string{"appId": "app", "service": "lambda:api", "severity": AlertSeverityCritical}
+	if len(publisher.attributes) != len(wantAttributes) {
+		t.Errorf("attributes = %v, want %v", publisher.attributes, wantAttributes)
+	}
+	for name, want := range wantAttributes {
+		if got := publisher.attributes[name]; got != want {
+			t.Errorf("attribute %s = %q, want %q", name, got, want)
+		}
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(publisher.message), &body); err != nil {
+		t.Fatalf("message isn't JSON: %v", err)
+	}
+	// Metric, value and threshold are those of the critical alert
+	wantBody := map[string]interface{}{
+		"appId":            "app",
+		"service":          "lambda:api",
+		"severity":         AlertSeverityCritical,
+		"previousSeverity": "healthy",
+		"metric":           "errorRate",
+		"value":            float64(40),
+		"threshold":        float64(25),
+		"timestamp":        float64(1700000000),
+	}
+	for field, want := range wantBody {
+		if got := body[field]; got != want {
+			t.Errorf("body %s = %v, want %v", field, got, want)
+		}
+	}
+	if got, ok := body["alerts"].([]interface{}); !ok || len(got) != len(alerts) {
+		t.Errorf("body alerts = %v, want %d alerts", body["alerts"], len(alerts))
+	}
+}
+
+func TestSNSNotifierPublishesRecovery(t *testing.T) {
+	publisher := &fakePublisher{}
+	notification := AlertNotification{
+		Text:           alertNotificationText("app", "dynamodb:users", "healthy", AlertSeverityDegraded, nil),
+		AppID:          "app",
+		Service:        "dynamodb:users",
+		Status:         "healthy",
+		PreviousStatus: AlertSeverityDegraded,
+		Alerts:         []Alert{},
+		Timestamp:      1700000000,
+	}
+	if err := NewSNSNotifier(publisher).Notify(context.Background(), notification); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(publisher.message), &body); err != nil {
+		t.Fatalf("message isn't JSON: %v", err)
+	}
+	if body["severity"] != "healthy" || body["previousSeverity"] != AlertSeverityDegraded {
+		t.Errorf("body severities = %v, %v; want healthy, %s", body["severity"], body["previousSeverity"], AlertSeverityDegraded)
+	}
+	// A recovered service has no breached metric to report
+	for _, field := range []string{"metric", "value", "threshold"} {
+		if _, ok := body[field]; ok {
+			t.Errorf("body has %s for a recovered service", field)
+		}
+	}
+	if publisher.attributes["severity"] != "healthy" {
+		t.Errorf("severity attribute = %q, want healthy", publisher.attributes["severity"])
+	}
+}
+
+// capturedWebhook is a webhook server recording the notifications posted to it
+type capturedWebhook struct {
+	server        *httptest.Server
+	status        int
+	notifications []AlertNotification
+}
+
+func newCapturedWebhook(t *testing.T) *capturedWebhook {
+	t.Helper()
+	webhook := &capturedWebhook{status: http.StatusOK}
+	webhook.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("webhook got %s with Content-Type %q, want a JSON POST", r.Method, r.Header.Get("Content-Type"))
+		}
+		var notification AlertNotification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("webhook body isn't a notification: %v", err)
+		}
+		webhook.notifications = append(webhook.notifications, notification)
+		w.WriteHeader(webhook.status)
+	}))
+	t.Cleanup(webhook.server.Close)
+	return webhook
+}
+
+// lambdaHealth is the health metrics of a single Lambda function
+func lambdaHealth(functionName string) HealthMetrics {
+	return HealthMetrics{Lambda: map[string]*aws.LambdaMetrics{functionName: {FunctionName: functionName}}}
+}
+
+func lambdaAlert(functionName, severity string) Alert {
+	return Alert{Severity: severity, Service: "lambda", Resource: "lambda:" + functionName, Metric: "errorRate", Threshold: 5, Value: 10, Message: "Lambda " + functionName + " has high error rate: 10.00%"}
+}
+
+func newTestTracker(notifier Notifier, renotify time.Duration, now *time.Time) *AlertTracker {
+	tracker := NewAlertTracker(notifier, renotify, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	tracker.now = func() time.Time { return *now }
+	return tracker
+}
+
+func TestWebhookNotifierPostsNotification(t *testing.T) {
+	webhook := newCapturedWebhook(t)
+	notification := AlertNotification{
+		Text:           "DEGRADED: app lambda:api (was healthy)",
+		AppID:          "app",
+		Service:        "lambda:api",
+		Status:         AlertSeverityDegraded,
+		PreviousStatus: "healthy",
+		Alerts:         []Alert{lambdaAlert("api", AlertSeverityDegraded)},
+		Timestamp:      1700000000,
+	}
+
+	if err := NewWebhookNotifier(webhook.server.URL).Notify(context.Background(), notification); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(webhook.notifications) != 1 {
+		t.Fatalf("webhook got %d notifications, want 1", len(webhook.notifications))
+	}
+	got := webhook.notifications[0]
+	if got.Text != notification.Text || got.AppID != "app" || got.Service != "lambda:api" || got.Status != AlertSeverityDegraded ||
+		got.PreviousStatus != "healthy" || got.Timestamp != notification.Timestamp || len(got.Alerts) != 1 || got.Alerts[0].Metric != "errorRate" {
+		t.Errorf("webhook got %+v, want %+v", got, notification)
+	}
+
+	webhook.status = http.StatusInternalServerError
+	if err := NewWebhookNotifier(webhook.server.URL).Notify(context.Background(), notification); err == nil {
+		t.Error("Notify succeeded on a 500 response")
+	}
+}
+
+func TestAlertTrackerNotifiesChanges(t *testing.T) {
+	webhook := newCapturedWebhook(t)
+	now := time.Unix(1700000000, 0)
+	tracker := newTestTracker(NewWebhookNotifier(webhook.server.URL), 0, &now)
+	ctx := context.Background()
+	metrics := lambdaHealth("api")
+
+	steps := []struct {
+		alerts       []Alert
+		wantStatus   string // Status notified, empty for none
+		wantPrevious string
+	}{
+		{alerts: nil},
+		{alerts: []Alert{lambdaAlert("api", AlertSeverityDegraded)}, wantStatus: AlertSeverityDegraded, wantPrevious: "healthy"},
+		{alerts: []Alert{lambdaAlert("api", AlertSeverityDegraded)}},
+		{alerts: []Alert{lambdaAlert("api", AlertSeverityCritical)}, wantStatus: AlertSeverityCritical, wantPrevious: AlertSeverityDegraded},
+		{alerts: nil, wantStatus: "healthy", wantPrevious: AlertSeverityCritical},
+		{alerts: nil},
+	}
+	for i, step := range steps {
+		before := len(webhook.notifications)
+		if err := tracker.send(ctx, tracker.observe("app", metrics, step.alerts)); err != nil {
+			t.Fatalf("step %d: send: %v", i, err)
+		}
+		sent := webhook.notifications[before:]
+
+		if step.wantStatus == "" {
+			if len(sent) != 0 {
+				t.Errorf("step %d: notified %+v, want nothing", i, sent)
+			}
+			continue
+		}
+		if len(sent) != 1 {
+			t.Fatalf("step %d: sent %d notifications, want 1", i, len(sent))
+		}
+		if sent[0].Status != step.wantStatus || sent[0].PreviousStatus != step.wantPrevious || sent[0].Service != "lambda:api" {
+			t.Errorf("step %d: notified %s (was %s) for %s, want %s (was %s) for lambda:api",
+				i, sent[0].Status, sent[0].PreviousStatus, sent[0].Service, step.wantStatus, step.wantPrevious)
+		}
+	}
+}
+
+func TestAlertTrackerRenotifies(t *testing.T) {
+	webhook := newCapturedWebhook(t)
+	now := time.Unix(1700000000, 0)
+	tracker := newTestTracker(NewWebhookNotifier(webhook.server.URL), time.Hour, &now)
+	ctx := context.Background()
+	alerts := []Alert{lambdaAlert("api", AlertSeverityCritical)}
+
+	tracker.send(ctx, tracker.observe("app", lambdaHealth("api"), alerts))
+
+	now = now.Add(30 * time.Minute)
+	tracker.send(ctx, tracker.observe("app", lambdaHealth("api"), alerts))
+	if len(webhook.notifications) != 1 {
+		t.Fatalf("sent %d notifications before the renotify interval, want 1", len(webhook.notifications))
+	}
+
+	now = now.Add(30 * time.Minute)
+	tracker.send(ctx, tracker.observe("app", lambdaHealth("api"), alerts))
+	if len(webhook.notifications) != 2 {
+		t.Fatalf("sent %d notifications after the renotify interval, want 2", len(webhook.notifications))
+	}
+	if reminder := webhook.notifications[1]; reminder.Status != AlertSeverityCritical || reminder.PreviousStatus != AlertSeverityCritical {
+		t.Errorf("reminder was %s (was %s), want critical (was critical)", reminder.Status, reminder.PreviousStatus)
+	}
+}
+
+func TestAlertTrackerRetriesFailedDelivery(t *testing.T) {
+	webhook := newCapturedWebhook(t)
+	webhook.status = http.StatusServiceUnavailable
+	now := time.Unix(1700000000, 0)
+	tracker := newTestTracker(NewWebhookNotifier(webhook.server.URL), 0, &now)
+	ctx := context.Background()
+	alerts := []Alert{lambdaAlert("api", AlertSeverityDegraded)}
+
+	if err := tracker.send(ctx, tracker.observe("app", lambdaHealth("api"), alerts)); err == nil {
+		t.Fatal("send succeeded though the webhook failed")
+	}
+
+	// The transition that failed to deliver is notified again once the webhook recovers
+	webhook.status = http.StatusOK
+	now = now.Add(time.Minute)
+	if err := tracker.send(ctx, tracker.observe("app", lambdaHealth("api"), alerts)); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(webhook.notifications) != 2 {
+		t.Fatalf("webhook got %d notifications, want the failed one and its retry", len(webhook.notifications))
+	}
+	if retry := webhook.notifications[1]; retry.Status != AlertSeverityDegraded || retry.PreviousStatus != "healthy" {
+		t.Errorf("retry was %s (was %s), want degraded (was healthy)", retry.Status, retry.PreviousStatus)
+	}
+
+	// Once delivered it isn't repeated
+	tracker.send(ctx, tracker.observe("app", lambdaHealth("api"), alerts))
+	if len(webhook.notifications) != 2 {
+		t.Errorf("webhook got %d notifications after delivery, want 2", len(webhook.notifications))
+	}
+}
+
+func TestAlertTrackerKeepsStateOfUnfetchedServices(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	publisher := &fakePublisher{}
+	tracker := newTestTracker(NewSNSNotifier(publisher), 0, &now)
+	ctx := context.Background()
+
+	tracker.send(ctx, tracker.observe("app", lambdaHealth("api"), []Alert{lambdaAlert("api", AlertSeverityCritical)}))
+
+	// A function whose metrics couldn't be fetched isn't treated as recovered
+	unfetched := HealthMetrics{Lambda: map[string]*aws.LambdaMetrics{"api": nil}}
+	if notifications := tracker.observe("app", unfetched, nil); len(notifications) != 0 {
+		t.Errorf("notified %+v for a function that wasn't fetched", notifications)
+	}
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// Alert severities, from least to most severe. They double as service health statuses.
+const (
+	AlertSeverityDegraded = "degraded"
+	AlertSeverityCritical = "critical"
+)
+
+// healthWindow is how far back health checks and alerts look
+const healthWindow = time.Hour
+
+// Alert is a health threshold a resource is breaching
+type Alert struct {
+	Severity  string  `json:"severity"`
+	Service   string  `json:"service"`
+	Resource  string  `json:"resource"`
+	Metric    string  `json:"metric"`
+	Threshold float64 `json:"threshold"`
+	Value     float64 `json:"value"`
+	Message   string  `json:"message"`
+
+	signal string // Health signal the alert counts against in health scores
+}
+
+// HealthMetrics are the recent observations an app's health is evaluated on. A nil entry is a
+// resource whose metrics couldn't be fetched; it isn't evaluated.
+type HealthMetrics struct {
+	Lambda     map[string]*aws.LambdaMetrics
+	APIName    string
+	APIGateway *aws.APIGatewayMetrics
+	DynamoDB   map[string]*aws.DynamoDBMetrics
+}
+
+// EvaluateHealth checks metrics against rules and returns the alerts firing, grouped by
+// resource in a stable order. Each metric breaching its critical threshold raises a critical
+// alert, and one breaching only its degraded threshold a degraded alert.
+func EvaluateHealth(metrics HealthMetrics, rules config.AlertRules) []Alert {
+	var alerts []Alert
+
+	for _, functionName := range sortedKeys(metrics.Lambda) {
+		m := metrics.Lambda[functionName]
+		if m == nil {
+			continue
+		}
+		errorRate := float64(0)
+		if m.Invocations > 0 {
+			errorRate = (m.Errors / m.Invocations) * 100
+		}
+		resource := "lambda:" + functionName
+		alerts = checkThreshold(alerts, rules.LambdaErrorRate, errorRate, Alert{
+			Service: "lambda", Resource: resource, Metric: "errorRate", signal: config.HealthSignalLambdaErrors,
+			Message: formatIssue("Lambda %s has high error rate: %.2f%%", functionName, errorRate),
+		})
+		alerts = checkThreshold(alerts, rules.LambdaThrottles, m.Throttles, Alert{
+			Service: "lambda", Resource: resource, Metric: "throttles", signal: config.HealthSignalLambdaThrottles,
+			Message: formatIssue("Lambda %s is being throttled: %.0f throttles", functionName, m.Throttles),
+		})
+	}
+
+	if m := metrics.APIGateway; m != nil {
+		errorRate := float64(0)
+		if m.Count > 0 {
+			errorRate = ((m.Error4XX + m.Error5XX) / m.Count) * 100
+		}
+		resource := "apigateway:" + metrics.APIName
+		alerts = checkThreshold(alerts, rules.APIErrorRate, errorRate, Alert{
+			Service: "apigateway", Resource: resource, Metric: "errorRate", signal: config.HealthSignalAPIErrors,
+			Message: formatIssue("API Gateway has high error rate: %.2f%%", errorRate),
+		})
+		alerts = checkThreshold(alerts, rules.APILatencyP99, m.LatencyP99, Alert{
+			Service: "apigateway", Resource: resource, Metric: "latencyP99", signal: config.HealthSignalAPILatency,
+			Message: formatIssue("API Gateway has high p99 latency: %.0fms", m.LatencyP99),
+		})
+	}
+
+	for _, tableName := range sortedKeys(metrics.DynamoDB) {
+		m := metrics.DynamoDB[tableName]
+		if m == nil {
+			continue
+		}
+		resource := "dynamodb:" + tableName
+		alerts = checkThreshold(alerts, rules.DynamoDBThrottles, m.ThrottledRequests, Alert{
+			Service: "dynamodb", Resource: resource, Metric: "throttledRequests", signal: config.HealthSignalDynamoDBThrottles,
+			Message: formatIssue("DynamoDB table %s is being throttled: %.0f throttled requests", tableName, m.ThrottledRequests),
+		})
+		alerts = checkThreshold(alerts, rules.DynamoDBSystemErrors, m.SystemErrors, Alert{
+			Service: "dynamodb", Resource: resource, Metric: "systemErrors", signal: config.HealthSignalDynamoDBSystemErrors,
+			Message: formatIssue("DynamoDB table %s has system errors: %.0f", tableName, m.SystemErrors),
+		})
+	}
+
+	return alerts
+}
+
+// checkThreshold appends alert to alerts, with its severity and the threshold breached, when
+// value exceeds threshold
+func checkThreshold(alerts []Alert, threshold config.AlertThreshold, value float64, alert Alert) []Alert {
+	switch {
+	case value > threshold.Critical:
+		alert.Severity = AlertSeverityCritical
+		alert.Threshold = threshold.Critical
+	case value > threshold.Degraded:
+		alert.Severity = AlertSeverityDegraded
+		alert.Threshold = threshold.Degraded
+	default:
+		return alerts
+	}
+	alert.Value = value
+	return append(alerts, alert)
+}
+
+// healthStatus returns "healthy", or the severity of the most severe alert
+func healthStatus(alerts []Alert) string {
+	status := "healthy"
+	for _, alert := range alerts {
+		if alert.Severity == AlertSeverityCritical {
+			return AlertSeverityCritical
+		}
+		status = AlertSeverityDegraded
+	}
+	return status
+}
+
+// alertsByResource groups alerts by the resource they fired for
+func alertsByResource(alerts []Alert) map[string][]Alert {
+	grouped := make(map[string][]Alert)
+	for _, alert := range alerts {
+		grouped[alert.Resource] = append(grouped[alert.Resource], alert)
+	}
+	return grouped
+}
+
+// fetchHealthMetrics fetches the metrics health is evaluated on for the services requested,
+// over the last healthWindow, recording each resource's outcome
+func (h *AppHandler) fetchHealthMetrics(ctx context.Context, appID string, lambda, apiGateway, dynamoDB bool, outcome *partialResult) HealthMetrics {
+	endTime := time.Now()
+	startTime := endTime.Add(-healthWindow)
+
+	metrics := HealthMetrics{
+		Lambda:   make(map[string]*aws.LambdaMetrics),
+		DynamoDB: make(map[string]*aws.DynamoDBMetrics),
+	}
+
+	if lambda && h.Features.Lambda {
+		for _, functionName := range h.ResolveLambdaFunctions(ctx, appID) {
+			m, err := h.CloudWatch.GetLambdaMetrics(ctx, functionName, startTime, endTime)
+			if err != nil {
+				outcome.failed("lambda:"+functionName, err)
+				metrics.Lambda[functionName] = nil
+				continue
+			}
+			outcome.succeeded()
+			metrics.Lambda[functionName] = m
+		}
+	}
+
+	if apiName := h.AppsConfig.GetAPIGateway(appID); apiGateway && apiName != "" {
+		metrics.APIName = apiName
+		m, err := h.CloudWatch.GetAPIGatewayMetrics(ctx, apiName, startTime, endTime)
+		if err != nil {
+			outcome.failed("apigateway:"+apiName, err)
+		} else {
+			outcome.succeeded()
+			metrics.APIGateway = m
+		}
+	}
+
+	if dynamoDB && h.Features.DynamoDB {
+		for _, tableName := range h.AppsConfig.GetDynamoDBTables(appID) {
+			m, err := h.DynamoDB.GetTableMetrics(ctx, tableName, startTime, endTime)
+			if err != nil {
+				outcome.failed("dynamodb:"+tableName, err)
+				metrics.DynamoDB[tableName] = nil
+				continue
+			}
+			outcome.succeeded()
+			metrics.DynamoDB[tableName] = m
+		}
+	}
+
+	return metrics
+}
+
+// GetAlerts returns the health alerts currently firing for an app: every threshold in the
+// configured alert rules that a resource breached over the last hour
+func (h *AppHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	outcome := newPartialResult()
+	metrics := h.fetchHealthMetrics(r.Context(), appID, true, true, true, outcome)
+	alerts := h.evaluateHealth(appID, metrics)
+	if alerts == nil {
+		alerts = []Alert{}
+	}
+
+	outcome.writeJSON(w, map[string]interface{}{
+		"appId":     appID,
+		"status":    healthStatus(alerts),
+		"alerts":    alerts,
+		"timestamp": time.Now().Unix(),
+	})
+}
+
+// sortedKeys returns m's keys in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// testAlertRules uses the same thresholds for every metric so each can be probed alike
+func testAlertRules() config.AlertRules {
+	threshold := config.AlertThreshold{Degraded: 10, Critical: 50}
+	return config.AlertRules{
+		LambdaErrorRate:      threshold,
+		LambdaThrottles:      threshold,
+		APIErrorRate:         threshold,
+		APILatencyP99:        threshold,
+		DynamoDBThrottles:    threshold,
+		DynamoDBSystemErrors: threshold,
+	}
+}
+
+func TestEvaluateHealthThresholds(t *testing.T) {
+	// Each metric's health metrics when it reads value and everything else is healthy
+	metrics := []struct {
+		metric   string
+		resource string
+		build    func(value float64) HealthMetrics
+	}{
+		{"errorRate", "lambda:api", func(value float64) HealthMetrics {
+			return HealthMetrics{Lambda: map[string]*aws.LambdaMetrics{"api": {Invocations: 1000, Errors: value * 10}}}
+		}},
+		{"throttles", "lambda:api", func(value float64) HealthMetrics {
+			return HealthMetrics{Lambda: map[string]*aws.LambdaMetrics{"api": {Invocations: 1000, Throttles: value}}}
+		}},
+		{"errorRate", "apigateway:rest", func(value float64) HealthMetrics {
+			return HealthMetrics{APIName: "rest", APIGateway: &aws.APIGatewayMetrics{Count: 1000, Error4XX: value * 5, Error5XX: value * 5}}
+		}},
+		{"latencyP99", "apigateway:rest", func(value float64) HealthMetrics {
+			return HealthMetrics{APIName: "rest", APIGateway: &aws.APIGatewayMetrics{Count: 1000, LatencyP99: value}}
+		}},
+		{"throttledRequests", "dynamodb:users", func(value float64) HealthMetrics {
+			return HealthMetrics{DynamoDB: map[string]*aws.DynamoDBMetrics{"users": {ThrottledRequests: value}}}
+		}},
+		{"systemErrors", "dynamodb:users", func(value float64) HealthMetrics {
+			return HealthMetrics{DynamoDB: map[string]*aws.DynamoDBMetrics{"users": {SystemErrors: value}}}
+		}},
+	}
+
+	// Thresholds are exceeded, not met: a value at a threshold doesn't breach it
+	boundaries := []struct {
+		name          string
+		value         float64
+		wantSeverity  string // Empty for healthy
+		wantThreshold float64
+	}{
+		{"below degraded", 9.9, "", 0},
+		{"at degraded", 10, "", 0},
+		{"above degraded", 10.1, AlertSeverityDegraded, 10},
+		{"below critical", 49.9, AlertSeverityDegraded, 10},
+		{"at critical", 50, AlertSeverityDegraded, 10},
+		{"above critical", 50.1, AlertSeverityCritical, 50},
+	}
+
+	rules := testAlertRules()
+	for _, m := range metrics {
+		for _, b := range boundaries {
+			t.Run(m.resource+" "+m.metric+" "+b.name, func(t *testing.T) {
+				alerts := EvaluateHealth(m.build(b.value), rules)
+
+				wantStatus := "healthy"
+				if b.wantSeverity != "" {
+					wantStatus = b.wantSeverity
+				}
+				if got := healthStatus(alerts); got != wantStatus {
+					t.Errorf("status = %s, want %s", got, wantStatus)
+				}
+				if b.wantSeverity == "" {
+					if len(alerts) != 0 {
+						t.Errorf("alerts = %+v, want none", alerts)
+					}
+					return
+				}
+
+				if len(alerts) != 1 {
+					t.Fatalf("got %d alerts, want 1: %+v", len(alerts), alerts)
+				}
+				alert := alerts[0]
+				if alert.Severity != b.wantSeverity || alert.Metric != m.metric || alert.Resource != m.resource || alert.Threshold != b.wantThreshold {
+					t.Errorf("alert = %s %s on %s over %g, want %s %s on %s over %g",
+						alert.Severity, alert.Metric, alert.Resource, alert.Threshold, b.wantSeverity, m.metric, m.resource, b.wantThreshold)
+				}
+				if diff := alert.Value - b.value; diff > 1e-9 || diff < -1e-9 {
+					t.Errorf("value = %g, want %g", alert.Value, b.value)
+				}
+				if alert.Message == "" {
+					t.Error("alert has no message")
+				}
+			})
+		}
+	}
+}
+
+func TestEvaluateHealthSkipsUnfetchedResources(t *testing.T) {
+	metrics := HealthMetrics{
+		Lambda:   map[string]*aws.LambdaMetrics{"api": nil},
+		DynamoDB: map[string]*aws.DynamoDBMetrics{"users": nil},
+	}
+	if alerts := EvaluateHealth(metrics, testAlertRules()); len(alerts) != 0 {
+		t.Errorf("alerts = %+v, want none for resources that weren't fetched", alerts)
+	}
+}
+
+func TestEvaluateHealthOrdersByResource(t *testing.T) {
+	metrics := HealthMetrics{
+		Lambda: map[string]*aws.LambdaMetrics{
+			"worker": {Invocations: 100, Throttles: 60},
+			"api":    {Invocations: 100, Errors: 20},
+		},
+		APIName:    "rest",
+		APIGateway: &aws.APIGatewayMetrics{Count: 100, LatencyP99: 20},
+		DynamoDB:   map[string]*aws.DynamoDBMetrics{"users": {SystemErrors: 20}},
+	}
+
+	alerts := EvaluateHealth(metrics, testAlertRules())
+	want := []string{"lambda:api", "lambda:worker", "apigateway:rest", "dynamodb:users"}
+	if len(alerts) != len(want) {
+		t.Fatalf("got %d alerts, want %d: %+v", len(alerts), len(want), alerts)
+	}
+	for i, resource := range want {
+		if alerts[i].Resource != resource {
+			t.Errorf("alert %d is for %s, want %s", i, alerts[i].Resource, resource)
+		}
+	}
+	if got := healthStatus(alerts); got != AlertSeverityCritical {
+		t.Errorf("status = %s, want critical from the throttled worker", got)
+	}
+}
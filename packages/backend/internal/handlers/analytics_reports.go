@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+)
+
+const (
+	// defaultReportCategory limits status checks to one report category, since every report
+	// checked costs further App Store Connect calls
+	defaultReportCategory = "APP_STORE_ENGAGEMENT"
+
+	// defaultReportGranularity is the instance granularity returned when none is requested
+	defaultReportGranularity = "DAILY"
+)
+
+// RefreshAppStoreReport ensures an analytics report request exists for the app and returns
+// its job ID. Apple generates reports asynchronously; poll GetAppStoreReportJob for results.
+func (h *AppHandler) RefreshAppStoreReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	if h.AppStore == nil {
+		writeError(w, "App Store Connect not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	accessType := appstore.AccessTypeOneTimeSnapshot
+	if r.URL.Query().Get("ongoing") == "true" {
+		accessType = appstore.AccessTypeOngoing
+	}
+
+	job, err := h.AppStore.EnsureReportRequest(r.Context(), h.AppsConfig.GetAppStoreID(appID), accessType)
+	if err != nil {
+		writeAppStoreError(w, fmt.Sprintf("Failed to request App Store report: %v", err), err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"appId":      appID,
+		"jobId":      job.ID,
+		"accessType": job.AccessType,
+		"status":     job.Status,
+		"timestamp":  time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusAccepted, response)
+}
+
+// GetAppStoreReportJob reports whether a report job is ready and, when it is, the latest
+// instance of each report with download links. Filter with ?category=, ?name= and ?granularity=.
+func (h *AppHandler) GetAppStoreReportJob(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+	jobID := vars["jobId"]
+
+	if h.AppStore == nil {
+		writeError(w, "App Store Connect not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := appstore.ReportFilter{
+		Category:    strings.ToUpper(query.Get("category")),
+		Name:        query.Get("name"),
+		Granularity: strings.ToUpper(query.Get("granularity")),
+	}
+	if filter.Category == "" && filter.Name == "" {
+		filter.Category = defaultReportCategory
+	}
+	if filter.Granularity == "" {
+		filter.Granularity = defaultReportGranularity
+	}
+
+	job, err := h.AppStore.GetReportJob(r.Context(), h.AppsConfig.GetAppStoreID(appID), jobID, filter)
+	if errors.Is(err, appstore.ErrReportRequestNotFound) {
+		writeError(w, "Report job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeAppStoreError(w, fmt.Sprintf("Failed to get App Store report: %v", err), err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"appId":     appID,
+		"job":       job,
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
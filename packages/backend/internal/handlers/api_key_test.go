@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// fakeAPIKeyStore holds keys by the hash of their secret
+type fakeAPIKeyStore map[string]*auth.APIKey
+
+func (s fakeAPIKeyStore) GetAPIKey(ctx context.Context, keyHash string) (*auth.APIKey, error) {
+	return s[keyHash], nil
+}
+
+func TestAuthorizeAcceptsAPIKeys(t *testing.T) {
+	h := &AppHandler{
+		JWTManager:  auth.NewJWTManager([]byte(testJWTSecret), "central-analytics", time.Hour),
+		AuthMetrics: auth.NewAuthMetrics(),
+		AppsConfig:  &appconfig.AppsConfiguration{},
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+		APIKeys: auth.NewAPIKeyAuthenticator(fakeAPIKeyStore{
+			auth.HashAPIKey("ci-secret"):      {ID: "ci", Scopes: []string{auth.ScopeViewer}, ExpiresAt: time.Now().Add(time.Hour)},
+			auth.HashAPIKey("expired-secret"): {ID: "old", Scopes: []string{auth.ScopeViewer}, ExpiresAt: time.Now().Add(-time.Minute)},
+			auth.HashAPIKey("revoked-secret"): {ID: "leaked", Scopes: []string{auth.ScopeViewer}, Revoked: true},
+		}),
+	}
+	var identity string
+	next := func(w http.ResponseWriter, r *http.Request) {
+		identity = r.Context().Value("claims").(*auth.SessionClaims).UserID
+		w.WriteHeader(http.StatusOK)
+	}
+	routes := map[string]http.HandlerFunc{
+		"viewer":  h.RequireScope(auth.ScopeViewer, next),
+		"billing": h.RequireScope(auth.ScopeBilling, next),
+		"admin":   h.AuthMiddleware(next),
+		"session": h.SessionMiddleware(next),
+	}
+	bearer := "Bearer " + signTestToken(t, testJWTSecret, time.Hour, &auth.AppleUserInfo{Sub: "viewer", Scopes: []string{auth.ScopeViewer}})
+
+	tests := []struct {
+		name          string
+		route         string
+		apiKey        string
+		authorization string
+		wantStatus    int
+		wantIdentity  string
+	}{
+		{name: "key within its scopes", route: "viewer", apiKey: "ci-secret", wantStatus: http.StatusOK, wantIdentity: "apikey:ci"},
+		{name: "key outside its scopes", route: "billing", apiKey: "ci-secret", wantStatus: http.StatusForbidden},
+		{name: "key on an admin route", route: "admin", apiKey: "ci-secret", wantStatus: http.StatusForbidden},
+		{name: "expired key", route: "viewer", apiKey: "expired-secret", wantStatus: http.StatusUnauthorized},
+		{name: "revoked key", route: "viewer", apiKey: "revoked-secret", wantStatus: http.StatusUnauthorized},
+		{name: "unknown key", route: "viewer", apiKey: "guessed-secret", wantStatus: http.StatusUnauthorized},
+		{name: "key is no session", route: "session", apiKey: "ci-secret", wantStatus: http.StatusUnauthorized},
+		{name: "JWT still works", route: "viewer", authorization: bearer, wantStatus: http.StatusOK, wantIdentity: "viewer"},
+		{name: "JWT on a session route", route: "session", authorization: bearer, wantStatus: http.StatusOK, wantIdentity: "viewer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity = ""
+			req := httptest.NewRequest(http.MethodGet, "/api/apps", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(auth.APIKeyHeader, tt.apiKey)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			routes[tt.route](rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if identity != tt.wantIdentity {
+				t.Errorf("identity = %q, want %q", identity, tt.wantIdentity)
+			}
+		})
+	}
+}
+
+func TestAPIKeysIgnoredWhenNotConfigured(t *testing.T) {
+	h := &AppHandler{
+		JWTManager:  auth.NewJWTManager([]byte(testJWTSecret), "central-analytics", time.Hour),
+		AuthMetrics: auth.NewAuthMetrics(),
+		AppsConfig:  &appconfig.AppsConfiguration{},
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	next := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	req := httptest.NewRequest(http.MethodGet, "/api/apps", nil)
+	req.Header.Set(auth.APIKeyHeader, "ci-secret")
+	rec := httptest.NewRecorder()
+	h.RequireScope(auth.ScopeViewer, next)(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401 without a bearer token", rec.Code)
+	}
+}
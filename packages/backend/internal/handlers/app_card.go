@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// appCardTTL is how long a summary card is served from cache. It is short enough for a list
+// view to notice incidents within a minute while absorbing rapid refreshes and app switching.
+const appCardTTL = time.Minute
+
+// AppCard is the minimal per-app summary shown in app switchers and portfolio lists. Each
+// figure is null when the app has nothing configured for its source or the fetch failed.
+type AppCard struct {
+	AppID     string   `json:"appId"`
+	Status    string   `json:"status"`    // Health status over the last hour
+	ErrorRate *float64 `json:"errorRate"` // Lambda error rate percentage since midnight UTC
+	CostToday *float64 `json:"costToday"` // Spend since midnight UTC
+	Downloads *int64   `json:"downloads"` // First-time downloads on the previous UTC day
+	Partial   bool     `json:"partial"`
+	// Cached marks a card served from cache; GeneratedAt is when it was built
+	Cached      bool  `json:"cached"`
+	GeneratedAt int64 `json:"generatedAt"`
+}
+
+// appCardEntry is a cached card and the status code it was built with
+type appCardEntry struct {
+	card      AppCard
+	status    int
+	fetchedAt time.Time
+}
+
+// appCardCache holds recently built cards per app
+type appCardCache struct {
+	mu      sync.Mutex
+	entries map[string]appCardEntry
+}
+
+// GetAppCard returns a minimal, cached summary of an app's health, error rate, today's cost
+// and downloads, meant for list views that show many apps at once
+func (ma *MetricsAggregator) GetAppCard(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	card, status := ma.appCard(r.Context(), appID)
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(appCardTTL.Seconds())))
+	writeJSON(w, status, card)
+}
+
+// appCard returns the app's card from cache while it is fresh, building and caching it otherwise
+func (ma *MetricsAggregator) appCard(ctx context.Context, appID string) (AppCard, int) {
+	ma.cards.mu.Lock()
+	entry, ok := ma.cards.entries[appID]
+	ma.cards.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < appCardTTL {
+		entry.card.Cached = true
+		return entry.card, entry.status
+	}
+
+	card, status := ma.buildAppCard(ctx, appID)
+
+	ma.cards.mu.Lock()
+	ma.cards.entries[appID] = appCardEntry{card: card, status: status, fetchedAt: time.Now()}
+	ma.cards.mu.Unlock()
+	return card, status
+}
+
+// buildAppCard fetches each figure on the card concurrently. Downloads use the previous day
+// because Apple publishes sales reports the day after.
+func (ma *MetricsAggregator) buildAppCard(ctx context.Context, appID string) (AppCard, int) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	card := AppCard{
+		AppID:       appID,
+		GeneratedAt: now.Unix(),
+	}
+	outcome := newPartialResult()
+	features := ma.appHandler.Features
+
+	var wg sync.WaitGroup
+	if features.Lambda {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if summary := ma.fetchLambdaSummary(ctx, appID, today, now, false, outcome); summary != nil && summary.TotalInvocations > 0 {
+				card.ErrorRate = &summary.ErrorRate
+			}
+		}()
+	}
+
+	if features.Cost {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			costData, err := ma.appHandler.GetAppCosts(ctx, appID, today, today.AddDate(0, 0, 1))
+			if err != nil {
+				outcome.failed("cost", err)
+				return
+			}
+			outcome.succeeded()
+			card.CostToday = &costData.TotalCost
+		}()
+	}
+
+	appStoreID := ma.appHandler.AppsConfig.GetAppStoreID(appID)
+	if features.AppStore && ma.appHandler.AppStore != nil && appStoreID != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			analytics, err := ma.appHandler.AppStore.GetAppAnalytics(ctx, appStoreID, yesterday, yesterday)
+			if err != nil {
+				outcome.failed("appstore:"+appStoreID, err)
+				return
+			}
+			outcome.succeeded()
+			card.Downloads = &analytics.Downloads
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		card.Status = ma.fetchHealthSummary(ctx, appID).Status
+	}()
+
+	wg.Wait()
+
+	card.Partial = outcome.Partial()
+	return card, outcome.StatusCode()
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+)
+
+func TestAppCardIsMinimal(t *testing.T) {
+	aggregator := newTestAggregator(&fakeAppStore{analytics: &appstore.AppAnalytics{Downloads: 42}})
+
+	rec := serveCached(aggregator.GetAppCard, "/apps/app/card")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "private, max-age=60" {
+		t.Errorf("Cache-Control = %q, want private, max-age=60", got)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	if want := []string{"appId", "cached", "costToday", "downloads", "errorRate", "generatedAt", "partial", "status"}; !slices.Equal(keys, want) {
+		t.Errorf("card fields = %v, want %v", keys, want)
+	}
+
+	var card AppCard
+	if err := json.Unmarshal(rec.Body.Bytes(), &card); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if card.AppID != "app" || card.Status != "healthy" || card.Partial || card.Cached {
+		t.Errorf("card = %+v, want a fresh, complete, healthy card for app", card)
+	}
+	if card.Downloads == nil || *card.Downloads != 42 {
+		t.Errorf("downloads = %v, want 42", card.Downloads)
+	}
+	// Lambda and cost are disabled, so their figures are null rather than zero
+	if card.ErrorRate != nil || card.CostToday != nil {
+		t.Errorf("error rate = %v, cost today = %v, want both null", card.ErrorRate, card.CostToday)
+	}
+	if generated := time.Unix(card.GeneratedAt, 0); time.Since(generated) > time.Minute {
+		t.Errorf("generatedAt = %s, want now", generated)
+	}
+}
+
+func TestAppCardServesRepeatsFromCache(t *testing.T) {
+	appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{Downloads: 42}}
+	aggregator := newTestAggregator(appStore)
+
+	var first AppCard
+	for i := range 5 {
+		rec := serveCached(aggregator.GetAppCard, "/apps/app/card")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200: %s", i, rec.Code, rec.Body)
+		}
+		var card AppCard
+		if err := json.Unmarshal(rec.Body.Bytes(), &card); err != nil {
+			t.Fatalf("request %d: decode response: %v", i, err)
+		}
+		if i == 0 {
+			first = card
+			continue
+		}
+		if !card.Cached {
+			t.Errorf("request %d was not served from cache", i)
+		}
+		if card.GeneratedAt != first.GeneratedAt || card.Downloads == nil || *card.Downloads != *first.Downloads {
+			t.Errorf("request %d: card = %+v, want the first card %+v", i, card, first)
+		}
+	}
+	if got := appStore.calls.Load(); got != 1 {
+		t.Errorf("App Store fetched %d times for rapid repeats, want 1", got)
+	}
+
+	// Once the card is older than its TTL it is rebuilt
+	aggregator.cards.mu.Lock()
+	entry := aggregator.cards.entries["app"]
+	entry.fetchedAt = entry.fetchedAt.Add(-appCardTTL)
+	aggregator.cards.entries["app"] = entry
+	aggregator.cards.mu.Unlock()
+
+	rec := serveCached(aggregator.GetAppCard, "/apps/app/card")
+	var card AppCard
+	if err := json.Unmarshal(rec.Body.Bytes(), &card); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if card.Cached {
+		t.Error("an expired card was served from cache")
+	}
+	if got := appStore.calls.Load(); got != 2 {
+		t.Errorf("App Store fetched %d times after the card expired, want 2", got)
+	}
+}
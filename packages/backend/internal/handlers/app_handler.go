@@ -2,10 +2,12 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,18 +15,39 @@ import (
 	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
 	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
 	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/clientip"
 	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+	"github.com/jamesvolpe/central-analytics/backend/internal/version"
+	"github.com/jamesvolpe/central-analytics/backend/internal/workers"
 )
 
 // AppHandler handles application analytics endpoints
 type AppHandler struct {
-	CloudWatch   *aws.CloudWatchClient
-	CostExplorer *aws.CostExplorerClient
-	DynamoDB     *aws.DynamoDBClient
-	AppStore     *appstore.AppStoreConnectClient
-	JWTManager   *auth.JWTManager
-	AppsConfig   *appconfig.AppsConfiguration
-	Logger       *slog.Logger
+	CloudWatch     *aws.CloudWatchClient
+	CostExplorer   *aws.CostExplorerClient
+	DynamoDB       *aws.DynamoDBClient
+	AppStore       appstore.Client
+	Tagging        *aws.ResourceTaggingClient
+	LambdaConfig   *aws.LambdaConfigClient
+	Idempotency    IdempotencyStore
+	RatingsHistory RatingsHistoryStore
+	Retention      *Retention
+	Workers        *workers.Registry
+	LambdaPricing  aws.LambdaPricing
+	JWTManager     *auth.JWTManager
+	APIKeys        *auth.APIKeyAuthenticator // nil disables X-API-Key authentication
+	AuthMetrics    *auth.AuthMetrics
+	ClientIP       *clientip.Resolver
+	Concurrency    *aws.ConcurrencyLimiter
+	Polling        *PollingDetector
+	AppsConfig     *appconfig.AppsConfiguration
+	Features       appconfig.FeatureFlags
+	Freshness      appconfig.FreshnessWindows
+	SLO            appconfig.SLO
+	AlertRules     appconfig.AlertRules
+	AlertTracker   *AlertTracker
+	Logger         *slog.Logger
 }
 
 // NewAppHandler creates a new application handler with injected dependencies
@@ -32,65 +55,155 @@ func NewAppHandler(
 	cloudWatch *aws.CloudWatchClient,
 	costExplorer *aws.CostExplorerClient,
 	dynamoDB *aws.DynamoDBClient,
-	appStore *appstore.AppStoreConnectClient,
+	appStore appstore.Client,
+	tagging *aws.ResourceTaggingClient,
 	jwtManager *auth.JWTManager,
 	appsConfig *appconfig.AppsConfiguration,
 	logger *slog.Logger,
 ) *AppHandler {
 	return &AppHandler{
-		CloudWatch:   cloudWatch,
-		CostExplorer: costExplorer,
-		DynamoDB:     dynamoDB,
-		AppStore:     appStore,
-		JWTManager:   jwtManager,
-		AppsConfig:   appsConfig,
-		Logger:       logger,
+		CloudWatch:    cloudWatch,
+		CostExplorer:  costExplorer,
+		DynamoDB:      dynamoDB,
+		AppStore:      appStore,
+		Tagging:       tagging,
+		JWTManager:    jwtManager,
+		AuthMetrics:   auth.NewAuthMetrics(),
+		AppsConfig:    appsConfig,
+		Features:      appconfig.AllFeaturesEnabled(),
+		Freshness:     appconfig.LoadFreshnessWindows(),
+		SLO:           appconfig.LoadSLO(),
+		AlertRules:    appconfig.LoadAlertRules(),
+		AlertTracker:  NewAlertTracker(nil, DefaultAlertRenotifyInterval, logger),
+		Workers:       workers.NewRegistry(),
+		LambdaPricing: aws.DefaultLambdaPricing(),
+		Logger:        logger,
 	}
 }
 
-// AuthMiddleware validates JWT tokens and checks admin access
+// AuthMiddleware validates JWT tokens or API keys and checks admin access
 func (h *AppHandler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return h.authorize(next, true, "Admin access required", func(claims *auth.SessionClaims) bool {
+		return claims.IsAdmin
+	})
+}
+
+// RequireScope validates JWT tokens or API keys and lets through identities holding scope.
+// Admins hold every scope, so routes behind RequireScope stay open to them.
+func (h *AppHandler) RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return h.authorize(next, true, fmt.Sprintf("%s access required", scope), func(claims *auth.SessionClaims) bool {
+		return claims.HasScope(scope)
+	})
+}
+
+// SessionMiddleware validates JWT tokens and lets through any signed-in user, whatever their
+// scopes, for routes about the session itself. API keys have no session and aren't accepted.
+func (h *AppHandler) SessionMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return h.authorize(next, false, "", func(*auth.SessionClaims) bool {
+		return true
+	})
+}
+
+// authorize validates the bearer token, or the X-API-Key header when acceptAPIKey is set and
+// API keys are configured, and calls next if allowed accepts the resulting claims, responding
+// 403 with denial otherwise
+func (h *AppHandler) authorize(next http.HandlerFunc, acceptAPIKey bool, denial string, allowed func(*auth.SessionClaims) bool) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		h.Logger.Debug("AuthMiddleware called", "path", r.URL.Path, "method", r.Method)
-		// Extract token from Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			h.Logger.Warn("No Authorization header", "path", r.URL.Path)
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
+
+		var claims *auth.SessionClaims
+		if apiKey := r.Header.Get(auth.APIKeyHeader); apiKey != "" && acceptAPIKey && h.APIKeys != nil {
+			var err error
+			claims, err = h.APIKeys.Authenticate(apiKey)
+			if err != nil {
+				outcome := h.AuthMetrics.RecordValidation(err)
+				h.Logger.Warn("API key validation failed", "outcome", outcome, "error", err, "client_ip", h.ClientIP.ClientIP(r))
+				writeError(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			h.Logger.Debug("API key validated", "userID", claims.UserID, "isAdmin", claims.IsAdmin, "scopes", claims.Scopes)
+		} else {
+			var ok bool
+			claims, ok = h.bearerClaims(w, r)
+			if !ok {
+				return
+			}
 		}
 
-		// Remove "Bearer " prefix
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		if token == authHeader {
-			h.Logger.Warn("Invalid authorization format", "header", authHeader)
-			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+		// Check the route's access requirement
+		if !allowed(claims) {
+			h.Logger.Warn("User without access attempted request", "userID", claims.UserID, "scopes", claims.Scopes, "path", r.URL.Path, "client_ip", h.ClientIP.ClientIP(r))
+			h.AuthMetrics.Record(auth.OutcomeForbidden)
+			writeError(w, denial, http.StatusForbidden)
 			return
 		}
+		h.Logger.Debug("Access granted", "userID", claims.UserID)
+		h.AuthMetrics.Record(auth.OutcomeSuccess)
+		h.Polling.Observe(r, h.ClientIP.ClientIP(r))
 
-		// Validate token
-		claims, err := h.JWTManager.ValidateToken(token)
-		if err != nil {
-			h.Logger.Warn("Token validation failed", "error", err)
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
+		// Add claims to context
+		ctx := context.WithValue(r.Context(), "claims", claims)
+
+		// Count AWS calls for this request against the app's concurrency limit. Only configured
+		// apps get a semaphore so arbitrary path values can't grow the limiter.
+		if appID := mux.Vars(r)["appId"]; h.AppsConfig.GetAppConfig(appID) != nil {
+			ctx = aws.WithAppID(ctx, appID)
 		}
-		h.Logger.Debug("Token validated", "userID", claims.UserID, "isAdmin", claims.IsAdmin)
 
-		// Check admin access
-		if !claims.IsAdmin {
-			h.Logger.Warn("Non-admin user attempted access", "userID", claims.UserID, "path", r.URL.Path)
-			http.Error(w, "Admin access required", http.StatusForbidden)
-			return
+		// Reject a malformed ?range= shorthand here so every handler needn't
+		if value := r.URL.Query().Get("range"); value != "" {
+			if _, err := timerange.ParseRelative(value); err != nil {
+				writeError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 		}
-		h.Logger.Debug("Admin access granted", "userID", claims.UserID)
 
-		// Add claims to context
-		ctx := context.WithValue(r.Context(), "claims", claims)
+		// An explicit ?period= (seconds) overrides the CloudWatch period chosen from the range
+		if value := r.URL.Query().Get("period"); value != "" {
+			period, err := strconv.Atoi(value)
+			if err != nil || !aws.ValidPeriod(period) {
+				writeError(w, "period must be a multiple of 60 seconds, up to 7 days", http.StatusBadRequest)
+				return
+			}
+			ctx = aws.WithPeriod(ctx, int32(period))
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// bearerClaims validates the bearer token in the Authorization header, responding 401 and
+// reporting false when it is missing or invalid
+func (h *AppHandler) bearerClaims(w http.ResponseWriter, r *http.Request) (*auth.SessionClaims, bool) {
+	// Extract token from Authorization header
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		h.Logger.Warn("No Authorization header", "path", r.URL.Path, "client_ip", h.ClientIP.ClientIP(r))
+		h.AuthMetrics.Record(auth.OutcomeMissingToken)
+		writeError(w, "Authorization header required", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Remove "Bearer " prefix
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == authHeader {
+		h.Logger.Warn("Invalid authorization format", "header", authHeader, "client_ip", h.ClientIP.ClientIP(r))
+		h.AuthMetrics.Record(auth.OutcomeMalformed)
+		writeError(w, "Invalid authorization format", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Validate token
+	claims, err := h.JWTManager.ValidateToken(token)
+	if err != nil {
+		outcome := h.AuthMetrics.RecordValidation(err)
+		h.Logger.Warn("Token validation failed", "outcome", outcome, "error", err, "client_ip", h.ClientIP.ClientIP(r))
+		writeError(w, "Invalid token", http.StatusUnauthorized)
+		return nil, false
+	}
+	h.Logger.Debug("Token validated", "userID", claims.UserID, "isAdmin", claims.IsAdmin, "scopes", claims.Scopes)
+	return claims, true
+}
+
 // GetLambdaMetrics handles Lambda metrics endpoint
 func (h *AppHandler) GetLambdaMetrics(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -99,29 +212,92 @@ func (h *AppHandler) GetLambdaMetrics(w http.ResponseWriter, r *http.Request) {
 	// Parse time range
 	startTime, endTime := parseTimeRange(r)
 
+	rateUnit, err := parseRateUnit(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get Lambda functions for the app
-	lambdaFunctions := h.AppsConfig.GetLambdaFunctions(appID)
+	lambdaFunctions := h.ResolveLambdaFunctions(r.Context(), appID)
 
-	var allMetrics []*aws.LambdaMetrics
+	outcome := newPartialResult()
+	allMetrics := []*aws.LambdaMetrics{}
 	for _, functionName := range lambdaFunctions {
 		metrics, err := h.CloudWatch.GetLambdaMetrics(r.Context(), functionName, startTime, endTime)
 		if err != nil {
-			fmt.Printf("Error getting metrics for Lambda %s: %v\n", functionName, err)
+			h.Logger.Warn("Failed to get Lambda metrics", "function", functionName, "error", err)
+			outcome.failed(functionName, err)
 			continue
 		}
+		outcome.succeeded()
+		if rateUnit != "" {
+			metrics.Rates = metrics.CounterRates(endTime.Sub(startTime), rateUnit)
+		}
 		allMetrics = append(allMetrics, metrics)
 	}
 
+	var latest time.Time
+	for _, metrics := range allMetrics {
+		latest = newestTime(latest, metrics.DataAsOf)
+	}
+	freshness := h.freshness("lambda", latest, endTime)
+
 	// Create response
 	response := map[string]interface{}{
 		"appId":     appID,
 		"metrics":   allMetrics,
-		"period":    fmt.Sprintf("%s to %s", startTime.Format(time.RFC3339), endTime.Format(time.RFC3339)),
+		"period":    timerange.NewPeriod(startTime, endTime),
+		"dataAsOf":  freshness.DataAsOf,
+		"stale":     freshness.Stale,
 		"timestamp": time.Now().Unix(),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	outcome.writeJSON(w, response)
+}
+
+// GetLambdaStatistics handles the Lambda detail endpoint with mixed statistics per function
+func (h *AppHandler) GetLambdaStatistics(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	// Parse time range
+	startTime, endTime := parseTimeRange(r)
+
+	// Restrict to a single function when requested
+	lambdaFunctions := h.ResolveLambdaFunctions(r.Context(), appID)
+	if function := r.URL.Query().Get("function"); function != "" {
+		lambdaFunctions = []string{function}
+	}
+
+	// Memory size enables a cost estimate at the region's Lambda rates
+	memoryMB, _ := strconv.Atoi(r.URL.Query().Get("memoryMb"))
+
+	outcome := newPartialResult()
+	allStats := []*aws.LambdaStatistics{}
+	for _, functionName := range lambdaFunctions {
+		stats, err := h.CloudWatch.GetLambdaStatistics(r.Context(), functionName, startTime, endTime)
+		if err != nil {
+			h.Logger.Warn("Failed to get Lambda statistics", "function", functionName, "error", err)
+			outcome.failed(functionName, err)
+			continue
+		}
+		outcome.succeeded()
+		if memoryMB > 0 {
+			stats.EstimatedCost = h.LambdaPricing.EstimateCost(stats.InvocationsSum, stats.DurationAverage, memoryMB)
+		}
+		allStats = append(allStats, stats)
+	}
+
+	response := map[string]interface{}{
+		"appId":      appID,
+		"statistics": allStats,
+		"pricing":    h.LambdaPricing,
+		"period":     timerange.NewPeriod(startTime, endTime),
+		"timestamp":  time.Now().Unix(),
+	}
+
+	outcome.writeJSON(w, response)
 }
 
 // GetAPIGatewayMetrics handles API Gateway metrics endpoint
@@ -132,24 +308,36 @@ func (h *AppHandler) GetAPIGatewayMetrics(w http.ResponseWriter, r *http.Request
 	// Parse time range
 	startTime, endTime := parseTimeRange(r)
 
+	rateUnit, err := parseRateUnit(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get API Gateway name for the app
 	apiName := h.AppsConfig.GetAPIGateway(appID)
 
 	metrics, err := h.CloudWatch.GetAPIGatewayMetrics(r.Context(), apiName, startTime, endTime)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get API Gateway metrics: %v", err), http.StatusInternalServerError)
+		writeError(w, fmt.Sprintf("Failed to get API Gateway metrics: %v", err), http.StatusInternalServerError)
 		return
 	}
+	if rateUnit != "" {
+		metrics.Rates = metrics.CounterRates(endTime.Sub(startTime), rateUnit)
+	}
+
+	freshness := h.freshness("apigateway", newestTime(time.Time{}, metrics.DataAsOf), endTime)
 
 	// Create response
 	response := map[string]interface{}{
 		"appId":     appID,
 		"metrics":   metrics,
+		"dataAsOf":  freshness.DataAsOf,
+		"stale":     freshness.Stale,
 		"timestamp": time.Now().Unix(),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetDynamoDBMetrics handles DynamoDB metrics endpoint
@@ -163,21 +351,63 @@ func (h *AppHandler) GetDynamoDBMetrics(w http.ResponseWriter, r *http.Request)
 	// Get DynamoDB tables for the app
 	tables := h.AppsConfig.GetDynamoDBTables(appID)
 
-	metrics, err := h.DynamoDB.GetMultipleTableMetrics(r.Context(), tables, startTime, endTime)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get DynamoDB metrics: %v", err), http.StatusInternalServerError)
-		return
+	outcome := newPartialResult()
+	metrics := []*aws.DynamoDBMetrics{}
+	for _, tableName := range tables {
+		tableMetrics, err := h.DynamoDB.GetTableMetrics(r.Context(), tableName, startTime, endTime)
+		if err != nil {
+			h.Logger.Warn("Failed to get DynamoDB metrics", "table", tableName, "error", err)
+			outcome.failed(tableName, err)
+			continue
+		}
+		outcome.succeeded()
+		metrics = append(metrics, tableMetrics)
+	}
+
+	// Exact counts scan every item, so they are only run when explicitly requested
+	if r.URL.Query().Get("exactCount") == "true" {
+		h.exactItemCounts(r.Context(), appID, metrics)
+	}
+
+	var latest time.Time
+	for _, tableMetrics := range metrics {
+		latest = newestTime(latest, tableMetrics.DataAsOf)
 	}
+	freshness := h.freshness("dynamodb", latest, endTime)
 
 	// Create response
 	response := map[string]interface{}{
 		"appId":     appID,
 		"metrics":   metrics,
+		"dataAsOf":  freshness.DataAsOf,
+		"stale":     freshness.Stale,
 		"timestamp": time.Now().Unix(),
 	}
+	for _, tableMetrics := range metrics {
+		if tableMetrics.ItemCountApproximate {
+			response["itemCountNote"] = fmt.Sprintf("Item counts are approximate; DynamoDB refreshes them about every %s", aws.ItemCountRefreshInterval)
+			break
+		}
+	}
+
+	outcome.writeJSON(w, response)
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// exactItemCounts replaces approximate item counts with Scan counts where the rate limit allows
+func (h *AppHandler) exactItemCounts(ctx context.Context, appID string, metrics []*aws.DynamoDBMetrics) {
+	h.Logger.Warn("Exact DynamoDB item count requested; scanning full tables", "app_id", appID)
+
+	for _, tableMetrics := range metrics {
+		count, err := h.DynamoDB.ExactItemCount(ctx, tableMetrics.TableName)
+		if err != nil {
+			h.Logger.Warn("Falling back to approximate item count", "table", tableMetrics.TableName, "error", err)
+			continue
+		}
+		now := time.Now().UTC()
+		tableMetrics.ItemCount = count
+		tableMetrics.ItemCountApproximate = false
+		tableMetrics.ItemCountAsOf = &now
+	}
 }
 
 // GetCostAnalytics handles AWS cost analytics endpoint
@@ -189,28 +419,118 @@ func (h *AppHandler) GetCostAnalytics(w http.ResponseWriter, r *http.Request) {
 	startTime, endTime := parseTimeRange(r)
 
 	// Get cost data
-	costData, err := h.CostExplorer.GetCostAndUsage(r.Context(), startTime, endTime)
+	costData, err := h.GetAppCosts(r.Context(), appID, startTime, endTime)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get cost data: %v", err), http.StatusInternalServerError)
+		writeError(w, fmt.Sprintf("Failed to get cost data: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// Get cost forecast
-	forecast, err := h.CostExplorer.GetForecast(r.Context(), 30)
+	forecast, err := h.CostExplorer.GetForecast(r.Context(), 30, aws.AppCostFilter(h.costScope(appID)))
 	if err != nil {
 		fmt.Printf("Failed to get cost forecast: %v\n", err)
 	}
 
+	freshness := h.freshness("cost", latestCostDate(costData), endTime)
+
 	// Create response
 	response := map[string]interface{}{
 		"appId":     appID,
 		"current":   costData,
 		"forecast":  forecast,
+		"dataAsOf":  freshness.DataAsOf,
+		"stale":     freshness.Stale,
 		"timestamp": time.Now().Unix(),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	// Unprocessed Cost Explorer results, kept apart from the processed data for audits
+	if r.URL.Query().Get("raw") == "true" {
+		response["raw"] = costData.Raw
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetCostByCategory handles the cost breakdown by Cost Category values endpoint
+func (h *AppHandler) GetCostByCategory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	// Parse time range
+	startTime, endTime := parseTimeRange(r)
+
+	// Default to the app's configured Cost Category
+	categoryName := r.URL.Query().Get("category")
+	if categoryName == "" {
+		categoryName, _, _ = h.AppsConfig.GetCostCategory(appID)
+	}
+	if categoryName == "" {
+		writeError(w, "No cost category configured or requested", http.StatusBadRequest)
+		return
+	}
+
+	categories, err := h.CostExplorer.GetCostByCategory(r.Context(), categoryName, startTime, endTime)
+	if err != nil {
+		writeError(w, fmt.Sprintf("Failed to get cost by category: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Create response
+	response := map[string]interface{}{
+		"appId":     appID,
+		"category":  categoryName,
+		"values":    categories,
+		"period":    timerange.NewDatePeriod(startTime, endTime),
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetCostGrouped handles the cost breakdown by dimension endpoint (?groupBy=service|region).
+// Spend is limited to ?regions= or the app's configured cost regions, giving a combined
+// multi-region total alongside the per-group breakdown.
+func (h *AppHandler) GetCostGrouped(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	groupBy := strings.ToUpper(r.URL.Query().Get("groupBy"))
+	if !aws.IsCostGroupBy(groupBy) {
+		writeError(w, fmt.Sprintf("groupBy must be %q or %q", strings.ToLower(aws.CostGroupByService), strings.ToLower(aws.CostGroupByRegion)), http.StatusBadRequest)
+		return
+	}
+
+	// Parse time range
+	startTime, endTime := parseTimeRange(r)
+
+	regions := h.AppsConfig.GetCostRegions(appID)
+	if value := r.URL.Query().Get("regions"); value != "" {
+		regions = nil
+		for _, region := range strings.Split(value, ",") {
+			if region = strings.TrimSpace(region); region != "" {
+				regions = append(regions, region)
+			}
+		}
+	}
+
+	grouped, err := h.CostExplorer.GetCostGroupedBy(r.Context(), groupBy, aws.AppCostFilter(h.costScope(appID)), regions, startTime, endTime)
+	if err != nil {
+		writeError(w, fmt.Sprintf("Failed to get grouped costs: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Create response
+	response := map[string]interface{}{
+		"appId":     appID,
+		"groupBy":   grouped.GroupBy,
+		"regions":   grouped.Regions,
+		"total":     grouped.Total,
+		"groups":    grouped.Groups,
+		"period":    timerange.NewDatePeriod(startTime, endTime),
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetAppStoreDownloads handles App Store downloads metrics endpoint
@@ -219,7 +539,7 @@ func (h *AppHandler) GetAppStoreDownloads(w http.ResponseWriter, r *http.Request
 	appID := vars["appId"]
 
 	if h.AppStore == nil {
-		http.Error(w, "App Store Connect not configured", http.StatusServiceUnavailable)
+		writeError(w, "App Store Connect not configured", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -229,7 +549,7 @@ func (h *AppHandler) GetAppStoreDownloads(w http.ResponseWriter, r *http.Request
 	// Get App Store analytics
 	analytics, err := h.AppStore.GetAppAnalytics(r.Context(), h.AppsConfig.GetAppStoreID(appID), startTime, endTime)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get App Store analytics: %v", err), http.StatusInternalServerError)
+		writeAppStoreError(w, fmt.Sprintf("Failed to get App Store analytics: %v", err), err)
 		return
 	}
 
@@ -243,8 +563,7 @@ func (h *AppHandler) GetAppStoreDownloads(w http.ResponseWriter, r *http.Request
 		"timestamp":     time.Now().Unix(),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetAppStoreRevenue handles App Store revenue metrics endpoint
@@ -253,7 +572,7 @@ func (h *AppHandler) GetAppStoreRevenue(w http.ResponseWriter, r *http.Request)
 	appID := vars["appId"]
 
 	if h.AppStore == nil {
-		http.Error(w, "App Store Connect not configured", http.StatusServiceUnavailable)
+		writeError(w, "App Store Connect not configured", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -263,7 +582,7 @@ func (h *AppHandler) GetAppStoreRevenue(w http.ResponseWriter, r *http.Request)
 	// Get App Store analytics
 	analytics, err := h.AppStore.GetAppAnalytics(r.Context(), h.AppsConfig.GetAppStoreID(appID), startTime, endTime)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get App Store revenue: %v", err), http.StatusInternalServerError)
+		writeAppStoreError(w, fmt.Sprintf("Failed to get App Store revenue: %v", err), err)
 		return
 	}
 
@@ -275,16 +594,80 @@ func (h *AppHandler) GetAppStoreRevenue(w http.ResponseWriter, r *http.Request)
 
 	// Create response focused on revenue
 	response := map[string]interface{}{
-		"appId":     appID,
-		"revenue":   analytics.Revenue,
-		"arpu":      arpu,
-		"ratings":   analytics.Ratings,
-		"period":    analytics.Period,
+		"appId":           appID,
+		"revenue":         analytics.Revenue,
+		"revenueCurrency": analytics.RevenueCurrency,
+		"proceeds":        analytics.Proceeds,
+		"arpu":            arpu,
+		"ratings":         analytics.Ratings,
+		"period":          analytics.Period,
+		"timestamp":       time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetAppStoreDiagnostics reports the App Store Connect quota last observed from Apple
+func (h *AppHandler) GetAppStoreDiagnostics(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"configured": h.AppStore != nil,
+		"timestamp":  time.Now().Unix(),
+	}
+
+	if h.AppStore != nil {
+		response["rateLimit"] = h.AppStore.RateLimitStatus()
+		_, mock := h.AppStore.(*appstore.MockClient)
+		response["mock"] = mock
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetVersion reports the running build and which subsystems are enabled
+func (h *AppHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"build":    version.Get(),
+		"features": h.Features,
+		"subsystems": map[string]bool{
+			"appStoreConnect": h.AppStore != nil,
+			"idempotency":     h.Idempotency != nil,
+			"ratingsHistory":  h.RatingsHistory != nil,
+			"lambdaDiscovery": h.Tagging != nil,
+		},
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetWorkerDiagnostics reports the status of registered background workers
+func (h *AppHandler) GetWorkerDiagnostics(w http.ResponseWriter, r *http.Request) {
+	statuses := []workers.WorkerStatus{}
+	if h.Workers != nil {
+		statuses = h.Workers.Statuses()
+	}
+
+	response := map[string]interface{}{
+		"workers":   statuses,
 		"timestamp": time.Now().Unix(),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetConcurrencyDiagnostics reports in-flight AWS calls per app
+func (h *AppHandler) GetConcurrencyDiagnostics(w http.ResponseWriter, r *http.Request) {
+	apps := []aws.AppConcurrency{}
+	if h.Concurrency != nil {
+		apps = h.Concurrency.InFlight()
+	}
+
+	response := map[string]interface{}{
+		"apps":      apps,
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetHealthStatus handles health status endpoint
@@ -292,94 +675,193 @@ func (h *AppHandler) GetHealthStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	appID := vars["appId"]
 
-	// Get current time for recent metrics (last hour)
-	endTime := time.Now()
-	startTime := endTime.Add(-1 * time.Hour)
+	metrics := h.fetchHealthMetrics(r.Context(), appID, true, true, true, newPartialResult())
+	alerts := h.evaluateHealth(appID, metrics)
+	byResource := alertsByResource(alerts)
+
+	services := map[string]string{}
+	for functionName, m := range metrics.Lambda {
+		services[functionName] = serviceHealth(m != nil, byResource["lambda:"+functionName])
+	}
+	if metrics.APIName != "" {
+		services["apiGateway"] = serviceHealth(metrics.APIGateway != nil, byResource["apigateway:"+metrics.APIName])
+	}
+	for tableName, m := range metrics.DynamoDB {
+		services[tableName] = serviceHealth(m != nil, byResource["dynamodb:"+tableName])
+	}
 
 	health := map[string]interface{}{
 		"appId":     appID,
-		"status":    "healthy",
+		"status":    healthStatus(alerts),
 		"timestamp": time.Now().Unix(),
-		"services":  map[string]string{},
+		"services":  services,
 	}
 
-	// Check Lambda health
-	lambdaFunctions := h.AppsConfig.GetLambdaFunctions(appID)
-	lambdaHealthy := true
-	for _, functionName := range lambdaFunctions {
-		metrics, err := h.CloudWatch.GetLambdaMetrics(r.Context(), functionName, startTime, endTime)
-		if err != nil {
-			health["services"].(map[string]string)[functionName] = "unknown"
-			continue
-		}
+	writeJSON(w, http.StatusOK, health)
+}
 
-		// Check error rate
-		errorRate := float64(0)
-		if metrics.Invocations > 0 {
-			errorRate = (metrics.Errors / metrics.Invocations) * 100
-		}
+// serviceHealth returns a resource's status from the alerts firing for it, or "unknown" when
+// its metrics couldn't be fetched
+func serviceHealth(fetched bool, alerts []Alert) string {
+	if !fetched {
+		return "unknown"
+	}
+	return healthStatus(alerts)
+}
 
-		if errorRate > 5 || metrics.Throttles > 0 {
-			health["services"].(map[string]string)[functionName] = "degraded"
-			lambdaHealthy = false
-		} else {
-			health["services"].(map[string]string)[functionName] = "healthy"
-		}
+// Helper functions
+
+// ResolveLambdaFunctions returns the Lambda functions configured for an app, falling back
+// to tag-based discovery when no explicit list is configured
+func (h *AppHandler) ResolveLambdaFunctions(ctx context.Context, appID string) []string {
+	if functions := h.AppsConfig.GetLambdaFunctions(appID); len(functions) > 0 {
+		return functions
 	}
 
-	// Check API Gateway health
-	apiName := h.AppsConfig.GetAPIGateway(appID)
-	apiMetrics, err := h.CloudWatch.GetAPIGatewayMetrics(r.Context(), apiName, startTime, endTime)
+	tagKey, tagValue, ok := h.AppsConfig.GetLambdaTag(appID)
+	if !ok || h.Tagging == nil {
+		return []string{}
+	}
+
+	functions, err := h.Tagging.GetLambdaFunctionsByTag(ctx, tagKey, tagValue)
 	if err != nil {
-		health["services"].(map[string]string)["apiGateway"] = "unknown"
-	} else {
-		errorRate := float64(0)
-		if apiMetrics.Count > 0 {
-			errorRate = ((apiMetrics.Error4XX + apiMetrics.Error5XX) / apiMetrics.Count) * 100
-		}
+		h.Logger.Warn("Failed to discover Lambda functions by tag", "appId", appID, "tag", tagKey+"="+tagValue, "error", err)
+		return []string{}
+	}
 
-		if errorRate > 5 || apiMetrics.Latency > 1000 {
-			health["services"].(map[string]string)["apiGateway"] = "degraded"
-			lambdaHealthy = false
-		} else {
-			health["services"].(map[string]string)["apiGateway"] = "healthy"
-		}
+	return functions
+}
+
+// SelectLambdaFunctions returns the app's Lambda functions, narrowed to the comma-separated
+// functions query parameter when present. Requested names must belong to the app.
+func (h *AppHandler) SelectLambdaFunctions(r *http.Request, appID string) ([]string, error) {
+	functions := h.ResolveLambdaFunctions(r.Context(), appID)
+
+	requested := r.URL.Query().Get("functions")
+	if strings.TrimSpace(requested) == "" {
+		return functions, nil
 	}
 
-	// Check DynamoDB health
-	tables := h.AppsConfig.GetDynamoDBTables(appID)
-	dynamoHealthy := true
-	for _, tableName := range tables {
-		metrics, err := h.DynamoDB.GetTableMetrics(r.Context(), tableName, startTime, endTime)
-		if err != nil {
-			health["services"].(map[string]string)[tableName] = "unknown"
+	known := make(map[string]bool, len(functions))
+	for _, name := range functions {
+		known[name] = true
+	}
+
+	selected := []string{}
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(requested, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
 			continue
 		}
-
-		if metrics.ThrottledRequests > 0 || metrics.SystemErrors > 0 {
-			health["services"].(map[string]string)[tableName] = "degraded"
-			dynamoHealthy = false
-		} else {
-			health["services"].(map[string]string)[tableName] = "healthy"
+		if !known[name] {
+			return nil, fmt.Errorf("unknown function %q for app %s", name, appID)
 		}
+		seen[name] = true
+		selected = append(selected, name)
 	}
+	return selected, nil
+}
 
-	// Set overall health status
-	if !lambdaHealthy || !dynamoHealthy {
-		health["status"] = "degraded"
+// GetAppCosts returns cost data for an app, filtered to its Cost Category and cost allocation
+// tag when they are configured
+// and with untracked services grouped as "Other" when a tracked-services list is set
+func (h *AppHandler) GetAppCosts(ctx context.Context, appID string, startTime, endTime time.Time) (*aws.CostData, error) {
+	costData, err := h.CostExplorer.GetCostAndUsageFiltered(ctx, startTime, endTime, aws.AppCostFilter(h.costScope(appID)))
+	if err != nil {
+		return nil, err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(health)
+	if tracked := h.AppsConfig.GetTrackedServices(appID); len(tracked) > 0 {
+		costData.Services = aws.GroupUntrackedServices(costData.Services, tracked)
+	}
+	return costData, nil
 }
 
-// Helper functions
+// costScope returns the Cost Category and cost allocation tag configured to isolate an app's
+// spend, each nil when not configured
+func (h *AppHandler) costScope(appID string) (*aws.CostCategoryFilter, *aws.CostTagFilter) {
+	var category *aws.CostCategoryFilter
+	if name, value, ok := h.AppsConfig.GetCostCategory(appID); ok {
+		category = &aws.CostCategoryFilter{Name: name, Value: value}
+	}
+	var tag *aws.CostTagFilter
+	if key, value, ok := h.AppsConfig.GetCostTag(appID); ok {
+		tag = &aws.CostTagFilter{Key: key, Value: value}
+	}
+	return category, tag
+}
+
+// writeAppStoreError responds with message and the status appStoreErrorStatus maps err to.
+// Rate limit rejections pass on Apple's requested wait as Retry-After so clients back off.
+func writeAppStoreError(w http.ResponseWriter, message string, err error) {
+	var rateLimitErr *appstore.RateLimitError
+	if errors.As(err, &rateLimitErr) && rateLimitErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))))
+	}
+	writeError(w, message, appStoreErrorStatus(err))
+}
+
+// appStoreErrorStatus maps App Store Connect errors to an HTTP status code
+func appStoreErrorStatus(err error) int {
+	if errors.Is(err, appstore.ErrRateLimited) {
+		return http.StatusTooManyRequests
+	}
+	// A client that failed initialization or has no vendor number for sales reports is
+	// treated like one that isn't configured
+	if errors.Is(err, appstore.ErrClientNotInitialized) || errors.Is(err, appstore.ErrVendorNumberNotSet) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// parseRateUnit reads the optional rate query parameter (second or minute), which adds
+// per-unit rates alongside counter sums
+func parseRateUnit(r *http.Request) (string, error) {
+	unit := r.URL.Query().Get("rate")
+	if unit != "" && !aws.IsRateUnit(unit) {
+		return "", fmt.Errorf("rate must be %q or %q", aws.RatePerSecond, aws.RatePerMinute)
+	}
+	return unit, nil
+}
+
+// freshness computes the dataAsOf/stale indicator for a metric source
+func (h *AppHandler) freshness(metric string, latest, endTime time.Time) timerange.Freshness {
+	return timerange.NewFreshness(latest, h.Freshness.Window(metric), endTime)
+}
+
+// newestTime returns the later of current and candidate, ignoring a nil candidate
+func newestTime(current time.Time, candidate *time.Time) time.Time {
+	if candidate != nil && candidate.After(current) {
+		return *candidate
+	}
+	return current
+}
+
+// latestCostDate returns the start of the most recent day with cost data
+func latestCostDate(costData *aws.CostData) time.Time {
+	var latest time.Time
+	for _, daily := range costData.DailyCosts {
+		if date, err := time.Parse("2006-01-02", daily.Date); err == nil && date.After(latest) {
+			latest = date
+		}
+	}
+	return latest
+}
 
 func parseTimeRange(r *http.Request) (time.Time, time.Time) {
 	// Default to last 24 hours
 	endTime := time.Now()
 	startTime := endTime.Add(-24 * time.Hour)
 
+	// An end-relative range (e.g. ?range=7d) takes precedence over start/end. AuthMiddleware
+	// has already rejected invalid shorthands.
+	if value := r.URL.Query().Get("range"); value != "" {
+		if span, err := timerange.ParseRelative(value); err == nil {
+			return endTime.Add(-span), endTime
+		}
+	}
+
 	// Parse query parameters
 	if start := r.URL.Query().Get("start"); start != "" {
 		if t, err := time.Parse(time.RFC3339, start); err == nil {
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/workers"
+)
+
+func TestWriteAppStoreError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantRetry  string
+	}{
+		{
+			name:       "rate limited with retry after",
+			err:        fmt.Errorf("failed to fetch reviews: %w", &appstore.RateLimitError{RetryAfter: 1500 * time.Millisecond}),
+			wantStatus: http.StatusTooManyRequests,
+			wantRetry:  "2",
+		},
+		{
+			name:       "rate limited without retry after",
+			err:        &appstore.RateLimitError{},
+			wantStatus: http.StatusTooManyRequests,
+		},
+		{
+			name:       "client not initialized",
+			err:        appstore.ErrClientNotInitialized,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "other failure",
+			err:        fmt.Errorf("unexpected response"),
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeAppStoreError(rec, "Failed to fetch reviews", tt.err)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Retry-After"); got != tt.wantRetry {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestGetWorkerDiagnostics(t *testing.T) {
+	registry := workers.NewRegistry()
+	registry.Register("appstore-ratings-snapshot", 6*time.Hour).RecordRun(errors.New("throttled"))
+	registry.Register("alert-check", 5*time.Minute)
+	h := &AppHandler{Workers: registry}
+
+	rec := httptest.NewRecorder()
+	h.GetWorkerDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics/workers", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var body struct {
+		Workers []workers.WorkerStatus `json:"workers"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	if len(body.Workers) != 2 {
+		t.Fatalf("workers = %+v, want 2", body.Workers)
+	}
+	idle, snapshot := body.Workers[0], body.Workers[1]
+	if idle.Name != "alert-check" || idle.RunCount != 0 || idle.LastRun != nil {
+		t.Errorf("idle worker = %+v", idle)
+	}
+	if snapshot.Name != "appstore-ratings-snapshot" || snapshot.RunCount != 1 || snapshot.LastRun == nil || snapshot.LastError != "throttled" {
+		t.Errorf("snapshot worker = %+v", snapshot)
+	}
+
+	// Without a registry the list is empty rather than null
+	rec = httptest.NewRecorder()
+	(&AppHandler{}).GetWorkerDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics/workers", nil))
+	if !strings.Contains(rec.Body.String(), `"workers":[]`) {
+		t.Errorf("response without a registry = %s", rec.Body)
+	}
+}
+
+func TestSelectLambdaFunctions(t *testing.T) {
+	h := &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"app": {ID: "app", LambdaFunctions: []string{"api", "worker", "auth"}},
+		}},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	tests := []struct {
+		name      string
+		functions string
+		want      []string
+		wantErr   bool
+	}{
+		{name: "default", want: []string{"api", "worker", "auth"}},
+		{name: "subset", functions: "auth,api", want: []string{"auth", "api"}},
+		{name: "spaces and repeats", functions: " worker , ,worker", want: []string{"worker"}},
+		{name: "unknown function", functions: "api,billing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/?functions="+url.QueryEscape(tt.functions), nil)
+			got, err := h.SelectLambdaFunctions(req, "app")
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("SelectLambdaFunctions = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectLambdaFunctions: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectLambdaFunctions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLambdaChartsRejectUnknownFunctions(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	h := &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"app": {ID: "app", LambdaFunctions: []string{"api"}},
+		}},
+		Logger: logger,
+	}
+	echarts := NewEChartsHandler(h, 0, logger)
+
+	handlers := map[string]http.HandlerFunc{
+		"time series":       NewTimeSeriesHandler(h, 0, logger).GetLambdaTimeSeries,
+		"ECharts metrics":   echarts.GetLambdaMetricsECharts,
+		"ECharts functions": echarts.GetLambdaFunctionsECharts,
+	}
+	for name, handler := range handlers {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/?functions=billing", nil), map[string]string{"appId": "app"})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestParseRateUnit(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "", want: ""},
+		{query: "?rate=minute", want: aws.RatePerMinute},
+		{query: "?rate=second", want: aws.RatePerSecond},
+		{query: "?rate=hour", wantErr: true},
+		{query: "?rate=Minute", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRateUnit(httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parseRateUnit(%q) = %q, %v, want %q with error %v", tt.query, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMetricsRejectUnknownRateUnit(t *testing.T) {
+	h := &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"app": {ID: "app", LambdaFunctions: []string{"api"}, APIGateway: "orders-api"},
+		}},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	// Rejected before CloudWatch, which this handler doesn't have, is called
+	handlers := map[string]http.HandlerFunc{
+		"Lambda":      h.GetLambdaMetrics,
+		"API Gateway": h.GetAPIGatewayMetrics,
+	}
+	for name, handler := range handlers {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/?rate=hour", nil), map[string]string{"appId": "app"})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestCostScopeIsPerApp(t *testing.T) {
+	h := &AppHandler{AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+		"tagged":   {ID: "tagged", CostTag: "app=tagged"},
+		"both":     {ID: "both", CostCategory: "App=both", CostTag: "app=both"},
+		"unscoped": {ID: "unscoped"},
+	}}}
+
+	tests := []struct {
+		appID        string
+		wantCategory *aws.CostCategoryFilter
+		wantTag      *aws.CostTagFilter
+	}{
+		{appID: "tagged", wantTag: &aws.CostTagFilter{Key: "app", Value: "tagged"}},
+		{appID: "both", wantCategory: &aws.CostCategoryFilter{Name: "App", Value: "both"}, wantTag: &aws.CostTagFilter{Key: "app", Value: "both"}},
+		{appID: "unscoped"},
+	}
+
+	for _, tt := range tests {
+		category, tag := h.costScope(tt.appID)
+		if !reflect.DeepEqual(category, tt.wantCategory) || !reflect.DeepEqual(tag, tt.wantTag) {
+			t.Errorf("costScope(%q) = %+v, %+v, want %+v, %+v", tt.appID, category, tag, tt.wantCategory, tt.wantTag)
+		}
+	}
+}
+
+func TestGetConcurrencyDiagnostics(t *testing.T) {
+	limiter := aws.NewConcurrencyLimiter(3)
+	release, err := limiter.Acquire(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer release()
+
+	tests := []struct {
+		name    string
+		limiter *aws.ConcurrencyLimiter
+		want    []aws.AppConcurrency
+	}{
+		{name: "limited", limiter: limiter, want: []aws.AppConcurrency{{AppID: "app", InFlight: 1, Limit: 3}}},
+		{name: "unlimited", want: []aws.AppConcurrency{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &AppHandler{Concurrency: tt.limiter}
+			rec := httptest.NewRecorder()
+			h.GetConcurrencyDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics/concurrency", nil))
+
+			var body struct {
+				Apps []aws.AppConcurrency `json:"apps"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if !reflect.DeepEqual(body.Apps, tt.want) {
+				t.Errorf("apps = %+v, want %+v", body.Apps, tt.want)
+			}
+		})
+	}
+}
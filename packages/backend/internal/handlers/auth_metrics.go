@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// GetAuthDiagnostics reports authentication attempt counts by outcome
+func (h *AppHandler) GetAuthDiagnostics(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"outcomes":  h.AuthMetrics.Snapshot(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetPrometheusMetrics exposes authentication and cache counters for Prometheus scraping
+func (h *AppHandler) GetPrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", prometheusContentType)
+	if err := h.AuthMetrics.WritePrometheus(w); err != nil {
+		h.Logger.Warn("Failed to write Prometheus metrics", "error", err)
+		return
+	}
+	if err := aws.WriteCachePrometheus(w, h.cacheSnapshots()); err != nil {
+		h.Logger.Warn("Failed to write Prometheus metrics", "error", err)
+	}
+}
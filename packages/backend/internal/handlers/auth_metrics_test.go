@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+const testJWTSecret = "test-secret-key-of-at-least-32-bytes"
+
+// signTestToken issues an access token for user from a manager with the given secret and TTL
+func signTestToken(t *testing.T, secret string, ttl time.Duration, user *auth.AppleUserInfo) string {
+	t.Helper()
+	token, err := auth.NewJWTManager([]byte(secret), "central-analytics", ttl).GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	return token
+}
+
+func TestAuthMiddlewareCountsOutcomes(t *testing.T) {
+	admin := &auth.AppleUserInfo{Sub: "admin", IsAdmin: true}
+	viewer := &auth.AppleUserInfo{Sub: "viewer"}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		want          auth.AuthOutcome
+	}{
+		{name: "valid admin", authorization: "Bearer " + signTestToken(t, testJWTSecret, time.Hour, admin), wantStatus: http.StatusOK, want: auth.OutcomeSuccess},
+		{name: "non-admin", authorization: "Bearer " + signTestToken(t, testJWTSecret, time.Hour, viewer), wantStatus: http.StatusForbidden, want: auth.OutcomeForbidden},
+		{name: "expired", authorization: "Bearer " + signTestToken(t, testJWTSecret, -time.Hour, admin), wantStatus: http.StatusUnauthorized, want: auth.OutcomeExpired},
+		{name: "bad signature", authorization: "Bearer " + signTestToken(t, "another-secret-key-of-at-least-32-bytes", time.Hour, admin), wantStatus: http.StatusUnauthorized, want: auth.OutcomeInvalidSignature},
+		{name: "malformed token", authorization: "Bearer not-a-jwt", wantStatus: http.StatusUnauthorized, want: auth.OutcomeMalformed},
+		{name: "not a bearer token", authorization: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized, want: auth.OutcomeMalformed},
+		{name: "missing", wantStatus: http.StatusUnauthorized, want: auth.OutcomeMissingToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &AppHandler{
+				JWTManager:  auth.NewJWTManager([]byte(testJWTSecret), "central-analytics", time.Hour),
+				AuthMetrics: auth.NewAuthMetrics(),
+				AppsConfig:  &appconfig.AppsConfiguration{},
+				Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			next := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+			req := httptest.NewRequest(http.MethodGet, "/api/apps", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			h.AuthMiddleware(next)(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			for outcome, count := range h.AuthMetrics.Snapshot() {
+				want := int64(0)
+				if outcome == tt.want {
+					want = 1
+				}
+				if count != want {
+					t.Errorf("%s = %d, want %d", outcome, count, want)
+				}
+			}
+
+			// The diagnostics and Prometheus endpoints report the same counters
+			rec = httptest.NewRecorder()
+			h.GetAuthDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics/auth", nil))
+			var diagnostics struct {
+				Outcomes map[auth.AuthOutcome]int64 `json:"outcomes"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &diagnostics); err != nil {
+				t.Fatalf("decode diagnostics: %v", err)
+			}
+			if diagnostics.Outcomes[tt.want] != 1 {
+				t.Errorf("diagnostics outcomes = %v, want one %s", diagnostics.Outcomes, tt.want)
+			}
+
+			rec = httptest.NewRecorder()
+			h.GetPrometheusMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			if line := `central_analytics_auth_attempts_total{outcome="` + string(tt.want) + `"} 1`; !strings.Contains(rec.Body.String(), line) {
+				t.Errorf("Prometheus metrics are missing %q", line)
+			}
+		})
+	}
+}
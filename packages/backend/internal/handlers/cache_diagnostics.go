@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// cacheSnapshots collects counters from every configured cache
+func (h *AppHandler) cacheSnapshots() []aws.CacheSnapshot {
+	caches := []aws.CacheSnapshot{}
+	if h.Tagging != nil {
+		caches = append(caches, h.Tagging.CacheStats())
+	}
+	if h.LambdaConfig != nil {
+		caches = append(caches, h.LambdaConfig.CacheStats())
+	}
+	return caches
+}
+
+// GetCacheDiagnostics reports hit, miss and eviction counts per cache so TTLs can be tuned
+func (h *AppHandler) GetCacheDiagnostics(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"caches":    h.cacheSnapshots(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
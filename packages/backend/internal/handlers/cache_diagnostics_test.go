@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+func TestGetCacheDiagnostics(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   *AppHandler
+		wantNames []string
+	}{
+		{name: "no caches", handler: &AppHandler{}, wantNames: []string{}},
+		{
+			name:      "tagging and Lambda config",
+			handler:   &AppHandler{Tagging: aws.NewResourceTaggingClient(awssdk.Config{}), LambdaConfig: aws.NewLambdaConfigClient(awssdk.Config{})},
+			wantNames: []string{"tagged_functions", "lambda_memory"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tt.handler.GetCacheDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics/cache", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+
+			var body struct {
+				Caches []aws.CacheSnapshot `json:"caches"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body.Caches == nil {
+				t.Fatal("caches = null, want an array")
+			}
+			if len(body.Caches) != len(tt.wantNames) {
+				t.Fatalf("caches = %+v, want %v", body.Caches, tt.wantNames)
+			}
+			for i, cache := range body.Caches {
+				if cache.Name != tt.wantNames[i] || cache.Hits != 0 || cache.Misses != 0 {
+					t.Errorf("cache %d = %+v, want %s with no lookups", i, cache, tt.wantNames[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPrometheusMetricsIncludeCaches(t *testing.T) {
+	h := &AppHandler{
+		AuthMetrics: auth.NewAuthMetrics(),
+		Tagging:     aws.NewResourceTaggingClient(awssdk.Config{}),
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetPrometheusMetrics(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`central_analytics_cache_hits_total{cache="tagged_functions"} 0`,
+		`central_analytics_cache_misses_total{cache="tagged_functions"} 0`,
+	} {
+		if !strings.Contains(rec.Body.String(), line) {
+			t.Errorf("metrics are missing %q:\n%s", line, rec.Body)
+		}
+	}
+}
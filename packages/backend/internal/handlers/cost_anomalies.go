@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// GetCostAnomalies lists days of abnormally high spend found by Cost Anomaly Detection. Without
+// an anomaly monitor it returns an empty list flagged unavailable rather than an error.
+func (h *AppHandler) GetCostAnomalies(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	// Parse time range
+	startTime, endTime := parseTimeRange(r)
+
+	metadata := map[string]interface{}{
+		"appId":     appID,
+		"period":    timerange.NewDatePeriod(startTime, endTime),
+		"available": true,
+	}
+
+	anomalies, err := h.CostExplorer.GetCostAnomalies(r.Context(), startTime, endTime)
+	if errors.Is(err, aws.ErrNoAnomalyMonitor) {
+		anomalies = []aws.CostAnomaly{}
+		metadata["available"] = false
+		metadata["error"] = "No Cost Anomaly Detection monitor configured"
+	} else if err != nil {
+		writeError(w, fmt.Sprintf("Failed to get cost anomalies: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"appId":     appID,
+		"anomalies": anomalies,
+		"metadata":  metadata,
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
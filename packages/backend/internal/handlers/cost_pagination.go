@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// Daily cost page sizes, in days
+const (
+	defaultCostPageSize = 90
+	maxCostPageSize     = 366
+)
+
+// costDateLayout is the layout of daily cost dates and of the cursors that point at them
+const costDateLayout = "2006-01-02"
+
+// costPage selects a page of a daily cost series. Cursor is the date of the page's first day;
+// empty starts at the beginning of the series.
+type costPage struct {
+	Cursor string `json:"cursor"`
+	Limit  int    `json:"limit"`
+}
+
+// parseCostPage reads the cursor and limit parameters. ok is false when neither is set, in
+// which case the whole series is returned as before pagination existed.
+func parseCostPage(r *http.Request) (page costPage, ok bool, err error) {
+	query := r.URL.Query()
+	page = costPage{Cursor: query.Get("cursor"), Limit: defaultCostPageSize}
+
+	if page.Cursor != "" {
+		if _, err := time.Parse(costDateLayout, page.Cursor); err != nil {
+			return page, true, fmt.Errorf("cursor must be a date in YYYY-MM-DD format")
+		}
+	}
+
+	value := query.Get("limit")
+	if value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxCostPageSize {
+			return page, true, fmt.Errorf("limit must be an integer from 1 to %d", maxCostPageSize)
+		}
+		page.Limit = limit
+	}
+
+	return page, page.Cursor != "" || value != "", nil
+}
+
+// paginateDailyCosts returns the days of daily, which is oldest first, from the cursor's date
+// up to the page limit, and the cursor of the page after it, empty on the last page. Pages
+// follow on from one another, so walking the cursors visits every day exactly once.
+func paginateDailyCosts(daily []aws.DailyCost, page costPage) ([]aws.DailyCost, string) {
+	first := 0
+	if page.Cursor != "" {
+		first = sort.Search(len(daily), func(i int) bool { return daily[i].Date >= page.Cursor })
+	}
+	last := min(first+page.Limit, len(daily))
+
+	nextCursor := ""
+	if last < len(daily) {
+		nextCursor = daily[last].Date
+	}
+	return daily[first:last], nextCursor
+}
+
+// dailyCostResponse builds the daily cost response, holding one page of the series when
+// paginated is set. Totals describe the whole range whichever page is returned.
+func dailyCostResponse(appID string, startTime, endTime time.Time, costData *aws.CostData, page costPage, paginated bool) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"appId":     appID,
+		"period":    timerange.NewPeriod(startTime, endTime),
+		"totalCost": costData.TotalCost,
+		"currency":  costData.Currency,
+		"totalDays": len(costData.DailyCosts),
+	}
+	response := map[string]interface{}{
+		"data":     costData.DailyCosts,
+		"metadata": metadata,
+	}
+
+	if paginated {
+		data, nextCursor := paginateDailyCosts(costData.DailyCosts, page)
+		response["data"] = data
+		response["nextCursor"] = nextCursor
+		metadata["page"] = page
+	}
+	return response
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// dailyCostSeries returns one cost per day from start for days days, each costing its index
+func dailyCostSeries(start time.Time, days int) []aws.DailyCost {
+	daily := make([]aws.DailyCost, days)
+	for i := range daily {
+		daily[i] = aws.DailyCost{Date: start.AddDate(0, 0, i).Format(costDateLayout), Cost: float64(i)}
+	}
+	return daily
+}
+
+func TestPaginateDailyCostsVisitsEveryDayOnce(t *testing.T) {
+	// Three years, leap day included
+	start := time.Date(2022, 3, 15, 0, 0, 0, 0, time.UTC)
+	daily := dailyCostSeries(start, 1096)
+
+	for _, limit := range []int{1, 90, 97, maxCostPageSize, 1096} {
+		page := costPage{Limit: limit}
+		var visited []aws.DailyCost
+		pages := 0
+		for {
+			data, next := paginateDailyCosts(daily, page)
+			pages++
+			if len(data) == 0 || len(data) > limit {
+				t.Fatalf("limit %d: page %d has %d days", limit, pages, len(data))
+			}
+			if page.Cursor != "" && data[0].Date != page.Cursor {
+				t.Fatalf("limit %d: page %d starts on %s, want its cursor %s", limit, pages, data[0].Date, page.Cursor)
+			}
+			visited = append(visited, data...)
+			if next == "" {
+				break
+			}
+			if next <= data[len(data)-1].Date {
+				t.Fatalf("limit %d: cursor %s doesn't follow the page ending %s", limit, next, data[len(data)-1].Date)
+			}
+			page.Cursor = next
+		}
+
+		if wantPages := (len(daily) + limit - 1) / limit; pages != wantPages {
+			t.Errorf("limit %d: %d pages, want %d", limit, pages, wantPages)
+		}
+		if len(visited) != len(daily) {
+			t.Fatalf("limit %d: visited %d days, want %d", limit, len(visited), len(daily))
+		}
+		for i := range daily {
+			if visited[i] != daily[i] {
+				t.Fatalf("limit %d: day %d = %+v, want %+v", limit, i, visited[i], daily[i])
+			}
+		}
+	}
+}
+
+func TestPaginateDailyCostsCursorBetweenDays(t *testing.T) {
+	// Cost Explorer omits days with no usage, so a cursor can fall between reported days
+	daily := []aws.DailyCost{{Date: "2024-01-01"}, {Date: "2024-01-02"}, {Date: "2024-01-05"}, {Date: "2024-01-06"}}
+
+	tests := []struct {
+		cursor   string
+		wantDays []string
+		wantNext string
+	}{
+		{cursor: "2024-01-03", wantDays: []string{"2024-01-05", "2024-01-06"}},
+		{cursor: "2023-12-01", wantDays: []string{"2024-01-01", "2024-01-02"}, wantNext: "2024-01-05"},
+		{cursor: "2024-02-01"},
+	}
+	for _, tt := range tests {
+		data, next := paginateDailyCosts(daily, costPage{Cursor: tt.cursor, Limit: 2})
+		var days []string
+		for _, day := range data {
+			days = append(days, day.Date)
+		}
+		if len(days) != len(tt.wantDays) || next != tt.wantNext {
+			t.Errorf("cursor %s: days %v, next %q, want %v, %q", tt.cursor, days, next, tt.wantDays, tt.wantNext)
+			continue
+		}
+		for i := range days {
+			if days[i] != tt.wantDays[i] {
+				t.Errorf("cursor %s: days %v, want %v", tt.cursor, days, tt.wantDays)
+				break
+			}
+		}
+	}
+}
+
+func TestDailyCostResponseTotalsCoverTheRange(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(2, 0, 0)
+	costData := &aws.CostData{TotalCost: 1234.5, Currency: "USD", DailyCosts: dailyCostSeries(start, 730)}
+
+	response := dailyCostResponse("app", start, end, costData, costPage{Cursor: "2023-06-01", Limit: 30}, true)
+
+	data := response["data"].([]aws.DailyCost)
+	if len(data) != 30 || data[0].Date != "2023-06-01" {
+		t.Errorf("page = %d days from %s, want 30 from 2023-06-01", len(data), data[0].Date)
+	}
+	if next := response["nextCursor"]; next != "2023-07-01" {
+		t.Errorf("nextCursor = %v, want 2023-07-01", next)
+	}
+	metadata := response["metadata"].(map[string]interface{})
+	if metadata["totalCost"] != 1234.5 || metadata["totalDays"] != 730 {
+		t.Errorf("totals = %v over %v days, want the whole range's 1234.5 over 730", metadata["totalCost"], metadata["totalDays"])
+	}
+
+	// Without paging parameters the whole series is returned, with no cursor
+	response = dailyCostResponse("app", start, end, costData, costPage{Limit: defaultCostPageSize}, false)
+	if data := response["data"].([]aws.DailyCost); len(data) != 730 {
+		t.Errorf("unpaginated response has %d days, want 730", len(data))
+	}
+	if _, ok := response["nextCursor"]; ok {
+		t.Error("unpaginated response has a cursor")
+	}
+}
+
+func TestParseCostPage(t *testing.T) {
+	tests := []struct {
+		query         string
+		want          costPage
+		wantPaginated bool
+		wantErr       bool
+	}{
+		{query: "", want: costPage{Limit: defaultCostPageSize}},
+		{query: "limit=30", want: costPage{Limit: 30}, wantPaginated: true},
+		{query: "cursor=2024-02-29", want: costPage{Cursor: "2024-02-29", Limit: defaultCostPageSize}, wantPaginated: true},
+		{query: "cursor=2024-02-29&limit=366", want: costPage{Cursor: "2024-02-29", Limit: 366}, wantPaginated: true},
+		{query: "cursor=2023-02-29", wantErr: true},
+		{query: "cursor=yesterday", wantErr: true},
+		{query: "limit=0", wantErr: true},
+		{query: "limit=367", wantErr: true},
+		{query: "limit=ten", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/cost/daily?"+tt.query, nil)
+		page, paginated, err := parseCostPage(req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCostPage(%q) = %+v, want an error", tt.query, page)
+			}
+			continue
+		}
+		if err != nil || page != tt.want || paginated != tt.wantPaginated {
+			t.Errorf("parseCostPage(%q) = %+v, %v, %v, want %+v, %v", tt.query, page, paginated, err, tt.want, tt.wantPaginated)
+		}
+	}
+}
+
+func TestGetCostDailyEChartsRejectsBadPage(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := NewEChartsHandler(&AppHandler{Logger: logger}, 0, logger)
+
+	for _, query := range []string{"cursor=2024-13-01", "limit=1000"} {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/cost/daily?"+query, nil), map[string]string{"appId": "app"})
+		rec := httptest.NewRecorder()
+		handler.GetCostDailyECharts(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
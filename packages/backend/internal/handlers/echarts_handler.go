@@ -2,26 +2,30 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
 	"net/http"
 	"sort"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 )
 
 // EChartsHandler formats data specifically for ECharts visualization
 type EChartsHandler struct {
 	appHandler *AppHandler
 	logger     *slog.Logger
+	cache      *metricsCache
 }
 
-// NewEChartsHandler creates a new ECharts data handler
-func NewEChartsHandler(appHandler *AppHandler, logger *slog.Logger) *EChartsHandler {
+// NewEChartsHandler creates a new ECharts data handler. Responses served through Cached live
+// for cacheTTL; zero disables caching.
+func NewEChartsHandler(appHandler *AppHandler, cacheTTL time.Duration, logger *slog.Logger) *EChartsHandler {
 	return &EChartsHandler{
 		appHandler: appHandler,
 		logger:     logger,
+		cache:      newMetricsCache(cacheTTL),
 	}
 }
 
@@ -37,6 +41,42 @@ type EChartsDataPoint struct {
 	Value     float64 `json:"value"`
 }
 
+// bucketDatapoints sums datapoints from several resources into buckets of the given width,
+// aligned to startTime so they line up with CloudWatch's periods. Buckets are ordered by time
+// before their timestamps are formatted.
+func bucketDatapoints(series [][]aws.MetricDatapoint, startTime time.Time, bucket time.Duration) []EChartsDataPoint {
+	if bucket <= 0 {
+		bucket = time.Minute
+	}
+
+	sums := make(map[time.Time]float64)
+	for _, datapoints := range series {
+		for _, dp := range datapoints {
+			offset := dp.Timestamp.Sub(startTime)
+			index := offset / bucket
+			if offset < 0 && offset%bucket != 0 {
+				index--
+			}
+			sums[startTime.Add(index*bucket)] += dp.Value
+		}
+	}
+
+	buckets := make([]time.Time, 0, len(sums))
+	for bucketStart := range sums {
+		buckets = append(buckets, bucketStart)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Before(buckets[j]) })
+
+	dataPoints := make([]EChartsDataPoint, 0, len(buckets))
+	for _, bucketStart := range buckets {
+		dataPoints = append(dataPoints, EChartsDataPoint{
+			Timestamp: bucketStart.UTC().Format("2006-01-02T15:04:05Z"),
+			Value:     sums[bucketStart],
+		})
+	}
+	return dataPoints
+}
+
 // GetLambdaMetricsECharts returns Lambda metrics formatted for ECharts
 func (h *EChartsHandler) GetLambdaMetricsECharts(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -50,44 +90,25 @@ func (h *EChartsHandler) GetLambdaMetricsECharts(w http.ResponseWriter, r *http.
 	// Parse time range
 	startTime, endTime := parseTimeRange(r)
 
-	// Get Lambda functions for the app
-	lambdaFunctions := h.appHandler.AppsConfig.GetLambdaFunctions(appID)
-
-	// Collect all data points across functions
-	dataPointsMap := make(map[time.Time]float64)
-
-	for _, functionName := range lambdaFunctions {
-		metrics, err := h.appHandler.CloudWatch.GetLambdaMetrics(context.Background(), functionName, startTime, endTime)
-		if err != nil {
-			continue
-		}
-
-		// Aggregate datapoints
-		for _, dp := range metrics.Datapoints {
-			// Round timestamp to nearest 5 minutes for aggregation
-			roundedTime := dp.Timestamp.Round(5 * time.Minute)
-			dataPointsMap[roundedTime] += dp.Value
-		}
+	// Get Lambda functions for the app, optionally narrowed by the functions parameter
+	lambdaFunctions, err := h.appHandler.SelectLambdaFunctions(r, appID)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// Convert map to sorted slice
-	var dataPoints []EChartsDataPoint
-	for timestamp, value := range dataPointsMap {
-		dataPoints = append(dataPoints, EChartsDataPoint{
-			Timestamp: timestamp.Format("2006-01-02T15:04:05Z"),
-			Value:     value,
-		})
+	batch, err := h.appHandler.CloudWatch.GetLambdaMetricsBatch(r.Context(), lambdaFunctions, startTime, endTime)
+	if err != nil {
+		h.logger.Warn("Failed to get Lambda metrics", "error", err)
 	}
 
-	// Sort by timestamp
-	sort.Slice(dataPoints, func(i, j int) bool {
-		return dataPoints[i].Timestamp < dataPoints[j].Timestamp
-	})
-
-	// Ensure we return an empty array instead of null
-	if dataPoints == nil {
-		dataPoints = []EChartsDataPoint{}
+	// Sum datapoints across functions in buckets of the period they were queried at
+	bucket := time.Duration(aws.MetricPeriod(r.Context(), startTime, endTime)) * time.Second
+	var series [][]aws.MetricDatapoint
+	for _, metrics := range batch {
+		series = append(series, metrics.Datapoints)
 	}
+	dataPoints := bucketDatapoints(series, startTime, bucket)
 
 	response := EChartsResponse{
 		Data: dataPoints,
@@ -95,13 +116,12 @@ func (h *EChartsHandler) GetLambdaMetricsECharts(w http.ResponseWriter, r *http.
 			"appId":      appID,
 			"metricType": "lambda:" + metricType,
 			"functions":  lambdaFunctions,
-			"period":     formatPeriod(startTime, endTime),
+			"period":     timerange.NewPeriod(startTime, endTime),
 			"unit":       h.getMetricUnit(metricType),
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetAPIGatewayMetricsECharts returns API Gateway metrics formatted for ECharts
@@ -120,13 +140,13 @@ func (h *EChartsHandler) GetAPIGatewayMetricsECharts(w http.ResponseWriter, r *h
 	// Get API Gateway name
 	apiName := h.appHandler.AppsConfig.GetAPIGateway(appID)
 	if apiName == "" {
-		http.Error(w, "No API Gateway configured for this app", http.StatusNotFound)
+		writeError(w, "No API Gateway configured for this app", http.StatusNotFound)
 		return
 	}
 
 	metrics, err := h.appHandler.CloudWatch.GetAPIGatewayMetrics(context.Background(), apiName, startTime, endTime)
 	if err != nil {
-		http.Error(w, "Failed to get API Gateway metrics", http.StatusInternalServerError)
+		writeError(w, "Failed to get API Gateway metrics", http.StatusInternalServerError)
 		return
 	}
 
@@ -155,13 +175,12 @@ func (h *EChartsHandler) GetAPIGatewayMetricsECharts(w http.ResponseWriter, r *h
 			"appId":      appID,
 			"metricType": "apigateway:" + metricType,
 			"apiName":    apiName,
-			"period":     formatPeriod(startTime, endTime),
+			"period":     timerange.NewPeriod(startTime, endTime),
 			"unit":       h.getAPIGatewayUnit(metricType),
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetDynamoDBMetricsECharts returns DynamoDB metrics formatted for ECharts
@@ -180,41 +199,19 @@ func (h *EChartsHandler) GetDynamoDBMetricsECharts(w http.ResponseWriter, r *htt
 	// Get DynamoDB tables
 	tables := h.appHandler.AppsConfig.GetDynamoDBTables(appID)
 
-	// Collect all data points across tables
-	dataPointsMap := make(map[time.Time]float64)
-
+	// Collect datapoints across tables
+	var series [][]aws.MetricDatapoint
 	for _, tableName := range tables {
-		metrics, err := h.appHandler.DynamoDB.GetTableMetrics(context.Background(), tableName, startTime, endTime)
+		metrics, err := h.appHandler.DynamoDB.GetTableMetrics(r.Context(), tableName, startTime, endTime)
 		if err != nil {
 			continue
 		}
-
-		// Aggregate datapoints
-		for _, dp := range metrics.Datapoints {
-			// Round timestamp to nearest 5 minutes for aggregation
-			roundedTime := dp.Timestamp.Round(5 * time.Minute)
-			dataPointsMap[roundedTime] += dp.Value
-		}
-	}
-
-	// Convert map to sorted slice
-	var dataPoints []EChartsDataPoint
-	for timestamp, value := range dataPointsMap {
-		dataPoints = append(dataPoints, EChartsDataPoint{
-			Timestamp: timestamp.Format("2006-01-02T15:04:05Z"),
-			Value:     value,
-		})
+		series = append(series, metrics.Datapoints)
 	}
 
-	// Sort by timestamp
-	sort.Slice(dataPoints, func(i, j int) bool {
-		return dataPoints[i].Timestamp < dataPoints[j].Timestamp
-	})
-
-	// Ensure we return an empty array instead of null
-	if dataPoints == nil {
-		dataPoints = []EChartsDataPoint{}
-	}
+	// Sum them in buckets of the period they were queried at
+	bucket := time.Duration(aws.MetricPeriod(r.Context(), startTime, endTime)) * time.Second
+	dataPoints := bucketDatapoints(series, startTime, bucket)
 
 	response := EChartsResponse{
 		Data: dataPoints,
@@ -222,13 +219,12 @@ func (h *EChartsHandler) GetDynamoDBMetricsECharts(w http.ResponseWriter, r *htt
 			"appId":      appID,
 			"metricType": "dynamodb:" + metricType,
 			"tables":     tables,
-			"period":     formatPeriod(startTime, endTime),
+			"period":     timerange.NewPeriod(startTime, endTime),
 			"unit":       h.getDynamoDBUnit(metricType),
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetCostMetricsECharts returns cost metrics formatted for ECharts
@@ -240,9 +236,9 @@ func (h *EChartsHandler) GetCostMetricsECharts(w http.ResponseWriter, r *http.Re
 	startTime, endTime := parseTimeRange(r)
 
 	// Get cost data
-	costData, err := h.appHandler.CostExplorer.GetCostAndUsage(context.Background(), startTime, endTime)
+	costData, err := h.appHandler.GetAppCosts(context.Background(), appID, startTime, endTime)
 	if err != nil {
-		http.Error(w, "Failed to get cost data", http.StatusInternalServerError)
+		writeError(w, "Failed to get cost data", http.StatusInternalServerError)
 		return
 	}
 
@@ -274,7 +270,7 @@ func (h *EChartsHandler) GetCostMetricsECharts(w http.ResponseWriter, r *http.Re
 		Metadata: map[string]interface{}{
 			"appId":            appID,
 			"metricType":       "cost:daily",
-			"period":           formatPeriod(startTime, endTime),
+			"period":           timerange.NewPeriod(startTime, endTime),
 			"unit":             "USD",
 			"totalCost":        totalCost,
 			"avgDailyCost":     avgDailyCost,
@@ -283,8 +279,7 @@ func (h *EChartsHandler) GetCostMetricsECharts(w http.ResponseWriter, r *http.Re
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetAppStoreMetricsECharts returns App Store metrics formatted for ECharts
@@ -298,7 +293,7 @@ func (h *EChartsHandler) GetAppStoreMetricsECharts(w http.ResponseWriter, r *htt
 	}
 
 	if h.appHandler.AppStore == nil {
-		http.Error(w, "App Store Connect not configured", http.StatusServiceUnavailable)
+		writeError(w, "App Store Connect not configured", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -308,13 +303,13 @@ func (h *EChartsHandler) GetAppStoreMetricsECharts(w http.ResponseWriter, r *htt
 	// Get App Store analytics
 	appStoreID := h.appHandler.AppsConfig.GetAppStoreID(appID)
 	if appStoreID == "" {
-		http.Error(w, "No App Store ID configured for this app", http.StatusNotFound)
+		writeError(w, "No App Store ID configured for this app", http.StatusNotFound)
 		return
 	}
 
 	analytics, err := h.appHandler.AppStore.GetAppAnalytics(context.Background(), appStoreID, startTime, endTime)
 	if err != nil {
-		http.Error(w, "Failed to get App Store analytics", http.StatusInternalServerError)
+		writeAppStoreError(w, "Failed to get App Store analytics", err)
 		return
 	}
 
@@ -339,14 +334,13 @@ func (h *EChartsHandler) GetAppStoreMetricsECharts(w http.ResponseWriter, r *htt
 			"appId":      appID,
 			"metricType": "appstore:" + metricType,
 			"appName":    analytics.AppName,
-			"period":     formatPeriod(startTime, endTime),
+			"period":     timerange.NewPeriod(startTime, endTime),
 			"unit":       h.getAppStoreUnit(metricType),
 			"ratings":    analytics.Ratings,
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetLambdaTimeSeriesECharts returns Lambda time series data formatted for ECharts
@@ -363,36 +357,53 @@ func (h *EChartsHandler) GetLambdaFunctionsECharts(w http.ResponseWriter, r *htt
 	// Parse time range
 	startTime, endTime := parseTimeRange(r)
 
-	// Get Lambda functions for the app
-	lambdaFunctions := h.appHandler.AppsConfig.GetLambdaFunctions(appID)
+	// Get Lambda functions for the app, optionally narrowed by the functions parameter
+	lambdaFunctions, err := h.appHandler.SelectLambdaFunctions(r, appID)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	type FunctionMetrics struct {
 		Name        string  `json:"name"`
 		Invocations float64 `json:"invocations"`
 		Errors      float64 `json:"errors"`
 		Duration    float64 `json:"duration"`
+		MemoryMB    int     `json:"memoryMb,omitempty"`
+		GBSeconds   float64 `json:"gbSeconds"`
 		Cost        float64 `json:"cost"`
 	}
 
-	var functionsData []FunctionMetrics
+	functionsData := []FunctionMetrics{}
+
+	batch, err := h.appHandler.CloudWatch.GetLambdaMetricsBatch(context.Background(), lambdaFunctions, startTime, endTime)
+	if err != nil {
+		h.logger.Warn("Failed to get Lambda metrics", "error", err)
+	}
 
 	for _, functionName := range lambdaFunctions {
-		metrics, err := h.appHandler.CloudWatch.GetLambdaMetrics(context.Background(), functionName, startTime, endTime)
-		if err != nil {
+		metrics, ok := batch[functionName]
+		if !ok {
 			continue
 		}
 
-		var invocations, errors, duration float64
-		for _, dp := range metrics.Datapoints {
-			invocations += dp.Value
+		// Without the configured memory only the request charge can be priced
+		var memoryMB int
+		if h.appHandler.LambdaConfig != nil {
+			memoryMB, err = h.appHandler.LambdaConfig.GetMemorySize(context.Background(), functionName)
+			if err != nil {
+				h.logger.Warn("Failed to get Lambda memory size", "function", functionName, "error", err)
+			}
 		}
 
 		functionsData = append(functionsData, FunctionMetrics{
 			Name:        functionName,
-			Invocations: invocations,
-			Errors:      errors,
-			Duration:    duration / invocations,  // Average duration
-			Cost:        invocations * 0.0000002, // Rough Lambda cost estimate
+			Invocations: metrics.Invocations,
+			Errors:      metrics.Errors,
+			Duration:    metrics.Duration,
+			MemoryMB:    memoryMB,
+			GBSeconds:   aws.GBSeconds(memoryMB, metrics.Duration, metrics.Invocations),
+			Cost:        h.appHandler.LambdaPricing.EstimateCost(metrics.Invocations, metrics.Duration, memoryMB),
 		})
 	}
 
@@ -400,12 +411,11 @@ func (h *EChartsHandler) GetLambdaFunctionsECharts(w http.ResponseWriter, r *htt
 		"data": functionsData,
 		"metadata": map[string]interface{}{
 			"appId":  appID,
-			"period": formatPeriod(startTime, endTime),
+			"period": timerange.NewPeriod(startTime, endTime),
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetCostBreakdownECharts returns cost breakdown by service
@@ -417,9 +427,9 @@ func (h *EChartsHandler) GetCostBreakdownECharts(w http.ResponseWriter, r *http.
 	startTime, endTime := parseTimeRange(r)
 
 	// Get cost data
-	costData, err := h.appHandler.CostExplorer.GetCostAndUsage(context.Background(), startTime, endTime)
+	costData, err := h.appHandler.GetAppCosts(context.Background(), appID, startTime, endTime)
 	if err != nil {
-		http.Error(w, "Failed to get cost data", http.StatusInternalServerError)
+		writeError(w, "Failed to get cost data", http.StatusInternalServerError)
 		return
 	}
 
@@ -436,16 +446,16 @@ func (h *EChartsHandler) GetCostBreakdownECharts(w http.ResponseWriter, r *http.
 		"data": breakdown,
 		"metadata": map[string]interface{}{
 			"appId":     appID,
-			"period":    formatPeriod(startTime, endTime),
+			"period":    timerange.NewPeriod(startTime, endTime),
 			"totalCost": costData.TotalCost,
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
-// GetCostDailyECharts returns daily cost data
+// GetCostDailyECharts returns daily cost data. Long ranges can be paged through by date with
+// ?limit= and the nextCursor of each response passed back as ?cursor=.
 func (h *EChartsHandler) GetCostDailyECharts(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	appID := vars["appId"]
@@ -453,23 +463,20 @@ func (h *EChartsHandler) GetCostDailyECharts(w http.ResponseWriter, r *http.Requ
 	// Parse time range
 	startTime, endTime := parseTimeRange(r)
 
-	// Get cost data
-	costData, err := h.appHandler.CostExplorer.GetCostAndUsage(context.Background(), startTime, endTime)
+	page, paginated, err := parseCostPage(r)
 	if err != nil {
-		http.Error(w, "Failed to get cost data", http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	response := map[string]interface{}{
-		"data": costData.DailyCosts,
-		"metadata": map[string]interface{}{
-			"appId":  appID,
-			"period": formatPeriod(startTime, endTime),
-		},
+	// Get cost data
+	costData, err := h.appHandler.GetAppCosts(context.Background(), appID, startTime, endTime)
+	if err != nil {
+		writeError(w, "Failed to get cost data", http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, dailyCostResponse(appID, startTime, endTime, costData, page, paginated))
 }
 
 // GetCostProjectionECharts returns cost projection data
@@ -481,9 +488,9 @@ func (h *EChartsHandler) GetCostProjectionECharts(w http.ResponseWriter, r *http
 	endTime := time.Now()
 	startTime := endTime.AddDate(0, 0, -30)
 
-	costData, err := h.appHandler.CostExplorer.GetCostAndUsage(context.Background(), startTime, endTime)
+	costData, err := h.appHandler.GetAppCosts(context.Background(), appID, startTime, endTime)
 	if err != nil {
-		http.Error(w, "Failed to get cost data", http.StatusInternalServerError)
+		writeError(w, "Failed to get cost data", http.StatusInternalServerError)
 		return
 	}
 
@@ -509,8 +516,7 @@ func (h *EChartsHandler) GetCostProjectionECharts(w http.ResponseWriter, r *http
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetCreditPacksECharts returns credit pack sales data formatted for ECharts
@@ -528,13 +534,12 @@ func (h *EChartsHandler) GetCreditPacksECharts(w http.ResponseWriter, r *http.Re
 			"data": []interface{}{},
 			"metadata": map[string]interface{}{
 				"appId":     appID,
-				"period":    formatPeriod(startTime, endTime),
+				"period":    timerange.NewPeriod(startTime, endTime),
 				"error":     "App Store Connect not configured",
 				"available": false,
 			},
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		writeJSON(w, http.StatusOK, response)
 		return
 	}
 
@@ -544,14 +549,13 @@ func (h *EChartsHandler) GetCreditPacksECharts(w http.ResponseWriter, r *http.Re
 		"data": []interface{}{},
 		"metadata": map[string]interface{}{
 			"appId":     appID,
-			"period":    formatPeriod(startTime, endTime),
+			"period":    timerange.NewPeriod(startTime, endTime),
 			"error":     "Credit pack breakdown not available from App Store Connect API",
 			"available": false,
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetGeographicECharts returns geographic distribution data formatted for ECharts
@@ -569,13 +573,12 @@ func (h *EChartsHandler) GetGeographicECharts(w http.ResponseWriter, r *http.Req
 			"data": []interface{}{},
 			"metadata": map[string]interface{}{
 				"appId":     appID,
-				"period":    formatPeriod(startTime, endTime),
+				"period":    timerange.NewPeriod(startTime, endTime),
 				"error":     "App Store Connect not configured",
 				"available": false,
 			},
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		writeJSON(w, http.StatusOK, response)
 		return
 	}
 
@@ -585,14 +588,13 @@ func (h *EChartsHandler) GetGeographicECharts(w http.ResponseWriter, r *http.Req
 		"data": []interface{}{},
 		"metadata": map[string]interface{}{
 			"appId":     appID,
-			"period":    formatPeriod(startTime, endTime),
+			"period":    timerange.NewPeriod(startTime, endTime),
 			"error":     "Geographic distribution not available from App Store Connect API",
 			"available": false,
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // GetEngagementECharts returns user engagement metrics formatted for ECharts
@@ -610,13 +612,12 @@ func (h *EChartsHandler) GetEngagementECharts(w http.ResponseWriter, r *http.Req
 			"data": []interface{}{},
 			"metadata": map[string]interface{}{
 				"appId":     appID,
-				"period":    formatPeriod(startTime, endTime),
+				"period":    timerange.NewPeriod(startTime, endTime),
 				"error":     "App Store Connect not configured",
 				"available": false,
 			},
 		}
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		writeJSON(w, http.StatusOK, response)
 		return
 	}
 
@@ -626,14 +627,13 @@ func (h *EChartsHandler) GetEngagementECharts(w http.ResponseWriter, r *http.Req
 		"data": []interface{}{},
 		"metadata": map[string]interface{}{
 			"appId":     appID,
-			"period":    formatPeriod(startTime, endTime),
+			"period":    timerange.NewPeriod(startTime, endTime),
 			"error":     "User engagement metrics not available from App Store Connect API",
 			"available": false,
 		},
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // Helper functions
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+func TestResponsesCarryStructuredPeriod(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 8, 0, 0, 0, 0, time.UTC)
+	query := "?start=" + start.Format(time.RFC3339) + "&end=" + end.Format(time.RFC3339)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appsConfig := &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+		"period-app": {ID: "period-app", AppStoreID: "1234567890"},
+	}}
+	withAppStore := &AppHandler{
+		AppStore:   &fakeAppStore{analytics: &appstore.AppAnalytics{AppName: "Period App", Downloads: 12}},
+		AppsConfig: appsConfig,
+		Logger:     logger,
+	}
+	withoutAppStore := &AppHandler{AppsConfig: appsConfig, Logger: logger}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "App Store ECharts", handler: NewEChartsHandler(withAppStore, 0, logger).GetAppStoreMetricsECharts},
+		{name: "credit packs ECharts", handler: NewEChartsHandler(withoutAppStore, 0, logger).GetCreditPacksECharts},
+		{name: "subscriptions", handler: withoutAppStore.GetAppStoreSubscriptions},
+	}
+
+	want := timerange.NewPeriod(start, end)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/"+query, nil), map[string]string{"appId": "period-app"})
+			rec := httptest.NewRecorder()
+			tt.handler(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+			}
+			var body struct {
+				Metadata map[string]json.RawMessage `json:"metadata"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if _, ok := body.Metadata["range"]; ok {
+				t.Error("metadata still has a separate range")
+			}
+			var period timerange.Period
+			if err := json.Unmarshal(body.Metadata["period"], &period); err != nil {
+				t.Fatalf("metadata period %s is not a period object: %v", body.Metadata["period"], err)
+			}
+			if period != want {
+				t.Errorf("period = %+v, want %+v", period, want)
+			}
+		})
+	}
+}
+
+func TestBucketDatapoints(t *testing.T) {
+	// Ranges rarely start on a round minute; buckets follow the range, not the clock
+	start := time.Date(2024, 5, 1, 23, 47, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return start.Add(time.Duration(minutes) * time.Minute) }
+	edt := time.FixedZone("EDT", -4*60*60)
+
+	tests := []struct {
+		name   string
+		series [][]aws.MetricDatapoint
+		bucket time.Duration
+		want   []EChartsDataPoint
+	}{
+		{
+			name: "resources sum within a bucket",
+			series: [][]aws.MetricDatapoint{
+				{{Timestamp: at(0), Value: 1}, {Timestamp: at(5), Value: 2}},
+				{{Timestamp: at(0), Value: 10}, {Timestamp: at(5), Value: 20}},
+			},
+			bucket: 5 * time.Minute,
+			want:   []EChartsDataPoint{{Timestamp: "2024-05-01T23:47:00Z", Value: 11}, {Timestamp: "2024-05-01T23:52:00Z", Value: 22}},
+		},
+		{
+			name: "distinct minutes stay apart at a one-minute period",
+			series: [][]aws.MetricDatapoint{
+				{{Timestamp: at(1), Value: 1}},
+				{{Timestamp: at(2), Value: 2}, {Timestamp: at(3), Value: 3}},
+			},
+			bucket: time.Minute,
+			want: []EChartsDataPoint{
+				{Timestamp: "2024-05-01T23:48:00Z", Value: 1},
+				{Timestamp: "2024-05-01T23:49:00Z", Value: 2},
+				{Timestamp: "2024-05-01T23:50:00Z", Value: 3},
+			},
+		},
+		{
+			name: "timestamps inside a bucket align to its start",
+			series: [][]aws.MetricDatapoint{
+				{{Timestamp: at(4), Value: 1}, {Timestamp: at(6), Value: 2}},
+				{{Timestamp: start.Add(-30 * time.Second), Value: 4}},
+			},
+			bucket: 5 * time.Minute,
+			want: []EChartsDataPoint{
+				{Timestamp: "2024-05-01T23:42:00Z", Value: 4},
+				{Timestamp: "2024-05-01T23:47:00Z", Value: 1},
+				{Timestamp: "2024-05-01T23:52:00Z", Value: 2},
+			},
+		},
+		{
+			name: "chronological across midnight",
+			series: [][]aws.MetricDatapoint{
+				{{Timestamp: at(25), Value: 3}, {Timestamp: at(5), Value: 1}},
+				{{Timestamp: at(15), Value: 2}, {Timestamp: at(-1440), Value: 9}},
+			},
+			bucket: 10 * time.Minute,
+			want: []EChartsDataPoint{
+				{Timestamp: "2024-04-30T23:47:00Z", Value: 9},
+				{Timestamp: "2024-05-01T23:47:00Z", Value: 1},
+				{Timestamp: "2024-05-01T23:57:00Z", Value: 2},
+				{Timestamp: "2024-05-02T00:07:00Z", Value: 3},
+			},
+		},
+		{
+			// 21:10 EDT is after 00:07 UTC but formats as an earlier string
+			name: "zones compare by instant",
+			series: [][]aws.MetricDatapoint{
+				{{Timestamp: at(83).In(edt), Value: 2}},
+				{{Timestamp: at(20), Value: 1}},
+			},
+			bucket: 10 * time.Minute,
+			want: []EChartsDataPoint{
+				{Timestamp: "2024-05-02T00:07:00Z", Value: 1},
+				{Timestamp: "2024-05-02T01:07:00Z", Value: 2},
+			},
+		},
+		{
+			name:   "no datapoints",
+			series: [][]aws.MetricDatapoint{nil, {}},
+			bucket: 5 * time.Minute,
+			want:   []EChartsDataPoint{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := bucketDatapoints(tt.series, start, tt.bucket)
+			if got == nil {
+				t.Fatal("datapoints are nil, want an empty array")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("datapoints = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("datapoint %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
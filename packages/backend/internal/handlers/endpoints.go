@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+const (
+	defaultSlowestEndpoints = 10
+	maxSlowestEndpoints     = 100
+)
+
+// Latency statistics endpoints can be ranked by
+const (
+	LatencyStatAverage = "avg"
+	LatencyStatP99     = "p99"
+)
+
+// rankEndpointsByLatency orders endpoints slowest first by stat and returns at most limit.
+// Endpoints with no requests in the window have no meaningful latency and are dropped.
+func rankEndpointsByLatency(endpoints []aws.EndpointLatency, stat string, limit int) []aws.EndpointLatency {
+	latency := func(e aws.EndpointLatency) float64 {
+		if stat == LatencyStatP99 {
+			return e.LatencyP99
+		}
+		return e.LatencyAverage
+	}
+
+	ranked := make([]aws.EndpointLatency, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if endpoint.Count > 0 {
+			ranked = append(ranked, endpoint)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if latency(ranked[i]) != latency(ranked[j]) {
+			return latency(ranked[i]) > latency(ranked[j])
+		}
+		return ranked[i].Count > ranked[j].Count
+	})
+
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// GetSlowestEndpoints ranks the app's API endpoints by latency over the window and returns
+// the slowest. Use ?stat=avg|p99 to choose the statistic and ?limit= for how many to return.
+func (h *AppHandler) GetSlowestEndpoints(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	query := r.URL.Query()
+	stat := query.Get("stat")
+	if stat == "" {
+		stat = LatencyStatAverage
+	}
+	if stat != LatencyStatAverage && stat != LatencyStatP99 {
+		writeError(w, fmt.Sprintf("stat must be %q or %q", LatencyStatAverage, LatencyStatP99), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultSlowestEndpoints
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSlowestEndpoints {
+			writeError(w, fmt.Sprintf("limit must be between 1 and %d", maxSlowestEndpoints), http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	startTime, endTime := parseTimeRange(r)
+
+	apiName := h.AppsConfig.GetAPIGateway(appID)
+	if apiName == "" {
+		writeError(w, "No API Gateway configured for app", http.StatusNotFound)
+		return
+	}
+
+	endpoints, err := h.CloudWatch.ListAPIEndpoints(r.Context(), apiName)
+	if err != nil {
+		writeError(w, fmt.Sprintf("Failed to list API endpoints: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	latencies, err := h.CloudWatch.GetEndpointLatencies(r.Context(), apiName, endpoints, startTime, endTime)
+	if err != nil {
+		writeError(w, fmt.Sprintf("Failed to get endpoint latencies: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"appId":     appID,
+		"apiName":   apiName,
+		"stat":      stat,
+		"period":    timerange.NewPeriod(startTime, endTime),
+		"endpoints": rankEndpointsByLatency(latencies, stat, limit),
+		"timestamp": time.Now().Unix(),
+	}
+	if len(endpoints) == 0 {
+		response["note"] = "No per-endpoint metrics found; enable detailed CloudWatch metrics on the API stage"
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
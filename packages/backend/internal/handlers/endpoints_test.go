@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// endpointLatencies are stub per-endpoint latencies where average and p99 rank differently
+var endpointLatencies = []aws.EndpointLatency{
+	{APIEndpoint: aws.APIEndpoint{Method: "GET", Resource: "/items"}, Count: 1200, LatencyAverage: 80, LatencyP99: 950},
+	{APIEndpoint: aws.APIEndpoint{Method: "POST", Resource: "/orders"}, Count: 40, LatencyAverage: 300, LatencyP99: 600},
+	{APIEndpoint: aws.APIEndpoint{Method: "GET", Resource: "/search"}, Count: 500, LatencyAverage: 150, LatencyP99: 700},
+	{APIEndpoint: aws.APIEndpoint{Method: "GET", Resource: "/users"}, Count: 900, LatencyAverage: 150, LatencyP99: 200},
+	{APIEndpoint: aws.APIEndpoint{Method: "GET", Resource: "/unused"}, Count: 0},
+}
+
+func TestRankEndpointsByLatency(t *testing.T) {
+	tests := []struct {
+		name       string
+		stat       string
+		limit      int
+		want       []string
+		wantCounts []float64
+	}{
+		// Equal averages fall back to the busier endpoint first
+		{name: "average", stat: LatencyStatAverage, limit: 10, want: []string{"/orders", "/users", "/search", "/items"}, wantCounts: []float64{40, 900, 500, 1200}},
+		{name: "p99", stat: LatencyStatP99, limit: 10, want: []string{"/items", "/search", "/orders", "/users"}, wantCounts: []float64{1200, 500, 40, 900}},
+		{name: "limited", stat: LatencyStatP99, limit: 2, want: []string{"/items", "/search"}, wantCounts: []float64{1200, 500}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := rankEndpointsByLatency(endpointLatencies, tt.stat, tt.limit)
+			if len(ranked) != len(tt.want) {
+				t.Fatalf("ranked = %+v, want %v", ranked, tt.want)
+			}
+			for i, endpoint := range ranked {
+				if endpoint.Resource != tt.want[i] || endpoint.Count != tt.wantCounts[i] {
+					t.Errorf("rank %d = %s with %v requests, want %s with %v", i, endpoint.Resource, endpoint.Count, tt.want[i], tt.wantCounts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetSlowestEndpointsValidatesParams(t *testing.T) {
+	handler := &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{"app": {ID: "app"}}},
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: "?stat=p50", want: http.StatusBadRequest},
+		{query: "?limit=0", want: http.StatusBadRequest},
+		{query: "?limit=101", want: http.StatusBadRequest},
+		{query: "?limit=ten", want: http.StatusBadRequest},
+		// Valid params reach the API lookup, which this app doesn't have
+		{query: "?stat=p99&limit=5", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/"+tt.query, nil), map[string]string{"appId": "app"})
+		rec := httptest.NewRecorder()
+		handler.GetSlowestEndpoints(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.query, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// Deploy recommendations, from least to most severe
+const (
+	DeployOK      = "ok"
+	DeployCaution = "caution"
+	DeployFreeze  = "freeze"
+)
+
+// deploySeverity ranks recommendations so the most severe source wins
+var deploySeverity = map[string]int{
+	DeployOK:      0,
+	DeployCaution: 1,
+	DeployFreeze:  2,
+}
+
+// ErrorBudgetStatus is one source's error budget position over the SLO window, its recent burn
+// rate, and the release recommendation they imply
+type ErrorBudgetStatus struct {
+	Source          string   `json:"source"`
+	Requests        float64  `json:"requests"`
+	Errors          float64  `json:"errors"`
+	Availability    float64  `json:"availability"`
+	BudgetConsumed  float64  `json:"budgetConsumed"`
+	BudgetRemaining float64  `json:"budgetRemaining"`
+	RecentRequests  float64  `json:"recentRequests"`
+	RecentErrors    float64  `json:"recentErrors"`
+	BurnRate        float64  `json:"burnRate"`
+	Recommendation  string   `json:"recommendation"`
+	Reasons         []string `json:"reasons"`
+}
+
+// evaluateErrorBudget measures a source's counts over the SLO window and the recent burn-rate
+// window against the SLO. An exhausted budget or a fast burn means freeze; a low remaining
+// budget or a burn faster than the budget can sustain means caution.
+func evaluateErrorBudget(source string, slo appconfig.SLO, window, recent aws.RequestErrorCounts) ErrorBudgetStatus {
+	status := ErrorBudgetStatus{
+		Source:          source,
+		Requests:        window.Requests,
+		Errors:          window.Errors,
+		Availability:    100,
+		BudgetRemaining: 1,
+		RecentRequests:  recent.Requests,
+		RecentErrors:    recent.Errors,
+		Recommendation:  DeployOK,
+		Reasons:         []string{},
+	}
+
+	budget := slo.ErrorBudget()
+	if window.Requests > 0 {
+		status.Availability = (1 - window.Errors/window.Requests) * 100
+		status.BudgetConsumed = window.Errors / (budget * window.Requests)
+		status.BudgetRemaining = 1 - status.BudgetConsumed
+		if status.BudgetRemaining < 0 {
+			status.BudgetRemaining = 0
+		}
+	}
+	if recent.Requests > 0 {
+		status.BurnRate = (recent.Errors / recent.Requests) / budget
+	}
+
+	raise := func(recommendation, reason string) {
+		if deploySeverity[recommendation] > deploySeverity[status.Recommendation] {
+			status.Recommendation = recommendation
+		}
+		status.Reasons = append(status.Reasons, reason)
+	}
+
+	switch {
+	case status.BudgetConsumed >= 1:
+		raise(DeployFreeze, fmt.Sprintf("error budget exhausted (%.0f%% consumed)", status.BudgetConsumed*100))
+	case status.BudgetRemaining < slo.CautionBudget:
+		raise(DeployCaution, fmt.Sprintf("only %.0f%% of error budget remaining", status.BudgetRemaining*100))
+	}
+
+	switch {
+	case status.BurnRate >= slo.FreezeBurnRate:
+		raise(DeployFreeze, fmt.Sprintf("burning error budget %.1fx faster than sustainable", status.BurnRate))
+	case status.BurnRate > slo.CautionBurnRate:
+		raise(DeployCaution, fmt.Sprintf("burning error budget %.1fx faster than sustainable", status.BurnRate))
+	}
+
+	return status
+}
+
+// GetDeployRecommendation recommends whether releases should proceed (ok), proceed with care
+// (caution) or stop (freeze), based on the API's and Lambda functions' remaining error budget
+// and recent burn rate. The most severe source determines the overall recommendation.
+func (h *AppHandler) GetDeployRecommendation(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	endTime := time.Now()
+	windowStart := endTime.Add(-h.SLO.Window)
+	recentStart := endTime.Add(-h.SLO.BurnRateWindow)
+
+	outcome := newPartialResult()
+	sources := []ErrorBudgetStatus{}
+
+	if apiName := h.AppsConfig.GetAPIGateway(appID); apiName != "" {
+		window, err := h.CloudWatch.GetAPIGatewayErrorCounts(r.Context(), apiName, windowStart, endTime)
+		if err == nil {
+			var recent *aws.RequestErrorCounts
+			recent, err = h.CloudWatch.GetAPIGatewayErrorCounts(r.Context(), apiName, recentStart, endTime)
+			if err == nil {
+				sources = append(sources, evaluateErrorBudget("apigateway", h.SLO, *window, *recent))
+			}
+		}
+		if err != nil {
+			h.Logger.Warn("Failed to get API Gateway error counts", "api", apiName, "error", err)
+			outcome.failed(apiName, err)
+		} else {
+			outcome.succeeded()
+		}
+	}
+
+	if h.Features.Lambda {
+		var window, recent aws.RequestErrorCounts
+		found := false
+		for _, functionName := range h.ResolveLambdaFunctions(r.Context(), appID) {
+			windowStats, err := h.CloudWatch.GetLambdaStatistics(r.Context(), functionName, windowStart, endTime)
+			if err == nil {
+				var recentStats *aws.LambdaStatistics
+				recentStats, err = h.CloudWatch.GetLambdaStatistics(r.Context(), functionName, recentStart, endTime)
+				if err == nil {
+					window.Requests += windowStats.InvocationsSum
+					window.Errors += windowStats.ErrorsSum
+					recent.Requests += recentStats.InvocationsSum
+					recent.Errors += recentStats.ErrorsSum
+					found = true
+				}
+			}
+			if err != nil {
+				h.Logger.Warn("Failed to get Lambda error counts", "function", functionName, "error", err)
+				outcome.failed(functionName, err)
+				continue
+			}
+			outcome.succeeded()
+		}
+		if found {
+			sources = append(sources, evaluateErrorBudget("lambda", h.SLO, window, recent))
+		}
+	}
+
+	recommendation := DeployOK
+	for _, source := range sources {
+		if deploySeverity[source.Recommendation] > deploySeverity[recommendation] {
+			recommendation = source.Recommendation
+		}
+	}
+
+	response := map[string]interface{}{
+		"appId":          appID,
+		"recommendation": recommendation,
+		"slo": map[string]interface{}{
+			"target":          h.SLO.Target,
+			"window":          h.SLO.Window.String(),
+			"burnRateWindow":  h.SLO.BurnRateWindow.String(),
+			"cautionBurnRate": h.SLO.CautionBurnRate,
+			"freezeBurnRate":  h.SLO.FreezeBurnRate,
+			"cautionBudget":   h.SLO.CautionBudget,
+		},
+		"sources":   sources,
+		"period":    timerange.NewPeriod(windowStart, endTime),
+		"timestamp": time.Now().Unix(),
+	}
+
+	outcome.writeJSON(w, response)
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// testSLO is a 99.9% target over 30 days, allowing one failed request in a thousand
+var testSLO = appconfig.SLO{
+	Target:          99.9,
+	Window:          30 * 24 * time.Hour,
+	BurnRateWindow:  time.Hour,
+	CautionBurnRate: 1,
+	FreezeBurnRate:  14.4,
+	CautionBudget:   0.25,
+}
+
+func TestEvaluateErrorBudget(t *testing.T) {
+	tests := []struct {
+		name          string
+		window        aws.RequestErrorCounts
+		recent        aws.RequestErrorCounts
+		want          string
+		wantConsumed  float64
+		wantRemaining float64
+		wantBurnRate  float64
+		wantReasons   int
+	}{
+		{
+			name:   "healthy",
+			window: aws.RequestErrorCounts{Requests: 1000000, Errors: 200},
+			recent: aws.RequestErrorCounts{Requests: 10000, Errors: 5},
+			want:   DeployOK, wantConsumed: 0.2, wantRemaining: 0.8, wantBurnRate: 0.5,
+		},
+		{
+			name:   "elevated burn",
+			window: aws.RequestErrorCounts{Requests: 1000000, Errors: 300},
+			recent: aws.RequestErrorCounts{Requests: 10000, Errors: 30},
+			want:   DeployCaution, wantConsumed: 0.3, wantRemaining: 0.7, wantBurnRate: 3, wantReasons: 1,
+		},
+		{
+			name:   "little budget left",
+			window: aws.RequestErrorCounts{Requests: 1000000, Errors: 800},
+			recent: aws.RequestErrorCounts{Requests: 10000, Errors: 5},
+			want:   DeployCaution, wantConsumed: 0.8, wantRemaining: 0.2, wantBurnRate: 0.5, wantReasons: 1,
+		},
+		{
+			name:   "exhausted budget",
+			window: aws.RequestErrorCounts{Requests: 1000000, Errors: 1500},
+			recent: aws.RequestErrorCounts{Requests: 10000, Errors: 5},
+			want:   DeployFreeze, wantConsumed: 1.5, wantRemaining: 0, wantBurnRate: 0.5, wantReasons: 1,
+		},
+		{
+			name:   "fast burn",
+			window: aws.RequestErrorCounts{Requests: 1000000, Errors: 300},
+			recent: aws.RequestErrorCounts{Requests: 10000, Errors: 200},
+			want:   DeployFreeze, wantConsumed: 0.3, wantRemaining: 0.7, wantBurnRate: 20, wantReasons: 1,
+		},
+		{
+			name:   "exhausted and still burning",
+			window: aws.RequestErrorCounts{Requests: 1000000, Errors: 1500},
+			recent: aws.RequestErrorCounts{Requests: 10000, Errors: 30},
+			want:   DeployFreeze, wantConsumed: 1.5, wantRemaining: 0, wantBurnRate: 3, wantReasons: 2,
+		},
+		{
+			name: "no traffic",
+			want: DeployOK, wantRemaining: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := evaluateErrorBudget("apigateway", testSLO, tt.window, tt.recent)
+
+			if status.Recommendation != tt.want {
+				t.Errorf("Recommendation = %s, want %s (reasons %v)", status.Recommendation, tt.want, status.Reasons)
+			}
+			if len(status.Reasons) != tt.wantReasons {
+				t.Errorf("Reasons = %v, want %d", status.Reasons, tt.wantReasons)
+			}
+			for _, n := range []struct {
+				name      string
+				got, want float64
+			}{
+				{"BudgetConsumed", status.BudgetConsumed, tt.wantConsumed},
+				{"BudgetRemaining", status.BudgetRemaining, tt.wantRemaining},
+				{"BurnRate", status.BurnRate, tt.wantBurnRate},
+			} {
+				if math.Abs(n.got-n.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", n.name, n.got, n.want)
+				}
+			}
+			if status.Requests != tt.window.Requests || status.RecentErrors != tt.recent.Errors {
+				t.Errorf("status = %+v, want the counts behind the recommendation", status)
+			}
+		})
+	}
+}
+
+func TestGetDeployRecommendationWithoutSources(t *testing.T) {
+	handler := &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{"app": {ID: "app"}}},
+		SLO:        testSLO,
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"appId": "app"})
+	rec := httptest.NewRecorder()
+	handler.GetDeployRecommendation(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Recommendation string              `json:"recommendation"`
+		Sources        []ErrorBudgetStatus `json:"sources"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.Recommendation != DeployOK || len(body.Sources) != 0 {
+		t.Errorf("response = %+v, want ok with no sources", body)
+	}
+}
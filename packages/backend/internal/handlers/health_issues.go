@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxHealthIssues caps the issues listed in a health summary; the rest are counted in a
+// final "+N more" entry
+const maxHealthIssues = 10
+
+// Issue severities, most severe first when sorted
+const (
+	issueSeverityWarning = iota + 1 // Throttling and latency: degraded but serving
+	issueSeverityError              // Errors and crash spikes: requests or sessions failing
+)
+
+// healthIssue is a problem found while assessing a service
+type healthIssue struct {
+	severity int
+	resource string // e.g. "lambda:my-function", used to order issues of equal severity
+	message  string
+}
+
+// issueMessages orders issues by severity, most severe first, then resource and message, drops
+// repeated messages and caps the list at maxHealthIssues, so the same degraded inputs always
+// produce the same list
+func issueMessages(issues []healthIssue) []string {
+	sorted := make([]healthIssue, len(issues))
+	copy(sorted, issues)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].severity != sorted[j].severity {
+			return sorted[i].severity > sorted[j].severity
+		}
+		if sorted[i].resource != sorted[j].resource {
+			return sorted[i].resource < sorted[j].resource
+		}
+		return sorted[i].message < sorted[j].message
+	})
+
+	messages := []string{}
+	seen := make(map[string]bool)
+	for _, issue := range sorted {
+		if seen[issue.message] {
+			continue
+		}
+		seen[issue.message] = true
+		messages = append(messages, issue.message)
+	}
+
+	if len(messages) > maxHealthIssues {
+		more := len(messages) - maxHealthIssues
+		messages = append(messages[:maxHealthIssues], fmt.Sprintf("+%d more", more))
+	}
+	return messages
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+func TestIssueMessagesOrderAndDedupe(t *testing.T) {
+	issues := []healthIssue{
+		{severity: issueSeverityWarning, resource: "lambda:worker", message: "Lambda worker is being throttled"},
+		{severity: issueSeverityError, resource: "lambda:api", message: "Lambda api has high error rate"},
+		{severity: issueSeverityWarning, resource: "dynamodb:users", message: "DynamoDB users is being throttled"},
+		{severity: issueSeverityError, resource: "appstore:123", message: "App crashes spiked"},
+		{severity: issueSeverityWarning, resource: "lambda:worker", message: "Lambda worker is being throttled"},
+		{severity: issueSeverityError, resource: "lambda:api", message: "Lambda api has high error rate"},
+	}
+	want := []string{
+		"App crashes spiked",
+		"Lambda api has high error rate",
+		"DynamoDB users is being throttled",
+		"Lambda worker is being throttled",
+	}
+
+	// However the issues arrive, the list is the same
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		random.Shuffle(len(issues), func(a, b int) { issues[a], issues[b] = issues[b], issues[a] })
+		if got := issueMessages(issues); !slices.Equal(got, want) {
+			t.Fatalf("issueMessages(%+v) = %q, want %q", issues, got, want)
+		}
+	}
+}
+
+func TestIssueMessagesCap(t *testing.T) {
+	var issues []healthIssue
+	for i := 0; i < maxHealthIssues+3; i++ {
+		resource := fmt.Sprintf("lambda:fn-%02d", i)
+		issues = append(issues, healthIssue{severity: issueSeverityWarning, resource: resource, message: resource + " is being throttled"})
+	}
+
+	got := issueMessages(issues)
+	if len(got) != maxHealthIssues+1 {
+		t.Fatalf("got %d issues, want %d and a summary: %q", len(got), maxHealthIssues, got)
+	}
+	if got[0] != "lambda:fn-00 is being throttled" || got[maxHealthIssues-1] != "lambda:fn-09 is being throttled" {
+		t.Errorf("issues = %q, want the first %d by resource", got, maxHealthIssues)
+	}
+	if last := got[maxHealthIssues]; last != "+3 more" {
+		t.Errorf("last issue = %q, want +3 more", last)
+	}
+
+	// Duplicates don't count toward the cap
+	if got := issueMessages(append(issues[:maxHealthIssues:maxHealthIssues], issues[0], issues[1])); len(got) != maxHealthIssues {
+		t.Errorf("issues = %q, want %d with no summary", got, maxHealthIssues)
+	}
+
+	if got := issueMessages(nil); got == nil || len(got) != 0 {
+		t.Errorf("issueMessages(nil) = %#v, want an empty list", got)
+	}
+}
+
+func TestHealthSummaryIssuesAreStable(t *testing.T) {
+	metrics := HealthMetrics{
+		Lambda: map[string]*aws.LambdaMetrics{
+			"checkout": {Invocations: 1000, Errors: 600},
+			"worker":   {Invocations: 1000, Throttles: 20},
+			"api":      {Invocations: 1000, Errors: 200},
+		},
+		DynamoDB: map[string]*aws.DynamoDBMetrics{
+			"users":  {ThrottledRequests: 20},
+			"orders": {SystemErrors: 60},
+		},
+	}
+	alerts := EvaluateHealth(metrics, testAlertRules())
+
+	var first []string
+	for i := 0; i < 20; i++ {
+		summary := newHealthSummary()
+		summary.assessServices(metrics, alerts, nil)
+		summary.finish()
+		if i == 0 {
+			first = summary.Issues
+			continue
+		}
+		if !slices.Equal(summary.Issues, first) {
+			t.Fatalf("issues = %q, then %q for the same inputs", first, summary.Issues)
+		}
+	}
+
+	// Critical alerts come first, each severity ordered by resource (service, then name)
+	wantResources := []string{"orders", "checkout", "users", "api", "worker"}
+	if len(first) != len(wantResources) {
+		t.Fatalf("issues = %q, want one per degraded resource", first)
+	}
+	for i, resource := range wantResources {
+		if !strings.Contains(first[i], " "+resource+" ") {
+			t.Errorf("issue %d = %q, want %s", i, first[i], resource)
+		}
+	}
+}
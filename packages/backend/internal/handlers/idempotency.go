@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+)
+
+// IdempotencyStore reserves Idempotency-Keys and keeps the results of the requests that used
+// them, as aws.IdempotencyStore does in DynamoDB
+type IdempotencyStore interface {
+	// Acquire reserves key, or returns the record already holding it and false
+	Acquire(ctx context.Context, key string) (*aws.IdempotencyRecord, bool, error)
+	Complete(ctx context.Context, record *aws.IdempotencyRecord) error
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyMiddleware replays the stored result when a mutating request repeats an Idempotency-Key.
+// A repeat whose body differs from the original is rejected rather than replayed. Requests without
+// the header, and all requests when no store is configured, pass straight through.
+func (h *AppHandler) IdempotencyMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if h.Idempotency == nil || key == "" || !isMutatingMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		requestHash := hashRequestBody(body)
+
+		// Scope keys to the caller and endpoint so unrelated requests can't collide
+		scopedKey := r.Method + " " + r.URL.Path + " " + key
+		if claims, ok := r.Context().Value("claims").(*auth.SessionClaims); ok {
+			scopedKey = claims.UserID + " " + scopedKey
+		}
+
+		record, acquired, err := h.Idempotency.Acquire(r.Context(), scopedKey)
+		if err != nil {
+			h.Logger.Error("Failed to check idempotency key", "error", err)
+			writeError(w, "Failed to check idempotency key", http.StatusInternalServerError)
+			return
+		}
+
+		if !acquired {
+			if record.RequestHash != "" && record.RequestHash != requestHash {
+				writeError(w, "Idempotency-Key was already used with a different request body", http.StatusUnprocessableEntity)
+				return
+			}
+			if !record.Completed {
+				writeError(w, "A request with this Idempotency-Key is already in progress", http.StatusConflict)
+				return
+			}
+			if record.ContentType != "" {
+				w.Header().Set("Content-Type", record.ContentType)
+			}
+			w.Header().Set(idempotencyReplayedHeader, "true")
+			w.WriteHeader(record.StatusCode)
+			w.Write(record.Body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// Server errors are not cached so the client can retry with the same key
+		if recorder.statusCode >= http.StatusInternalServerError {
+			if err := h.Idempotency.Release(r.Context(), scopedKey); err != nil {
+				h.Logger.Warn("Failed to release idempotency key", "error", err)
+			}
+			return
+		}
+
+		err = h.Idempotency.Complete(r.Context(), &aws.IdempotencyRecord{
+			Key:         scopedKey,
+			RequestHash: requestHash,
+			StatusCode:  recorder.statusCode,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			h.Logger.Warn("Failed to store idempotency result", "error", err)
+		}
+	}
+}
+
+// hashRequestBody returns the hex SHA-256 digest stored with a result to recognize its request
+func hashRequestBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// isMutatingMethod reports whether a request method can change server state
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder captures a handler's response while passing it through to the client
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+	partial     bool // The handler reported resources it failed to fetch
+}
+
+// WriteHeader records the status code before writing it
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// markPartial records that the response is missing resources that failed
+func (r *responseRecorder) markPartial() {
+	r.partial = true
+}
+
+// Write records the body before writing it
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// memoryIdempotencyStore is an IdempotencyStore held in a map
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*aws.IdempotencyRecord
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*aws.IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) Acquire(ctx context.Context, key string) (*aws.IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		return record, false, nil
+	}
+	s.records[key] = &aws.IdempotencyRecord{Key: key}
+	return nil, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, record *aws.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	completed := *record
+	completed.Completed = true
+	s.records[record.Key] = &completed
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// countingCreate answers 201 with a body numbering the calls, or status once set
+type countingCreate struct {
+	calls  int
+	status int
+	body   string // The request body the handler last read
+}
+
+func (c *countingCreate) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls++
+	body, _ := io.ReadAll(r.Body)
+	c.body = string(body)
+	if c.status != 0 {
+		writeError(w, "failed", c.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, `{"created":`+strconv.Itoa(c.calls)+`}`)
+}
+
+func newIdempotentHandler(store IdempotencyStore) (*countingCreate, http.HandlerFunc) {
+	h := &AppHandler{Idempotency: store, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	create := &countingCreate{}
+	return create, h.IdempotencyMiddleware(create.ServeHTTP)
+}
+
+// sendWithKey sends a method request to path as userID, with an Idempotency-Key header unless key is empty
+func sendWithKey(handler http.HandlerFunc, method, path, key, userID string) *httptest.ResponseRecorder {
+	return sendBodyWithKey(handler, method, path, key, userID, "")
+}
+
+// sendBodyWithKey is sendWithKey for a request with body
+func sendBodyWithKey(handler http.HandlerFunc, method, path, key, userID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotencyKeyHeader, key)
+	}
+	if userID != "" {
+		req = req.WithContext(context.WithValue(req.Context(), "claims", &auth.SessionClaims{UserID: userID}))
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestIdempotencyMiddlewareReplaysRepeatedKey(t *testing.T) {
+	create, handler := newIdempotentHandler(newMemoryIdempotencyStore())
+
+	first := sendWithKey(handler, http.MethodPost, "/api/apps/app/appstore/reports/refresh", "key-1", "user-1")
+	if first.Code != http.StatusCreated || first.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Fatalf("first request = %d, replayed %q", first.Code, first.Header().Get(idempotencyReplayedHeader))
+	}
+
+	repeat := sendWithKey(handler, http.MethodPost, "/api/apps/app/appstore/reports/refresh", "key-1", "user-1")
+	if create.calls != 1 {
+		t.Errorf("handler ran %d times, want once", create.calls)
+	}
+	if repeat.Code != first.Code || repeat.Body.String() != first.Body.String() {
+		t.Errorf("repeat = %d %s, want %d %s", repeat.Code, repeat.Body, first.Code, first.Body)
+	}
+	if got := repeat.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("repeat Content-Type = %q, want application/json", got)
+	}
+	if got := repeat.Header().Get(idempotencyReplayedHeader); got != "true" {
+		t.Errorf("repeat %s = %q, want true", idempotencyReplayedHeader, got)
+	}
+}
+
+func TestIdempotencyMiddlewareRunsDistinctRequests(t *testing.T) {
+	create, handler := newIdempotentHandler(newMemoryIdempotencyStore())
+	sendWithKey(handler, http.MethodPost, "/refresh", "key-1", "user-1")
+
+	tests := []struct {
+		name, method, path, key, userID string
+	}{
+		{name: "another key", method: http.MethodPost, path: "/refresh", key: "key-2", userID: "user-1"},
+		{name: "another user", method: http.MethodPost, path: "/refresh", key: "key-1", userID: "user-2"},
+		{name: "another endpoint", method: http.MethodPost, path: "/other", key: "key-1", userID: "user-1"},
+		{name: "no key", method: http.MethodPost, path: "/refresh", userID: "user-1"},
+		{name: "read-only method", method: http.MethodGet, path: "/refresh", key: "key-1", userID: "user-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := create.calls
+			rec := sendWithKey(handler, tt.method, tt.path, tt.key, tt.userID)
+			if create.calls != calls+1 || rec.Header().Get(idempotencyReplayedHeader) != "" {
+				t.Errorf("request was replayed instead of run: %d %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddlewareReleasesServerErrors(t *testing.T) {
+	create, handler := newIdempotentHandler(newMemoryIdempotencyStore())
+	create.status = http.StatusBadGateway
+
+	if rec := sendWithKey(handler, http.MethodPost, "/refresh", "key-1", "user-1"); rec.Code != http.StatusBadGateway {
+		t.Fatalf("failing request = %d, want 502", rec.Code)
+	}
+
+	// The retry runs again, and its success is what later repeats see
+	create.status = 0
+	if rec := sendWithKey(handler, http.MethodPost, "/refresh", "key-1", "user-1"); rec.Code != http.StatusCreated {
+		t.Fatalf("retry = %d, want 201", rec.Code)
+	}
+	sendWithKey(handler, http.MethodPost, "/refresh", "key-1", "user-1")
+	if create.calls != 2 {
+		t.Errorf("handler ran %d times, want 2", create.calls)
+	}
+}
+
+func TestIdempotencyMiddlewareRejectsInProgressAndLongKeys(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	create, handler := newIdempotentHandler(store)
+	store.Acquire(context.Background(), "user-1 POST /refresh key-1")
+
+	if rec := sendWithKey(handler, http.MethodPost, "/refresh", "key-1", "user-1"); rec.Code != http.StatusConflict {
+		t.Errorf("request while the key is held = %d, want 409", rec.Code)
+	}
+	if rec := sendWithKey(handler, http.MethodPost, "/refresh", strings.Repeat("k", maxIdempotencyKeyLength+1), "user-1"); rec.Code != http.StatusBadRequest {
+		t.Errorf("request with an over-long key = %d, want 400", rec.Code)
+	}
+	if create.calls != 0 {
+		t.Errorf("handler ran %d times, want never", create.calls)
+	}
+}
+
+func TestIdempotencyMiddlewareRejectsChangedBody(t *testing.T) {
+	create, handler := newIdempotentHandler(newMemoryIdempotencyStore())
+
+	first := sendBodyWithKey(handler, http.MethodPost, "/alerts", "key-1", "user-1", `{"threshold":5}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first request = %d, want 201", first.Code)
+	}
+	// The middleware reads the body to hash it, so the handler must still see it whole
+	if create.body != `{"threshold":5}` {
+		t.Errorf("handler read body %q, want the request body", create.body)
+	}
+
+	if rec := sendBodyWithKey(handler, http.MethodPost, "/alerts", "key-1", "user-1", `{"threshold":5}`); rec.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Errorf("retry with the same body = %d, want it replayed", rec.Code)
+	}
+
+	changed := sendBodyWithKey(handler, http.MethodPost, "/alerts", "key-1", "user-1", `{"threshold":50}`)
+	if changed.Code != http.StatusUnprocessableEntity {
+		t.Errorf("retry with a different body = %d, want 422", changed.Code)
+	}
+	if changed.Header().Get(idempotencyReplayedHeader) != "" {
+		t.Error("retry with a different body was replayed")
+	}
+	if create.calls != 1 {
+		t.Errorf("handler ran %d times, want once", create.calls)
+	}
+}
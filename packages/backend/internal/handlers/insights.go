@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// Insight severities, most severe first
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// Insight categories
+const (
+	InsightCategoryLambda   = "lambda"
+	InsightCategoryDynamoDB = "dynamodb"
+	InsightCategoryCost     = "cost"
+)
+
+const (
+	// defaultInsightsWindow is compared against the window before it when no start is requested
+	defaultInsightsWindow = 7 * 24 * time.Hour
+
+	// minInsightInvocations keeps tiny sample sizes from producing error rate insights
+	minInsightInvocations = 100
+
+	// overProvisionedUtilization is the utilization percentage below which a table is flagged
+	overProvisionedUtilization = 40.0
+
+	// costIncreaseThreshold is the percentage increase in projected cost worth reporting
+	costIncreaseThreshold = 20.0
+)
+
+// Insight is a plain-language finding with the numbers behind it
+type Insight struct {
+	Severity string             `json:"severity"`
+	Category string             `json:"category"`
+	Message  string             `json:"message"`
+	Evidence map[string]float64 `json:"evidence"`
+}
+
+// lambdaComparison holds one function's metrics for the current and previous windows
+type lambdaComparison struct {
+	FunctionName string
+	Current      *aws.LambdaMetrics
+	Previous     *aws.LambdaMetrics
+}
+
+// insightInputs is the data the insight rules run over
+type insightInputs struct {
+	Window       time.Duration
+	Lambda       []lambdaComparison
+	Tables       []*aws.DynamoDBMetrics
+	CurrentCost  *aws.CostData
+	PreviousCost *aws.CostData
+}
+
+// insightRule inspects the inputs and returns any findings
+type insightRule func(in insightInputs) []Insight
+
+// insightRules are evaluated in order for every insights request
+var insightRules = []insightRule{
+	lambdaErrorRateRule,
+	lambdaThrottleRule,
+	dynamoDBOverProvisionedRule,
+	dynamoDBThrottleRule,
+	costProjectionRule,
+}
+
+// severityRank orders severities for sorting, most severe first
+func severityRank(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return 0
+	case SeverityWarning:
+		return 1
+	}
+	return 2
+}
+
+// generateInsights runs every rule and ranks the results by severity
+func generateInsights(in insightInputs) []Insight {
+	insights := []Insight{}
+	for _, rule := range insightRules {
+		insights = append(insights, rule(in)...)
+	}
+
+	sort.SliceStable(insights, func(i, j int) bool {
+		if severityRank(insights[i].Severity) != severityRank(insights[j].Severity) {
+			return severityRank(insights[i].Severity) < severityRank(insights[j].Severity)
+		}
+		return insights[i].Category < insights[j].Category
+	})
+	return insights
+}
+
+// lambdaErrorRate returns errors as a percentage of invocations
+func lambdaErrorRate(metrics *aws.LambdaMetrics) float64 {
+	if metrics == nil || metrics.Invocations == 0 {
+		return 0
+	}
+	return metrics.Errors / metrics.Invocations * 100
+}
+
+// lambdaErrorRateRule flags functions whose error rate at least doubled versus the previous window
+func lambdaErrorRateRule(in insightInputs) []Insight {
+	var insights []Insight
+	for _, fn := range in.Lambda {
+		if fn.Current == nil || fn.Previous == nil || fn.Current.Invocations < minInsightInvocations {
+			continue
+		}
+
+		current, previous := lambdaErrorRate(fn.Current), lambdaErrorRate(fn.Previous)
+		if current < 1 || current < previous*2 {
+			continue
+		}
+
+		severity := SeverityWarning
+		if current >= 5 {
+			severity = SeverityCritical
+		}
+
+		message := fmt.Sprintf("Lambda %s error rate rose to %.1f%% from %.1f%% in the previous period", fn.FunctionName, current, previous)
+		if previous > 0 {
+			message = fmt.Sprintf("Lambda %s error rate %s vs the previous period (%.1f%% → %.1f%%)", fn.FunctionName, multipleDescription(current/previous), previous, current)
+		}
+
+		insights = append(insights, Insight{
+			Severity: severity,
+			Category: InsightCategoryLambda,
+			Message:  message,
+			Evidence: map[string]float64{
+				"currentErrorRate":  current,
+				"previousErrorRate": previous,
+				"invocations":       fn.Current.Invocations,
+				"errors":            fn.Current.Errors,
+			},
+		})
+	}
+	return insights
+}
+
+// multipleDescription describes a ratio of at least two in words
+func multipleDescription(ratio float64) string {
+	if ratio < 2.5 {
+		return "doubled"
+	}
+	return fmt.Sprintf("rose %.1fx", ratio)
+}
+
+// lambdaThrottleRule flags functions that were throttled in the current window
+func lambdaThrottleRule(in insightInputs) []Insight {
+	var insights []Insight
+	for _, fn := range in.Lambda {
+		if fn.Current == nil || fn.Current.Throttles == 0 {
+			continue
+		}
+		insights = append(insights, Insight{
+			Severity: SeverityWarning,
+			Category: InsightCategoryLambda,
+			Message:  fmt.Sprintf("Lambda %s was throttled %.0f times", fn.FunctionName, fn.Current.Throttles),
+			Evidence: map[string]float64{
+				"throttles":   fn.Current.Throttles,
+				"invocations": fn.Current.Invocations,
+			},
+		})
+	}
+	return insights
+}
+
+// dynamoDBOverProvisionedRule flags provisioned tables using well under their capacity.
+// Consumed capacity is summed over the window, so it is averaged per second before comparing.
+func dynamoDBOverProvisionedRule(in insightInputs) []Insight {
+	seconds := in.Window.Seconds()
+	if seconds <= 0 {
+		return nil
+	}
+
+	var insights []Insight
+	for _, table := range in.Tables {
+		provisioned := table.ProvisionedReadCapacity + table.ProvisionedWriteCapacity
+		if provisioned == 0 {
+			continue // on-demand
+		}
+
+		consumed := (table.ConsumedReadCapacity + table.ConsumedWriteCapacity) / seconds
+		utilization := consumed / provisioned * 100
+		if utilization >= overProvisionedUtilization {
+			continue
+		}
+
+		insights = append(insights, Insight{
+			Severity: SeverityInfo,
+			Category: InsightCategoryDynamoDB,
+			Message:  fmt.Sprintf("DynamoDB table %s is over-provisioned by %.0f%%", table.TableName, 100-utilization),
+			Evidence: map[string]float64{
+				"utilizationPercent":       utilization,
+				"provisionedReadCapacity":  table.ProvisionedReadCapacity,
+				"provisionedWriteCapacity": table.ProvisionedWriteCapacity,
+				"averageConsumedCapacity":  consumed,
+			},
+		})
+	}
+	return insights
+}
+
+// dynamoDBThrottleRule flags tables with throttled requests
+func dynamoDBThrottleRule(in insightInputs) []Insight {
+	var insights []Insight
+	for _, table := range in.Tables {
+		if table.ThrottledRequests == 0 {
+			continue
+		}
+		insights = append(insights, Insight{
+			Severity: SeverityWarning,
+			Category: InsightCategoryDynamoDB,
+			Message:  fmt.Sprintf("DynamoDB table %s throttled %.0f requests", table.TableName, table.ThrottledRequests),
+			Evidence: map[string]float64{
+				"throttledRequests": table.ThrottledRequests,
+			},
+		})
+	}
+	return insights
+}
+
+// costProjectionRule flags a projected monthly cost well above the previous period's pace
+func costProjectionRule(in insightInputs) []Insight {
+	days := in.Window.Hours() / 24
+	if in.CurrentCost == nil || in.PreviousCost == nil || days <= 0 || in.PreviousCost.TotalCost <= 0 {
+		return nil
+	}
+
+	currentProjection := in.CurrentCost.TotalCost / days * 30
+	previousProjection := in.PreviousCost.TotalCost / days * 30
+	change := (currentProjection - previousProjection) / previousProjection * 100
+	if change < costIncreaseThreshold {
+		return nil
+	}
+
+	severity := SeverityWarning
+	if change >= 50 {
+		severity = SeverityCritical
+	}
+
+	return []Insight{{
+		Severity: severity,
+		Category: InsightCategoryCost,
+		Message:  fmt.Sprintf("Projected monthly cost is up %.0f%% ($%.2f vs $%.2f)", change, currentProjection, previousProjection),
+		Evidence: map[string]float64{
+			"currentProjection":  currentProjection,
+			"previousProjection": previousProjection,
+			"changePercent":      change,
+		},
+	}}
+}
+
+// GetInsights returns ranked plain-language findings comparing the requested window
+// with the window of the same length before it
+func (h *AppHandler) GetInsights(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	startTime, endTime := parseTimeRange(r)
+	if r.URL.Query().Get("start") == "" {
+		startTime = endTime.Add(-defaultInsightsWindow)
+	}
+
+	outcome := newPartialResult()
+	in := h.collectInsightInputs(r.Context(), appID, startTime, endTime, outcome)
+
+	response := map[string]interface{}{
+		"appId":     appID,
+		"period":    timerange.NewPeriod(startTime, endTime),
+		"insights":  generateInsights(in),
+		"timestamp": time.Now().Unix(),
+	}
+	outcome.writeJSON(w, response)
+}
+
+// collectInsightInputs fetches current and previous window data for the insight rules
+func (h *AppHandler) collectInsightInputs(ctx context.Context, appID string, startTime, endTime time.Time, outcome *partialResult) insightInputs {
+	window := endTime.Sub(startTime)
+	previousStart := startTime.Add(-window)
+	in := insightInputs{Window: window}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+
+	if h.Features.Lambda {
+		for _, functionName := range h.ResolveLambdaFunctions(ctx, appID) {
+			wg.Add(1)
+			go func(functionName string) {
+				defer wg.Done()
+				current, err := h.CloudWatch.GetLambdaMetrics(ctx, functionName, startTime, endTime)
+				if err != nil {
+					outcome.failed("lambda:"+functionName, err)
+					return
+				}
+				previous, err := h.CloudWatch.GetLambdaMetrics(ctx, functionName, previousStart, startTime)
+				if err != nil {
+					outcome.failed("lambda:"+functionName, err)
+					return
+				}
+				outcome.succeeded()
+
+				mu.Lock()
+				in.Lambda = append(in.Lambda, lambdaComparison{FunctionName: functionName, Current: current, Previous: previous})
+				mu.Unlock()
+			}(functionName)
+		}
+	}
+
+	if h.Features.DynamoDB {
+		for _, tableName := range h.AppsConfig.GetDynamoDBTables(appID) {
+			wg.Add(1)
+			go func(tableName string) {
+				defer wg.Done()
+				metrics, err := h.DynamoDB.GetTableMetrics(ctx, tableName, startTime, endTime)
+				if err != nil {
+					outcome.failed("dynamodb:"+tableName, err)
+					return
+				}
+				outcome.succeeded()
+
+				mu.Lock()
+				in.Tables = append(in.Tables, metrics)
+				mu.Unlock()
+			}(tableName)
+		}
+	}
+
+	if h.Features.Cost {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			current, err := h.GetAppCosts(ctx, appID, startTime, endTime)
+			if err != nil {
+				outcome.failed("cost", err)
+				return
+			}
+			previous, err := h.GetAppCosts(ctx, appID, previousStart, startTime)
+			if err != nil {
+				outcome.failed("cost", err)
+				return
+			}
+			outcome.succeeded()
+
+			mu.Lock()
+			in.CurrentCost, in.PreviousCost = current, previous
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	// Goroutines finish in any order; keep insight output stable
+	sort.Slice(in.Lambda, func(i, j int) bool { return in.Lambda[i].FunctionName < in.Lambda[j].FunctionName })
+	sort.Slice(in.Tables, func(i, j int) bool { return in.Tables[i].TableName < in.Tables[j].TableName })
+
+	return in
+}
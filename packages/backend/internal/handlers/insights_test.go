@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+const insightsWindow = 7 * 24 * time.Hour
+
+// lambdaWindow is a function's metrics for one window
+func lambdaWindow(invocations, errors, throttles float64) *aws.LambdaMetrics {
+	return &aws.LambdaMetrics{Invocations: invocations, Errors: errors, Throttles: throttles}
+}
+
+// provisionedTable is a table with 10 read and 10 write units provisioned, consuming
+// perSecond units on average over insightsWindow
+func provisionedTable(name string, perSecond, throttled float64) *aws.DynamoDBMetrics {
+	consumed := perSecond * insightsWindow.Seconds()
+	return &aws.DynamoDBMetrics{
+		TableName:                name,
+		ProvisionedReadCapacity:  10,
+		ProvisionedWriteCapacity: 10,
+		ConsumedReadCapacity:     consumed / 2,
+		ConsumedWriteCapacity:    consumed / 2,
+		ThrottledRequests:        throttled,
+	}
+}
+
+func TestInsightRules(t *testing.T) {
+	tests := []struct {
+		name         string
+		rule         insightRule
+		in           insightInputs
+		wantSeverity string // "" when the rule should stay silent
+		wantMessage  string
+	}{
+		{
+			name:         "error rate rose 2.5x",
+			rule:         lambdaErrorRateRule,
+			in:           insightInputs{Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(1000, 25, 0), Previous: lambdaWindow(1000, 10, 0)}}},
+			wantSeverity: SeverityWarning,
+			wantMessage:  "Lambda api error rate rose 2.5x vs the previous period (1.0% → 2.5%)",
+		},
+		{
+			name:         "error rate doubled past 5%",
+			rule:         lambdaErrorRateRule,
+			in:           insightInputs{Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(1000, 60, 0), Previous: lambdaWindow(1000, 25, 0)}}},
+			wantSeverity: SeverityCritical,
+			wantMessage:  "Lambda api error rate doubled",
+		},
+		{
+			name:         "errors from none",
+			rule:         lambdaErrorRateRule,
+			in:           insightInputs{Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(1000, 20, 0), Previous: lambdaWindow(1000, 0, 0)}}},
+			wantSeverity: SeverityWarning,
+			wantMessage:  "Lambda api error rate rose to 2.0% from 0.0%",
+		},
+		{
+			name: "steady error rate",
+			rule: lambdaErrorRateRule,
+			in:   insightInputs{Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(1000, 15, 0), Previous: lambdaWindow(1000, 10, 0)}}},
+		},
+		{
+			name: "too few invocations",
+			rule: lambdaErrorRateRule,
+			in:   insightInputs{Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(50, 25, 0), Previous: lambdaWindow(50, 0, 0)}}},
+		},
+		{
+			name:         "Lambda throttled",
+			rule:         lambdaThrottleRule,
+			in:           insightInputs{Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(1000, 0, 12)}}},
+			wantSeverity: SeverityWarning,
+			wantMessage:  "Lambda api was throttled 12 times",
+		},
+		{
+			name: "Lambda not throttled",
+			rule: lambdaThrottleRule,
+			in:   insightInputs{Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(1000, 0, 0)}}},
+		},
+		{
+			name:         "over-provisioned table",
+			rule:         dynamoDBOverProvisionedRule,
+			in:           insightInputs{Window: insightsWindow, Tables: []*aws.DynamoDBMetrics{provisionedTable("users", 2, 0)}},
+			wantSeverity: SeverityInfo,
+			wantMessage:  "DynamoDB table users is over-provisioned by 90%",
+		},
+		{
+			name: "well-used table",
+			rule: dynamoDBOverProvisionedRule,
+			in:   insightInputs{Window: insightsWindow, Tables: []*aws.DynamoDBMetrics{provisionedTable("users", 15, 0)}},
+		},
+		{
+			name: "on-demand table",
+			rule: dynamoDBOverProvisionedRule,
+			in:   insightInputs{Window: insightsWindow, Tables: []*aws.DynamoDBMetrics{{TableName: "events"}}},
+		},
+		{
+			name:         "table throttled",
+			rule:         dynamoDBThrottleRule,
+			in:           insightInputs{Tables: []*aws.DynamoDBMetrics{provisionedTable("users", 15, 7)}},
+			wantSeverity: SeverityWarning,
+			wantMessage:  "DynamoDB table users throttled 7 requests",
+		},
+		{
+			name: "table not throttled",
+			rule: dynamoDBThrottleRule,
+			in:   insightInputs{Tables: []*aws.DynamoDBMetrics{provisionedTable("users", 15, 0)}},
+		},
+		{
+			name:         "projected cost up 40%",
+			rule:         costProjectionRule,
+			in:           insightInputs{Window: insightsWindow, CurrentCost: &aws.CostData{TotalCost: 140}, PreviousCost: &aws.CostData{TotalCost: 100}},
+			wantSeverity: SeverityWarning,
+			wantMessage:  "Projected monthly cost is up 40% ($600.00 vs $428.57)",
+		},
+		{
+			name:         "projected cost doubled",
+			rule:         costProjectionRule,
+			in:           insightInputs{Window: insightsWindow, CurrentCost: &aws.CostData{TotalCost: 200}, PreviousCost: &aws.CostData{TotalCost: 100}},
+			wantSeverity: SeverityCritical,
+			wantMessage:  "Projected monthly cost is up 100%",
+		},
+		{
+			name: "projected cost steady",
+			rule: costProjectionRule,
+			in:   insightInputs{Window: insightsWindow, CurrentCost: &aws.CostData{TotalCost: 110}, PreviousCost: &aws.CostData{TotalCost: 100}},
+		},
+		{
+			name: "no previous cost",
+			rule: costProjectionRule,
+			in:   insightInputs{Window: insightsWindow, CurrentCost: &aws.CostData{TotalCost: 110}, PreviousCost: &aws.CostData{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			insights := tt.rule(tt.in)
+			if tt.wantSeverity == "" {
+				if len(insights) != 0 {
+					t.Errorf("rule fired on normal data: %+v", insights)
+				}
+				return
+			}
+			if len(insights) != 1 {
+				t.Fatalf("rule returned %d insights, want 1: %+v", len(insights), insights)
+			}
+			insight := insights[0]
+			if insight.Severity != tt.wantSeverity || !strings.HasPrefix(insight.Message, tt.wantMessage) {
+				t.Errorf("insight = %s %q, want %s %q", insight.Severity, insight.Message, tt.wantSeverity, tt.wantMessage)
+			}
+			if len(insight.Evidence) == 0 {
+				t.Error("insight has no evidence")
+			}
+		})
+	}
+}
+
+func TestGenerateInsightsRanksBySeverity(t *testing.T) {
+	insights := generateInsights(insightInputs{
+		Window: insightsWindow,
+		Lambda: []lambdaComparison{{FunctionName: "api", Current: lambdaWindow(1000, 60, 3), Previous: lambdaWindow(1000, 25, 0)}},
+		Tables: []*aws.DynamoDBMetrics{provisionedTable("users", 2, 4)},
+	})
+
+	var got []string
+	for _, insight := range insights {
+		got = append(got, insight.Severity+" "+insight.Category)
+	}
+	want := "critical lambda, warning dynamodb, warning lambda, info dynamodb"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("insights ranked %v, want %s", got, want)
+	}
+
+	if insights := generateInsights(insightInputs{Window: insightsWindow}); insights == nil || len(insights) != 0 {
+		t.Errorf("insights without data = %v, want an empty list", insights)
+	}
+}
+
+func TestGetInsightsWithoutSources(t *testing.T) {
+	h := &AppHandler{}
+	rec := serveCached(h.GetInsights, "/insights")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var body struct {
+		AppID    string    `json:"appId"`
+		Insights []Insight `json:"insights"`
+		Partial  bool      `json:"partial"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.AppID != "app" || body.Insights == nil || len(body.Insights) != 0 || body.Partial {
+		t.Errorf("response = %+v, want no insights for app", body)
+	}
+}
@@ -2,37 +2,68 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 )
 
+// Detail depths of the aggregated response, selectable with the depth query parameter.
+// Summary returns totals only; detailed adds per-function, per-table and per-endpoint breakdowns.
+const (
+	DepthSummary  = "summary"
+	DepthDetailed = "detailed"
+)
+
+// IsAggregatedDepth reports whether depth is a supported aggregated response depth
+func IsAggregatedDepth(depth string) bool {
+	return depth == DepthSummary || depth == DepthDetailed
+}
+
 // MetricsAggregator handles aggregated metrics endpoints
 type MetricsAggregator struct {
-	appHandler *AppHandler
-	logger     *slog.Logger
+	appHandler   *AppHandler
+	defaultDepth string
+	logger       *slog.Logger
+	cards        appCardCache
+	cache        *metricsCache
 }
 
-// NewMetricsAggregator creates a new metrics aggregator
-func NewMetricsAggregator(appHandler *AppHandler, logger *slog.Logger) *MetricsAggregator {
+// NewMetricsAggregator creates a new metrics aggregator. defaultDepth applies when a request
+// doesn't set depth; unsupported values use DepthSummary. Responses served through Cached
+// live for cacheTTL; zero disables caching.
+func NewMetricsAggregator(appHandler *AppHandler, defaultDepth string, cacheTTL time.Duration, logger *slog.Logger) *MetricsAggregator {
+	if !IsAggregatedDepth(defaultDepth) {
+		defaultDepth = DepthSummary
+	}
 	return &MetricsAggregator{
-		appHandler: appHandler,
-		logger:     logger,
+		appHandler:   appHandler,
+		defaultDepth: defaultDepth,
+		logger:       logger,
+		cards:        appCardCache{entries: make(map[string]appCardEntry)},
+		cache:        newMetricsCache(cacheTTL),
 	}
 }
 
 // AggregatedMetrics represents combined metrics from all sources
 type AggregatedMetrics struct {
 	AppID     string                  `json:"appId"`
-	Period    string                  `json:"period"`
+	Depth     string                  `json:"depth"`
+	Period    timerange.Period        `json:"period"`
 	AWS       *AWSMetricsSummary      `json:"aws"`
 	AppStore  *AppStoreMetricsSummary `json:"appStore"`
 	Health    *HealthSummary          `json:"health"`
+	Partial   bool                    `json:"partial"`
+	Failures  []ResourceFailure       `json:"failures"`
 	Timestamp int64                   `json:"timestamp"`
 }
 
@@ -46,12 +77,41 @@ type AWSMetricsSummary struct {
 
 // LambdaSummary represents summarized Lambda metrics
 type LambdaSummary struct {
-	TotalInvocations float64 `json:"totalInvocations"`
-	TotalErrors      float64 `json:"totalErrors"`
-	ErrorRate        float64 `json:"errorRate"`
-	AverageDuration  float64 `json:"averageDuration"`
-	TotalThrottles   float64 `json:"totalThrottles"`
-	FunctionCount    int     `json:"functionCount"`
+	TotalInvocations  float64  `json:"totalInvocations"`
+	TotalErrors       float64  `json:"totalErrors"`
+	ErrorRate         float64  `json:"errorRate"`         // Raw rate, async retries included
+	AdjustedErrorRate float64  `json:"adjustedErrorRate"` // Unique failed events, async retries removed
+	SuccessRate       *float64 `json:"successRate"`       // 100 - ErrorRate; null without invocations
+	AverageDuration   float64  `json:"averageDuration"`
+	TotalThrottles    float64  `json:"totalThrottles"`
+	FunctionCount     int      `json:"functionCount"`
+
+	// Functions is only included at the detailed depth
+	Functions []LambdaFunctionBreakdown `json:"functions,omitempty"`
+}
+
+// LambdaFunctionBreakdown is one function's share of the Lambda summary
+type LambdaFunctionBreakdown struct {
+	FunctionName    string   `json:"functionName"`
+	Invocations     float64  `json:"invocations"`
+	Errors          float64  `json:"errors"`
+	ErrorRate       float64  `json:"errorRate"`
+	SuccessRate     *float64 `json:"successRate"` // Null without invocations
+	AverageDuration float64  `json:"averageDuration"`
+	Throttles       float64  `json:"throttles"`
+}
+
+// newLambdaFunctionBreakdown returns one function's share of the Lambda summary
+func newLambdaFunctionBreakdown(functionName string, metrics *aws.LambdaMetrics) LambdaFunctionBreakdown {
+	return LambdaFunctionBreakdown{
+		FunctionName:    functionName,
+		Invocations:     metrics.Invocations,
+		Errors:          metrics.Errors,
+		ErrorRate:       metrics.ErrorRate,
+		SuccessRate:     metrics.SuccessRate,
+		AverageDuration: metrics.Duration,
+		Throttles:       metrics.Throttles,
+	}
 }
 
 // APIGatewaySummary represents summarized API Gateway metrics
@@ -61,6 +121,12 @@ type APIGatewaySummary struct {
 	Total5XXErrors float64 `json:"total5xxErrors"`
 	ErrorRate      float64 `json:"errorRate"`
 	AverageLatency float64 `json:"averageLatency"`
+	LatencyP50     float64 `json:"latencyP50"`
+	LatencyP95     float64 `json:"latencyP95"`
+	LatencyP99     float64 `json:"latencyP99"`
+
+	// Endpoints is only included at the detailed depth
+	Endpoints []aws.EndpointLatency `json:"endpoints,omitempty"`
 }
 
 // DynamoDBSummary represents summarized DynamoDB metrics
@@ -72,6 +138,37 @@ type DynamoDBSummary struct {
 	TableCount         int     `json:"tableCount"`
 	TotalItemCount     int64   `json:"totalItemCount"`
 	TotalSizeBytes     int64   `json:"totalSizeBytes"`
+
+	// Tables is only included at the detailed depth
+	Tables []TableBreakdown `json:"tables,omitempty"`
+}
+
+// TableBreakdown is one table's share of the DynamoDB summary
+type TableBreakdown struct {
+	TableName      string  `json:"tableName"`
+	ReadCapacity   float64 `json:"readCapacity"`
+	WriteCapacity  float64 `json:"writeCapacity"`
+	Throttles      float64 `json:"throttles"`
+	ReadThrottles  float64 `json:"readThrottles"`
+	WriteThrottles float64 `json:"writeThrottles"`
+	Errors         float64 `json:"errors"`
+	ItemCount      int64   `json:"itemCount"`
+	SizeBytes      int64   `json:"sizeBytes"`
+}
+
+// newTableBreakdown returns one table's share of the DynamoDB summary
+func newTableBreakdown(tableName string, metrics *aws.DynamoDBMetrics) TableBreakdown {
+	return TableBreakdown{
+		TableName:      tableName,
+		ReadCapacity:   metrics.ConsumedReadCapacity,
+		WriteCapacity:  metrics.ConsumedWriteCapacity,
+		Throttles:      metrics.ThrottledRequests,
+		ReadThrottles:  metrics.ThrottledReadRequests,
+		WriteThrottles: metrics.ThrottledWriteRequests,
+		Errors:         metrics.UserErrors + metrics.SystemErrors,
+		ItemCount:      metrics.ItemCount,
+		SizeBytes:      metrics.TableSizeBytes,
+	}
 }
 
 // CostSummary represents summarized cost metrics
@@ -98,15 +195,46 @@ type AppStoreMetricsSummary struct {
 	ActiveDevices int64   `json:"activeDevices"`
 	AverageRating float64 `json:"averageRating"`
 	TotalRatings  int64   `json:"totalRatings"`
+	Crashes       int64   `json:"crashes"`
 }
 
-// HealthSummary represents overall health status
+// HealthSummary represents overall health status. Score is the weighted percentage of
+// assessed services that are healthy, each service weighing as much as its heaviest enabled
+// health signal.
 type HealthSummary struct {
 	Status           string   `json:"status"`
+	Score            float64  `json:"score"`
 	HealthyServices  int      `json:"healthyServices"`
 	DegradedServices int      `json:"degradedServices"`
 	UnknownServices  int      `json:"unknownServices"`
 	Issues           []string `json:"issues"`
+
+	healthyWeight  float64
+	degradedWeight float64
+	critical       bool
+	issues         []healthIssue
+}
+
+// newHealthSummary returns a healthy summary with nothing assessed yet
+func newHealthSummary() *HealthSummary {
+	return &HealthSummary{
+		Status: "healthy",
+		Score:  100,
+		Issues: []string{},
+	}
+}
+
+// healthy records a healthy service of the given weight
+func (s *HealthSummary) healthy(weight float64) {
+	s.HealthyServices++
+	s.healthyWeight += weight
+}
+
+// degraded records a degraded service of the given weight and the issue found with resource
+func (s *HealthSummary) degraded(weight float64, severity int, resource, issue string) {
+	s.DegradedServices++
+	s.degradedWeight += weight
+	s.issues = append(s.issues, healthIssue{severity: severity, resource: resource, message: issue})
 }
 
 // GetAggregatedMetrics returns combined metrics from all sources
@@ -117,153 +245,285 @@ func (ma *MetricsAggregator) GetAggregatedMetrics(w http.ResponseWriter, r *http
 	// Parse time range
 	startTime, endTime := parseTimeRange(r)
 
+	sections, depth, err := ma.parseAggregatedQuery(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	aggregated, outcome := ma.collectAggregatedMetrics(r.Context(), appID, startTime, endTime, sections, depth)
+
+	// Send response
+	outcome.markResponse(w)
+	writeJSON(w, outcome.StatusCode(), aggregated)
+}
+
+// parseAggregatedQuery reads the sections and depth query parameters of an aggregated request
+func (ma *MetricsAggregator) parseAggregatedQuery(r *http.Request) (aggregatedSections, string, error) {
+	sections, err := parseAggregatedSections(r.URL.Query().Get("sections"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	depth := ma.defaultDepth
+	if value := r.URL.Query().Get("depth"); value != "" {
+		if !IsAggregatedDepth(value) {
+			return nil, "", fmt.Errorf("depth must be %q or %q", DepthSummary, DepthDetailed)
+		}
+		depth = value
+	}
+	return sections, depth, nil
+}
+
+// collectAggregatedMetrics fetches the requested and enabled metrics sources concurrently,
+// recording which resources failed so partial results are flagged. At DepthDetailed the
+// AWS summaries also carry their per-resource breakdowns.
+func (ma *MetricsAggregator) collectAggregatedMetrics(ctx context.Context, appID string, startTime, endTime time.Time, sections aggregatedSections, depth string) (*AggregatedMetrics, *partialResult) {
 	// Create wait group for concurrent fetching
 	var wg sync.WaitGroup
-	ctx := r.Context()
 
 	aggregated := &AggregatedMetrics{
 		AppID:     appID,
-		Period:    formatPeriod(startTime, endTime),
+		Depth:     depth,
+		Period:    timerange.NewPeriod(startTime, endTime),
 		Timestamp: time.Now().Unix(),
 		AWS:       &AWSMetricsSummary{},
 	}
+	detailed := depth == DepthDetailed
+
+	// Per-resource outcomes across all sources
+	outcome := newPartialResult()
 
-	// Channel for collecting errors
-	errChan := make(chan error, 10)
+	features := ma.appHandler.Features
 
 	// Fetch Lambda metrics concurrently
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		summary := ma.fetchLambdaSummary(ctx, appID, startTime, endTime)
-		aggregated.AWS.Lambda = summary
-	}()
+	if features.Lambda && sections.has(sectionLambda) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary := ma.fetchLambdaSummary(ctx, appID, startTime, endTime, detailed, outcome)
+			aggregated.AWS.Lambda = summary
+		}()
+	}
 
 	// Fetch API Gateway metrics concurrently
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		summary := ma.fetchAPIGatewaySummary(ctx, appID, startTime, endTime)
-		aggregated.AWS.APIGateway = summary
-	}()
+	if sections.has(sectionAPIGateway) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary := ma.fetchAPIGatewaySummary(ctx, appID, startTime, endTime, detailed, outcome)
+			aggregated.AWS.APIGateway = summary
+		}()
+	}
 
 	// Fetch DynamoDB metrics concurrently
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		summary := ma.fetchDynamoDBSummary(ctx, appID, startTime, endTime)
-		aggregated.AWS.DynamoDB = summary
-	}()
+	if features.DynamoDB && sections.has(sectionDynamoDB) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary := ma.fetchDynamoDBSummary(ctx, appID, startTime, endTime, detailed, outcome)
+			aggregated.AWS.DynamoDB = summary
+		}()
+	}
 
 	// Fetch Cost metrics concurrently
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		summary := ma.fetchCostSummary(ctx, startTime, endTime)
-		aggregated.AWS.Cost = summary
-	}()
+	if features.Cost && sections.has(sectionCost) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary := ma.fetchCostSummary(ctx, appID, startTime, endTime, outcome)
+			aggregated.AWS.Cost = summary
+		}()
+	}
 
 	// Fetch App Store metrics if configured
-	if ma.appHandler.AppStore != nil {
+	if features.AppStore && ma.appHandler.AppStore != nil && sections.has(sectionAppStore) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			summary := ma.fetchAppStoreSummary(ctx, appID, startTime, endTime)
+			summary := ma.fetchAppStoreSummary(ctx, appID, startTime, endTime, outcome)
 			aggregated.AppStore = summary
 		}()
 	}
 
 	// Fetch health status
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		summary := ma.fetchHealthSummary(ctx, appID)
-		aggregated.Health = summary
-	}()
+	if sections.has(sectionHealth) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary := ma.fetchHealthSummary(ctx, appID)
+			aggregated.Health = summary
+		}()
+	}
 
 	// Wait for all goroutines to complete
 	wg.Wait()
-	close(errChan)
 
-	// Send response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(aggregated)
+	aggregated.Partial = outcome.Partial()
+	aggregated.Failures = outcome.Failures()
+
+	return aggregated, outcome
 }
 
-// Helper functions for fetching summaries
+// Sections of the aggregated response selectable with the sections query parameter
+const (
+	sectionLambda     = "lambda"
+	sectionAPIGateway = "apigateway"
+	sectionDynamoDB   = "dynamodb"
+	sectionCost       = "cost"
+	sectionAppStore   = "appstore"
+	sectionHealth     = "health"
+)
 
-func (ma *MetricsAggregator) fetchLambdaSummary(ctx context.Context, appID string, startTime, endTime time.Time) *LambdaSummary {
-	summary := &LambdaSummary{}
+var allAggregatedSections = []string{sectionLambda, sectionAPIGateway, sectionDynamoDB, sectionCost, sectionAppStore, sectionHealth}
+
+// aggregatedSections is the set of sections to include in an aggregated response
+type aggregatedSections map[string]bool
 
-	lambdaFunctions := ma.appHandler.AppsConfig.GetLambdaFunctions(appID)
+// allSections returns a set containing every aggregated section
+func allSections() aggregatedSections {
+	sections := make(aggregatedSections, len(allAggregatedSections))
+	for _, name := range allAggregatedSections {
+		sections[name] = true
+	}
+	return sections
+}
+
+// parseAggregatedSections parses a comma-separated section list, defaulting to every section
+func parseAggregatedSections(value string) (aggregatedSections, error) {
+	if strings.TrimSpace(value) == "" {
+		return allSections(), nil
+	}
+
+	valid := allSections()
+	sections := make(aggregatedSections)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !valid[name] {
+			return nil, fmt.Errorf("unknown section %q (valid: %s)", name, strings.Join(allAggregatedSections, ", "))
+		}
+		sections[name] = true
+	}
+	return sections, nil
+}
+
+// has reports whether a section was requested
+func (s aggregatedSections) has(name string) bool {
+	return s[name]
+}
+
+// Helper functions for fetching summaries. Each returns nil when the app has nothing
+// configured for that source, so the section is null rather than indistinguishable zeros.
+
+func (ma *MetricsAggregator) fetchLambdaSummary(ctx context.Context, appID string, startTime, endTime time.Time, detailed bool, outcome *partialResult) *LambdaSummary {
+	lambdaFunctions := ma.appHandler.ResolveLambdaFunctions(ctx, appID)
+	if len(lambdaFunctions) == 0 {
+		return nil
+	}
+
+	summary := &LambdaSummary{}
 	summary.FunctionCount = len(lambdaFunctions)
 
-	var totalDuration float64
-	var durationCount int
+	// Functions' average durations are combined weighted by invocations
+	var duration aws.WeightedMean
+	var uniqueEvents, uniqueFailures float64
 
+	// One batched call covers every function; if it fails, so does each function
+	batch, err := ma.appHandler.CloudWatch.GetLambdaMetricsBatch(ctx, lambdaFunctions, startTime, endTime)
 	for _, functionName := range lambdaFunctions {
-		metrics, err := ma.appHandler.CloudWatch.GetLambdaMetrics(ctx, functionName, startTime, endTime)
 		if err != nil {
+			outcome.failed("lambda:"+functionName, err)
 			continue
 		}
+		metrics := batch[functionName]
+		outcome.succeeded()
 
 		summary.TotalInvocations += metrics.Invocations
 		summary.TotalErrors += metrics.Errors
 		summary.TotalThrottles += metrics.Throttles
+		events, failures := aws.UniqueEventCounts(metrics.Invocations, metrics.Errors, metrics.AsyncEventsReceived)
+		uniqueEvents += events
+		uniqueFailures += failures
+
+		duration.Add(metrics.Duration, metrics.Invocations)
 
-		if metrics.Duration > 0 {
-			totalDuration += metrics.Duration
-			durationCount++
+		if detailed {
+			summary.Functions = append(summary.Functions, newLambdaFunctionBreakdown(functionName, metrics))
 		}
 	}
 
 	if summary.TotalInvocations > 0 {
 		summary.ErrorRate = (summary.TotalErrors / summary.TotalInvocations) * 100
+		summary.AdjustedErrorRate = aws.ErrorRate(uniqueEvents, uniqueFailures)
 	}
+	summary.SuccessRate = aws.SuccessRate(summary.TotalInvocations, summary.TotalErrors)
 
-	if durationCount > 0 {
-		summary.AverageDuration = totalDuration / float64(durationCount)
-	}
+	summary.AverageDuration = duration.Value()
 
 	return summary
 }
 
-func (ma *MetricsAggregator) fetchAPIGatewaySummary(ctx context.Context, appID string, startTime, endTime time.Time) *APIGatewaySummary {
-	summary := &APIGatewaySummary{}
-
+func (ma *MetricsAggregator) fetchAPIGatewaySummary(ctx context.Context, appID string, startTime, endTime time.Time, detailed bool, outcome *partialResult) *APIGatewaySummary {
 	apiName := ma.appHandler.AppsConfig.GetAPIGateway(appID)
 	if apiName == "" {
-		return summary
+		return nil
 	}
 
+	summary := &APIGatewaySummary{}
+
 	metrics, err := ma.appHandler.CloudWatch.GetAPIGatewayMetrics(ctx, apiName, startTime, endTime)
 	if err != nil {
+		outcome.failed("apigateway:"+apiName, err)
 		return summary
 	}
+	outcome.succeeded()
 
 	summary.TotalRequests = metrics.Count
 	summary.Total4XXErrors = metrics.Error4XX
 	summary.Total5XXErrors = metrics.Error5XX
 	summary.AverageLatency = metrics.Latency
+	summary.LatencyP50 = metrics.LatencyP50
+	summary.LatencyP95 = metrics.LatencyP95
+	summary.LatencyP99 = metrics.LatencyP99
 
 	if summary.TotalRequests > 0 {
 		summary.ErrorRate = ((summary.Total4XXErrors + summary.Total5XXErrors) / summary.TotalRequests) * 100
 	}
 
+	if detailed {
+		endpoints, err := ma.appHandler.CloudWatch.ListAPIEndpoints(ctx, apiName)
+		if err == nil {
+			summary.Endpoints, err = ma.appHandler.CloudWatch.GetEndpointLatencies(ctx, apiName, endpoints, startTime, endTime)
+		}
+		if err != nil {
+			outcome.failed("apigateway:"+apiName+":endpoints", err)
+		} else {
+			outcome.succeeded()
+		}
+	}
+
 	return summary
 }
 
-func (ma *MetricsAggregator) fetchDynamoDBSummary(ctx context.Context, appID string, startTime, endTime time.Time) *DynamoDBSummary {
-	summary := &DynamoDBSummary{}
-
+func (ma *MetricsAggregator) fetchDynamoDBSummary(ctx context.Context, appID string, startTime, endTime time.Time, detailed bool, outcome *partialResult) *DynamoDBSummary {
 	tables := ma.appHandler.AppsConfig.GetDynamoDBTables(appID)
+	if len(tables) == 0 {
+		return nil
+	}
+
+	summary := &DynamoDBSummary{}
 	summary.TableCount = len(tables)
 
 	for _, tableName := range tables {
 		metrics, err := ma.appHandler.DynamoDB.GetTableMetrics(ctx, tableName, startTime, endTime)
 		if err != nil {
+			outcome.failed("dynamodb:"+tableName, err)
 			continue
 		}
+		outcome.succeeded()
 
 		summary.TotalReadCapacity += metrics.ConsumedReadCapacity
 		summary.TotalWriteCapacity += metrics.ConsumedWriteCapacity
@@ -271,18 +531,24 @@ func (ma *MetricsAggregator) fetchDynamoDBSummary(ctx context.Context, appID str
 		summary.TotalErrors += metrics.UserErrors + metrics.SystemErrors
 		summary.TotalItemCount += metrics.ItemCount
 		summary.TotalSizeBytes += metrics.TableSizeBytes
+
+		if detailed {
+			summary.Tables = append(summary.Tables, newTableBreakdown(tableName, metrics))
+		}
 	}
 
 	return summary
 }
 
-func (ma *MetricsAggregator) fetchCostSummary(ctx context.Context, startTime, endTime time.Time) *CostSummary {
+func (ma *MetricsAggregator) fetchCostSummary(ctx context.Context, appID string, startTime, endTime time.Time, outcome *partialResult) *CostSummary {
 	summary := &CostSummary{}
 
-	costData, err := ma.appHandler.CostExplorer.GetCostAndUsage(ctx, startTime, endTime)
+	costData, err := ma.appHandler.GetAppCosts(ctx, appID, startTime, endTime)
 	if err != nil {
+		outcome.failed("cost", err)
 		return summary
 	}
+	outcome.succeeded()
 
 	summary.CurrentPeriod = costData.TotalCost
 
@@ -310,18 +576,20 @@ func (ma *MetricsAggregator) fetchCostSummary(ctx context.Context, startTime, en
 	return summary
 }
 
-func (ma *MetricsAggregator) fetchAppStoreSummary(ctx context.Context, appID string, startTime, endTime time.Time) *AppStoreMetricsSummary {
-	summary := &AppStoreMetricsSummary{}
-
+func (ma *MetricsAggregator) fetchAppStoreSummary(ctx context.Context, appID string, startTime, endTime time.Time, outcome *partialResult) *AppStoreMetricsSummary {
 	appStoreID := ma.appHandler.AppsConfig.GetAppStoreID(appID)
 	if appStoreID == "" {
-		return summary
+		return nil
 	}
 
+	summary := &AppStoreMetricsSummary{}
+
 	analytics, err := ma.appHandler.AppStore.GetAppAnalytics(ctx, appStoreID, startTime, endTime)
 	if err != nil {
+		outcome.failed("appstore:"+appStoreID, err)
 		return summary
 	}
+	outcome.succeeded()
 
 	summary.Downloads = analytics.Downloads
 	summary.Updates = analytics.Updates
@@ -329,6 +597,7 @@ func (ma *MetricsAggregator) fetchAppStoreSummary(ctx context.Context, appID str
 	summary.ActiveDevices = analytics.ActiveDevices
 	summary.AverageRating = analytics.Ratings.AverageRating
 	summary.TotalRatings = analytics.Ratings.TotalRatings
+	summary.Crashes = analytics.Crashes
 
 	if summary.ActiveDevices > 0 {
 		summary.ARPU = summary.Revenue / float64(summary.ActiveDevices)
@@ -338,107 +607,144 @@ func (ma *MetricsAggregator) fetchAppStoreSummary(ctx context.Context, appID str
 }
 
 func (ma *MetricsAggregator) fetchHealthSummary(ctx context.Context, appID string) *HealthSummary {
-	summary := &HealthSummary{
-		Status: "healthy",
-		Issues: []string{},
-	}
-
-	// Get current time for recent metrics (last hour)
-	endTime := time.Now()
-	startTime := endTime.Add(-1 * time.Hour)
+	summary := newHealthSummary()
 
-	// Check Lambda health
-	lambdaFunctions := ma.appHandler.AppsConfig.GetLambdaFunctions(appID)
-	for _, functionName := range lambdaFunctions {
-		metrics, err := ma.appHandler.CloudWatch.GetLambdaMetrics(ctx, functionName, startTime, endTime)
-		if err != nil {
-			summary.UnknownServices++
-			continue
-		}
+	// Signals the app is scored on; a service is fetched only when one of its signals is enabled
+	signals := ma.appHandler.AppsConfig.GetHealthSignals(appID)
+	lambdaWeight, apiWeight, dynamoWeight := serviceWeights(signals)
 
-		errorRate := float64(0)
-		if metrics.Invocations > 0 {
-			errorRate = (metrics.Errors / metrics.Invocations) * 100
-		}
+	// Check Lambda, API Gateway and DynamoDB health against the alert rules
+	metrics := ma.appHandler.fetchHealthMetrics(ctx, appID, lambdaWeight > 0, apiWeight > 0, dynamoWeight > 0, newPartialResult())
+	summary.assessServices(metrics, ma.appHandler.evaluateHealth(appID, metrics), signals)
 
-		if errorRate > 5 {
-			summary.DegradedServices++
-			summary.Issues = append(summary.Issues,
-				formatIssue("Lambda %s has high error rate: %.2f%%", functionName, errorRate))
-		} else if metrics.Throttles > 0 {
-			summary.DegradedServices++
-			summary.Issues = append(summary.Issues,
-				formatIssue("Lambda %s is being throttled", functionName))
-		} else {
-			summary.HealthyServices++
-		}
-	}
-
-	// Check API Gateway health
-	apiName := ma.appHandler.AppsConfig.GetAPIGateway(appID)
-	if apiName != "" {
-		apiMetrics, err := ma.appHandler.CloudWatch.GetAPIGatewayMetrics(ctx, apiName, startTime, endTime)
-		if err != nil {
+	// Check App Store crash trend. Crash reports are daily, so the latest reported day is
+	// compared against the week before it rather than the last hour.
+	appCrashes := signals.Weight(config.HealthSignalAppCrashes)
+	appStoreID := ma.appHandler.AppsConfig.GetAppStoreID(appID)
+	if ma.appHandler.Features.AppStore && ma.appHandler.AppStore != nil && appStoreID != "" && appCrashes > 0 {
+		endTime := time.Now()
+		crashes, err := ma.appHandler.AppStore.GetCrashMetrics(ctx, appStoreID, endTime.AddDate(0, 0, -(crashBaselineDays+1)), endTime)
+		switch {
+		case errors.Is(err, appstore.ErrCrashReportUnavailable):
+			// No crash reports requested for this app; nothing to assess
+		case err != nil:
 			summary.UnknownServices++
-		} else {
-			errorRate := float64(0)
-			if apiMetrics.Count > 0 {
-				errorRate = ((apiMetrics.Error4XX + apiMetrics.Error5XX) / apiMetrics.Count) * 100
-			}
-
-			if errorRate > 5 {
-				summary.DegradedServices++
-				summary.Issues = append(summary.Issues,
-					formatIssue("API Gateway has high error rate: %.2f%%", errorRate))
-			} else if apiMetrics.Latency > 1000 {
-				summary.DegradedServices++
-				summary.Issues = append(summary.Issues,
-					formatIssue("API Gateway has high latency: %.0fms", apiMetrics.Latency))
+		default:
+			if latest, baseline, spiking := crashSpike(crashes.Daily); spiking {
+				summary.degraded(appCrashes, issueSeverityError, "appstore:"+appStoreID,
+					formatIssue("App crashes spiked to %d on %s (daily average %.1f)", latest.Crashes, latest.Date, baseline))
 			} else {
-				summary.HealthyServices++
+				summary.healthy(appCrashes)
 			}
 		}
 	}
 
-	// Check DynamoDB health
-	tables := ma.appHandler.AppsConfig.GetDynamoDBTables(appID)
-	for _, tableName := range tables {
-		metrics, err := ma.appHandler.DynamoDB.GetTableMetrics(ctx, tableName, startTime, endTime)
-		if err != nil {
-			summary.UnknownServices++
-			continue
-		}
+	summary.finish()
+	return summary
+}
 
-		if metrics.ThrottledRequests > 0 {
-			summary.DegradedServices++
-			summary.Issues = append(summary.Issues,
-				formatIssue("DynamoDB table %s is being throttled", tableName))
-		} else if metrics.SystemErrors > 0 {
-			summary.DegradedServices++
-			summary.Issues = append(summary.Issues,
-				formatIssue("DynamoDB table %s has system errors", tableName))
-		} else {
-			summary.HealthyServices++
+// serviceWeights returns the weight of each AWS service in the health score: its heaviest
+// enabled signal, or 0 when none of its signals is enabled
+func serviceWeights(signals config.HealthSignalWeights) (lambda, apiGateway, dynamoDB float64) {
+	lambda = max(signals.Weight(config.HealthSignalLambdaErrors), signals.Weight(config.HealthSignalLambdaThrottles))
+	apiGateway = max(signals.Weight(config.HealthSignalAPIErrors), signals.Weight(config.HealthSignalAPILatency))
+	dynamoDB = max(signals.Weight(config.HealthSignalDynamoDBThrottles), signals.Weight(config.HealthSignalDynamoDBSystemErrors))
+	return lambda, apiGateway, dynamoDB
+}
+
+// assessServices scores each fetched AWS resource on the alerts firing for its enabled
+// signals. Resources whose metrics could not be fetched are counted as unknown.
+func (s *HealthSummary) assessServices(metrics HealthMetrics, alerts []Alert, signals config.HealthSignalWeights) {
+	lambdaWeight, apiWeight, dynamoWeight := serviceWeights(signals)
+	byResource := alertsByResource(alerts)
+	assess := func(fetched bool, weight float64, resource string) {
+		if !fetched {
+			s.UnknownServices++
+			return
+		}
+		alert, ok := worstAlert(byResource[resource], signals)
+		if !ok {
+			s.healthy(weight)
+			return
 		}
+		severity := issueSeverityWarning
+		if alert.Severity == AlertSeverityCritical {
+			severity = issueSeverityError
+			s.critical = true
+		}
+		s.degraded(weight, severity, resource, alert.Message)
 	}
-
-	// Update overall status
-	if summary.DegradedServices > 0 {
-		summary.Status = "degraded"
+	if lambdaWeight > 0 {
+		for _, functionName := range sortedKeys(metrics.Lambda) {
+			assess(metrics.Lambda[functionName] != nil, lambdaWeight, "lambda:"+functionName)
+		}
 	}
-	if summary.DegradedServices > summary.HealthyServices {
-		summary.Status = "critical"
+	if apiWeight > 0 && metrics.APIName != "" {
+		assess(metrics.APIGateway != nil, apiWeight, "apigateway:"+metrics.APIName)
+	}
+	if dynamoWeight > 0 {
+		for _, tableName := range sortedKeys(metrics.DynamoDB) {
+			assess(metrics.DynamoDB[tableName] != nil, dynamoWeight, "dynamodb:"+tableName)
+		}
 	}
+}
 
-	return summary
+// finish sets the overall score, status and issues from the services assessed
+func (s *HealthSummary) finish() {
+	s.Issues = issueMessages(s.issues)
+	if assessed := s.healthyWeight + s.degradedWeight; assessed > 0 {
+		s.Score = s.healthyWeight / assessed * 100
+	}
+	if s.DegradedServices > 0 {
+		s.Status = "degraded"
+	}
+	if s.critical || s.degradedWeight > s.healthyWeight {
+		s.Status = "critical"
+	}
 }
 
-func formatPeriod(startTime, endTime time.Time) string {
-	return formatTime(startTime) + " to " + formatTime(endTime)
+// worstAlert returns the most severe of a resource's alerts whose health signal is enabled,
+// preferring the first of equally severe ones
+func worstAlert(alerts []Alert, signals config.HealthSignalWeights) (Alert, bool) {
+	var worst Alert
+	found := false
+	for _, alert := range alerts {
+		if signals.Weight(alert.signal) <= 0 {
+			continue
+		}
+		if !found || alert.Severity == AlertSeverityCritical && worst.Severity != AlertSeverityCritical {
+			worst = alert
+			found = true
+		}
+	}
+	return worst, found
 }
 
-func formatTime(t time.Time) string {
-	return t.Format("2006-01-02 15:04:05")
+// Crash spike detection: the latest reported day is a spike when it has at least
+// minCrashSpike crashes and more than crashSpikeFactor times the daily average of the
+// crashBaselineDays before it
+const (
+	crashBaselineDays = 7
+	crashSpikeFactor  = 2
+	minCrashSpike     = 10
+)
+
+// crashSpike reports whether the latest day in daily, which is oldest first, spiked above the
+// baseline of the days before it. Days missing from daily had no crashes.
+func crashSpike(daily []appstore.DailyCrashes) (appstore.DailyCrashes, float64, bool) {
+	if len(daily) == 0 {
+		return appstore.DailyCrashes{}, 0, false
+	}
+	latest := daily[len(daily)-1]
+
+	var previous int64
+	for _, day := range daily[:len(daily)-1] {
+		previous += day.Crashes
+	}
+	baseline := float64(previous) / crashBaselineDays
+
+	spiking := latest.Crashes >= minCrashSpike && float64(latest.Crashes) > crashSpikeFactor*baseline
+	return latest, baseline, spiking
 }
 
 func formatIssue(format string, args ...interface{}) string {
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// newTestAggregator returns an aggregator for an app whose only data source is the App Store
+func newTestAggregator(appStore appstore.Client) *MetricsAggregator {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appHandler := &AppHandler{
+		AppStore: appStore,
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"app": {ID: "app", AppStoreID: "1234567890"},
+		}},
+		Features: appconfig.FeatureFlags{AppStore: true},
+		Logger:   logger,
+	}
+	return NewMetricsAggregator(appHandler, DepthSummary, 0, logger)
+}
+
+func TestAggregatedMetricsSections(t *testing.T) {
+	tests := []struct {
+		name         string
+		sections     string
+		wantAppStore bool
+		wantHealth   bool
+	}{
+		{name: "default is every section", sections: "", wantAppStore: true, wantHealth: true},
+		{name: "App Store only", sections: "appstore", wantAppStore: true},
+		{name: "names are trimmed and case-insensitive", sections: " AppStore ,", wantAppStore: true},
+		{name: "without App Store", sections: "health,lambda", wantHealth: true},
+		{name: "AWS only", sections: "lambda,apigateway,dynamodb,cost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{Downloads: 42}}
+			aggregator := newTestAggregator(appStore)
+
+			rec := serveCached(aggregator.GetAggregatedMetrics, cachedPath+"&sections="+url.QueryEscape(tt.sections))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			var metrics AggregatedMetrics
+			if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			if got := metrics.AppStore != nil; got != tt.wantAppStore {
+				t.Errorf("App Store section present = %v, want %v", got, tt.wantAppStore)
+			}
+			if tt.wantAppStore && metrics.AppStore.Downloads != 42 {
+				t.Errorf("App Store downloads = %d, want 42", metrics.AppStore.Downloads)
+			}
+			wantCalls := int32(0)
+			if tt.wantAppStore {
+				wantCalls = 1
+			}
+			if got := appStore.calls.Load(); got != wantCalls {
+				t.Errorf("App Store fetched %d times, want %d", got, wantCalls)
+			}
+			if got := metrics.Health != nil; got != tt.wantHealth {
+				t.Errorf("health section present = %v, want %v", got, tt.wantHealth)
+			}
+		})
+	}
+}
+
+func TestAggregatedMetricsUnknownSection(t *testing.T) {
+	appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{}}
+	aggregator := newTestAggregator(appStore)
+
+	rec := serveCached(aggregator.GetAggregatedMetrics, cachedPath+"&sections=appstore,revenue")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if got := appStore.calls.Load(); got != 0 {
+		t.Errorf("App Store fetched %d times for a rejected request", got)
+	}
+}
+
+func TestAggregatedMetricsNullUnconfiguredSections(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appHandler := &AppHandler{
+		AppStore: &fakeAppStore{analytics: &appstore.AppAnalytics{}},
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"configured": {ID: "configured", AppStoreID: "1234567890"},
+			"bare":       {ID: "bare"},
+		}},
+		Features: appconfig.FeatureFlags{Lambda: true, DynamoDB: true, AppStore: true},
+		Logger:   logger,
+	}
+	aggregator := NewMetricsAggregator(appHandler, DepthSummary, 0, logger)
+
+	tests := []struct {
+		appID        string
+		wantAppStore bool
+	}{
+		{appID: "bare"},
+		{appID: "configured", wantAppStore: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.appID, func(t *testing.T) {
+			target := cachedPath + "&sections=appstore,lambda,apigateway,dynamodb"
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, target, nil), map[string]string{"appId": tt.appID})
+			rec := httptest.NewRecorder()
+			aggregator.GetAggregatedMetrics(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+
+			var body struct {
+				AWS      map[string]json.RawMessage `json:"aws"`
+				AppStore json.RawMessage            `json:"appStore"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			// A configured App Store section is present even when every figure is zero
+			if got := string(body.AppStore) != "null"; got != tt.wantAppStore {
+				t.Errorf("appStore = %s, want present %v", body.AppStore, tt.wantAppStore)
+			}
+			for _, section := range []string{"lambda", "apiGateway", "dynamoDB"} {
+				if got := string(body.AWS[section]); got != "null" {
+					t.Errorf("aws.%s = %s, want null with nothing configured", section, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregatedMetricsDepth(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultDepth string
+		query        string
+		want         string
+	}{
+		{name: "default", defaultDepth: DepthSummary, want: DepthSummary},
+		{name: "configured default", defaultDepth: DepthDetailed, want: DepthDetailed},
+		{name: "unsupported default", defaultDepth: "full", want: DepthSummary},
+		{name: "summary", defaultDepth: DepthDetailed, query: "&depth=summary", want: DepthSummary},
+		{name: "detailed", defaultDepth: DepthSummary, query: "&depth=detailed", want: DepthDetailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := newTestAggregator(&fakeAppStore{analytics: &appstore.AppAnalytics{}})
+			aggregator := NewMetricsAggregator(base.appHandler, tt.defaultDepth, 0, base.logger)
+
+			rec := serveCached(aggregator.GetAggregatedMetrics, cachedPath+tt.query)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			var metrics AggregatedMetrics
+			if err := json.Unmarshal(rec.Body.Bytes(), &metrics); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if metrics.Depth != tt.want {
+				t.Errorf("depth = %q, want %q", metrics.Depth, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregatedMetricsUnknownDepth(t *testing.T) {
+	appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{}}
+	aggregator := newTestAggregator(appStore)
+
+	rec := serveCached(aggregator.GetAggregatedMetrics, cachedPath+"&depth=full")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if got := appStore.calls.Load(); got != 0 {
+		t.Errorf("App Store fetched %d times for a rejected request", got)
+	}
+}
+
+func TestBreakdownsCarryEachResource(t *testing.T) {
+	successRate := 97.5
+	function := newLambdaFunctionBreakdown("checkout", &aws.LambdaMetrics{
+		FunctionName: "ignored", Invocations: 400, Errors: 10, ErrorRate: 2.5, SuccessRate: &successRate, Duration: 120, Throttles: 3,
+	})
+	wantFunction := LambdaFunctionBreakdown{FunctionName: "checkout", Invocations: 400, Errors: 10, ErrorRate: 2.5, SuccessRate: &successRate, AverageDuration: 120, Throttles: 3}
+	if function != wantFunction {
+		t.Errorf("function breakdown = %+v, want %+v", function, wantFunction)
+	}
+
+	table := newTableBreakdown("orders", &aws.DynamoDBMetrics{
+		ConsumedReadCapacity: 50, ConsumedWriteCapacity: 20, ThrottledRequests: 7, ThrottledReadRequests: 5, ThrottledWriteRequests: 2,
+		UserErrors: 4, SystemErrors: 1, ItemCount: 1200, TableSizeBytes: 4096,
+	})
+	wantTable := TableBreakdown{TableName: "orders", ReadCapacity: 50, WriteCapacity: 20, Throttles: 7, ReadThrottles: 5, WriteThrottles: 2, Errors: 5, ItemCount: 1200, SizeBytes: 4096}
+	if table != wantTable {
+		t.Errorf("table breakdown = %+v, want %+v", table, wantTable)
+	}
+}
+
+func TestSummaryDepthOmitsBreakdowns(t *testing.T) {
+	summaries := []struct {
+		name     string
+		key      string
+		summary  any
+		detailed any
+	}{
+		{
+			name:     "Lambda",
+			key:      "functions",
+			summary:  &LambdaSummary{FunctionCount: 1},
+			detailed: &LambdaSummary{FunctionCount: 1, Functions: []LambdaFunctionBreakdown{{FunctionName: "checkout"}}},
+		},
+		{
+			name:     "API Gateway",
+			key:      "endpoints",
+			summary:  &APIGatewaySummary{TotalRequests: 10},
+			detailed: &APIGatewaySummary{TotalRequests: 10, Endpoints: []aws.EndpointLatency{{APIEndpoint: aws.APIEndpoint{Method: "GET", Resource: "/items"}}}},
+		},
+		{
+			name:     "DynamoDB",
+			key:      "tables",
+			summary:  &DynamoDBSummary{TableCount: 1},
+			detailed: &DynamoDBSummary{TableCount: 1, Tables: []TableBreakdown{{TableName: "orders"}}},
+		},
+	}
+
+	for _, tt := range summaries {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, c := range []struct {
+				summary any
+				want    bool
+			}{{tt.summary, false}, {tt.detailed, true}} {
+				data, err := json.Marshal(c.summary)
+				if err != nil {
+					t.Fatalf("marshal summary: %v", err)
+				}
+				var fields map[string]json.RawMessage
+				if err := json.Unmarshal(data, &fields); err != nil {
+					t.Fatalf("decode summary: %v", err)
+				}
+				if _, ok := fields[tt.key]; ok != c.want {
+					t.Errorf("%s = %s, want present %v", tt.key, fields[tt.key], c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthSignalsSelectWhatIsScored(t *testing.T) {
+	// The Lambda has a degraded error rate, the API is healthy and the table is critically throttled
+	metrics := HealthMetrics{
+		Lambda:     map[string]*aws.LambdaMetrics{"api": {Invocations: 1000, Errors: 200}},
+		APIName:    "rest",
+		APIGateway: &aws.APIGatewayMetrics{Count: 1000, LatencyP99: 5},
+		DynamoDB:   map[string]*aws.DynamoDBMetrics{"users": {ThrottledRequests: 60}},
+	}
+	alerts := EvaluateHealth(metrics, testAlertRules())
+
+	tests := []struct {
+		name         string
+		signals      string
+		wantStatus   string
+		wantScore    float64
+		wantHealthy  int
+		wantDegraded int
+		wantIssues   int
+	}{
+		{name: "every signal by default", wantStatus: "critical", wantScore: 100.0 / 3, wantHealthy: 1, wantDegraded: 2, wantIssues: 2},
+		{name: "DynamoDB disabled", signals: "lambda_errors,lambda_throttles,api_errors,api_latency", wantStatus: "degraded", wantScore: 50, wantHealthy: 1, wantDegraded: 1, wantIssues: 1},
+		{name: "only Lambda throttles", signals: "lambda_throttles", wantStatus: "healthy", wantScore: 100, wantHealthy: 1},
+		{name: "only API latency", signals: "api_latency,dynamodb_throttles=0", wantStatus: "healthy", wantScore: 100, wantHealthy: 1},
+		{name: "weighted Lambda outweighs a healthy API", signals: "lambda_errors=3,api_errors", wantStatus: "critical", wantScore: 25, wantHealthy: 1, wantDegraded: 1, wantIssues: 1},
+		{name: "weighted API outweighs a degraded Lambda", signals: "lambda_errors,api_errors=3", wantStatus: "degraded", wantScore: 75, wantHealthy: 1, wantDegraded: 1, wantIssues: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := newHealthSummary()
+			summary.assessServices(metrics, alerts, appconfig.ParseHealthSignals(tt.signals))
+			summary.finish()
+
+			if summary.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", summary.Status, tt.wantStatus)
+			}
+			if diff := summary.Score - tt.wantScore; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("score = %v, want %v", summary.Score, tt.wantScore)
+			}
+			if summary.HealthyServices != tt.wantHealthy || summary.DegradedServices != tt.wantDegraded {
+				t.Errorf("services = %d healthy, %d degraded, want %d, %d", summary.HealthyServices, summary.DegradedServices, tt.wantHealthy, tt.wantDegraded)
+			}
+			if len(summary.Issues) != tt.wantIssues {
+				t.Errorf("issues = %q, want %d", summary.Issues, tt.wantIssues)
+			}
+		})
+	}
+}
+
+func TestHealthSignalsToggleCrashCheck(t *testing.T) {
+	tests := []struct {
+		signals     string
+		wantUnknown int
+	}{
+		// The fake App Store fails crash lookups, so an assessed crash signal is unknown
+		{signals: "app_crashes", wantUnknown: 1},
+		{signals: "lambda_errors", wantUnknown: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signals, func(t *testing.T) {
+			aggregator := newTestAggregator(&fakeAppStore{})
+			aggregator.appHandler.AppsConfig.Apps["app"].HealthSignals = appconfig.ParseHealthSignals(tt.signals)
+
+			summary := aggregator.fetchHealthSummary(context.Background(), "app")
+			if summary.UnknownServices != tt.wantUnknown {
+				t.Errorf("unknown services = %d, want %d", summary.UnknownServices, tt.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestSuccessRateIsNullWithoutInvocations(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics *aws.LambdaMetrics
+		want    string
+	}{
+		{name: "with errors", metrics: &aws.LambdaMetrics{Invocations: 200, Errors: 10, SuccessRate: aws.SuccessRate(200, 10)}, want: "95"},
+		{name: "no errors", metrics: &aws.LambdaMetrics{Invocations: 200, SuccessRate: aws.SuccessRate(200, 0)}, want: "100"},
+		{name: "zero invocations", metrics: &aws.LambdaMetrics{SuccessRate: aws.SuccessRate(0, 0)}, want: "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			function := newLambdaFunctionBreakdown("fn", tt.metrics)
+			summary := LambdaSummary{SuccessRate: aws.SuccessRate(tt.metrics.Invocations, tt.metrics.Errors)}
+
+			for name, value := range map[string]any{"function": function, "summary": summary} {
+				data, err := json.Marshal(value)
+				if err != nil {
+					t.Fatalf("marshal %s: %v", name, err)
+				}
+				var fields map[string]json.RawMessage
+				if err := json.Unmarshal(data, &fields); err != nil {
+					t.Fatalf("decode %s: %v", name, err)
+				}
+				if got := string(fields["successRate"]); got != tt.want {
+					t.Errorf("%s successRate = %s, want %s", name, got, tt.want)
+				}
+			}
+		})
+	}
+}
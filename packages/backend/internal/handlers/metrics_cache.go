@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/cache"
+)
+
+// DefaultMetricsCacheTTL is how long metrics responses are cached by default
+const DefaultMetricsCacheTTL = time.Minute
+
+const (
+	// metricsCacheHeader reports whether a response was served from the metrics cache
+	metricsCacheHeader = "X-Cache"
+
+	// noCacheParam bypasses the metrics cache when true
+	noCacheParam = "noCache"
+)
+
+// Query parameters captured separately in metricsCacheKey rather than in its metric type
+var metricsCacheKeyParams = map[string]bool{
+	"start":      true,
+	"end":        true,
+	"range":      true,
+	"interval":   true,
+	noCacheParam: true,
+}
+
+// metricsCacheKey identifies a cached metrics response. Start and end are truncated to the
+// cache TTL so polls of a range ending now share an entry until it expires.
+type metricsCacheKey struct {
+	appID      string
+	metricType string
+	start      time.Time
+	end        time.Time
+	interval   string
+}
+
+// cachedMetricsResponse is a successful metrics response as it was written
+type cachedMetricsResponse struct {
+	contentType string
+	body        []byte
+}
+
+// cloneMetricsResponse copies a cached response so readers never share its body
+func cloneMetricsResponse(response cachedMetricsResponse) cachedMetricsResponse {
+	response.body = append([]byte(nil), response.body...)
+	return response
+}
+
+// metricsCache caches expensive metrics responses for a short TTL. A nil cache disables caching.
+type metricsCache struct {
+	entries *cache.TTLCache[metricsCacheKey, cachedMetricsResponse]
+}
+
+// newMetricsCache creates a cache for ttl, returning nil to disable caching when ttl isn't positive
+func newMetricsCache(ttl time.Duration) *metricsCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &metricsCache{entries: cache.New[metricsCacheKey](ttl, cloneMetricsResponse)}
+}
+
+// wrap serves next's successful responses from cache, keyed on the app, metricType and the
+// request's time range, interval and remaining query parameters. ?noCache=true bypasses the
+// cache and refreshes the entry.
+func (c *metricsCache) wrap(metricType string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c == nil {
+			next(w, r)
+			return
+		}
+
+		key := c.key(r, metricType)
+		bypass, _ := strconv.ParseBool(r.URL.Query().Get(noCacheParam))
+		if !bypass {
+			if cached, ok := c.entries.Get(key); ok {
+				w.Header().Set("Content-Type", cached.contentType)
+				w.Header().Set(metricsCacheHeader, "HIT")
+				w.WriteHeader(http.StatusOK)
+				w.Write(cached.body)
+				return
+			}
+		}
+
+		w.Header().Set(metricsCacheHeader, "MISS")
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next(recorder, r)
+
+		// Errors and responses missing failed resources aren't cached so the next poll retries
+		// the failed sources
+		if recorder.statusCode == http.StatusOK && !recorder.partial {
+			c.entries.Set(key, cachedMetricsResponse{
+				contentType: recorder.Header().Get("Content-Type"),
+				body:        recorder.body.Bytes(),
+			})
+		}
+	}
+}
+
+// key builds the cache key for a request
+func (c *metricsCache) key(r *http.Request, metricType string) metricsCacheKey {
+	query := r.URL.Query()
+	for param := range metricsCacheKeyParams {
+		query.Del(param)
+	}
+	if encoded := query.Encode(); encoded != "" {
+		metricType += "?" + encoded
+	}
+
+	startTime, endTime := parseTimeRange(r)
+	ttl := c.entries.TTL()
+	return metricsCacheKey{
+		appID:      mux.Vars(r)["appId"],
+		metricType: metricType,
+		start:      startTime.Truncate(ttl),
+		end:        endTime.Truncate(ttl),
+		interval:   r.URL.Query().Get("interval"),
+	}
+}
+
+// Cached serves an aggregated metrics handler through the metrics cache under metricType
+func (ma *MetricsAggregator) Cached(metricType string, next http.HandlerFunc) http.HandlerFunc {
+	return ma.cache.wrap(metricType, next)
+}
+
+// Cached serves an ECharts handler through the metrics cache under metricType
+func (h *EChartsHandler) Cached(metricType string, next http.HandlerFunc) http.HandlerFunc {
+	return h.cache.wrap(metricType, next)
+}
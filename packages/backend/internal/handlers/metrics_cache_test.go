@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// cachedPath requests a fixed time range, so cache keys don't change as the clock moves
+const cachedPath = "/metrics?start=2024-01-01T00:00:00Z&end=2024-01-02T00:00:00Z"
+
+// countingHandler writes a partial result whose failures come from fail, counting its calls
+func countingHandler(calls *int, fail func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		outcome := newPartialResult()
+		outcome.succeeded()
+		if fail() {
+			outcome.failed("lambda:fn", errors.New("unavailable"))
+		}
+		outcome.writeJSON(w, map[string]interface{}{"calls": *calls})
+	}
+}
+
+func serveCached(handler http.HandlerFunc, target string) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, target, nil), map[string]string{"appId": "app"})
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestMetricsCacheServesHits(t *testing.T) {
+	calls := 0
+	handler := newMetricsCache(time.Minute).wrap("test", countingHandler(&calls, func() bool { return false }))
+
+	first := serveCached(handler, cachedPath)
+	if got := first.Header().Get(metricsCacheHeader); got != "MISS" {
+		t.Errorf("first %s = %q, want MISS", metricsCacheHeader, got)
+	}
+
+	second := serveCached(handler, cachedPath)
+	if got := second.Header().Get(metricsCacheHeader); got != "HIT" {
+		t.Errorf("second %s = %q, want HIT", metricsCacheHeader, got)
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Errorf("cached body = %q, want %q", second.Body.String(), first.Body.String())
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+
+	serveCached(handler, cachedPath+"&sections=lambda")
+	if calls != 2 {
+		t.Errorf("handler called %d times after a different query, want 2", calls)
+	}
+}
+
+func TestMetricsCacheNoCacheBypass(t *testing.T) {
+	calls := 0
+	handler := newMetricsCache(time.Minute).wrap("test", countingHandler(&calls, func() bool { return false }))
+
+	serveCached(handler, cachedPath)
+	bypassed := serveCached(handler, cachedPath+"&noCache=true")
+	if got := bypassed.Header().Get(metricsCacheHeader); got != "MISS" {
+		t.Errorf("bypassed %s = %q, want MISS", metricsCacheHeader, got)
+	}
+	if calls != 2 {
+		t.Fatalf("handler called %d times, want 2", calls)
+	}
+
+	// The bypass refreshed the entry, which later requests are served
+	refreshed := serveCached(handler, cachedPath)
+	if got := refreshed.Header().Get(metricsCacheHeader); got != "HIT" {
+		t.Errorf("%s after bypass = %q, want HIT", metricsCacheHeader, got)
+	}
+	if refreshed.Body.String() != bypassed.Body.String() {
+		t.Errorf("body after bypass = %q, want the refreshed %q", refreshed.Body.String(), bypassed.Body.String())
+	}
+}
+
+func TestMetricsCacheSkipsPartialResults(t *testing.T) {
+	calls := 0
+	failing := true
+	handler := newMetricsCache(time.Minute).wrap("test", countingHandler(&calls, func() bool { return failing }))
+
+	partial := serveCached(handler, cachedPath)
+	if partial.Code != http.StatusOK {
+		t.Fatalf("partial status = %d, want %d", partial.Code, http.StatusOK)
+	}
+
+	failing = false
+	retried := serveCached(handler, cachedPath)
+	if got := retried.Header().Get(metricsCacheHeader); got != "MISS" {
+		t.Errorf("%s after a partial result = %q, want MISS", metricsCacheHeader, got)
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestMetricsCacheSkipsErrors(t *testing.T) {
+	calls := 0
+	handler := newMetricsCache(time.Minute).wrap("test", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeError(w, "unavailable", http.StatusBadGateway)
+	})
+
+	serveCached(handler, cachedPath)
+	serveCached(handler, cachedPath)
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestMetricsCacheExpiry(t *testing.T) {
+	calls := 0
+	ttl := 20 * time.Millisecond
+	handler := newMetricsCache(ttl).wrap("test", countingHandler(&calls, func() bool { return false }))
+
+	serveCached(handler, cachedPath)
+	time.Sleep(2 * ttl)
+	expired := serveCached(handler, cachedPath)
+	if got := expired.Header().Get(metricsCacheHeader); got != "MISS" {
+		t.Errorf("%s after the TTL = %q, want MISS", metricsCacheHeader, got)
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestMetricsCacheDisabled(t *testing.T) {
+	calls := 0
+	handler := newMetricsCache(0).wrap("test", countingHandler(&calls, func() bool { return false }))
+
+	serveCached(handler, cachedPath)
+	serveCached(handler, cachedPath)
+	if calls != 2 {
+		t.Errorf("handler called %d times with caching disabled, want 2", calls)
+	}
+}
+
+func TestMetricsCacheConcurrentAccess(t *testing.T) {
+	var calls atomic.Int32
+	handler := newMetricsCache(time.Minute).wrap("test", func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeJSON(w, http.StatusOK, map[string]string{"sections": r.URL.Query().Get("sections")})
+	})
+	sections := []string{"lambda", "cost", "appstore", "health"}
+
+	// Every goroutine reads and fills the same few entries at once
+	var wg sync.WaitGroup
+	errs := make(chan string, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(section string) {
+			defer wg.Done()
+			rec := serveCached(handler, cachedPath+"&sections="+section)
+			if want := `{"sections":"` + section + `"}`; strings.TrimSpace(rec.Body.String()) != want {
+				errs <- fmt.Sprintf("%s: body = %s, want %s", section, rec.Body, want)
+			}
+		}(sections[i%len(sections)])
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Concurrent misses may each fetch, but once filled every entry is served from cache
+	filled := calls.Load()
+	if filled < int32(len(sections)) || filled > 200 {
+		t.Errorf("handler called %d times for %d keys", filled, len(sections))
+	}
+	for _, section := range sections {
+		rec := serveCached(handler, cachedPath+"&sections="+section)
+		if got := rec.Header().Get(metricsCacheHeader); got != "HIT" {
+			t.Errorf("%s: %s = %q after concurrent fills, want HIT", section, metricsCacheHeader, got)
+		}
+	}
+	if got := calls.Load(); got != filled {
+		t.Errorf("handler called %d more times once the cache was filled", got-filled)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// prometheusContentType is the content type of the Prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// promNamespace prefixes every metric name, as the auth and cache counters are
+const promNamespace = "central_analytics_"
+
+// Prometheus metric types
+const (
+	promCounter = "counter"
+	promGauge   = "gauge"
+)
+
+// GetPrometheusMetrics renders the detailed aggregated metrics in the Prometheus text
+// exposition format, one series per resource labelled with app, service and resource. Counts
+// are counters and cover the requested range (last 24 hours by default); rates, latencies,
+// sizes, costs and scores are gauges. Resources that couldn't be fetched are reported by
+// central_analytics_resource_up rather than failing the scrape.
+func (ma *MetricsAggregator) GetPrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	startTime, endTime := parseTimeRange(r)
+
+	sections, err := parseAggregatedSections(r.URL.Query().Get("sections"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Per-resource labels need the per-resource breakdowns
+	aggregated, outcome := ma.collectAggregatedMetrics(r.Context(), appID, startTime, endTime, sections, DepthDetailed)
+	outcome.markResponse(w)
+
+	exposition := newPromExposition(appID)
+	ma.exposeAWSMetrics(exposition, aggregated.AWS)
+	if s := aggregated.AppStore; s != nil {
+		resource := ma.appHandler.AppsConfig.GetAppStoreID(appID)
+		exposition.add("appstore_downloads_total", promCounter, "App Store first-time downloads.", "appstore", resource, float64(s.Downloads))
+		exposition.add("appstore_updates_total", promCounter, "App Store updates.", "appstore", resource, float64(s.Updates))
+		exposition.add("appstore_crashes_total", promCounter, "Crashes reported through App Store Connect.", "appstore", resource, float64(s.Crashes))
+		exposition.add("appstore_revenue", promGauge, "App Store revenue.", "appstore", resource, s.Revenue)
+		exposition.add("appstore_active_devices", promGauge, "Devices with at least one session.", "appstore", resource, float64(s.ActiveDevices))
+		exposition.add("appstore_rating_average", promGauge, "Average App Store rating.", "appstore", resource, s.AverageRating)
+		exposition.add("appstore_ratings", promGauge, "Number of App Store ratings.", "appstore", resource, float64(s.TotalRatings))
+	}
+	if s := aggregated.Health; s != nil {
+		exposition.add("health_score", promGauge, "Weighted percentage of healthy services.", "health", appID, s.Score)
+		exposition.add("health_degraded_services", promGauge, "Services with a health issue.", "health", appID, float64(s.DegradedServices))
+	}
+	for _, failure := range aggregated.Failures {
+		service, _, _ := strings.Cut(failure.Resource, ":")
+		exposition.add("resource_up", promGauge, "0 when a resource's metrics couldn't be fetched for this scrape.", service, failure.Resource, 0)
+	}
+
+	w.Header().Set("Content-Type", prometheusContentType)
+	if err := exposition.write(w); err != nil {
+		ma.logger.Debug("Failed to write Prometheus metrics", "appId", appID, "error", err)
+	}
+}
+
+// exposeAWSMetrics adds the per-resource AWS series to exposition
+func (ma *MetricsAggregator) exposeAWSMetrics(exposition *promExposition, summary *AWSMetricsSummary) {
+	if summary == nil {
+		return
+	}
+
+	if s := summary.Lambda; s != nil {
+		for _, f := range s.Functions {
+			exposition.add("lambda_invocations_total", promCounter, "Lambda invocations.", "lambda", f.FunctionName, f.Invocations)
+			exposition.add("lambda_errors_total", promCounter, "Lambda invocation errors.", "lambda", f.FunctionName, f.Errors)
+			exposition.add("lambda_throttles_total", promCounter, "Throttled Lambda invocations.", "lambda", f.FunctionName, f.Throttles)
+			exposition.add("lambda_error_rate_percent", promGauge, "Lambda error rate.", "lambda", f.FunctionName, f.ErrorRate)
+			exposition.add("lambda_duration_average_milliseconds", promGauge, "Average Lambda duration.", "lambda", f.FunctionName, f.AverageDuration)
+		}
+	}
+
+	if s := summary.APIGateway; s != nil {
+		apiName := ma.appHandler.AppsConfig.GetAPIGateway(exposition.app)
+		exposition.add("apigateway_requests_total", promCounter, "API Gateway requests.", "apigateway", apiName, s.TotalRequests)
+		exposition.add("apigateway_4xx_errors_total", promCounter, "API Gateway 4XX responses.", "apigateway", apiName, s.Total4XXErrors)
+		exposition.add("apigateway_5xx_errors_total", promCounter, "API Gateway 5XX responses.", "apigateway", apiName, s.Total5XXErrors)
+		exposition.add("apigateway_latency_average_milliseconds", promGauge, "Average API Gateway latency.", "apigateway", apiName, s.AverageLatency)
+		exposition.add("apigateway_latency_p50_milliseconds", promGauge, "Median API Gateway latency.", "apigateway", apiName, s.LatencyP50)
+		exposition.add("apigateway_latency_p95_milliseconds", promGauge, "95th percentile API Gateway latency.", "apigateway", apiName, s.LatencyP95)
+		exposition.add("apigateway_latency_p99_milliseconds", promGauge, "99th percentile API Gateway latency.", "apigateway", apiName, s.LatencyP99)
+		for _, e := range s.Endpoints {
+			endpoint := e.Method + " " + e.Resource
+			exposition.add("apigateway_endpoint_requests_total", promCounter, "API Gateway requests per endpoint.", "apigateway", endpoint, e.Count)
+			exposition.add("apigateway_endpoint_latency_average_milliseconds", promGauge, "Average API Gateway latency per endpoint.", "apigateway", endpoint, e.LatencyAverage)
+			exposition.add("apigateway_endpoint_latency_p99_milliseconds", promGauge, "99th percentile API Gateway latency per endpoint.", "apigateway", endpoint, e.LatencyP99)
+		}
+	}
+
+	if s := summary.DynamoDB; s != nil {
+		for _, t := range s.Tables {
+			exposition.add("dynamodb_consumed_read_capacity_units_total", promCounter, "Consumed DynamoDB read capacity units.", "dynamodb", t.TableName, t.ReadCapacity)
+			exposition.add("dynamodb_consumed_write_capacity_units_total", promCounter, "Consumed DynamoDB write capacity units.", "dynamodb", t.TableName, t.WriteCapacity)
+			exposition.add("dynamodb_throttles_total", promCounter, "Throttled DynamoDB requests.", "dynamodb", t.TableName, t.Throttles)
+			exposition.add("dynamodb_errors_total", promCounter, "DynamoDB user and system errors.", "dynamodb", t.TableName, t.Errors)
+			exposition.add("dynamodb_items", promGauge, "Approximate DynamoDB item count.", "dynamodb", t.TableName, float64(t.ItemCount))
+			exposition.add("dynamodb_size_bytes", promGauge, "Approximate DynamoDB table size.", "dynamodb", t.TableName, float64(t.SizeBytes))
+		}
+	}
+
+	if s := summary.Cost; s != nil {
+		exposition.add("cost_period_usd", promGauge, "AWS cost over the range.", "cost", "total", s.CurrentPeriod)
+		exposition.add("cost_projected_month_usd", promGauge, "AWS cost projected over 30 days.", "cost", "total", s.ProjectedMonth)
+		for _, service := range s.TopServices {
+			exposition.add("cost_service_usd", promGauge, "AWS cost over the range for the top services.", "cost", service.ServiceName, service.Cost)
+		}
+	}
+}
+
+// promExposition collects samples grouped by metric family, in the order families are first
+// added, as the exposition format requires each family's samples to be contiguous
+type promExposition struct {
+	app      string
+	families []*promFamily
+	byName   map[string]*promFamily
+}
+
+type promFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []promSample
+}
+
+type promSample struct {
+	service  string
+	resource string
+	value    float64
+}
+
+// newPromExposition creates an exposition whose samples are labelled with app
+func newPromExposition(app string) *promExposition {
+	return &promExposition{app: app, byName: make(map[string]*promFamily)}
+}
+
+// add records a sample of the named family, creating the family on first use
+func (e *promExposition) add(name, kind, help, service, resource string, value float64) {
+	family, ok := e.byName[name]
+	if !ok {
+		family = &promFamily{name: name, kind: kind, help: help}
+		e.byName[name] = family
+		e.families = append(e.families, family)
+	}
+	family.samples = append(family.samples, promSample{service: service, resource: resource, value: value})
+}
+
+// write writes the exposition in the Prometheus text format
+func (e *promExposition) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range e.families {
+		name := promNamespace + family.name
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, family.help)
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, family.kind)
+		for _, sample := range family.samples {
+			fmt.Fprintf(bw, "%s{app=\"%s\",service=\"%s\",resource=\"%s\"} %s\n",
+				name,
+				escapeLabelValue(e.app),
+				escapeLabelValue(sample.service),
+				escapeLabelValue(sample.resource),
+				strconv.FormatFloat(sample.value, 'g', -1, 64),
+			)
+		}
+	}
+	return bw.Flush()
+}
+
+// labelValueEscaper escapes the characters the exposition format doesn't allow raw in label values
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
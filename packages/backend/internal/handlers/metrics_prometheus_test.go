@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+)
+
+var (
+	promSampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)\{(.*)\} (\S+)$`)
+	promLabel      = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"(,|$)`)
+)
+
+// parsePromExposition parses the text exposition format strictly enough to catch what
+// Prometheus rejects: samples before their TYPE, a family split across the output, bad label
+// syntax and unparsable values. It returns each metric's type and each sample's value keyed by
+// its name and unescaped labels, e.g. `name{app=x,service=y,resource=z}`.
+func parsePromExposition(t *testing.T, body string) (types map[string]string, samples map[string]float64) {
+	t.Helper()
+
+	types = make(map[string]string)
+	samples = make(map[string]float64)
+	unescape := strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+	current := ""
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, "# HELP "); ok {
+			name, _, _ := strings.Cut(rest, " ")
+			if _, seen := types[name]; seen {
+				t.Fatalf("line %d: %s is split across the output", n, name)
+			}
+			current = name
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name, kind, _ := strings.Cut(rest, " ")
+			if name != current || kind != promCounter && kind != promGauge {
+				t.Fatalf("line %d: bad TYPE %q", n, line)
+			}
+			types[name] = kind
+			continue
+		}
+
+		match := promSampleLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("line %d: not a sample: %q", n, line)
+		}
+		name, labels, value := match[1], match[2], match[3]
+		if name != current || types[name] == "" {
+			t.Fatalf("line %d: sample of %s outside its family", n, name)
+		}
+		var pairs []string
+		for labels != "" {
+			label := promLabel.FindStringSubmatch(labels)
+			if label == nil {
+				t.Fatalf("line %d: bad labels %q", n, match[2])
+			}
+			pairs = append(pairs, label[1]+"="+unescape.Replace(label[2]))
+			labels = labels[len(label[0]):]
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("line %d: bad value %q", n, value)
+		}
+		samples[name+"{"+strings.Join(pairs, ",")+"}"] = parsed
+	}
+	return types, samples
+}
+
+// promSeries is the key parsePromExposition gives a sample
+func promSeries(name, app, service, resource string) string {
+	return fmt.Sprintf("%s%s{app=%s,service=%s,resource=%s}", promNamespace, name, app, service, resource)
+}
+
+func TestGetPrometheusMetrics(t *testing.T) {
+	appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{Downloads: 42, Updates: 7, Revenue: 12.5, Ratings: appstore.RatingsData{AverageRating: 4.5}}}
+	aggregator := newTestAggregator(appStore)
+
+	rec := serveCached(aggregator.GetPrometheusMetrics, cachedPath+"&sections=appstore,health")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Content-Type"); got != prometheusContentType {
+		t.Errorf("Content-Type = %q, want %q", got, prometheusContentType)
+	}
+
+	types, samples := parsePromExposition(t, rec.Body.String())
+	want := map[string]float64{
+		promSeries("appstore_downloads_total", "app", "appstore", "1234567890"): 42,
+		promSeries("appstore_updates_total", "app", "appstore", "1234567890"):   7,
+		promSeries("appstore_revenue", "app", "appstore", "1234567890"):         12.5,
+		promSeries("appstore_rating_average", "app", "appstore", "1234567890"):  4.5,
+		promSeries("health_score", "app", "health", "app"):                      100,
+	}
+	for series, value := range want {
+		if got, ok := samples[series]; !ok || got != value {
+			t.Errorf("%s = %v (present %v), want %v", series, got, ok, value)
+		}
+	}
+
+	// Counts are counters named _total; everything else is a gauge
+	for name, kind := range types {
+		if isCounter := strings.HasSuffix(name, "_total"); isCounter != (kind == promCounter) {
+			t.Errorf("%s is a %s", name, kind)
+		}
+	}
+}
+
+func TestGetPrometheusMetricsRejectsUnknownSection(t *testing.T) {
+	appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{}}
+	rec := serveCached(newTestAggregator(appStore).GetPrometheusMetrics, cachedPath+"&sections=revenue")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+	if got := appStore.calls.Load(); got != 0 {
+		t.Errorf("App Store fetched %d times for a rejected request", got)
+	}
+}
+
+func TestExposeAWSMetricsPerResource(t *testing.T) {
+	aggregator := newTestAggregator(nil)
+	exposition := newPromExposition("app")
+	aggregator.exposeAWSMetrics(exposition, &AWSMetricsSummary{
+		Lambda: &LambdaSummary{Functions: []LambdaFunctionBreakdown{
+			{FunctionName: "auth", Invocations: 1234, Errors: 12, ErrorRate: 0.97},
+			{FunctionName: "upload", Invocations: 50},
+		}},
+		DynamoDB: &DynamoDBSummary{Tables: []TableBreakdown{{TableName: "users", ReadCapacity: 300, ItemCount: 9000}}},
+		Cost: &CostSummary{CurrentPeriod: 4.2, TopServices: []ServiceCostSummary{
+			{ServiceName: "AWS Lambda", Cost: 3},
+			{ServiceName: `Amazon "Simple" Storage\Service`, Cost: 1.2},
+		}},
+	})
+	// Samples of a family added later still come out together
+	exposition.add("lambda_invocations_total", promCounter, "Lambda invocations.", "lambda", "late", 1)
+
+	var body strings.Builder
+	if err := exposition.write(&body); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	types, samples := parsePromExposition(t, body.String())
+
+	want := map[string]float64{
+		promSeries("lambda_invocations_total", "app", "lambda", "auth"):                       1234,
+		promSeries("lambda_invocations_total", "app", "lambda", "upload"):                     50,
+		promSeries("lambda_invocations_total", "app", "lambda", "late"):                       1,
+		promSeries("lambda_errors_total", "app", "lambda", "auth"):                            12,
+		promSeries("lambda_error_rate_percent", "app", "lambda", "auth"):                      0.97,
+		promSeries("dynamodb_consumed_read_capacity_units_total", "app", "dynamodb", "users"): 300,
+		promSeries("dynamodb_items", "app", "dynamodb", "users"):                              9000,
+		promSeries("cost_period_usd", "app", "cost", "total"):                                 4.2,
+		promSeries("cost_service_usd", "app", "cost", "AWS Lambda"):                           3,
+		promSeries("cost_service_usd", "app", "cost", `Amazon "Simple" Storage\Service`):      1.2,
+	}
+	for series, value := range want {
+		if got, ok := samples[series]; !ok || got != value {
+			t.Errorf("%s = %v (present %v), want %v", series, got, ok, value)
+		}
+	}
+	if types[promNamespace+"lambda_invocations_total"] != promCounter || types[promNamespace+"dynamodb_items"] != promGauge {
+		t.Errorf("types = %v, want invocations a counter and items a gauge", types)
+	}
+	// Sections left out of the summary add no series
+	for series := range samples {
+		if strings.Contains(series, "apigateway") {
+			t.Errorf("unexpected series %s", series)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Push intervals of the live metrics stream. Every event re-queries each source, so the
+// minimum keeps a handful of open dashboards from exhausting AWS quota.
+const (
+	defaultStreamInterval = 30 * time.Second
+	minStreamInterval     = 5 * time.Second
+)
+
+// StreamAggregatedMetrics pushes a fresh AggregatedMetrics payload as a Server-Sent Event every
+// interval seconds (?interval=, default 30, at least 5) until the client disconnects. It
+// accepts the same range, sections and depth parameters as GetAggregatedMetrics; relative
+// ranges slide forward with each event.
+func (ma *MetricsAggregator) StreamAggregatedMetrics(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	sections, depth, err := ma.parseAggregatedQuery(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	interval := defaultStreamInterval
+	if value := r.URL.Query().Get("interval"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			writeError(w, "interval must be a positive number of seconds", http.StatusBadRequest)
+			return
+		}
+		interval = max(time.Duration(seconds)*time.Second, minStreamInterval)
+	}
+
+	// The stream outlives the server's write timeout, so lift it for this response
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		ma.logger.Debug("Could not lift write deadline for metrics stream", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx-style proxies from buffering events
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Tell EventSource clients to reconnect after one interval
+	fmt.Fprintf(w, "retry: %d\n\n", interval.Milliseconds())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for id := 1; ; id++ {
+		startTime, endTime := parseTimeRange(r)
+		aggregated, _ := ma.collectAggregatedMetrics(r.Context(), appID, startTime, endTime, sections, depth)
+		if r.Context().Err() != nil {
+			return
+		}
+
+		payload, err := json.Marshal(aggregated)
+		if err != nil {
+			ma.logger.Error("Failed to encode streamed metrics", "appId", appID, "error", err)
+			return
+		}
+		fmt.Fprintf(w, "id: %d\nevent: metrics\ndata: %s\n\n", id, payload)
+		if err := rc.Flush(); err != nil {
+			ma.logger.Warn("Metrics stream flush failed", "appId", appID, "error", err)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
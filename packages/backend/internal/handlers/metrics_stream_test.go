@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+)
+
+// streamEvent is one parsed Server-Sent Event
+type streamEvent struct {
+	id    string
+	event string
+	data  string
+	retry string
+}
+
+// readEvent reads lines up to the blank line ending the next event
+func readEvent(t *testing.T, reader *bufio.Reader) streamEvent {
+	t.Helper()
+
+	var event streamEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event
+		}
+		field, value, _ := strings.Cut(line, ": ")
+		switch field {
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			event.data = value
+		case "retry":
+			event.retry = value
+		}
+	}
+}
+
+func TestStreamAggregatedMetrics(t *testing.T) {
+	appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{Downloads: 42}}
+	aggregator := newTestAggregator(appStore)
+
+	handlerDone := make(chan struct{})
+	router := mux.NewRouter()
+	router.HandleFunc("/api/apps/{appId}/metrics/stream", func(w http.ResponseWriter, r *http.Request) {
+		defer close(handlerDone)
+		aggregator.StreamAggregatedMetrics(w, r)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// An interval below the minimum is raised to it
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/apps/app/metrics/stream?sections=appstore&interval=1", nil)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if preamble := readEvent(t, reader); preamble.retry != "5000" {
+		t.Errorf("retry = %q, want the 5s minimum interval", preamble.retry)
+	}
+
+	started := time.Now()
+	for i, wantID := range []string{"1", "2"} {
+		event := readEvent(t, reader)
+		if event.id != wantID || event.event != "metrics" {
+			t.Fatalf("event %d = id %q, event %q, want id %s, event metrics", i, event.id, event.event, wantID)
+		}
+		var metrics AggregatedMetrics
+		if err := json.Unmarshal([]byte(event.data), &metrics); err != nil {
+			t.Fatalf("event %d: decode data %q: %v", i, event.data, err)
+		}
+		if metrics.AppStore == nil || metrics.AppStore.Downloads != 42 {
+			t.Errorf("event %d App Store = %+v, want 42 downloads", i, metrics.AppStore)
+		}
+	}
+	if elapsed := time.Since(started); elapsed < minStreamInterval-100*time.Millisecond {
+		t.Errorf("second event arrived after %s, want the %s minimum interval", elapsed, minStreamInterval)
+	}
+	if got := appStore.calls.Load(); got != 2 {
+		t.Errorf("App Store fetched %d times for two events, want 2", got)
+	}
+
+	// Disconnecting ends the handler without waiting for the next tick
+	cancel()
+	select {
+	case <-handlerDone:
+	case <-time.After(time.Second):
+		t.Fatal("handler still streaming after the client disconnected")
+	}
+	if got := appStore.calls.Load(); got != 2 {
+		t.Errorf("App Store fetched %d times after disconnect, want 2", got)
+	}
+}
+
+func TestStreamAggregatedMetricsRejectsBadParameters(t *testing.T) {
+	for _, query := range []string{"interval=0", "interval=soon", "sections=revenue", "depth=full"} {
+		t.Run(query, func(t *testing.T) {
+			appStore := &fakeAppStore{analytics: &appstore.AppAnalytics{}}
+			rec := serveCached(newTestAggregator(appStore).StreamAggregatedMetrics, "/metrics/stream?"+query)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got == "text/event-stream" {
+				t.Error("a rejected request opened a stream")
+			}
+			if got := appStore.calls.Load(); got != 0 {
+				t.Errorf("App Store fetched %d times for a rejected request", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// ResourceFailure describes a resource whose data could not be fetched. Throttled marks
+// failures where AWS kept throttling the call, so retrying later may succeed.
+type ResourceFailure struct {
+	Resource  string `json:"resource"`
+	Error     string `json:"error"`
+	Throttled bool   `json:"throttled,omitempty"`
+}
+
+// partialResult tracks per-resource outcomes for handlers that fan out across resources.
+// Partial success is reported as 200 with partial=true; when every resource fails the
+// response is 502 (429 if AWS throttled every call) so clients don't mistake an empty payload
+// for real data.
+type partialResult struct {
+	mu        sync.Mutex
+	attempted int
+	failures  []ResourceFailure
+}
+
+// newPartialResult creates an empty outcome tracker
+func newPartialResult() *partialResult {
+	return &partialResult{failures: []ResourceFailure{}}
+}
+
+// succeeded records a resource that was fetched
+func (p *partialResult) succeeded() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempted++
+}
+
+// failed records a resource that could not be fetched
+func (p *partialResult) failed(resource string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempted++
+	p.failures = append(p.failures, ResourceFailure{
+		Resource:  resource,
+		Error:     err.Error(),
+		Throttled: errors.Is(err, aws.ErrThrottled),
+	})
+}
+
+// Failures returns the recorded failures
+func (p *partialResult) Failures() []ResourceFailure {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ResourceFailure{}, p.failures...)
+}
+
+// Partial reports whether some, but not all, resources failed
+func (p *partialResult) Partial() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.failures) > 0 && len(p.failures) < p.attempted
+}
+
+// AllFailed reports whether resources were attempted and every one failed
+func (p *partialResult) AllFailed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.attempted > 0 && len(p.failures) == p.attempted
+}
+
+// allThrottled reports whether there were failures and every one was throttling
+func (p *partialResult) allThrottled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, failure := range p.failures {
+		if !failure.Throttled {
+			return false
+		}
+	}
+	return len(p.failures) > 0
+}
+
+// StatusCode returns 429 when every resource failed because AWS was throttling, 502 when
+// every resource failed for any other reason, and 200 otherwise
+func (p *partialResult) StatusCode() int {
+	if p.AllFailed() {
+		if p.allThrottled() {
+			return http.StatusTooManyRequests
+		}
+		return http.StatusBadGateway
+	}
+	return http.StatusOK
+}
+
+// partialMarker is implemented by response writers that need to know a response is a
+// partial result, such as the metrics cache's recorder
+type partialMarker interface {
+	markPartial()
+}
+
+// markResponse tells w when resources failed, so wrappers such as the metrics cache don't
+// keep a response that is missing their data
+func (p *partialResult) markResponse(w http.ResponseWriter) {
+	marker, ok := w.(partialMarker)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.failures) > 0 {
+		marker.markPartial()
+	}
+}
+
+// writeJSON writes the response with the partial flag, failures and matching status code
+func (p *partialResult) writeJSON(w http.ResponseWriter, response map[string]interface{}) {
+	response["partial"] = p.Partial()
+	response["failures"] = p.Failures()
+	p.markResponse(w)
+
+	writeJSON(w, p.StatusCode(), response)
+}
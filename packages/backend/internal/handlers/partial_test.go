@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// partialBody is the part of a response partialResult writes
+type partialBody struct {
+	Partial  bool              `json:"partial"`
+	Failures []ResourceFailure `json:"failures"`
+}
+
+func TestPartialResultOutcomes(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	throttled := fmt.Errorf("lambda:fn: %w", aws.ErrThrottled)
+
+	tests := []struct {
+		name         string
+		succeeded    int
+		failures     []error
+		wantStatus   int
+		wantPartial  bool
+		wantFailures int
+	}{
+		{name: "full", succeeded: 3, wantStatus: http.StatusOK},
+		{name: "partial", succeeded: 2, failures: []error{unavailable}, wantStatus: http.StatusOK, wantPartial: true, wantFailures: 1},
+		{name: "all failed", failures: []error{unavailable, throttled}, wantStatus: http.StatusBadGateway, wantFailures: 2},
+		{name: "all throttled", failures: []error{throttled, throttled}, wantStatus: http.StatusTooManyRequests, wantFailures: 2},
+		{name: "partial while throttled", succeeded: 1, failures: []error{throttled}, wantStatus: http.StatusOK, wantPartial: true, wantFailures: 1},
+		{name: "no resources", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := newPartialResult()
+			for i := 0; i < tt.succeeded; i++ {
+				outcome.succeeded()
+			}
+			for i, err := range tt.failures {
+				outcome.failed(fmt.Sprintf("resource-%d", i), err)
+			}
+
+			rec := httptest.NewRecorder()
+			outcome.writeJSON(rec, map[string]interface{}{"data": "value"})
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body partialBody
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body.Partial != tt.wantPartial || len(body.Failures) != tt.wantFailures {
+				t.Errorf("partial = %v with %d failures, want %v with %d", body.Partial, len(body.Failures), tt.wantPartial, tt.wantFailures)
+			}
+			if body.Failures == nil {
+				t.Error("failures is null, want a list")
+			}
+			for i, failure := range body.Failures {
+				wantThrottled := errors.Is(tt.failures[i], aws.ErrThrottled)
+				if failure.Resource != fmt.Sprintf("resource-%d", i) || failure.Throttled != wantThrottled {
+					t.Errorf("failure %d = %+v, want throttled %v", i, failure, wantThrottled)
+				}
+			}
+		})
+	}
+}
+
+func TestAggregatedMetricsOutcomes(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		wantStatus   int
+		wantFailures int
+	}{
+		{name: "fetched", wantStatus: http.StatusOK},
+		{name: "failed", err: errors.New("App Store Connect unavailable"), wantStatus: http.StatusBadGateway, wantFailures: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator := newTestAggregator(&fakeAppStore{analytics: &appstore.AppAnalytics{Downloads: 7}, err: tt.err})
+
+			rec := serveCached(aggregator.GetAggregatedMetrics, cachedPath+"&sections=appstore")
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body partialBody
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body.Partial || len(body.Failures) != tt.wantFailures {
+				t.Errorf("partial = %v with failures %+v, want %d failures", body.Partial, body.Failures, tt.wantFailures)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPollingThreshold is how many identical queries per window count as a storm
+	DefaultPollingThreshold = 30
+
+	// DefaultPollingWindow is the window polling rates are measured over
+	DefaultPollingWindow = time.Minute
+)
+
+// PollingStats describes how often a single query fingerprint has been requested
+type PollingStats struct {
+	Fingerprint   string    `json:"fingerprint"`
+	WindowCount   int       `json:"windowCount"`
+	TotalRequests int64     `json:"totalRequests"`
+	StormWindows  int64     `json:"stormWindows"`
+	LastClientIP  string    `json:"lastClientIp"`
+	LastSeen      time.Time `json:"lastSeen"`
+}
+
+// pollingEntry tracks one fingerprint's current fixed window
+type pollingEntry struct {
+	stats       PollingStats
+	windowStart time.Time
+	warned      bool
+}
+
+// PollingDetector counts identical queries per fixed window and warns when a single
+// fingerprint exceeds the threshold, surfacing clients that poll too aggressively.
+// A nil detector records nothing.
+type PollingDetector struct {
+	threshold int
+	window    time.Duration
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*pollingEntry
+	storms  int64
+}
+
+// NewPollingDetector creates a detector warning past threshold requests per window
+func NewPollingDetector(threshold int, window time.Duration, logger *slog.Logger) *PollingDetector {
+	if threshold <= 0 {
+		threshold = DefaultPollingThreshold
+	}
+	if window <= 0 {
+		window = DefaultPollingWindow
+	}
+	return &PollingDetector{
+		threshold: threshold,
+		window:    window,
+		logger:    logger,
+		now:       time.Now,
+		entries:   make(map[string]*pollingEntry),
+	}
+}
+
+// queryFingerprint identifies a query by method, path and sorted query parameters
+func queryFingerprint(r *http.Request) string {
+	fingerprint := r.Method + " " + r.URL.Path
+	if query := r.URL.Query().Encode(); query != "" {
+		fingerprint += "?" + query
+	}
+	return fingerprint
+}
+
+// Observe records a request and reports whether its fingerprint is over the threshold.
+// The warning is logged once per fingerprint per window.
+func (d *PollingDetector) Observe(r *http.Request, clientIP string) bool {
+	if d == nil {
+		return false
+	}
+
+	fingerprint := queryFingerprint(r)
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.entries[fingerprint]
+	if !ok {
+		entry = &pollingEntry{stats: PollingStats{Fingerprint: fingerprint}, windowStart: now}
+		d.entries[fingerprint] = entry
+	}
+	if now.Sub(entry.windowStart) >= d.window {
+		entry.windowStart = now
+		entry.stats.WindowCount = 0
+		entry.warned = false
+	}
+
+	entry.stats.WindowCount++
+	entry.stats.TotalRequests++
+	entry.stats.LastClientIP = clientIP
+	entry.stats.LastSeen = now
+
+	storm := entry.stats.WindowCount > d.threshold
+	if storm && !entry.warned {
+		entry.warned = true
+		entry.stats.StormWindows++
+		d.storms++
+		if d.logger != nil {
+			d.logger.Warn("Polling storm detected",
+				"fingerprint", fingerprint,
+				"requests", entry.stats.WindowCount,
+				"window", d.window,
+				"threshold", d.threshold,
+				"client_ip", clientIP)
+		}
+	}
+
+	d.prune(now)
+	return storm
+}
+
+// prune drops fingerprints idle for several windows so the map stays bounded.
+// Callers must hold mu.
+func (d *PollingDetector) prune(now time.Time) {
+	for fingerprint, entry := range d.entries {
+		if now.Sub(entry.stats.LastSeen) > 10*d.window {
+			delete(d.entries, fingerprint)
+		}
+	}
+}
+
+// Snapshot returns the total storm count and per-fingerprint stats, busiest first
+func (d *PollingDetector) Snapshot() (int64, []PollingStats) {
+	if d == nil {
+		return 0, []PollingStats{}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	stats := make([]PollingStats, 0, len(d.entries))
+	for _, entry := range d.entries {
+		stats = append(stats, entry.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].WindowCount != stats[j].WindowCount {
+			return stats[i].WindowCount > stats[j].WindowCount
+		}
+		return stats[i].Fingerprint < stats[j].Fingerprint
+	})
+	return d.storms, stats
+}
+
+// GetPollingDiagnostics reports request frequency per query fingerprint
+func (h *AppHandler) GetPollingDiagnostics(w http.ResponseWriter, r *http.Request) {
+	storms, fingerprints := h.Polling.Snapshot()
+
+	response := map[string]interface{}{
+		"enabled":      h.Polling != nil,
+		"storms":       storms,
+		"fingerprints": fingerprints,
+		"timestamp":    time.Now().Unix(),
+	}
+	if h.Polling != nil {
+		response["threshold"] = h.Polling.threshold
+		response["window"] = h.Polling.window.String()
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPollingDetectorWarnsPastThreshold(t *testing.T) {
+	var logs bytes.Buffer
+	detector := NewPollingDetector(3, time.Minute, slog.New(slog.NewTextHandler(&logs, nil)))
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	detector.now = func() time.Time { return now }
+
+	observe := func(target string) bool {
+		return detector.Observe(httptest.NewRequest(http.MethodGet, target, nil), "203.0.113.7")
+	}
+	warnings := func() int {
+		return strings.Count(logs.String(), "Polling storm detected")
+	}
+
+	// Parameter order doesn't change the fingerprint
+	for i, target := range []string{"/api/apps/app/aws/lambda?range=24h&metric=errors", "/api/apps/app/aws/lambda?metric=errors&range=24h", "/api/apps/app/aws/lambda?range=24h&metric=errors"} {
+		if observe(target) {
+			t.Errorf("request %d flagged at or under the threshold", i+1)
+		}
+	}
+	// Other queries are counted separately
+	if observe("/api/apps/app/aws/lambda?range=7d&metric=errors") {
+		t.Error("a different query was flagged")
+	}
+	if warnings() != 0 {
+		t.Fatalf("warned before the threshold: %s", logs.String())
+	}
+
+	if !observe("/api/apps/app/aws/lambda?range=24h&metric=errors") {
+		t.Error("request past the threshold wasn't flagged")
+	}
+	if !observe("/api/apps/app/aws/lambda?range=24h&metric=errors") {
+		t.Error("later request in the storm wasn't flagged")
+	}
+	if warnings() != 1 {
+		t.Errorf("logged %d warnings in one window, want 1", warnings())
+	}
+
+	// A new window starts counting again and warns again once past the threshold
+	now = now.Add(time.Minute)
+	for i := 0; i < 4; i++ {
+		observe("/api/apps/app/aws/lambda?range=24h&metric=errors")
+	}
+	if warnings() != 2 {
+		t.Errorf("logged %d warnings over two stormy windows, want 2", warnings())
+	}
+
+	storms, stats := detector.Snapshot()
+	if storms != 2 || len(stats) != 2 {
+		t.Fatalf("snapshot = %d storms over %v, want 2 storms over 2 fingerprints", storms, stats)
+	}
+	busiest := stats[0]
+	if busiest.Fingerprint != "GET /api/apps/app/aws/lambda?metric=errors&range=24h" || busiest.WindowCount != 4 || busiest.TotalRequests != 9 || busiest.StormWindows != 2 || busiest.LastClientIP != "203.0.113.7" {
+		t.Errorf("busiest fingerprint = %+v", busiest)
+	}
+
+	// Fingerprints idle for ten windows are dropped
+	now = now.Add(11 * time.Minute)
+	observe("/api/apps")
+	if _, stats := detector.Snapshot(); len(stats) != 1 {
+		t.Errorf("snapshot kept %d fingerprints, want only the latest", len(stats))
+	}
+}
+
+func TestGetPollingDiagnostics(t *testing.T) {
+	detector := NewPollingDetector(1, time.Minute, nil)
+	for i := 0; i < 2; i++ {
+		detector.Observe(httptest.NewRequest(http.MethodGet, "/api/apps?range=1h", nil), "203.0.113.7")
+	}
+
+	tests := []struct {
+		name        string
+		detector    *PollingDetector
+		wantEnabled bool
+		wantStorms  int64
+	}{
+		{name: "enabled", detector: detector, wantEnabled: true, wantStorms: 1},
+		{name: "disabled", detector: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &AppHandler{Polling: tt.detector}
+			rec := httptest.NewRecorder()
+			h.GetPollingDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics/polling", nil))
+
+			var body struct {
+				Enabled      bool           `json:"enabled"`
+				Storms       int64          `json:"storms"`
+				Fingerprints []PollingStats `json:"fingerprints"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body.Enabled != tt.wantEnabled || body.Storms != tt.wantStorms || body.Fingerprints == nil {
+				t.Errorf("diagnostics = %+v, want enabled %v with %d storms", body, tt.wantEnabled, tt.wantStorms)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+)
+
+// defaultRatingsHistoryDays is the history window when no start is requested
+const defaultRatingsHistoryDays = 90
+
+// RatingsTrend summarizes how ratings changed across a history window
+type RatingsTrend struct {
+	AverageRatingChange float64 `json:"averageRatingChange"`
+	TotalRatingsChange  int64   `json:"totalRatingsChange"`
+	Direction           string  `json:"direction"`
+}
+
+// RatingsHistoryStore keeps App Store ratings snapshots, as aws.RatingsHistoryStore does in
+// DynamoDB
+type RatingsHistoryStore interface {
+	PutSnapshot(ctx context.Context, snapshot aws.RatingSnapshot) error
+
+	// GetSnapshots returns an app's snapshots within a time range, oldest first
+	GetSnapshots(ctx context.Context, appID string, startTime, endTime time.Time) ([]aws.RatingSnapshot, error)
+
+	// PruneSnapshots deletes an app's snapshots taken before the cutoff, returning how many
+	PruneSnapshots(ctx context.Context, appID string, before time.Time) (int, error)
+}
+
+// SnapshotRatings records the current App Store ratings of every configured app
+func (h *AppHandler) SnapshotRatings(ctx context.Context) error {
+	if h.AppStore == nil || h.RatingsHistory == nil {
+		return nil
+	}
+
+	var errs []error
+	now := time.Now().UTC()
+	for _, app := range h.AppsConfig.GetAllApps() {
+		if app.AppStoreID == "" {
+			continue
+		}
+
+		ratings, err := h.AppStore.GetAppRatings(ctx, app.AppStoreID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", app.ID, err))
+			continue
+		}
+
+		err = h.RatingsHistory.PutSnapshot(ctx, aws.RatingSnapshot{
+			AppID:         app.ID,
+			Timestamp:     now,
+			AverageRating: ratings.AverageRating,
+			TotalRatings:  ratings.TotalRatings,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", app.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetAppStoreRatingsHistory returns stored ratings snapshots and the trend across them
+func (h *AppHandler) GetAppStoreRatingsHistory(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	if h.RatingsHistory == nil {
+		writeError(w, "Ratings history not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Ratings move slowly, so default to a longer window than other metrics
+	startTime, endTime := parseTimeRange(r)
+	if r.URL.Query().Get("start") == "" {
+		startTime = endTime.AddDate(0, 0, -defaultRatingsHistoryDays)
+	}
+
+	snapshots, err := h.RatingsHistory.GetSnapshots(r.Context(), appID, startTime, endTime)
+	if err != nil {
+		h.Logger.Error("Failed to get ratings history", "app_id", appID, "error", err)
+		writeError(w, "Failed to get ratings history", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"appId":     appID,
+		"snapshots": snapshots,
+		"trend":     ratingsTrend(snapshots),
+		"startTime": startTime.Unix(),
+		"endTime":   endTime.Unix(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// ratingsTrend compares the first and last snapshots in a window
+func ratingsTrend(snapshots []aws.RatingSnapshot) RatingsTrend {
+	trend := RatingsTrend{Direction: "flat"}
+	if len(snapshots) < 2 {
+		return trend
+	}
+
+	first, last := snapshots[0], snapshots[len(snapshots)-1]
+	trend.AverageRatingChange = last.AverageRating - first.AverageRating
+	trend.TotalRatingsChange = last.TotalRatings - first.TotalRatings
+
+	switch {
+	case trend.AverageRatingChange > 0:
+		trend.Direction = "improving"
+	case trend.AverageRatingChange < 0:
+		trend.Direction = "declining"
+	}
+	return trend
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// memoryRatingsHistory is a RatingsHistoryStore held in a slice
+type memoryRatingsHistory struct {
+	mu        sync.Mutex
+	snapshots []aws.RatingSnapshot
+}
+
+func (s *memoryRatingsHistory) PutSnapshot(ctx context.Context, snapshot aws.RatingSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots = append(s.snapshots, snapshot)
+	return nil
+}
+
+func (s *memoryRatingsHistory) GetSnapshots(ctx context.Context, appID string, startTime, endTime time.Time) ([]aws.RatingSnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshots := []aws.RatingSnapshot{}
+	for _, snapshot := range s.snapshots {
+		if snapshot.AppID == appID && !snapshot.Timestamp.Before(startTime) && !snapshot.Timestamp.After(endTime) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+func (s *memoryRatingsHistory) PruneSnapshots(ctx context.Context, appID string, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.snapshots[:0]
+	for _, snapshot := range s.snapshots {
+		if snapshot.AppID != appID || !snapshot.Timestamp.Before(before) {
+			kept = append(kept, snapshot)
+		}
+	}
+	pruned := len(s.snapshots) - len(kept)
+	s.snapshots = kept
+	return pruned, nil
+}
+
+// ratingsAppStore reports fixed ratings per App Store ID, failing for unknown apps
+type ratingsAppStore struct {
+	fakeAppStore
+	ratings map[string]appstore.RatingsData
+}
+
+func (f *ratingsAppStore) GetAppRatings(ctx context.Context, appID string) (*appstore.RatingsData, error) {
+	ratings, ok := f.ratings[appID]
+	if !ok {
+		return nil, errors.New("app not found")
+	}
+	return &ratings, nil
+}
+
+func TestSnapshotRatingsWritesEveryApp(t *testing.T) {
+	history := &memoryRatingsHistory{}
+	h := &AppHandler{
+		AppStore: &ratingsAppStore{ratings: map[string]appstore.RatingsData{
+			"111": {AverageRating: 4.5, TotalRatings: 900},
+			"222": {AverageRating: 3.9, TotalRatings: 40},
+		}},
+		RatingsHistory: history,
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"first":   {ID: "first", AppStoreID: "111"},
+			"second":  {ID: "second", AppStoreID: "222"},
+			"web":     {ID: "web"},
+			"removed": {ID: "removed", AppStoreID: "333"},
+		}},
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	// One app's failure is reported without losing the others' snapshots
+	if err := h.SnapshotRatings(context.Background()); err == nil {
+		t.Error("SnapshotRatings hid the failure for an unknown app")
+	}
+
+	got := map[string]aws.RatingSnapshot{}
+	for _, snapshot := range history.snapshots {
+		got[snapshot.AppID] = snapshot
+	}
+	if len(got) != 2 || len(history.snapshots) != 2 {
+		t.Fatalf("snapshots = %+v, want one each for first and second", history.snapshots)
+	}
+	if first := got["first"]; first.AverageRating != 4.5 || first.TotalRatings != 900 || first.Timestamp.IsZero() {
+		t.Errorf("first = %+v", first)
+	}
+	if second := got["second"]; second.AverageRating != 3.9 || second.TotalRatings != 40 {
+		t.Errorf("second = %+v", second)
+	}
+}
+
+func TestGetAppStoreRatingsHistory(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	history := &memoryRatingsHistory{snapshots: []aws.RatingSnapshot{
+		{AppID: "app", Timestamp: now.AddDate(0, 0, -120), AverageRating: 3.2, TotalRatings: 100},
+		{AppID: "app", Timestamp: now.AddDate(0, 0, -60), AverageRating: 4.0, TotalRatings: 400},
+		{AppID: "app", Timestamp: now.AddDate(0, 0, -30), AverageRating: 3.8, TotalRatings: 500},
+		{AppID: "app", Timestamp: now.AddDate(0, 0, -1), AverageRating: 4.4, TotalRatings: 650},
+		{AppID: "other", Timestamp: now.AddDate(0, 0, -2), AverageRating: 1.0, TotalRatings: 3},
+	}}
+	h := &AppHandler{RatingsHistory: history, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantTimes []time.Time
+		wantTrend RatingsTrend
+	}{
+		{
+			name:      "default 90 days",
+			query:     "?end=" + now.Format(time.RFC3339),
+			wantTimes: []time.Time{now.AddDate(0, 0, -60), now.AddDate(0, 0, -30), now.AddDate(0, 0, -1)},
+			wantTrend: RatingsTrend{AverageRatingChange: 0.4, TotalRatingsChange: 250, Direction: "improving"},
+		},
+		{
+			name:      "explicit range",
+			query:     "?start=" + now.AddDate(0, 0, -61).Format(time.RFC3339) + "&end=" + now.AddDate(0, 0, -29).Format(time.RFC3339),
+			wantTimes: []time.Time{now.AddDate(0, 0, -60), now.AddDate(0, 0, -30)},
+			wantTrend: RatingsTrend{AverageRatingChange: -0.2, TotalRatingsChange: 100, Direction: "declining"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/"+tt.query, nil), map[string]string{"appId": "app"})
+			rec := httptest.NewRecorder()
+			h.GetAppStoreRatingsHistory(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+
+			var body struct {
+				Snapshots []aws.RatingSnapshot `json:"snapshots"`
+				Trend     RatingsTrend         `json:"trend"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if len(body.Snapshots) != len(tt.wantTimes) {
+				t.Fatalf("snapshots = %+v, want %d", body.Snapshots, len(tt.wantTimes))
+			}
+			for i, want := range tt.wantTimes {
+				if !body.Snapshots[i].Timestamp.Equal(want) {
+					t.Errorf("snapshot %d at %s, want %s", i, body.Snapshots[i].Timestamp, want)
+				}
+			}
+			trend := body.Trend
+			if trend.Direction != tt.wantTrend.Direction || trend.TotalRatingsChange != tt.wantTrend.TotalRatingsChange || math.Abs(trend.AverageRatingChange-tt.wantTrend.AverageRatingChange) > 1e-9 {
+				t.Errorf("trend = %+v, want %+v", trend, tt.wantTrend)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	(&AppHandler{}).GetAppStoreRatingsHistory(rec, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/", nil), map[string]string{"appId": "app"}))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status without a history store = %d, want 503", rec.Code)
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+const (
+	sparklineWidth  = 240
+	sparklineHeight = 48
+	forecastDays    = 30
+)
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"money":   func(v float64) string { return fmt.Sprintf("$%.2f", v) },
+	"number":  func(v float64) string { return fmt.Sprintf("%.0f", v) },
+	"decimal": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+}).Parse(reportHTML))
+
+// ReportHandler renders shareable point-in-time metrics reports
+type ReportHandler struct {
+	appHandler *AppHandler
+	aggregator *MetricsAggregator
+	logger     *slog.Logger
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler(appHandler *AppHandler, aggregator *MetricsAggregator, logger *slog.Logger) *ReportHandler {
+	return &ReportHandler{
+		appHandler: appHandler,
+		aggregator: aggregator,
+		logger:     logger,
+	}
+}
+
+// MetricsReport represents the data rendered into an HTML report
+type MetricsReport struct {
+	AppID               string
+	GeneratedAt         string
+	Period              timerange.Period
+	Metrics             *AggregatedMetrics
+	ForecastCost        float64
+	HasForecast         bool
+	InvocationSparkline string
+	CostSparkline       string
+}
+
+// GetMetricsReport renders the aggregated metrics as a downloadable HTML report
+func (h *ReportHandler) GetMetricsReport(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	startTime, endTime := parseTimeRange(r)
+	ctx := r.Context()
+
+	report, outcome, err := h.buildReport(ctx, appID, startTime, endTime)
+	if err != nil {
+		h.logger.Error("Failed to build metrics report", "app_id", appID, "error", err)
+		writeError(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+
+	// A report without any metrics isn't worth sharing; a partial one lists what is missing
+	if outcome.AllFailed() {
+		h.logger.Error("Failed to fetch metrics for report", "app_id", appID, "failures", outcome.Failures())
+		writeError(w, "Failed to fetch metrics for report", outcome.StatusCode())
+		return
+	}
+	if outcome.Partial() {
+		h.logger.Warn("Metrics report is missing data", "app_id", appID, "failures", outcome.Failures())
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, report); err != nil {
+		h.logger.Error("Failed to render metrics report", "app_id", appID, "error", err)
+		writeError(w, "Failed to render report", http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-report-%s.html", reportFilenameSafe(appID), time.Now().UTC().Format("20060102"))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write(buf.Bytes())
+}
+
+// buildReport gathers the aggregated metrics, cost projection and sparkline series, returning
+// the aggregated metrics' per-resource outcome alongside the report
+func (h *ReportHandler) buildReport(ctx context.Context, appID string, startTime, endTime time.Time) (*MetricsReport, *partialResult, error) {
+	report := &MetricsReport{
+		AppID:       appID,
+		GeneratedAt: time.Now().UTC().Format(time.RFC1123),
+		Period:      timerange.NewPeriod(startTime, endTime),
+	}
+	var outcome *partialResult
+	report.Metrics, outcome = h.aggregator.collectAggregatedMetrics(ctx, appID, startTime, endTime, allSections(), DepthSummary)
+
+	if h.appHandler.Features.Lambda {
+		report.InvocationSparkline = sparklinePoints(h.invocationSeries(ctx, appID, startTime, endTime), sparklineWidth, sparklineHeight)
+	}
+
+	if h.appHandler.Features.Cost && h.appHandler.CostExplorer != nil {
+		costData, err := h.appHandler.GetAppCosts(ctx, appID, startTime, endTime)
+		if err != nil {
+			h.logger.Warn("Failed to get daily costs for report", "error", err)
+		} else {
+			var dailyCosts []float64
+			for _, daily := range costData.DailyCosts {
+				dailyCosts = append(dailyCosts, daily.Cost)
+			}
+			report.CostSparkline = sparklinePoints(dailyCosts, sparklineWidth, sparklineHeight)
+		}
+
+		forecast, err := h.appHandler.CostExplorer.GetForecast(ctx, forecastDays, aws.AppCostFilter(h.appHandler.costScope(appID)))
+		if err != nil {
+			h.logger.Warn("Failed to get cost forecast for report", "error", err)
+		} else {
+			report.ForecastCost = forecast.TotalCost
+			report.HasForecast = true
+		}
+	}
+
+	return report, outcome, nil
+}
+
+// invocationSeries sums Lambda invocations across the app's functions in 5 minute buckets
+func (h *ReportHandler) invocationSeries(ctx context.Context, appID string, startTime, endTime time.Time) []float64 {
+	buckets := make(map[time.Time]float64)
+
+	for _, functionName := range h.appHandler.ResolveLambdaFunctions(ctx, appID) {
+		metrics, err := h.appHandler.CloudWatch.GetLambdaMetrics(ctx, functionName, startTime, endTime)
+		if err != nil {
+			continue
+		}
+		for _, dp := range metrics.Datapoints {
+			buckets[dp.Timestamp.Round(5*time.Minute)] += dp.Value
+		}
+	}
+
+	timestamps := make([]time.Time, 0, len(buckets))
+	for timestamp := range buckets {
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool {
+		return timestamps[i].Before(timestamps[j])
+	})
+
+	values := make([]float64, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		values = append(values, buckets[timestamp])
+	}
+	return values
+}
+
+// sparklinePoints scales values into an SVG polyline points attribute
+func sparklinePoints(values []float64, width, height float64) string {
+	if len(values) < 2 {
+		return ""
+	}
+
+	minValue, maxValue := values[0], values[0]
+	for _, v := range values {
+		if v < minValue {
+			minValue = v
+		}
+		if v > maxValue {
+			maxValue = v
+		}
+	}
+
+	span := maxValue - minValue
+	if span == 0 {
+		span = 1
+	}
+	step := width / float64(len(values)-1)
+
+	points := make([]string, 0, len(values))
+	for i, v := range values {
+		x := float64(i) * step
+		y := height - ((v-minValue)/span)*height
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x, y))
+	}
+	return strings.Join(points, " ")
+}
+
+// reportFilenameSafe strips characters that are not safe in a download filename
+func reportFilenameSafe(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return -1
+	}, name)
+	if safe == "" {
+		return "app"
+	}
+	return safe
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// fakeAppStore returns analytics, or err, for every app, counting the calls; its other
+// calls fail
+type fakeAppStore struct {
+	analytics *appstore.AppAnalytics
+	err       error
+	calls     atomic.Int32
+}
+
+var errUnexpectedCall = errors.New("unexpected App Store call")
+
+func (f *fakeAppStore) GetAppAnalytics(ctx context.Context, appID string, startDate, endDate time.Time) (*appstore.AppAnalytics, error) {
+	f.calls.Add(1)
+	return f.analytics, f.err
+}
+
+func (f *fakeAppStore) GetAppRatings(ctx context.Context, appID string) (*appstore.RatingsData, error) {
+	return nil, errUnexpectedCall
+}
+
+func (f *fakeAppStore) GetCrashMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*appstore.CrashMetrics, error) {
+	return nil, errUnexpectedCall
+}
+
+func (f *fakeAppStore) GetSubscriptionMetrics(ctx context.Context, appID string, startDate, endDate time.Time) (*appstore.SubscriptionMetrics, error) {
+	return nil, errUnexpectedCall
+}
+
+func (f *fakeAppStore) GetCustomerReviews(ctx context.Context, appID string, filter appstore.ReviewFilter) (*appstore.ReviewPage, error) {
+	return nil, errUnexpectedCall
+}
+
+func (f *fakeAppStore) EnsureReportRequest(ctx context.Context, appID, accessType string) (*appstore.ReportJob, error) {
+	return nil, errUnexpectedCall
+}
+
+func (f *fakeAppStore) GetReportJob(ctx context.Context, appID, requestID string, filter appstore.ReportFilter) (*appstore.ReportJob, error) {
+	return nil, errUnexpectedCall
+}
+
+func (f *fakeAppStore) RateLimitStatus() appstore.RateLimitStatus {
+	return appstore.RateLimitStatus{}
+}
+
+// newTestReportHandler returns a report handler for an app whose only data source is the
+// App Store
+func newTestReportHandler(appStore appstore.Client) *ReportHandler {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appHandler := &AppHandler{
+		AppStore: appStore,
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"report-app": {ID: "report-app", AppStoreID: "1234567890"},
+		}},
+		Features: appconfig.FeatureFlags{AppStore: true},
+		Logger:   logger,
+	}
+	return NewReportHandler(appHandler, NewMetricsAggregator(appHandler, DepthSummary, 0, logger), logger)
+}
+
+func serveReport(handler *ReportHandler) *httptest.ResponseRecorder {
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/apps/report-app/reports/metrics", nil), map[string]string{"appId": "report-app"})
+	rec := httptest.NewRecorder()
+	handler.GetMetricsReport(rec, req)
+	return rec
+}
+
+// checkWellFormed fails t unless every element in page is closed in order, HTML void
+// elements aside
+func checkWellFormed(t *testing.T, page string) {
+	t.Helper()
+
+	decoder := xml.NewDecoder(strings.NewReader(page))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	void := map[string]bool{"meta": true, "br": true, "hr": true, "img": true, "link": true, "input": true}
+	var open []string
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("parse report: %v", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			if !void[token.Name.Local] {
+				open = append(open, token.Name.Local)
+			}
+		case xml.EndElement:
+			if len(open) == 0 || open[len(open)-1] != token.Name.Local {
+				t.Fatalf("</%s> closes %v", token.Name.Local, open)
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) != 0 {
+		t.Fatalf("unclosed elements %v", open)
+	}
+}
+
+func TestGetMetricsReport(t *testing.T) {
+	handler := newTestReportHandler(&fakeAppStore{analytics: &appstore.AppAnalytics{
+		Downloads:     4321,
+		Updates:       210,
+		Revenue:       567.89,
+		ActiveDevices: 1000,
+		Ratings:       appstore.RatingsData{AverageRating: 4.6, TotalRatings: 321},
+	}})
+
+	rec := serveReport(handler)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	wantDisposition := `attachment; filename="report-app-report-` + time.Now().UTC().Format("20060102") + `.html"`
+	if got := rec.Header().Get("Content-Disposition"); got != wantDisposition {
+		t.Errorf("Content-Disposition = %q, want %q", got, wantDisposition)
+	}
+
+	page := rec.Body.String()
+	checkWellFormed(t, page)
+	for _, want := range []string{
+		"<title>report-app analytics report</title>",
+		"<tr><th>Downloads</th><td>4321</td></tr>",
+		"<tr><th>Updates</th><td>210</td></tr>",
+		"<tr><th>Revenue</th><td>$567.89</td></tr>",
+		"<tr><th>ARPU</th><td>$0.57</td></tr>",
+		"<tr><th>Average rating</th><td>4.60 (321 ratings)</td></tr>",
+		`<strong class="status-healthy">healthy</strong>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+	if strings.Contains(page, "could not be fetched") {
+		t.Error("complete report lists missing data")
+	}
+}
+
+func TestGetMetricsReportWithoutAnyMetrics(t *testing.T) {
+	handler := newTestReportHandler(&fakeAppStore{err: errors.New("App Store Connect unavailable")})
+
+	rec := serveReport(handler)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("failed report was offered as a download: %q", got)
+	}
+}
+
+func TestReportTemplateListsFailures(t *testing.T) {
+	report := &MetricsReport{
+		AppID:  "report-app",
+		Period: timerange.NewPeriod(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)),
+		Metrics: &AggregatedMetrics{
+			AWS:      &AWSMetricsSummary{Lambda: &LambdaSummary{FunctionCount: 1, TotalInvocations: 1200}},
+			Partial:  true,
+			Failures: []ResourceFailure{{Resource: "lambda:checkout", Error: "throttled <retry later>", Throttled: true}},
+		},
+		InvocationSparkline: sparklinePoints([]float64{0, 600, 1200}, sparklineWidth, sparklineHeight),
+	}
+
+	var buf bytes.Buffer
+	if err := reportTemplate.Execute(&buf, report); err != nil {
+		t.Fatalf("render report: %v", err)
+	}
+	page := buf.String()
+	checkWellFormed(t, page)
+	for _, want := range []string{
+		"Some data could not be fetched",
+		"<li>lambda:checkout: throttled &lt;retry later&gt;</li>",
+		"<tr><th>Invocations</th><td>1200</td></tr>",
+		`<polyline points="0.0,48.0 120.0,24.0 240.0,0.0"/>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report is missing %q", want)
+		}
+	}
+}
+
+func TestSparklinePoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   string
+	}{
+		{name: "too few values", values: []float64{5}, want: ""},
+		{name: "flat", values: []float64{3, 3}, want: "0.0,48.0 240.0,48.0"},
+		{name: "rising and falling", values: []float64{10, 30, 20}, want: "0.0,48.0 120.0,0.0 240.0,24.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sparklinePoints(tt.values, sparklineWidth, sparklineHeight); got != tt.want {
+				t.Errorf("sparklinePoints = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
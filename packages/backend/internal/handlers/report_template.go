@@ -0,0 +1,104 @@
+package handlers
+
+// reportHTML is the self-contained template for metrics reports (inline styles, inline SVG)
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.AppID}} analytics report</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Helvetica, Arial, sans-serif; margin: 32px; color: #1f2937; }
+h1 { margin-bottom: 4px; }
+h2 { margin-top: 32px; border-bottom: 1px solid #e5e7eb; padding-bottom: 4px; }
+.meta { color: #6b7280; font-size: 14px; }
+table { border-collapse: collapse; margin-top: 8px; }
+th, td { text-align: left; padding: 4px 16px 4px 0; }
+th { color: #6b7280; font-weight: normal; }
+.status-healthy { color: #059669; }
+.status-degraded { color: #d97706; }
+.status-critical { color: #dc2626; }
+svg polyline { fill: none; stroke: #2563eb; stroke-width: 2; }
+</style>
+</head>
+<body>
+<h1>{{.AppID}} analytics report</h1>
+<p class="meta">Period: {{.Period.Display}} &middot; Generated {{.GeneratedAt}}</p>
+{{if .Metrics.Failures}}
+<p class="status-degraded">Some data could not be fetched:</p>
+<ul>{{range .Metrics.Failures}}<li>{{.Resource}}: {{.Error}}</li>{{end}}</ul>
+{{end}}
+{{with .Metrics.Health}}
+<h2>Health</h2>
+<p>Status: <strong class="status-{{.Status}}">{{.Status}}</strong></p>
+<table>
+<tr><th>Healthy services</th><td>{{.HealthyServices}}</td></tr>
+<tr><th>Degraded services</th><td>{{.DegradedServices}}</td></tr>
+<tr><th>Unknown services</th><td>{{.UnknownServices}}</td></tr>
+</table>
+{{if .Issues}}<ul>{{range .Issues}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{end}}
+{{with .Metrics.AWS.Lambda}}
+<h2>Lambda</h2>
+<table>
+<tr><th>Functions</th><td>{{.FunctionCount}}</td></tr>
+<tr><th>Invocations</th><td>{{number .TotalInvocations}}</td></tr>
+<tr><th>Errors</th><td>{{number .TotalErrors}}</td></tr>
+<tr><th>Error rate (raw)</th><td>{{decimal .ErrorRate}}%</td></tr>
+<tr><th>Error rate (retry-adjusted)</th><td>{{decimal .AdjustedErrorRate}}%</td></tr>
+<tr><th>Average duration</th><td>{{decimal .AverageDuration}} ms</td></tr>
+<tr><th>Throttles</th><td>{{number .TotalThrottles}}</td></tr>
+</table>
+{{end}}
+{{if .InvocationSparkline}}
+<svg width="240" height="48" viewBox="0 0 240 48" role="img" aria-label="Invocations over time"><polyline points="{{.InvocationSparkline}}"/></svg>
+{{end}}
+{{with .Metrics.AWS.APIGateway}}
+<h2>API Gateway</h2>
+<table>
+<tr><th>Requests</th><td>{{number .TotalRequests}}</td></tr>
+<tr><th>Error rate</th><td>{{decimal .ErrorRate}}%</td></tr>
+<tr><th>Average latency</th><td>{{decimal .AverageLatency}} ms</td></tr>
+<tr><th>p99 latency</th><td>{{decimal .LatencyP99}} ms</td></tr>
+</table>
+{{end}}
+{{with .Metrics.AWS.DynamoDB}}
+<h2>DynamoDB</h2>
+<table>
+<tr><th>Tables</th><td>{{.TableCount}}</td></tr>
+<tr><th>Read capacity consumed</th><td>{{number .TotalReadCapacity}}</td></tr>
+<tr><th>Write capacity consumed</th><td>{{number .TotalWriteCapacity}}</td></tr>
+<tr><th>Throttled requests</th><td>{{number .TotalThrottles}}</td></tr>
+<tr><th>Items</th><td>{{.TotalItemCount}}</td></tr>
+</table>
+{{end}}
+{{with .Metrics.AWS.Cost}}
+<h2>Cost</h2>
+<table>
+<tr><th>Current period</th><td>{{money .CurrentPeriod}}</td></tr>
+<tr><th>Daily average</th><td>{{money .DailyAverage}}</td></tr>
+<tr><th>Projected month</th><td>{{money .ProjectedMonth}}</td></tr>
+{{if $.HasForecast}}<tr><th>Forecast (next 30 days)</th><td>{{money $.ForecastCost}}</td></tr>{{end}}
+</table>
+{{if .TopServices}}
+<table>
+<tr><th>Service</th><th>Cost</th><th>Share</th></tr>
+{{range .TopServices}}<tr><td>{{.ServiceName}}</td><td>{{money .Cost}}</td><td>{{decimal .Percentage}}%</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+{{if .CostSparkline}}
+<svg width="240" height="48" viewBox="0 0 240 48" role="img" aria-label="Daily cost"><polyline points="{{.CostSparkline}}"/></svg>
+{{end}}
+{{with .Metrics.AppStore}}
+<h2>App Store</h2>
+<table>
+<tr><th>Downloads</th><td>{{.Downloads}}</td></tr>
+<tr><th>Updates</th><td>{{.Updates}}</td></tr>
+<tr><th>Revenue</th><td>{{money .Revenue}}</td></tr>
+<tr><th>ARPU</th><td>{{money .ARPU}}</td></tr>
+<tr><th>Average rating</th><td>{{decimal .AverageRating}} ({{.TotalRatings}} ratings)</td></tr>
+</table>
+{{end}}
+</body>
+</html>
+`
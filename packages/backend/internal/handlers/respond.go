@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/jamesvolpe/central-analytics/backend/pkg/response"
+)
+
+// writeJSON writes data as a JSON response with statusCode, in the standard envelope when
+// it's enabled. Handlers write every JSON body through it so the envelope setting applies
+// across the API.
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	response.WriteJSON(w, statusCode, data)
+}
+
+// writeError writes message as an error response with statusCode, in place of http.Error
+func writeError(w http.ResponseWriter, message string, statusCode int) {
+	response.WriteError(w, message, statusCode)
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/pkg/response"
+)
+
+func TestHandlersFollowEnvelopeSetting(t *testing.T) {
+	tests := []struct {
+		name     string
+		envelope bool
+	}{
+		{name: "bare"},
+		{name: "enveloped", envelope: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response.SetEnvelope(tt.envelope)
+			t.Cleanup(func() { response.SetEnvelope(false) })
+			aggregator := newTestAggregator(&fakeAppStore{analytics: &appstore.AppAnalytics{Downloads: 42}})
+
+			rec := serveCached(aggregator.GetAppCard, "/apps/app/card")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+			}
+			var body map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			card := rec.Body.Bytes()
+			if tt.envelope {
+				if string(body["success"]) != "true" {
+					t.Errorf("success = %s, want true", body["success"])
+				}
+				card = body["data"]
+			}
+			var decoded AppCard
+			if err := json.Unmarshal(card, &decoded); err != nil || decoded.AppID != "app" {
+				t.Errorf("card = %s (%v), want the app's card", card, err)
+			}
+
+			rec = serveCached(aggregator.GetAggregatedMetrics, cachedPath+"&sections=revenue")
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", rec.Code)
+			}
+			var failure response.StandardResponse
+			err := json.Unmarshal(rec.Body.Bytes(), &failure)
+			if tt.envelope && (err != nil || failure.Success || failure.Error == "") {
+				t.Errorf("error body = %s, want a failed standard response", rec.Body)
+			}
+			if !tt.envelope && err == nil {
+				t.Errorf("error body = %s, want plain text", rec.Body)
+			}
+		})
+	}
+}
+
+func TestEnvelopedPartialResultsReportFailure(t *testing.T) {
+	response.SetEnvelope(true)
+	t.Cleanup(func() { response.SetEnvelope(false) })
+	unavailable := errors.New("unavailable")
+	throttled := fmt.Errorf("lambda:fn: %w", aws.ErrThrottled)
+
+	tests := []struct {
+		name        string
+		succeeded   int
+		failures    []error
+		wantStatus  int
+		wantSuccess bool
+	}{
+		{name: "partial", succeeded: 1, failures: []error{unavailable}, wantStatus: http.StatusOK, wantSuccess: true},
+		{name: "all failed", failures: []error{unavailable, throttled}, wantStatus: http.StatusBadGateway},
+		{name: "all throttled", failures: []error{throttled}, wantStatus: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outcome := newPartialResult()
+			for i := 0; i < tt.succeeded; i++ {
+				outcome.succeeded()
+			}
+			for i, err := range tt.failures {
+				outcome.failed(fmt.Sprintf("resource-%d", i), err)
+			}
+
+			rec := httptest.NewRecorder()
+			outcome.writeJSON(rec, map[string]interface{}{"data": "value"})
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			var body struct {
+				Success bool        `json:"success"`
+				Data    partialBody `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if body.Success != tt.wantSuccess {
+				t.Errorf("success = %v on a %d, want %v", body.Success, rec.Code, tt.wantSuccess)
+			}
+			if len(body.Data.Failures) != len(tt.failures) {
+				t.Errorf("failures = %+v, want %d in the enveloped data", body.Data.Failures, len(tt.failures))
+			}
+		})
+	}
+}
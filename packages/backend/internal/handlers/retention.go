@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stores with retention windows
+const (
+	RetentionStoreRatingsHistory = "ratings-history"
+)
+
+// DefaultRatingsHistoryRetention is how long ratings snapshots are kept
+const DefaultRatingsHistoryRetention = 365 * 24 * time.Hour
+
+// StoreRetention reports one store's retention window and pruning history
+type StoreRetention struct {
+	Store       string     `json:"store"`
+	Retention   string     `json:"retention"`
+	LastPruned  int        `json:"lastPruned"`
+	TotalPruned int64      `json:"totalPruned"`
+	LastRun     *time.Time `json:"lastRun"`
+}
+
+// Retention holds per-store retention windows and counts what pruning has removed.
+// A store with no window, or a zero window, keeps its data forever.
+type Retention struct {
+	windows map[string]time.Duration
+
+	mu    sync.Mutex
+	stats map[string]*StoreRetention
+}
+
+// NewRetention creates a retention policy from per-store windows
+func NewRetention(windows map[string]time.Duration) *Retention {
+	return &Retention{
+		windows: windows,
+		stats:   make(map[string]*StoreRetention),
+	}
+}
+
+// Cutoff returns the time before which store's items should be pruned, and false if the
+// store keeps its data forever
+func (r *Retention) Cutoff(store string, now time.Time) (time.Time, bool) {
+	if r == nil || r.windows[store] <= 0 {
+		return time.Time{}, false
+	}
+	return now.Add(-r.windows[store]), true
+}
+
+// record adds a pruning run's deleted item count to store's totals
+func (r *Retention) record(store string, pruned int, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats, ok := r.stats[store]
+	if !ok {
+		stats = &StoreRetention{Store: store, Retention: r.windows[store].String()}
+		r.stats[store] = stats
+	}
+	stats.LastPruned = pruned
+	stats.TotalPruned += int64(pruned)
+	stats.LastRun = &at
+}
+
+// Stats reports every store with a retention window, ordered by name
+func (r *Retention) Stats() []StoreRetention {
+	stores := []StoreRetention{}
+	if r == nil {
+		return stores
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for store, window := range r.windows {
+		if window <= 0 {
+			continue
+		}
+		stats := StoreRetention{Store: store, Retention: window.String()}
+		if recorded, ok := r.stats[store]; ok {
+			stats = *recorded
+		}
+		stores = append(stores, stats)
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].Store < stores[j].Store })
+	return stores
+}
+
+// PruneExpiredData deletes items older than each store's retention window
+func (h *AppHandler) PruneExpiredData(ctx context.Context) error {
+	now := time.Now().UTC()
+	var errs []error
+
+	if cutoff, ok := h.Retention.Cutoff(RetentionStoreRatingsHistory, now); ok && h.RatingsHistory != nil {
+		pruned := 0
+		for _, app := range h.AppsConfig.GetAllApps() {
+			n, err := h.RatingsHistory.PruneSnapshots(ctx, app.ID, cutoff)
+			pruned += n
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %s: %w", RetentionStoreRatingsHistory, app.ID, err))
+			}
+		}
+		h.Retention.record(RetentionStoreRatingsHistory, pruned, now)
+		if pruned > 0 {
+			h.Logger.Info("Pruned expired data", "store", RetentionStoreRatingsHistory, "items", pruned, "cutoff", cutoff)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetRetentionDiagnostics reports retention windows and how many items pruning has removed
+func (h *AppHandler) GetRetentionDiagnostics(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"stores":    h.Retention.Stats(),
+		"timestamp": time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
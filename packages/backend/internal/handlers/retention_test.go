@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// newRetentionHandler builds a handler for two apps whose ratings history is kept for window
+func newRetentionHandler(history RatingsHistoryStore, window time.Duration) *AppHandler {
+	return &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{
+			"first":  {ID: "first"},
+			"second": {ID: "second"},
+		}},
+		RatingsHistory: history,
+		Retention:      NewRetention(map[string]time.Duration{RetentionStoreRatingsHistory: window}),
+		Logger:         slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// agedSnapshots holds a snapshot per app taken each of ages ago
+func agedSnapshots(now time.Time, ages ...time.Duration) []aws.RatingSnapshot {
+	var snapshots []aws.RatingSnapshot
+	for _, appID := range []string{"first", "second"} {
+		for _, age := range ages {
+			snapshots = append(snapshots, aws.RatingSnapshot{AppID: appID, Timestamp: now.Add(-age), AverageRating: 4.5, TotalRatings: 100})
+		}
+	}
+	return snapshots
+}
+
+func TestPruneExpiredDataRemovesOnlyExpiredSnapshots(t *testing.T) {
+	day := 24 * time.Hour
+	now := time.Now().UTC()
+	history := &memoryRatingsHistory{snapshots: agedSnapshots(now, 400*day, 31*day, 29*day, time.Hour)}
+	handler := newRetentionHandler(history, 30*day)
+
+	if err := handler.PruneExpiredData(context.Background()); err != nil {
+		t.Fatalf("PruneExpiredData: %v", err)
+	}
+
+	if len(history.snapshots) != 4 {
+		t.Fatalf("kept %d snapshots, want the 4 inside the window", len(history.snapshots))
+	}
+	for _, snapshot := range history.snapshots {
+		if age := now.Sub(snapshot.Timestamp); age >= 30*day {
+			t.Errorf("kept %s snapshot %s old, past the 30 day window", snapshot.AppID, age)
+		}
+	}
+
+	stats := handler.Retention.Stats()
+	if len(stats) != 1 || stats[0].Store != RetentionStoreRatingsHistory || stats[0].LastPruned != 4 || stats[0].TotalPruned != 4 || stats[0].LastRun == nil {
+		t.Fatalf("stats = %+v, want 4 ratings snapshots pruned", stats)
+	}
+
+	// A second run finds nothing new to prune but keeps the running total
+	if err := handler.PruneExpiredData(context.Background()); err != nil {
+		t.Fatalf("second PruneExpiredData: %v", err)
+	}
+	if stats := handler.Retention.Stats(); stats[0].LastPruned != 0 || stats[0].TotalPruned != 4 {
+		t.Errorf("stats after a second run = %+v, want last 0, total 4", stats[0])
+	}
+	if len(history.snapshots) != 4 {
+		t.Errorf("second run left %d snapshots, want 4", len(history.snapshots))
+	}
+}
+
+func TestPruneExpiredDataKeepsForeverWithoutWindow(t *testing.T) {
+	now := time.Now().UTC()
+
+	for _, window := range []time.Duration{0, -time.Hour} {
+		history := &memoryRatingsHistory{snapshots: agedSnapshots(now, 10*365*24*time.Hour)}
+		handler := newRetentionHandler(history, window)
+
+		if err := handler.PruneExpiredData(context.Background()); err != nil {
+			t.Fatalf("PruneExpiredData with window %s: %v", window, err)
+		}
+		if len(history.snapshots) != 2 {
+			t.Errorf("window %s pruned down to %d snapshots, want all 2 kept", window, len(history.snapshots))
+		}
+		if stats := handler.Retention.Stats(); len(stats) != 0 {
+			t.Errorf("window %s stats = %+v, want no stores", window, stats)
+		}
+	}
+
+	// No retention policy at all keeps everything too
+	history := &memoryRatingsHistory{snapshots: agedSnapshots(now, 10*365*24*time.Hour)}
+	handler := newRetentionHandler(history, 0)
+	handler.Retention = nil
+	if err := handler.PruneExpiredData(context.Background()); err != nil {
+		t.Fatalf("PruneExpiredData without a policy: %v", err)
+	}
+	if len(history.snapshots) != 2 {
+		t.Errorf("no policy pruned down to %d snapshots, want all 2 kept", len(history.snapshots))
+	}
+}
+
+func TestGetRetentionDiagnostics(t *testing.T) {
+	now := time.Now().UTC()
+	history := &memoryRatingsHistory{snapshots: agedSnapshots(now, 48*time.Hour, time.Hour)}
+	handler := newRetentionHandler(history, 24*time.Hour)
+	if err := handler.PruneExpiredData(context.Background()); err != nil {
+		t.Fatalf("PruneExpiredData: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.GetRetentionDiagnostics(rec, httptest.NewRequest(http.MethodGet, "/api/diagnostics/retention", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var body struct {
+		Stores []StoreRetention `json:"stores"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(body.Stores) != 1 || body.Stores[0].Retention != "24h0m0s" || body.Stores[0].LastPruned != 2 || body.Stores[0].TotalPruned != 2 {
+		t.Errorf("stores = %+v, want ratings history with a 24h window and 2 pruned", body.Stores)
+	}
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+)
+
+// GetAppStoreReviews returns a page of customer reviews filtered by rating, territory and date
+func (h *AppHandler) GetAppStoreReviews(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	if h.AppStore == nil {
+		writeError(w, "App Store Connect not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	filter, err := parseReviewFilter(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.AppStore.GetCustomerReviews(r.Context(), h.AppsConfig.GetAppStoreID(appID), filter)
+	if errors.Is(err, appstore.ErrInvalidCursor) {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeAppStoreError(w, fmt.Sprintf("Failed to get App Store reviews: %v", err), err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"appId":      appID,
+		"reviews":    page.Reviews,
+		"nextCursor": page.NextCursor,
+		"timestamp":  time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// parseReviewFilter reads the minRating, territory, start, end, cursor and limit parameters.
+// Unlike other endpoints there is no default date range; omitting start or end leaves it open.
+func parseReviewFilter(r *http.Request) (appstore.ReviewFilter, error) {
+	query := r.URL.Query()
+	filter := appstore.ReviewFilter{
+		Territory: strings.ToUpper(strings.TrimSpace(query.Get("territory"))),
+		Cursor:    query.Get("cursor"),
+	}
+
+	if value := query.Get("minRating"); value != "" {
+		rating, err := strconv.Atoi(value)
+		if err != nil || rating < 1 || rating > 5 {
+			return filter, fmt.Errorf("minRating must be an integer from 1 to 5")
+		}
+		filter.MinRating = rating
+	}
+
+	if filter.Territory != "" && len(filter.Territory) != 3 {
+		return filter, fmt.Errorf("territory must be a three-letter ISO country code")
+	}
+
+	for name, target := range map[string]*time.Time{"start": &filter.Start, "end": &filter.End} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be an RFC3339 timestamp", name)
+		}
+		*target = t
+	}
+	if !filter.Start.IsZero() && !filter.End.IsZero() && filter.End.Before(filter.Start) {
+		return filter, fmt.Errorf("end must not be before start")
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > appstore.MaxReviewsPageSize {
+			return filter, fmt.Errorf("limit must be an integer from 1 to %d", appstore.MaxReviewsPageSize)
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseReviewFilter(t *testing.T) {
+	tests := []struct {
+		query         string
+		wantRating    int
+		wantTerritory string
+		wantErr       bool
+	}{
+		{query: ""},
+		{query: "minRating=4&territory=usa", wantRating: 4, wantTerritory: "USA"},
+		{query: "minRating=0", wantErr: true},
+		{query: "minRating=6", wantErr: true},
+		{query: "territory=US", wantErr: true},
+		{query: "start=2024-05-02T00:00:00Z&end=2024-05-01T00:00:00Z", wantErr: true},
+		{query: "start=yesterday", wantErr: true},
+		{query: "limit=500", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		filter, err := parseReviewFilter(httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseReviewFilter(%q) err = %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if err == nil && (filter.MinRating != tt.wantRating || filter.Territory != tt.wantTerritory) {
+			t.Errorf("parseReviewFilter(%q) = %+v, want rating %d, territory %q", tt.query, filter, tt.wantRating, tt.wantTerritory)
+		}
+	}
+
+	// No date range is applied by default
+	filter, _ := parseReviewFilter(httptest.NewRequest(http.MethodGet, "/?end=2024-05-01T00:00:00Z", nil))
+	if !filter.Start.IsZero() || !filter.End.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("filter = %+v, want only an end", filter)
+	}
+}
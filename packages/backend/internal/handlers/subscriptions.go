@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/appstore"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// GetAppStoreSubscriptions reports active subscriptions, new subscriptions and cancellations,
+// retention and estimated MRR. When App Store Connect isn't set up for sales reports or the app
+// has no subscriptions it returns null metrics flagged unavailable rather than an error.
+func (h *AppHandler) GetAppStoreSubscriptions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	// Parse time range
+	startTime, endTime := parseTimeRange(r)
+
+	metadata := map[string]interface{}{
+		"appId":     appID,
+		"period":    timerange.NewPeriod(startTime, endTime),
+		"available": true,
+	}
+
+	var subscriptions *appstore.SubscriptionMetrics
+	appStoreID := h.AppsConfig.GetAppStoreID(appID)
+	switch {
+	case h.AppStore == nil:
+		metadata["available"] = false
+		metadata["error"] = "App Store Connect not configured"
+	case appStoreID == "":
+		metadata["available"] = false
+		metadata["error"] = "No App Store ID configured for this app"
+	default:
+		var err error
+		subscriptions, err = h.AppStore.GetSubscriptionMetrics(r.Context(), appStoreID, startTime, endTime)
+		if errors.Is(err, appstore.ErrNoSubscriptions) || errors.Is(err, appstore.ErrVendorNumberNotSet) {
+			metadata["available"] = false
+			metadata["error"] = err.Error()
+		} else if err != nil {
+			writeAppStoreError(w, fmt.Sprintf("Failed to get App Store subscriptions: %v", err), err)
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"appId":         appID,
+		"subscriptions": subscriptions,
+		"metadata":      metadata,
+		"timestamp":     time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
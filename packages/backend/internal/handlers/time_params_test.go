@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/auth"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+// serveAuthenticated sends an admin's GET for target through AuthMiddleware, passing the
+// request that reaches the handler to next
+func serveAuthenticated(t *testing.T, target string, next http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+
+	h := &AppHandler{
+		JWTManager:  auth.NewJWTManager([]byte(testJWTSecret), "central-analytics", time.Hour),
+		AuthMetrics: auth.NewAuthMetrics(),
+		AppsConfig:  &appconfig.AppsConfiguration{},
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, testJWTSecret, time.Hour, &auth.AppleUserInfo{Sub: "admin", IsAdmin: true}))
+	rec := httptest.NewRecorder()
+	h.AuthMiddleware(next)(rec, req)
+	return rec
+}
+
+func TestAuthMiddlewarePeriodParam(t *testing.T) {
+	end := time.Now()
+	start := end.Add(-time.Hour)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantPeriod int32
+	}{
+		{query: "", wantStatus: http.StatusOK, wantPeriod: 60},
+		{query: "?period=900", wantStatus: http.StatusOK, wantPeriod: 900},
+		{query: "?period=90", wantStatus: http.StatusBadRequest},
+		{query: "?period=0", wantStatus: http.StatusBadRequest},
+		{query: "?period=691200", wantStatus: http.StatusBadRequest},
+		{query: "?period=5m", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		var period int32
+		rec := serveAuthenticated(t, "/api/apps"+tt.query, func(w http.ResponseWriter, r *http.Request) {
+			period = aws.MetricPeriod(r.Context(), start, end)
+		})
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %q = %d, want %d", tt.query, rec.Code, tt.wantStatus)
+		}
+		if period != tt.wantPeriod {
+			t.Errorf("GET %q period = %d, want %d", tt.query, period, tt.wantPeriod)
+		}
+	}
+}
+
+func TestRangeShorthand(t *testing.T) {
+	explicit := "&start=2024-05-01T00:00:00Z&end=2024-05-02T00:00:00Z"
+	shorthands := map[string]time.Duration{
+		"1h":  time.Hour,
+		"6h":  6 * time.Hour,
+		"24h": 24 * time.Hour,
+		"7d":  7 * 24 * time.Hour,
+		"30d": 30 * 24 * time.Hour,
+	}
+
+	for value, span := range shorthands {
+		t.Run(value, func(t *testing.T) {
+			// The shorthand ends now and wins over an explicit start and end
+			req := httptest.NewRequest(http.MethodGet, "/?range="+value+explicit, nil)
+			before := time.Now()
+			start, end := parseTimeRange(req)
+			if end.Before(before) || end.After(time.Now()) || end.Sub(start) != span {
+				t.Errorf("parseTimeRange = %s to %s, want %s ending now", start, end, span)
+			}
+
+			h := NewTimeSeriesHandler(nil, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			start, end, _, err := h.parseTimeSeriesParams(req)
+			if err != nil {
+				t.Fatalf("parseTimeSeriesParams: %v", err)
+			}
+			if end.Before(before) || end.Sub(start) != span {
+				t.Errorf("parseTimeSeriesParams = %s to %s, want %s ending now", start, end, span)
+			}
+
+			if rec := serveAuthenticated(t, "/api/apps?range="+value, func(w http.ResponseWriter, r *http.Request) {}); rec.Code != http.StatusOK {
+				t.Errorf("AuthMiddleware = %d, want 200", rec.Code)
+			}
+		})
+	}
+}
+
+func TestInvalidRangeShorthand(t *testing.T) {
+	for _, value := range []string{"1y", "0h", "91d", "soon"} {
+		reached := false
+		rec := serveAuthenticated(t, "/api/apps?range="+value, func(w http.ResponseWriter, r *http.Request) { reached = true })
+		if rec.Code != http.StatusBadRequest || reached {
+			t.Errorf("range=%s: status = %d, handler reached = %v, want 400 before the handler", value, rec.Code, reached)
+		}
+
+		h := NewTimeSeriesHandler(nil, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if _, _, _, err := h.parseTimeSeriesParams(httptest.NewRequest(http.MethodGet, "/?range="+value, nil)); err == nil {
+			t.Errorf("range=%s: parseTimeSeriesParams succeeded, want an error", value)
+		}
+	}
+}
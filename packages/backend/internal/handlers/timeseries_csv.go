@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time series response formats, selectable with the format query parameter
+const (
+	timeSeriesFormatJSON = "json"
+	timeSeriesFormatCSV  = "csv"
+)
+
+// writeTimeSeries writes response as JSON, or as a CSV attachment of its series when the
+// request asks for ?format=csv
+func writeTimeSeries(w http.ResponseWriter, r *http.Request, response TimeSeriesData) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", timeSeriesFormatJSON:
+		writeJSON(w, http.StatusOK, response)
+	case timeSeriesFormatCSV:
+		writeTimeSeriesCSV(w, response)
+	default:
+		writeError(w, fmt.Sprintf("format must be %q or %q", timeSeriesFormatJSON, timeSeriesFormatCSV), http.StatusBadRequest)
+	}
+}
+
+// writeTimeSeriesCSV writes one timestamp,value row per point with RFC3339 timestamps, named
+// after the app, metric and range so downloads of different series don't collide
+func writeTimeSeriesCSV(w http.ResponseWriter, response TimeSeriesData) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", timeSeriesFilename(response)))
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"timestamp", "value"})
+	for _, point := range response.Series {
+		writer.Write([]string{
+			point.Timestamp.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(point.Value, 'f', -1, 64),
+		})
+	}
+	writer.Flush()
+}
+
+// timeSeriesFilename builds e.g. "myapp_lambda-invocations_20240101T000000Z-20240102T000000Z.csv"
+func timeSeriesFilename(response TimeSeriesData) string {
+	// Period bounds are RFC3339 in UTC; drop the separators for a compact stamp
+	compact := strings.NewReplacer("-", "", ":", "")
+	name := fmt.Sprintf("%s_%s_%s-%s.csv",
+		response.AppID,
+		strings.ReplaceAll(response.MetricType, ":", "-"),
+		compact.Replace(response.Period.Start),
+		compact.Replace(response.Period.End),
+	)
+	// Keep the header value to a safe filename whatever the path and query held
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("._-", r) {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// smallSeries is a two-hour Lambda series with a fractional value and a point in another zone
+func smallSeries() TimeSeriesData {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	return TimeSeriesData{
+		AppID:      "myapp",
+		MetricType: "lambda:invocations",
+		Period:     timerange.NewPeriod(start, start.Add(2*time.Hour)),
+		Interval:   "1h0m0s",
+		Series: []TimeSeriesPoint{
+			{Timestamp: start, Value: 12},
+			{Timestamp: start.Add(time.Hour).In(time.FixedZone("EST", -5*60*60)), Value: 3.25},
+		},
+	}
+}
+
+func TestWriteTimeSeriesCSV(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeTimeSeries(rec, httptest.NewRequest(http.MethodGet, "/timeseries?format=csv", nil), smallSeries())
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv", got)
+	}
+	wantDisposition := `attachment; filename="myapp_lambda-invocations_20240101T000000Z-20240101T020000Z.csv"`
+	if got := rec.Header().Get("Content-Disposition"); got != wantDisposition {
+		t.Errorf("Content-Disposition = %q, want %q", got, wantDisposition)
+	}
+	want := "timestamp,value\n2024-01-01T00:00:00Z,12\n2024-01-01T01:00:00Z,3.25\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("body = %q, want %q", got, want)
+	}
+}
+
+func TestWriteTimeSeriesFormats(t *testing.T) {
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantJSON   bool
+	}{
+		{query: "", wantStatus: http.StatusOK, wantJSON: true},
+		{query: "?format=json", wantStatus: http.StatusOK, wantJSON: true},
+		{query: "?format=xlsx", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			writeTimeSeries(rec, httptest.NewRequest(http.MethodGet, "/timeseries"+tt.query, nil), smallSeries())
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !tt.wantJSON {
+				return
+			}
+			if got := rec.Header().Get("Content-Disposition"); got != "" {
+				t.Errorf("JSON response is an attachment: %q", got)
+			}
+			var response TimeSeriesData
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.AppID != "myapp" || len(response.Series) != 2 {
+				t.Errorf("response = %+v, want the series as JSON", response)
+			}
+		})
+	}
+}
+
+func TestTimeSeriesFilenameIsSafe(t *testing.T) {
+	response := smallSeries()
+	response.AppID = `../"evil" app`
+	response.MetricType = "cost:daily/total"
+
+	want := "..__evil__app_cost-daily_total_20240101T000000Z-20240101T020000Z.csv"
+	if got := timeSeriesFilename(response); got != want {
+		t.Errorf("filename = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// exportMetricPrefix namespaces exported metric names
+const exportMetricPrefix = "central_analytics"
+
+// defaultExportMetrics are exported when no metrics parameter is given
+var defaultExportMetrics = []string{"lambda:invocations", "lambda:errors", "apigateway:count", "dynamodb:consumed", "cost:daily"}
+
+// exportableMetrics lists the metrics each service can export
+var exportableMetrics = map[string][]string{
+	"lambda":     {"invocations", "errors", "duration", "throttles", "concurrent"},
+	"apigateway": {"count", "latency", "4xx", "5xx", "errors"},
+	"dynamodb":   {"consumed", "read", "write", "throttles", "errors"},
+	"cost":       {"daily"},
+}
+
+// ExportSample is one value at a millisecond Unix timestamp
+type ExportSample struct {
+	Value       float64 `json:"value"`
+	TimestampMs int64   `json:"timestampMs"`
+}
+
+// ExportSeries is a labelled series shaped like a Prometheus remote-write TimeSeries
+type ExportSeries struct {
+	Labels  map[string]string `json:"labels"`
+	Samples []ExportSample    `json:"samples"`
+}
+
+// exportMetric is a parsed service:metric pair
+type exportMetric struct {
+	Service string
+	Metric  string
+}
+
+// parseExportMetrics parses a comma-separated list of service:metric pairs
+func parseExportMetrics(value string) ([]exportMetric, error) {
+	names := defaultExportMetrics
+	if strings.TrimSpace(value) != "" {
+		names = strings.Split(value, ",")
+	}
+
+	var metrics []exportMetric
+	for _, name := range names {
+		service, metric, ok := strings.Cut(strings.ToLower(strings.TrimSpace(name)), ":")
+		if !ok {
+			return nil, fmt.Errorf("metric %q must be service:metric", name)
+		}
+		valid := false
+		for _, candidate := range exportableMetrics[service] {
+			if candidate == metric {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown metric %q", name)
+		}
+		metrics = append(metrics, exportMetric{Service: service, Metric: metric})
+	}
+	return metrics, nil
+}
+
+// newExportSeries labels a series with app, service, resource and metric and converts its
+// points to millisecond samples
+func newExportSeries(appID, service, resource, metric string, points []TimeSeriesPoint) ExportSeries {
+	samples := make([]ExportSample, 0, len(points))
+	for _, point := range points {
+		samples = append(samples, ExportSample{Value: point.Value, TimestampMs: point.Timestamp.UnixMilli()})
+	}
+
+	return ExportSeries{
+		Labels: map[string]string{
+			"__name__": fmt.Sprintf("%s_%s_%s", exportMetricPrefix, service, metric),
+			"app":      appID,
+			"service":  service,
+			"resource": resource,
+			"metric":   metric,
+		},
+		Samples: samples,
+	}
+}
+
+// ExportTimeSeries returns time series, one per resource, in a Prometheus remote-write-style
+// JSON shape for backfilling into a TSDB. Select metrics with ?metrics=lambda:errors,cost:daily.
+func (h *TimeSeriesHandler) ExportTimeSeries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	metrics, err := parseExportMetrics(r.URL.Query().Get("metrics"))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	startTime, endTime, interval, err := h.parseTimeSeriesParams(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var lambdaFunctions []string
+	if h.appHandler.Features.Lambda {
+		lambdaFunctions, err = h.appHandler.SelectLambdaFunctions(r, appID)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	series := []ExportSeries{}
+	for _, metric := range metrics {
+		exported, err := h.exportMetricSeries(r.Context(), appID, metric, lambdaFunctions, startTime, endTime, interval)
+		if err != nil {
+			h.logger.Warn("Failed to export time series", "metric", metric.Service+":"+metric.Metric, "error", err)
+			continue
+		}
+		series = append(series, exported...)
+	}
+
+	response := map[string]interface{}{
+		"appId":      appID,
+		"period":     timerange.NewIntervalPeriod(startTime, endTime, interval),
+		"timeseries": series,
+		"timestamp":  time.Now().Unix(),
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// exportMetricSeries fetches one series per resource for a metric using the time series fetchers
+func (h *TimeSeriesHandler) exportMetricSeries(ctx context.Context, appID string, metric exportMetric, lambdaFunctions []string, startTime, endTime time.Time, interval time.Duration) ([]ExportSeries, error) {
+	features := h.appHandler.Features
+	var series []ExportSeries
+
+	switch metric.Service {
+	case "lambda":
+		for _, functionName := range lambdaFunctions {
+			points := h.lambdaSeries(ctx, []string{functionName}, metric.Metric, startTime, endTime, interval)
+			series = append(series, newExportSeries(appID, metric.Service, functionName, metric.Metric, points))
+		}
+	case "apigateway":
+		apiName := h.appHandler.AppsConfig.GetAPIGateway(appID)
+		if apiName == "" {
+			return nil, nil
+		}
+		points := h.apiGatewaySeries(ctx, apiName, metric.Metric, startTime, endTime, interval)
+		series = append(series, newExportSeries(appID, metric.Service, apiName, metric.Metric, points))
+	case "dynamodb":
+		if !features.DynamoDB {
+			return nil, nil
+		}
+		for _, tableName := range h.appHandler.AppsConfig.GetDynamoDBTables(appID) {
+			points := h.dynamoDBSeries(ctx, []string{tableName}, metric.Metric, startTime, endTime, interval)
+			series = append(series, newExportSeries(appID, metric.Service, tableName, metric.Metric, points))
+		}
+	case "cost":
+		if !features.Cost {
+			return nil, nil
+		}
+		points, err := h.costSeries(ctx, appID, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, newExportSeries(appID, metric.Service, appID, metric.Metric, points))
+	}
+
+	return series, nil
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+)
+
+func TestParseExportMetrics(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []exportMetric
+		wantErr bool
+	}{
+		{value: "", want: []exportMetric{{"lambda", "invocations"}, {"lambda", "errors"}, {"apigateway", "count"}, {"dynamodb", "consumed"}, {"cost", "daily"}}},
+		{value: " Lambda:Duration ,cost:daily", want: []exportMetric{{"lambda", "duration"}, {"cost", "daily"}}},
+		{value: "lambda", wantErr: true},
+		{value: "lambda:latency", wantErr: true},
+		{value: "s3:objects", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseExportMetrics(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseExportMetrics(%q) err = %v, want error %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseExportMetrics(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewExportSeriesLabelsAndMilliseconds(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 250*int(time.Millisecond), time.FixedZone("EDT", -4*60*60))
+	points := []TimeSeriesPoint{
+		{Timestamp: start, Value: 3},
+		{Timestamp: start.Add(5 * time.Minute), Value: 7.5},
+	}
+
+	series := newExportSeries("app", "lambda", "api-handler", "errors", points)
+
+	wantLabels := map[string]string{
+		"__name__": "central_analytics_lambda_errors",
+		"app":      "app",
+		"service":  "lambda",
+		"resource": "api-handler",
+		"metric":   "errors",
+	}
+	if !reflect.DeepEqual(series.Labels, wantLabels) {
+		t.Errorf("labels = %v, want %v", series.Labels, wantLabels)
+	}
+	wantSamples := []ExportSample{
+		{Value: 3, TimestampMs: 1714579200250},
+		{Value: 7.5, TimestampMs: 1714579500250},
+	}
+	if !reflect.DeepEqual(series.Samples, wantSamples) {
+		t.Errorf("samples = %v, want %v", series.Samples, wantSamples)
+	}
+
+	// An empty series still encodes samples as a list
+	encoded, err := json.Marshal(newExportSeries("app", "cost", "app", "daily", nil))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"labels":{"__name__":"central_analytics_cost_daily","app":"app","metric":"daily","resource":"app","service":"cost"},"samples":[]}`; string(encoded) != want {
+		t.Errorf("encoded = %s, want %s", encoded, want)
+	}
+}
+
+func TestExportTimeSeries(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	appHandler := &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{"app": {ID: "app"}}},
+		Logger:     logger,
+	}
+	h := NewTimeSeriesHandler(appHandler, 0, logger)
+
+	rec := serveCached(h.ExportTimeSeries, "/export?metrics=lambda:latency")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown metric: status = %d, want 400", rec.Code)
+	}
+
+	// Nothing configured for the app exports no series
+	rec = serveCached(h.ExportTimeSeries, "/export")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		AppID      string         `json:"appId"`
+		Timeseries []ExportSeries `json:"timeseries"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body.AppID != "app" || body.Timeseries == nil || len(body.Timeseries) != 0 {
+		t.Errorf("response = %+v, want an empty timeseries list", body)
+	}
+}
@@ -2,25 +2,46 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
 )
 
+// DefaultMaxTimeSeriesBuckets caps how many buckets a single time series request may produce
+const DefaultMaxTimeSeriesBuckets = 1500
+
+// coarseIntervals are the intervals tried, finest first, when the default interval yields too many buckets
+var coarseIntervals = []time.Duration{
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+}
+
 // TimeSeriesHandler handles time series data endpoints
 type TimeSeriesHandler struct {
 	appHandler *AppHandler
+	maxBuckets int
 	logger     *slog.Logger
 }
 
-// NewTimeSeriesHandler creates a new time series handler
-func NewTimeSeriesHandler(appHandler *AppHandler, logger *slog.Logger) *TimeSeriesHandler {
+// NewTimeSeriesHandler creates a new time series handler.
+// maxBuckets values below 1 use DefaultMaxTimeSeriesBuckets.
+func NewTimeSeriesHandler(appHandler *AppHandler, maxBuckets int, logger *slog.Logger) *TimeSeriesHandler {
+	if maxBuckets < 1 {
+		maxBuckets = DefaultMaxTimeSeriesBuckets
+	}
 	return &TimeSeriesHandler{
 		appHandler: appHandler,
+		maxBuckets: maxBuckets,
 		logger:     logger,
 	}
 }
@@ -29,9 +50,11 @@ func NewTimeSeriesHandler(appHandler *AppHandler, logger *slog.Logger) *TimeSeri
 type TimeSeriesData struct {
 	AppID      string            `json:"appId"`
 	MetricType string            `json:"metricType"`
-	Period     string            `json:"period"`
+	Period     timerange.Period  `json:"period"`
 	Interval   string            `json:"interval"`
 	Series     []TimeSeriesPoint `json:"series"`
+	Cumulative []TimeSeriesPoint `json:"cumulative,omitempty"`
+	Band       *aws.AnomalyBand  `json:"band,omitempty"`
 	Metadata   map[string]string `json:"metadata"`
 	Timestamp  int64             `json:"timestamp"`
 }
@@ -54,11 +77,59 @@ func (h *TimeSeriesHandler) GetLambdaTimeSeries(w http.ResponseWriter, r *http.R
 	}
 
 	// Parse time range and interval
-	startTime, endTime, interval := h.parseTimeSeriesParams(r)
+	startTime, endTime, interval, err := h.parseTimeSeriesParams(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	showBand, bandWidth, err := parseAnomalyBandParams(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if showBand && !aws.IsLambdaBandMetric(metricName) {
+		writeError(w, fmt.Sprintf("no expected band for Lambda metric %q", metricName), http.StatusBadRequest)
+		return
+	}
 
-	// Get Lambda functions for the app
-	lambdaFunctions := h.appHandler.AppsConfig.GetLambdaFunctions(appID)
+	// Get Lambda functions for the app, optionally narrowed by the functions parameter
+	lambdaFunctions, err := h.appHandler.SelectLambdaFunctions(r, appID)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	series := h.lambdaSeries(r.Context(), lambdaFunctions, metricName, startTime, endTime, interval)
+
+	var band *aws.AnomalyBand
+	if showBand && len(lambdaFunctions) > 0 {
+		band, err = h.appHandler.CloudWatch.GetLambdaAnomalyBand(r.Context(), lambdaFunctions, metricName, bandWidth, startTime, endTime, interval)
+		if err != nil {
+			h.logger.Warn("Failed to get Lambda expected band", "metric", metricName, "error", err)
+		}
+	}
+
+	response := TimeSeriesData{
+		AppID:      appID,
+		MetricType: "lambda:" + metricName,
+		Period:     timerange.NewIntervalPeriod(startTime, endTime, interval),
+		Interval:   interval.String(),
+		Series:     series,
+		Band:       band,
+		Metadata: map[string]string{
+			"unit":      h.getMetricUnit(metricName),
+			"functions": strconv.Itoa(len(lambdaFunctions)),
+		},
+		Timestamp: time.Now().Unix(),
+	}
+
+	writeTimeSeries(w, r, response)
+}
+
+// lambdaSeries buckets a Lambda metric summed across functions (duration is averaged weighted
+// by invocations, and errorRate is computed over the combined counts)
+func (h *TimeSeriesHandler) lambdaSeries(ctx context.Context, lambdaFunctions []string, metricName string, startTime, endTime time.Time, interval time.Duration) []TimeSeriesPoint {
 	series := []TimeSeriesPoint{}
 
 	// Generate time series data points
@@ -69,12 +140,13 @@ func (h *TimeSeriesHandler) GetLambdaTimeSeries(w http.ResponseWriter, r *http.R
 		}
 
 		totalValue := float64(0)
-		successCount := 0
+		var invocations, errors float64
+		var duration aws.WeightedMean
 
 		// Aggregate metrics from all Lambda functions
 		for _, functionName := range lambdaFunctions {
 			metrics, err := h.appHandler.CloudWatch.GetLambdaMetrics(
-				context.Background(),
+				ctx,
 				functionName,
 				current,
 				pointEnd,
@@ -90,18 +162,24 @@ func (h *TimeSeriesHandler) GetLambdaTimeSeries(w http.ResponseWriter, r *http.R
 			case "errors":
 				totalValue += metrics.Errors
 			case "duration":
-				totalValue += metrics.Duration
+				duration.Add(metrics.Duration, metrics.Invocations)
 			case "throttles":
 				totalValue += metrics.Throttles
 			case "concurrent":
 				totalValue += metrics.ConcurrentExecutions
 			}
-			successCount++
+			invocations += metrics.Invocations
+			errors += metrics.Errors
+		}
+
+		// Duration is the invocation-weighted average across functions
+		if metricName == "duration" {
+			totalValue = duration.Value()
 		}
 
-		// Calculate average for duration metric
-		if metricName == "duration" && successCount > 0 {
-			totalValue = totalValue / float64(successCount)
+		// Error rate is taken over the combined counts, not summed across functions
+		if metricName == "errorRate" {
+			totalValue = aws.ErrorRate(invocations, errors)
 		}
 
 		series = append(series, TimeSeriesPoint{
@@ -114,34 +192,46 @@ func (h *TimeSeriesHandler) GetLambdaTimeSeries(w http.ResponseWriter, r *http.R
 		})
 	}
 
+	return series
+}
+
+// GetCostTimeSeries returns cost metrics over time
+func (h *TimeSeriesHandler) GetCostTimeSeries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	// Parse time range
+	startTime, endTime, _, _ := h.parseTimeSeriesParams(r)
+
+	series, _ := h.costSeries(r.Context(), appID, startTime, endTime)
+
 	response := TimeSeriesData{
 		AppID:      appID,
-		MetricType: "lambda:" + metricName,
-		Period:     formatPeriod(startTime, endTime),
-		Interval:   interval.String(),
+		MetricType: "cost:daily",
+		Period:     timerange.NewIntervalPeriod(startTime, endTime, 24*time.Hour),
+		Interval:   "24h",
 		Series:     series,
 		Metadata: map[string]string{
-			"unit":      h.getMetricUnit(metricName),
-			"functions": strconv.Itoa(len(lambdaFunctions)),
+			"unit":     "USD",
+			"currency": "USD",
 		},
 		Timestamp: time.Now().Unix(),
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
-}
-
-// GetCostTimeSeries returns cost metrics over time
-func (h *TimeSeriesHandler) GetCostTimeSeries(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	appID := vars["appId"]
+	// Running total for month-to-date burn charts
+	if r.URL.Query().Get("cumulative") == "true" {
+		response.Cumulative = cumulativeSeries(series)
+	}
 
-	// Parse time range
-	startTime, endTime, _ := h.parseTimeSeriesParams(r)
+	writeTimeSeries(w, r, response)
+}
 
+// costSeries returns the app's daily cost as a series
+func (h *TimeSeriesHandler) costSeries(ctx context.Context, appID string, startTime, endTime time.Time) ([]TimeSeriesPoint, error) {
 	// Get daily cost data
-	costData, err := h.appHandler.CostExplorer.GetCostAndUsage(
-		context.Background(),
+	costData, err := h.appHandler.GetAppCosts(
+		ctx,
+		appID,
 		startTime,
 		endTime,
 	)
@@ -163,21 +253,22 @@ func (h *TimeSeriesHandler) GetCostTimeSeries(w http.ResponseWriter, r *http.Req
 		}
 	}
 
-	response := TimeSeriesData{
-		AppID:      appID,
-		MetricType: "cost:daily",
-		Period:     formatPeriod(startTime, endTime),
-		Interval:   "24h",
-		Series:     series,
-		Metadata: map[string]string{
-			"unit":     "USD",
-			"currency": "USD",
-		},
-		Timestamp: time.Now().Unix(),
-	}
+	return series, err
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// cumulativeSeries returns the running total of a chronological series
+func cumulativeSeries(series []TimeSeriesPoint) []TimeSeriesPoint {
+	cumulative := make([]TimeSeriesPoint, 0, len(series))
+	var total float64
+	for _, point := range series {
+		total += point.Value
+		cumulative = append(cumulative, TimeSeriesPoint{
+			Timestamp: point.Timestamp,
+			Value:     total,
+			Metadata:  point.Metadata,
+		})
+	}
+	return cumulative
 }
 
 // GetAPIGatewayTimeSeries returns API Gateway metrics over time
@@ -191,15 +282,58 @@ func (h *TimeSeriesHandler) GetAPIGatewayTimeSeries(w http.ResponseWriter, r *ht
 	}
 
 	// Parse time range and interval
-	startTime, endTime, interval := h.parseTimeSeriesParams(r)
+	startTime, endTime, interval, err := h.parseTimeSeriesParams(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	showBand, bandWidth, err := parseAnomalyBandParams(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if showBand && !aws.IsAPIGatewayBandMetric(metricName) {
+		writeError(w, fmt.Sprintf("no expected band for API Gateway metric %q", metricName), http.StatusBadRequest)
+		return
+	}
 
 	// Get API Gateway for the app
 	apiName := h.appHandler.AppsConfig.GetAPIGateway(appID)
 	if apiName == "" {
-		http.Error(w, "No API Gateway configured for this app", http.StatusNotFound)
+		writeError(w, "No API Gateway configured for this app", http.StatusNotFound)
 		return
 	}
 
+	series := h.apiGatewaySeries(r.Context(), apiName, metricName, startTime, endTime, interval)
+
+	var band *aws.AnomalyBand
+	if showBand {
+		band, err = h.appHandler.CloudWatch.GetAPIGatewayAnomalyBand(r.Context(), apiName, metricName, bandWidth, startTime, endTime, interval)
+		if err != nil {
+			h.logger.Warn("Failed to get API Gateway expected band", "metric", metricName, "error", err)
+		}
+	}
+
+	response := TimeSeriesData{
+		AppID:      appID,
+		MetricType: "apigateway:" + metricName,
+		Period:     timerange.NewIntervalPeriod(startTime, endTime, interval),
+		Interval:   interval.String(),
+		Series:     series,
+		Band:       band,
+		Metadata: map[string]string{
+			"unit":    h.getAPIMetricUnit(metricName),
+			"apiName": apiName,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+
+	writeTimeSeries(w, r, response)
+}
+
+// apiGatewaySeries buckets an API Gateway metric for one API
+func (h *TimeSeriesHandler) apiGatewaySeries(ctx context.Context, apiName, metricName string, startTime, endTime time.Time, interval time.Duration) []TimeSeriesPoint {
 	series := []TimeSeriesPoint{}
 
 	// Generate time series data points
@@ -210,7 +344,7 @@ func (h *TimeSeriesHandler) GetAPIGatewayTimeSeries(w http.ResponseWriter, r *ht
 		}
 
 		metrics, err := h.appHandler.CloudWatch.GetAPIGatewayMetrics(
-			context.Background(),
+			ctx,
 			apiName,
 			current,
 			pointEnd,
@@ -229,6 +363,8 @@ func (h *TimeSeriesHandler) GetAPIGatewayTimeSeries(w http.ResponseWriter, r *ht
 				value = metrics.Error5XX
 			case "errors":
 				value = metrics.Error4XX + metrics.Error5XX
+			case "errorRate":
+				value = aws.ErrorRate(metrics.Count, metrics.Error5XX)
 			}
 		}
 
@@ -242,21 +378,7 @@ func (h *TimeSeriesHandler) GetAPIGatewayTimeSeries(w http.ResponseWriter, r *ht
 		})
 	}
 
-	response := TimeSeriesData{
-		AppID:      appID,
-		MetricType: "apigateway:" + metricName,
-		Period:     formatPeriod(startTime, endTime),
-		Interval:   interval.String(),
-		Series:     series,
-		Metadata: map[string]string{
-			"unit":    h.getAPIMetricUnit(metricName),
-			"apiName": apiName,
-		},
-		Timestamp: time.Now().Unix(),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return series
 }
 
 // GetDynamoDBTimeSeries returns DynamoDB metrics over time
@@ -270,11 +392,35 @@ func (h *TimeSeriesHandler) GetDynamoDBTimeSeries(w http.ResponseWriter, r *http
 	}
 
 	// Parse time range and interval
-	startTime, endTime, interval := h.parseTimeSeriesParams(r)
+	startTime, endTime, interval, err := h.parseTimeSeriesParams(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get DynamoDB tables for the app
 	tables := h.appHandler.AppsConfig.GetDynamoDBTables(appID)
 
+	series := h.dynamoDBSeries(r.Context(), tables, metricName, startTime, endTime, interval)
+
+	response := TimeSeriesData{
+		AppID:      appID,
+		MetricType: "dynamodb:" + metricName,
+		Period:     timerange.NewIntervalPeriod(startTime, endTime, interval),
+		Interval:   interval.String(),
+		Series:     series,
+		Metadata: map[string]string{
+			"unit":   h.getDynamoDBMetricUnit(metricName),
+			"tables": strconv.Itoa(len(tables)),
+		},
+		Timestamp: time.Now().Unix(),
+	}
+
+	writeTimeSeries(w, r, response)
+}
+
+// dynamoDBSeries buckets a DynamoDB metric summed across tables
+func (h *TimeSeriesHandler) dynamoDBSeries(ctx context.Context, tables []string, metricName string, startTime, endTime time.Time, interval time.Duration) []TimeSeriesPoint {
 	series := []TimeSeriesPoint{}
 
 	// Generate time series data points
@@ -289,7 +435,7 @@ func (h *TimeSeriesHandler) GetDynamoDBTimeSeries(w http.ResponseWriter, r *http
 		// Aggregate metrics from all tables
 		for _, tableName := range tables {
 			metrics, err := h.appHandler.DynamoDB.GetTableMetrics(
-				context.Background(),
+				ctx,
 				tableName,
 				current,
 				pointEnd,
@@ -322,48 +468,117 @@ func (h *TimeSeriesHandler) GetDynamoDBTimeSeries(w http.ResponseWriter, r *http
 		})
 	}
 
-	response := TimeSeriesData{
-		AppID:      appID,
-		MetricType: "dynamodb:" + metricName,
-		Period:     formatPeriod(startTime, endTime),
-		Interval:   interval.String(),
-		Series:     series,
-		Metadata: map[string]string{
-			"unit":   h.getDynamoDBMetricUnit(metricName),
-			"tables": strconv.Itoa(len(tables)),
-		},
-		Timestamp: time.Now().Unix(),
+	return series
+}
+
+// CapacityTimeSeriesData represents provisioned versus consumed capacity over time
+type CapacityTimeSeriesData struct {
+	AppID           string                     `json:"appId"`
+	Period          timerange.Period           `json:"period"`
+	Tables          []*aws.TableCapacitySeries `json:"tables"`
+	OnDemandTables  []string                   `json:"onDemandTables"`
+	ThrottleRiskAny bool                       `json:"throttleRiskAny"`
+	Timestamp       int64                      `json:"timestamp"`
+}
+
+// GetDynamoDBCapacityTimeSeries returns capacity headroom (provisioned - consumed) for provisioned tables
+func (h *TimeSeriesHandler) GetDynamoDBCapacityTimeSeries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	// Parse time range and interval
+	startTime, endTime, interval, err := h.parseTimeSeriesParams(r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get DynamoDB tables for the app
+	tables := h.appHandler.AppsConfig.GetDynamoDBTables(appID)
+
+	response := CapacityTimeSeriesData{
+		AppID:          appID,
+		Period:         timerange.NewIntervalPeriod(startTime, endTime, interval),
+		Tables:         []*aws.TableCapacitySeries{},
+		OnDemandTables: []string{},
+		Timestamp:      time.Now().Unix(),
+	}
+
+	for _, tableName := range tables {
+		series, err := h.appHandler.DynamoDB.GetTableCapacitySeries(r.Context(), tableName, startTime, endTime, interval)
+		if err != nil {
+			h.logger.Warn("Failed to get capacity series", "table", tableName, "error", err)
+			continue
+		}
+
+		// Headroom is meaningless for on-demand tables, which have no provisioned ceiling
+		if series.BillingMode == "PAY_PER_REQUEST" {
+			response.OnDemandTables = append(response.OnDemandTables, tableName)
+			continue
+		}
+
+		if series.ThrottleRiskCount > 0 {
+			response.ThrottleRiskAny = true
+		}
+		response.Tables = append(response.Tables, series)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, http.StatusOK, response)
 }
 
 // Helper functions
 
-func (h *TimeSeriesHandler) parseTimeSeriesParams(r *http.Request) (time.Time, time.Time, time.Duration) {
+// parseAnomalyBandParams reads whether a CloudWatch expected band was requested
+// (anomalyBand=true) and its width in standard deviations (bandWidth)
+func parseAnomalyBandParams(r *http.Request) (bool, float64, error) {
+	if r.URL.Query().Get("anomalyBand") != "true" {
+		return false, 0, nil
+	}
+
+	width := aws.DefaultAnomalyBandWidth
+	if value := r.URL.Query().Get("bandWidth"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed <= 0 || parsed > 10 {
+			return false, 0, fmt.Errorf("bandWidth must be a number of standard deviations between 0 and 10")
+		}
+		width = parsed
+	}
+	return true, width, nil
+}
+
+func (h *TimeSeriesHandler) parseTimeSeriesParams(r *http.Request) (time.Time, time.Time, time.Duration, error) {
 	// Default to last 24 hours with 1-hour intervals
 	endTime := time.Now()
 	startTime := endTime.Add(-24 * time.Hour)
 	interval := 1 * time.Hour
 
-	// Parse query parameters
-	if start := r.URL.Query().Get("start"); start != "" {
-		if t, err := time.Parse(time.RFC3339, start); err == nil {
-			startTime = t
+	// Parse query parameters; an end-relative range takes precedence over start/end
+	if value := r.URL.Query().Get("range"); value != "" {
+		span, err := timerange.ParseRelative(value)
+		if err != nil {
+			return startTime, endTime, interval, err
+		}
+		startTime = endTime.Add(-span)
+	} else {
+		if start := r.URL.Query().Get("start"); start != "" {
+			if t, err := time.Parse(time.RFC3339, start); err == nil {
+				startTime = t
+			}
 		}
-	}
 
-	if end := r.URL.Query().Get("end"); end != "" {
-		if t, err := time.Parse(time.RFC3339, end); err == nil {
-			endTime = t
+		if end := r.URL.Query().Get("end"); end != "" {
+			if t, err := time.Parse(time.RFC3339, end); err == nil {
+				endTime = t
+			}
 		}
 	}
 
 	// Parse interval (in minutes)
+	explicitInterval := false
 	if intervalStr := r.URL.Query().Get("interval"); intervalStr != "" {
 		if minutes, err := strconv.Atoi(intervalStr); err == nil && minutes > 0 {
 			interval = time.Duration(minutes) * time.Minute
+			explicitInterval = true
 		}
 	}
 
@@ -382,7 +597,39 @@ func (h *TimeSeriesHandler) parseTimeSeriesParams(r *http.Request) (time.Time, t
 		}
 	}
 
-	return startTime, endTime, interval
+	// Keep the bucket count bounded: reject an over-fine requested interval,
+	// and coarsen the default one
+	if bucketCount(timeRange, interval) > h.maxBuckets {
+		minimum := minimumInterval(timeRange, h.maxBuckets)
+		if explicitInterval {
+			return startTime, endTime, interval, fmt.Errorf(
+				"interval of %d minutes produces %d buckets over this range (max %d); use an interval of at least %d minutes",
+				int(interval.Minutes()), bucketCount(timeRange, interval), h.maxBuckets, int(minimum.Minutes()))
+		}
+		interval = minimum
+	}
+
+	return startTime, endTime, interval, nil
+}
+
+// bucketCount returns how many interval-sized buckets cover a time range
+func bucketCount(timeRange, interval time.Duration) int {
+	if interval <= 0 || timeRange <= 0 {
+		return 0
+	}
+	return int((timeRange + interval - 1) / interval)
+}
+
+// minimumInterval returns the finest standard interval that keeps a range within maxBuckets
+func minimumInterval(timeRange time.Duration, maxBuckets int) time.Duration {
+	for _, interval := range coarseIntervals {
+		if bucketCount(timeRange, interval) <= maxBuckets {
+			return interval
+		}
+	}
+	// Beyond the coarsest standard interval, round up to whole days
+	days := (timeRange/time.Duration(maxBuckets) + 24*time.Hour - 1) / (24 * time.Hour)
+	return days * 24 * time.Hour
 }
 
 func (h *TimeSeriesHandler) getMetricUnit(metricName string) string {
@@ -393,6 +640,8 @@ func (h *TimeSeriesHandler) getMetricUnit(metricName string) string {
 		return "milliseconds"
 	case "concurrent":
 		return "executions"
+	case "errorRate":
+		return "percent"
 	default:
 		return "count"
 	}
@@ -404,6 +653,8 @@ func (h *TimeSeriesHandler) getAPIMetricUnit(metricName string) string {
 		return "count"
 	case "latency":
 		return "milliseconds"
+	case "errorRate":
+		return "percent"
 	default:
 		return "count"
 	}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCumulativeSeriesIsPrefixSums(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	daily := []float64{1.25, 0, 3.5, 0.75, 2}
+
+	series := make([]TimeSeriesPoint, len(daily))
+	for i, cost := range daily {
+		series[i] = TimeSeriesPoint{Timestamp: start.AddDate(0, 0, i), Value: cost}
+	}
+
+	cumulative := cumulativeSeries(series)
+	if len(cumulative) != len(series) {
+		t.Fatalf("cumulative series has %d points, want %d", len(cumulative), len(series))
+	}
+	var prefix float64
+	for i, point := range cumulative {
+		prefix += daily[i]
+		if math.Abs(point.Value-prefix) > 1e-9 {
+			t.Errorf("point %d = %v, want prefix sum %v", i, point.Value, prefix)
+		}
+		if !point.Timestamp.Equal(series[i].Timestamp) {
+			t.Errorf("point %d timestamp = %s, want %s", i, point.Timestamp, series[i].Timestamp)
+		}
+		if i > 0 && point.Value < cumulative[i-1].Value {
+			t.Errorf("point %d = %v decreased from %v", i, point.Value, cumulative[i-1].Value)
+		}
+	}
+
+	// The daily series is left as it was
+	if series[2].Value != 3.5 {
+		t.Errorf("daily series was modified: %v", series[2].Value)
+	}
+
+	if got := cumulativeSeries(nil); len(got) != 0 {
+		t.Errorf("cumulative of no days = %v, want empty", got)
+	}
+}
+
+func TestParseTimeSeriesParamsCapsBuckets(t *testing.T) {
+	end := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	ninetyDays := "?start=" + end.AddDate(0, 0, -90).Format(time.RFC3339) + "&end=" + end.Format(time.RFC3339)
+	oneDay := "?start=" + end.AddDate(0, 0, -1).Format(time.RFC3339) + "&end=" + end.Format(time.RFC3339)
+
+	tests := []struct {
+		name         string
+		maxBuckets   int
+		query        string
+		wantInterval time.Duration
+		wantErr      string
+	}{
+		{name: "over-fine interval", query: ninetyDays + "&interval=1", wantErr: "use an interval of at least 360 minutes"},
+		{name: "coarse enough interval", query: ninetyDays + "&interval=360", wantInterval: 6 * time.Hour},
+		{name: "default interval is coarsened", query: ninetyDays, wantInterval: 6 * time.Hour},
+		{name: "reasonable interval", query: oneDay + "&interval=5", wantInterval: 5 * time.Minute},
+		{name: "configured cap", maxBuckets: 100, query: oneDay + "&interval=5", wantErr: "(max 100)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewTimeSeriesHandler(nil, tt.maxBuckets, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			_, _, interval, err := h.parseTimeSeriesParams(httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTimeSeriesParams: %v", err)
+			}
+			if interval != tt.wantInterval {
+				t.Errorf("interval = %s, want %s", interval, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestGetLambdaTimeSeriesRejectsOverFineInterval(t *testing.T) {
+	h := NewTimeSeriesHandler(nil, 0, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rec := httptest.NewRecorder()
+	h.GetLambdaTimeSeries(rec, httptest.NewRequest(http.MethodGet, "/?range=90d&interval=1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestMinimumInterval(t *testing.T) {
+	tests := []struct {
+		timeRange  time.Duration
+		maxBuckets int
+		want       time.Duration
+	}{
+		{timeRange: 2 * time.Hour, maxBuckets: 1500, want: 5 * time.Minute},
+		{timeRange: 30 * 24 * time.Hour, maxBuckets: 1500, want: time.Hour},
+		{timeRange: 365 * 24 * time.Hour, maxBuckets: 1500, want: 6 * time.Hour},
+		{timeRange: 365 * 24 * time.Hour, maxBuckets: 10, want: 37 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		got := minimumInterval(tt.timeRange, tt.maxBuckets)
+		if got != tt.want {
+			t.Errorf("minimumInterval(%s, %d) = %s, want %s", tt.timeRange, tt.maxBuckets, got, tt.want)
+		}
+		if count := bucketCount(tt.timeRange, got); count > tt.maxBuckets {
+			t.Errorf("minimumInterval(%s, %d) leaves %d buckets", tt.timeRange, tt.maxBuckets, count)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// Sources the comparable metrics are computed from; each is fetched once per window
+const (
+	compareSourceLambda     = "lambda"
+	compareSourceAPIGateway = "apigateway"
+	compareSourceDynamoDB   = "dynamodb"
+	compareSourceCost       = "cost"
+)
+
+// comparableMetrics maps each metric that can be compared across windows to its source
+var comparableMetrics = map[string]string{
+	"lambda.invocations":     compareSourceLambda,
+	"lambda.errors":          compareSourceLambda,
+	"lambda.throttles":       compareSourceLambda,
+	"lambda.errorRate":       compareSourceLambda,
+	"lambda.avgDuration":     compareSourceLambda,
+	"apigateway.requests":    compareSourceAPIGateway,
+	"apigateway.4xx":         compareSourceAPIGateway,
+	"apigateway.5xx":         compareSourceAPIGateway,
+	"apigateway.latency":     compareSourceAPIGateway,
+	"dynamodb.readCapacity":  compareSourceDynamoDB,
+	"dynamodb.writeCapacity": compareSourceDynamoDB,
+	"dynamodb.throttles":     compareSourceDynamoDB,
+	"dynamodb.errors":        compareSourceDynamoDB,
+	"cost.total":             compareSourceCost,
+}
+
+// CompareWindow is one explicit time window in a comparison request
+type CompareWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// CompareWindowsRequest is the body of a window comparison
+type CompareWindowsRequest struct {
+	WindowA CompareWindow `json:"windowA"`
+	WindowB CompareWindow `json:"windowB"`
+	Metrics []string      `json:"metrics"`
+}
+
+// WindowValues holds the requested metrics computed over one window
+type WindowValues struct {
+	Period timerange.Period   `json:"period"`
+	Values map[string]float64 `json:"values"`
+}
+
+// WindowDelta is how a metric changed from window A to window B. ChangePercent is omitted
+// when window A's value is zero.
+type WindowDelta struct {
+	Change        float64  `json:"change"`
+	ChangePercent *float64 `json:"changePercent,omitempty"`
+}
+
+// CompareWindows returns the requested metrics for two arbitrary windows side by side, with
+// each metric's change from window A to window B. The windows may overlap; each is computed
+// independently and all of them concurrently.
+func (h *AppHandler) CompareWindows(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	appID := vars["appId"]
+
+	var req CompareWindowsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	sources, err := h.validateCompareWindows(req)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	outcome := newPartialResult()
+	fetch := func(ctx context.Context, source string, startTime, endTime time.Time) (map[string]float64, error) {
+		return h.windowSourceValues(ctx, appID, source, startTime, endTime)
+	}
+	result, deltas := compareWindows(r.Context(), req, sources, fetch, outcome)
+
+	response := map[string]interface{}{
+		"appId":     appID,
+		"windowA":   result["windowA"],
+		"windowB":   result["windowB"],
+		"deltas":    deltas,
+		"timestamp": time.Now().Unix(),
+	}
+	outcome.writeJSON(w, response)
+}
+
+// windowFetcher computes every metric of one source over a window
+type windowFetcher func(ctx context.Context, source string, startTime, endTime time.Time) (map[string]float64, error)
+
+// compareWindows fetches each source over both windows, all concurrently, and returns the
+// requested metrics per window with their change from window A to window B. Sources that
+// fail are recorded in outcome and their metrics left out.
+func compareWindows(ctx context.Context, req CompareWindowsRequest, sources []string, fetch windowFetcher, outcome *partialResult) (map[string]WindowValues, map[string]WindowDelta) {
+	windows := map[string]CompareWindow{"windowA": req.WindowA, "windowB": req.WindowB}
+	values := map[string]map[string]float64{"windowA": {}, "windowB": {}}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for name, window := range windows {
+		for _, source := range sources {
+			wg.Add(1)
+			go func(name string, window CompareWindow, source string) {
+				defer wg.Done()
+				sourceValues, err := fetch(ctx, source, window.Start, window.End)
+				if err != nil {
+					outcome.failed(name+":"+source, err)
+					return
+				}
+				outcome.succeeded()
+
+				mu.Lock()
+				for metric, value := range sourceValues {
+					values[name][metric] = value
+				}
+				mu.Unlock()
+			}(name, window, source)
+		}
+	}
+	wg.Wait()
+
+	// Only the requested metrics are returned, though a source yields all of its metrics
+	result := make(map[string]WindowValues, len(windows))
+	for name, window := range windows {
+		requested := make(map[string]float64, len(req.Metrics))
+		for _, metric := range req.Metrics {
+			if value, ok := values[name][metric]; ok {
+				requested[metric] = value
+			}
+		}
+		result[name] = WindowValues{Period: timerange.NewPeriod(window.Start, window.End), Values: requested}
+	}
+
+	deltas := make(map[string]WindowDelta, len(req.Metrics))
+	for _, metric := range req.Metrics {
+		a, okA := result["windowA"].Values[metric]
+		b, okB := result["windowB"].Values[metric]
+		if !okA || !okB {
+			continue
+		}
+		delta := WindowDelta{Change: b - a}
+		if a != 0 {
+			percent := (b - a) / a * 100
+			delta.ChangePercent = &percent
+		}
+		deltas[metric] = delta
+	}
+
+	return result, deltas
+}
+
+// validateCompareWindows checks both windows and the metric list, returning the sources the
+// metrics need in a stable order
+func (h *AppHandler) validateCompareWindows(req CompareWindowsRequest) ([]string, error) {
+	for name, window := range map[string]CompareWindow{"windowA": req.WindowA, "windowB": req.WindowB} {
+		if window.Start.IsZero() || window.End.IsZero() {
+			return nil, fmt.Errorf("%s needs a start and end (RFC 3339)", name)
+		}
+		if !window.End.After(window.Start) {
+			return nil, fmt.Errorf("%s must end after it starts", name)
+		}
+	}
+	if len(req.Metrics) == 0 {
+		return nil, fmt.Errorf("metrics must list at least one of: %s", strings.Join(h.comparableMetricNames(), ", "))
+	}
+
+	needed := make(map[string]bool)
+	for _, metric := range req.Metrics {
+		source, ok := comparableMetrics[metric]
+		if !ok || !h.compareSourceEnabled(source) {
+			return nil, fmt.Errorf("unknown metric %q; supported: %s", metric, strings.Join(h.comparableMetricNames(), ", "))
+		}
+		needed[source] = true
+	}
+
+	sources := make([]string, 0, len(needed))
+	for source := range needed {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+// comparableMetricNames lists the metrics that can be compared with the enabled features
+func (h *AppHandler) comparableMetricNames() []string {
+	names := make([]string, 0, len(comparableMetrics))
+	for metric, source := range comparableMetrics {
+		if h.compareSourceEnabled(source) {
+			names = append(names, metric)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// compareSourceEnabled reports whether a source's feature is enabled
+func (h *AppHandler) compareSourceEnabled(source string) bool {
+	switch source {
+	case compareSourceLambda:
+		return h.Features.Lambda
+	case compareSourceDynamoDB:
+		return h.Features.DynamoDB
+	case compareSourceCost:
+		return h.Features.Cost
+	}
+	return true
+}
+
+// windowSourceValues computes every metric of one source over a window
+func (h *AppHandler) windowSourceValues(ctx context.Context, appID, source string, startTime, endTime time.Time) (map[string]float64, error) {
+	switch source {
+	case compareSourceLambda:
+		functions := h.ResolveLambdaFunctions(ctx, appID)
+		if len(functions) == 0 {
+			return nil, fmt.Errorf("no Lambda functions configured")
+		}
+		batch, err := h.CloudWatch.GetLambdaMetricsBatch(ctx, functions, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		return lambdaWindowValues(batch), nil
+
+	case compareSourceAPIGateway:
+		apiName := h.AppsConfig.GetAPIGateway(appID)
+		if apiName == "" {
+			return nil, fmt.Errorf("no API Gateway configured")
+		}
+		metrics, err := h.CloudWatch.GetAPIGatewayMetrics(ctx, apiName, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]float64{
+			"apigateway.requests": metrics.Count,
+			"apigateway.4xx":      metrics.Error4XX,
+			"apigateway.5xx":      metrics.Error5XX,
+			"apigateway.latency":  metrics.Latency,
+		}, nil
+
+	case compareSourceDynamoDB:
+		values := map[string]float64{
+			"dynamodb.readCapacity":  0,
+			"dynamodb.writeCapacity": 0,
+			"dynamodb.throttles":     0,
+			"dynamodb.errors":        0,
+		}
+		for _, tableName := range h.AppsConfig.GetDynamoDBTables(appID) {
+			metrics, err := h.DynamoDB.GetTableMetrics(ctx, tableName, startTime, endTime)
+			if err != nil {
+				return nil, fmt.Errorf("table %s: %w", tableName, err)
+			}
+			values["dynamodb.readCapacity"] += metrics.ConsumedReadCapacity
+			values["dynamodb.writeCapacity"] += metrics.ConsumedWriteCapacity
+			values["dynamodb.throttles"] += metrics.ThrottledRequests
+			values["dynamodb.errors"] += metrics.UserErrors + metrics.SystemErrors
+		}
+		return values, nil
+
+	case compareSourceCost:
+		costData, err := h.GetAppCosts(ctx, appID, startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]float64{"cost.total": costData.TotalCost}, nil
+	}
+	return nil, fmt.Errorf("unknown metric source %q", source)
+}
+
+// lambdaWindowValues combines every function's metrics over a window. Average duration is
+// weighted by invocations, so a busy function counts for more than an idle one.
+func lambdaWindowValues(batch map[string]*aws.LambdaMetrics) map[string]float64 {
+	var invocations, errorCount, throttles float64
+	var duration aws.WeightedMean
+	for _, metrics := range batch {
+		invocations += metrics.Invocations
+		errorCount += metrics.Errors
+		throttles += metrics.Throttles
+		duration.Add(metrics.Duration, metrics.Invocations)
+	}
+	values := map[string]float64{
+		"lambda.invocations": invocations,
+		"lambda.errors":      errorCount,
+		"lambda.throttles":   throttles,
+		"lambda.errorRate":   0,
+		"lambda.avgDuration": duration.Value(),
+	}
+	if invocations > 0 {
+		values["lambda.errorRate"] = errorCount / invocations * 100
+	}
+	return values
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jamesvolpe/central-analytics/backend/internal/aws"
+	appconfig "github.com/jamesvolpe/central-analytics/backend/internal/config"
+	"github.com/jamesvolpe/central-analytics/backend/internal/timerange"
+)
+
+// hourlyFetcher computes each metric from the window alone, so windows are independent: Lambda
+// invocations are 100 per hour of the window and API Gateway requests 10 per hour. Each fetch
+// waits until all expected fetches are in flight, proving they run concurrently.
+type hourlyFetcher struct {
+	mu       sync.Mutex
+	calls    map[string]CompareWindow
+	inFlight sync.WaitGroup
+}
+
+func newHourlyFetcher(expected int) *hourlyFetcher {
+	f := &hourlyFetcher{calls: make(map[string]CompareWindow)}
+	f.inFlight.Add(expected)
+	return f
+}
+
+func (f *hourlyFetcher) fetch(ctx context.Context, source string, startTime, endTime time.Time) (map[string]float64, error) {
+	f.mu.Lock()
+	f.calls[source+"@"+startTime.Format(time.RFC3339)] = CompareWindow{Start: startTime, End: endTime}
+	f.mu.Unlock()
+
+	f.inFlight.Done()
+	waited := make(chan struct{})
+	go func() { f.inFlight.Wait(); close(waited) }()
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		return nil, errors.New("fetches ran one after another")
+	}
+
+	hours := endTime.Sub(startTime).Hours()
+	switch source {
+	case compareSourceLambda:
+		return map[string]float64{"lambda.invocations": 100 * hours, "lambda.errors": hours}, nil
+	case compareSourceAPIGateway:
+		return map[string]float64{"apigateway.requests": 10 * hours}, nil
+	}
+	return nil, errors.New("source unavailable")
+}
+
+func TestCompareWindowsComputesEachWindowIndependently(t *testing.T) {
+	launch := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		windowA, windowB CompareWindow
+		wantA, wantB     float64 // Lambda invocations
+	}{
+		{
+			name:    "disjoint",
+			windowA: CompareWindow{Start: launch, End: launch.Add(24 * time.Hour)},
+			windowB: CompareWindow{Start: launch.AddDate(0, 0, 7), End: launch.AddDate(0, 0, 7).Add(48 * time.Hour)},
+			wantA:   2400,
+			wantB:   4800,
+		},
+		{
+			name:    "overlapping",
+			windowA: CompareWindow{Start: launch, End: launch.Add(10 * time.Hour)},
+			windowB: CompareWindow{Start: launch.Add(5 * time.Hour), End: launch.Add(20 * time.Hour)},
+			wantA:   1000,
+			wantB:   1500,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := CompareWindowsRequest{
+				WindowA: tt.windowA,
+				WindowB: tt.windowB,
+				Metrics: []string{"lambda.invocations", "apigateway.requests"},
+			}
+			fetcher := newHourlyFetcher(4)
+			outcome := newPartialResult()
+
+			result, deltas := compareWindows(context.Background(), req, []string{compareSourceAPIGateway, compareSourceLambda}, fetcher.fetch, outcome)
+			if outcome.Partial() {
+				t.Fatalf("failures = %+v", outcome.Failures())
+			}
+
+			// Each source was fetched once per window, over exactly that window
+			if len(fetcher.calls) != 4 {
+				t.Errorf("fetched %d times, want each of 2 sources per window", len(fetcher.calls))
+			}
+			for _, window := range []CompareWindow{tt.windowA, tt.windowB} {
+				for _, source := range []string{compareSourceLambda, compareSourceAPIGateway} {
+					if got := fetcher.calls[source+"@"+window.Start.Format(time.RFC3339)]; got != window {
+						t.Errorf("%s fetched over %+v, want %+v", source, got, window)
+					}
+				}
+			}
+
+			a, b := result["windowA"], result["windowB"]
+			if a.Values["lambda.invocations"] != tt.wantA || b.Values["lambda.invocations"] != tt.wantB {
+				t.Errorf("invocations = %v and %v, want %v and %v", a.Values["lambda.invocations"], b.Values["lambda.invocations"], tt.wantA, tt.wantB)
+			}
+			if a.Values["apigateway.requests"] != tt.wantA/10 || b.Values["apigateway.requests"] != tt.wantB/10 {
+				t.Errorf("requests = %v and %v, want %v and %v", a.Values["apigateway.requests"], b.Values["apigateway.requests"], tt.wantA/10, tt.wantB/10)
+			}
+			if a.Period != timerange.NewPeriod(tt.windowA.Start, tt.windowA.End) || b.Period != timerange.NewPeriod(tt.windowB.Start, tt.windowB.End) {
+				t.Errorf("periods = %+v and %+v, want the requested windows", a.Period, b.Period)
+			}
+
+			// Metrics a source yields but that weren't requested are left out
+			if _, ok := a.Values["lambda.errors"]; ok {
+				t.Error("unrequested lambda.errors was returned")
+			}
+
+			delta := deltas["lambda.invocations"]
+			wantPercent := (tt.wantB - tt.wantA) / tt.wantA * 100
+			if delta.Change != tt.wantB-tt.wantA || delta.ChangePercent == nil || math.Abs(*delta.ChangePercent-wantPercent) > 1e-9 {
+				t.Errorf("delta = %+v, want change %v (%v%%)", delta, tt.wantB-tt.wantA, wantPercent)
+			}
+		})
+	}
+}
+
+func TestCompareWindowsPartialFailure(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	req := CompareWindowsRequest{
+		WindowA: CompareWindow{Start: start, End: start.Add(time.Hour)},
+		WindowB: CompareWindow{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour)},
+		Metrics: []string{"lambda.invocations", "cost.total"},
+	}
+	fetcher := newHourlyFetcher(4)
+	outcome := newPartialResult()
+
+	result, deltas := compareWindows(context.Background(), req, []string{compareSourceCost, compareSourceLambda}, fetcher.fetch, outcome)
+
+	if !outcome.Partial() || len(outcome.Failures()) != 2 {
+		t.Errorf("failures = %+v, want cost in both windows", outcome.Failures())
+	}
+	if _, ok := result["windowA"].Values["cost.total"]; ok {
+		t.Error("failed cost.total was returned")
+	}
+	if _, ok := deltas["cost.total"]; ok {
+		t.Error("failed cost.total has a delta")
+	}
+	if delta, ok := deltas["lambda.invocations"]; !ok || delta.Change != 0 || delta.ChangePercent == nil || *delta.ChangePercent != 0 {
+		t.Errorf("invocations delta = %+v, want no change", delta)
+	}
+}
+
+func TestCompareWindowsValidatesRequest(t *testing.T) {
+	h := &AppHandler{
+		AppsConfig: &appconfig.AppsConfiguration{Apps: map[string]*appconfig.AppConfig{"app": {ID: "app"}}},
+		Features:   appconfig.FeatureFlags{Lambda: true},
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	windows := `"windowA":{"start":"2024-05-01T00:00:00Z","end":"2024-05-02T00:00:00Z"},"windowB":{"start":"2024-05-08T00:00:00Z","end":"2024-05-09T00:00:00Z"}`
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "not JSON", body: "windows"},
+		{name: "no metrics", body: `{` + windows + `}`},
+		{name: "unknown metric", body: `{` + windows + `,"metrics":["lambda.coldStarts"]}`},
+		{name: "disabled source", body: `{` + windows + `,"metrics":["cost.total"]}`},
+		{name: "missing window", body: `{"windowA":{"start":"2024-05-01T00:00:00Z","end":"2024-05-02T00:00:00Z"},"metrics":["lambda.invocations"]}`},
+		{name: "window ends before it starts", body: `{"windowA":{"start":"2024-05-02T00:00:00Z","end":"2024-05-01T00:00:00Z"},"windowB":{"start":"2024-05-08T00:00:00Z","end":"2024-05-09T00:00:00Z"},"metrics":["lambda.invocations"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)), map[string]string{"appId": "app"})
+			rec := httptest.NewRecorder()
+			h.CompareWindows(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400: %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestLambdaWindowValuesWeightDuration(t *testing.T) {
+	values := lambdaWindowValues(map[string]*aws.LambdaMetrics{
+		"checkout": {Invocations: 1000, Errors: 10, Duration: 800},
+		"cron":     {Invocations: 10, Errors: 0, Duration: 50, Throttles: 2},
+		"idle":     {Duration: 3000},
+	})
+
+	want := map[string]float64{
+		"lambda.invocations": 1010,
+		"lambda.errors":      10,
+		"lambda.throttles":   2,
+		"lambda.errorRate":   10.0 / 1010 * 100,
+		// An equal-weight mean across the three would be 1283ms
+		"lambda.avgDuration": (800*1000 + 50*10) / 1010.0,
+	}
+	for metric, w := range want {
+		if got := values[metric]; math.Abs(got-w) > 1e-9 {
+			t.Errorf("%s = %v, want %v", metric, got, w)
+		}
+	}
+}
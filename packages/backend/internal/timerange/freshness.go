@@ -0,0 +1,29 @@
+package timerange
+
+import "time"
+
+// Freshness describes how current a set of metrics is
+type Freshness struct {
+	DataAsOf *time.Time `json:"dataAsOf"`
+	Stale    bool       `json:"stale"`
+}
+
+// NewFreshness reports the newest datapoint and whether it lags the reference time by more than window.
+// The reference is the end of the requested range, capped at now, so historical ranges aren't flagged.
+// A zero latest time means no data was returned, which is always considered stale.
+func NewFreshness(latest time.Time, window time.Duration, endTime time.Time) Freshness {
+	reference := endTime
+	if now := time.Now(); reference.After(now) {
+		reference = now
+	}
+
+	if latest.IsZero() {
+		return Freshness{Stale: true}
+	}
+
+	dataAsOf := latest.UTC()
+	return Freshness{
+		DataAsOf: &dataAsOf,
+		Stale:    reference.Sub(latest) > window,
+	}
+}
@@ -0,0 +1,41 @@
+package timerange
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewFreshness(t *testing.T) {
+	now := time.Now()
+	window := 10 * time.Minute
+	historicalEnd := now.Add(-72 * time.Hour)
+
+	tests := []struct {
+		name      string
+		latest    time.Time
+		endTime   time.Time
+		wantStale bool
+	}{
+		{name: "within the window", latest: now.Add(-4 * time.Minute), endTime: now, wantStale: false},
+		{name: "lagging the window", latest: now.Add(-25 * time.Minute), endTime: now, wantStale: true},
+		{name: "range ending in the future is measured from now", latest: now.Add(-25 * time.Minute), endTime: now.Add(time.Hour), wantStale: true},
+		{name: "historical range is measured from its end", latest: historicalEnd.Add(-5 * time.Minute), endTime: historicalEnd, wantStale: false},
+		{name: "historical range lagging its end", latest: historicalEnd.Add(-time.Hour), endTime: historicalEnd, wantStale: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freshness := NewFreshness(tt.latest, window, tt.endTime)
+			if freshness.DataAsOf == nil || !freshness.DataAsOf.Equal(tt.latest) {
+				t.Errorf("DataAsOf = %v, want %v", freshness.DataAsOf, tt.latest)
+			}
+			if freshness.Stale != tt.wantStale {
+				t.Errorf("Stale = %v, want %v", freshness.Stale, tt.wantStale)
+			}
+		})
+	}
+
+	if freshness := NewFreshness(time.Time{}, window, now); freshness.DataAsOf != nil || !freshness.Stale {
+		t.Errorf("freshness without data = %+v, want stale with no dataAsOf", freshness)
+	}
+}
@@ -0,0 +1,47 @@
+package timerange
+
+import (
+	"time"
+)
+
+// Period describes the time window covered by a metrics response
+type Period struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Duration string `json:"duration"`
+	Interval string `json:"interval,omitempty"`
+	Display  string `json:"display"`
+}
+
+// NewPeriod creates a period for the given time range
+func NewPeriod(startTime, endTime time.Time) Period {
+	return Period{
+		Start:    startTime.UTC().Format(time.RFC3339),
+		End:      endTime.UTC().Format(time.RFC3339),
+		Duration: endTime.Sub(startTime).String(),
+		Display:  FormatDisplay(startTime, endTime),
+	}
+}
+
+// NewIntervalPeriod creates a period for a range bucketed at the given interval
+func NewIntervalPeriod(startTime, endTime time.Time, interval time.Duration) Period {
+	p := NewPeriod(startTime, endTime)
+	p.Interval = interval.String()
+	return p
+}
+
+// NewDatePeriod creates a period for a date range (e.g. Cost Explorer, App Store reports)
+func NewDatePeriod(startDate, endDate time.Time) Period {
+	p := NewPeriod(startDate, endDate)
+	p.Display = startDate.Format("2006-01-02") + " to " + endDate.Format("2006-01-02")
+	return p
+}
+
+// FormatDisplay returns a human readable description of the time range
+func FormatDisplay(startTime, endTime time.Time) string {
+	return formatTime(startTime) + " to " + formatTime(endTime)
+}
+
+func formatTime(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
+}
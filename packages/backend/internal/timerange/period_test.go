@@ -0,0 +1,41 @@
+package timerange
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewPeriod(t *testing.T) {
+	start := time.Date(2024, 5, 1, 2, 30, 0, 0, time.FixedZone("EDT", -4*60*60))
+	end := start.Add(36 * time.Hour)
+
+	tests := []struct {
+		name   string
+		period Period
+		want   Period
+	}{
+		{
+			name:   "range",
+			period: NewPeriod(start, end),
+			want:   Period{Start: "2024-05-01T06:30:00Z", End: "2024-05-02T18:30:00Z", Duration: "36h0m0s", Display: "2024-05-01 02:30:00 to 2024-05-02 14:30:00"},
+		},
+		{
+			name:   "interval",
+			period: NewIntervalPeriod(start, end, 5*time.Minute),
+			want:   Period{Start: "2024-05-01T06:30:00Z", End: "2024-05-02T18:30:00Z", Duration: "36h0m0s", Interval: "5m0s", Display: "2024-05-01 02:30:00 to 2024-05-02 14:30:00"},
+		},
+		{
+			name:   "dates",
+			period: NewDatePeriod(start, end),
+			want:   Period{Start: "2024-05-01T06:30:00Z", End: "2024-05-02T18:30:00Z", Duration: "36h0m0s", Display: "2024-05-01 to 2024-05-02"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.period != tt.want {
+				t.Errorf("period = %+v, want %+v", tt.period, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package timerange
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// MaxRelativeRange is the longest range a shorthand may cover
+const MaxRelativeRange = 90 * 24 * time.Hour
+
+// relativeUnits maps shorthand suffixes to their duration
+var relativeUnits = map[byte]time.Duration{
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// ParseRelative parses an end-relative range shorthand such as 1h, 6h, 24h, 7d or 30d
+func ParseRelative(value string) (time.Duration, error) {
+	if len(value) < 2 {
+		return 0, fmt.Errorf("invalid range %q: use a count and unit such as 6h or 7d", value)
+	}
+	unit, ok := relativeUnits[value[len(value)-1]]
+	count, err := strconv.Atoi(value[:len(value)-1])
+	if !ok || err != nil || count <= 0 {
+		return 0, fmt.Errorf("invalid range %q: use a count and unit such as 6h or 7d", value)
+	}
+
+	span := time.Duration(count) * unit
+	if span > MaxRelativeRange {
+		return 0, fmt.Errorf("range %q exceeds the maximum of %d days", value, int(MaxRelativeRange.Hours()/24))
+	}
+	return span, nil
+}
@@ -0,0 +1,45 @@
+package timerange
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRelative(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "1h", want: time.Hour},
+		{value: "6h", want: 6 * time.Hour},
+		{value: "24h", want: day},
+		{value: "7d", want: 7 * day},
+		{value: "30d", want: 30 * day},
+		{value: "15m", want: 15 * time.Minute},
+		{value: "2w", want: 14 * day},
+		{value: "90d", want: MaxRelativeRange},
+		{value: "91d", wantErr: true},
+		{value: "0h", wantErr: true},
+		{value: "-1h", wantErr: true},
+		{value: "h", wantErr: true},
+		{value: "7", wantErr: true},
+		{value: "1y", wantErr: true},
+		{value: "1.5h", wantErr: true},
+		{value: "week", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRelative(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRelative(%q) = %s, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRelative(%q) = %s, %v, want %s", tt.value, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+// Package version holds build metadata injected at link time, e.g.
+//
+//	go build -ldflags "-X github.com/jamesvolpe/central-analytics/backend/internal/version.Version=1.2.0"
+package version
+
+import "runtime/debug"
+
+// Build metadata, overridden with -ldflags "-X ..."
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"buildTime"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build metadata, falling back to the VCS stamp Go embeds
+// when the commit or build time weren't injected
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = buildInfo.GoVersion
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildTime == "" {
+					info.BuildTime = setting.Value
+				}
+			}
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,149 @@
+package workers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Registry tracks background workers so their health can be inspected
+type Registry struct {
+	mu      sync.RWMutex
+	workers map[string]*Worker
+}
+
+// Worker records the run history of a single background worker
+type Worker struct {
+	mu        sync.Mutex
+	name      string
+	interval  time.Duration
+	startedAt time.Time
+	lastRun   time.Time
+	lastError string
+	runCount  int64
+	running   bool
+}
+
+// WorkerStatus represents a point-in-time view of a worker
+type WorkerStatus struct {
+	Name      string     `json:"name"`
+	Interval  string     `json:"interval,omitempty"`
+	StartedAt time.Time  `json:"startedAt"`
+	LastRun   *time.Time `json:"lastRun"`
+	LastError string     `json:"lastError,omitempty"`
+	RunCount  int64      `json:"runCount"`
+	Running   bool       `json:"running"`
+}
+
+// NewRegistry creates an empty worker registry
+func NewRegistry() *Registry {
+	return &Registry{
+		workers: make(map[string]*Worker),
+	}
+}
+
+// Register adds a worker to the registry, returning the existing worker if the name is taken
+func (r *Registry) Register(name string, interval time.Duration) *Worker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if worker, ok := r.workers[name]; ok {
+		return worker
+	}
+
+	worker := &Worker{
+		name:      name,
+		interval:  interval,
+		startedAt: time.Now(),
+	}
+	r.workers[name] = worker
+	return worker
+}
+
+// Statuses returns every registered worker's status ordered by name
+func (r *Registry) Statuses() []WorkerStatus {
+	r.mu.RLock()
+	workers := make([]*Worker, 0, len(r.workers))
+	for _, worker := range r.workers {
+		workers = append(workers, worker)
+	}
+	r.mu.RUnlock()
+
+	statuses := make([]WorkerStatus, 0, len(workers))
+	for _, worker := range workers {
+		statuses = append(statuses, worker.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// RunEvery calls fn immediately and then on every interval until ctx is cancelled,
+// recording each run on the worker. Workers without an interval run once.
+func (w *Worker) RunEvery(ctx context.Context, fn func(context.Context) error) {
+	if w.interval <= 0 {
+		w.Run(ctx, fn)
+		return
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.Run(ctx, fn)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run calls fn once and records the outcome
+func (w *Worker) Run(ctx context.Context, fn func(context.Context) error) {
+	w.mu.Lock()
+	w.running = true
+	w.mu.Unlock()
+
+	err := fn(ctx)
+	w.RecordRun(err)
+}
+
+// RecordRun records a completed run for workers that manage their own loop
+func (w *Worker) RecordRun(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.running = false
+	w.lastRun = time.Now()
+	w.runCount++
+	w.lastError = ""
+	if err != nil {
+		w.lastError = err.Error()
+	}
+}
+
+// Status returns the worker's current status
+func (w *Worker) Status() WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := WorkerStatus{
+		Name:      w.name,
+		StartedAt: w.startedAt,
+		LastError: w.lastError,
+		RunCount:  w.runCount,
+		Running:   w.running,
+	}
+	if w.interval > 0 {
+		status.Interval = w.interval.String()
+	}
+	if !w.lastRun.IsZero() {
+		lastRun := w.lastRun
+		status.LastRun = &lastRun
+	}
+	return status
+}
@@ -0,0 +1,93 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWorkerStatusUpdatesAfterRuns(t *testing.T) {
+	registry := NewRegistry()
+	worker := registry.Register("snapshot", time.Hour)
+
+	status := worker.Status()
+	if status.Name != "snapshot" || status.Interval != "1h0m0s" || status.RunCount != 0 || status.LastRun != nil || status.Running {
+		t.Fatalf("status before any run = %+v", status)
+	}
+
+	var during WorkerStatus
+	worker.Run(context.Background(), func(ctx context.Context) error {
+		during = worker.Status()
+		return errors.New("table not found")
+	})
+	if !during.Running {
+		t.Error("worker not reported as running during its run")
+	}
+	status = worker.Status()
+	if status.Running || status.RunCount != 1 || status.LastRun == nil || status.LastError != "table not found" {
+		t.Errorf("status after a failed run = %+v", status)
+	}
+
+	firstRun := *status.LastRun
+	worker.Run(context.Background(), func(ctx context.Context) error { return nil })
+	status = worker.Status()
+	if status.RunCount != 2 || status.LastError != "" || status.LastRun.Before(firstRun) {
+		t.Errorf("status after a successful run = %+v", status)
+	}
+}
+
+func TestRegistryStatuses(t *testing.T) {
+	registry := NewRegistry()
+	if statuses := registry.Statuses(); len(statuses) != 0 {
+		t.Fatalf("empty registry reports %+v", statuses)
+	}
+
+	prune := registry.Register("retention-prune", 24*time.Hour)
+	registry.Register("alert-check", 5*time.Minute)
+	if again := registry.Register("retention-prune", time.Minute); again != prune {
+		t.Error("registering a taken name created a second worker")
+	}
+	prune.RecordRun(nil)
+
+	statuses := registry.Statuses()
+	if len(statuses) != 2 || statuses[0].Name != "alert-check" || statuses[1].Name != "retention-prune" {
+		t.Fatalf("statuses = %+v, want alert-check then retention-prune", statuses)
+	}
+	if statuses[0].RunCount != 0 || statuses[1].RunCount != 1 || statuses[1].Interval != "24h0m0s" {
+		t.Errorf("statuses = %+v", statuses)
+	}
+}
+
+func TestRunEvery(t *testing.T) {
+	registry := NewRegistry()
+
+	once := registry.Register("once", 0)
+	once.RunEvery(context.Background(), func(ctx context.Context) error { return nil })
+	if got := once.Status().RunCount; got != 1 {
+		t.Errorf("worker without an interval ran %d times, want once", got)
+	}
+
+	ticking := registry.Register("ticking", time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticking.RunEvery(ctx, func(ctx context.Context) error {
+			if runs++; runs == 3 {
+				cancel()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunEvery didn't stop after its context was cancelled")
+	}
+	if got := ticking.Status().RunCount; got != 3 {
+		t.Errorf("ticking worker ran %d times, want 3", got)
+	}
+}
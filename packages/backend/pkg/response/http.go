@@ -0,0 +1,47 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// envelope makes WriteJSON and WriteError wrap bodies in StandardResponse, as the Lambda
+// handlers do. Off by default, so HTTP handlers return bare objects.
+var envelope bool
+
+// SetEnvelope selects whether HTTP responses are wrapped in StandardResponse. Call before
+// serving requests.
+func SetEnvelope(enabled bool) {
+	envelope = enabled
+}
+
+// WriteJSON writes data as JSON with statusCode, wrapped in the standard envelope when it's
+// enabled. The envelope reports success only for statuses below 400, so a body describing a
+// failure, such as an all-failed partial result, is still marked unsuccessful.
+func WriteJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	if envelope {
+		writeBody(w, statusCode, StandardResponse{Success: statusCode < http.StatusBadRequest, Data: data})
+		return
+	}
+	writeBody(w, statusCode, data)
+}
+
+// WriteError writes an error response. With the envelope enabled it's a StandardResponse
+// carrying message; otherwise it's message as plain text, like http.Error.
+func WriteError(w http.ResponseWriter, message string, statusCode int) {
+	if !envelope {
+		http.Error(w, message, statusCode)
+		return
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeBody(w, statusCode, StandardResponse{Success: false, Error: message})
+}
+
+// writeBody writes body as JSON with statusCode
+func writeBody(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
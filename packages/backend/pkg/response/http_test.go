@@ -0,0 +1,123 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withEnvelope sets the envelope mode for the rest of the test
+func withEnvelope(t *testing.T, enabled bool) {
+	t.Helper()
+	SetEnvelope(enabled)
+	t.Cleanup(func() { SetEnvelope(false) })
+}
+
+func TestWriteJSONEnvelopeModes(t *testing.T) {
+	data := map[string]interface{}{"appId": "app", "downloads": 42}
+
+	tests := []struct {
+		name     string
+		envelope bool
+		want     string
+	}{
+		{name: "bare", want: `{"appId":"app","downloads":42}`},
+		{name: "enveloped", envelope: true, want: `{"success":true,"data":{"appId":"app","downloads":42}}`},
+	}
+	// A body written with an error status is not a success, whatever it holds
+	failures := []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusBadGateway}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withEnvelope(t, tt.envelope)
+
+			rec := httptest.NewRecorder()
+			WriteJSON(rec, http.StatusCreated, data)
+
+			if rec.Code != http.StatusCreated {
+				t.Errorf("status = %d, want 201", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	withEnvelope(t, true)
+	for _, status := range failures {
+		rec := httptest.NewRecorder()
+		WriteJSON(rec, status, data)
+
+		want := `{"success":false,"data":{"appId":"app","downloads":42}}`
+		if got := strings.TrimSpace(rec.Body.String()); rec.Code != status || got != want {
+			t.Errorf("WriteJSON with %d = %d %s, want %d %s", status, rec.Code, got, status, want)
+		}
+	}
+}
+
+func TestWriteErrorEnvelopeModes(t *testing.T) {
+	t.Run("bare", func(t *testing.T) {
+		withEnvelope(t, false)
+
+		rec := httptest.NewRecorder()
+		WriteError(rec, "interval must be positive", http.StatusBadRequest)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain") {
+			t.Errorf("Content-Type = %q, want plain text like http.Error", got)
+		}
+		if got := strings.TrimSpace(rec.Body.String()); got != "interval must be positive" {
+			t.Errorf("body = %q, want the message", got)
+		}
+	})
+
+	t.Run("enveloped", func(t *testing.T) {
+		withEnvelope(t, true)
+
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Length", "100")
+		WriteError(rec, "interval must be positive", http.StatusBadRequest)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", rec.Code)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+		if got := rec.Header().Get("Content-Length"); got != "" {
+			t.Errorf("Content-Length = %q, want it dropped", got)
+		}
+		want := `{"success":false,"error":"interval must be positive"}`
+		if got := strings.TrimSpace(rec.Body.String()); got != want {
+			t.Errorf("body = %s, want %s", got, want)
+		}
+	})
+}
+
+func TestEnvelopeMatchesLambdaResponses(t *testing.T) {
+	withEnvelope(t, true)
+	data := map[string]string{"status": "healthy"}
+
+	rec := httptest.NewRecorder()
+	WriteJSON(rec, http.StatusOK, data)
+
+	var httpBody, lambdaBody StandardResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &httpBody); err != nil {
+		t.Fatalf("decode HTTP body: %v", err)
+	}
+	if err := json.Unmarshal([]byte(Success(http.StatusOK, data).Body), &lambdaBody); err != nil {
+		t.Fatalf("decode Lambda body: %v", err)
+	}
+	httpJSON, _ := json.Marshal(httpBody)
+	lambdaJSON, _ := json.Marshal(lambdaBody)
+	if string(httpJSON) != string(lambdaJSON) {
+		t.Errorf("HTTP body %s, Lambda body %s, want the same shape", httpJSON, lambdaJSON)
+	}
+}
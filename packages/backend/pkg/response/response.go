@@ -65,4 +65,4 @@ func Raw(statusCode int, body interface{}) events.APIGatewayProxyResponse {
 		Headers:    Headers(),
 		Body:       string(bodyBytes),
 	}
-}
\ No newline at end of file
+}
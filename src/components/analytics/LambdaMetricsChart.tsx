@@ -142,7 +142,7 @@ export const LambdaMetricsChart: React.FC<LambdaMetricsChartProps> = ({
     <>
       <ChartContainer
         title="Lambda Functions"
-        subtitle={data?.metadata?.period || undefined}
+        subtitle={data?.metadata?.period?.display || undefined}
         loading={isLoading}
         error={error}
         onRetry={refetch}
@@ -501,9 +501,9 @@ class AppleAuthenticationSDK {
       expires: 1 // 1 day
     });
 
-    // Store refresh token in httpOnly cookie (server should handle this)
+    // Refresh tokens are single-use; each refresh returns a replacement
     if (refreshToken) {
-      // This should be set by the server as httpOnly
+      sessionStorage.setItem('refresh_token', refreshToken);
       sessionStorage.setItem('has_refresh_token', 'true');
     }
   }
@@ -513,6 +513,7 @@ class AppleAuthenticationSDK {
    */
   clearAuthTokens(): void {
     Cookies.remove('access_token');
+    sessionStorage.removeItem('refresh_token');
     sessionStorage.removeItem('has_refresh_token');
     sessionStorage.removeItem('apple_auth_state');
     localStorage.removeItem('biometric_credential_id');
@@ -524,6 +525,13 @@ class AppleAuthenticationSDK {
   getAccessToken(): string | undefined {
     return Cookies.get('access_token');
   }
+
+  /**
+   * Get the current refresh token
+   */
+  getRefreshToken(): string | null {
+    return sessionStorage.getItem('refresh_token');
+  }
 }
 
 // Export singleton instance
@@ -366,8 +366,9 @@ export const useAuthStore = create<AuthState>()(
           const response = await fetch(`${API_BASE_URL}/api/auth/refresh`, {
             method: 'POST',
             headers: {
-              'Authorization': `Bearer ${appleAuth.getAccessToken()}`,
+              'Content-Type': 'application/json',
             },
+            body: JSON.stringify({ refreshToken: appleAuth.getRefreshToken() }),
           });
 
           if (!response.ok) {
@@ -403,12 +404,12 @@ export const useAuthStore = create<AuthState>()(
   )
 );
 
-// Auto-refresh session every 30 minutes
+// Auto-refresh session every 10 minutes, ahead of the 15-minute access token expiry
 if (typeof window !== 'undefined') {
   setInterval(() => {
     const state = useAuthStore.getState();
     if (state.isAuthenticated && state.user) {
       state.refreshSession();
     }
-  }, 30 * 60 * 1000);
+  }, 10 * 60 * 1000);
 }
\ No newline at end of file
@@ -141,28 +141,50 @@ export interface UserEngagement {
   retentionDay30: number;
 }
 
+// Structured time window returned by the backend (RFC3339 start/end)
+export interface Period {
+  start: string;
+  end: string;
+  duration: string;
+  interval?: string;
+  display: string;
+}
+
 // Aggregated Metrics from Backend
 export interface AggregatedMetrics {
   appId: string;
-  period: string;
+  period: Period;
   aws: AWSMetricsSummary;
-  appStore: AppStoreMetricsSummary;
+  /** null when the app has no App Store ID configured */
+  appStore: AppStoreMetricsSummary | null;
   health: HealthSummary;
+  partial: boolean;
+  failures: ResourceFailure[];
   timestamp: number;
 }
 
+// A resource whose data could not be fetched
+export interface ResourceFailure {
+  resource: string;
+  error: string;
+}
+
 // AWS Metrics Summary
+// Lambda, API Gateway and DynamoDB are null when the app has nothing configured for them
 export interface AWSMetricsSummary {
-  lambda: LambdaSummary;
-  apiGateway: APIGatewaySummary;
-  dynamoDB: DynamoDBSummary;
+  lambda: LambdaSummary | null;
+  apiGateway: APIGatewaySummary | null;
+  dynamoDB: DynamoDBSummary | null;
   cost: CostSummary;
 }
 
 export interface LambdaSummary {
   totalInvocations: number;
   totalErrors: number;
+  /** Raw error rate; async retries are counted as separate errors */
   errorRate: number;
+  /** Error rate over unique events with async retries removed */
+  adjustedErrorRate: number;
   averageDuration: number;
   totalThrottles: number;
   functionCount: number;
@@ -174,6 +196,9 @@ export interface APIGatewaySummary {
   total5xxErrors: number;
   errorRate: number;
   averageLatency: number;
+  latencyP50: number;
+  latencyP95: number;
+  latencyP99: number;
 }
 
 export interface DynamoDBSummary {
@@ -6,6 +6,8 @@
 export interface User {
   id: string;
   email?: string;
+  /** True when email is an Apple Hide My Email relay address; key users by appleUserSub */
+  isPrivateRelay?: boolean;
   name?: string;
   appleUserSub: string;
   isAdmin: boolean;